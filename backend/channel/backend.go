@@ -32,7 +32,6 @@
 package channel
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -44,7 +43,14 @@ import (
 )
 
 func init() {
-	omnistorage.Register("channel", NewFromConfig)
+	omnistorage.Register("channel", NewFromConfig,
+		omnistorage.WithDescription("In-process streaming between goroutines"),
+		omnistorage.WithConfigKeys(
+			omnistorage.ConfigKey{Name: "buffer_size", Description: "Channel buffer size", Default: "100"},
+			omnistorage.ConfigKey{Name: "persistent", Description: "Buffer data for late readers", Default: "false"},
+			omnistorage.ConfigKey{Name: "max_buffered_messages", Description: "Messages retained per channel in persistent mode", Default: "0"},
+			omnistorage.ConfigKey{Name: "max_buffered_bytes", Description: "Bytes retained per channel in persistent mode", Default: "0"},
+		))
 }
 
 // Message represents a message sent through a channel.
@@ -57,17 +63,22 @@ type Message struct {
 type channelInfo struct {
 	ch       chan []byte
 	closed   bool
-	buffered [][]byte // Buffered data for readers that connect after writes
+	buffered [][]byte      // Buffered data for readers that connect after writes
+	first    int64         // Offset of buffered[0]
+	size     int64         // Total bytes in buffered
+	notify   chan struct{} // Closed and replaced when data is buffered or the channel closes
 	mu       sync.RWMutex
 }
 
 // Backend implements omnistorage.Backend using Go channels.
 type Backend struct {
-	channels   map[string]*channelInfo
-	bufferSize int  // Channel buffer size
-	persistent bool // Whether to buffer data for late readers
-	closed     bool
-	mu         sync.RWMutex
+	channels    map[string]*channelInfo
+	bufferSize  int   // Channel buffer size
+	persistent  bool  // Whether to buffer data for late readers
+	maxMessages int   // Messages retained per channel in persistent mode
+	maxBytes    int64 // Bytes retained per channel in persistent mode
+	closed      bool
+	mu          sync.RWMutex
 }
 
 // Option configures a channel backend.
@@ -90,6 +101,25 @@ func WithPersistence(enabled bool) Option {
 	}
 }
 
+// WithMaxBufferedMessages limits the messages retained per channel in
+// persistent mode. When the limit is exceeded, the oldest messages are
+// dropped. Default is 0 (unlimited).
+func WithMaxBufferedMessages(n int) Option {
+	return func(b *Backend) {
+		b.maxMessages = n
+	}
+}
+
+// WithMaxBufferedBytes limits the bytes retained per channel in
+// persistent mode. When the limit is exceeded, the oldest messages are
+// dropped; the newest message is always retained. Default is 0
+// (unlimited).
+func WithMaxBufferedBytes(n int64) Option {
+	return func(b *Backend) {
+		b.maxBytes = n
+	}
+}
+
 // New creates a new channel backend with optional configuration.
 func New(opts ...Option) *Backend {
 	b := &Backend{
@@ -107,6 +137,8 @@ func New(opts ...Option) *Backend {
 // Supported options:
 //   - buffer_size: Channel buffer size (default: 100)
 //   - persistent: Buffer data for late readers (default: false)
+//   - max_buffered_messages: Messages retained per channel (default: 0, unlimited)
+//   - max_buffered_bytes: Bytes retained per channel (default: 0, unlimited)
 func NewFromConfig(config map[string]string) (omnistorage.Backend, error) {
 	var opts []Option
 
@@ -123,6 +155,20 @@ func NewFromConfig(config map[string]string) (omnistorage.Backend, error) {
 		}
 	}
 
+	if s, ok := config["max_buffered_messages"]; ok {
+		var n int
+		if _, err := fmt.Sscanf(s, "%d", &n); err == nil && n > 0 {
+			opts = append(opts, WithMaxBufferedMessages(n))
+		}
+	}
+
+	if s, ok := config["max_buffered_bytes"]; ok {
+		var n int64
+		if _, err := fmt.Sscanf(s, "%d", &n); err == nil && n > 0 {
+			opts = append(opts, WithMaxBufferedBytes(n))
+		}
+	}
+
 	return New(opts...), nil
 }
 
@@ -136,7 +182,8 @@ func (b *Backend) getOrCreateChannel(path string) *channelInfo {
 	}
 
 	info := &channelInfo{
-		ch: make(chan []byte, b.bufferSize),
+		ch:     make(chan []byte, b.bufferSize),
+		notify: make(chan struct{}),
 	}
 	b.channels[path] = info
 	return info
@@ -169,6 +216,11 @@ func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorag
 
 // NewReader creates a reader for the given path.
 // It receives data from any writers on the same path.
+//
+// In persistent mode, every reader receives every message, starting at the
+// position set by FromStart (the default), FromOffset or TailOnly, and
+// reads until the writer closes the channel. Otherwise, each message goes
+// to one reader.
 func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
 	if err := b.checkClosed(); err != nil {
 		return nil, err
@@ -191,22 +243,22 @@ func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorag
 		ctx:     ctx,
 	}
 
-	// If persistent mode, replay buffered data
+	// If persistent mode, read the buffered log from the replay position
 	if b.persistent {
+		config := omnistorage.ApplyReaderOptions(opts...)
+		pos, _ := config.Extensions[replayKey{}].(replay)
+
 		info.mu.RLock()
-		if len(info.buffered) > 0 {
-			// Concatenate all buffered data
-			var total int
-			for _, d := range info.buffered {
-				total += len(d)
-			}
-			combined := make([]byte, 0, total)
-			for _, d := range info.buffered {
-				combined = append(combined, d...)
-			}
-			reader.buffer = bytes.NewBuffer(combined)
+		switch {
+		case pos.tail:
+			reader.next = info.first + int64(len(info.buffered))
+		case pos.offset > info.first:
+			reader.next = pos.offset
+		default:
+			reader.next = info.first
 		}
 		info.mu.RUnlock()
+		reader.log = true
 	}
 
 	return reader, nil
@@ -243,19 +295,15 @@ func (b *Backend) Delete(ctx context.Context, path string) error {
 	defer b.mu.Unlock()
 
 	if info, exists := b.channels[path]; exists {
-		info.mu.Lock()
-		if !info.closed {
-			close(info.ch)
-			info.closed = true
-		}
-		info.mu.Unlock()
+		info.close()
 		delete(b.channels, path)
 	}
 
 	return nil
 }
 
-// List returns all channel paths with the given prefix.
+// List returns the paths of the channels under the directory prefix,
+// relative to it.
 func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	if err := b.checkClosed(); err != nil {
 		return nil, err
@@ -268,10 +316,12 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
+	prefix = omnistorage.CleanPrefix(prefix)
+
 	var paths []string
 	for path := range b.channels {
-		if prefix == "" || strings.HasPrefix(path, prefix) {
-			paths = append(paths, path)
+		if rel, ok := strings.CutPrefix(path, prefix); ok {
+			paths = append(paths, rel)
 		}
 	}
 
@@ -292,19 +342,15 @@ func (b *Backend) Close() error {
 
 	// Close all channels
 	for _, info := range b.channels {
-		info.mu.Lock()
-		if !info.closed {
-			close(info.ch)
-			info.closed = true
-		}
-		info.mu.Unlock()
+		info.close()
 	}
 
 	b.channels = nil
 	return nil
 }
 
-// Broadcast sends data to all channels matching a prefix.
+// Broadcast sends data to all channels matching a prefix. In persistent
+// mode the data is appended to each channel's log.
 func (b *Backend) Broadcast(ctx context.Context, prefix string, data []byte) error {
 	if err := b.checkClosed(); err != nil {
 		return err
@@ -319,6 +365,10 @@ func (b *Backend) Broadcast(ctx context.Context, prefix string, data []byte) err
 			closed := info.closed
 			info.mu.RUnlock()
 
+			if !closed && b.persistent {
+				b.appendLog(info, data)
+				continue
+			}
 			if !closed {
 				select {
 				case <-ctx.Done():
@@ -381,14 +431,6 @@ func (w *channelWriter) Write(p []byte) (n int, err error) {
 	data := make([]byte, len(p))
 	copy(data, p)
 
-	// If persistent mode, buffer the data
-	if w.backend.persistent {
-		w.info.mu.Lock()
-		w.info.buffered = append(w.info.buffered, data)
-		w.info.mu.Unlock()
-	}
-
-	// Send to channel (non-blocking with select)
 	w.info.mu.RLock()
 	closed := w.info.closed
 	w.info.mu.RUnlock()
@@ -397,6 +439,13 @@ func (w *channelWriter) Write(p []byte) (n int, err error) {
 		return 0, io.ErrClosedPipe
 	}
 
+	// If persistent mode, append to the log that readers follow
+	if w.backend.persistent {
+		w.backend.appendLog(w.info, data)
+		return len(p), nil
+	}
+
+	// Send to channel (non-blocking with select)
 	select {
 	case <-w.ctx.Done():
 		return 0, w.ctx.Err()
@@ -416,12 +465,7 @@ func (w *channelWriter) Close() error {
 	w.closed = true
 
 	// Close the channel to signal EOF to readers
-	w.info.mu.Lock()
-	if !w.info.closed {
-		close(w.info.ch)
-		w.info.closed = true
-	}
-	w.info.mu.Unlock()
+	w.info.close()
 
 	return nil
 }
@@ -432,9 +476,10 @@ type channelReader struct {
 	info    *channelInfo
 	path    string
 	ctx     context.Context
-	buffer  *bytes.Buffer // Buffer for persistent mode or partial reads
-	current []byte        // Current message being read
-	offset  int           // Offset into current message
+	log     bool   // Whether to read the persistent log instead of the channel
+	next    int64  // Offset of the next log message to read
+	current []byte // Current message being read
+	offset  int    // Offset into current message
 	closed  bool
 	mu      sync.Mutex
 }
@@ -454,11 +499,6 @@ func (r *channelReader) Read(p []byte) (n int, err error) {
 	default:
 	}
 
-	// If we have a buffer from persistent mode, read from it first
-	if r.buffer != nil && r.buffer.Len() > 0 {
-		return r.buffer.Read(p)
-	}
-
 	// If we have leftover data from a previous message, return it
 	if r.offset < len(r.current) {
 		n = copy(p, r.current[r.offset:])
@@ -466,6 +506,10 @@ func (r *channelReader) Read(p []byte) (n int, err error) {
 		return n, nil
 	}
 
+	if r.log {
+		return r.readLog(p)
+	}
+
 	// Get next message from channel
 	select {
 	case <-r.ctx.Done():
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -232,13 +233,13 @@ func TestList(t *testing.T) {
 		t.Errorf("List returned %d paths, want 4", len(result))
 	}
 
-	// List with prefix
-	result, err = backend.List(ctx, "a/")
+	// List with prefix, relative to it
+	result, err = backend.List(ctx, "a")
 	if err != nil {
 		t.Fatalf("List with prefix failed: %v", err)
 	}
-	if len(result) != 2 {
-		t.Errorf("List with prefix returned %d paths, want 2", len(result))
+	if !slices.Equal(result, []string{"1", "2"}) {
+		t.Errorf("List with prefix = %v, want [1 2]", result)
 	}
 }
 
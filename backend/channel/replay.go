@@ -0,0 +1,113 @@
+package channel
+
+import (
+	"io"
+
+	"github.com/grokify/omnistorage"
+)
+
+type replayKey struct{}
+
+// replay is a reader's starting position in a persistent channel.
+type replay struct {
+	offset int64
+	tail   bool
+}
+
+// FromStart makes a reader of a persistent channel start at the oldest
+// retained message. This is the default.
+func FromStart() omnistorage.ReaderOption {
+	return omnistorage.WithReaderExtension(replayKey{}, replay{})
+}
+
+// FromOffset makes a reader of a persistent channel start at the message
+// with the given offset. Messages are numbered from 0 in write order; if
+// the message has been dropped, the reader starts at the oldest retained
+// message.
+func FromOffset(offset int64) omnistorage.ReaderOption {
+	return omnistorage.WithReaderExtension(replayKey{}, replay{offset: offset})
+}
+
+// TailOnly makes a reader of a persistent channel skip retained messages
+// and receive only messages written after it is created.
+func TailOnly() omnistorage.ReaderOption {
+	return omnistorage.WithReaderExtension(replayKey{}, replay{tail: true})
+}
+
+// Offsets returns the offset of the oldest retained message of a
+// persistent channel and the offset the next message will get. Both are 0
+// for a channel that does not exist.
+func (b *Backend) Offsets(path string) (oldest, next int64) {
+	b.mu.RLock()
+	info, ok := b.channels[path]
+	b.mu.RUnlock()
+	if !ok {
+		return 0, 0
+	}
+
+	info.mu.RLock()
+	defer info.mu.RUnlock()
+	return info.first, info.first + int64(len(info.buffered))
+}
+
+// appendLog appends data to the channel's log, dropping the oldest
+// messages beyond the retention limits, and wakes waiting readers.
+func (b *Backend) appendLog(info *channelInfo, data []byte) {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	info.buffered = append(info.buffered, data)
+	info.size += int64(len(data))
+	for len(info.buffered) > 1 &&
+		((b.maxMessages > 0 && len(info.buffered) > b.maxMessages) ||
+			(b.maxBytes > 0 && info.size > b.maxBytes)) {
+		info.size -= int64(len(info.buffered[0]))
+		info.buffered[0] = nil
+		info.buffered = info.buffered[1:]
+		info.first++
+	}
+
+	close(info.notify)
+	info.notify = make(chan struct{})
+}
+
+// close closes the channel and wakes waiting readers.
+func (info *channelInfo) close() {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	if !info.closed {
+		close(info.ch)
+		close(info.notify)
+		info.closed = true
+	}
+}
+
+// readLog reads the next message of the persistent log, waiting for one
+// to be written. The caller holds r.mu.
+func (r *channelReader) readLog(p []byte) (int, error) {
+	for {
+		r.info.mu.RLock()
+		if r.next < r.info.first {
+			r.next = r.info.first // dropped while the reader was behind
+		}
+		if i := r.next - r.info.first; i < int64(len(r.info.buffered)) {
+			r.current = r.info.buffered[i]
+			r.info.mu.RUnlock()
+			r.next++
+			r.offset = copy(p, r.current)
+			return r.offset, nil
+		}
+		closed, wait := r.info.closed, r.info.notify
+		r.info.mu.RUnlock()
+
+		if closed {
+			return 0, io.EOF
+		}
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-wait:
+		}
+	}
+}
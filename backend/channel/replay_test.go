@@ -0,0 +1,142 @@
+package channel
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+func writeMessages(t *testing.T, w io.Writer, msgs ...string) {
+	t.Helper()
+	for _, m := range msgs {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("Write(%q) failed: %v", m, err)
+		}
+	}
+}
+
+func readAll(t *testing.T, backend *Backend, path string, opts ...omnistorage.ReaderOption) string {
+	t.Helper()
+	r, err := backend.NewReader(context.Background(), path, opts...)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestReplayPositions(t *testing.T) {
+	backend := New(WithPersistence(true))
+	ctx := context.Background()
+
+	w, _ := backend.NewWriter(ctx, "log")
+	writeMessages(t, w, "a", "b", "c")
+
+	tail, err := backend.NewReader(ctx, "log", TailOnly())
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	writeMessages(t, w, "d")
+	_ = w.Close()
+
+	tests := []struct {
+		name string
+		opts []omnistorage.ReaderOption
+		want string
+	}{
+		{"default", nil, "abcd"},
+		{"from start", []omnistorage.ReaderOption{FromStart()}, "abcd"},
+		{"from offset", []omnistorage.ReaderOption{FromOffset(2)}, "cd"},
+		{"past end", []omnistorage.ReaderOption{FromOffset(10)}, ""},
+	}
+	for _, tt := range tests {
+		if got := readAll(t, backend, "log", tt.opts...); got != tt.want {
+			t.Errorf("%s: read %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	data, _ := io.ReadAll(tail)
+	if string(data) != "d" {
+		t.Errorf("tail-only reader read %q, want %q", data, "d")
+	}
+}
+
+func TestReplayRetention(t *testing.T) {
+	ctx := context.Background()
+
+	backend := New(WithPersistence(true), WithMaxBufferedMessages(2))
+	w, _ := backend.NewWriter(ctx, "log")
+	writeMessages(t, w, "a", "b", "c")
+	_ = w.Close()
+
+	if oldest, next := backend.Offsets("log"); oldest != 1 || next != 3 {
+		t.Errorf("Offsets() = %d, %d; want 1, 3", oldest, next)
+	}
+	if got := readAll(t, backend, "log", FromOffset(0)); got != "bc" {
+		t.Errorf("read %q, want %q", got, "bc")
+	}
+
+	backend = New(WithPersistence(true), WithMaxBufferedBytes(4))
+	w, _ = backend.NewWriter(ctx, "log")
+	writeMessages(t, w, "aa", "bb", "cc", "dddddd")
+	_ = w.Close()
+
+	if got := readAll(t, backend, "log"); got != "dddddd" {
+		t.Errorf("read %q, want newest message retained", got)
+	}
+}
+
+func TestReplayFollowsWriter(t *testing.T) {
+	backend := New(WithPersistence(true))
+	ctx := context.Background()
+
+	w, _ := backend.NewWriter(ctx, "log")
+	readers := make([]io.ReadCloser, 2)
+	for i := range readers {
+		r, err := backend.NewReader(ctx, "log")
+		if err != nil {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		readers[i] = r
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte("x"))
+		_, _ = w.Write([]byte("y"))
+		_ = w.Close()
+	}()
+
+	// Every reader receives every message
+	for i, r := range readers {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("reader %d: ReadAll failed: %v", i, err)
+		}
+		if string(data) != "xy" {
+			t.Errorf("reader %d read %q, want %q", i, data, "xy")
+		}
+	}
+}
+
+func TestReplayContextCancel(t *testing.T) {
+	backend := New(WithPersistence(true))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r, _ := backend.NewReader(ctx, "log")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	if _, err := r.Read(make([]byte, 8)); err != context.Canceled {
+		t.Errorf("Read error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,42 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Abouter
+var _ omnistorage.Abouter = (*Backend)(nil)
+
+// errStatfsUnsupported is returned by diskUsage on platforms without a
+// filesystem statistics call.
+var errStatfsUnsupported = errors.New("file: filesystem statistics not supported on this platform")
+
+// About reports the size and free space of the filesystem holding the
+// root directory. Used is the space used on the whole filesystem, not
+// just under the root, and Objects is -1. Free is the space available to
+// unprivileged users.
+func (b *Backend) About(ctx context.Context) (omnistorage.Usage, error) {
+	if err := b.checkClosed(); err != nil {
+		return omnistorage.Usage{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return omnistorage.Usage{}, err
+	}
+
+	usage, err := diskUsage(b.config.Root)
+	switch {
+	case errors.Is(err, errStatfsUnsupported):
+		return omnistorage.Usage{}, fmt.Errorf("file: about: %w", omnistorage.ErrNotSupported)
+	case errors.Is(err, os.ErrNotExist):
+		return omnistorage.Usage{}, fmt.Errorf("file: root %s: %w", b.config.Root, omnistorage.ErrNotFound)
+	case err != nil:
+		return omnistorage.Usage{}, fmt.Errorf("file: about: %w", err)
+	}
+	usage.Objects = -1
+	return usage, nil
+}
@@ -0,0 +1,155 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/grokify/omnistorage"
+)
+
+// tempMarker is part of the name of every temporary file, so List can
+// skip files that are still being written.
+const tempMarker = ".omnistorage-tmp-"
+
+// isTempFile reports whether name is a temporary file of this backend.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, tempMarker)
+}
+
+// createTemp creates a temporary file for writing dst, in Config.TempDir
+// or else in dst's directory.
+func (b *Backend) createTemp(dst string) (*os.File, error) {
+	dir := b.config.TempDir
+	if dir == "" {
+		dir = filepath.Dir(dst)
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(dst)+tempMarker+"*")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(b.config.FilePermissions); err != nil && runtime.GOOS != "windows" {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// commit closes the temporary file f and renames it to dst, syncing first
+// if Config.Fsync is set. The temporary file is removed on error.
+func (b *Backend) commit(f *os.File, dst string) (err error) {
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	if b.config.Fsync {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("sync %s: %w", dst, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		if b.config.TempDir != "" {
+			return fmt.Errorf("%w (TempDir must be on the same filesystem as Root)", err)
+		}
+		return err
+	}
+
+	if b.config.Fsync {
+		return syncDir(filepath.Dir(dst))
+	}
+	return nil
+}
+
+// syncDir flushes a directory entry, so a rename survives a crash.
+// Directories cannot be synced on Windows.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// atomicWriter writes to a temporary file that replaces the destination
+// on Close, so readers never see a partially written file and a crash
+// leaves the previous content in place.
+type atomicWriter struct {
+	backend *Backend
+	f       *os.File
+	path    string // as passed to NewWriter
+	dst     string // full path
+	meta    *fileMeta
+	verify  *omnistorage.ChecksumVerifier
+	err     error // first write error
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (w *atomicWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, omnistorage.ErrWriterClosed
+	}
+	n, err := w.f.Write(p)
+	_, _ = w.verify.Write(p[:n])
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// Close commits the file. If a write failed or the data does not match
+// the expected checksums, the temporary file is discarded and the
+// destination is left unchanged.
+func (w *atomicWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.err != nil {
+		_ = w.f.Close()
+		_ = os.Remove(w.f.Name())
+		return fmt.Errorf("writing %s: %w; file not committed", w.path, w.err)
+	}
+	if err := w.verify.Verify(); err != nil {
+		_ = w.f.Close()
+		_ = os.Remove(w.f.Name())
+		return fmt.Errorf("writing %s: %w; file not committed", w.path, err)
+	}
+	sidecar, err := w.backend.storeMeta(w.f, w.meta)
+	if err != nil {
+		_ = w.f.Close()
+		_ = os.Remove(w.f.Name())
+		return fmt.Errorf("committing %s: %w", w.path, err)
+	}
+	if err := w.backend.commit(w.f, w.dst); err != nil {
+		return fmt.Errorf("committing %s: %w", w.path, err)
+	}
+	return w.backend.updateSidecar(w.dst, w.meta, sidecar)
+}
@@ -14,13 +14,25 @@ import (
 )
 
 func init() {
-	omnistorage.Register("file", NewFromConfig)
+	omnistorage.Register("file", NewFromConfig,
+		omnistorage.WithDescription("Local filesystem"),
+		omnistorage.WithConfigKeys(
+			omnistorage.ConfigKey{Name: "root", Description: "Root directory", Default: "."},
+			omnistorage.ConfigKey{Name: "create_dirs", Description: "Create parent directories automatically", Default: "true"},
+			omnistorage.ConfigKey{Name: "fsync", Description: "Sync files to disk before committing them", Default: "false"},
+			omnistorage.ConfigKey{Name: "temp_dir", Description: "Directory for files being written (default: next to the file)"},
+			omnistorage.ConfigKey{Name: "metadata", Description: "Metadata storage: auto, xattr, sidecar or none", Default: "auto"},
+			omnistorage.ConfigKey{Name: "link_copies", Description: "Copy files as hard links", Default: "false"},
+		),
+		omnistorage.WithFeatures((&Backend{}).Features()))
 }
 
 // Config holds configuration for the file backend.
 type Config struct {
 	// Root is the root directory for all operations.
-	// All paths are relative to this directory.
+	// All paths are relative to this directory. On Windows it may be
+	// a drive path (C:\data), a UNC share (\\server\share\backup) or a
+	// long path (\\?\C:\data), with either kind of slash.
 	Root string
 
 	// CreateDirs controls whether parent directories are created automatically.
@@ -34,6 +46,30 @@ type Config struct {
 	// FilePermissions is the permission mode for created files.
 	// Default: 0644
 	FilePermissions os.FileMode
+
+	// Fsync syncs each file and its directory to disk when a writer is
+	// closed, so a committed file survives a power loss or OS crash.
+	// Default: false
+	Fsync bool
+
+	// TempDir is the directory for files being written. Files are written
+	// to a temporary file and renamed into place on Close, so a crash
+	// never leaves a truncated file. TempDir must be on the same
+	// filesystem as Root.
+	// Default: "" (the destination file's directory)
+	TempDir string
+
+	// Metadata selects where content types and custom metadata are
+	// stored, so Stat returns them.
+	// Default: MetadataAuto (extended attributes, else sidecar files)
+	Metadata MetadataStorage
+
+	// LinkCopies makes Copy create a hard link instead of copying data,
+	// falling back to a copy across filesystems. Writes through the
+	// backend replace files, so they never change a linked copy, but
+	// writes by other programs may.
+	// Default: false
+	LinkCopies bool
 }
 
 // DefaultConfig returns the default configuration.
@@ -58,6 +94,10 @@ func New(config Config) *Backend {
 	if config.Root == "" {
 		config.Root = "."
 	}
+	config.Root = cleanRoot(config.Root)
+	if config.TempDir != "" {
+		config.TempDir = cleanRoot(config.TempDir)
+	}
 	if config.DirPermissions == 0 {
 		config.DirPermissions = 0755
 	}
@@ -73,6 +113,10 @@ func New(config Config) *Backend {
 // Supported keys:
 //   - root: root directory (default: ".")
 //   - create_dirs: "true" or "false" (default: "true")
+//   - fsync: "true" or "false" (default: "false")
+//   - temp_dir: directory for files being written (default: next to the file)
+//   - metadata: "auto", "xattr", "sidecar" or "none" (default: "auto")
+//   - link_copies: "true" or "false" (default: "false")
 func NewFromConfig(configMap map[string]string) (omnistorage.Backend, error) {
 	config := DefaultConfig()
 
@@ -84,10 +128,35 @@ func NewFromConfig(configMap map[string]string) (omnistorage.Backend, error) {
 		config.CreateDirs = createDirs != "false"
 	}
 
+	if fsync, ok := configMap["fsync"]; ok {
+		config.Fsync = fsync == "true"
+	}
+
+	if tempDir, ok := configMap["temp_dir"]; ok {
+		config.TempDir = tempDir
+	}
+
+	if linkCopies, ok := configMap["link_copies"]; ok {
+		config.LinkCopies = linkCopies == "true"
+	}
+
+	if metadata, ok := configMap["metadata"]; ok {
+		switch m := MetadataStorage(metadata); m {
+		case "auto":
+			config.Metadata = MetadataAuto
+		case MetadataAuto, MetadataXattr, MetadataSidecar, MetadataNone:
+			config.Metadata = m
+		default:
+			return nil, fmt.Errorf("invalid metadata storage: %q", metadata)
+		}
+	}
+
 	return New(config), nil
 }
 
-// NewWriter creates a writer for the given path.
+// NewWriter creates a writer for the given path. Data goes to a temporary
+// file that replaces the path when the writer is closed, so the path holds
+// either its previous content or the complete new content.
 func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
 	if err := b.checkClosed(); err != nil {
 		return nil, err
@@ -111,12 +180,21 @@ func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorag
 		}
 	}
 
-	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, b.config.FilePermissions)
+	f, err := b.createTemp(fullPath)
 	if err != nil {
 		return nil, fmt.Errorf("creating file %s: %w", path, err)
 	}
 
-	return f, nil
+	config := omnistorage.ApplyWriterOptions(opts...)
+
+	return &atomicWriter{
+		backend: b,
+		f:       f,
+		path:    path,
+		dst:     fullPath,
+		meta:    newFileMeta(path, config.ContentType, config.Metadata),
+		verify:  omnistorage.NewChecksumVerifier(config),
+	}, nil
 }
 
 // NewReader creates a reader for the given path.
@@ -212,7 +290,7 @@ func (b *Backend) Delete(ctx context.Context, path string) error {
 
 	err := os.Remove(fullPath)
 	if err == nil || os.IsNotExist(err) {
-		return nil // Idempotent
+		return b.updateSidecar(fullPath, nil, false) // Idempotent
 	}
 	if os.IsPermission(err) {
 		return omnistorage.ErrPermissionDenied
@@ -220,7 +298,7 @@ func (b *Backend) Delete(ctx context.Context, path string) error {
 	return fmt.Errorf("deleting %s: %w", path, err)
 }
 
-// List lists paths with the given prefix.
+// List lists the paths under the directory prefix, relative to it.
 func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	if err := b.checkClosed(); err != nil {
 		return nil, err
@@ -232,10 +310,8 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 
 	var paths []string
 
-	root := b.config.Root
-	if prefix != "" {
-		root = b.fullPath(prefix)
-	}
+	prefix = omnistorage.CleanPrefix(prefix)
+	root := b.fullPath(prefix)
 
 	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		// Check context on each iteration
@@ -251,21 +327,20 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 			return err
 		}
 
-		// Skip directories
-		if info.IsDir() {
+		// Skip directories, the prefix itself if it is a file, files
+		// still being written and metadata sidecars
+		if info.IsDir() || path == root || isTempFile(info.Name()) {
+			return nil
+		}
+		if b.config.Metadata != MetadataNone && isSidecar(path) {
 			return nil
 		}
 
-		// Get relative path
-		rel, err := filepath.Rel(b.config.Root, path)
+		rel, err := b.relPath(path)
 		if err != nil {
 			return err
 		}
-
-		// Convert to forward slashes for consistency
-		rel = filepath.ToSlash(rel)
-
-		paths = append(paths, rel)
+		paths = append(paths, strings.TrimPrefix(rel, prefix))
 		return nil
 	})
 
@@ -279,6 +354,56 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	return paths, nil
 }
 
+// ListDirs lists the directories under prefix, including empty ones,
+// relative to it.
+func (b *Backend) ListDirs(ctx context.Context, prefix string) ([]string, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+
+	prefix = omnistorage.CleanPrefix(prefix)
+	root := b.fullPath(prefix)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			if os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !info.IsDir() || path == root {
+			return nil
+		}
+
+		rel, err := b.relPath(path)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, strings.TrimPrefix(rel, prefix))
+		return nil
+	})
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("listing directories in %s: %w", prefix, err)
+	}
+
+	return dirs, nil
+}
+
 // Close releases any resources held by the backend.
 func (b *Backend) Close() error {
 	b.mu.Lock()
@@ -294,15 +419,30 @@ func (b *Backend) fullPath(path string) string {
 	return filepath.Join(b.config.Root, path)
 }
 
+// relPath returns the slash-separated path relative to the root for
+// full, a filesystem path under it.
+func (b *Backend) relPath(full string) (string, error) {
+	rel, err := filepath.Rel(b.config.Root, full)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsLocal(rel) && rel != "." {
+		return "", fmt.Errorf("%s is outside root %s: %w", full, b.config.Root, omnistorage.ErrInvalidPath)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
 // validatePath checks if a path is valid.
 func (b *Backend) validatePath(path string) error {
 	if path == "" {
 		return omnistorage.ErrInvalidPath
 	}
 
-	// Check for path traversal attempts
-	cleaned := filepath.Clean(path)
-	if strings.HasPrefix(cleaned, "..") || strings.Contains(cleaned, "../") {
+	// Reject paths that would leave the root: traversal with ".." and,
+	// on Windows, drive letters, UNC prefixes and reserved names such as
+	// NUL. A leading slash is ignored, as on other backends.
+	local := filepath.FromSlash(strings.TrimLeft(path, "/"))
+	if !filepath.IsLocal(local) {
 		return omnistorage.ErrInvalidPath
 	}
 
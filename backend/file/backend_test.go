@@ -2,9 +2,12 @@ package file
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"testing"
 
 	"github.com/grokify/omnistorage"
@@ -76,6 +79,128 @@ func TestNewWriterCreatesDirs(t *testing.T) {
 	}
 }
 
+func TestNewWriterAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend := New(Config{Root: tmpDir})
+	defer func() { _ = backend.Close() }()
+
+	ctx := context.Background()
+	target := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	w, err := backend.NewWriter(ctx, "test.txt")
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if _, err := w.Write([]byte("new content")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Until Close, the old content is in place and the temp file is not listed
+	content, _ := os.ReadFile(target)
+	if string(content) != "old" {
+		t.Errorf("content before Close = %q, want %q", content, "old")
+	}
+	paths, _ := backend.List(ctx, "")
+	if len(paths) != 1 || paths[0] != "test.txt" {
+		t.Errorf("List() before Close = %v, want [test.txt]", paths)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	content, _ = os.ReadFile(target)
+	if string(content) != "new content" {
+		t.Errorf("content after Close = %q, want %q", content, "new content")
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries after Close, want 1", len(entries))
+	}
+	if info, _ := os.Stat(target); runtime.GOOS != "windows" && info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %o, want %o", info.Mode().Perm(), 0644)
+	}
+}
+
+func TestNewWriterFailedWriteNotCommitted(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend := New(Config{Root: tmpDir})
+	defer func() { _ = backend.Close() }()
+
+	ctx := context.Background()
+	target := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	w, err := backend.NewWriter(ctx, "test.txt")
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	_ = w.(*atomicWriter).f.Close() // make the next write fail
+	if _, err := w.Write([]byte("partial")); err == nil {
+		t.Fatal("Write succeeded, want error")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close succeeded after failed write, want error")
+	}
+
+	content, _ := os.ReadFile(target)
+	if string(content) != "old" {
+		t.Errorf("content = %q, want %q", content, "old")
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want temp file removed", len(entries))
+	}
+}
+
+func TestNewWriterFsyncTempDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	stagingDir := filepath.Join(tmpDir, "staging")
+	rootDir := filepath.Join(tmpDir, "root")
+	for _, dir := range []string{stagingDir, rootDir} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Mkdir failed: %v", err)
+		}
+	}
+
+	backend, err := NewFromConfig(map[string]string{
+		"root":     rootDir,
+		"fsync":    "true",
+		"temp_dir": stagingDir,
+	})
+	if err != nil {
+		t.Fatalf("NewFromConfig failed: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	ctx := context.Background()
+	w, err := backend.NewWriter(ctx, "a/test.txt")
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	_, _ = w.Write([]byte("durable"))
+
+	if entries, _ := os.ReadDir(stagingDir); len(entries) != 1 {
+		t.Errorf("staging dir has %d entries while writing, want 1", len(entries))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if entries, _ := os.ReadDir(stagingDir); len(entries) != 0 {
+		t.Errorf("staging dir has %d entries after Close, want 0", len(entries))
+	}
+
+	content, _ := os.ReadFile(filepath.Join(rootDir, "a", "test.txt"))
+	if string(content) != "durable" {
+		t.Errorf("content = %q, want %q", content, "durable")
+	}
+}
+
 func TestNewReader(t *testing.T) {
 	tmpDir := t.TempDir()
 	backend := New(Config{Root: tmpDir})
@@ -465,6 +590,53 @@ func TestValidatePath(t *testing.T) {
 	if err != omnistorage.ErrInvalidPath {
 		t.Errorf("Nested path traversal: error = %v, want %v", err, omnistorage.ErrInvalidPath)
 	}
+
+	_, err = backend.NewWriter(ctx, "/")
+	if err != omnistorage.ErrInvalidPath {
+		t.Errorf("Root path: error = %v, want %v", err, omnistorage.ErrInvalidPath)
+	}
+
+	// Names that merely begin with dots are valid
+	for _, p := range []string{"..hidden", "a/..b/c.txt", "/leading/slash.txt"} {
+		if err := backend.validatePath(p); err != nil {
+			t.Errorf("validatePath(%q) = %v, want nil", p, err)
+		}
+	}
+}
+
+func TestSlashRoot(t *testing.T) {
+	// A root given with forward slashes and a trailing slash, as in a
+	// config file, lists nested paths the same as a cleaned one
+	backend := New(Config{Root: filepath.ToSlash(t.TempDir()) + "/", CreateDirs: true})
+	defer func() { _ = backend.Close() }()
+	ctx := context.Background()
+
+	for _, p := range []string{"a/b/c.txt", "a/d.txt"} {
+		w, err := backend.NewWriter(ctx, p)
+		if err != nil {
+			t.Fatalf("NewWriter(%s): %v", p, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close(%s): %v", p, err)
+		}
+	}
+
+	paths, err := backend.List(ctx, "a/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	slices.Sort(paths)
+	if want := []string{"b/c.txt", "d.txt"}; !slices.Equal(paths, want) {
+		t.Errorf("List = %q, want %q", paths, want)
+	}
+	dirs, err := backend.ListDirs(ctx, "")
+	if err != nil {
+		t.Fatalf("ListDirs: %v", err)
+	}
+	slices.Sort(dirs)
+	if want := []string{"a", "a/b"}; !slices.Equal(dirs, want) {
+		t.Errorf("ListDirs = %q, want %q", dirs, want)
+	}
 }
 
 func TestContextCancellation(t *testing.T) {
@@ -571,3 +743,66 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("DefaultConfig FilePermissions = %o, want %o", config.FilePermissions, 0644)
 	}
 }
+
+func TestListDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"a/b", "c"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backend := New(Config{Root: root})
+	ctx := context.Background()
+
+	dirs, err := backend.ListDirs(ctx, "")
+	if err != nil {
+		t.Fatalf("ListDirs failed: %v", err)
+	}
+	if want := []string{"a", "a/b", "c"}; !slices.Equal(dirs, want) {
+		t.Errorf("ListDirs() = %v, want %v", dirs, want)
+	}
+
+	dirs, err = backend.ListDirs(ctx, "a")
+	if err != nil || !slices.Equal(dirs, []string{"b"}) {
+		t.Errorf("ListDirs(a) = %v, %v, want [b]", dirs, err)
+	}
+
+	dirs, err = backend.ListDirs(ctx, "missing")
+	if err != nil || len(dirs) != 0 {
+		t.Errorf("ListDirs(missing) = %v, %v, want empty", dirs, err)
+	}
+}
+
+func TestWriterChecksum(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend := New(Config{Root: tmpDir})
+	defer func() { _ = backend.Close() }()
+	ctx := context.Background()
+
+	write := func(data string, opts ...omnistorage.WriterOption) error {
+		w, err := backend.NewWriter(ctx, "data.txt", opts...)
+		if err != nil {
+			t.Fatalf("NewWriter failed: %v", err)
+		}
+		_, _ = io.WriteString(w, data)
+		return w.Close()
+	}
+
+	if err := write("old", omnistorage.WithContentMD5(omnistorage.HashBytes([]byte("old"), omnistorage.HashMD5))); err != nil {
+		t.Fatalf("Close with matching checksum: %v", err)
+	}
+	err := write("new", omnistorage.WithContentSHA256(omnistorage.HashBytes([]byte("other"), omnistorage.HashSHA256)))
+	if !errors.Is(err, omnistorage.ErrChecksumMismatch) {
+		t.Fatalf("Close with wrong checksum = %v, want ErrChecksumMismatch", err)
+	}
+
+	// The previous content is kept and the temporary file removed
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "data.txt"))
+	if string(data) != "old" {
+		t.Errorf("content = %q, want %q", data, "old")
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+}
@@ -0,0 +1,14 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(Config{Root: t.TempDir(), CreateDirs: true})
+	})
+}
@@ -38,11 +38,15 @@ func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo
 		return nil, fmt.Errorf("stat %s: %w", path, err)
 	}
 
-	// Determine content type from extension
+	// Use the stored content type, else determine it from the extension
 	contentType := ""
+	var metadata map[string]string
 	if !info.IsDir() {
-		ext := filepath.Ext(path)
-		if ext != "" {
+		if meta, _ := b.loadMeta(fullPath); meta != nil {
+			contentType = meta.ContentType
+			metadata = meta.Metadata
+		}
+		if ext := filepath.Ext(path); contentType == "" && ext != "" {
 			contentType = mime.TypeByExtension(ext)
 		}
 	}
@@ -54,6 +58,7 @@ func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo
 		ObjectIsDir:       info.IsDir(),
 		ObjectContentType: contentType,
 		ObjectHashes:      nil, // File backend doesn't store hashes; compute on demand if needed
+		ObjectMetadata:    metadata,
 	}, nil
 }
 
@@ -127,7 +132,9 @@ func (b *Backend) Rmdir(ctx context.Context, path string) error {
 	return nil
 }
 
-// Copy copies an object from src to dst using server-side copy (hard link or copy).
+// Copy copies an object from src to dst. On filesystems with
+// copy-on-write support the copy shares the source's data blocks (reflink);
+// with Config.LinkCopies it is a hard link. Otherwise the data is copied.
 func (b *Backend) Copy(ctx context.Context, src, dst string) error {
 	if err := b.checkClosed(); err != nil {
 		return err
@@ -171,6 +178,14 @@ func (b *Backend) Copy(ctx context.Context, src, dst string) error {
 		}
 	}
 
+	// Hard links are safe to share because writes replace files rather
+	// than modify them
+	if b.config.LinkCopies {
+		if err := b.link(srcPath, dstPath); err == nil {
+			return nil
+		}
+	}
+
 	// Perform the copy
 	return b.copyFile(srcPath, dstPath)
 }
@@ -218,7 +233,7 @@ func (b *Backend) Move(ctx context.Context, src, dst string) error {
 	// Try rename first (atomic, same filesystem)
 	err = os.Rename(srcPath, dstPath)
 	if err == nil {
-		return nil
+		return b.moveSidecar(srcPath, dstPath)
 	}
 
 	// If rename fails (cross-filesystem), fall back to copy+delete
@@ -226,7 +241,76 @@ func (b *Backend) Move(ctx context.Context, src, dst string) error {
 		return err
 	}
 
-	return os.Remove(srcPath)
+	if err := os.Remove(srcPath); err != nil {
+		return err
+	}
+	return b.updateSidecar(srcPath, nil, false)
+}
+
+// Link replaces path with a hard link to target, so both share one copy
+// of the content. The replacement is atomic. Both paths must be on the
+// same filesystem.
+func (b *Backend) Link(ctx context.Context, target, path string) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := b.validatePath(target); err != nil {
+		return err
+	}
+	if err := b.validatePath(path); err != nil {
+		return err
+	}
+
+	targetPath := b.fullPath(target)
+	linkPath := b.fullPath(path)
+
+	if _, err := os.Stat(targetPath); err != nil {
+		if os.IsNotExist(err) {
+			return omnistorage.ErrNotFound
+		}
+		return fmt.Errorf("stat %s: %w", target, err)
+	}
+
+	if b.config.CreateDirs {
+		dir := filepath.Dir(linkPath)
+		if err := os.MkdirAll(dir, b.config.DirPermissions); err != nil {
+			return fmt.Errorf("creating directory %s: %w", dir, err)
+		}
+	}
+
+	if err := b.link(targetPath, linkPath); err != nil {
+		return fmt.Errorf("link %s: %w", path, err)
+	}
+	return nil
+}
+
+// link replaces the file at linkPath with a hard link to targetPath.
+func (b *Backend) link(targetPath, linkPath string) error {
+	// Link to a temporary name and rename over path, so path is never
+	// missing.
+	tmpPath := linkPath + ".omnistorage-link"
+	_ = os.Remove(tmpPath)
+	if err := os.Link(targetPath, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, linkPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if b.config.Fsync {
+		if err := syncDir(filepath.Dir(linkPath)); err != nil {
+			return err
+		}
+	}
+
+	// Extended attributes are shared through the link; sidecars are not
+	meta, sidecar := b.loadMeta(targetPath)
+	return b.updateSidecar(linkPath, meta, sidecar)
 }
 
 // Features returns the capabilities of the file backend.
@@ -237,6 +321,7 @@ func (b *Backend) Features() omnistorage.Features {
 		Mkdir:                true,
 		Rmdir:                true,
 		Stat:                 true,
+		CustomMetadata:       b.config.Metadata != MetadataNone,
 		Hashes:               []omnistorage.HashType{}, // Hashes computed on demand, not stored
 		CanStream:            true,
 		ServerSideEncryption: false,
@@ -246,7 +331,8 @@ func (b *Backend) Features() omnistorage.Features {
 	}
 }
 
-// copyFile copies a file from src to dst.
+// copyFile copies a file from src to dst. Like a write, the copy goes to
+// a temporary file that replaces dst once complete.
 func (b *Backend) copyFile(src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
@@ -254,18 +340,49 @@ func (b *Backend) copyFile(src, dst string) error {
 	}
 	defer func() { _ = srcFile.Close() }()
 
-	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, b.config.FilePermissions)
+	dstFile, err := b.createTemp(dst)
 	if err != nil {
 		return fmt.Errorf("create destination: %w", err)
 	}
-	defer func() { _ = dstFile.Close() }()
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	meta, _ := b.loadMeta(src)
+	sidecar, err := b.storeMeta(dstFile, meta)
+	if err == nil && reflink(dstFile, srcFile) != nil {
+		_, err = io.Copy(dstFile, srcFile)
+	}
+	if err != nil {
+		_ = dstFile.Close()
+		_ = os.Remove(dstFile.Name())
 		return fmt.Errorf("copy data: %w", err)
 	}
 
-	return dstFile.Close()
+	if err := b.commit(dstFile, dst); err != nil {
+		return fmt.Errorf("commit destination: %w", err)
+	}
+	return b.updateSidecar(dst, meta, sidecar)
+}
+
+// moveSidecar moves the metadata sidecar of a renamed file, if any, and
+// removes a stale sidecar at the destination otherwise.
+func (b *Backend) moveSidecar(src, dst string) error {
+	if b.config.Metadata == MetadataNone {
+		return nil
+	}
+	err := os.Rename(src+SidecarSuffix, dst+SidecarSuffix)
+	if err == nil {
+		return nil
+	}
+	if os.IsNotExist(err) {
+		return b.updateSidecar(dst, nil, false)
+	}
+	return fmt.Errorf("moving metadata sidecar: %w", err)
 }
 
 // Ensure Backend implements omnistorage.ExtendedBackend
 var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// Ensure Backend implements omnistorage.Linker
+var _ omnistorage.Linker = (*Backend)(nil)
+
+// Ensure Backend implements omnistorage.DirLister
+var _ omnistorage.DirLister = (*Backend)(nil)
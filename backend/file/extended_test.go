@@ -2,6 +2,7 @@ package file
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -199,6 +200,37 @@ func TestRmdirOnFile(t *testing.T) {
 	}
 }
 
+func TestLink(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend := New(Config{Root: tmpDir, CreateDirs: true})
+	defer func() { _ = backend.Close() }()
+
+	ctx := context.Background()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("same"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("same"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.Link(ctx, "a.txt", "b.txt"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	a, _ := os.Stat(filepath.Join(tmpDir, "a.txt"))
+	b, _ := os.Stat(filepath.Join(tmpDir, "b.txt"))
+	if !os.SameFile(a, b) {
+		t.Error("b.txt is not a hard link to a.txt")
+	}
+
+	if err := backend.Link(ctx, "sub/c.txt", "d.txt"); err != omnistorage.ErrNotFound {
+		t.Errorf("Link missing target: err = %v, want ErrNotFound", err)
+	}
+	if err := backend.Link(ctx, "a.txt", "sub/e.txt"); err != nil {
+		t.Errorf("Link into new directory failed: %v", err)
+	}
+}
+
 func TestCopy(t *testing.T) {
 	tmpDir := t.TempDir()
 	backend := New(Config{Root: tmpDir})
@@ -233,6 +265,47 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+func TestCopyLinkCopies(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend := New(Config{Root: tmpDir, LinkCopies: true})
+	defer func() { _ = backend.Close() }()
+
+	ctx := context.Background()
+
+	srcPath := filepath.Join(tmpDir, "src.txt")
+	if err := os.WriteFile(srcPath, []byte("shared"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := backend.Copy(ctx, "src.txt", "dst.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	srcInfo, _ := os.Stat(srcPath)
+	dstInfo, err := os.Stat(filepath.Join(tmpDir, "dst.txt"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if !os.SameFile(srcInfo, dstInfo) {
+		t.Error("Copy did not create a hard link")
+	}
+
+	// Overwriting the source through the backend leaves the copy unchanged
+	w, err := backend.NewWriter(ctx, "src.txt")
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	_, _ = w.Write([]byte("changed"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dstData, _ := os.ReadFile(filepath.Join(tmpDir, "dst.txt"))
+	if string(dstData) != "shared" {
+		t.Errorf("copy content = %q, want %q", dstData, "shared")
+	}
+}
+
 func TestCopyToNestedPath(t *testing.T) {
 	tmpDir := t.TempDir()
 	backend := New(Config{Root: tmpDir, CreateDirs: true})
@@ -468,3 +541,37 @@ func TestExtendedContextCancellation(t *testing.T) {
 		t.Errorf("Move with cancelled context error = %v, want context.Canceled", err)
 	}
 }
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	if err := New(Config{Root: root}).Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	missing := filepath.Join(root, "missing")
+	if err := New(Config{Root: missing}).Ping(ctx); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Ping of missing root: err = %v, want ErrNotFound", err)
+	}
+	if err := New(Config{Root: missing, CreateDirs: true}).Ping(ctx); err != nil {
+		t.Errorf("Ping of missing root with CreateDirs: %v", err)
+	}
+}
+
+func TestAbout(t *testing.T) {
+	usage, err := New(Config{Root: t.TempDir()}).About(context.Background())
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Skip("filesystem statistics not supported")
+	}
+	if err != nil {
+		t.Fatalf("About: %v", err)
+	}
+	if usage.Total <= 0 || usage.Free < 0 || usage.Free > usage.Total || usage.Used > usage.Total || usage.Objects != -1 {
+		t.Errorf("About = %+v", usage)
+	}
+
+	_, err = New(Config{Root: filepath.Join(t.TempDir(), "missing")}).About(context.Background())
+	if !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("About of missing root: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,220 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.MetadataSetter
+var _ omnistorage.MetadataSetter = (*Backend)(nil)
+
+// MetadataStorage selects where the file backend keeps content types and
+// custom metadata set with omnistorage.WithContentType and WithMetadata.
+type MetadataStorage string
+
+const (
+	// MetadataAuto stores metadata in an extended attribute, falling back
+	// to a sidecar file where the filesystem does not support them.
+	MetadataAuto MetadataStorage = ""
+
+	// MetadataXattr stores metadata in an extended attribute only. Writes
+	// with metadata fail where extended attributes are not supported.
+	MetadataXattr MetadataStorage = "xattr"
+
+	// MetadataSidecar stores metadata in a sidecar file next to each file.
+	MetadataSidecar MetadataStorage = "sidecar"
+
+	// MetadataNone discards metadata.
+	MetadataNone MetadataStorage = "none"
+)
+
+// SidecarSuffix is appended to a file's name to name its metadata sidecar.
+const SidecarSuffix = ".meta.json"
+
+// xattrName is the extended attribute holding a file's metadata.
+const xattrName = "user.omnistorage.metadata"
+
+// errXattrUnsupported is returned by the xattr functions on platforms
+// without extended attributes.
+var errXattrUnsupported = errors.New("extended attributes not supported")
+
+// fileMeta is the stored metadata of a file, encoded as JSON in both the
+// extended attribute and the sidecar.
+type fileMeta struct {
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// newFileMeta returns the metadata to store for a file at path, or nil if
+// there is none. A content type that matches the file's extension is not
+// stored, since Stat derives it anyway.
+func newFileMeta(path, contentType string, metadata map[string]string) *fileMeta {
+	if contentType == mime.TypeByExtension(filepath.Ext(path)) {
+		contentType = ""
+	}
+	if contentType == "" && len(metadata) == 0 {
+		return nil
+	}
+	return &fileMeta{ContentType: contentType, Metadata: metadata}
+}
+
+// isSidecar reports whether the file at full is the sidecar of an
+// existing file.
+func isSidecar(full string) bool {
+	base, ok := strings.CutSuffix(full, SidecarSuffix)
+	if !ok {
+		return false
+	}
+	info, err := os.Stat(base)
+	return err == nil && !info.IsDir()
+}
+
+// storeMeta stores meta in an extended attribute of the open temporary
+// file f, and reports whether it must go to a sidecar instead.
+func (b *Backend) storeMeta(f *os.File, meta *fileMeta) (sidecar bool, err error) {
+	if meta == nil {
+		return false, nil
+	}
+	switch b.config.Metadata {
+	case MetadataNone:
+		return false, nil
+	case MetadataSidecar:
+		return true, nil
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return false, err
+	}
+	if err := fsetxattr(f, xattrName, data); err != nil {
+		if b.config.Metadata == MetadataXattr {
+			return false, fmt.Errorf("storing metadata: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// updateSidecar writes meta to the sidecar of the file at full if
+// sidecar is true, and otherwise removes any stale sidecar.
+func (b *Backend) updateSidecar(full string, meta *fileMeta, sidecar bool) error {
+	if b.config.Metadata == MetadataNone {
+		return nil
+	}
+	path := full + SidecarSuffix
+	if !sidecar {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing metadata sidecar: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	f, err := b.createTemp(path)
+	if err != nil {
+		return fmt.Errorf("writing metadata sidecar: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("writing metadata sidecar: %w", err)
+	}
+	if err := b.commit(f, path); err != nil {
+		return fmt.Errorf("writing metadata sidecar: %w", err)
+	}
+	return nil
+}
+
+// loadMeta returns the stored metadata of the file at full, and whether
+// it came from a sidecar. It returns nil if there is none.
+func (b *Backend) loadMeta(full string) (meta *fileMeta, sidecar bool) {
+	if b.config.Metadata == MetadataNone {
+		return nil, false
+	}
+
+	if b.config.Metadata != MetadataSidecar {
+		if data, err := getxattr(full, xattrName); err == nil {
+			if err := json.Unmarshal(data, &meta); err == nil {
+				return meta, false
+			}
+		}
+	}
+	if b.config.Metadata != MetadataXattr {
+		if data, err := os.ReadFile(full + SidecarSuffix); err == nil {
+			if err := json.Unmarshal(data, &meta); err == nil {
+				return meta, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// SetMetadata replaces the custom metadata of the file at path, and its
+// content type unless contentType is empty, where Config.Metadata stores
+// them. The file's content and modification time are not changed. With
+// MetadataNone, it does nothing.
+func (b *Backend) SetMetadata(ctx context.Context, path string, metadata map[string]string, contentType string) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := b.validatePath(path); err != nil {
+		return err
+	}
+
+	full := b.fullPath(path)
+	info, err := os.Stat(full)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return omnistorage.ErrNotFound
+		}
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot set metadata of directory: %s", path)
+	}
+	if b.config.Metadata == MetadataNone {
+		return nil
+	}
+
+	if contentType == "" {
+		if old, _ := b.loadMeta(full); old != nil {
+			contentType = old.ContentType
+		}
+	}
+	meta := newFileMeta(path, contentType, metadata)
+
+	sidecar := b.config.Metadata == MetadataSidecar
+	if !sidecar {
+		if meta == nil {
+			err = removexattr(full, xattrName)
+		} else {
+			var data []byte
+			if data, err = json.Marshal(meta); err == nil {
+				err = setxattr(full, xattrName, data)
+			}
+		}
+		if err != nil {
+			if b.config.Metadata == MetadataXattr {
+				return fmt.Errorf("storing metadata: %w", err)
+			}
+			sidecar = true
+		}
+	}
+	return b.updateSidecar(full, meta, sidecar && meta != nil)
+}
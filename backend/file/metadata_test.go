@@ -0,0 +1,205 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+// xattrSupported reports whether dir's filesystem supports user extended
+// attributes.
+func xattrSupported(t *testing.T, dir string) bool {
+	t.Helper()
+	f, err := os.CreateTemp(dir, "probe")
+	if err != nil {
+		t.Fatalf("CreateTemp failed: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	return fsetxattr(f, xattrName, []byte("{}")) == nil
+}
+
+func writeWithMeta(t *testing.T, backend *Backend, path string, opts ...omnistorage.WriterOption) {
+	t.Helper()
+	w, err := backend.NewWriter(context.Background(), path, opts...)
+	if err != nil {
+		t.Fatalf("NewWriter(%q) failed: %v", path, err)
+	}
+	_, _ = w.Write([]byte("content"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%q) failed: %v", path, err)
+	}
+}
+
+func checkMeta(t *testing.T, backend *Backend, path, contentType, owner string) {
+	t.Helper()
+	info, err := backend.Stat(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Stat(%q) failed: %v", path, err)
+	}
+	if info.ContentType() != contentType {
+		t.Errorf("Stat(%q).ContentType() = %q, want %q", path, info.ContentType(), contentType)
+	}
+	if got := info.Metadata()["owner"]; got != owner {
+		t.Errorf("Stat(%q).Metadata()[owner] = %q, want %q", path, got, owner)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage MetadataStorage
+	}{
+		{"auto", MetadataAuto},
+		{"xattr", MetadataXattr},
+		{"sidecar", MetadataSidecar},
+	}
+	for _, tt := range tests {
+		storage := tt.storage
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if storage == MetadataXattr && !xattrSupported(t, tmpDir) {
+				t.Skip("extended attributes not supported")
+			}
+			backend := New(Config{Root: tmpDir, CreateDirs: true, Metadata: storage})
+			defer func() { _ = backend.Close() }()
+			ctx := context.Background()
+
+			writeWithMeta(t, backend, "a.dat",
+				omnistorage.WithContentType("application/x-custom"),
+				omnistorage.WithMetadata(map[string]string{"owner": "alice"}))
+			checkMeta(t, backend, "a.dat", "application/x-custom", "alice")
+
+			paths, _ := backend.List(ctx, "")
+			if len(paths) != 1 || paths[0] != "a.dat" {
+				t.Errorf("List() = %v, want [a.dat]", paths)
+			}
+
+			if err := backend.Copy(ctx, "a.dat", "b.dat"); err != nil {
+				t.Fatalf("Copy failed: %v", err)
+			}
+			checkMeta(t, backend, "b.dat", "application/x-custom", "alice")
+
+			if err := backend.Move(ctx, "b.dat", "sub/c.dat"); err != nil {
+				t.Fatalf("Move failed: %v", err)
+			}
+			checkMeta(t, backend, "sub/c.dat", "application/x-custom", "alice")
+
+			// Overwriting without metadata clears it
+			writeWithMeta(t, backend, "a.dat")
+			checkMeta(t, backend, "a.dat", "", "")
+
+			if err := backend.Delete(ctx, "sub/c.dat"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			for _, dir := range []string{tmpDir, filepath.Join(tmpDir, "sub")} {
+				entries, _ := os.ReadDir(dir)
+				for _, e := range entries {
+					if !e.IsDir() && e.Name() != "a.dat" {
+						t.Errorf("unexpected file %s left in %s", e.Name(), dir)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMetadataSidecarFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend := New(Config{Root: tmpDir, Metadata: MetadataSidecar})
+	defer func() { _ = backend.Close() }()
+
+	writeWithMeta(t, backend, "a.dat", omnistorage.WithMetadata(map[string]string{"owner": "bob"}))
+	if _, err := os.Stat(filepath.Join(tmpDir, "a.dat"+SidecarSuffix)); err != nil {
+		t.Errorf("sidecar not written: %v", err)
+	}
+
+	// A content type matching the extension is not stored
+	writeWithMeta(t, backend, "b.json", omnistorage.WithContentType("application/json"))
+	if _, err := os.Stat(filepath.Join(tmpDir, "b.json"+SidecarSuffix)); !os.IsNotExist(err) {
+		t.Errorf("sidecar written for default content type: %v", err)
+	}
+
+	// A .meta.json file without a matching file is listed
+	if err := os.WriteFile(filepath.Join(tmpDir, "report"+SidecarSuffix), []byte("{}"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	paths, _ := backend.List(context.Background(), "")
+	want := []string{"a.dat", "b.json", "report.meta.json"}
+	if len(paths) != len(want) {
+		t.Fatalf("List() = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("List()[%d] = %q, want %q", i, paths[i], want[i])
+		}
+	}
+}
+
+func TestMetadataNone(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend := New(Config{Root: tmpDir, Metadata: MetadataNone})
+	defer func() { _ = backend.Close() }()
+
+	writeWithMeta(t, backend, "a.dat",
+		omnistorage.WithContentType("application/x-custom"),
+		omnistorage.WithMetadata(map[string]string{"owner": "alice"}))
+	checkMeta(t, backend, "a.dat", "", "")
+
+	if backend.Features().CustomMetadata {
+		t.Error("Features.CustomMetadata = true, want false")
+	}
+	if _, err := NewFromConfig(map[string]string{"metadata": "database"}); err == nil {
+		t.Error("NewFromConfig with invalid metadata storage succeeded, want error")
+	}
+}
+
+func TestSetMetadata(t *testing.T) {
+	for _, storage := range []MetadataStorage{MetadataAuto, MetadataXattr, MetadataSidecar} {
+		t.Run(string(storage), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if storage == MetadataXattr && !xattrSupported(t, tmpDir) {
+				t.Skip("extended attributes not supported")
+			}
+			backend := New(Config{Root: tmpDir, Metadata: storage})
+			defer func() { _ = backend.Close() }()
+			ctx := context.Background()
+
+			writeWithMeta(t, backend, "a.dat", omnistorage.WithContentType("application/x-custom"))
+			full := filepath.Join(tmpDir, "a.dat")
+			before, _ := os.Stat(full)
+
+			if err := backend.SetMetadata(ctx, "a.dat", map[string]string{"owner": "carol"}, ""); err != nil {
+				t.Fatalf("SetMetadata failed: %v", err)
+			}
+			checkMeta(t, backend, "a.dat", "application/x-custom", "carol")
+
+			if err := backend.SetMetadata(ctx, "a.dat", nil, "text/csv"); err != nil {
+				t.Fatalf("SetMetadata failed: %v", err)
+			}
+			checkMeta(t, backend, "a.dat", "text/csv", "")
+
+			after, _ := os.Stat(full)
+			if !after.ModTime().Equal(before.ModTime()) {
+				t.Errorf("ModTime changed from %v to %v", before.ModTime(), after.ModTime())
+			}
+			if data, _ := os.ReadFile(full); string(data) != "content" {
+				t.Errorf("content = %q, want %q", data, "content")
+			}
+
+			// Clearing everything leaves no sidecar behind
+			if err := backend.SetMetadata(ctx, "a.dat", nil, "application/octet-stream"); err != nil {
+				t.Fatalf("SetMetadata failed: %v", err)
+			}
+			if err := backend.SetMetadata(ctx, "b.dat", nil, ""); !errors.Is(err, omnistorage.ErrNotFound) {
+				t.Errorf("SetMetadata(missing) error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
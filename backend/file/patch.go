@@ -0,0 +1,63 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Patcher
+var _ omnistorage.Patcher = (*Backend)(nil)
+
+// WriteAt writes p to the file at path at offset off, extending it if
+// needed. Unlike NewWriter, the change is not atomic.
+func (b *Backend) WriteAt(ctx context.Context, path string, p []byte, off int64) error {
+	f, err := b.openPatch(ctx, path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(p, off); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Truncate changes the size of the file at path.
+func (b *Backend) Truncate(ctx context.Context, path string, size int64) error {
+	f, err := b.openPatch(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("truncate %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// openPatch opens an existing file at path for writing in place.
+func (b *Backend) openPatch(ctx context.Context, path string) (*os.File, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := b.validatePath(path); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(b.fullPath(path), os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, omnistorage.ErrNotFound
+		}
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return f, nil
+}
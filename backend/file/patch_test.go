@@ -0,0 +1,49 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestPatch(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	backend := New(Config{Root: tmpDir})
+	defer backend.Close()
+
+	full := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(full, []byte("hello world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.WriteAt(ctx, "a.txt", []byte("W"), 6); err != nil {
+		t.Fatalf("WriteAt failed: %v", err)
+	}
+	if err := backend.WriteAt(ctx, "a.txt", []byte("!"), 11); err != nil {
+		t.Fatalf("WriteAt at end failed: %v", err)
+	}
+	data, _ := os.ReadFile(full)
+	if string(data) != "hello World!" {
+		t.Errorf("content = %q, want %q", data, "hello World!")
+	}
+
+	if err := backend.Truncate(ctx, "a.txt", 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	data, _ = os.ReadFile(full)
+	if string(data) != "hello" {
+		t.Errorf("content = %q, want %q", data, "hello")
+	}
+
+	if err := backend.WriteAt(ctx, "missing.txt", []byte("x"), 0); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("WriteAt(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := backend.Truncate(ctx, "missing.txt", 0); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Truncate(missing) error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,39 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Pinger
+var _ omnistorage.Pinger = (*Backend)(nil)
+
+// Ping checks that the root directory exists, or can be created by the
+// first write if Config.CreateDirs is set.
+func (b *Backend) Ping(ctx context.Context) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(b.config.Root)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && b.config.CreateDirs:
+		return nil
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("file: root %s: %w", b.config.Root, omnistorage.ErrNotFound)
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("file: root %s: %w", b.config.Root, omnistorage.ErrPermissionDenied)
+	case err != nil:
+		return fmt.Errorf("file: root %s: %w", b.config.Root, err)
+	case !info.IsDir():
+		return fmt.Errorf("file: root %s is not a directory", b.config.Root)
+	}
+	return nil
+}
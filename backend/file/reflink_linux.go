@@ -0,0 +1,15 @@
+//go:build linux
+
+package file
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink makes dst share src's data blocks (FICLONE), on filesystems
+// with copy-on-write support such as Btrfs and XFS.
+func reflink(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
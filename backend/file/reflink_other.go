@@ -0,0 +1,13 @@
+//go:build !linux
+
+package file
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink is not supported on this platform.
+func reflink(_, _ *os.File) error {
+	return errors.New("reflink not supported")
+}
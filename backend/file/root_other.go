@@ -0,0 +1,10 @@
+//go:build !windows
+
+package file
+
+import "path/filepath"
+
+// cleanRoot returns root cleaned.
+func cleanRoot(root string) string {
+	return filepath.Clean(root)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package file
+
+import "path/filepath"
+
+// cleanRoot returns root with backslashes, cleaned and absolute. Drive
+// letters, UNC shares (\\server\share) and long path roots (\\?\C:\data)
+// are kept. The os package adds the \\?\ prefix that paths longer than
+// MAX_PATH need, but only to absolute paths, so a relative root is
+// resolved against the working directory.
+func cleanRoot(root string) string {
+	root = filepath.Clean(filepath.FromSlash(root))
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return root
+}
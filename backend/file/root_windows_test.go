@@ -0,0 +1,82 @@
+//go:build windows
+
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestCleanRootWindows(t *testing.T) {
+	for root, want := range map[string]string{
+		`\\server\share\backup`:  `\\server\share\backup`,
+		`//server/share/backup/`: `\\server\share\backup`,
+		`\\?\C:\data\`:           `\\?\C:\data`,
+		`C:/data/sub`:            `C:\data\sub`,
+	} {
+		if got := cleanRoot(root); got != want {
+			t.Errorf("cleanRoot(%q) = %q, want %q", root, got, want)
+		}
+	}
+	if got := cleanRoot("data"); !filepath.IsAbs(got) {
+		t.Errorf("cleanRoot(data) = %q, want an absolute path", got)
+	}
+
+	b := New(Config{Root: `//server/share/backup`})
+	if got, want := b.fullPath("2024/01/report.csv"), `\\server\share\backup\2024\01\report.csv`; got != want {
+		t.Errorf("fullPath = %q, want %q", got, want)
+	}
+	if got, err := b.relPath(`\\server\share\backup\2024\01\report.csv`); err != nil || got != "2024/01/report.csv" {
+		t.Errorf("relPath = %q, %v", got, err)
+	}
+}
+
+func TestValidatePathWindows(t *testing.T) {
+	b := New(Config{Root: t.TempDir()})
+	for _, p := range []string{`C:/escape.txt`, `c:escape.txt`, `..\escape.txt`, `a\..\..\escape.txt`, `NUL`, `a/COM1`} {
+		if err := b.validatePath(p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("validatePath(%q) = %v, want ErrInvalidPath", p, err)
+		}
+	}
+	// A leading double slash is not a UNC share in an API path
+	if err := b.validatePath("//server/share/a.txt"); err != nil {
+		t.Errorf("validatePath(//server/share/a.txt) = %v, want nil", err)
+	}
+}
+
+func TestLongPathsWindows(t *testing.T) {
+	// Nested paths well beyond MAX_PATH (260 characters)
+	b := New(Config{Root: filepath.ToSlash(t.TempDir()), CreateDirs: true})
+	ctx := context.Background()
+	elem := strings.Repeat("d", 60)
+	p := strings.Repeat(elem+"/", 6) + "file.txt"
+
+	w, err := b.NewWriter(ctx, p)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err := w.Write([]byte("long")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(b.fullPath(p)); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	paths, err := b.List(ctx, elem+"/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !slices.Equal(paths, []string{p}) {
+		t.Errorf("List = %q, want [%s]", paths, p)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package file
+
+import "github.com/grokify/omnistorage"
+
+// diskUsage is not supported on this platform.
+func diskUsage(_ string) (omnistorage.Usage, error) {
+	return omnistorage.Usage{}, errStatfsUnsupported
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin
+
+package file
+
+import (
+	"github.com/grokify/omnistorage"
+	"golang.org/x/sys/unix"
+)
+
+// diskUsage returns the size, used and free space of the filesystem
+// holding path.
+func diskUsage(path string) (omnistorage.Usage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return omnistorage.Usage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return omnistorage.Usage{
+		Total: int64(st.Blocks * bsize),
+		Used:  int64((st.Blocks - st.Bfree) * bsize),
+		Free:  int64(st.Bavail * bsize),
+	}, nil
+}
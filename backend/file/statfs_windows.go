@@ -0,0 +1,26 @@
+//go:build windows
+
+package file
+
+import (
+	"github.com/grokify/omnistorage"
+	"golang.org/x/sys/windows"
+)
+
+// diskUsage returns the size, used and free space of the volume holding
+// path.
+func diskUsage(path string) (omnistorage.Usage, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return omnistorage.Usage{}, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree); err != nil {
+		return omnistorage.Usage{}, err
+	}
+	return omnistorage.Usage{
+		Total: int64(total),
+		Used:  int64(total - totalFree),
+		Free:  int64(free),
+	}, nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+
+package file
+
+import "os"
+
+// fsetxattr is not supported on this platform.
+func fsetxattr(_ *os.File, _ string, _ []byte) error {
+	return errXattrUnsupported
+}
+
+// getxattr is not supported on this platform.
+func getxattr(_, _ string) ([]byte, error) {
+	return nil, errXattrUnsupported
+}
+
+// setxattr is not supported on this platform.
+func setxattr(_, _ string, _ []byte) error {
+	return errXattrUnsupported
+}
+
+// removexattr is not supported on this platform.
+func removexattr(_, _ string) error {
+	return errXattrUnsupported
+}
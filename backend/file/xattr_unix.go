@@ -0,0 +1,42 @@
+//go:build linux || darwin
+
+package file
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsetxattr sets an extended attribute on an open file.
+func fsetxattr(f *os.File, name string, data []byte) error {
+	return unix.Fsetxattr(int(f.Fd()), name, data, 0)
+}
+
+// getxattr returns an extended attribute of the file at path.
+func getxattr(path, name string) ([]byte, error) {
+	size, err := unix.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	n, err := unix.Getxattr(path, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// setxattr sets an extended attribute of the file at path.
+func setxattr(path, name string, data []byte) error {
+	return unix.Setxattr(path, name, data, 0)
+}
+
+// removexattr removes an extended attribute of the file at path. It
+// succeeds if the attribute does not exist.
+func removexattr(path, name string) error {
+	if _, err := getxattr(path, name); err != nil {
+		return nil // absent, or unreadable and so never used
+	}
+	return unix.Removexattr(path, name)
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Abouter
+var _ omnistorage.Abouter = (*Backend)(nil)
+
+// About reports the bytes and files stored. Total and Free are the
+// WithMaxBytes limit and the room left under it, or -1 without a limit.
+func (b *Backend) About(ctx context.Context) (omnistorage.Usage, error) {
+	if err := b.checkClosed(); err != nil {
+		return omnistorage.Usage{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return omnistorage.Usage{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	usage := omnistorage.Usage{Total: -1, Used: b.bytes, Free: -1, Objects: int64(b.files)}
+	if b.maxBytes > 0 {
+		usage.Total = b.maxBytes
+		usage.Free = max(b.maxBytes-b.bytes, 0)
+	}
+	return usage, nil
+}
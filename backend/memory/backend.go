@@ -11,6 +11,7 @@ package memory
 
 import (
 	"bytes"
+	"container/list"
 	"context"
 	"fmt"
 	"io"
@@ -24,35 +25,96 @@ import (
 )
 
 func init() {
-	omnistorage.Register("memory", NewFromConfig)
+	omnistorage.Register("memory", NewFromConfig,
+		omnistorage.WithDescription("In-memory storage for tests and caching"),
+		omnistorage.WithFeatures((&Backend{}).Features()))
 }
 
 // object represents a stored object in memory.
+//
+// The data slice is immutable once stored: writes and copies replace the
+// object rather than modify it, so readers and copies share it without
+// copying.
 type object struct {
 	data        []byte
 	contentType string
+	headers     headers
 	modTime     time.Time
 	isDir       bool
+	elem        *list.Element // position in the LRU list, for files in a limited backend
+
+	hashMu sync.Mutex
+	hashes map[omnistorage.HashType]string // computed on demand
+}
+
+// headers are the standard HTTP headers stored with an object.
+type headers struct {
+	cacheControl       string
+	contentEncoding    string
+	contentDisposition string
 }
 
 // Backend implements omnistorage.ExtendedBackend for in-memory storage.
 type Backend struct {
-	objects map[string]*object
-	closed  bool
-	mu      sync.RWMutex
+	objects    map[string]*object
+	maxBytes   int64
+	maxObjects int
+	eviction   EvictionPolicy
+	lru        *list.List // file paths, most recently used first
+	files      int
+	bytes      int64
+	stats      Stats
+	closed     bool
+	mu         sync.RWMutex
 }
 
-// New creates a new memory backend.
-func New() *Backend {
-	return &Backend{
+// New creates a new memory backend with optional configuration. By
+// default it is unbounded; use WithMaxBytes and WithMaxObjects to use it
+// as a bounded cache.
+func New(opts ...Option) *Backend {
+	b := &Backend{
 		objects: make(map[string]*object),
+		lru:     list.New(),
 	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
 }
 
 // NewFromConfig creates a new memory backend from a config map.
-// The memory backend ignores all configuration options.
-func NewFromConfig(_ map[string]string) (omnistorage.Backend, error) {
-	return New(), nil
+// Supported options:
+//   - max_bytes: Limit on the total size of files (default: unlimited)
+//   - max_objects: Limit on the number of files (default: unlimited)
+//   - eviction: "reject" or "lru" (default: "reject")
+func NewFromConfig(config map[string]string) (omnistorage.Backend, error) {
+	var opts []Option
+
+	if s, ok := config["max_bytes"]; ok {
+		var n int64
+		if _, err := fmt.Sscanf(s, "%d", &n); err != nil || n < 0 {
+			return nil, fmt.Errorf("memory: invalid max_bytes: %q", s)
+		}
+		opts = append(opts, WithMaxBytes(n))
+	}
+
+	if s, ok := config["max_objects"]; ok {
+		var n int
+		if _, err := fmt.Sscanf(s, "%d", &n); err != nil || n < 0 {
+			return nil, fmt.Errorf("memory: invalid max_objects: %q", s)
+		}
+		opts = append(opts, WithMaxObjects(n))
+	}
+
+	if s, ok := config["eviction"]; ok {
+		p, err := ParseEvictionPolicy(s)
+		if err != nil {
+			return nil, fmt.Errorf("memory: %w", err)
+		}
+		opts = append(opts, WithEviction(p))
+	}
+
+	return New(opts...), nil
 }
 
 // NewWriter creates a writer for the given path.
@@ -73,9 +135,16 @@ func (b *Backend) NewWriter(ctx context.Context, p string, opts ...omnistorage.W
 
 	return &memoryWriter{
 		backend:     b,
+		maxBytes:    b.maxBytes,
 		path:        normalizePath(p),
 		buffer:      &bytes.Buffer{},
 		contentType: config.ContentType,
+		headers: headers{
+			cacheControl:       config.CacheControl,
+			contentEncoding:    config.ContentEncoding,
+			contentDisposition: config.ContentDisposition,
+		},
+		verify: omnistorage.NewChecksumVerifier(config),
 	}, nil
 }
 
@@ -95,10 +164,7 @@ func (b *Backend) NewReader(ctx context.Context, p string, opts ...omnistorage.R
 
 	normalPath := normalizePath(p)
 
-	b.mu.RLock()
-	obj, exists := b.objects[normalPath]
-	b.mu.RUnlock()
-
+	obj, exists := b.lookup(normalPath)
 	if !exists {
 		return nil, omnistorage.ErrNotFound
 	}
@@ -109,9 +175,8 @@ func (b *Backend) NewReader(ctx context.Context, p string, opts ...omnistorage.R
 
 	config := omnistorage.ApplyReaderOptions(opts...)
 
-	// Make a copy of the data to avoid race conditions
-	data := make([]byte, len(obj.data))
-	copy(data, obj.data)
+	// The data is immutable, so the reader can share it
+	data := obj.data
 
 	// Apply offset
 	if config.Offset > 0 {
@@ -172,13 +237,13 @@ func (b *Backend) Delete(ctx context.Context, p string) error {
 	normalPath := normalizePath(p)
 
 	b.mu.Lock()
-	delete(b.objects, normalPath)
+	b.remove(normalPath)
 	b.mu.Unlock()
 
 	return nil
 }
 
-// List lists paths with the given prefix.
+// List lists the paths under the directory prefix, relative to it.
 func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	if err := b.checkClosed(); err != nil {
 		return nil, err
@@ -188,7 +253,7 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 		return nil, err
 	}
 
-	normalPrefix := normalizePath(prefix)
+	normalPrefix := omnistorage.CleanPrefix(prefix)
 
 	b.mu.RLock()
 	defer b.mu.RUnlock()
@@ -206,8 +271,8 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 		}
 
 		// Match prefix
-		if normalPrefix == "" || strings.HasPrefix(p, normalPrefix) || strings.HasPrefix(p, normalPrefix+"/") {
-			paths = append(paths, p)
+		if rel, ok := strings.CutPrefix(p, normalPrefix); ok {
+			paths = append(paths, rel)
 		}
 	}
 
@@ -215,13 +280,43 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	return paths, nil
 }
 
+// ListDirs lists the directories created with Mkdir under prefix,
+// relative to it.
+func (b *Backend) ListDirs(ctx context.Context, prefix string) ([]string, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	normalPrefix := omnistorage.CleanPrefix(prefix)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	dirs := []string{}
+	for p, obj := range b.objects {
+		if !obj.isDir {
+			continue
+		}
+		if rel, ok := strings.CutPrefix(p, normalPrefix); ok && rel != "" {
+			dirs = append(dirs, rel)
+		}
+	}
+
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
 // Close releases any resources held by the backend.
 func (b *Backend) Close() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	b.closed = true
-	b.objects = nil
+	b.reset(nil)
 	return nil
 }
 
@@ -254,12 +349,19 @@ func (b *Backend) Stat(ctx context.Context, p string) (omnistorage.ObjectInfo, e
 		size = 0
 	}
 
-	return &omnistorage.BasicObjectInfo{
-		ObjectPath:        normalPath,
-		ObjectSize:        size,
-		ObjectModTime:     obj.modTime,
-		ObjectIsDir:       obj.isDir,
-		ObjectContentType: obj.contentType,
+	return &objectInfo{
+		BasicObjectInfo: omnistorage.BasicObjectInfo{
+			ObjectPath:        normalPath,
+			ObjectSize:        size,
+			ObjectModTime:     obj.modTime,
+			ObjectIsDir:       obj.isDir,
+			ObjectContentType: obj.contentType,
+
+			ObjectCacheControl:       obj.headers.cacheControl,
+			ObjectContentEncoding:    obj.headers.contentEncoding,
+			ObjectContentDisposition: obj.headers.contentDisposition,
+		},
+		obj: obj,
 	}, nil
 }
 
@@ -287,10 +389,10 @@ func (b *Backend) Mkdir(ctx context.Context, p string) error {
 	for i := range parts {
 		dirPath := strings.Join(parts[:i+1], "/")
 		if _, exists := b.objects[dirPath]; !exists {
-			b.objects[dirPath] = &object{
+			_ = b.put(dirPath, &object{
 				isDir:   true,
 				modTime: time.Now(),
-			}
+			})
 		}
 	}
 
@@ -333,7 +435,7 @@ func (b *Backend) Rmdir(ctx context.Context, p string) error {
 		}
 	}
 
-	delete(b.objects, normalPath)
+	b.remove(normalPath)
 	return nil
 }
 
@@ -369,18 +471,15 @@ func (b *Backend) Copy(ctx context.Context, src, dst string) error {
 		return fmt.Errorf("cannot copy directory: %s", src)
 	}
 
-	// Create a copy of the data
-	dataCopy := make([]byte, len(srcObj.data))
-	copy(dataCopy, srcObj.data)
-
-	b.objects[dstPath] = &object{
-		data:        dataCopy,
+	// The data is immutable, so the copy can share it
+	return b.put(dstPath, &object{
+		data:        srcObj.data,
 		contentType: srcObj.contentType,
+		headers:     srcObj.headers,
 		modTime:     time.Now(),
 		isDir:       false,
-	}
-
-	return nil
+		hashes:      srcObj.cachedHashes(),
+	})
 }
 
 // Move moves/renames an object from src to dst.
@@ -415,14 +514,23 @@ func (b *Backend) Move(ctx context.Context, src, dst string) error {
 		return fmt.Errorf("cannot move directory: %s", src)
 	}
 
-	// Move the object
-	b.objects[dstPath] = &object{
+	if srcPath == dstPath {
+		return nil
+	}
+
+	// Move the object; removing the source first frees its space
+	b.remove(srcPath)
+	if err := b.put(dstPath, &object{
 		data:        srcObj.data,
 		contentType: srcObj.contentType,
+		headers:     srcObj.headers,
 		modTime:     time.Now(),
 		isDir:       false,
+		hashes:      srcObj.cachedHashes(),
+	}); err != nil {
+		_ = b.put(srcPath, srcObj)
+		return err
 	}
-	delete(b.objects, srcPath)
 
 	return nil
 }
@@ -435,7 +543,7 @@ func (b *Backend) Features() omnistorage.Features {
 		Mkdir:                true,
 		Rmdir:                true,
 		Stat:                 true,
-		Hashes:               []omnistorage.HashType{}, // Can compute on demand
+		Hashes:               []omnistorage.HashType{omnistorage.HashMD5, omnistorage.HashSHA256}, // Computed on demand
 		CanStream:            true,
 		ServerSideEncryption: false,
 		Versioning:           false,
@@ -450,11 +558,7 @@ func (b *Backend) Size() int64 {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	var total int64
-	for _, obj := range b.objects {
-		total += int64(len(obj.data))
-	}
-	return total
+	return b.bytes
 }
 
 // Count returns the number of objects in the backend.
@@ -462,13 +566,7 @@ func (b *Backend) Count() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	count := 0
-	for _, obj := range b.objects {
-		if !obj.isDir {
-			count++
-		}
-	}
-	return count
+	return b.files
 }
 
 // Clear removes all objects from the backend.
@@ -476,7 +574,7 @@ func (b *Backend) Clear() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.objects = make(map[string]*object)
+	b.reset(make(map[string]*object))
 }
 
 // checkClosed returns an error if the backend is closed.
@@ -525,8 +623,11 @@ func normalizePath(p string) string {
 type memoryWriter struct {
 	backend     *Backend
 	path        string
+	maxBytes    int64
 	buffer      *bytes.Buffer
 	contentType string
+	headers     headers
+	verify      *omnistorage.ChecksumVerifier
 	closed      bool
 	mu          sync.Mutex
 }
@@ -539,6 +640,14 @@ func (w *memoryWriter) Write(p []byte) (n int, err error) {
 		return 0, omnistorage.ErrWriterClosed
 	}
 
+	// Fail early rather than buffer an object that can never be stored
+	if w.maxBytes > 0 && int64(w.buffer.Len()+len(p)) > w.maxBytes {
+		w.backend.mu.Lock()
+		w.backend.stats.Rejections++
+		w.backend.mu.Unlock()
+		return 0, fmt.Errorf("%w: %s exceeds %d bytes", ErrCapacityExceeded, w.path, w.maxBytes)
+	}
+
 	return w.buffer.Write(p)
 }
 
@@ -552,6 +661,11 @@ func (w *memoryWriter) Close() error {
 
 	w.closed = true
 
+	_, _ = w.verify.Write(w.buffer.Bytes())
+	if err := w.verify.Verify(); err != nil {
+		return fmt.Errorf("writing %s: %w", w.path, err)
+	}
+
 	// Store the data in the backend
 	w.backend.mu.Lock()
 	defer w.backend.mu.Unlock()
@@ -560,14 +674,15 @@ func (w *memoryWriter) Close() error {
 		return omnistorage.ErrBackendClosed
 	}
 
-	w.backend.objects[w.path] = &object{
+	// The buffer is not written to again, so its bytes become the
+	// object's immutable data
+	return w.backend.put(w.path, &object{
 		data:        w.buffer.Bytes(),
 		contentType: w.contentType,
+		headers:     w.headers,
 		modTime:     time.Now(),
 		isDir:       false,
-	}
-
-	return nil
+	})
 }
 
 // memoryReader implements io.ReadCloser for memory backend.
@@ -598,3 +713,6 @@ func (r *memoryReader) Close() error {
 
 // Ensure Backend implements omnistorage.ExtendedBackend
 var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// Ensure Backend implements omnistorage.DirLister
+var _ omnistorage.DirLister = (*Backend)(nil)
@@ -2,7 +2,11 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"io"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/grokify/omnistorage"
@@ -375,6 +379,54 @@ func TestStat(t *testing.T) {
 	}
 }
 
+func TestStatHash(t *testing.T) {
+	backend := New()
+	defer func() { _ = backend.Close() }()
+
+	ctx := context.Background()
+	writeTestFile(t, backend, "test.txt", "hello")
+
+	info, err := backend.Stat(ctx, "test.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	for _, ht := range []omnistorage.HashType{omnistorage.HashMD5, omnistorage.HashSHA256} {
+		want := omnistorage.HashBytes([]byte("hello"), ht)
+		if got := info.Hash(ht); got != want {
+			t.Errorf("Hash(%s) = %q, want %q", ht, got, want)
+		}
+		if !backend.Features().SupportsHash(ht) {
+			t.Errorf("Features does not list %s", ht)
+		}
+	}
+	if got := info.Hash(omnistorage.HashCRC32C); got != "" {
+		t.Errorf("Hash(crc32c) = %q, want empty", got)
+	}
+
+	// Copies keep the hash; overwrites change it
+	if err := backend.Copy(ctx, "test.txt", "copy.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	writeTestFile(t, backend, "test.txt", "changed")
+
+	info, _ = backend.Stat(ctx, "test.txt")
+	if got, want := info.Hash(omnistorage.HashMD5), omnistorage.HashBytes([]byte("changed"), omnistorage.HashMD5); got != want {
+		t.Errorf("Hash after overwrite = %q, want %q", got, want)
+	}
+	info, _ = backend.Stat(ctx, "copy.txt")
+	if got, want := info.Hash(omnistorage.HashMD5), omnistorage.HashBytes([]byte("hello"), omnistorage.HashMD5); got != want {
+		t.Errorf("Hash of copy = %q, want %q", got, want)
+	}
+
+	if err := backend.Mkdir(ctx, "dir"); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	info, _ = backend.Stat(ctx, "dir")
+	if got := info.Hash(omnistorage.HashMD5); got != "" {
+		t.Errorf("Hash of directory = %q, want empty", got)
+	}
+}
+
 func TestStatNotFound(t *testing.T) {
 	backend := New()
 	defer func() { _ = backend.Close() }()
@@ -566,6 +618,58 @@ func TestMoveNotFound(t *testing.T) {
 	}
 }
 
+func TestReaderSurvivesOverwrite(t *testing.T) {
+	backend := New()
+	defer func() { _ = backend.Close() }()
+
+	ctx := context.Background()
+	writeTestFile(t, backend, "test.txt", "original")
+
+	r, err := backend.NewReader(ctx, "test.txt")
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	writeTestFile(t, backend, "test.txt", "replaced")
+	if err := backend.Copy(ctx, "test.txt", "copy.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	writeTestFile(t, backend, "test.txt", "replaced again")
+
+	data, _ := io.ReadAll(r)
+	if string(data) != "original" {
+		t.Errorf("open reader got %q, want %q", data, "original")
+	}
+	if got := readTestFile(t, backend, "copy.txt"); got != "replaced" {
+		t.Errorf("copy.txt = %q, want %q", got, "replaced")
+	}
+}
+
+func TestNewReaderZeroCopy(t *testing.T) {
+	backend := New()
+	defer func() { _ = backend.Close() }()
+
+	ctx := context.Background()
+	const size = 1 << 20
+	writeTestFile(t, backend, "big.bin", strings.Repeat("x", size))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 20; i++ {
+		r, err := backend.NewReader(ctx, "big.bin", omnistorage.WithOffset(1))
+		if err != nil {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		_ = r.Close()
+	}
+	runtime.ReadMemStats(&after)
+
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > size {
+		t.Errorf("20 NewReader calls allocated %d bytes, want less than one %d-byte object", alloc, size)
+	}
+}
+
 func TestFeatures(t *testing.T) {
 	backend := New()
 	defer func() { _ = backend.Close() }()
@@ -850,3 +954,52 @@ func TestPathNormalization(t *testing.T) {
 		t.Errorf("Data = %q, want %q", data, "test")
 	}
 }
+
+func TestListDirs(t *testing.T) {
+	backend := New()
+	ctx := context.Background()
+	if err := backend.Mkdir(ctx, "a/b"); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, backend, "c/file.txt", "data")
+
+	dirs, err := backend.ListDirs(ctx, "")
+	if err != nil {
+		t.Fatalf("ListDirs failed: %v", err)
+	}
+	if want := []string{"a", "a/b"}; !slices.Equal(dirs, want) {
+		t.Errorf("ListDirs() = %v, want %v", dirs, want)
+	}
+
+	dirs, _ = backend.ListDirs(ctx, "a")
+	if !slices.Equal(dirs, []string{"b"}) {
+		t.Errorf("ListDirs(a) = %v, want [b]", dirs)
+	}
+}
+
+func TestWriterChecksum(t *testing.T) {
+	backend := New()
+	defer func() { _ = backend.Close() }()
+	ctx := context.Background()
+	data := []byte("checked")
+
+	write := func(p string, opts ...omnistorage.WriterOption) error {
+		w, err := backend.NewWriter(ctx, p, opts...)
+		if err != nil {
+			return err
+		}
+		_, _ = w.Write(data)
+		return w.Close()
+	}
+
+	if err := write("good.txt", omnistorage.WithContentSHA256(omnistorage.HashBytes(data, omnistorage.HashSHA256))); err != nil {
+		t.Errorf("Close with matching checksum: %v", err)
+	}
+	err := write("bad.txt", omnistorage.WithContentMD5(omnistorage.HashBytes([]byte("other"), omnistorage.HashMD5)))
+	if !errors.Is(err, omnistorage.ErrChecksumMismatch) {
+		t.Errorf("Close with wrong checksum = %v, want ErrChecksumMismatch", err)
+	}
+	if exists, _ := backend.Exists(ctx, "bad.txt"); exists {
+		t.Error("object with wrong checksum was stored")
+	}
+}
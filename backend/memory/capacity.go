@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+)
+
+// ErrCapacityExceeded is returned when a write would take the backend over
+// its byte or object limit and the eviction policy is EvictReject, or when
+// a single object is larger than the byte limit.
+var ErrCapacityExceeded = errors.New("memory: capacity exceeded")
+
+// EvictionPolicy decides what happens when a write would exceed a limit.
+type EvictionPolicy int
+
+const (
+	// EvictReject fails the write with ErrCapacityExceeded.
+	EvictReject EvictionPolicy = iota
+
+	// EvictLRU deletes the least recently used files until the write
+	// fits. Files are used when they are written or opened for reading.
+	EvictLRU
+)
+
+// String returns the policy name.
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictReject:
+		return "reject"
+	case EvictLRU:
+		return "lru"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseEvictionPolicy parses a policy name as returned by String.
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	switch s {
+	case "reject":
+		return EvictReject, nil
+	case "lru":
+		return EvictLRU, nil
+	default:
+		return 0, fmt.Errorf("unknown eviction policy: %q", s)
+	}
+}
+
+// Option configures a memory backend.
+type Option func(*Backend)
+
+// WithMaxBytes limits the total size of stored files. A writer fails as
+// soon as it has buffered more than n bytes. Default is 0 (unlimited).
+func WithMaxBytes(n int64) Option {
+	return func(b *Backend) {
+		b.maxBytes = n
+	}
+}
+
+// WithMaxObjects limits the number of stored files. Directories are not
+// counted. Default is 0 (unlimited).
+func WithMaxObjects(n int) Option {
+	return func(b *Backend) {
+		b.maxObjects = n
+	}
+}
+
+// WithEviction sets what happens when a write would exceed a limit.
+// Default is EvictReject.
+func WithEviction(p EvictionPolicy) Option {
+	return func(b *Backend) {
+		b.eviction = p
+	}
+}
+
+// Stats reports the backend's usage and eviction counters.
+type Stats struct {
+	// Objects is the number of stored files.
+	Objects int
+
+	// Bytes is the total size of stored files.
+	Bytes int64
+
+	// Evictions is the number of files deleted by EvictLRU.
+	Evictions int64
+
+	// EvictedBytes is the total size of evicted files.
+	EvictedBytes int64
+
+	// Rejections is the number of writes failed with ErrCapacityExceeded.
+	Rejections int64
+}
+
+// Stats returns the current usage and eviction counters.
+func (b *Backend) Stats() Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	s := b.stats
+	s.Objects = b.files
+	s.Bytes = b.bytes
+	return s
+}
+
+// limited reports whether the backend has a byte or object limit.
+func (b *Backend) limited() bool {
+	return b.maxBytes > 0 || b.maxObjects > 0
+}
+
+// put stores obj at p, evicting or rejecting per the limits. The caller
+// must hold b.mu for writing.
+func (b *Backend) put(p string, obj *object) error {
+	old := b.objects[p]
+	if !obj.isDir {
+		size := int64(len(obj.data))
+		if b.maxBytes > 0 && size > b.maxBytes {
+			b.stats.Rejections++
+			return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrCapacityExceeded, p, size, b.maxBytes)
+		}
+		if err := b.makeRoom(p, old, size); err != nil {
+			return err
+		}
+	}
+
+	b.remove(p)
+	b.objects[p] = obj
+	if !obj.isDir {
+		b.files++
+		b.bytes += int64(len(obj.data))
+		if b.limited() {
+			obj.elem = b.lru.PushFront(p)
+		}
+	}
+	return nil
+}
+
+// makeRoom ensures a file of size bytes fits at p, replacing old.
+func (b *Backend) makeRoom(p string, old *object, size int64) error {
+	fits := func() bool {
+		bytes, files := b.bytes+size, b.files+1
+		if old != nil && !old.isDir {
+			bytes -= int64(len(old.data))
+			files--
+		}
+		return (b.maxBytes <= 0 || bytes <= b.maxBytes) &&
+			(b.maxObjects <= 0 || files <= b.maxObjects)
+	}
+	for !fits() {
+		if b.eviction != EvictLRU || !b.evictOldest(p) {
+			b.stats.Rejections++
+			return fmt.Errorf("%w: no room for %s", ErrCapacityExceeded, p)
+		}
+	}
+	return nil
+}
+
+// evictOldest deletes the least recently used file other than keep and
+// reports whether there was one.
+func (b *Backend) evictOldest(keep string) bool {
+	for e := b.lru.Back(); e != nil; e = e.Prev() {
+		p := e.Value.(string)
+		if p == keep {
+			continue
+		}
+		b.stats.Evictions++
+		b.stats.EvictedBytes += int64(len(b.objects[p].data))
+		b.remove(p)
+		return true
+	}
+	return false
+}
+
+// remove deletes the object at p, if any. The caller must hold b.mu for
+// writing.
+func (b *Backend) remove(p string) {
+	obj, ok := b.objects[p]
+	if !ok {
+		return
+	}
+	delete(b.objects, p)
+	if !obj.isDir {
+		b.files--
+		b.bytes -= int64(len(obj.data))
+		if obj.elem != nil {
+			b.lru.Remove(obj.elem)
+			obj.elem = nil
+		}
+	}
+}
+
+// touch marks the file at p as recently used.
+func (b *Backend) touch(obj *object) {
+	if obj.elem != nil {
+		b.lru.MoveToFront(obj.elem)
+	}
+}
+
+// reset replaces the contents with the empty map objects. The caller
+// must hold b.mu for writing.
+func (b *Backend) reset(objects map[string]*object) {
+	b.objects = objects
+	b.lru = list.New()
+	b.files, b.bytes = 0, 0
+}
+
+// lookup returns the object at p, marking files as recently used under
+// EvictLRU.
+func (b *Backend) lookup(p string) (*object, bool) {
+	if b.eviction != EvictLRU || !b.limited() {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		obj, ok := b.objects[p]
+		return obj, ok
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	obj, ok := b.objects[p]
+	if ok {
+		b.touch(obj)
+	}
+	return obj, ok
+}
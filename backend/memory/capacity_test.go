@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestMaxBytesReject(t *testing.T) {
+	ctx := context.Background()
+	b := New(WithMaxBytes(10))
+
+	writeTestFile(t, b, "a.txt", "12345")
+	writeTestFile(t, b, "b.txt", "12345")
+
+	w, _ := b.NewWriter(ctx, "c.txt")
+	_, _ = w.Write([]byte("1"))
+	if err := w.Close(); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("Close error = %v, want ErrCapacityExceeded", err)
+	}
+	if ok, _ := b.Exists(ctx, "c.txt"); ok {
+		t.Error("c.txt was stored despite the limit")
+	}
+
+	// Overwriting with a file of the same size fits
+	writeTestFile(t, b, "a.txt", "abcde")
+
+	w, _ = b.NewWriter(ctx, "big.txt")
+	if _, err := w.Write(bytes.Repeat([]byte("x"), 11)); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("Write error = %v, want ErrCapacityExceeded", err)
+	}
+
+	s := b.Stats()
+	if s.Objects != 2 || s.Bytes != 10 || s.Rejections != 2 || s.Evictions != 0 {
+		t.Errorf("Stats() = %+v, want 2 objects, 10 bytes, 2 rejections", s)
+	}
+}
+
+func TestMaxObjectsLRU(t *testing.T) {
+	ctx := context.Background()
+	b := New(WithMaxObjects(2), WithEviction(EvictLRU))
+
+	writeTestFile(t, b, "a.txt", "a")
+	writeTestFile(t, b, "b.txt", "bb")
+	_ = readTestFile(t, b, "a.txt") // b.txt is now least recently used
+	writeTestFile(t, b, "c.txt", "c")
+
+	if ok, _ := b.Exists(ctx, "b.txt"); ok {
+		t.Error("b.txt was not evicted")
+	}
+	for _, p := range []string{"a.txt", "c.txt"} {
+		if ok, _ := b.Exists(ctx, p); !ok {
+			t.Errorf("%s was evicted", p)
+		}
+	}
+
+	// Directories are not counted
+	if err := b.Mkdir(ctx, "dir"); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	s := b.Stats()
+	if s.Objects != 2 || s.Bytes != 2 || s.Evictions != 1 || s.EvictedBytes != 2 {
+		t.Errorf("Stats() = %+v, want 2 objects, 2 bytes, 1 eviction of 2 bytes", s)
+	}
+}
+
+func TestMaxBytesLRU(t *testing.T) {
+	ctx := context.Background()
+	b := New(WithMaxBytes(10), WithEviction(EvictLRU))
+
+	writeTestFile(t, b, "a.txt", "1234")
+	writeTestFile(t, b, "b.txt", "1234")
+	writeTestFile(t, b, "c.txt", "12345678")
+
+	paths, _ := b.List(ctx, "")
+	if strings.Join(paths, ",") != "c.txt" {
+		t.Errorf("List() = %v, want [c.txt]", paths)
+	}
+	if b.Size() != 8 || b.Count() != 1 {
+		t.Errorf("Size(), Count() = %d, %d; want 8, 1", b.Size(), b.Count())
+	}
+
+	// Copy evicts to make room; Move frees the source first
+	if err := b.Copy(ctx, "c.txt", "d.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if ok, _ := b.Exists(ctx, "c.txt"); ok {
+		t.Error("c.txt was not evicted by Copy")
+	}
+	if err := b.Move(ctx, "d.txt", "e.txt"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if s := b.Stats(); s.Objects != 1 || s.Bytes != 8 || s.Evictions != 3 {
+		t.Errorf("Stats() = %+v, want 1 object, 8 bytes, 3 evictions", s)
+	}
+}
+
+func TestLoadFromLimits(t *testing.T) {
+	ctx := context.Background()
+	src := New()
+	writeTestFile(t, src, "a.txt", "old")
+	writeTestFile(t, src, "b.txt", "new")
+
+	var buf bytes.Buffer
+	if err := src.SaveTo(ctx, &buf); err != nil {
+		t.Fatalf("SaveTo failed: %v", err)
+	}
+	snap := buf.Bytes()
+
+	reject := New(WithMaxObjects(1))
+	writeTestFile(t, reject, "keep.txt", "keep")
+	if err := reject.LoadFrom(ctx, bytes.NewReader(snap)); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("LoadFrom error = %v, want ErrCapacityExceeded", err)
+	}
+	if ok, _ := reject.Exists(ctx, "keep.txt"); !ok {
+		t.Error("contents changed after failed LoadFrom")
+	}
+
+	lru := New(WithMaxObjects(1), WithEviction(EvictLRU))
+	if err := lru.LoadFrom(ctx, bytes.NewReader(snap)); err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if got := readTestFile(t, lru, "b.txt"); got != "new" {
+		t.Errorf("b.txt = %q, want newest file kept", got)
+	}
+	if s := lru.Stats(); s.Objects != 1 || s.Evictions != 1 {
+		t.Errorf("Stats() = %+v, want 1 object, 1 eviction", s)
+	}
+}
+
+func TestNewFromConfigLimits(t *testing.T) {
+	backend, err := NewFromConfig(map[string]string{
+		"max_bytes":   "100",
+		"max_objects": "5",
+		"eviction":    "lru",
+	})
+	if err != nil {
+		t.Fatalf("NewFromConfig failed: %v", err)
+	}
+	b := backend.(*Backend)
+	if b.maxBytes != 100 || b.maxObjects != 5 || b.eviction != EvictLRU {
+		t.Errorf("limits = %d, %d, %v; want 100, 5, lru", b.maxBytes, b.maxObjects, b.eviction)
+	}
+
+	for _, config := range []map[string]string{
+		{"max_bytes": "lots"},
+		{"max_objects": "-1"},
+		{"eviction": "fifo"},
+	} {
+		if _, err := NewFromConfig(config); err == nil {
+			t.Errorf("NewFromConfig(%v) succeeded, want error", config)
+		}
+	}
+}
+
+func TestAbout(t *testing.T) {
+	ctx := context.Background()
+
+	b := New()
+	writeTestFile(t, b, "a.txt", "12345")
+	usage, err := b.About(ctx)
+	if err != nil {
+		t.Fatalf("About error = %v", err)
+	}
+	if want := (omnistorage.Usage{Total: -1, Used: 5, Free: -1, Objects: 1}); usage != want {
+		t.Errorf("About() = %+v, want %+v", usage, want)
+	}
+
+	b = New(WithMaxBytes(100))
+	writeTestFile(t, b, "a.txt", "12345")
+	writeTestFile(t, b, "b.txt", "123")
+	usage, _ = b.About(ctx)
+	if want := (omnistorage.Usage{Total: 100, Used: 8, Free: 92, Objects: 2}); usage != want {
+		t.Errorf("About() = %+v, want %+v", usage, want)
+	}
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New()
+	})
+}
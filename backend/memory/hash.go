@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"github.com/grokify/omnistorage"
+)
+
+// hash returns the hex-encoded hash of the object's data, computing it on
+// first use. Hashes are cached on the object; since stored data is never
+// modified, a write replaces the object and with it the cache.
+func (o *object) hash(t omnistorage.HashType) string {
+	if o.isDir || !isSupportedHash(t) {
+		return ""
+	}
+
+	o.hashMu.Lock()
+	defer o.hashMu.Unlock()
+
+	if h, ok := o.hashes[t]; ok {
+		return h
+	}
+	h := omnistorage.HashBytes(o.data, t)
+	if o.hashes == nil {
+		o.hashes = make(map[omnistorage.HashType]string)
+	}
+	o.hashes[t] = h
+	return h
+}
+
+// cachedHashes returns a copy of the hashes computed so far, for an object
+// that shares this object's data.
+func (o *object) cachedHashes() map[omnistorage.HashType]string {
+	o.hashMu.Lock()
+	defer o.hashMu.Unlock()
+
+	if len(o.hashes) == 0 {
+		return nil
+	}
+	hashes := make(map[omnistorage.HashType]string, len(o.hashes))
+	for t, h := range o.hashes {
+		hashes[t] = h
+	}
+	return hashes
+}
+
+// isSupportedHash reports whether t is one of the hash types listed in
+// Features.
+func isSupportedHash(t omnistorage.HashType) bool {
+	return t == omnistorage.HashMD5 || t == omnistorage.HashSHA256
+}
+
+// objectInfo is returned by Stat. Hashes are computed when first asked
+// for, so Stat stays cheap for callers that do not need them.
+type objectInfo struct {
+	omnistorage.BasicObjectInfo
+	obj *object
+}
+
+// Hash returns the MD5 or SHA-256 hash of the object.
+func (i *objectInfo) Hash(t omnistorage.HashType) string {
+	return i.obj.hash(t)
+}
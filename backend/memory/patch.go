@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Patcher
+var _ omnistorage.Patcher = (*Backend)(nil)
+
+// WriteAt writes p to the object at path at offset off, extending it if
+// needed.
+func (b *Backend) WriteAt(ctx context.Context, p string, data []byte, off int64) error {
+	if off < 0 {
+		return fmt.Errorf("memory: negative offset %d", off)
+	}
+	return b.patch(ctx, p, func(old []byte) []byte {
+		size := max(int64(len(old)), off+int64(len(data)))
+		buf := make([]byte, size)
+		copy(buf, old)
+		copy(buf[off:], data)
+		return buf
+	})
+}
+
+// Truncate changes the size of the object at path.
+func (b *Backend) Truncate(ctx context.Context, p string, size int64) error {
+	if size < 0 {
+		return fmt.Errorf("memory: negative size %d", size)
+	}
+	return b.patch(ctx, p, func(old []byte) []byte {
+		buf := make([]byte, size)
+		copy(buf, old)
+		return buf
+	})
+}
+
+// patch replaces the data of the file at p with update(data). Stored data
+// is immutable, so update must return a new slice.
+func (b *Backend) patch(ctx context.Context, p string, update func([]byte) []byte) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := validatePath(p); err != nil {
+		return err
+	}
+
+	normalPath := normalizePath(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	obj, exists := b.objects[normalPath]
+	if !exists {
+		return omnistorage.ErrNotFound
+	}
+	if obj.isDir {
+		return fmt.Errorf("cannot patch directory: %s", p)
+	}
+
+	return b.put(normalPath, &object{
+		data:        update(obj.data),
+		contentType: obj.contentType,
+		headers:     obj.headers,
+		modTime:     time.Now(),
+	})
+}
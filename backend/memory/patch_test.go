@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestPatch(t *testing.T) {
+	ctx := context.Background()
+	b := New(WithMaxBytes(12))
+	writeTestFile(t, b, "a.txt", "hello world")
+
+	if err := b.WriteAt(ctx, "a.txt", []byte("W"), 6); err != nil {
+		t.Fatalf("WriteAt failed: %v", err)
+	}
+	if err := b.WriteAt(ctx, "a.txt", []byte("!"), 11); err != nil {
+		t.Fatalf("WriteAt at end failed: %v", err)
+	}
+	if got := readTestFile(t, b, "a.txt"); got != "hello World!" {
+		t.Errorf("content = %q, want %q", got, "hello World!")
+	}
+	if err := b.WriteAt(ctx, "a.txt", []byte("x"), 12); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("WriteAt past capacity error = %v, want ErrCapacityExceeded", err)
+	}
+
+	if err := b.Truncate(ctx, "a.txt", 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if got := readTestFile(t, b, "a.txt"); got != "hello" {
+		t.Errorf("content = %q, want %q", got, "hello")
+	}
+	if s := b.Stats(); s.Bytes != 5 {
+		t.Errorf("Stats().Bytes = %d, want 5", s.Bytes)
+	}
+
+	if err := b.WriteAt(ctx, "missing.txt", []byte("x"), 0); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("WriteAt(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := b.Truncate(ctx, "missing.txt", 0); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Truncate(missing) error = %v, want ErrNotFound", err)
+	}
+}
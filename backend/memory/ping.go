@@ -0,0 +1,18 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Pinger
+var _ omnistorage.Pinger = (*Backend)(nil)
+
+// Ping returns ErrBackendClosed if the backend is closed.
+func (b *Backend) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.checkClosed()
+}
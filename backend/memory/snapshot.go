@@ -0,0 +1,297 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// snapshotMagic starts every snapshot.
+const snapshotMagic = "OSMEMv1\n"
+
+// Snapshot flags, stored in the byte after the magic.
+const (
+	flagCompressed byte = 1 << iota
+	flagEncrypted
+)
+
+// ErrInvalidSnapshot is returned by LoadFrom for data that is not a
+// memory backend snapshot.
+var ErrInvalidSnapshot = errors.New("memory: invalid snapshot")
+
+// SnapshotOption configures SaveTo and LoadFrom.
+type SnapshotOption func(*snapshotConfig)
+
+type snapshotConfig struct {
+	compress bool
+	key      []byte
+}
+
+// WithCompression gzip-compresses the snapshot. LoadFrom detects
+// compression on its own.
+func WithCompression() SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.compress = true
+	}
+}
+
+// WithEncryption encrypts the snapshot with AES-GCM. The key must be 16,
+// 24 or 32 bytes, for AES-128, AES-192 or AES-256. LoadFrom needs the
+// same key to read the snapshot.
+func WithEncryption(key []byte) SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.key = key
+	}
+}
+
+// snapshotObject is the serialized form of an object.
+type snapshotObject struct {
+	Path        string
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+	IsDir       bool
+
+	CacheControl       string
+	ContentEncoding    string
+	ContentDisposition string
+}
+
+// SaveTo writes a snapshot of every object and directory to w, so the
+// store can be restored with LoadFrom, for example after a restart or in
+// another environment.
+//
+// The snapshot is consistent: writes that complete during SaveTo are not
+// included. An encrypted snapshot is built in memory before it is
+// written.
+func (b *Backend) SaveTo(ctx context.Context, w io.Writer, opts ...SnapshotOption) error {
+	var cfg snapshotConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	objects, err := b.snapshot()
+	if err != nil {
+		return err
+	}
+
+	var flags byte
+	if cfg.compress {
+		flags |= flagCompressed
+	}
+	var gcm cipher.AEAD
+	if cfg.key != nil {
+		if gcm, err = newGCM(cfg.key); err != nil {
+			return err
+		}
+		flags |= flagEncrypted
+	}
+	header := []byte(snapshotMagic + string(flags))
+
+	if gcm == nil {
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		return encodeSnapshot(ctx, w, objects, cfg.compress)
+	}
+
+	var payload bytes.Buffer
+	if err := encodeSnapshot(ctx, &payload, objects, cfg.compress); err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := gcm.Seal(nonce, nonce, payload.Bytes(), header)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(sealed)
+	return err
+}
+
+// LoadFrom replaces the backend's contents with a snapshot written by
+// SaveTo. On error, the contents are unchanged. A snapshot larger than the
+// backend's limits fails with ErrCapacityExceeded under EvictReject; under
+// EvictLRU the oldest files are dropped.
+func (b *Backend) LoadFrom(ctx context.Context, r io.Reader, opts ...SnapshotOption) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	var cfg snapshotConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrInvalidSnapshot
+	}
+	flags := header[len(snapshotMagic)]
+
+	var payload io.Reader = br
+	if flags&flagEncrypted != 0 {
+		if cfg.key == nil {
+			return errors.New("memory: snapshot is encrypted; use WithEncryption")
+		}
+		gcm, err := newGCM(cfg.key)
+		if err != nil {
+			return err
+		}
+		sealed, err := io.ReadAll(br)
+		if err != nil {
+			return err
+		}
+		if len(sealed) < gcm.NonceSize() {
+			return ErrInvalidSnapshot
+		}
+		nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+		plain, err := gcm.Open(nil, nonce, ciphertext, header)
+		if err != nil {
+			return fmt.Errorf("memory: decrypting snapshot (wrong key?): %w", err)
+		}
+		payload = bytes.NewReader(plain)
+	}
+	if flags&flagCompressed != 0 {
+		zr, err := gzip.NewReader(payload)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		defer func() { _ = zr.Close() }()
+		payload = zr
+	}
+
+	var objects []snapshotObject
+	dec := gob.NewDecoder(payload)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var so snapshotObject
+		if err := dec.Decode(&so); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		if err := validatePath(so.Path); err != nil {
+			return fmt.Errorf("%w: path %q", ErrInvalidSnapshot, so.Path)
+		}
+		objects = append(objects, so)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return omnistorage.ErrBackendClosed
+	}
+
+	// Load into a fresh backend with the same limits, oldest first, so
+	// that EvictLRU keeps the newest files
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].ModTime.Before(objects[j].ModTime)
+	})
+	loaded := &Backend{
+		objects:    make(map[string]*object, len(objects)),
+		maxBytes:   b.maxBytes,
+		maxObjects: b.maxObjects,
+		eviction:   b.eviction,
+		lru:        list.New(),
+	}
+	for _, so := range objects {
+		err := loaded.put(normalizePath(so.Path), &object{
+			data:        so.Data,
+			contentType: so.ContentType,
+			headers: headers{
+				cacheControl:       so.CacheControl,
+				contentEncoding:    so.ContentEncoding,
+				contentDisposition: so.ContentDisposition,
+			},
+			modTime: so.ModTime,
+			isDir:   so.IsDir,
+		})
+		if err != nil {
+			b.stats.Rejections++
+			return err
+		}
+	}
+
+	b.objects, b.lru = loaded.objects, loaded.lru
+	b.files, b.bytes = loaded.files, loaded.bytes
+	b.stats.Evictions += loaded.stats.Evictions
+	b.stats.EvictedBytes += loaded.stats.EvictedBytes
+	return nil
+}
+
+// snapshot returns the current objects. Object data is never modified in
+// place, so the objects can be encoded without holding the lock.
+func (b *Backend) snapshot() ([]snapshotObject, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return nil, omnistorage.ErrBackendClosed
+	}
+	objects := make([]snapshotObject, 0, len(b.objects))
+	for p, obj := range b.objects {
+		objects = append(objects, snapshotObject{
+			Path:        p,
+			Data:        obj.data,
+			ContentType: obj.contentType,
+			ModTime:     obj.modTime,
+			IsDir:       obj.isDir,
+
+			CacheControl:       obj.headers.cacheControl,
+			ContentEncoding:    obj.headers.contentEncoding,
+			ContentDisposition: obj.headers.contentDisposition,
+		})
+	}
+	return objects, nil
+}
+
+// encodeSnapshot writes objects as a stream of gob values, optionally
+// gzip-compressed.
+func encodeSnapshot(ctx context.Context, w io.Writer, objects []snapshotObject, compress bool) error {
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+	enc := gob.NewEncoder(w)
+	for i := range objects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := enc.Encode(&objects[i]); err != nil {
+			return err
+		}
+	}
+	if zw != nil {
+		return zw.Close()
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("memory: snapshot key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func newSnapshotFixture(t *testing.T) *Backend {
+	t.Helper()
+	ctx := context.Background()
+	b := New()
+	for path, content := range map[string]string{
+		"a.txt":     "alpha",
+		"dir/b.txt": "bravo",
+	} {
+		w, err := b.NewWriter(ctx, path, omnistorage.WithContentType("text/plain"))
+		if err != nil {
+			t.Fatalf("NewWriter(%q) failed: %v", path, err)
+		}
+		_, _ = w.Write([]byte(content))
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close(%q) failed: %v", path, err)
+		}
+	}
+	if err := b.Mkdir(ctx, "empty"); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	return b
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	tests := []struct {
+		name string
+		opts []SnapshotOption
+	}{
+		{"plain", nil},
+		{"compressed", []SnapshotOption{WithCompression()}},
+		{"encrypted", []SnapshotOption{WithEncryption(key)}},
+		{"compressed and encrypted", []SnapshotOption{WithCompression(), WithEncryption(key)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			src := newSnapshotFixture(t)
+			srcInfo, _ := src.Stat(ctx, "a.txt")
+
+			var buf bytes.Buffer
+			if err := src.SaveTo(ctx, &buf, tt.opts...); err != nil {
+				t.Fatalf("SaveTo failed: %v", err)
+			}
+
+			dst := New()
+			writeTestFile(t, dst, "stale.txt", "stale")
+			if err := dst.LoadFrom(ctx, &buf, tt.opts...); err != nil {
+				t.Fatalf("LoadFrom failed: %v", err)
+			}
+
+			if dst.Count() != src.Count() {
+				t.Errorf("Count() = %d, want %d", dst.Count(), src.Count())
+			}
+			if ok, _ := dst.Exists(ctx, "stale.txt"); ok {
+				t.Error("LoadFrom kept stale.txt, want contents replaced")
+			}
+			if got := readTestFile(t, dst, "dir/b.txt"); got != "bravo" {
+				t.Errorf("dir/b.txt = %q, want %q", got, "bravo")
+			}
+			info, err := dst.Stat(ctx, "a.txt")
+			if err != nil {
+				t.Fatalf("Stat failed: %v", err)
+			}
+			if info.ContentType() != "text/plain" || !info.ModTime().Equal(srcInfo.ModTime()) {
+				t.Errorf("Stat = (%q, %v), want (%q, %v)",
+					info.ContentType(), info.ModTime(), "text/plain", srcInfo.ModTime())
+			}
+			if info, err := dst.Stat(ctx, "empty"); err != nil || !info.IsDir() {
+				t.Errorf("Stat(empty) = %v, %v; want directory", info, err)
+			}
+		})
+	}
+}
+
+func TestSnapshotEncryptionKey(t *testing.T) {
+	ctx := context.Background()
+	src := newSnapshotFixture(t)
+
+	var buf bytes.Buffer
+	if err := src.SaveTo(ctx, &buf, WithEncryption(bytes.Repeat([]byte{1}, 32))); err != nil {
+		t.Fatalf("SaveTo failed: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("alpha")) {
+		t.Error("encrypted snapshot contains plaintext")
+	}
+	snap := buf.Bytes()
+
+	dst := New()
+	writeTestFile(t, dst, "keep.txt", "keep")
+	if err := dst.LoadFrom(ctx, bytes.NewReader(snap)); err == nil {
+		t.Error("LoadFrom without key succeeded, want error")
+	}
+	if err := dst.LoadFrom(ctx, bytes.NewReader(snap), WithEncryption(bytes.Repeat([]byte{2}, 32))); err == nil {
+		t.Error("LoadFrom with wrong key succeeded, want error")
+	}
+	if got := readTestFile(t, dst, "keep.txt"); got != "keep" {
+		t.Errorf("contents changed after failed LoadFrom: keep.txt = %q", got)
+	}
+
+	if err := src.SaveTo(ctx, io.Discard, WithEncryption([]byte("short"))); err == nil {
+		t.Error("SaveTo with invalid key size succeeded, want error")
+	}
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	ctx := context.Background()
+	b := New()
+	for _, data := range []string{"", "not a snapshot", snapshotMagic + "\x00garbage"} {
+		err := b.LoadFrom(ctx, bytes.NewReader([]byte(data)))
+		if !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("LoadFrom(%q) error = %v, want ErrInvalidSnapshot", data, err)
+		}
+	}
+}
+
+func TestSnapshotClosed(t *testing.T) {
+	ctx := context.Background()
+	b := New()
+	_ = b.Close()
+
+	if err := b.SaveTo(ctx, io.Discard); !errors.Is(err, omnistorage.ErrBackendClosed) {
+		t.Errorf("SaveTo error = %v, want ErrBackendClosed", err)
+	}
+	if err := b.LoadFrom(ctx, bytes.NewReader(nil)); !errors.Is(err, omnistorage.ErrBackendClosed) {
+		t.Errorf("LoadFrom error = %v, want ErrBackendClosed", err)
+	}
+}
+
+func writeTestFile(t *testing.T, b *Backend, path, content string) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewWriter(%q) failed: %v", path, err)
+	}
+	_, _ = w.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%q) failed: %v", path, err)
+	}
+}
+
+func readTestFile(t *testing.T, b *Backend, path string) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewReader(%q) failed: %v", path, err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll(%q) failed: %v", path, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,47 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Abouter
+var _ omnistorage.Abouter = (*Backend)(nil)
+
+// About reports the bytes and objects stored under the configured prefix.
+// S3 buckets have no capacity, so Total and Free are -1. Usage is counted
+// by listing every object, one request per 1000 objects, so for large
+// buckets prefer S3 Storage Lens or inventory reports.
+func (b *Backend) About(ctx context.Context) (omnistorage.Usage, error) {
+	if err := b.checkClosed(); err != nil {
+		return omnistorage.Usage{}, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return omnistorage.Usage{}, err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	usage := omnistorage.Usage{Total: -1, Free: -1}
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket:       aws.String(b.config.Bucket),
+		Prefix:       aws.String(b.fullKey("")),
+		RequestPayer: b.config.requestPayer(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return omnistorage.Usage{}, b.translateError(err, "about", "")
+		}
+		for _, obj := range page.Contents {
+			usage.Used += aws.ToInt64(obj.Size)
+			usage.Objects++
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,33 @@
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestAbout(t *testing.T) {
+	f, b := newFakeS3(t)
+	f.put("a.txt", []byte("12345"))
+	f.put("dir/b.txt", []byte("123"))
+	f.put("other/c.txt", []byte("1234567"))
+
+	usage, err := b.About(context.Background())
+	if err != nil {
+		t.Fatalf("About: %v", err)
+	}
+	want := omnistorage.Usage{Total: -1, Used: 15, Free: -1, Objects: 3}
+	if usage != want {
+		t.Errorf("About = %+v, want %+v", usage, want)
+	}
+
+	prefixed := f.newBackend(b, func(c *Config) { c.Prefix = "dir/" })
+	usage, err = prefixed.About(context.Background())
+	if err != nil {
+		t.Fatalf("About: %v", err)
+	}
+	if usage.Used != 3 || usage.Objects != 1 {
+		t.Errorf("About under dir/ = %+v, want 3 bytes in 1 object", usage)
+	}
+}
@@ -36,9 +36,11 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
+	tmtypes "github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
@@ -46,7 +48,10 @@ import (
 )
 
 func init() {
-	omnistorage.Register("s3", NewFromConfig)
+	omnistorage.Register("s3", NewFromConfig,
+		omnistorage.WithDescription("Amazon S3 and S3-compatible object storage"),
+		omnistorage.WithConfigKeys(configKeys...),
+		omnistorage.WithFeatures((&Backend{}).Features()))
 }
 
 // Errors specific to the S3 backend.
@@ -59,11 +64,16 @@ type Backend struct {
 	client         *s3.Client
 	transferClient *transfermanager.Client
 	config         Config
+	sseCustomer    customerKey
 	closed         bool
 	mu             sync.RWMutex
 }
 
 // New creates a new S3 backend with the given configuration.
+//
+// New may make network requests: GetBucketLocation when Region and
+// Endpoint are empty, bounded by OpTimeout or 10 seconds, and the bucket
+// checks of CreateBucket and VerifyBucketEncryption, bounded by OpTimeout.
 func New(cfg Config) (*Backend, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -76,6 +86,9 @@ func New(cfg Config) (*Backend, error) {
 	if cfg.Concurrency == 0 {
 		cfg.Concurrency = 5
 	}
+	if cfg.CopyCutoff <= 0 || cfg.CopyCutoff > MaxCopySize {
+		cfg.CopyCutoff = MaxCopySize
+	}
 
 	// Build AWS config options
 	var optFns []func(*config.LoadOptions) error
@@ -86,7 +99,9 @@ func New(cfg Config) (*Backend, error) {
 	}
 
 	// Credentials
-	if cfg.AccessKeyID != "" && cfg.SecretAccessKey != "" {
+	if cfg.Anonymous {
+		optFns = append(optFns, config.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	} else if cfg.AccessKeyID != "" && cfg.SecretAccessKey != "" {
 		creds := credentials.NewStaticCredentialsProvider(
 			cfg.AccessKeyID,
 			cfg.SecretAccessKey,
@@ -95,6 +110,16 @@ func New(cfg Config) (*Backend, error) {
 		optFns = append(optFns, config.WithCredentialsProvider(creds))
 	}
 
+	// Retries
+	if retryer := cfg.retryer(); retryer != nil {
+		optFns = append(optFns, config.WithRetryer(retryer))
+	}
+
+	// Request timeout
+	if cfg.Timeout > 0 {
+		optFns = append(optFns, config.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(cfg.Timeout)))
+	}
+
 	// Load AWS config
 	awsCfg, err := config.LoadDefaultConfig(context.Background(), optFns...)
 	if err != nil {
@@ -118,8 +143,23 @@ func New(cfg Config) (*Backend, error) {
 		})
 	}
 
-	// Create S3 client
+	// Create S3 client, in the bucket's region if none is configured.
+	// S3-compatible services may report locations that are not regions.
 	client := s3.NewFromConfig(awsCfg, s3OptFns...)
+	if cfg.Region == "" && cfg.Endpoint == "" {
+		timeout := cfg.OpTimeout
+		if timeout <= 0 {
+			timeout = regionTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		region, err := bucketRegion(ctx, client, cfg.Bucket)
+		cancel()
+		if err == nil {
+			cfg.Region = region
+			awsCfg.Region = region
+			client = s3.NewFromConfig(awsCfg, s3OptFns...)
+		}
+	}
 
 	// Create transfer manager client
 	transferClient := transfermanager.New(client, func(o *transfermanager.Options) {
@@ -127,11 +167,26 @@ func New(cfg Config) (*Backend, error) {
 		o.Concurrency = cfg.Concurrency
 	})
 
-	return &Backend{
+	b := &Backend{
 		client:         client,
 		transferClient: transferClient,
 		config:         cfg,
-	}, nil
+		sseCustomer:    newCustomerKey(cfg.SSECustomerKey),
+	}
+
+	if cfg.CreateBucket {
+		if err := b.EnsureBucket(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.VerifyBucketEncryption {
+		if err := b.VerifyBucketEncryption(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
 }
 
 // NewFromConfig creates a new S3 backend from a config map.
@@ -154,13 +209,27 @@ func (b *Backend) NewWriter(ctx context.Context, p string, opts ...omnistorage.W
 	key := b.fullKey(p)
 	cfg := omnistorage.ApplyWriterOptions(opts...)
 
+	sse, err := b.writeEncryption(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return &s3Writer{
 		backend:     b,
 		ctx:         ctx,
 		key:         key,
 		buffer:      &bytes.Buffer{},
 		contentType: cfg.ContentType,
+		headers:     newHTTPHeaders(cfg),
 		metadata:    cfg.Metadata,
+		sse:         sse,
+		class:       b.writeStorageClass(cfg),
+		tags:        encodeTags(b.writeTags(cfg)),
+		lock:        newLockParams(cfg),
+
+		contentMD5:    cfg.ContentMD5,
+		contentSHA256: cfg.ContentSHA256,
+		verify:        omnistorage.NewChecksumVerifier(cfg),
 	}, nil
 }
 
@@ -178,9 +247,14 @@ func (b *Backend) NewReader(ctx context.Context, p string, opts ...omnistorage.R
 	cfg := omnistorage.ApplyReaderOptions(opts...)
 
 	// Build GetObject input
+	sseC := b.readCustomerKey(cfg)
 	input := &s3.GetObjectInput{
-		Bucket: aws.String(b.config.Bucket),
-		Key:    aws.String(key),
+		Bucket:               aws.String(b.config.Bucket),
+		Key:                  aws.String(key),
+		SSECustomerAlgorithm: sseC.algorithm,
+		SSECustomerKey:       sseC.key,
+		SSECustomerKeyMD5:    sseC.keyMD5,
+		RequestPayer:         b.config.requestPayer(),
 	}
 
 	// Handle range requests
@@ -197,7 +271,7 @@ func (b *Backend) NewReader(ctx context.Context, p string, opts ...omnistorage.R
 	// Get object
 	result, err := b.client.GetObject(ctx, input)
 	if err != nil {
-		return nil, b.translateError(err, p)
+		return nil, b.translateError(err, "read", p)
 	}
 
 	return result.Body, nil
@@ -213,12 +287,12 @@ func (b *Backend) Exists(ctx context.Context, p string) (bool, error) {
 		return false, err
 	}
 
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
 	key := b.fullKey(p)
 
-	_, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(b.config.Bucket),
-		Key:    aws.String(key),
-	})
+	_, err := b.client.HeadObject(ctx, b.headInput(key))
 
 	if err != nil {
 		var nsk *types.NotFound
@@ -232,7 +306,11 @@ func (b *Backend) Exists(ctx context.Context, p string) (bool, error) {
 				return false, nil
 			}
 		}
-		return false, b.translateError(err, p)
+		err = b.translateError(err, "exists", p)
+		if omnistorage.KindOf(err) == omnistorage.KindPermissionDenied {
+			return b.existsFallback(ctx, key, p, err)
+		}
+		return false, err
 	}
 
 	return true, nil
@@ -248,11 +326,15 @@ func (b *Backend) Delete(ctx context.Context, p string) error {
 		return err
 	}
 
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
 	key := b.fullKey(p)
 
 	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(b.config.Bucket),
-		Key:    aws.String(key),
+		Bucket:       aws.String(b.config.Bucket),
+		Key:          aws.String(key),
+		RequestPayer: b.config.requestPayer(),
 	})
 
 	if err != nil {
@@ -261,13 +343,13 @@ func (b *Backend) Delete(ctx context.Context, p string) error {
 		if errors.As(err, &nsk) {
 			return nil
 		}
-		return b.translateError(err, p)
+		return b.translateError(err, "delete", p)
 	}
 
 	return nil
 }
 
-// List lists paths with the given prefix.
+// List lists the paths under the directory prefix, relative to it.
 func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	if err := b.checkClosed(); err != nil {
 		return nil, err
@@ -277,12 +359,16 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 		return nil, err
 	}
 
-	fullPrefix := b.fullKey(prefix)
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	fullPrefix := b.listPrefix(prefix)
 
 	var paths []string
 	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
-		Bucket: aws.String(b.config.Bucket),
-		Prefix: aws.String(fullPrefix),
+		Bucket:       aws.String(b.config.Bucket),
+		Prefix:       aws.String(fullPrefix),
+		RequestPayer: b.config.requestPayer(),
 	})
 
 	for paginator.HasMorePages() {
@@ -299,10 +385,10 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 			if obj.Key == nil {
 				continue
 			}
-			// Remove prefix to get relative path
-			relPath := strings.TrimPrefix(*obj.Key, b.config.Prefix)
-			relPath = strings.TrimPrefix(relPath, "/")
-			if relPath != "" {
+			// Remove prefix to get relative path, skipping the
+			// directory markers created by Mkdir
+			relPath := strings.TrimPrefix(*obj.Key, fullPrefix)
+			if relPath != "" && !strings.HasSuffix(relPath, "/") {
 				paths = append(paths, relPath)
 			}
 		}
@@ -330,15 +416,15 @@ func (b *Backend) Stat(ctx context.Context, p string) (omnistorage.ObjectInfo, e
 		return nil, err
 	}
 
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
 	key := b.fullKey(p)
 
-	result, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(b.config.Bucket),
-		Key:    aws.String(key),
-	})
+	result, err := b.client.HeadObject(ctx, b.headInput(key))
 
 	if err != nil {
-		return nil, b.translateError(err, p)
+		return nil, b.translateError(err, "stat", p)
 	}
 
 	// Get size
@@ -361,21 +447,52 @@ func (b *Backend) Stat(ctx context.Context, p string) (omnistorage.ObjectInfo, e
 
 	// Get ETag as MD5 hash (for non-multipart uploads)
 	hashes := make(map[omnistorage.HashType]string)
-	if result.ETag != nil {
-		etag := strings.Trim(*result.ETag, "\"")
-		// ETag is MD5 for non-multipart uploads (no hyphen)
-		if !strings.Contains(etag, "-") {
+	etag := strings.Trim(aws.ToString(result.ETag), "\"")
+	if etag != "" {
+		// ETag is MD5 for non-multipart uploads (no hyphen), unless the
+		// object is encrypted with SSE-KMS or SSE-C
+		encrypted := result.ServerSideEncryption == types.ServerSideEncryptionAwsKms ||
+			result.ServerSideEncryption == types.ServerSideEncryptionAwsKmsDsse ||
+			result.SSECustomerAlgorithm != nil
+		if !strings.Contains(etag, "-") && !encrypted {
 			hashes[omnistorage.HashMD5] = etag
 		}
 	}
 
-	return &omnistorage.BasicObjectInfo{
-		ObjectPath:        p,
-		ObjectSize:        size,
-		ObjectModTime:     modTime,
-		ObjectIsDir:       false, // S3 doesn't have real directories
-		ObjectContentType: contentType,
-		ObjectHashes:      hashes,
+	// Storage class is omitted from the response for STANDARD
+	storageClass := string(result.StorageClass)
+	if storageClass == "" {
+		storageClass = StorageClassStandard
+	}
+
+	// Tags require a separate request, so only fetch them if present
+	var tags map[string]string
+	if aws.ToInt32(result.TagCount) > 0 {
+		tags, err = b.Tags(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ObjectInfo{
+		BasicObjectInfo: omnistorage.BasicObjectInfo{
+			ObjectPath:        p,
+			ObjectSize:        size,
+			ObjectModTime:     modTime,
+			ObjectIsDir:       false, // S3 doesn't have real directories
+			ObjectContentType: contentType,
+			ObjectHashes:      hashes,
+			ObjectMetadata:    result.Metadata,
+
+			ObjectETag:               etag,
+			ObjectStorageClass:       storageClass,
+			ObjectVersionID:          aws.ToString(result.VersionId),
+			ObjectCacheControl:       aws.ToString(result.CacheControl),
+			ObjectContentEncoding:    aws.ToString(result.ContentEncoding),
+			ObjectContentDisposition: aws.ToString(result.ContentDisposition),
+		},
+		Tags: tags,
+		Lock: objectLock(result.ObjectLockMode, result.ObjectLockRetainUntilDate, result.ObjectLockLegalHoldStatus),
 	}, nil
 }
 
@@ -389,6 +506,9 @@ func (b *Backend) Mkdir(ctx context.Context, p string) error {
 		return err
 	}
 
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
 	// S3 doesn't need directories - they're implicit from object keys
 	// Some tools create zero-byte objects with trailing slash to represent directories
 	// We'll do that for compatibility
@@ -397,11 +517,23 @@ func (b *Backend) Mkdir(ctx context.Context, p string) error {
 		key += "/"
 	}
 
-	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:        aws.String(b.config.Bucket),
-		Key:           aws.String(key),
-		Body:          bytes.NewReader([]byte{}),
-		ContentLength: aws.Int64(0),
+	sse, err := b.config.encryption().params()
+	if err != nil {
+		return err
+	}
+
+	_, err = b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(b.config.Bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader([]byte{}),
+		ContentLength:        aws.Int64(0),
+		ServerSideEncryption: sse.mode,
+		SSEKMSKeyId:          sse.kmsKeyID,
+		BucketKeyEnabled:     sse.bucketKeyEnabled,
+		SSECustomerAlgorithm: sse.customer.algorithm,
+		SSECustomerKey:       sse.customer.key,
+		SSECustomerKeyMD5:    sse.customer.keyMD5,
+		RequestPayer:         b.config.requestPayer(),
 	})
 
 	if err != nil {
@@ -421,6 +553,9 @@ func (b *Backend) Rmdir(ctx context.Context, p string) error {
 		return err
 	}
 
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
 	key := b.fullKey(p)
 	if !strings.HasSuffix(key, "/") {
 		key += "/"
@@ -428,9 +563,10 @@ func (b *Backend) Rmdir(ctx context.Context, p string) error {
 
 	// Check if directory is empty
 	result, err := b.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket:  aws.String(b.config.Bucket),
-		Prefix:  aws.String(key),
-		MaxKeys: aws.Int32(2), // Just need to know if there's more than the marker
+		Bucket:       aws.String(b.config.Bucket),
+		Prefix:       aws.String(key),
+		MaxKeys:      aws.Int32(2), // Just need to know if there's more than the marker
+		RequestPayer: b.config.requestPayer(),
 	})
 	if err != nil {
 		return fmt.Errorf("s3: checking directory: %w", err)
@@ -450,12 +586,13 @@ func (b *Backend) Rmdir(ctx context.Context, p string) error {
 
 	// Delete the directory marker
 	_, err = b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(b.config.Bucket),
-		Key:    aws.String(key),
+		Bucket:       aws.String(b.config.Bucket),
+		Key:          aws.String(key),
+		RequestPayer: b.config.requestPayer(),
 	})
 
 	if err != nil {
-		return b.translateError(err, p)
+		return b.translateError(err, "rmdir", p)
 	}
 
 	return nil
@@ -471,20 +608,52 @@ func (b *Backend) Copy(ctx context.Context, src, dst string) error {
 		return err
 	}
 
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
 	srcKey := b.fullKey(src)
 	dstKey := b.fullKey(dst)
 
 	// S3 CopyObject requires the source as bucket/key
 	copySource := fmt.Sprintf("%s/%s", b.config.Bucket, srcKey)
 
-	_, err := b.client.CopyObject(ctx, &s3.CopyObjectInput{
-		Bucket:     aws.String(b.config.Bucket),
-		CopySource: aws.String(copySource),
-		Key:        aws.String(dstKey),
+	// CopyObject is limited to MaxCopySize; copy larger objects in parts
+	head, err := b.client.HeadObject(ctx, b.headInput(srcKey))
+	if err != nil {
+		return b.translateError(err, "copy", src)
+	}
+	if aws.ToInt64(head.ContentLength) > b.config.CopyCutoff {
+		return b.multipartCopy(ctx, src, dstKey, copySource, head, types.StorageClass(b.config.StorageClass))
+	}
+
+	// CopyObject does not carry the source's encryption or storage class
+	// over; the destination uses the configured values, or the bucket
+	// defaults. Tags are copied.
+	sse, err := b.config.encryption().params()
+	if err != nil {
+		return err
+	}
+
+	_, err = b.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:                         aws.String(b.config.Bucket),
+		CopySource:                     aws.String(copySource),
+		Key:                            aws.String(dstKey),
+		StorageClass:                   types.StorageClass(b.config.StorageClass),
+		ServerSideEncryption:           sse.mode,
+		SSEKMSKeyId:                    sse.kmsKeyID,
+		SSEKMSEncryptionContext:        sse.kmsContext,
+		BucketKeyEnabled:               sse.bucketKeyEnabled,
+		SSECustomerAlgorithm:           sse.customer.algorithm,
+		SSECustomerKey:                 sse.customer.key,
+		SSECustomerKeyMD5:              sse.customer.keyMD5,
+		CopySourceSSECustomerAlgorithm: b.sseCustomer.algorithm,
+		CopySourceSSECustomerKey:       b.sseCustomer.key,
+		CopySourceSSECustomerKeyMD5:    b.sseCustomer.keyMD5,
+		RequestPayer:                   b.config.requestPayer(),
 	})
 
 	if err != nil {
-		return b.translateError(err, src)
+		return b.translateError(err, "copy", src)
 	}
 
 	return nil
@@ -523,6 +692,7 @@ func (b *Backend) Features() omnistorage.Features {
 		Versioning:           true, // Depends on bucket config
 		RangeRead:            true,
 		ListPrefix:           true,
+		ObjectLock:           b.config.ObjectLock,
 	}
 }
 
@@ -534,6 +704,37 @@ func (b *Backend) fullKey(p string) string {
 	return path.Join(b.config.Prefix, p)
 }
 
+// listPrefix returns the key prefix List matches for the directory
+// prefix. Unlike fullKey, it ends with a slash, as it ends the configured
+// Prefix with one, so the Prefix "data" does not match the key "data2/x".
+func (b *Backend) listPrefix(prefix string) string {
+	if b.config.Prefix == "" {
+		return omnistorage.CleanPrefix(prefix)
+	}
+	return strings.TrimSuffix(b.config.Prefix, "/") + "/" + omnistorage.CleanPrefix(prefix)
+}
+
+// headInput builds a HeadObject request, with the SSE-C key if configured.
+func (b *Backend) headInput(key string) *s3.HeadObjectInput {
+	return &s3.HeadObjectInput{
+		Bucket:               aws.String(b.config.Bucket),
+		Key:                  aws.String(key),
+		SSECustomerAlgorithm: b.sseCustomer.algorithm,
+		SSECustomerKey:       b.sseCustomer.key,
+		SSECustomerKeyMD5:    b.sseCustomer.keyMD5,
+		RequestPayer:         b.config.requestPayer(),
+	}
+}
+
+// opContext bounds an operation other than a read or write by
+// Config.OpTimeout.
+func (b *Backend) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.config.OpTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, b.config.OpTimeout)
+}
+
 // checkClosed returns an error if the backend is closed.
 func (b *Backend) checkClosed() error {
 	b.mu.RLock()
@@ -545,18 +746,14 @@ func (b *Backend) checkClosed() error {
 	return nil
 }
 
-// translateError converts S3 errors to omnistorage errors.
-func (b *Backend) translateError(err error, path string) error {
+// translateError converts an S3 error from the operation op on path to an
+// *omnistorage.Error, so callers can match it against the omnistorage
+// sentinels and still see which key failed.
+func (b *Backend) translateError(err error, op, path string) error {
 	if err == nil {
 		return nil
 	}
 
-	// Check for NotFound
-	var nsk *types.NotFound
-	if errors.As(err, &nsk) {
-		return omnistorage.ErrNotFound
-	}
-
 	var nsb *types.NoSuchBucket
 	if errors.As(err, &nsb) {
 		return fmt.Errorf("s3: bucket not found: %s", b.config.Bucket)
@@ -567,20 +764,30 @@ func (b *Backend) translateError(err error, path string) error {
 		return fmt.Errorf("s3: upload not found: %s", path)
 	}
 
+	kind := omnistorage.KindOf(err)
+	var nsk *types.NotFound
+	if errors.As(err, &nsk) {
+		kind = omnistorage.KindNotFound
+	}
+
 	// Check error code
 	var apiErr interface{ ErrorCode() string }
 	if errors.As(err, &apiErr) {
 		switch apiErr.ErrorCode() {
 		case "NotFound", "NoSuchKey":
-			return omnistorage.ErrNotFound
-		case "AccessDenied":
-			return omnistorage.ErrPermissionDenied
-		case "InvalidAccessKeyId", "SignatureDoesNotMatch":
-			return omnistorage.ErrPermissionDenied
+			kind = omnistorage.KindNotFound
+		case "AccessDenied", "Forbidden", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+			kind = omnistorage.KindPermissionDenied
+		case "RequestTimeout":
+			kind = omnistorage.KindTimeout
+		case "QuotaExceeded", "ServiceQuotaExceededException":
+			kind = omnistorage.KindQuotaExceeded
+		case "InvalidObjectState":
+			err = fmt.Errorf("%w: %w", ErrObjectArchived, err)
 		}
 	}
 
-	return fmt.Errorf("s3: %w", err)
+	return &omnistorage.Error{Op: op, Backend: "s3", Path: path, Kind: kind, Err: err}
 }
 
 // s3Writer implements io.WriteCloser for S3.
@@ -590,9 +797,18 @@ type s3Writer struct {
 	key         string
 	buffer      *bytes.Buffer
 	contentType string
+	headers     httpHeaders
 	metadata    map[string]string
+	sse         sseParams
+	class       string
+	tags        *string
+	lock        lockParams
 	closed      bool
 	mu          sync.Mutex
+
+	contentMD5    string
+	contentSHA256 string
+	verify        *omnistorage.ChecksumVerifier
 }
 
 func (w *s3Writer) Write(p []byte) (n int, err error) {
@@ -615,21 +831,58 @@ func (w *s3Writer) Close() error {
 	}
 	w.closed = true
 
-	// Build UploadObject input
+	// Data that does not match its checksums is never uploaded. Objects
+	// small enough for a single request are verified by S3 as well.
+	if w.verify != nil {
+		body := w.buffer.Bytes()
+		_, _ = w.verify.Write(body)
+		if err := w.verify.Verify(); err != nil {
+			return fmt.Errorf("s3: %w", err)
+		}
+		if int64(len(body)) < w.backend.config.PartSize {
+			return w.putVerified(body)
+		}
+	}
+
+	return w.upload(bytes.NewReader(w.buffer.Bytes()))
+}
+
+// upload uploads body with the transfer manager, in parts if it is large.
+func (w *s3Writer) upload(body io.Reader) error {
 	input := &transfermanager.UploadObjectInput{
 		Bucket: aws.String(w.backend.config.Bucket),
 		Key:    aws.String(w.key),
-		Body:   bytes.NewReader(w.buffer.Bytes()),
+		Body:   body,
 	}
 
 	if w.contentType != "" {
 		input.ContentType = aws.String(w.contentType)
 	}
+	input.CacheControl = w.headers.cacheControl
+	input.ContentEncoding = w.headers.contentEncoding
+	input.ContentDisposition = w.headers.contentDisposition
 
 	if len(w.metadata) > 0 {
 		input.Metadata = w.metadata
 	}
 
+	input.StorageClass = tmtypes.StorageClass(w.class)
+	input.Tagging = w.tags
+
+	input.ObjectLockMode = tmtypes.ObjectLockMode(w.lock.mode)
+	input.ObjectLockRetainUntilDate = w.lock.retainUntil
+	input.ObjectLockLegalHoldStatus = tmtypes.ObjectLockLegalHoldStatus(w.lock.legalHold)
+
+	input.ServerSideEncryption = tmtypes.ServerSideEncryption(w.sse.mode)
+	input.SSEKMSKeyID = w.sse.kmsKeyID
+	input.SSEKMSEncryptionContext = w.sse.kmsContext
+	input.BucketKeyEnabled = w.sse.bucketKeyEnabled
+	input.SSECustomerAlgorithm = w.sse.customer.algorithm
+	input.SSECustomerKey = w.sse.customer.key
+	input.SSECustomerKeyMD5 = w.sse.customer.keyMD5
+
+	input.RequestPayer = tmtypes.RequestPayer(w.backend.config.requestPayer())
+
 	// Use transfer manager for potentially large files
 	_, err := w.backend.transferClient.UploadObject(w.ctx, input)
 	if err != nil {
@@ -639,5 +892,27 @@ func (w *s3Writer) Close() error {
 	return nil
 }
 
+// httpHeaders are the standard HTTP headers set on an upload, or nil if
+// not set.
+type httpHeaders struct {
+	cacheControl       *string
+	contentEncoding    *string
+	contentDisposition *string
+}
+
+func newHTTPHeaders(cfg *omnistorage.WriterConfig) httpHeaders {
+	optional := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return aws.String(v)
+	}
+	return httpHeaders{
+		cacheControl:       optional(cfg.CacheControl),
+		contentEncoding:    optional(cfg.ContentEncoding),
+		contentDisposition: optional(cfg.ContentDisposition),
+	}
+}
+
 // Ensure Backend implements omnistorage.ExtendedBackend
 var _ omnistorage.ExtendedBackend = (*Backend)(nil)
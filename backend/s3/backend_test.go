@@ -2,6 +2,7 @@ package s3
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"testing"
@@ -550,7 +551,7 @@ func TestTranslateError(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := backend.translateError(tt.err, "test-path")
+			got := backend.translateError(tt.err, "stat", "test-path")
 			if got != tt.wantErr {
 				t.Errorf("translateError() = %v, want %v", got, tt.wantErr)
 			}
@@ -558,6 +559,25 @@ func TestTranslateError(t *testing.T) {
 	}
 }
 
+func TestErrorPath(t *testing.T) {
+	_, b := newFakeS3(t)
+	ctx := context.Background()
+
+	_, err := b.Stat(ctx, "dir/missing.txt")
+	var e *omnistorage.Error
+	if !errors.As(err, &e) || !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Fatalf("Stat error = %v, want *omnistorage.Error matching ErrNotFound", err)
+	}
+	if e.Op != "stat" || e.Backend != "s3" || e.Path != "dir/missing.txt" || e.Kind != omnistorage.KindNotFound {
+		t.Errorf("Error = %+v", e)
+	}
+
+	_, err = b.NewReader(ctx, "other.txt")
+	if !errors.As(err, &e) || e.Path != "other.txt" || !omnistorage.IsNotFound(err) {
+		t.Errorf("NewReader error = %v, want not found error for other.txt", err)
+	}
+}
+
 func TestConfigFromMapEdgeCases(t *testing.T) {
 	// Test with empty map
 	cfg := ConfigFromMap(map[string]string{})
@@ -0,0 +1,146 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Object ownership settings for buckets created with Config.CreateBucket.
+const (
+	// ObjectOwnershipBucketOwnerEnforced disables ACLs, so the bucket owner
+	// owns every object. This is the S3 default for new buckets.
+	ObjectOwnershipBucketOwnerEnforced = string(types.ObjectOwnershipBucketOwnerEnforced)
+
+	// ObjectOwnershipBucketOwnerPreferred makes the bucket owner own
+	// objects uploaded with the bucket-owner-full-control ACL.
+	ObjectOwnershipBucketOwnerPreferred = string(types.ObjectOwnershipBucketOwnerPreferred)
+
+	// ObjectOwnershipObjectWriter makes the uploading account own each
+	// object.
+	ObjectOwnershipObjectWriter = string(types.ObjectOwnershipObjectWriter)
+)
+
+// ErrInvalidBucketSettings is returned for an unknown BucketACL or
+// ObjectOwnership.
+var ErrInvalidBucketSettings = errors.New("s3: invalid bucket settings")
+
+// defaultRegion is the region of buckets without a location constraint.
+const defaultRegion = "us-east-1"
+
+// regionTimeout bounds the detection of the bucket's region by New unless
+// Config.OpTimeout is set.
+const regionTimeout = 10 * time.Second
+
+// validateBucket checks the settings of a bucket created by New.
+func (c Config) validateBucket() error {
+	if c.BucketACL != "" && !slices.Contains(types.BucketCannedACL("").Values(), types.BucketCannedACL(c.BucketACL)) {
+		return fmt.Errorf("%w: unknown ACL %q", ErrInvalidBucketSettings, c.BucketACL)
+	}
+	if c.ObjectOwnership != "" && !slices.Contains(types.ObjectOwnership("").Values(), types.ObjectOwnership(c.ObjectOwnership)) {
+		return fmt.Errorf("%w: unknown object ownership %q", ErrInvalidBucketSettings, c.ObjectOwnership)
+	}
+	return nil
+}
+
+// BucketRegion returns the region of the bucket, with GetBucketLocation.
+func (b *Backend) BucketRegion(ctx context.Context) (string, error) {
+	if err := b.checkClosed(); err != nil {
+		return "", err
+	}
+	region, err := bucketRegion(ctx, b.client, b.config.Bucket)
+	if err != nil {
+		return "", b.translateError(err, "bucket region", "")
+	}
+	return region, nil
+}
+
+// withDefaultRegion sends a request from a client without a region to
+// defaultRegion.
+func withDefaultRegion(o *s3.Options) {
+	if o.Region == "" {
+		o.Region = defaultRegion
+	}
+}
+
+// bucketRegion asks for the location of bucket. GetBucketLocation is
+// answered in any region.
+func bucketRegion(ctx context.Context, client *s3.Client, bucket string) (string, error) {
+	result, err := client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucket),
+	}, withDefaultRegion)
+	if err != nil {
+		return "", err
+	}
+	return locationRegion(result.LocationConstraint), nil
+}
+
+// locationRegion returns the region of a bucket location constraint,
+// which is empty for us-east-1 and "EU" for older eu-west-1 buckets.
+func locationRegion(c types.BucketLocationConstraint) string {
+	switch c {
+	case "":
+		return defaultRegion
+	case types.BucketLocationConstraintEu:
+		return "eu-west-1"
+	default:
+		return string(c)
+	}
+}
+
+// EnsureBucket creates the bucket if it does not exist, in the configured
+// region or us-east-1, with the configured BucketACL and ObjectOwnership,
+// and with Object Lock enabled if ObjectLock is set. A bucket that exists
+// but may not be accessed, such as one owned by another account, is left
+// alone; the error surfaces on first use.
+func (b *Backend) EnsureBucket(ctx context.Context) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	_, err := b.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(b.config.Bucket),
+	}, withDefaultRegion)
+	if err == nil {
+		return nil
+	}
+	if err := b.translateError(err, "create bucket", ""); !errors.Is(err, omnistorage.ErrNotFound) {
+		if errors.Is(err, omnistorage.ErrPermissionDenied) {
+			return nil
+		}
+		return err
+	}
+
+	input := &s3.CreateBucketInput{
+		Bucket:          aws.String(b.config.Bucket),
+		ACL:             types.BucketCannedACL(b.config.BucketACL),
+		ObjectOwnership: types.ObjectOwnership(b.config.ObjectOwnership),
+	}
+	if region := b.client.Options().Region; region != "" && region != defaultRegion {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	if b.config.ObjectLock {
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+
+	_, err = b.client.CreateBucket(ctx, input, withDefaultRegion)
+	var owned *types.BucketAlreadyOwnedByYou
+	if errors.As(err, &owned) {
+		// Created meanwhile by another client of ours
+		return nil
+	}
+	return b.translateError(err, "create bucket", "")
+}
@@ -0,0 +1,132 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestBucketRegion(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+
+	for _, tt := range []struct{ location, want string }{
+		{"", "us-east-1"},
+		{"EU", "eu-west-1"},
+		{"ap-southeast-2", "ap-southeast-2"},
+	} {
+		f.location = tt.location
+		got, err := b.BucketRegion(ctx)
+		if err != nil {
+			t.Fatalf("BucketRegion: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("BucketRegion with location %q = %q, want %q", tt.location, got, tt.want)
+		}
+	}
+	if got := locationRegion(types.BucketLocationConstraint("eu-central-1")); got != "eu-central-1" {
+		t.Errorf("locationRegion = %q", got)
+	}
+}
+
+func TestBucketRegionTimeout(t *testing.T) {
+	// A GetBucketLocation that never answers gives up after OpTimeout,
+	// leaving the environment's region
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ENDPOINT_URL_S3", server.URL)
+	t.Setenv("AWS_REGION", "eu-west-2")
+
+	start := time.Now()
+	b, err := New(Config{
+		Bucket:          "test-bucket",
+		UsePathStyle:    true,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		OpTimeout:       100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("New took %v, want it bounded by OpTimeout", elapsed)
+	}
+	if got := b.client.Options().Region; got != "eu-west-2" {
+		t.Errorf("region = %q, want eu-west-2 from the environment", got)
+	}
+}
+
+func TestCreateBucket(t *testing.T) {
+	f, b := newFakeS3(t)
+	f.missing = true
+
+	created := f.newBackend(b, func(c *Config) {
+		c.Region = "eu-central-1"
+		c.CreateBucket = true
+		c.BucketACL = "private"
+		c.ObjectOwnership = ObjectOwnershipBucketOwnerPreferred
+		c.ObjectLock = true
+	})
+	defer created.Close()
+
+	r := f.lastRequest(http.MethodPut, "")
+	if r.URL.Path != "/test-bucket" {
+		t.Fatalf("CreateBucket requested %s, want /test-bucket", r.URL.Path)
+	}
+	if got := r.Header.Get("X-Amz-Acl"); got != "private" {
+		t.Errorf("x-amz-acl = %q, want private", got)
+	}
+	if got := r.Header.Get("X-Amz-Object-Ownership"); got != ObjectOwnershipBucketOwnerPreferred {
+		t.Errorf("x-amz-object-ownership = %q", got)
+	}
+	if got := r.Header.Get("X-Amz-Bucket-Object-Lock-Enabled"); got != "true" {
+		t.Errorf("x-amz-bucket-object-lock-enabled = %q, want true", got)
+	}
+	body, _ := io.ReadAll(r.Body)
+	if !strings.Contains(string(body), "<LocationConstraint>eu-central-1</LocationConstraint>") {
+		t.Errorf("CreateBucket body = %s, want location eu-central-1", body)
+	}
+
+	// An existing bucket is not created again
+	puts := f.count(http.MethodPut)
+	f.newBackend(b, func(c *Config) { c.CreateBucket = true })
+	if n := f.count(http.MethodPut); n != puts {
+		t.Errorf("New created an existing bucket")
+	}
+
+	// In us-east-1 no location constraint is sent
+	f.missing = true
+	f.newBackend(b, func(c *Config) { c.CreateBucket = true })
+	body, _ = io.ReadAll(f.lastRequest(http.MethodPut, "").Body)
+	if strings.Contains(string(body), "LocationConstraint") {
+		t.Errorf("CreateBucket in us-east-1 body = %s, want no location constraint", body)
+	}
+}
+
+func TestBucketSettingsValidate(t *testing.T) {
+	if _, err := New(Config{Bucket: "b", BucketACL: "everyone"}); !errors.Is(err, ErrInvalidBucketSettings) {
+		t.Errorf("New with bad BucketACL: err = %v, want ErrInvalidBucketSettings", err)
+	}
+	if _, err := New(Config{Bucket: "b", ObjectOwnership: "Nobody"}); !errors.Is(err, ErrInvalidBucketSettings) {
+		t.Errorf("New with bad ObjectOwnership: err = %v, want ErrInvalidBucketSettings", err)
+	}
+
+	cfg := ConfigFromMap(map[string]string{
+		"bucket":           "b",
+		"create_bucket":    "true",
+		"bucket_acl":       "private",
+		"object_ownership": ObjectOwnershipObjectWriter,
+	})
+	if !cfg.CreateBucket || cfg.BucketACL != "private" || cfg.ObjectOwnership != ObjectOwnershipObjectWriter {
+		t.Errorf("ConfigFromMap = %+v", cfg)
+	}
+}
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// base64Sum converts a hex-encoded hash to the base64 form S3 headers use,
+// or returns nil if sum is empty or not hex.
+func base64Sum(sum string) *string {
+	raw, err := hex.DecodeString(sum)
+	if sum == "" || err != nil {
+		return nil
+	}
+	return aws.String(base64.StdEncoding.EncodeToString(raw))
+}
+
+// putVerified uploads body in a single PutObject request with the
+// Content-MD5 and x-amz-checksum-sha256 headers, so that S3 rejects data
+// that was corrupted on the way. The transfer manager cannot send
+// Content-MD5, and multipart uploads only carry per-part checksums, so
+// larger objects are verified locally only.
+func (w *s3Writer) putVerified(body []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket:                    aws.String(w.backend.config.Bucket),
+		Key:                       aws.String(w.key),
+		Body:                      bytes.NewReader(body),
+		ContentLength:             aws.Int64(int64(len(body))),
+		ContentMD5:                base64Sum(w.contentMD5),
+		ChecksumSHA256:            base64Sum(w.contentSHA256),
+		CacheControl:              w.headers.cacheControl,
+		ContentEncoding:           w.headers.contentEncoding,
+		ContentDisposition:        w.headers.contentDisposition,
+		StorageClass:              types.StorageClass(w.class),
+		Tagging:                   w.tags,
+		ObjectLockMode:            w.lock.mode,
+		ObjectLockRetainUntilDate: w.lock.retainUntil,
+		ObjectLockLegalHoldStatus: w.lock.legalHold,
+		ServerSideEncryption:      w.sse.mode,
+		SSEKMSKeyId:               w.sse.kmsKeyID,
+		SSEKMSEncryptionContext:   w.sse.kmsContext,
+		BucketKeyEnabled:          w.sse.bucketKeyEnabled,
+		SSECustomerAlgorithm:      w.sse.customer.algorithm,
+		SSECustomerKey:            w.sse.customer.key,
+		SSECustomerKeyMD5:         w.sse.customer.keyMD5,
+		RequestPayer:              w.backend.config.requestPayer(),
+	}
+	if input.ChecksumSHA256 != nil {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	}
+	if w.contentType != "" {
+		input.ContentType = aws.String(w.contentType)
+	}
+	if len(w.metadata) > 0 {
+		input.Metadata = w.metadata
+	}
+
+	if _, err := w.backend.client.PutObject(w.ctx, input); err != nil {
+		return fmt.Errorf("s3: uploading object: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestWriterChecksum(t *testing.T) {
+	f, b := newFakeS3(t)
+	data := []byte("checked")
+	md5sum := omnistorage.HashBytes(data, omnistorage.HashMD5)
+	sha := omnistorage.HashBytes(data, omnistorage.HashSHA256)
+
+	writeObject(t, b, "good.txt", string(data),
+		omnistorage.WithContentMD5(md5sum), omnistorage.WithContentSHA256(sha))
+	r := f.lastRequest(http.MethodPut, "")
+	if got := r.Header.Get("Content-Md5"); got != *base64Sum(md5sum) {
+		t.Errorf("Content-MD5 = %q, want %q", got, *base64Sum(md5sum))
+	}
+	if got := r.Header.Get("X-Amz-Checksum-Sha256"); got != *base64Sum(sha) {
+		t.Errorf("x-amz-checksum-sha256 = %q, want %q", got, *base64Sum(sha))
+	}
+	if got := readObject(t, b, "good.txt"); got != string(data) {
+		t.Errorf("good.txt = %q", got)
+	}
+
+	// Data that does not match is never sent
+	puts := f.count(http.MethodPut)
+	w, err := b.NewWriter(context.Background(), "bad.txt", omnistorage.WithContentMD5(md5sum))
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	_, _ = io.WriteString(w, "tampered")
+	if err := w.Close(); !errors.Is(err, omnistorage.ErrChecksumMismatch) {
+		t.Errorf("Close with wrong checksum = %v, want ErrChecksumMismatch", err)
+	}
+	if n := f.count(http.MethodPut); n != puts {
+		t.Errorf("%d PUT requests sent for mismatched data", n-puts)
+	}
+
+	// Multipart uploads are verified locally only
+	big := strings.Repeat("x", int(b.config.PartSize)+1)
+	writeObject(t, b, "big.txt", big,
+		omnistorage.WithContentMD5(omnistorage.HashBytes([]byte(big), omnistorage.HashMD5)))
+	if got := readObject(t, b, "big.txt"); len(got) != len(big) {
+		t.Errorf("big.txt has %d bytes, want %d", len(got), len(big))
+	}
+}
+
+// The ETag of an SSE-KMS object is not its MD5, so Stat does not report it
+func TestStatEncryptedETag(t *testing.T) {
+	f, b := newFakeS3(t)
+	kms := f.newBackend(b, func(c *Config) {
+		c.ServerSideEncryption = SSEKMS
+	})
+	writeObject(t, b, "plain.txt", "hello")
+	writeObject(t, kms, "kms.txt", "hello")
+
+	if got := statS3(t, b, "plain.txt").Hash(omnistorage.HashMD5); got == "" {
+		t.Error("plain object has no MD5")
+	}
+	if got := statS3(t, b, "kms.txt").Hash(omnistorage.HashMD5); got != "" {
+		t.Errorf("SSE-KMS object MD5 = %q, want none", got)
+	}
+
+	// Listings do not say how objects are encrypted, so carry no MD5
+	entries, err := b.ListDir(context.Background(), "")
+	if err != nil {
+		t.Fatalf("ListDir: %v", err)
+	}
+	for _, e := range entries {
+		if got := e.Hash(omnistorage.HashMD5); got != "" {
+			t.Errorf("ListDir %s MD5 = %q, want none", e.Path(), got)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// SDK retry modes.
+const (
+	// RetryModeStandard retries with exponential backoff and a retry quota.
+	RetryModeStandard = string(aws.RetryModeStandard)
+
+	// RetryModeAdaptive adds client-side rate limiting to RetryModeStandard.
+	RetryModeAdaptive = string(aws.RetryModeAdaptive)
+)
+
+// ErrInvalidRetryMode is returned for an unknown RetryMode.
+var ErrInvalidRetryMode = errors.New("s3: invalid retry mode")
+
+// validateRetry checks the retry settings.
+func (c Config) validateRetry() error {
+	switch c.RetryMode {
+	case "", RetryModeStandard, RetryModeAdaptive:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidRetryMode, c.RetryMode)
+	}
+}
+
+// retryer returns a retryer factory for the configured retry settings, or
+// nil to keep the SDK defaults.
+func (c Config) retryer() func() aws.Retryer {
+	if c.RetryMode == "" && c.MaxAttempts <= 0 && c.MaxBackoff <= 0 {
+		return nil
+	}
+	return func() aws.Retryer {
+		var r aws.Retryer
+		if c.RetryMode == RetryModeAdaptive {
+			r = retry.NewAdaptiveMode()
+		} else {
+			r = retry.NewStandard()
+		}
+		if c.MaxAttempts > 0 {
+			r = retry.AddWithMaxAttempts(r, c.MaxAttempts)
+		}
+		if c.MaxBackoff > 0 {
+			r = retry.AddWithMaxBackoffDelay(r, c.MaxBackoff)
+		}
+		return r
+	}
+}
+
+// requestPayer returns the RequestPayer value sent with object requests.
+func (c Config) requestPayer() types.RequestPayer {
+	if c.RequesterPays {
+		return types.RequestPayerRequester
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestRequesterPays(t *testing.T) {
+	f, b := newFakeS3(t)
+	rp := f.newBackend(b, func(c *Config) { c.RequesterPays = true })
+	ctx := context.Background()
+
+	writeObject(t, rp, "a.txt", "data")
+	if _, err := rp.Stat(ctx, "a.txt"); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if _, err := rp.List(ctx, ""); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if err := rp.Copy(ctx, "a.txt", "b.txt"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if err := rp.Delete(ctx, "b.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	for _, r := range f.requests {
+		if got := r.Header.Get("X-Amz-Request-Payer"); got != "requester" {
+			t.Errorf("%s %s: request payer = %q, want requester", r.Method, r.URL, got)
+		}
+	}
+
+	writeObject(t, b, "c.txt", "data")
+	if got := f.lastRequest(http.MethodPut, "").Header.Get("X-Amz-Request-Payer"); got != "" {
+		t.Errorf("request payer sent without RequesterPays: %q", got)
+	}
+}
+
+func TestAnonymous(t *testing.T) {
+	f, b := newFakeS3(t)
+	f.put("public.txt", []byte("open data"))
+
+	anon := f.newBackend(b, func(c *Config) { c.Anonymous = true })
+	if exists, err := anon.Exists(context.Background(), "public.txt"); err != nil || !exists {
+		t.Fatalf("Exists = %v, %v", exists, err)
+	}
+	if got := f.lastRequest(http.MethodHead, "").Header.Get("Authorization"); got != "" {
+		t.Errorf("anonymous request was signed: %q", got)
+	}
+}
+
+func TestRetrySettings(t *testing.T) {
+	f, b := newFakeS3(t)
+	f.put("a.txt", []byte("data"))
+	ctx := context.Background()
+
+	retrying := f.newBackend(b, func(c *Config) {
+		c.MaxAttempts = 3
+		c.MaxBackoff = time.Millisecond
+	})
+	f.failures = 2
+	if _, err := retrying.Stat(ctx, "a.txt"); err != nil {
+		t.Fatalf("Stat after transient failures: %v", err)
+	}
+	if n := f.count(http.MethodHead); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+
+	single := f.newBackend(b, func(c *Config) { c.MaxAttempts = 1 })
+	f.failures = 1
+	if _, err := single.Stat(ctx, "a.txt"); err == nil {
+		t.Error("Stat with MaxAttempts 1: expected error")
+	}
+	if n := f.count(http.MethodHead); n != 4 {
+		t.Errorf("attempts = %d, want 4", n)
+	}
+
+	if _, err := New(Config{Bucket: "b", RetryMode: "sometimes"}); !errors.Is(err, ErrInvalidRetryMode) {
+		t.Errorf("New with bad RetryMode: err = %v, want ErrInvalidRetryMode", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	f, b := newFakeS3(t)
+	f.put("a.txt", []byte("data"))
+	f.delay = 200 * time.Millisecond
+
+	slow := f.newBackend(b, func(c *Config) {
+		c.Timeout = 20 * time.Millisecond
+		c.MaxAttempts = 1
+	})
+	if _, err := slow.Stat(context.Background(), "a.txt"); err == nil {
+		t.Error("Stat past timeout: expected error")
+	}
+}
+
+func TestOpTimeout(t *testing.T) {
+	f, b := newFakeS3(t)
+	f.put("a.txt", []byte("data"))
+	f.delay = 200 * time.Millisecond
+
+	slow := f.newBackend(b, func(c *Config) {
+		c.OpTimeout = 50 * time.Millisecond
+	})
+	start := time.Now()
+	_, err := slow.Stat(context.Background(), "a.txt")
+	if !errors.Is(err, omnistorage.ErrTimeout) {
+		t.Errorf("Stat past OpTimeout: err = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Stat took %v, want about 50ms", elapsed)
+	}
+	if cfg := ConfigFromMap(map[string]string{"op_timeout": "2m"}); cfg.OpTimeout != 2*time.Minute {
+		t.Errorf("op_timeout: OpTimeout = %v, want 2m", cfg.OpTimeout)
+	}
+}
+
+func TestConfigFromMapAccess(t *testing.T) {
+	cfg := ConfigFromMap(map[string]string{
+		"bucket":         "b",
+		"anonymous":      "true",
+		"requester_pays": "1",
+		"retry_mode":     "adaptive",
+		"max_attempts":   "5",
+		"max_backoff":    "2s",
+		"timeout":        "1m",
+	})
+	if !cfg.Anonymous || !cfg.RequesterPays {
+		t.Errorf("Anonymous = %v, RequesterPays = %v", cfg.Anonymous, cfg.RequesterPays)
+	}
+	if cfg.RetryMode != RetryModeAdaptive || cfg.MaxAttempts != 5 {
+		t.Errorf("RetryMode = %q, MaxAttempts = %d", cfg.RetryMode, cfg.MaxAttempts)
+	}
+	if cfg.MaxBackoff != 2*time.Second || cfg.Timeout != time.Minute {
+		t.Errorf("MaxBackoff = %v, Timeout = %v", cfg.MaxBackoff, cfg.Timeout)
+	}
+}
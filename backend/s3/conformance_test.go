@@ -0,0 +1,19 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	factory := func(t *testing.T) omnistorage.Backend {
+		_, b := newFakeS3(t)
+		return b
+	}
+	backendtest.RunConformanceTests(t, factory,
+		backendtest.WithSkip("object keys may begin with a slash", "PathNormalization"),
+		backendtest.WithSkip("directories are implicit prefixes", "Extended/Rmdir"),
+	)
+}
@@ -0,0 +1,173 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// MaxCopySize is the largest object CopyObject can copy in a single
+	// request. Larger objects are copied part by part.
+	MaxCopySize = 5 * 1024 * 1024 * 1024
+
+	// maxUploadParts is the maximum number of parts in a multipart upload.
+	maxUploadParts = 10000
+)
+
+// copyPartSize returns the part size for a multipart copy of size bytes,
+// growing PartSize if needed to stay within the part count limit.
+func (b *Backend) copyPartSize(size int64) int64 {
+	partSize := b.config.PartSize
+	if minSize := (size + maxUploadParts - 1) / maxUploadParts; partSize < minSize {
+		partSize = minSize
+	}
+	if partSize > MaxCopySize {
+		partSize = MaxCopySize
+	}
+	return partSize
+}
+
+// multipartCopy copies an object too large for CopyObject using
+// UploadPartCopy. Like CopyObject, it keeps the content headers, metadata
+// and tags in head, and applies the configured encryption and the storage
+// class class to the destination.
+func (b *Backend) multipartCopy(ctx context.Context, src, dstKey, copySource string, head *s3.HeadObjectOutput, class types.StorageClass) error {
+	sse, err := b.config.encryption().params()
+	if err != nil {
+		return err
+	}
+
+	var tagging *string
+	if aws.ToInt32(head.TagCount) > 0 {
+		tags, err := b.Tags(ctx, src)
+		if err != nil {
+			return err
+		}
+		tagging = encodeTags(tags)
+	}
+
+	created, err := b.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:                  aws.String(b.config.Bucket),
+		Key:                     aws.String(dstKey),
+		CacheControl:            head.CacheControl,
+		ContentDisposition:      head.ContentDisposition,
+		ContentEncoding:         head.ContentEncoding,
+		ContentLanguage:         head.ContentLanguage,
+		ContentType:             head.ContentType,
+		Metadata:                head.Metadata,
+		StorageClass:            class,
+		Tagging:                 tagging,
+		ServerSideEncryption:    sse.mode,
+		SSEKMSKeyId:             sse.kmsKeyID,
+		SSEKMSEncryptionContext: sse.kmsContext,
+		BucketKeyEnabled:        sse.bucketKeyEnabled,
+		SSECustomerAlgorithm:    sse.customer.algorithm,
+		SSECustomerKey:          sse.customer.key,
+		SSECustomerKeyMD5:       sse.customer.keyMD5,
+		RequestPayer:            b.config.requestPayer(),
+	})
+	if err != nil {
+		return b.translateError(err, "copy", src)
+	}
+	uploadID := created.UploadId
+
+	parts, err := b.copyParts(ctx, dstKey, copySource, uploadID, aws.ToInt64(head.ContentLength), sse.customer)
+	if err == nil {
+		_, err = b.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:               aws.String(b.config.Bucket),
+			Key:                  aws.String(dstKey),
+			UploadId:             uploadID,
+			MultipartUpload:      &types.CompletedMultipartUpload{Parts: parts},
+			SSECustomerAlgorithm: sse.customer.algorithm,
+			SSECustomerKey:       sse.customer.key,
+			SSECustomerKeyMD5:    sse.customer.keyMD5,
+			RequestPayer:         b.config.requestPayer(),
+		})
+	}
+	if err != nil {
+		// Abort with a fresh context so parts are not left behind when ctx
+		// was cancelled.
+		_, _ = b.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:       aws.String(b.config.Bucket),
+			Key:          aws.String(dstKey),
+			UploadId:     uploadID,
+			RequestPayer: b.config.requestPayer(),
+		})
+		return b.translateError(err, "multipart copy", src)
+	}
+	return nil
+}
+
+// copyParts copies size bytes in parts, up to Concurrency at a time.
+func (b *Backend) copyParts(ctx context.Context, dstKey, copySource string, uploadID *string, size int64, customer customerKey) ([]types.CompletedPart, error) {
+	partSize := b.copyPartSize(size)
+	n := int((size + partSize - 1) / partSize)
+	parts := make([]types.CompletedPart, n)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		sem      = make(chan struct{}, b.config.Concurrency)
+	)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		start := int64(i) * partSize
+		end := min(start+partSize, size) - 1
+		partNumber := aws.Int32(int32(i + 1)) //nolint:gosec // bounded by maxUploadParts
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := b.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+				Bucket:                         aws.String(b.config.Bucket),
+				Key:                            aws.String(dstKey),
+				UploadId:                       uploadID,
+				PartNumber:                     partNumber,
+				CopySource:                     aws.String(copySource),
+				CopySourceRange:                aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+				SSECustomerAlgorithm:           customer.algorithm,
+				SSECustomerKey:                 customer.key,
+				SSECustomerKeyMD5:              customer.keyMD5,
+				CopySourceSSECustomerAlgorithm: b.sseCustomer.algorithm,
+				CopySourceSSECustomerKey:       b.sseCustomer.key,
+				CopySourceSSECustomerKeyMD5:    b.sseCustomer.keyMD5,
+				RequestPayer:                   b.config.requestPayer(),
+			})
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			parts[i] = types.CompletedPart{ETag: result.CopyPartResult.ETag, PartNumber: partNumber}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
@@ -0,0 +1,106 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestMultipartCopy(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+
+	data := strings.Repeat("0123456789", 10)
+	writeObject(t, b, "big.bin", data,
+		omnistorage.WithContentType("application/octet-stream"),
+		omnistorage.WithMetadata(map[string]string{"owner": "ops"}),
+		WithObjectTags(map[string]string{"team": "data"}))
+
+	small := f.newBackend(b, func(c *Config) {
+		c.CopyCutoff = 50
+		c.PartSize = 30
+		c.Concurrency = 2
+	})
+	if err := small.Copy(ctx, "big.bin", "copy.bin"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	if got := readObject(t, b, "copy.bin"); got != data {
+		t.Errorf("copied data = %q, want %q", got, data)
+	}
+	info := statS3(t, b, "copy.bin")
+	if info.ContentType() != "application/octet-stream" {
+		t.Errorf("ContentType = %q", info.ContentType())
+	}
+	if info.Metadata()["owner"] != "ops" {
+		t.Errorf("Metadata = %v", info.Metadata())
+	}
+	if info.Tags["team"] != "data" {
+		t.Errorf("Tags = %v", info.Tags)
+	}
+
+	parts := 0
+	for _, r := range f.requests {
+		if r.Method == http.MethodPut && r.URL.Query().Has("partNumber") {
+			parts++
+		}
+	}
+	if parts != 4 {
+		t.Errorf("UploadPartCopy requests = %d, want 4", parts)
+	}
+	if len(f.uploads) != 0 {
+		t.Errorf("%d multipart uploads left open", len(f.uploads))
+	}
+
+	// Objects at or below the cutoff use a single CopyObject.
+	writeObject(t, b, "small.bin", "tiny")
+	if err := small.Copy(ctx, "small.bin", "small-copy.bin"); err != nil {
+		t.Fatalf("Copy small: %v", err)
+	}
+	if r := f.lastRequest(http.MethodPut, "X-Amz-Copy-Source"); r.URL.Query().Has("partNumber") {
+		t.Error("small object copied with UploadPartCopy")
+	}
+}
+
+func TestMultipartCopyAborts(t *testing.T) {
+	f, b := newFakeS3(t)
+	writeObject(t, b, "big.bin", strings.Repeat("x", 100))
+
+	small := f.newBackend(b, func(c *Config) {
+		c.CopyCutoff = 50
+		c.PartSize = 30
+		c.Concurrency = 1
+		c.MaxAttempts = 1
+	})
+
+	f.mu.Lock()
+	f.failParts = true
+	f.mu.Unlock()
+
+	if err := small.Copy(context.Background(), "big.bin", "copy.bin"); err == nil {
+		t.Fatal("Copy with failing part: expected error")
+	}
+	if r := f.lastRequest(http.MethodDelete, "uploadId"); r == nil {
+		t.Error("expected AbortMultipartUpload")
+	}
+	if len(f.uploads) != 0 {
+		t.Errorf("%d multipart uploads left open", len(f.uploads))
+	}
+	if exists, _ := b.Exists(context.Background(), "copy.bin"); exists {
+		t.Error("destination exists after failed copy")
+	}
+}
+
+func TestCopyPartSize(t *testing.T) {
+	b := &Backend{config: Config{PartSize: 5 * 1024 * 1024}}
+	if got := b.copyPartSize(100 * 1024 * 1024); got != 5*1024*1024 {
+		t.Errorf("copyPartSize(100MB) = %d", got)
+	}
+	const size = 5 * 1024 * 1024 * 1024 * 100
+	if got := b.copyPartSize(size); got*maxUploadParts < size {
+		t.Errorf("copyPartSize(500GB) = %d exceeds %d parts", got, maxUploadParts)
+	}
+}
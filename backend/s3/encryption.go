@@ -0,0 +1,251 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // SSE-C requires the MD5 digest of the key
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Server-side encryption modes.
+const (
+	// SSES3 encrypts with keys managed by S3.
+	SSES3 = string(types.ServerSideEncryptionAes256)
+
+	// SSEKMS encrypts with a key managed by AWS KMS.
+	SSEKMS = string(types.ServerSideEncryptionAwsKms)
+
+	// SSEKMSDSSE applies dual-layer encryption with a KMS key.
+	SSEKMSDSSE = string(types.ServerSideEncryptionAwsKmsDsse)
+)
+
+// sseCustomerAlgorithm is the only algorithm S3 supports for SSE-C.
+const sseCustomerAlgorithm = "AES256"
+
+// Encryption errors.
+var (
+	ErrInvalidEncryption  = errors.New("s3: invalid encryption settings")
+	ErrBucketNotEncrypted = errors.New("s3: bucket default encryption does not match")
+	ErrInvalidCustomerKey = errors.New("s3: SSE-C customer key must be 32 bytes")
+)
+
+// Encryption describes server-side encryption for an object.
+type Encryption struct {
+	// Mode is SSES3, SSEKMS or SSEKMSDSSE.
+	// Empty uses the bucket's default encryption.
+	Mode string
+
+	// KMSKeyID is the KMS key ID, alias or ARN for SSEKMS and SSEKMSDSSE.
+	// Empty uses the AWS managed key.
+	KMSKeyID string
+
+	// KMSContext is an optional KMS encryption context.
+	KMSContext map[string]string
+
+	// BucketKeyEnabled uses an S3 Bucket Key with SSEKMS to reduce KMS
+	// request costs.
+	BucketKeyEnabled bool
+
+	// CustomerKey is a 256-bit key for SSE-C. S3 does not store the key;
+	// the same key must be supplied to read the object.
+	// Cannot be combined with Mode.
+	CustomerKey []byte
+}
+
+// IsZero reports whether no encryption is requested.
+func (e Encryption) IsZero() bool {
+	return e.Mode == "" && e.KMSKeyID == "" && len(e.KMSContext) == 0 &&
+		!e.BucketKeyEnabled && len(e.CustomerKey) == 0
+}
+
+// Validate checks that the settings are consistent.
+func (e Encryption) Validate() error {
+	switch e.Mode {
+	case "", SSES3, SSEKMS, SSEKMSDSSE:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidEncryption, e.Mode)
+	}
+	if len(e.CustomerKey) > 0 {
+		if len(e.CustomerKey) != 32 {
+			return ErrInvalidCustomerKey
+		}
+		if e.Mode != "" || e.KMSKeyID != "" {
+			return fmt.Errorf("%w: SSE-C cannot be combined with another encryption mode", ErrInvalidEncryption)
+		}
+	}
+	if (e.KMSKeyID != "" || len(e.KMSContext) > 0) && e.Mode != SSEKMS && e.Mode != SSEKMSDSSE {
+		return fmt.Errorf("%w: KMS key or context requires mode %s or %s", ErrInvalidEncryption, SSEKMS, SSEKMSDSSE)
+	}
+	return nil
+}
+
+// sseParams holds encryption headers in the form the SDK inputs expect.
+type sseParams struct {
+	mode             types.ServerSideEncryption
+	kmsKeyID         *string
+	kmsContext       *string
+	bucketKeyEnabled *bool
+	customer         customerKey
+}
+
+// customerKey holds SSE-C headers.
+type customerKey struct {
+	algorithm *string
+	key       *string
+	keyMD5    *string
+}
+
+func newCustomerKey(key []byte) customerKey {
+	if len(key) == 0 {
+		return customerKey{}
+	}
+	sum := md5.Sum(key) //nolint:gosec // SSE-C requires the MD5 digest of the key
+	return customerKey{
+		algorithm: aws.String(sseCustomerAlgorithm),
+		key:       aws.String(base64.StdEncoding.EncodeToString(key)),
+		keyMD5:    aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	}
+}
+
+func (e Encryption) params() (sseParams, error) {
+	if err := e.Validate(); err != nil {
+		return sseParams{}, err
+	}
+
+	p := sseParams{
+		mode:     types.ServerSideEncryption(e.Mode),
+		customer: newCustomerKey(e.CustomerKey),
+	}
+	if e.KMSKeyID != "" {
+		p.kmsKeyID = aws.String(e.KMSKeyID)
+	}
+	if len(e.KMSContext) > 0 {
+		data, err := json.Marshal(e.KMSContext)
+		if err != nil {
+			return sseParams{}, fmt.Errorf("%w: encoding KMS context: %w", ErrInvalidEncryption, err)
+		}
+		p.kmsContext = aws.String(base64.StdEncoding.EncodeToString(data))
+	}
+	if e.BucketKeyEnabled {
+		p.bucketKeyEnabled = aws.Bool(true)
+	}
+	return p, nil
+}
+
+type encryptionKey struct{}
+
+type customerKeyKey struct{}
+
+// WithEncryption sets server-side encryption for a single object,
+// overriding the backend's configured encryption.
+func WithEncryption(e Encryption) omnistorage.WriterOption {
+	return omnistorage.WithWriterExtension(encryptionKey{}, e)
+}
+
+// WithCustomerKey supplies the SSE-C key for reading an object that was
+// written with a per-object customer key. Objects written with the
+// backend's configured SSECustomerKey do not need it.
+func WithCustomerKey(key []byte) omnistorage.ReaderOption {
+	return omnistorage.WithReaderExtension(customerKeyKey{}, key)
+}
+
+// encryption returns the backend's default encryption.
+func (c Config) encryption() Encryption {
+	return Encryption{
+		Mode:             c.ServerSideEncryption,
+		KMSKeyID:         c.SSEKMSKeyID,
+		BucketKeyEnabled: c.BucketKeyEnabled,
+		CustomerKey:      c.SSECustomerKey,
+	}
+}
+
+// writeEncryption returns the encryption headers for a write.
+func (b *Backend) writeEncryption(cfg *omnistorage.WriterConfig) (sseParams, error) {
+	e := b.config.encryption()
+	if v, ok := cfg.Extensions[encryptionKey{}].(Encryption); ok {
+		e = v
+	}
+	return e.params()
+}
+
+// readCustomerKey returns the SSE-C headers for a read.
+func (b *Backend) readCustomerKey(cfg *omnistorage.ReaderConfig) customerKey {
+	if key, ok := cfg.Extensions[customerKeyKey{}].([]byte); ok {
+		return newCustomerKey(key)
+	}
+	return b.sseCustomer
+}
+
+// BucketEncryption is a bucket's default encryption.
+type BucketEncryption struct {
+	// Mode is SSES3, SSEKMS or SSEKMSDSSE.
+	Mode string
+
+	// KMSKeyID is the default KMS key, if any.
+	KMSKeyID string
+
+	// BucketKeyEnabled reports whether S3 Bucket Keys are enabled.
+	BucketKeyEnabled bool
+}
+
+// BucketEncryption returns the bucket's default encryption.
+// It returns ErrBucketNotEncrypted if the bucket has no default encryption rule.
+func (b *Backend) BucketEncryption(ctx context.Context) (*BucketEncryption, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	result, err := b.client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{
+		Bucket: aws.String(b.config.Bucket),
+	})
+	if err != nil {
+		var apiErr interface{ ErrorCode() string }
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ServerSideEncryptionConfigurationNotFoundError" {
+			return nil, ErrBucketNotEncrypted
+		}
+		return nil, b.translateError(err, "bucket encryption", "")
+	}
+
+	if result.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range result.ServerSideEncryptionConfiguration.Rules {
+			def := rule.ApplyServerSideEncryptionByDefault
+			if def == nil {
+				continue
+			}
+			return &BucketEncryption{
+				Mode:             string(def.SSEAlgorithm),
+				KMSKeyID:         aws.ToString(def.KMSMasterKeyID),
+				BucketKeyEnabled: aws.ToBool(rule.BucketKeyEnabled),
+			}, nil
+		}
+	}
+	return nil, ErrBucketNotEncrypted
+}
+
+// VerifyBucketEncryption checks that the bucket encrypts new objects by
+// default, and that the default matches the configured ServerSideEncryption
+// and SSEKMSKeyID when those are set.
+func (b *Backend) VerifyBucketEncryption(ctx context.Context) error {
+	got, err := b.BucketEncryption(ctx)
+	if err != nil {
+		return err
+	}
+	if want := b.config.ServerSideEncryption; want != "" && got.Mode != want {
+		return fmt.Errorf("%w: bucket uses %s, want %s", ErrBucketNotEncrypted, got.Mode, want)
+	}
+	if want := b.config.SSEKMSKeyID; want != "" && got.KMSKeyID != want {
+		return fmt.Errorf("%w: bucket uses KMS key %q, want %q", ErrBucketNotEncrypted, got.KMSKeyID, want)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEncryptionValidate(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	tests := []struct {
+		name    string
+		enc     Encryption
+		wantErr error
+	}{
+		{"none", Encryption{}, nil},
+		{"sse-s3", Encryption{Mode: SSES3}, nil},
+		{"sse-kms", Encryption{Mode: SSEKMS, KMSKeyID: "alias/data", BucketKeyEnabled: true}, nil},
+		{"dsse", Encryption{Mode: SSEKMSDSSE}, nil},
+		{"sse-c", Encryption{CustomerKey: key}, nil},
+		{"unknown mode", Encryption{Mode: "rot13"}, ErrInvalidEncryption},
+		{"kms key without kms", Encryption{Mode: SSES3, KMSKeyID: "k"}, ErrInvalidEncryption},
+		{"short customer key", Encryption{CustomerKey: []byte("short")}, ErrInvalidCustomerKey},
+		{"sse-c with mode", Encryption{Mode: SSES3, CustomerKey: key}, ErrInvalidEncryption},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.enc.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := (Config{Bucket: "b", ServerSideEncryption: "bogus"}).Validate(); !errors.Is(err, ErrInvalidEncryption) {
+		t.Errorf("Config.Validate() = %v, want ErrInvalidEncryption", err)
+	}
+}
+
+func TestConfigFromMapEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	cfg := ConfigFromMap(map[string]string{
+		"bucket":                   "b",
+		"server_side_encryption":   "aws:kms",
+		"sse_kms_key_id":           "alias/data",
+		"bucket_key_enabled":       "true",
+		"verify_bucket_encryption": "true",
+	})
+	if cfg.ServerSideEncryption != SSEKMS || cfg.SSEKMSKeyID != "alias/data" || !cfg.BucketKeyEnabled || !cfg.VerifyBucketEncryption {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	cfg = ConfigFromMap(map[string]string{"sse_customer_key": base64.StdEncoding.EncodeToString(key)})
+	if !bytes.Equal(cfg.SSECustomerKey, key) {
+		t.Errorf("SSECustomerKey = %x, want %x", cfg.SSECustomerKey, key)
+	}
+}
+
+func TestWriteEncryptionHeaders(t *testing.T) {
+	f, b := newFakeS3(t)
+	kms := f.newBackend(b, func(c *Config) {
+		c.ServerSideEncryption = SSEKMS
+		c.SSEKMSKeyID = "alias/data"
+		c.BucketKeyEnabled = true
+	})
+	ctx := context.Background()
+
+	w, err := kms.NewWriter(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	_, _ = w.Write([]byte("hello"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	r := f.lastRequest("PUT", "")
+	if got := r.Header.Get("X-Amz-Server-Side-Encryption"); got != SSEKMS {
+		t.Errorf("SSE header = %q, want %q", got, SSEKMS)
+	}
+	if got := r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); got != "alias/data" {
+		t.Errorf("KMS key header = %q", got)
+	}
+	if got := r.Header.Get("X-Amz-Server-Side-Encryption-Bucket-Key-Enabled"); got != "true" {
+		t.Errorf("bucket key header = %q", got)
+	}
+
+	// A per-object option overrides the configured encryption.
+	w, _ = kms.NewWriter(ctx, "b.txt", WithEncryption(Encryption{Mode: SSES3}))
+	_ = w.Close()
+	r = f.lastRequest("PUT", "")
+	if got := r.Header.Get("X-Amz-Server-Side-Encryption"); got != SSES3 {
+		t.Errorf("SSE header = %q, want %q", got, SSES3)
+	}
+	if got := r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); got != "" {
+		t.Errorf("KMS key header = %q, want none", got)
+	}
+
+	// Copy applies the configured encryption to the destination.
+	if err := kms.Copy(ctx, "a.txt", "c.txt"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	r = f.lastRequest("PUT", "X-Amz-Copy-Source")
+	if got := r.Header.Get("X-Amz-Server-Side-Encryption"); got != SSEKMS {
+		t.Errorf("copy SSE header = %q, want %q", got, SSEKMS)
+	}
+
+	if _, err := kms.NewWriter(ctx, "d.txt", WithEncryption(Encryption{Mode: "bogus"})); !errors.Is(err, ErrInvalidEncryption) {
+		t.Errorf("NewWriter with invalid encryption: err = %v", err)
+	}
+}
+
+func TestCustomerKeyHeaders(t *testing.T) {
+	f, b := newFakeS3(t)
+	key := bytes.Repeat([]byte{9}, 32)
+	sum := md5.Sum(key)
+	wantKey := base64.StdEncoding.EncodeToString(key)
+	wantMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+	ssec := f.newBackend(b, func(c *Config) { c.SSECustomerKey = key })
+	ctx := context.Background()
+
+	w, _ := ssec.NewWriter(ctx, "secret.txt")
+	_, _ = w.Write([]byte("data"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	check := func(what string, r interface{ Get(string) string }) {
+		t.Helper()
+		if r.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "AES256" ||
+			r.Get("X-Amz-Server-Side-Encryption-Customer-Key") != wantKey ||
+			r.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") != wantMD5 {
+			t.Errorf("%s: missing or wrong SSE-C headers", what)
+		}
+	}
+	check("put", f.lastRequest("PUT", "").Header)
+
+	rc, err := ssec.NewReader(ctx, "secret.txt")
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "data" {
+		t.Errorf("read %q", data)
+	}
+	check("get", f.lastRequest("GET", "").Header)
+
+	if _, err := ssec.Stat(ctx, "secret.txt"); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	check("head", f.lastRequest("HEAD", "").Header)
+
+	if err := ssec.Copy(ctx, "secret.txt", "copy.txt"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	r := f.lastRequest("PUT", "X-Amz-Copy-Source")
+	check("copy destination", r.Header)
+	if r.Header.Get("X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key") != wantKey {
+		t.Error("copy: missing source SSE-C key")
+	}
+
+	// A per-object key on read overrides the configured key.
+	other := bytes.Repeat([]byte{3}, 32)
+	rc, err = b.NewReader(ctx, "secret.txt", WithCustomerKey(other))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	_ = rc.Close()
+	if got := f.lastRequest("GET", "").Header.Get("X-Amz-Server-Side-Encryption-Customer-Key"); got != base64.StdEncoding.EncodeToString(other) {
+		t.Errorf("read key header = %q", got)
+	}
+}
+
+func TestVerifyBucketEncryption(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+
+	if err := b.VerifyBucketEncryption(ctx); !errors.Is(err, ErrBucketNotEncrypted) {
+		t.Errorf("no default encryption: err = %v, want ErrBucketNotEncrypted", err)
+	}
+
+	f.encryption = `<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>aws:kms</SSEAlgorithm><KMSMasterKeyID>alias/data</KMSMasterKeyID></ApplyServerSideEncryptionByDefault><BucketKeyEnabled>true</BucketKeyEnabled></Rule></ServerSideEncryptionConfiguration>`
+
+	got, err := b.BucketEncryption(ctx)
+	if err != nil {
+		t.Fatalf("BucketEncryption: %v", err)
+	}
+	if got.Mode != SSEKMS || got.KMSKeyID != "alias/data" || !got.BucketKeyEnabled {
+		t.Errorf("BucketEncryption = %+v", got)
+	}
+
+	kms := f.newBackend(b, func(c *Config) { c.ServerSideEncryption = SSEKMS; c.SSEKMSKeyID = "alias/data" })
+	if err := kms.VerifyBucketEncryption(ctx); err != nil {
+		t.Errorf("matching encryption: %v", err)
+	}
+	s3mode := f.newBackend(b, func(c *Config) { c.ServerSideEncryption = SSES3 })
+	if err := s3mode.VerifyBucketEncryption(ctx); !errors.Is(err, ErrBucketNotEncrypted) {
+		t.Errorf("mismatched mode: err = %v, want ErrBucketNotEncrypted", err)
+	}
+
+	cfg := b.config
+	cfg.VerifyBucketEncryption = true
+	cfg.ServerSideEncryption = SSES3
+	if _, err := New(cfg); !errors.Is(err, ErrBucketNotEncrypted) {
+		t.Errorf("New with VerifyBucketEncryption: err = %v, want ErrBucketNotEncrypted", err)
+	}
+}
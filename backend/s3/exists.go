@@ -0,0 +1,104 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.DirExister
+var _ omnistorage.DirExister = (*Backend)(nil)
+
+// DirExists reports whether p is a directory: a prefix of at least one
+// object, or a directory marker created by Mkdir. It lists at most one
+// key.
+func (b *Backend) DirExists(ctx context.Context, p string) (bool, error) {
+	if err := b.checkClosed(); err != nil {
+		return false, err
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if strings.Trim(p, "/") == "" {
+		return true, nil
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	key := strings.TrimSuffix(b.fullKey(p), "/") + "/"
+	found, err := b.firstKey(ctx, key)
+	if err != nil {
+		return false, b.translateError(err, "dir exists", p)
+	}
+	return found != "", nil
+}
+
+// existsFallback answers Exists for key when HeadObject was denied with
+// headErr. Without s3:ListBucket, S3 denies HeadObject of a missing key
+// rather than report it missing, and some roles may not call HeadObject
+// at all, so Exists tries a one-key list, then, with ExistsGetFallback, a
+// one-byte GET. If those are denied too, headErr is returned.
+func (b *Backend) existsFallback(ctx context.Context, key, p string, headErr error) (bool, error) {
+	found, err := b.firstKey(ctx, key)
+	if err == nil {
+		// The key sorts before every other key it prefixes
+		return found == key, nil
+	}
+	if err := b.translateError(err, "exists", p); omnistorage.KindOf(err) != omnistorage.KindPermissionDenied {
+		return false, err
+	}
+	if !b.config.ExistsGetFallback {
+		return false, headErr
+	}
+
+	result, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:               aws.String(b.config.Bucket),
+		Key:                  aws.String(key),
+		Range:                aws.String("bytes=0-0"),
+		SSECustomerAlgorithm: b.sseCustomer.algorithm,
+		SSECustomerKey:       b.sseCustomer.key,
+		SSECustomerKeyMD5:    b.sseCustomer.keyMD5,
+		RequestPayer:         b.config.requestPayer(),
+	})
+	if err == nil {
+		_ = result.Body.Close()
+		return true, nil
+	}
+	var apiErr interface{ ErrorCode() string }
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey":
+			return false, nil
+		case "InvalidRange":
+			// An empty object has no byte 0
+			return true, nil
+		}
+	}
+	err = b.translateError(err, "exists", p)
+	if omnistorage.KindOf(err) == omnistorage.KindPermissionDenied {
+		return false, headErr
+	}
+	return false, err
+}
+
+// firstKey returns the first key with prefix, or "" if there is none.
+func (b *Backend) firstKey(ctx context.Context, prefix string) (string, error) {
+	result, err := b.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:       aws.String(b.config.Bucket),
+		Prefix:       aws.String(prefix),
+		MaxKeys:      aws.Int32(1),
+		RequestPayer: b.config.requestPayer(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(result.Contents) > 0 {
+		return aws.ToString(result.Contents[0].Key), nil
+	}
+	return "", nil
+}
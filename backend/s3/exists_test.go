@@ -0,0 +1,85 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestExistsFallback(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+	f.put("data/report.csv", []byte("a,b"))
+	f.put("data/report.csv.bak", []byte("old"))
+	f.put("data/empty", nil)
+
+	check := func(b *Backend, p string, want bool) {
+		t.Helper()
+		if got, err := b.Exists(ctx, p); err != nil || got != want {
+			t.Errorf("Exists(%s) = %v, %v, want %v", p, got, err, want)
+		}
+	}
+
+	// HeadObject denied: a one-key list answers
+	f.deny = map[string]bool{"head": true}
+	check(b, "data/report.csv", true)
+	check(b, "data/report", false)
+	check(b, "data/missing.csv", false)
+
+	// HeadObject and list denied: the denial stands without the GET
+	// fallback
+	f.deny = map[string]bool{"head": true, "list": true}
+	if _, err := b.Exists(ctx, "data/report.csv"); !errors.Is(err, omnistorage.ErrPermissionDenied) {
+		t.Errorf("Exists error = %v, want ErrPermissionDenied", err)
+	}
+
+	getter := f.newBackend(b, func(c *Config) { c.ExistsGetFallback = true })
+	check(getter, "data/report.csv", true)
+	check(getter, "data/empty", true)
+	check(getter, "data/missing.csv", false)
+	if r := f.lastRequest("GET", "Range"); r.Header.Get("Range") != "bytes=0-0" {
+		t.Errorf("Range = %q, want bytes=0-0", r.Header.Get("Range"))
+	}
+
+	// Everything denied
+	f.deny = map[string]bool{"head": true, "list": true, "get": true}
+	if _, err := getter.Exists(ctx, "data/report.csv"); !errors.Is(err, omnistorage.ErrPermissionDenied) {
+		t.Errorf("Exists error = %v, want ErrPermissionDenied", err)
+	}
+}
+
+func TestDirExists(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+	f.put("data/2024/report.csv", []byte("a,b"))
+	f.put("datastore.txt", []byte("x"))
+	if err := b.Mkdir(ctx, "empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[string]bool{
+		"":              true,
+		"data":          true,
+		"data/":         true,
+		"data/2024":     true,
+		"data/20":       false,
+		"datastore":     false,
+		"datastore.txt": false,
+		"empty":         true,
+		"missing":       false,
+	} {
+		if got, err := b.DirExists(ctx, p); err != nil || got != want {
+			t.Errorf("DirExists(%q) = %v, %v, want %v", p, got, err, want)
+		}
+	}
+
+	// The generic helper uses the backend's DirExists
+	if ok, err := omnistorage.DirExists(ctx, b, "data/2024"); err != nil || !ok {
+		t.Errorf("omnistorage.DirExists = %v, %v", ok, err)
+	}
+	if r := f.lastRequest("GET", "prefix"); r.URL.Query().Get("max-keys") != "1" {
+		t.Errorf("max-keys = %q, want 1", r.URL.Query().Get("max-keys"))
+	}
+}
@@ -0,0 +1,598 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a minimal in-memory S3 server for unit tests. It implements
+// just enough of the REST API, with path-style addressing, for the
+// operations the backend uses, and records every request.
+type fakeS3 struct {
+	t      *testing.T
+	bucket string
+
+	mu         sync.Mutex
+	objects    map[string]*fakeObject
+	uploads    map[string]*fakeUpload
+	requests   []*http.Request
+	encryption string // GetBucketEncryption response body; empty means none
+	failures   int    // number of upcoming requests to fail with 503 SlowDown
+	failParts  bool   // fail every multipart upload part
+	delay      time.Duration
+	deny       map[string]bool // request kinds denied with 403: "head", "get", "list"
+	location   string          // GetBucketLocation response
+	missing    bool            // the bucket does not exist until created
+}
+
+type fakeObject struct {
+	data    []byte
+	header  http.Header
+	tags    url.Values
+	modTime time.Time
+}
+
+// fakeUpload is an in-progress multipart upload.
+type fakeUpload struct {
+	key    string
+	header http.Header
+	tags   url.Values
+	parts  map[int][]byte
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *Backend) {
+	t.Helper()
+	f := &fakeS3{
+		t:       t,
+		bucket:  "test-bucket",
+		objects: make(map[string]*fakeObject),
+		uploads: make(map[string]*fakeUpload),
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	b, err := New(Config{
+		Bucket:          f.bucket,
+		Region:          "us-east-1",
+		Endpoint:        server.URL,
+		UsePathStyle:    true,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return f, b
+}
+
+// newBackend creates another backend against the same fake server.
+func (f *fakeS3) newBackend(b *Backend, mutate func(*Config)) *Backend {
+	f.t.Helper()
+	cfg := b.config
+	mutate(&cfg)
+	nb, err := New(cfg)
+	if err != nil {
+		f.t.Fatalf("New: %v", err)
+	}
+	return nb
+}
+
+// lastRequest returns the most recent request with the given method whose
+// query contains marker, or any request with that method if marker is "".
+func (f *fakeS3) lastRequest(method, marker string) *http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		r := f.requests[i]
+		if r.Method == method && (marker == "" || r.URL.Query().Has(marker) || r.Header.Get(marker) != "") {
+			return r
+		}
+	}
+	f.t.Fatalf("no %s request matching %q", method, marker)
+	return nil
+}
+
+// count returns the number of requests with the given method.
+func (f *fakeS3) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeS3) put(key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = &fakeObject{data: data, header: http.Header{}, modTime: time.Now()}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rec := r.Clone(r.Context())
+	rec.Body = io.NopCloser(bytes.NewReader(body))
+	f.requests = append(f.requests, rec)
+
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+	if f.failures > 0 {
+		f.failures--
+		writeError(w, http.StatusServiceUnavailable, "SlowDown")
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/"+f.bucket)
+	key := strings.TrimPrefix(p, "/")
+	q := r.URL.Query()
+
+	if kind := requestKind(r, key); f.deny[kind] {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		writeError(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	switch {
+	case key == "" && q.Has("encryption"):
+		if f.encryption == "" {
+			writeError(w, http.StatusNotFound, "ServerSideEncryptionConfigurationNotFoundError")
+			return
+		}
+		_, _ = io.WriteString(w, f.encryption)
+	case key == "" && q.Has("location"):
+		fmt.Fprintf(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">%s</LocationConstraint>`, f.location)
+	case key == "" && f.missing && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusNotFound)
+	case key == "" && r.Method == http.MethodPut:
+		// CreateBucket
+		if !f.missing {
+			writeError(w, http.StatusConflict, "BucketAlreadyOwnedByYou")
+			return
+		}
+		f.missing = false
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, q)
+	case key == "" && r.Method == http.MethodHead:
+		// HeadBucket
+	case q.Has("uploads") || q.Has("uploadId"):
+		f.multipart(w, r, key, body)
+	case q.Has("tagging"):
+		f.tagging(w, r, key, body)
+	case q.Has("retention") || q.Has("legal-hold"):
+		f.objectLock(w, r, key, body)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.copyObject(w, r, key)
+	case r.Method == http.MethodPut:
+		tags, _ := url.ParseQuery(r.Header.Get("X-Amz-Tagging"))
+		f.objects[key] = &fakeObject{data: body, header: storedHeaders(r.Header), tags: tags, modTime: time.Now()}
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		f.getObject(w, r, key)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// requestKind classifies a request for fakeS3.deny.
+func requestKind(r *http.Request, key string) string {
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		return "list"
+	case key != "" && r.Method == http.MethodHead:
+		return "head"
+	case key != "" && r.Method == http.MethodGet:
+		return "get"
+	}
+	return ""
+}
+
+func (f *fakeS3) getObject(w http.ResponseWriter, r *http.Request, key string) {
+	obj, ok := f.objects[key]
+	if !ok {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	for k, v := range obj.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", etag(obj.data))
+	w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+	if len(obj.tags) > 0 {
+		w.Header().Set("X-Amz-Tagging-Count", strconv.Itoa(len(obj.tags)))
+	}
+
+	data := obj.data
+	status := http.StatusOK
+	if r.Header.Get("Range") != "" && len(data) == 0 {
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+		return
+	}
+	if rng := r.Header.Get("Range"); rng != "" {
+		var start, end int64 = 0, int64(len(data)) - 1
+		spec := strings.TrimPrefix(rng, "bytes=")
+		parts := strings.SplitN(spec, "-", 2)
+		start, _ = strconv.ParseInt(parts[0], 10, 64)
+		if parts[1] != "" {
+			end, _ = strconv.ParseInt(parts[1], 10, 64)
+		}
+		if end >= int64(len(data)) {
+			end = int64(len(data)) - 1
+		}
+		data = data[start : end+1]
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(obj.data)))
+		status = http.StatusPartialContent
+	}
+
+	if status == http.StatusOK && w.Header().Get("X-Amz-Checksum-Crc32") == "" {
+		// S3 returns a full-object checksum for every object; without one,
+		// the SDK logs a warning on each read.
+		sum := crc32.ChecksumIEEE(data)
+		w.Header().Set("X-Amz-Checksum-Crc32", base64.StdEncoding.EncodeToString([]byte{
+			byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum),
+		}))
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+func (f *fakeS3) copyObject(w http.ResponseWriter, r *http.Request, key string) {
+	src, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	src = strings.TrimPrefix(strings.TrimPrefix(src, "/"), f.bucket+"/")
+	obj, ok := f.objects[src]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	header := obj.header.Clone()
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		header = storedHeaders(r.Header)
+	} else {
+		for k, v := range storedHeaders(r.Header) {
+			if !strings.HasPrefix(k, "X-Amz-Meta-") && k != "Content-Type" {
+				header[k] = v
+			}
+		}
+	}
+	tags := obj.tags
+	if r.Header.Get("X-Amz-Tagging-Directive") == "REPLACE" {
+		tags, _ = url.ParseQuery(r.Header.Get("X-Amz-Tagging"))
+	}
+	f.objects[key] = &fakeObject{data: append([]byte(nil), obj.data...), header: header, tags: tags, modTime: time.Now()}
+	fmt.Fprintf(w, `<CopyObjectResult><ETag>%s</ETag><LastModified>%s</LastModified></CopyObjectResult>`,
+		etag(obj.data), time.Now().UTC().Format(time.RFC3339))
+}
+
+func (f *fakeS3) multipart(w http.ResponseWriter, r *http.Request, key string, body []byte) {
+	q := r.URL.Query()
+	if q.Has("uploads") {
+		id := strconv.Itoa(len(f.requests))
+		tags, _ := url.ParseQuery(r.Header.Get("X-Amz-Tagging"))
+		f.uploads[id] = &fakeUpload{key: key, header: storedHeaders(r.Header), tags: tags, parts: make(map[int][]byte)}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>",
+			f.bucket, xmlEscape(key), id)
+		return
+	}
+
+	id := q.Get("uploadId")
+	upload, ok := f.uploads[id]
+	if !ok || upload.key != key {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if f.failParts {
+			writeError(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		data := body
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			src, _ = url.PathUnescape(src)
+			obj, ok := f.objects[strings.TrimPrefix(strings.TrimPrefix(src, "/"), f.bucket+"/")]
+			if !ok {
+				writeError(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			var start, end int
+			_, _ = fmt.Sscanf(r.Header.Get("X-Amz-Copy-Source-Range"), "bytes=%d-%d", &start, &end)
+			data = append([]byte(nil), obj.data[start:end+1]...)
+			fmt.Fprintf(w, "<CopyPartResult><ETag>%s</ETag></CopyPartResult>", etag(data))
+		} else {
+			w.Header().Set("ETag", etag(data))
+		}
+		upload.parts[n] = data
+	case http.MethodPost:
+		var complete struct {
+			Parts []struct{ PartNumber int } `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			writeError(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var data []byte
+		for _, p := range complete.Parts {
+			part, ok := upload.parts[p.PartNumber]
+			if !ok {
+				writeError(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			data = append(data, part...)
+		}
+		delete(f.uploads, id)
+		f.objects[key] = &fakeObject{data: data, header: upload.header, tags: upload.tags, modTime: time.Now()}
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>",
+			f.bucket, xmlEscape(key), etag(data))
+	case http.MethodDelete:
+		delete(f.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) tagging(w http.ResponseWriter, r *http.Request, key string, body []byte) {
+	obj, ok := f.objects[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var b strings.Builder
+		keys := make([]string, 0, len(obj.tags))
+		for k := range obj.tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "<Tag><Key>%s</Key><Value>%s</Value></Tag>", xmlEscape(k), xmlEscape(obj.tags.Get(k)))
+		}
+		fmt.Fprintf(w, "<Tagging><TagSet>%s</TagSet></Tagging>", b.String())
+	case http.MethodPut:
+		var tagging struct {
+			Tags []struct {
+				Key   string
+				Value string
+			} `xml:"TagSet>Tag"`
+		}
+		if err := xml.Unmarshal(body, &tagging); err != nil {
+			writeError(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		obj.tags = url.Values{}
+		for _, t := range tagging.Tags {
+			obj.tags.Set(t.Key, t.Value)
+		}
+	case http.MethodDelete:
+		obj.tags = nil
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// objectLock handles PutObjectRetention and PutObjectLegalHold, storing
+// the lock as the headers HeadObject returns.
+func (f *fakeS3) objectLock(w http.ResponseWriter, r *http.Request, key string, body []byte) {
+	obj, ok := f.objects[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	var lock struct {
+		Mode            string
+		RetainUntilDate string
+		Status          string
+	}
+	if err := xml.Unmarshal(body, &lock); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	if r.URL.Query().Has("legal-hold") {
+		obj.header.Set("X-Amz-Object-Lock-Legal-Hold", lock.Status)
+		return
+	}
+	if lock.Mode == "" && r.Header.Get("X-Amz-Bypass-Governance-Retention") != "true" {
+		writeError(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	if lock.Mode == "" {
+		obj.header.Del("X-Amz-Object-Lock-Mode")
+		obj.header.Del("X-Amz-Object-Lock-Retain-Until-Date")
+		return
+	}
+	obj.header.Set("X-Amz-Object-Lock-Mode", lock.Mode)
+	obj.header.Set("X-Amz-Object-Lock-Retain-Until-Date", lock.RetainUntilDate)
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, q url.Values) {
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	after := q.Get("continuation-token")
+	if after == "" {
+		after = q.Get("start-after")
+	}
+	maxKeys := 1000
+	if v := q.Get("max-keys"); v != "" {
+		maxKeys, _ = strconv.Atoi(v)
+	}
+
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var (
+		contents  strings.Builder
+		prefixes  strings.Builder
+		seen      = make(map[string]bool)
+		count     int
+		truncated bool
+		last      string
+	)
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) || k <= after {
+			continue
+		}
+		// A common prefix token skips every key under that prefix.
+		if delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(k, after) {
+			continue
+		}
+		entry := k
+		if delimiter != "" {
+			if i := strings.Index(k[len(prefix):], delimiter); i >= 0 {
+				entry = k[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if seen[entry] {
+			continue
+		}
+		if count == maxKeys {
+			truncated = true
+			break
+		}
+		seen[entry] = true
+		count++
+		last = entry
+		if entry != k {
+			fmt.Fprintf(&prefixes, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", xmlEscape(entry))
+			continue
+		}
+		obj := f.objects[k]
+		storageClass := obj.header.Get("X-Amz-Storage-Class")
+		if storageClass == "" {
+			storageClass = "STANDARD"
+		}
+		fmt.Fprintf(&contents, "<Contents><Key>%s</Key><Size>%d</Size><ETag>%s</ETag><LastModified>%s</LastModified><StorageClass>%s</StorageClass></Contents>",
+			xmlEscape(k), len(obj.data), etag(obj.data), obj.modTime.UTC().Format(time.RFC3339), storageClass)
+	}
+
+	fmt.Fprintf(w, "<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><MaxKeys>%d</MaxKeys><IsTruncated>%t</IsTruncated>",
+		f.bucket, xmlEscape(prefix), count, maxKeys, truncated)
+	if truncated {
+		fmt.Fprintf(w, "<NextContinuationToken>%s</NextContinuationToken>", xmlEscape(last))
+	}
+	_, _ = io.WriteString(w, contents.String()+prefixes.String()+"</ListBucketResult>")
+}
+
+// storedHeaders returns the request headers S3 would store with an object.
+func storedHeaders(h http.Header) http.Header {
+	out := http.Header{}
+	for k, v := range h {
+		switch {
+		case strings.HasPrefix(k, "X-Amz-Meta-"),
+			k == "Content-Type",
+			k == "Cache-Control",
+			k == "Content-Disposition",
+			k == "X-Amz-Storage-Class",
+			k == "X-Amz-Server-Side-Encryption",
+			k == "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id",
+			k == "X-Amz-Server-Side-Encryption-Customer-Algorithm",
+			strings.HasPrefix(k, "X-Amz-Object-Lock-"):
+			out[k] = v
+		case k == "Content-Encoding":
+			// aws-chunked describes the request, not the object
+			var encs []string
+			for _, e := range strings.Split(strings.Join(v, ","), ",") {
+				if e = strings.TrimSpace(e); e != "" && e != "aws-chunked" {
+					encs = append(encs, e)
+				}
+			}
+			if len(encs) > 0 {
+				out[k] = []string{strings.Join(encs, ",")}
+			}
+		}
+	}
+	return out
+}
+
+// readBody reads a request body, decoding aws-chunked framing if present.
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") &&
+		!strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var out bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex := strings.TrimSpace(strings.SplitN(line, ";", 2)[0])
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return out.Bytes(), nil
+		}
+		if _, err := io.CopyN(&out, br, size); err != nil {
+			return nil, err
+		}
+		if _, err := br.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.DirReader.
+var _ omnistorage.DirReader = (*Backend)(nil)
+
+// ListDir lists the immediate children of the directory dir, one level
+// deep, using "/" as the delimiter. Objects are returned as *ObjectInfo;
+// subdirectories (common prefixes) are returned with IsDir set and no
+// trailing slash. Directory markers created by Mkdir are omitted.
+// Entries are sorted by path. An empty dir lists the root.
+//
+// Objects carry their ETag but no MD5 hash: a listing does not say how an
+// object is encrypted, and the ETag of an SSE-KMS or SSE-C object is not
+// its MD5. Use Stat for the hash.
+func (b *Backend) ListDir(ctx context.Context, dir string) ([]omnistorage.ObjectInfo, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	fullPrefix := b.fullKey(strings.Trim(dir, "/"))
+	if fullPrefix != "" && !strings.HasSuffix(fullPrefix, "/") {
+		fullPrefix += "/"
+	}
+
+	var entries []omnistorage.ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket:       aws.String(b.config.Bucket),
+		Prefix:       aws.String(fullPrefix),
+		Delimiter:    aws.String("/"),
+		RequestPayer: b.config.requestPayer(),
+	})
+
+	for paginator.HasMorePages() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("s3: listing objects: %w", err)
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			rel := strings.TrimSuffix(b.relPath(aws.ToString(cp.Prefix)), "/")
+			if rel == "" {
+				continue
+			}
+			entries = append(entries, &omnistorage.BasicObjectInfo{
+				ObjectPath:  rel,
+				ObjectIsDir: true,
+			})
+		}
+
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if key == fullPrefix {
+				continue // directory marker
+			}
+
+			storageClass := string(obj.StorageClass)
+			if storageClass == "" {
+				storageClass = StorageClassStandard
+			}
+
+			entries = append(entries, &ObjectInfo{
+				BasicObjectInfo: omnistorage.BasicObjectInfo{
+					ObjectPath:         b.relPath(key),
+					ObjectSize:         aws.ToInt64(obj.Size),
+					ObjectModTime:      aws.ToTime(obj.LastModified),
+					ObjectETag:         strings.Trim(aws.ToString(obj.ETag), "\""),
+					ObjectStorageClass: storageClass,
+				},
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path() < entries[j].Path()
+	})
+	return entries, nil
+}
+
+// relPath returns key relative to the configured prefix.
+func (b *Backend) relPath(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, b.config.Prefix), "/")
+}
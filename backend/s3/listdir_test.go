@@ -0,0 +1,107 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestListDir(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+
+	for _, key := range []string{
+		"data/readme.txt",
+		"data/2024/jan.csv",
+		"data/2024/feb.csv",
+		"data/2025/jan.csv",
+		"data/logs/",
+		"other.txt",
+	} {
+		f.put(key, []byte("x"))
+	}
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"", []string{"data/", "other.txt"}},
+		{"data", []string{"data/2024/", "data/2025/", "data/logs/", "data/readme.txt"}},
+		{"data/", []string{"data/2024/", "data/2025/", "data/logs/", "data/readme.txt"}},
+		{"data/2024", []string{"data/2024/feb.csv", "data/2024/jan.csv"}},
+		{"data/logs", nil},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		entries, err := b.ListDir(ctx, tt.dir)
+		if err != nil {
+			t.Fatalf("ListDir(%q): %v", tt.dir, err)
+		}
+		var got []string
+		for _, e := range entries {
+			name := e.Path()
+			if e.IsDir() {
+				name += "/"
+			}
+			got = append(got, name)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ListDir(%q) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+
+	entries, err := b.ListDir(ctx, "data")
+	if err != nil {
+		t.Fatalf("ListDir: %v", err)
+	}
+	file, ok := entries[3].(*ObjectInfo)
+	if !ok || file.Size() != 1 || file.StorageClass() != StorageClassStandard {
+		t.Errorf("file entry = %#v", entries[3])
+	}
+}
+
+func TestListDirPrefix(t *testing.T) {
+	f, b := newFakeS3(t)
+	f.put("root/a/b.txt", []byte("x"))
+	f.put("root/c.txt", []byte("x"))
+	f.put("elsewhere.txt", []byte("x"))
+
+	prefixed := f.newBackend(b, func(c *Config) { c.Prefix = "root" })
+	entries, err := prefixed.ListDir(context.Background(), "")
+	if err != nil {
+		t.Fatalf("ListDir: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path() != "a" || !entries[0].IsDir() || entries[1].Path() != "c.txt" {
+		t.Errorf("ListDir = %v", entries)
+	}
+}
+
+func TestListPrefix(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+	for _, key := range []string{"data/a.txt", "data/sub/b.txt", "data/empty/", "data2/c.txt"} {
+		f.put(key, []byte("x"))
+	}
+
+	// The configured Prefix is a directory: "data2/c.txt" is outside it
+	scoped := f.newBackend(b, func(c *Config) { c.Prefix = "data" })
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"a.txt", "sub/b.txt"}},
+		{"sub", []string{"b.txt"}},
+		{"/sub/", []string{"b.txt"}},
+		{"s", nil},
+		{"a.txt", nil},
+	}
+	for _, tt := range tests {
+		got, err := scoped.List(ctx, tt.prefix)
+		if err != nil {
+			t.Fatalf("List(%q): %v", tt.prefix, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("List(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
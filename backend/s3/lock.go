@@ -0,0 +1,112 @@
+package s3
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.ObjectLocker.
+var _ omnistorage.ObjectLocker = (*Backend)(nil)
+
+// lockParams are the Object Lock headers of an upload, or empty if not
+// set.
+type lockParams struct {
+	mode        types.ObjectLockMode
+	retainUntil *time.Time
+	legalHold   types.ObjectLockLegalHoldStatus
+}
+
+func newLockParams(cfg *omnistorage.WriterConfig) lockParams {
+	var p lockParams
+	if cfg.RetentionMode != "" {
+		p.mode = types.ObjectLockMode(cfg.RetentionMode)
+		p.retainUntil = aws.Time(cfg.RetainUntil)
+	}
+	if cfg.LegalHold {
+		p.legalHold = types.ObjectLockLegalHoldStatusOn
+	}
+	return p
+}
+
+// objectLock converts the Object Lock fields of a response.
+func objectLock(mode types.ObjectLockMode, until *time.Time, hold types.ObjectLockLegalHoldStatus) omnistorage.ObjectLock {
+	return omnistorage.ObjectLock{
+		Mode:        omnistorage.RetentionMode(mode),
+		RetainUntil: aws.ToTime(until),
+		LegalHold:   hold == types.ObjectLockLegalHoldStatusOn,
+	}
+}
+
+// ObjectLock returns the Object Lock state of the object at path. It needs
+// the s3:GetObjectRetention and s3:GetObjectLegalHold permissions; without
+// them, S3 omits the lock and the object looks unlocked.
+func (b *Backend) ObjectLock(ctx context.Context, p string) (omnistorage.ObjectLock, error) {
+	if err := b.checkClosed(); err != nil {
+		return omnistorage.ObjectLock{}, err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	result, err := b.client.HeadObject(ctx, b.headInput(b.fullKey(p)))
+	if err != nil {
+		return omnistorage.ObjectLock{}, b.translateError(err, "object lock", p)
+	}
+	return objectLock(result.ObjectLockMode, result.ObjectLockRetainUntilDate, result.ObjectLockLegalHoldStatus), nil
+}
+
+// SetRetention sets the retention period of the object at path. A
+// COMPLIANCE retention can only be extended. An empty mode removes a
+// GOVERNANCE retention, which needs the s3:BypassGovernanceRetention
+// permission.
+func (b *Backend) SetRetention(ctx context.Context, p string, mode omnistorage.RetentionMode, until time.Time) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	input := &s3.PutObjectRetentionInput{
+		Bucket:       aws.String(b.config.Bucket),
+		Key:          aws.String(b.fullKey(p)),
+		Retention:    &types.ObjectLockRetention{},
+		RequestPayer: b.config.requestPayer(),
+	}
+	if mode != "" {
+		input.Retention.Mode = types.ObjectLockRetentionMode(mode)
+		input.Retention.RetainUntilDate = aws.Time(until)
+	} else {
+		input.BypassGovernanceRetention = aws.Bool(true)
+	}
+	_, err := b.client.PutObjectRetention(ctx, input)
+	return b.translateError(err, "set retention", p)
+}
+
+// SetLegalHold places or removes a legal hold on the object at path.
+func (b *Backend) SetLegalHold(ctx context.Context, p string, on bool) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	status := types.ObjectLockLegalHoldStatusOff
+	if on {
+		status = types.ObjectLockLegalHoldStatusOn
+	}
+	_, err := b.client.PutObjectLegalHold(ctx, &s3.PutObjectLegalHoldInput{
+		Bucket:       aws.String(b.config.Bucket),
+		Key:          aws.String(b.fullKey(p)),
+		LegalHold:    &types.ObjectLockLegalHold{Status: status},
+		RequestPayer: b.config.requestPayer(),
+	})
+	return b.translateError(err, "set legal hold", p)
+}
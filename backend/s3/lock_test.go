@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestObjectLock(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if b.Features().ObjectLock {
+		t.Error("ObjectLock feature set without Config.ObjectLock")
+	}
+	if !f.newBackend(b, func(c *Config) { c.ObjectLock = true }).Features().ObjectLock {
+		t.Error("ObjectLock feature not set with Config.ObjectLock")
+	}
+
+	writeObject(t, b, "plain.txt", "a")
+	if lock, err := b.ObjectLock(ctx, "plain.txt"); err != nil || lock.LockedAt(time.Now()) {
+		t.Errorf("ObjectLock(plain.txt) = %v, %v, want unlocked", lock, err)
+	}
+
+	writeObject(t, b, "locked.txt", "a",
+		omnistorage.WithRetention(omnistorage.RetentionCompliance, until),
+		omnistorage.WithLegalHold())
+	header := f.lastRequest("PUT", "").Header
+	if got := header.Get("X-Amz-Object-Lock-Mode"); got != "COMPLIANCE" {
+		t.Errorf("lock mode header = %q", got)
+	}
+	if got := header.Get("X-Amz-Object-Lock-Legal-Hold"); got != "ON" {
+		t.Errorf("legal hold header = %q", got)
+	}
+	want := omnistorage.ObjectLock{Mode: omnistorage.RetentionCompliance, RetainUntil: until, LegalHold: true}
+	if lock, err := b.ObjectLock(ctx, "locked.txt"); err != nil || lock != want {
+		t.Errorf("ObjectLock(locked.txt) = %v, %v, want %v", lock, err, want)
+	}
+	if got := statS3(t, b, "locked.txt").Lock; got != want {
+		t.Errorf("Stat Lock = %v, want %v", got, want)
+	}
+
+	if err := b.SetLegalHold(ctx, "locked.txt", false); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+	if err := b.SetRetention(ctx, "locked.txt", omnistorage.RetentionGovernance, until.AddDate(1, 0, 0)); err != nil {
+		t.Fatalf("SetRetention: %v", err)
+	}
+	lock, err := b.ObjectLock(ctx, "locked.txt")
+	if err != nil || lock.LegalHold || lock.Mode != omnistorage.RetentionGovernance || !lock.RetainUntil.Equal(until.AddDate(1, 0, 0)) {
+		t.Errorf("ObjectLock after changes = %v, %v", lock, err)
+	}
+	if err := b.SetRetention(ctx, "locked.txt", "", time.Time{}); err != nil {
+		t.Fatalf("SetRetention(none): %v", err)
+	}
+	if lock, _ := b.ObjectLock(ctx, "locked.txt"); lock.LockedAt(time.Now()) {
+		t.Errorf("ObjectLock after removing retention = %v", lock)
+	}
+
+	if _, err := b.ObjectLock(ctx, "missing.txt"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("ObjectLock(missing.txt) error = %v, want ErrNotFound", err)
+	}
+	if err := b.SetLegalHold(ctx, "missing.txt", true); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("SetLegalHold(missing.txt) error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,84 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.MetadataSetter
+var _ omnistorage.MetadataSetter = (*Backend)(nil)
+
+// SetMetadata replaces the user metadata of the object at path, and its
+// content type unless contentType is empty, by copying the object onto
+// itself with the REPLACE metadata directive; the data is not uploaded
+// again. The object keeps its other content headers, tags, storage class
+// and encryption. Objects larger than Config.CopyCutoff are copied part by
+// part and take the configured encryption, as with Copy.
+func (b *Backend) SetMetadata(ctx context.Context, p string, metadata map[string]string, contentType string) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	key := b.fullKey(p)
+	head, err := b.client.HeadObject(ctx, b.headInput(key))
+	if err != nil {
+		return b.translateError(err, "set metadata", p)
+	}
+
+	updated := *head
+	updated.Metadata = maps.Clone(metadata)
+	if contentType != "" {
+		updated.ContentType = aws.String(contentType)
+	}
+
+	copySource := fmt.Sprintf("%s/%s", b.config.Bucket, key)
+	if aws.ToInt64(head.ContentLength) > b.config.CopyCutoff {
+		return b.multipartCopy(ctx, p, key, copySource, &updated, head.StorageClass)
+	}
+
+	// SSE-KMS objects must name their key again, or the copy falls back to
+	// the bucket's default encryption
+	var kmsKeyID *string
+	if head.ServerSideEncryption == types.ServerSideEncryptionAwsKms ||
+		head.ServerSideEncryption == types.ServerSideEncryptionAwsKmsDsse {
+		kmsKeyID = head.SSEKMSKeyId
+	}
+
+	_, err = b.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:                         aws.String(b.config.Bucket),
+		CopySource:                     aws.String(copySource),
+		Key:                            aws.String(key),
+		MetadataDirective:              types.MetadataDirectiveReplace,
+		Metadata:                       updated.Metadata,
+		ContentType:                    updated.ContentType,
+		CacheControl:                   head.CacheControl,
+		ContentDisposition:             head.ContentDisposition,
+		ContentEncoding:                head.ContentEncoding,
+		ContentLanguage:                head.ContentLanguage,
+		StorageClass:                   head.StorageClass,
+		ServerSideEncryption:           head.ServerSideEncryption,
+		SSEKMSKeyId:                    kmsKeyID,
+		BucketKeyEnabled:               head.BucketKeyEnabled,
+		SSECustomerAlgorithm:           b.sseCustomer.algorithm,
+		SSECustomerKey:                 b.sseCustomer.key,
+		SSECustomerKeyMD5:              b.sseCustomer.keyMD5,
+		CopySourceSSECustomerAlgorithm: b.sseCustomer.algorithm,
+		CopySourceSSECustomerKey:       b.sseCustomer.key,
+		CopySourceSSECustomerKeyMD5:    b.sseCustomer.keyMD5,
+		RequestPayer:                   b.config.requestPayer(),
+	})
+	return b.translateError(err, "set metadata", p)
+}
@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestSetMetadata(t *testing.T) {
+	ctx := context.Background()
+	f, b := newFakeS3(t)
+	small := f.newBackend(b, func(c *Config) {
+		c.CopyCutoff = 50
+		c.PartSize = 30
+	})
+
+	data := strings.Repeat("0123456789", 10)
+	for _, p := range []string{"small.txt", "big.txt"} {
+		content := data[:10]
+		if p == "big.txt" {
+			content = data
+		}
+		writeObject(t, b, p, content,
+			omnistorage.WithContentType("application/octet-stream"),
+			omnistorage.WithCacheControl("max-age=60"),
+			omnistorage.WithMetadata(map[string]string{"owner": "ops"}),
+			WithStorageClass(StorageClassStandardIA),
+			WithObjectTags(map[string]string{"team": "data"}))
+
+		meta := map[string]string{"reviewed": "yes"}
+		if err := small.SetMetadata(ctx, p, meta, "text/plain"); err != nil {
+			t.Fatalf("SetMetadata(%s): %v", p, err)
+		}
+
+		info := statS3(t, b, p)
+		if got := info.ContentType(); got != "text/plain" {
+			t.Errorf("%s ContentType = %q, want text/plain", p, got)
+		}
+		if got := info.Metadata(); !maps.Equal(got, meta) {
+			t.Errorf("%s Metadata = %v, want %v", p, got, meta)
+		}
+		if got := info.CacheControl(); got != "max-age=60" {
+			t.Errorf("%s CacheControl = %q, want it kept", p, got)
+		}
+		if got := info.StorageClass(); got != StorageClassStandardIA {
+			t.Errorf("%s StorageClass = %q, want it kept", p, got)
+		}
+		if got := info.Tags["team"]; got != "data" {
+			t.Errorf("%s tags = %v, want them kept", p, info.Tags)
+		}
+		if got := readObject(t, b, p); got != content {
+			t.Errorf("%s data changed to %q", p, got)
+		}
+	}
+
+	// An empty content type keeps the current one
+	if err := b.SetMetadata(ctx, "small.txt", nil, ""); err != nil {
+		t.Fatalf("SetMetadata: %v", err)
+	}
+	info := statS3(t, b, "small.txt")
+	if info.ContentType() != "text/plain" || len(info.Metadata()) != 0 {
+		t.Errorf("ContentType = %q, Metadata = %v; want text/plain and none", info.ContentType(), info.Metadata())
+	}
+
+	if err := b.SetMetadata(ctx, "missing.txt", nil, ""); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("SetMetadata(missing) error = %v, want ErrNotFound", err)
+	}
+}
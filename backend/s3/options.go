@@ -1,8 +1,13 @@
 package s3
 
 import (
+	"encoding/base64"
+	"net/url"
 	"os"
 	"strconv"
+	"time"
+
+	"github.com/grokify/omnistorage"
 )
 
 // Config holds configuration for the S3 backend.
@@ -11,7 +16,10 @@ type Config struct {
 	Bucket string
 
 	// Region is the AWS region (e.g., "us-east-1").
-	// If empty, uses AWS_REGION or AWS_DEFAULT_REGION environment variable.
+	// If empty, New detects the bucket's region with GetBucketLocation on
+	// AWS, a network request bounded by OpTimeout or 10 seconds, and
+	// otherwise, or if the location cannot be read in time, uses the
+	// AWS_REGION or AWS_DEFAULT_REGION environment variable.
 	Region string
 
 	// Endpoint is a custom endpoint URL for S3-compatible services.
@@ -37,6 +45,14 @@ type Config struct {
 	// SessionToken is an optional session token for temporary credentials.
 	SessionToken string
 
+	// Anonymous sends unsigned requests, for reading public buckets without
+	// AWS credentials. It takes precedence over any configured credentials.
+	Anonymous bool
+
+	// RequesterPays acknowledges that the requester is charged for requests
+	// and data transfer. Required to access requester-pays buckets.
+	RequesterPays bool
+
 	// UsePathStyle forces path-style addressing instead of virtual-hosted-style.
 	// Required for some S3-compatible services like MinIO.
 	// Set to true for: MinIO, some older S3-compatible services.
@@ -55,6 +71,88 @@ type Config struct {
 	// Concurrency is the number of concurrent upload/download goroutines.
 	// Default: 5.
 	Concurrency int
+
+	// CopyCutoff is the object size above which Copy uses a multipart copy
+	// instead of a single CopyObject request.
+	// Default and maximum: MaxCopySize (5GB).
+	CopyCutoff int64
+
+	// RetryMode is the SDK retry strategy: RetryModeStandard or
+	// RetryModeAdaptive. If empty, the SDK default (standard) is used.
+	RetryMode string
+
+	// MaxAttempts is the maximum number of attempts per request, including
+	// the first. 1 disables retries. If zero, the SDK default (3) is used.
+	MaxAttempts int
+
+	// MaxBackoff caps the delay between retries.
+	// If zero, the SDK default (20s) is used.
+	MaxBackoff time.Duration
+
+	// Timeout bounds each HTTP request, including reading the response body.
+	// If zero, requests are bounded only by their context.
+	Timeout time.Duration
+
+	// OpTimeout bounds each operation other than reading or writing an
+	// object, such as Stat, List, Copy or Delete, including its retries.
+	// An operation that exceeds it fails with an error matching
+	// omnistorage.ErrTimeout. If zero, operations are bounded only by
+	// their context.
+	OpTimeout time.Duration
+
+	// ExistsGetFallback lets Exists fall back to a one-byte GET when both
+	// HeadObject and ListObjectsV2 are denied, for roles that may only
+	// read objects. It costs a GET request on each such Exists.
+	ExistsGetFallback bool
+
+	// ServerSideEncryption is the encryption applied to new objects:
+	// SSES3 ("AES256"), SSEKMS ("aws:kms") or SSEKMSDSSE ("aws:kms:dsse").
+	// If empty, the bucket's default encryption applies.
+	ServerSideEncryption string
+
+	// SSEKMSKeyID is the KMS key ID, alias or ARN used with SSEKMS.
+	// If empty, the AWS managed key is used.
+	SSEKMSKeyID string
+
+	// BucketKeyEnabled uses an S3 Bucket Key with SSEKMS.
+	BucketKeyEnabled bool
+
+	// SSECustomerKey is a 32-byte key for SSE-C. It is sent with every
+	// read and write; S3 does not store it.
+	SSECustomerKey []byte
+
+	// StorageClass is the storage class for new objects, such as
+	// STANDARD_IA, INTELLIGENT_TIERING, GLACIER or DEEP_ARCHIVE.
+	// If empty, S3 uses STANDARD.
+	StorageClass string
+
+	// Tags are applied to every new object.
+	Tags map[string]string
+
+	// ObjectLock declares that the bucket has Object Lock enabled.
+	// Features then reports ObjectLock, so sync checks the lock of each
+	// object before deleting it, which costs a HEAD request per delete.
+	ObjectLock bool
+
+	// VerifyBucketEncryption makes New fail unless the bucket has default
+	// encryption matching ServerSideEncryption and SSEKMSKeyID.
+	VerifyBucketEncryption bool
+
+	// CreateBucket makes New create the bucket if it does not exist, in
+	// Region (us-east-1 if no region is configured), with BucketACL and
+	// ObjectOwnership, and with Object Lock enabled if ObjectLock is set.
+	CreateBucket bool
+
+	// BucketACL is the canned ACL of a bucket created by CreateBucket,
+	// such as "private". If empty, S3 applies "private". Other ACLs
+	// require an ObjectOwnership other than BucketOwnerEnforced.
+	BucketACL string
+
+	// ObjectOwnership is the object ownership of a bucket created by
+	// CreateBucket: ObjectOwnershipBucketOwnerEnforced,
+	// ObjectOwnershipBucketOwnerPreferred or ObjectOwnershipObjectWriter.
+	// If empty, S3 applies BucketOwnerEnforced.
+	ObjectOwnership string
 }
 
 // DefaultConfig returns a Config with default values.
@@ -62,6 +160,7 @@ func DefaultConfig() Config {
 	return Config{
 		PartSize:    5 * 1024 * 1024, // 5MB
 		Concurrency: 5,
+		CopyCutoff:  MaxCopySize,
 	}
 }
 
@@ -76,6 +175,17 @@ func DefaultConfig() Config {
 //   - AWS_SESSION_TOKEN: session token
 //   - OMNISTORAGE_S3_USE_PATH_STYLE: "true" for path-style addressing
 //   - OMNISTORAGE_S3_DISABLE_SSL: "true" to disable SSL
+//   - OMNISTORAGE_S3_SSE: server-side encryption mode ("AES256", "aws:kms")
+//   - OMNISTORAGE_S3_SSE_KMS_KEY_ID: KMS key for SSE-KMS
+//   - OMNISTORAGE_S3_SSE_CUSTOMER_KEY: base64-encoded SSE-C key
+//   - OMNISTORAGE_S3_STORAGE_CLASS: storage class for new objects
+//   - OMNISTORAGE_S3_ANONYMOUS: "true" for unsigned requests
+//   - OMNISTORAGE_S3_REQUESTER_PAYS: "true" for requester-pays buckets
+//   - OMNISTORAGE_S3_RETRY_MODE: "standard" or "adaptive"
+//   - OMNISTORAGE_S3_MAX_ATTEMPTS: maximum attempts per request
+//   - OMNISTORAGE_S3_TIMEOUT: per-request timeout (e.g., "30s")
+//   - OMNISTORAGE_S3_OBJECT_LOCK: "true" if the bucket has Object Lock enabled
+//   - OMNISTORAGE_S3_CREATE_BUCKET: "true" to create the bucket if missing
 func ConfigFromEnv() Config {
 	config := DefaultConfig()
 
@@ -120,6 +230,61 @@ func ConfigFromEnv() Config {
 		config.DisableSSL = true
 	}
 
+	// Encryption
+	if v := os.Getenv("OMNISTORAGE_S3_SSE"); v != "" {
+		config.ServerSideEncryption = v
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_SSE_KMS_KEY_ID"); v != "" {
+		config.SSEKMSKeyID = v
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_SSE_CUSTOMER_KEY"); v != "" {
+		if key, err := base64.StdEncoding.DecodeString(v); err == nil {
+			config.SSECustomerKey = key
+		}
+	}
+
+	// Storage class
+	if v := os.Getenv("OMNISTORAGE_S3_STORAGE_CLASS"); v != "" {
+		config.StorageClass = v
+	}
+
+	// Access
+	if v := os.Getenv("OMNISTORAGE_S3_ANONYMOUS"); v == "true" || v == "1" {
+		config.Anonymous = true
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_REQUESTER_PAYS"); v == "true" || v == "1" {
+		config.RequesterPays = true
+	}
+
+	// Retries and timeouts
+	if v := os.Getenv("OMNISTORAGE_S3_RETRY_MODE"); v != "" {
+		config.RetryMode = v
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			config.MaxAttempts = n
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			config.Timeout = d
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_OP_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			config.OpTimeout = d
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_EXISTS_GET_FALLBACK"); v == "true" || v == "1" {
+		config.ExistsGetFallback = true
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_OBJECT_LOCK"); v == "true" || v == "1" {
+		config.ObjectLock = true
+	}
+	if v := os.Getenv("OMNISTORAGE_S3_CREATE_BUCKET"); v == "true" || v == "1" {
+		config.CreateBucket = true
+	}
+
 	return config
 }
 
@@ -136,6 +301,26 @@ func ConfigFromEnv() Config {
 //   - disable_ssl: "true" to disable SSL
 //   - part_size: multipart upload part size in bytes
 //   - concurrency: number of concurrent operations
+//   - copy_cutoff: size in bytes above which Copy uses multipart copy
+//   - server_side_encryption: encryption mode ("AES256", "aws:kms", "aws:kms:dsse")
+//   - sse_kms_key_id: KMS key for SSE-KMS
+//   - bucket_key_enabled: "true" to use an S3 Bucket Key
+//   - sse_customer_key: base64-encoded SSE-C key
+//   - verify_bucket_encryption: "true" to check the bucket default encryption
+//   - storage_class: storage class for new objects
+//   - tags: tags for new objects, URL-query encoded ("team=data&env=prod")
+//   - anonymous: "true" for unsigned requests
+//   - requester_pays: "true" for requester-pays buckets
+//   - retry_mode: "standard" or "adaptive"
+//   - max_attempts: maximum attempts per request
+//   - max_backoff: maximum delay between retries (e.g., "5s")
+//   - timeout: per-request timeout (e.g., "30s")
+//   - op_timeout: per-operation timeout, including retries (e.g., "2m")
+//   - exists_get_fallback: "true" to let Exists fall back to a GET
+//   - object_lock: "true" if the bucket has Object Lock enabled
+//   - create_bucket: "true" to create the bucket if it does not exist
+//   - bucket_acl: canned ACL of a created bucket (e.g., "private")
+//   - object_ownership: object ownership of a created bucket
 func ConfigFromMap(m map[string]string) Config {
 	config := DefaultConfig()
 
@@ -176,14 +361,132 @@ func ConfigFromMap(m map[string]string) Config {
 			config.Concurrency = c
 		}
 	}
+	if v, ok := m["copy_cutoff"]; ok {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil && size > 0 {
+			config.CopyCutoff = size
+		}
+	}
+	if v, ok := m["server_side_encryption"]; ok {
+		config.ServerSideEncryption = v
+	}
+	if v, ok := m["sse_kms_key_id"]; ok {
+		config.SSEKMSKeyID = v
+	}
+	if v, ok := m["bucket_key_enabled"]; ok && (v == "true" || v == "1") {
+		config.BucketKeyEnabled = true
+	}
+	if v, ok := m["sse_customer_key"]; ok {
+		if key, err := base64.StdEncoding.DecodeString(v); err == nil {
+			config.SSECustomerKey = key
+		}
+	}
+	if v, ok := m["verify_bucket_encryption"]; ok && (v == "true" || v == "1") {
+		config.VerifyBucketEncryption = true
+	}
+	if v, ok := m["storage_class"]; ok {
+		config.StorageClass = v
+	}
+	if v, ok := m["tags"]; ok {
+		if q, err := url.ParseQuery(v); err == nil && len(q) > 0 {
+			config.Tags = make(map[string]string, len(q))
+			for k := range q {
+				config.Tags[k] = q.Get(k)
+			}
+		}
+	}
+	if v, ok := m["anonymous"]; ok && (v == "true" || v == "1") {
+		config.Anonymous = true
+	}
+	if v, ok := m["requester_pays"]; ok && (v == "true" || v == "1") {
+		config.RequesterPays = true
+	}
+	if v, ok := m["retry_mode"]; ok {
+		config.RetryMode = v
+	}
+	if v, ok := m["max_attempts"]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			config.MaxAttempts = n
+		}
+	}
+	if v, ok := m["max_backoff"]; ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			config.MaxBackoff = d
+		}
+	}
+	if v, ok := m["timeout"]; ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			config.Timeout = d
+		}
+	}
+	if v, ok := m["op_timeout"]; ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			config.OpTimeout = d
+		}
+	}
+	if v, ok := m["exists_get_fallback"]; ok && (v == "true" || v == "1") {
+		config.ExistsGetFallback = true
+	}
+	if v, ok := m["object_lock"]; ok && (v == "true" || v == "1") {
+		config.ObjectLock = true
+	}
+	if v, ok := m["create_bucket"]; ok && (v == "true" || v == "1") {
+		config.CreateBucket = true
+	}
+	if v, ok := m["bucket_acl"]; ok {
+		config.BucketACL = v
+	}
+	if v, ok := m["object_ownership"]; ok {
+		config.ObjectOwnership = v
+	}
 
 	return config
 }
 
+// configKeys documents the keys accepted by ConfigFromMap.
+var configKeys = []omnistorage.ConfigKey{
+	{Name: "bucket", Description: "Bucket name", Required: true},
+	{Name: "region", Description: "AWS region"},
+	{Name: "endpoint", Description: "Custom endpoint URL"},
+	{Name: "prefix", Description: "Key prefix"},
+	{Name: "access_key_id", Description: "AWS access key"},
+	{Name: "secret_access_key", Description: "AWS secret key", Secret: true},
+	{Name: "session_token", Description: "Session token", Secret: true},
+	{Name: "use_path_style", Description: "Use path-style addressing", Default: "false"},
+	{Name: "disable_ssl", Description: "Disable SSL", Default: "false"},
+	{Name: "part_size", Description: "Multipart upload part size in bytes", Default: "5242880"},
+	{Name: "concurrency", Description: "Number of concurrent operations", Default: "5"},
+	{Name: "copy_cutoff", Description: "Size in bytes above which Copy uses multipart copy", Default: "5368709120"},
+	{Name: "server_side_encryption", Description: `Encryption mode ("AES256", "aws:kms", "aws:kms:dsse")`},
+	{Name: "sse_kms_key_id", Description: "KMS key for SSE-KMS"},
+	{Name: "bucket_key_enabled", Description: "Use an S3 Bucket Key", Default: "false"},
+	{Name: "sse_customer_key", Description: "Base64-encoded SSE-C key", Secret: true},
+	{Name: "verify_bucket_encryption", Description: "Check the bucket default encryption", Default: "false"},
+	{Name: "storage_class", Description: "Storage class for new objects"},
+	{Name: "tags", Description: `Tags for new objects, URL-query encoded ("team=data&env=prod")`},
+	{Name: "anonymous", Description: "Send unsigned requests", Default: "false"},
+	{Name: "requester_pays", Description: "Accept requester-pays charges", Default: "false"},
+	{Name: "retry_mode", Description: `SDK retry mode ("standard" or "adaptive")`},
+	{Name: "max_attempts", Description: "Maximum attempts per request"},
+	{Name: "max_backoff", Description: `Maximum delay between retries (e.g., "5s")`},
+	{Name: "timeout", Description: `Per-request timeout (e.g., "30s")`},
+	{Name: "op_timeout", Description: `Per-operation timeout for Stat, List, Copy and other calls, including retries (e.g., "2m")`},
+	{Name: "exists_get_fallback", Description: "Let Exists fall back to a one-byte GET when HEAD and LIST are denied", Default: "false"},
+	{Name: "object_lock", Description: "The bucket has Object Lock enabled", Default: "false"},
+	{Name: "create_bucket", Description: "Create the bucket if it does not exist", Default: "false"},
+	{Name: "bucket_acl", Description: `Canned ACL of a created bucket (e.g., "private")`},
+	{Name: "object_ownership", Description: `Object ownership of a created bucket ("BucketOwnerEnforced", "BucketOwnerPreferred", "ObjectWriter")`},
+}
+
 // Validate checks if the configuration is valid.
 func (c Config) Validate() error {
 	if c.Bucket == "" {
 		return ErrBucketRequired
 	}
-	return nil
+	if err := c.validateRetry(); err != nil {
+		return err
+	}
+	if err := c.validateBucket(); err != nil {
+		return err
+	}
+	return c.encryption().Validate()
 }
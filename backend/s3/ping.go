@@ -0,0 +1,30 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Pinger
+var _ omnistorage.Pinger = (*Backend)(nil)
+
+// Ping checks that the bucket exists and is accessible with HeadBucket.
+func (b *Backend) Ping(ctx context.Context) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	_, err := b.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(b.config.Bucket),
+	})
+	return b.translateError(err, "ping", "")
+}
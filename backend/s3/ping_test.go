@@ -0,0 +1,26 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestPing(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+
+	if err := b.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if r := f.lastRequest("HEAD", ""); r.URL.Path != "/test-bucket" {
+		t.Errorf("Ping requested %s, want HeadBucket on /test-bucket", r.URL.Path)
+	}
+
+	missing := f.newBackend(b, func(c *Config) { c.Bucket = "missing-bucket" })
+	if err := missing.Ping(ctx); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Ping of missing bucket: err = %v, want ErrNotFound", err)
+	}
+}
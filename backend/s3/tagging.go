@@ -0,0 +1,157 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements omnistorage.Tagger.
+var _ omnistorage.Tagger = (*Backend)(nil)
+
+// Storage classes for new objects.
+const (
+	StorageClassStandard           = string(types.StorageClassStandard)
+	StorageClassStandardIA         = string(types.StorageClassStandardIa)
+	StorageClassOneZoneIA          = string(types.StorageClassOnezoneIa)
+	StorageClassIntelligentTiering = string(types.StorageClassIntelligentTiering)
+	StorageClassGlacierIR          = string(types.StorageClassGlacierIr)
+	StorageClassGlacier            = string(types.StorageClassGlacier)
+	StorageClassDeepArchive        = string(types.StorageClassDeepArchive)
+)
+
+// ErrObjectArchived is returned when reading an object in an archive
+// storage class (GLACIER, DEEP_ARCHIVE) that has not been restored.
+var ErrObjectArchived = errors.New("s3: object is archived and must be restored before reading")
+
+// ObjectInfo is returned by Stat. It adds S3-specific fields to
+// omnistorage.BasicObjectInfo, whose StorageClass method returns the
+// object's storage class, such as STANDARD or GLACIER.
+type ObjectInfo struct {
+	omnistorage.BasicObjectInfo
+
+	// Tags are the object's tags, or nil if it has none.
+	Tags map[string]string
+
+	// Lock is the object's Object Lock state. It is empty unless the
+	// bucket has Object Lock enabled and the caller may read it.
+	Lock omnistorage.ObjectLock
+}
+
+type tagsKey struct{}
+
+// WithStorageClass sets the storage class for a single object, overriding
+// Config.StorageClass. Use it to write archives directly to GLACIER,
+// DEEP_ARCHIVE or INTELLIGENT_TIERING. It is the same as
+// omnistorage.WithStorageClass.
+func WithStorageClass(class string) omnistorage.WriterOption {
+	return omnistorage.WithStorageClass(class)
+}
+
+// WithObjectTags sets tags for a single object, replacing Config.Tags.
+func WithObjectTags(tags map[string]string) omnistorage.WriterOption {
+	return omnistorage.WithWriterExtension(tagsKey{}, tags)
+}
+
+// writeStorageClass returns the storage class for a write.
+func (b *Backend) writeStorageClass(cfg *omnistorage.WriterConfig) string {
+	if cfg.StorageClass != "" {
+		return cfg.StorageClass
+	}
+	return b.config.StorageClass
+}
+
+// writeTags returns the tags for a write.
+func (b *Backend) writeTags(cfg *omnistorage.WriterConfig) map[string]string {
+	if v, ok := cfg.Extensions[tagsKey{}].(map[string]string); ok {
+		return v
+	}
+	return b.config.Tags
+}
+
+// encodeTags encodes tags as the URL query string S3 expects in the
+// x-amz-tagging header. It returns nil if there are no tags.
+func encodeTags(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	v := make(url.Values, len(tags))
+	for k, val := range tags {
+		v.Set(k, val)
+	}
+	return aws.String(v.Encode())
+}
+
+// Tags returns the tags of the object at path.
+func (b *Backend) Tags(ctx context.Context, p string) (map[string]string, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	result, err := b.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket:       aws.String(b.config.Bucket),
+		Key:          aws.String(b.fullKey(p)),
+		RequestPayer: b.config.requestPayer(),
+	})
+	if err != nil {
+		return nil, b.translateError(err, "tags", p)
+	}
+
+	if len(result.TagSet) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(result.TagSet))
+	for _, t := range result.TagSet {
+		tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return tags, nil
+}
+
+// SetTags replaces the tags of the object at path. An empty map removes
+// all tags.
+func (b *Backend) SetTags(ctx context.Context, p string, tags map[string]string) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext(ctx)
+	defer cancel()
+
+	key := aws.String(b.fullKey(p))
+	if len(tags) == 0 {
+		_, err := b.client.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{
+			Bucket: aws.String(b.config.Bucket),
+			Key:    key,
+		})
+		return b.translateError(err, "set tags", p)
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tagSet := make([]types.Tag, len(keys))
+	for i, k := range keys {
+		tagSet[i] = types.Tag{Key: aws.String(k), Value: aws.String(tags[k])}
+	}
+
+	_, err := b.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:       aws.String(b.config.Bucket),
+		Key:          key,
+		Tagging:      &types.Tagging{TagSet: tagSet},
+		RequestPayer: b.config.requestPayer(),
+	})
+	return b.translateError(err, "set tags", p)
+}
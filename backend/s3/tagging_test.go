@@ -0,0 +1,166 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func writeObject(t *testing.T, b *Backend, p, data string, opts ...omnistorage.WriterOption) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), p, opts...)
+	if err != nil {
+		t.Fatalf("NewWriter(%s): %v", p, err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatalf("Write(%s): %v", p, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%s): %v", p, err)
+	}
+}
+
+func readObject(t *testing.T, b *Backend, p string) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewReader(%s): %v", p, err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll(%s): %v", p, err)
+	}
+	return string(data)
+}
+
+func statS3(t *testing.T, b *Backend, p string) *ObjectInfo {
+	t.Helper()
+	info, err := b.Stat(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Stat(%s): %v", p, err)
+	}
+	s3info, ok := info.(*ObjectInfo)
+	if !ok {
+		t.Fatalf("Stat returned %T, want *ObjectInfo", info)
+	}
+	return s3info
+}
+
+func TestStorageClass(t *testing.T) {
+	f, b := newFakeS3(t)
+
+	writeObject(t, b, "standard.txt", "a")
+	if got := statS3(t, b, "standard.txt").StorageClass(); got != StorageClassStandard {
+		t.Errorf("default StorageClass = %q, want %q", got, StorageClassStandard)
+	}
+
+	writeObject(t, b, "archive.tar", "a", WithStorageClass(StorageClassDeepArchive))
+	if got := f.lastRequest("PUT", "").Header.Get("X-Amz-Storage-Class"); got != StorageClassDeepArchive {
+		t.Errorf("storage class header = %q", got)
+	}
+	if got := statS3(t, b, "archive.tar").StorageClass(); got != StorageClassDeepArchive {
+		t.Errorf("StorageClass = %q, want %q", got, StorageClassDeepArchive)
+	}
+
+	it := f.newBackend(b, func(c *Config) { c.StorageClass = StorageClassIntelligentTiering })
+	writeObject(t, it, "tiered.bin", "a")
+	if got := statS3(t, it, "tiered.bin").StorageClass(); got != StorageClassIntelligentTiering {
+		t.Errorf("config StorageClass = %q, want %q", got, StorageClassIntelligentTiering)
+	}
+	if err := it.Copy(context.Background(), "standard.txt", "copied.txt"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if got := statS3(t, it, "copied.txt").StorageClass(); got != StorageClassIntelligentTiering {
+		t.Errorf("copied StorageClass = %q, want %q", got, StorageClassIntelligentTiering)
+	}
+}
+
+func TestObjectTags(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+
+	writeObject(t, b, "untagged.txt", "a")
+	if tags := statS3(t, b, "untagged.txt").Tags; tags != nil {
+		t.Errorf("Tags = %v, want nil", tags)
+	}
+
+	writeObject(t, b, "tagged.txt", "a", WithObjectTags(map[string]string{"team": "data", "env": "prod & test"}))
+	header, _ := url.ParseQuery(f.lastRequest("PUT", "").Header.Get("X-Amz-Tagging"))
+	if header.Get("env") != "prod & test" {
+		t.Errorf("tagging header = %v", header)
+	}
+
+	info := statS3(t, b, "tagged.txt")
+	if info.Tags["team"] != "data" || info.Tags["env"] != "prod & test" {
+		t.Errorf("Stat Tags = %v", info.Tags)
+	}
+
+	if err := b.SetTags(ctx, "tagged.txt", map[string]string{"owner": "ops"}); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	tags, err := b.Tags(ctx, "tagged.txt")
+	if err != nil {
+		t.Fatalf("Tags: %v", err)
+	}
+	if len(tags) != 1 || tags["owner"] != "ops" {
+		t.Errorf("Tags after SetTags = %v", tags)
+	}
+
+	if err := b.SetTags(ctx, "tagged.txt", nil); err != nil {
+		t.Fatalf("SetTags(nil): %v", err)
+	}
+	if tags, _ := b.Tags(ctx, "tagged.txt"); tags != nil {
+		t.Errorf("Tags after clearing = %v", tags)
+	}
+	if r := f.lastRequest(http.MethodDelete, "tagging"); r == nil {
+		t.Error("expected DeleteObjectTagging request")
+	}
+
+	if err := b.SetTags(ctx, "missing.txt", map[string]string{"a": "b"}); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("SetTags on missing object: err = %v, want ErrNotFound", err)
+	}
+
+	cfg := ConfigFromMap(map[string]string{"bucket": "b", "tags": "team=data&env=prod", "storage_class": "GLACIER"})
+	if cfg.Tags["team"] != "data" || cfg.Tags["env"] != "prod" || cfg.StorageClass != StorageClassGlacier {
+		t.Errorf("ConfigFromMap: tags %v, class %q", cfg.Tags, cfg.StorageClass)
+	}
+}
+
+func TestObjectAttributes(t *testing.T) {
+	_, b := newFakeS3(t)
+	opts := []omnistorage.WriterOption{
+		omnistorage.WithCacheControl("max-age=60"),
+		omnistorage.WithContentEncoding("gzip"),
+		omnistorage.WithContentDisposition(`attachment; filename="a.gz"`),
+		omnistorage.WithStorageClass(StorageClassStandardIA),
+	}
+
+	writeObject(t, b, "managed.gz", "a", opts...)
+	// Checksums send the object in a single PutObject
+	writeObject(t, b, "verified.gz", "a", append(opts, omnistorage.WithContentMD5("0cc175b9c0f1b6a831c399e269772661"))...)
+
+	for _, p := range []string{"managed.gz", "verified.gz"} {
+		var info omnistorage.ExtendedObjectInfo = statS3(t, b, p)
+		if got := info.CacheControl(); got != "max-age=60" {
+			t.Errorf("%s CacheControl = %q, want %q", p, got, "max-age=60")
+		}
+		if got := info.ContentEncoding(); got != "gzip" {
+			t.Errorf("%s ContentEncoding = %q, want %q", p, got, "gzip")
+		}
+		if got := info.ContentDisposition(); got != `attachment; filename="a.gz"` {
+			t.Errorf("%s ContentDisposition = %q", p, got)
+		}
+		if got := info.StorageClass(); got != StorageClassStandardIA {
+			t.Errorf("%s StorageClass = %q, want %q", p, got, StorageClassStandardIA)
+		}
+		if got := info.ETag(); got != "0cc175b9c0f1b6a831c399e269772661" {
+			t.Errorf("%s ETag = %q, want the MD5 of the content", p, got)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package s3
+
+import (
+	"context"
+	"io"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure Backend implements Uploader.
+var _ omnistorage.Uploader = (*Backend)(nil)
+
+// Upload uploads the content of r to p with the transfer manager, reading
+// r a part at a time, so that large objects are not held in memory as
+// they are by NewWriter. Objects with a checksum option are buffered, as
+// with NewWriter, so that mismatched data is never uploaded.
+func (b *Backend) Upload(ctx context.Context, p string, r io.Reader, opts ...omnistorage.WriterOption) error {
+	wc, err := b.NewWriter(ctx, p, opts...)
+	if err != nil {
+		return err
+	}
+	w := wc.(*s3Writer)
+	if w.verify != nil {
+		// Nothing is uploaded until Close
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+	w.closed = true
+	return w.upload(r)
+}
@@ -0,0 +1,46 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestUpload(t *testing.T) {
+	f, b := newFakeS3(t)
+	ctx := context.Background()
+
+	big := strings.Repeat("x", int(b.config.PartSize)+1)
+	if err := b.Upload(ctx, "big.txt", strings.NewReader(big), omnistorage.WithContentType("text/plain")); err != nil {
+		t.Fatalf("Upload error = %v", err)
+	}
+	if got := readObject(t, b, "big.txt"); got != big {
+		t.Errorf("big.txt has %d bytes, want %d", len(got), len(big))
+	}
+	if r := f.lastRequest(http.MethodPost, "uploads"); r.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("Content-Type = %q, want text/plain", r.Header.Get("Content-Type"))
+	}
+
+	// A failed read uploads nothing
+	puts := f.count(http.MethodPut)
+	errRead := errors.New("read failed")
+	md5sum := omnistorage.HashBytes([]byte("data"), omnistorage.HashMD5)
+	if err := b.Upload(ctx, "bad.txt", iotest.ErrReader(errRead), omnistorage.WithContentMD5(md5sum)); !errors.Is(err, errRead) {
+		t.Errorf("Upload error = %v, want the read error", err)
+	}
+	if n := f.count(http.MethodPut); n != puts {
+		t.Errorf("%d PUT requests sent for a failed read", n-puts)
+	}
+
+	if err := b.Upload(ctx, "checked.txt", strings.NewReader("data"), omnistorage.WithContentMD5(md5sum)); err != nil {
+		t.Fatalf("Upload with checksum error = %v", err)
+	}
+	if got := f.lastRequest(http.MethodPut, "").Header.Get("Content-Md5"); got != *base64Sum(md5sum) {
+		t.Errorf("Content-MD5 = %q, want %q", got, *base64Sum(md5sum))
+	}
+}
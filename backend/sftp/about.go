@@ -0,0 +1,57 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/grokify/omnistorage"
+	"github.com/pkg/sftp"
+)
+
+// Ensure Backend implements omnistorage.Abouter
+var _ omnistorage.Abouter = (*Backend)(nil)
+
+// statVFSExtension is the SFTP extension that reports filesystem
+// statistics.
+const statVFSExtension = "statvfs@openssh.com"
+
+// About reports the size and free space of the remote filesystem holding
+// the root directory, using the statvfs@openssh.com extension. Used is
+// the space used on the whole filesystem, and Objects is -1. Returns
+// ErrNotSupported if the server does not support the extension.
+func (b *Backend) About(ctx context.Context) (omnistorage.Usage, error) {
+	if err := b.checkClosed(); err != nil {
+		return omnistorage.Usage{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return omnistorage.Usage{}, err
+	}
+
+	root := b.fullPath("")
+	if root == "" {
+		root = "."
+	}
+	var usage omnistorage.Usage
+	err := b.do(ctx, func(c *sftp.Client) error {
+		if _, ok := c.HasExtension(statVFSExtension); !ok {
+			return fmt.Errorf("sftp: about: %w", omnistorage.ErrNotSupported)
+		}
+		st, err := c.StatVFS(root)
+		var status *sftp.StatusError
+		if errors.As(err, &status) && status.FxCode() == sftp.ErrSSHFxOpUnsupported {
+			return fmt.Errorf("sftp: about: %w", omnistorage.ErrNotSupported)
+		}
+		if err != nil {
+			return b.translateError(err, root)
+		}
+		usage = omnistorage.Usage{
+			Total:   int64(st.Blocks * st.Frsize),
+			Used:    int64((st.Blocks - st.Bfree) * st.Frsize),
+			Free:    int64(st.Bavail * st.Frsize),
+			Objects: -1,
+		}
+		return nil
+	})
+	return usage, err
+}
@@ -0,0 +1,36 @@
+package sftp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/pkg/sftp"
+)
+
+// statVFSCmder answers statvfs requests with a fixed 1000-block
+// filesystem of 4 KiB blocks.
+type statVFSCmder struct {
+	sftp.FileCmder
+}
+
+func (statVFSCmder) StatVFS(*sftp.Request) (*sftp.StatVFS, error) {
+	return &sftp.StatVFS{Bsize: 4096, Frsize: 4096, Blocks: 1000, Bfree: 300, Bavail: 250}, nil
+}
+
+func TestAbout(t *testing.T) {
+	ctx := context.Background()
+
+	s := newMemServer()
+	s.handlers.FileCmd = statVFSCmder{s.handlers.FileCmd}
+	b := newTestBackend(t, s, Config{Root: "/data"})
+	writeFile(t, b, "a.txt", "data")
+	usage, err := b.About(ctx)
+	if err != nil {
+		t.Fatalf("About: %v", err)
+	}
+	want := omnistorage.Usage{Total: 1000 * 4096, Used: 700 * 4096, Free: 250 * 4096, Objects: -1}
+	if usage != want {
+		t.Errorf("About = %+v, want %+v", usage, want)
+	}
+}
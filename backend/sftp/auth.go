@@ -0,0 +1,154 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrNoAuthMethod is returned when no authentication method is configured.
+var ErrNoAuthMethod = errors.New("sftp: no authentication method provided (password, key_file, signers or use_agent required)")
+
+// certSuffix is appended to a key file path to find its OpenSSH
+// certificate, following the ssh client convention.
+const certSuffix = "-cert.pub"
+
+// authenticator builds SSH auth methods for each new connection.
+//
+// All public keys (certificates, key files, explicit signers and agent
+// keys) are offered through a single publickey method, because the SSH
+// client tries each method type only once.
+type authenticator struct {
+	password    string
+	signers     []ssh.Signer
+	agentSocket string
+}
+
+// newAuthenticator loads the key files and certificates named in cfg.
+func newAuthenticator(cfg Config) (*authenticator, error) {
+	a := &authenticator{password: cfg.Password}
+
+	keyFiles := cfg.KeyFiles
+	if cfg.KeyFile != "" {
+		keyFiles = append([]string{cfg.KeyFile}, keyFiles...)
+	}
+	for i, keyFile := range keyFiles {
+		certFile := ""
+		if i == 0 && cfg.KeyFile != "" {
+			certFile = cfg.CertFile
+		}
+		signers, err := loadKeyFile(keyFile, certFile, cfg.KeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: loading key file %s: %w", keyFile, err)
+		}
+		a.signers = append(a.signers, signers...)
+	}
+
+	a.signers = append(a.signers, cfg.Signers...)
+
+	if cfg.UseAgent {
+		a.agentSocket = cfg.AgentSocket
+		if a.agentSocket == "" {
+			a.agentSocket = os.Getenv("SSH_AUTH_SOCK")
+		}
+		if a.agentSocket == "" {
+			return nil, errors.New("sftp: use_agent is set but SSH_AUTH_SOCK is empty")
+		}
+	}
+
+	if a.password == "" && len(a.signers) == 0 && a.agentSocket == "" {
+		return nil, ErrNoAuthMethod
+	}
+	return a, nil
+}
+
+// methods returns the auth methods for one connection attempt. The returned
+// cleanup function must be called once the SSH handshake is complete.
+func (a *authenticator) methods() ([]ssh.AuthMethod, func(), error) {
+	cleanup := func() {}
+	signers := a.signers
+
+	if a.agentSocket != "" {
+		agentConn, err := net.Dial("unix", a.agentSocket)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sftp: connecting to SSH agent: %w", err)
+		}
+		cleanup = func() { _ = agentConn.Close() }
+
+		agentSigners, err := agent.NewClient(agentConn).Signers()
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("sftp: listing SSH agent keys: %w", err)
+		}
+		signers = append(append([]ssh.Signer(nil), signers...), agentSigners...)
+	}
+
+	var methods []ssh.AuthMethod
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if a.password != "" {
+		methods = append(methods, ssh.Password(a.password))
+	}
+	return methods, cleanup, nil
+}
+
+// loadKeyFile parses a private key file. If a certificate is found, either
+// certFile or the key file path with certSuffix, the certificate signer is
+// returned first, followed by the plain key.
+func loadKeyFile(keyFile, certFile, passphrase string) ([]ssh.Signer, error) {
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(keyData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	explicit := certFile != ""
+	if !explicit {
+		certFile = keyFile + certSuffix
+	}
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return []ssh.Signer{signer}, nil
+		}
+		return nil, fmt.Errorf("reading certificate: %w", err)
+	}
+
+	certSigner, err := certSignerFor(signer, certData)
+	if err != nil {
+		return nil, err
+	}
+	return []ssh.Signer{certSigner, signer}, nil
+}
+
+// certSignerFor pairs signer with an OpenSSH certificate in authorized_keys
+// format.
+func certSignerFor(signer ssh.Signer, certData []byte) (ssh.Signer, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certData)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("parsing certificate: not an OpenSSH certificate")
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate does not match key: %w", err)
+	}
+	return certSigner, nil
+}
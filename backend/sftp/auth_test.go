@@ -0,0 +1,192 @@
+package sftp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func writeTestKey(t *testing.T, dir, name string) (string, ssh.Signer) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, signer
+}
+
+func writeTestCert(t *testing.T, path string, key ssh.Signer) {
+	t.Helper()
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:             key.PublicKey(),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"deploy"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, ssh.MarshalAuthorizedKey(cert), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func isCert(s ssh.Signer) bool {
+	_, ok := s.PublicKey().(*ssh.Certificate)
+	return ok
+}
+
+func TestAuthenticatorKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	key1, _ := writeTestKey(t, dir, "id_one")
+	key2, signer2 := writeTestKey(t, dir, "id_two")
+	writeTestCert(t, key2+certSuffix, signer2)
+
+	a, err := newAuthenticator(Config{KeyFile: key1, KeyFiles: []string{key2}})
+	if err != nil {
+		t.Fatalf("newAuthenticator: %v", err)
+	}
+
+	// key1, then key2's certificate, then key2.
+	if len(a.signers) != 3 {
+		t.Fatalf("signers = %d, want 3", len(a.signers))
+	}
+	if isCert(a.signers[0]) || !isCert(a.signers[1]) || isCert(a.signers[2]) {
+		t.Error("unexpected signer order")
+	}
+	if !bytes.Equal(a.signers[2].PublicKey().Marshal(), signer2.PublicKey().Marshal()) {
+		t.Error("last signer is not key2")
+	}
+}
+
+func TestAuthenticatorCertFile(t *testing.T) {
+	dir := t.TempDir()
+	key, signer := writeTestKey(t, dir, "id")
+	cert := filepath.Join(dir, "custom-cert.pub")
+	writeTestCert(t, cert, signer)
+
+	a, err := newAuthenticator(Config{KeyFile: key, CertFile: cert})
+	if err != nil {
+		t.Fatalf("newAuthenticator: %v", err)
+	}
+	if len(a.signers) != 2 || !isCert(a.signers[0]) {
+		t.Errorf("expected certificate signer first, got %d signers", len(a.signers))
+	}
+
+	// A certificate for a different key is rejected.
+	_, other := writeTestKey(t, dir, "other")
+	writeTestCert(t, cert, other)
+	if _, err := newAuthenticator(Config{KeyFile: key, CertFile: cert}); err == nil {
+		t.Error("expected error for mismatched certificate")
+	}
+
+	// An explicit certificate must exist.
+	if _, err := newAuthenticator(Config{KeyFile: key, CertFile: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected error for missing certificate")
+	}
+}
+
+func TestAuthenticatorAgent(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unix socket paths are limited in length; keep it short.
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "s")
+
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, c)
+				_ = c.Close()
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	a, err := newAuthenticator(Config{UseAgent: true})
+	if err != nil {
+		t.Fatalf("newAuthenticator: %v", err)
+	}
+
+	methods, cleanup, err := a.methods()
+	if err != nil {
+		t.Fatalf("methods: %v", err)
+	}
+	defer cleanup()
+	if len(methods) != 1 {
+		t.Errorf("methods = %d, want 1", len(methods))
+	}
+}
+
+func TestAuthenticatorErrors(t *testing.T) {
+	if _, err := newAuthenticator(Config{}); !errors.Is(err, ErrNoAuthMethod) {
+		t.Errorf("no auth: err = %v, want ErrNoAuthMethod", err)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if _, err := newAuthenticator(Config{UseAgent: true}); err == nil {
+		t.Error("expected error for agent without socket")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" a, ,b ,c")
+	want := []string{"a", "b", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("splitList = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("splitList[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -15,6 +15,14 @@
 //	    User:    "username",
 //	    KeyFile: "/path/to/id_rsa",
 //	})
+//
+// With keys held by an SSH agent:
+//
+//	backend, err := sftp.New(sftp.Config{
+//	    Host:     "example.com",
+//	    User:     "username",
+//	    UseAgent: true,
+//	})
 package sftp
 
 import (
@@ -26,107 +34,131 @@ import (
 	"net"
 	"os"
 	"path"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/knownhosts"
 
 	"github.com/grokify/omnistorage"
 )
 
 func init() {
-	omnistorage.Register("sftp", NewFromConfig)
+	omnistorage.Register("sftp", NewFromConfig,
+		omnistorage.WithDescription("Remote filesystem over SSH File Transfer Protocol"),
+		omnistorage.WithConfigKeys(configKeys...),
+		omnistorage.WithFeatures((&Backend{}).Features()))
 }
 
 // Backend implements omnistorage.ExtendedBackend for SFTP.
+//
+// Operations run on a pool of up to Config.Concurrency SSH connections, so
+// concurrent callers do not serialize on a single session. Connections that
+// fail are discarded and the operation is retried on a fresh connection,
+// with exponential backoff, up to Config.ReconnectAttempts times.
 type Backend struct {
-	sshClient  *ssh.Client
-	sftpClient *sftp.Client
-	config     Config
-	closed     bool
-	mu         sync.RWMutex
+	pool      *pool
+	config    Config
+	opTimeout time.Duration
+	closed    bool
+	mu        sync.RWMutex
 }
 
 // New creates a new SFTP backend with the given configuration.
+// It connects once to verify the configuration and keeps that connection
+// in the pool.
 func New(cfg Config) (*Backend, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
+	cfg = cfg.withDefaults()
 
-	// Set defaults
-	if cfg.Port == 0 {
-		cfg.Port = 22
-	}
-	if cfg.Timeout == 0 {
-		cfg.Timeout = 30
+	auth, err := newAuthenticator(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build SSH auth methods
-	var authMethods []ssh.AuthMethod
+	// Build SSH config.
+	// Host keys are verified against known_hosts and/or a pinned fingerprint
+	// to prevent man-in-the-middle attacks, unless verification is
+	// explicitly disabled.
+	hostKeyCallback, err := newHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	// Password auth
-	if cfg.Password != "" {
-		authMethods = append(authMethods, ssh.Password(cfg.Password))
+	sshConfig := &ssh.ClientConfig{
+		User:            cfg.User,
+		Timeout:         time.Duration(cfg.Timeout) * time.Second,
+		HostKeyCallback: hostKeyCallback,
 	}
 
-	// Key file auth
-	if cfg.KeyFile != "" {
-		keyAuth, err := keyFileAuth(cfg.KeyFile, cfg.KeyPassphrase)
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	return newWithDialer(cfg, sshDialer(addr, sshConfig, auth, cfg.clientOptions()))
+}
+
+// sshDialer returns a dialFunc that opens an SSH connection and starts an
+// SFTP session on it.
+func sshDialer(addr string, sshConfig *ssh.ClientConfig, auth *authenticator, clientOptions []sftp.ClientOption) dialFunc {
+	return func(ctx context.Context) (*conn, error) {
+		methods, cleanup, err := auth.methods()
 		if err != nil {
-			return nil, fmt.Errorf("sftp: loading key file: %w", err)
+			return nil, err
 		}
-		authMethods = append(authMethods, keyAuth)
-	}
+		defer cleanup()
 
-	if len(authMethods) == 0 {
-		return nil, fmt.Errorf("sftp: no authentication method provided (password or key_file required)")
-	}
+		config := *sshConfig
+		config.Auth = methods
 
-	// Build SSH config.
-	// Host key verification is performed using the user's known_hosts file.
-	// This prevents man-in-the-middle attacks by ensuring the server's host
-	// key matches a trusted entry.
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("sftp: could not determine user home directory for known_hosts: %w", err)
-	}
-	knownHostsPath := path.Join(homeDir, ".ssh", "known_hosts")
-	hostKeyCallback, err := knownhosts.New(knownHostsPath)
-	if err != nil {
-		return nil, fmt.Errorf("sftp: could not load known_hosts file (%s): %w", knownHostsPath, err)
+		d := net.Dialer{Timeout: config.Timeout}
+		netConn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: SSH connection failed: %w", err)
+		}
+
+		sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, &config)
+		if err != nil {
+			_ = netConn.Close()
+			return nil, fmt.Errorf("sftp: SSH connection failed: %w", err)
+		}
+		sshClient := ssh.NewClient(sshConn, chans, reqs)
+
+		sftpClient, err := sftp.NewClient(sshClient, clientOptions...)
+		if err != nil {
+			if closeErr := sshClient.Close(); closeErr != nil {
+				return nil, fmt.Errorf("sftp: SFTP session failed: %w (also failed to close SSH: %v)", err, closeErr)
+			}
+			return nil, fmt.Errorf("sftp: SFTP session failed: %w", err)
+		}
+
+		return &conn{client: sftpClient, closer: sshClient}, nil
 	}
+}
 
-	sshConfig := &ssh.ClientConfig{
-		User:            cfg.User,
-		Auth:            authMethods,
-		Timeout:         time.Duration(cfg.Timeout) * time.Second,
-		HostKeyCallback: hostKeyCallback,
+// newWithDialer creates a backend that opens connections with dial.
+func newWithDialer(cfg Config, dial dialFunc) (*Backend, error) {
+	cfg = cfg.withDefaults()
+
+	healthAfter := time.Duration(cfg.HealthCheckInterval) * time.Second
+	if cfg.HealthCheckInterval < 0 {
+		healthAfter = 0
 	}
 
-	// Connect
-	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	sshClient, err := ssh.Dial("tcp", addr, sshConfig)
-	if err != nil {
-		return nil, fmt.Errorf("sftp: SSH connection failed: %w", err)
+	b := &Backend{
+		pool:      newPool(dial, cfg.Concurrency, cfg.ReconnectAttempts, healthAfter),
+		config:    cfg,
+		opTimeout: time.Duration(cfg.OpTimeout) * time.Second,
 	}
 
-	// Create SFTP client
-	sftpClient, err := sftp.NewClient(sshClient)
+	// Connect eagerly so configuration errors surface here.
+	c, err := b.pool.get(context.Background())
 	if err != nil {
-		if closeErr := sshClient.Close(); closeErr != nil {
-			return nil, fmt.Errorf("sftp: SFTP session failed: %w (also failed to close SSH: %v)", err, closeErr)
-		}
-		return nil, fmt.Errorf("sftp: SFTP session failed: %w", err)
+		return nil, err
 	}
+	b.pool.put(c, false)
 
-	return &Backend{
-		sshClient:  sshClient,
-		sftpClient: sftpClient,
-		config:     cfg,
-	}, nil
+	return b, nil
 }
 
 // NewFromConfig creates a new SFTP backend from a config map.
@@ -136,24 +168,140 @@ func NewFromConfig(configMap map[string]string) (omnistorage.Backend, error) {
 	return New(cfg)
 }
 
-// keyFileAuth creates an SSH auth method from a private key file.
-func keyFileAuth(keyFile, passphrase string) (ssh.AuthMethod, error) {
-	keyData, err := os.ReadFile(keyFile)
-	if err != nil {
-		return nil, fmt.Errorf("reading key file: %w", err)
+// do runs op on a pooled connection. If op fails because the connection
+// broke, the connection is discarded and op is retried on a new one.
+// op must be safe to repeat.
+func (b *Backend) do(ctx context.Context, op func(*sftp.Client) error) error {
+	for attempt := 0; ; attempt++ {
+		c, err := b.pool.get(ctx)
+		if err != nil {
+			return b.poolError(err)
+		}
+
+		err = b.run(c, op)
+		if errors.Is(err, errOpTimeout) {
+			b.pool.put(c, true)
+			return err
+		}
+		broken := isConnError(err)
+		b.pool.put(c, broken)
+
+		if !broken || attempt >= b.config.ReconnectAttempts {
+			return err
+		}
+		if err := sleep(ctx, backoff(attempt)); err != nil {
+			return err
+		}
 	}
+}
 
-	var signer ssh.Signer
-	if passphrase != "" {
-		signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
-	} else {
-		signer, err = ssh.ParsePrivateKey(keyData)
+// errOpTimeout is returned by an operation that exceeded Config.OpTimeout.
+var errOpTimeout = fmt.Errorf("sftp: operation timed out: %w", omnistorage.ErrTimeout)
+
+// run runs op on c. If op exceeds the operation timeout, it is abandoned
+// and run returns errOpTimeout; the caller must then discard c, since the
+// SFTP client cannot cancel a request and closing the connection is what
+// fails op.
+func (b *Backend) run(c *conn, op func(*sftp.Client) error) error {
+	if b.opTimeout <= 0 {
+		return op(c.client)
+	}
+	done := make(chan error, 1)
+	go func() { done <- op(c.client) }()
+	timer := time.NewTimer(b.opTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return errOpTimeout
 	}
-	if err != nil {
-		return nil, fmt.Errorf("parsing private key: %w", err)
+}
+
+// open runs op on a pooled connection and keeps the connection checked out
+// for the lifetime of the returned file.
+func (b *Backend) open(ctx context.Context, op func(*sftp.Client) (*sftp.File, error)) (*pooledFile, error) {
+	for attempt := 0; ; attempt++ {
+		c, err := b.pool.get(ctx)
+		if err != nil {
+			return nil, b.poolError(err)
+		}
+
+		f, err := op(c.client)
+		if err == nil {
+			return &pooledFile{File: f, pool: b.pool, conn: c}, nil
+		}
+
+		broken := isConnError(err)
+		b.pool.put(c, broken)
+
+		if !broken || attempt >= b.config.ReconnectAttempts {
+			return nil, err
+		}
+		if err := sleep(ctx, backoff(attempt)); err != nil {
+			return nil, err
+		}
 	}
+}
 
-	return ssh.PublicKeys(signer), nil
+// poolError maps pool errors to omnistorage errors.
+func (b *Backend) poolError(err error) error {
+	if errors.Is(err, errPoolClosed) {
+		return omnistorage.ErrBackendClosed
+	}
+	return err
+}
+
+// pooledFile returns its connection to the pool when closed.
+type pooledFile struct {
+	*sftp.File
+	pool   *pool
+	conn   *conn
+	broken bool
+	closed bool
+}
+
+func (f *pooledFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	if err != nil && err != io.EOF && isConnError(err) {
+		f.broken = true
+	}
+	return n, err
+}
+
+func (f *pooledFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	if isConnError(err) {
+		f.broken = true
+	}
+	return n, err
+}
+
+func (f *pooledFile) ReadFrom(r io.Reader) (int64, error) {
+	n, err := f.File.ReadFrom(r)
+	if isConnError(err) {
+		f.broken = true
+	}
+	return n, err
+}
+
+func (f *pooledFile) WriteTo(w io.Writer) (int64, error) {
+	n, err := f.File.WriteTo(w)
+	if isConnError(err) {
+		f.broken = true
+	}
+	return n, err
+}
+
+func (f *pooledFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	err := f.File.Close()
+	f.pool.put(f.conn, f.broken || isConnError(err))
+	return err
 }
 
 // NewWriter creates a writer for the given path.
@@ -168,19 +316,23 @@ func (b *Backend) NewWriter(ctx context.Context, p string, opts ...omnistorage.W
 
 	fullPath := b.fullPath(p)
 
-	// Ensure parent directory exists
-	dir := path.Dir(fullPath)
-	if err := b.sftpClient.MkdirAll(dir); err != nil {
-		return nil, fmt.Errorf("sftp: creating directory: %w", err)
-	}
-
-	// Create or truncate file
-	f, err := b.sftpClient.Create(fullPath)
+	f, err := b.open(ctx, func(c *sftp.Client) (*sftp.File, error) {
+		// Ensure parent directory exists
+		if err := c.MkdirAll(path.Dir(fullPath)); err != nil {
+			return nil, fmt.Errorf("sftp: creating directory: %w", err)
+		}
+		// Create or truncate file
+		return c.Create(fullPath)
+	})
 	if err != nil {
 		return nil, b.translateError(err, p)
 	}
 
-	return f, nil
+	cfg := omnistorage.ApplyWriterOptions(opts...)
+	if size := b.config.BufferSize; size > 0 {
+		return b.withVerify(ctx, newBufferedWriter(f, size), p, cfg), nil
+	}
+	return b.withVerify(ctx, f, p, cfg), nil
 }
 
 // NewReader creates a reader for the given path.
@@ -196,7 +348,9 @@ func (b *Backend) NewReader(ctx context.Context, p string, opts ...omnistorage.R
 	fullPath := b.fullPath(p)
 	cfg := omnistorage.ApplyReaderOptions(opts...)
 
-	f, err := b.sftpClient.Open(fullPath)
+	f, err := b.open(ctx, func(c *sftp.Client) (*sftp.File, error) {
+		return c.Open(fullPath)
+	})
 	if err != nil {
 		return nil, b.translateError(err, p)
 	}
@@ -211,12 +365,20 @@ func (b *Backend) NewReader(ctx context.Context, p string, opts ...omnistorage.R
 		}
 	}
 
+	var r io.ReadCloser = f
+	if size := b.config.BufferSize; size > 0 {
+		if cfg.Limit > 0 && cfg.Limit < int64(size) {
+			size = int(cfg.Limit)
+		}
+		r = newBufferedReader(f, size)
+	}
+
 	// Handle limit
 	if cfg.Limit > 0 {
-		return &limitedReader{f, cfg.Limit}, nil
+		return &limitedReader{r, cfg.Limit}, nil
 	}
 
-	return f, nil
+	return r, nil
 }
 
 // limitedReader wraps a reader with a byte limit.
@@ -252,7 +414,10 @@ func (b *Backend) Exists(ctx context.Context, p string) (bool, error) {
 	}
 
 	fullPath := b.fullPath(p)
-	_, err := b.sftpClient.Stat(fullPath)
+	err := b.do(ctx, func(c *sftp.Client) error {
+		_, err := c.Stat(fullPath)
+		return err
+	})
 	if err != nil {
 		if os.IsNotExist(err) {
 			return false, nil
@@ -273,7 +438,9 @@ func (b *Backend) Delete(ctx context.Context, p string) error {
 	}
 
 	fullPath := b.fullPath(p)
-	err := b.sftpClient.Remove(fullPath)
+	err := b.do(ctx, func(c *sftp.Client) error {
+		return c.Remove(fullPath)
+	})
 	if err != nil {
 		// Delete is idempotent
 		if os.IsNotExist(err) {
@@ -284,7 +451,7 @@ func (b *Backend) Delete(ctx context.Context, p string) error {
 	return nil
 }
 
-// List lists paths with the given prefix.
+// List lists the paths under the directory prefix, relative to it.
 func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	if err := b.checkClosed(); err != nil {
 		return nil, err
@@ -294,20 +461,26 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 		return nil, err
 	}
 
-	// Determine the directory to list
-	fullPrefix := b.fullPath(prefix)
-	dir := fullPrefix
-	namePrefix := ""
-
-	// If prefix is not a directory, use parent dir and filter by name
-	info, err := b.sftpClient.Stat(fullPrefix)
-	if err != nil || !info.IsDir() {
-		dir = path.Dir(fullPrefix)
-		namePrefix = path.Base(fullPrefix)
+	dir := b.fullPath(omnistorage.CleanPrefix(prefix))
+	if dir == "" {
+		dir = "."
 	}
 
 	var paths []string
-	err = b.walkDir(ctx, dir, namePrefix, &paths)
+	err := b.do(ctx, func(c *sftp.Client) error {
+		paths = nil
+
+		// A prefix that names a file lists nothing
+		info, err := c.Stat(dir)
+		if isConnError(err) {
+			return err
+		}
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+
+		return b.walkDir(ctx, c, dir, "", &paths)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -315,12 +488,14 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	return paths, nil
 }
 
-func (b *Backend) walkDir(ctx context.Context, dir, namePrefix string, paths *[]string) error {
+// walkDir appends the paths of the files under dir to paths, joined to
+// rel, the path of dir relative to the listed prefix.
+func (b *Backend) walkDir(ctx context.Context, c *sftp.Client, dir, rel string, paths *[]string) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	entries, err := b.sftpClient.ReadDir(dir)
+	entries, err := c.ReadDir(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -329,17 +504,12 @@ func (b *Backend) walkDir(ctx context.Context, dir, namePrefix string, paths *[]
 	}
 
 	for _, entry := range entries {
-		if namePrefix != "" && !strings.HasPrefix(entry.Name(), namePrefix) {
-			continue
-		}
-
 		entryPath := path.Join(dir, entry.Name())
-		relPath := strings.TrimPrefix(entryPath, b.config.Root)
-		relPath = strings.TrimPrefix(relPath, "/")
+		relPath := path.Join(rel, entry.Name())
 
 		if entry.IsDir() {
 			// Recurse into subdirectories
-			if err := b.walkDir(ctx, entryPath, "", paths); err != nil {
+			if err := b.walkDir(ctx, c, entryPath, relPath, paths); err != nil {
 				return err
 			}
 		} else {
@@ -351,6 +521,7 @@ func (b *Backend) walkDir(ctx context.Context, dir, namePrefix string, paths *[]
 }
 
 // Close releases any resources held by the backend.
+// Readers and writers still open keep their connections until closed.
 func (b *Backend) Close() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -361,20 +532,8 @@ func (b *Backend) Close() error {
 
 	b.closed = true
 
-	var errs []error
-	if b.sftpClient != nil {
-		if err := b.sftpClient.Close(); err != nil {
-			errs = append(errs, err)
-		}
-	}
-	if b.sshClient != nil {
-		if err := b.sshClient.Close(); err != nil {
-			errs = append(errs, err)
-		}
-	}
-
-	if len(errs) > 0 {
-		return fmt.Errorf("sftp: close errors: %v", errs)
+	if err := b.pool.close(); err != nil {
+		return fmt.Errorf("sftp: close errors: %w", err)
 	}
 	return nil
 }
@@ -390,7 +549,12 @@ func (b *Backend) Stat(ctx context.Context, p string) (omnistorage.ObjectInfo, e
 	}
 
 	fullPath := b.fullPath(p)
-	info, err := b.sftpClient.Stat(fullPath)
+	var info os.FileInfo
+	err := b.do(ctx, func(c *sftp.Client) error {
+		var err error
+		info, err = c.Stat(fullPath)
+		return err
+	})
 	if err != nil {
 		return nil, b.translateError(err, p)
 	}
@@ -414,7 +578,9 @@ func (b *Backend) Mkdir(ctx context.Context, p string) error {
 	}
 
 	fullPath := b.fullPath(p)
-	err := b.sftpClient.MkdirAll(fullPath)
+	err := b.do(ctx, func(c *sftp.Client) error {
+		return c.MkdirAll(fullPath)
+	})
 	if err != nil {
 		return fmt.Errorf("sftp: creating directory: %w", err)
 	}
@@ -433,19 +599,25 @@ func (b *Backend) Rmdir(ctx context.Context, p string) error {
 
 	fullPath := b.fullPath(p)
 
-	// Check if directory is empty
-	entries, err := b.sftpClient.ReadDir(fullPath)
+	var notEmpty bool
+	err := b.do(ctx, func(c *sftp.Client) error {
+		// Check if directory is empty
+		entries, err := c.ReadDir(fullPath)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			notEmpty = true
+			return nil
+		}
+		return c.RemoveDirectory(fullPath)
+	})
 	if err != nil {
 		return b.translateError(err, p)
 	}
-	if len(entries) > 0 {
+	if notEmpty {
 		return fmt.Errorf("sftp: directory not empty: %s", p)
 	}
-
-	err = b.sftpClient.RemoveDirectory(fullPath)
-	if err != nil {
-		return b.translateError(err, p)
-	}
 	return nil
 }
 
@@ -463,42 +635,44 @@ func (b *Backend) Copy(ctx context.Context, src, dst string) error {
 	srcPath := b.fullPath(src)
 	dstPath := b.fullPath(dst)
 
-	// Ensure parent directory exists
-	if err := b.sftpClient.MkdirAll(path.Dir(dstPath)); err != nil {
-		return fmt.Errorf("sftp: creating directory: %w", err)
-	}
+	return b.do(ctx, func(c *sftp.Client) error {
+		// Ensure parent directory exists
+		if err := c.MkdirAll(path.Dir(dstPath)); err != nil {
+			return fmt.Errorf("sftp: creating directory: %w", err)
+		}
 
-	srcFile, err := b.sftpClient.Open(srcPath)
-	if err != nil {
-		return b.translateError(err, src)
-	}
+		srcFile, err := c.Open(srcPath)
+		if err != nil {
+			return b.translateError(err, src)
+		}
 
-	dstFile, err := b.sftpClient.Create(dstPath)
-	if err != nil {
-		if closeErr := srcFile.Close(); closeErr != nil {
-			return fmt.Errorf("sftp: %w (also failed to close source: %v)", b.translateError(err, dst), closeErr)
+		dstFile, err := c.Create(dstPath)
+		if err != nil {
+			if closeErr := srcFile.Close(); closeErr != nil {
+				return fmt.Errorf("sftp: %w (also failed to close source: %v)", b.translateError(err, dst), closeErr)
+			}
+			return b.translateError(err, dst)
 		}
-		return b.translateError(err, dst)
-	}
 
-	_, copyErr := io.Copy(dstFile, srcFile)
+		_, copyErr := io.Copy(dstFile, srcFile)
 
-	// Close both files, collecting any errors
-	srcCloseErr := srcFile.Close()
-	dstCloseErr := dstFile.Close()
+		// Close both files, collecting any errors
+		srcCloseErr := srcFile.Close()
+		dstCloseErr := dstFile.Close()
 
-	// Return the first error encountered
-	if copyErr != nil {
-		return fmt.Errorf("sftp: copying file: %w", copyErr)
-	}
-	if dstCloseErr != nil {
-		return fmt.Errorf("sftp: closing destination file: %w", dstCloseErr)
-	}
-	if srcCloseErr != nil {
-		return fmt.Errorf("sftp: closing source file: %w", srcCloseErr)
-	}
+		// Return the first error encountered
+		if copyErr != nil {
+			return fmt.Errorf("sftp: copying file: %w", copyErr)
+		}
+		if dstCloseErr != nil {
+			return fmt.Errorf("sftp: closing destination file: %w", dstCloseErr)
+		}
+		if srcCloseErr != nil {
+			return fmt.Errorf("sftp: closing source file: %w", srcCloseErr)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // Move moves a file.
@@ -514,13 +688,15 @@ func (b *Backend) Move(ctx context.Context, src, dst string) error {
 	srcPath := b.fullPath(src)
 	dstPath := b.fullPath(dst)
 
-	// Ensure parent directory exists
-	if err := b.sftpClient.MkdirAll(path.Dir(dstPath)); err != nil {
-		return fmt.Errorf("sftp: creating directory: %w", err)
-	}
+	err := b.do(ctx, func(c *sftp.Client) error {
+		// Ensure parent directory exists
+		if err := c.MkdirAll(path.Dir(dstPath)); err != nil {
+			return fmt.Errorf("sftp: creating directory: %w", err)
+		}
 
-	// Try rename first (same filesystem)
-	err := b.sftpClient.Rename(srcPath, dstPath)
+		// Try rename first (same filesystem)
+		return c.Rename(srcPath, dstPath)
+	})
 	if err != nil {
 		// Fall back to copy+delete
 		if copyErr := b.Copy(ctx, src, dst); copyErr != nil {
@@ -0,0 +1,52 @@
+package sftp
+
+import (
+	"bufio"
+	"io"
+)
+
+// bufferedReader batches small reads into large ones. A large read is
+// split by the SFTP client into concurrent requests, so callers such as
+// io.ReadAll that read in small chunks are not limited by round-trip time.
+// WriteTo is passed through to the file's concurrent download path.
+type bufferedReader struct {
+	*bufio.Reader
+	f *pooledFile
+}
+
+func newBufferedReader(f *pooledFile, size int) *bufferedReader {
+	return &bufferedReader{Reader: bufio.NewReaderSize(f, size), f: f}
+}
+
+func (r *bufferedReader) Close() error {
+	return r.f.Close()
+}
+
+// bufferedWriter batches small writes into large ones, which the SFTP
+// client can send as concurrent requests when ConcurrentWrites is set.
+// ReadFrom is passed through to the file's upload path while the buffer
+// is empty.
+type bufferedWriter struct {
+	*bufio.Writer
+	f *pooledFile
+}
+
+func newBufferedWriter(f *pooledFile, size int) *bufferedWriter {
+	return &bufferedWriter{Writer: bufio.NewWriterSize(f, size), f: f}
+}
+
+// Close flushes buffered data and closes the file.
+func (w *bufferedWriter) Close() error {
+	flushErr := w.Flush()
+	closeErr := w.f.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// Ensure buffered types keep the io fast paths
+var (
+	_ io.WriterTo   = (*bufferedReader)(nil)
+	_ io.ReaderFrom = (*bufferedWriter)(nil)
+)
@@ -0,0 +1,96 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestLargeFileTuned(t *testing.T) {
+	for _, cfg := range []Config{
+		{Root: "/data", BufferSize: -1},
+		{Root: "/data", BufferSize: 64 << 10},
+		{Root: "/data", BufferSize: 64 << 10, ConcurrentWrites: true, MaxPacket: 8 << 10, MaxConcurrentRequests: 4},
+		{Root: "/data", DisableConcurrentReads: true, UseFstat: true},
+	} {
+		s := newMemServer()
+		s.opts = cfg.withDefaults().clientOptions()
+		b := newTestBackend(t, s, cfg)
+		ctx := context.Background()
+		data := testData(1<<20 + 123)
+
+		// Small writes, as an encoder would produce.
+		w, err := b.NewWriter(ctx, "big.bin")
+		if err != nil {
+			t.Fatalf("NewWriter: %v", err)
+		}
+		for off := 0; off < len(data); off += 1000 {
+			end := min(off+1000, len(data))
+			if _, err := w.Write(data[off:end]); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		// io.Copy uses the WriteTo fast path.
+		r, err := b.NewReader(ctx, "big.bin")
+		if err != nil {
+			t.Fatalf("NewReader: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, r); err != nil {
+			t.Fatalf("Copy: %v", err)
+		}
+		_ = r.Close()
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("BufferSize=%d: downloaded data differs", cfg.BufferSize)
+		}
+
+		// io.Copy into the writer uses the ReadFrom fast path.
+		w, err = b.NewWriter(ctx, "copy.bin")
+		if err != nil {
+			t.Fatalf("NewWriter: %v", err)
+		}
+		if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+			t.Fatalf("Copy: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		// Ranged reads stop at the limit.
+		r, err = b.NewReader(ctx, "copy.bin", omnistorage.WithOffset(5000), omnistorage.WithLimit(70000))
+		if err != nil {
+			t.Fatalf("NewReader: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		if !bytes.Equal(got, data[5000:75000]) {
+			t.Errorf("BufferSize=%d: ranged read differs (got %d bytes)", cfg.BufferSize, len(got))
+		}
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	if n := len(Config{}.withDefaults().clientOptions()); n != 3 {
+		t.Errorf("default options = %d, want 3", n)
+	}
+	if n := len(Config{MaxPacket: 1 << 18, MaxConcurrentRequests: 16}.clientOptions()); n != 5 {
+		t.Errorf("tuned options = %d, want 5", n)
+	}
+}
@@ -0,0 +1,121 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/grokify/omnistorage"
+	"golang.org/x/crypto/ssh"
+)
+
+// Ensure Backend implements omnistorage.CompressedReader
+var _ omnistorage.CompressedReader = (*Backend)(nil)
+
+// NewCompressedReader returns a reader of the gzip-compressed content of
+// path, compressed by gzip on the server. It returns
+// omnistorage.ErrNotSupported unless Config.RemoteGzip is set.
+func (b *Backend) NewCompressedReader(ctx context.Context, p string) (io.ReadCloser, error) {
+	if !b.config.RemoteGzip {
+		return nil, omnistorage.ErrNotSupported
+	}
+
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c, err := b.pool.get(ctx)
+	if err != nil {
+		return nil, b.poolError(err)
+	}
+	client, ok := c.closer.(*ssh.Client)
+	if !ok {
+		b.pool.put(c, false)
+		return nil, omnistorage.ErrNotSupported
+	}
+
+	fullPath := b.fullPath(p)
+	if _, err := c.client.Stat(fullPath); err != nil {
+		b.pool.put(c, isConnError(err))
+		return nil, b.translateError(err, p)
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		b.pool.put(c, isConnError(err))
+		return nil, fmt.Errorf("sftp: remote gzip session: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		_ = session.Close()
+		b.pool.put(c, false)
+		return nil, fmt.Errorf("sftp: remote gzip session: %w", err)
+	}
+	r := &remoteGzipReader{b: b, c: c, session: session, stdout: stdout}
+	session.Stderr = &r.stderr
+	if err := session.Start("gzip -c -- " + shellQuote(fullPath)); err != nil {
+		_ = session.Close()
+		b.pool.put(c, false)
+		return nil, fmt.Errorf("sftp: remote gzip: %w", err)
+	}
+	return r, nil
+}
+
+// remoteGzipReader reads the output of gzip run on the server. It keeps
+// its connection checked out of the pool until closed.
+type remoteGzipReader struct {
+	b       *Backend
+	c       *conn
+	session *ssh.Session
+	stdout  io.Reader
+	stderr  bytes.Buffer
+	done    bool
+	err     error
+}
+
+func (r *remoteGzipReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF {
+		// A gzip that failed part way exits non-zero
+		if werr := r.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// wait waits for gzip to exit and returns its error, once.
+func (r *remoteGzipReader) wait() error {
+	if r.done {
+		return r.err
+	}
+	r.done = true
+	if err := r.session.Wait(); err != nil {
+		msg := strings.TrimSpace(r.stderr.String())
+		r.err = fmt.Errorf("sftp: remote gzip: %w: %s", err, msg)
+	}
+	return r.err
+}
+
+// Close ends the session and returns the connection to the pool.
+func (r *remoteGzipReader) Close() error {
+	if r.c == nil {
+		return nil
+	}
+	// Closing before EOF stops gzip; its exit status does not matter then
+	_ = r.session.Close()
+	r.b.pool.put(r.c, false)
+	r.c = nil
+	return nil
+}
+
+// shellQuote quotes s as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,43 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestNewCompressedReaderNotSupported(t *testing.T) {
+	ctx := context.Background()
+	s := newMemServer()
+
+	// Disabled by default
+	b := newTestBackend(t, s, Config{})
+	writeFile(t, b, "/a.txt", "hello")
+	if _, err := b.NewCompressedReader(ctx, "/a.txt"); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("NewCompressedReader error = %v, want ErrNotSupported", err)
+	}
+
+	// Enabled, but the connection is not SSH
+	b = newTestBackend(t, s, Config{RemoteGzip: true})
+	if _, err := b.NewCompressedReader(ctx, "/a.txt"); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("NewCompressedReader error = %v, want ErrNotSupported", err)
+	}
+	// The connection went back to the pool
+	if got := readFile(t, b, "/a.txt"); got != "hello" {
+		t.Errorf("read %q, want %q", got, "hello")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/data/a.txt", `'/data/a.txt'`},
+		{"it's $HOME", `'it'\''s $HOME'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
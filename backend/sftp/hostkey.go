@@ -0,0 +1,97 @@
+package sftp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ErrHostKeyMismatch is returned when the server's host key does not match
+// Config.HostKeyFingerprint.
+var ErrHostKeyMismatch = errors.New("sftp: host key fingerprint mismatch")
+
+// newHostKeyCallback builds the host key verification for cfg.
+//
+// With InsecureSkipHostKeyVerify, any host key is accepted. Otherwise the
+// key must match HostKeyFingerprint, if set, and must be listed in the
+// known_hosts file. The known_hosts check is skipped when only a
+// fingerprint is given; it uses KnownHostsFile, or ~/.ssh/known_hosts if
+// that is empty.
+func newHostKeyCallback(cfg Config) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureSkipHostKeyVerify {
+		return ssh.InsecureIgnoreHostKey(), nil //nolint:gosec // G106: explicitly requested via InsecureSkipHostKeyVerify
+	}
+
+	var callbacks []ssh.HostKeyCallback
+
+	if cfg.HostKeyFingerprint != "" {
+		callbacks = append(callbacks, fingerprintCallback(cfg.HostKeyFingerprint))
+	}
+
+	if cfg.HostKeyFingerprint == "" || cfg.KnownHostsFile != "" {
+		knownHostsPath := cfg.KnownHostsFile
+		if knownHostsPath == "" {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("sftp: could not determine user home directory for known_hosts: %w", err)
+			}
+			knownHostsPath = filepath.Join(homeDir, ".ssh", "known_hosts")
+		}
+		cb, err := knownhosts.New(knownHostsPath)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: could not load known_hosts file (%s): %w", knownHostsPath, err)
+		}
+		callbacks = append(callbacks, knownHostsCallback(cb))
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, cb := range callbacks {
+			if err := cb(hostname, remote, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// fingerprintCallback accepts only a host key with the given fingerprint,
+// in OpenSSH SHA256 form ("SHA256:...") or legacy MD5 form ("MD5:aa:bb:..."
+// or "aa:bb:...").
+func fingerprintCallback(want string) ssh.HostKeyCallback {
+	want = strings.TrimSpace(want)
+	fingerprint := ssh.FingerprintSHA256
+	if !strings.HasPrefix(want, "SHA256:") {
+		want = strings.ToLower(strings.TrimPrefix(want, "MD5:"))
+		fingerprint = ssh.FingerprintLegacyMD5
+	}
+
+	return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+		got := fingerprint(key)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			return fmt.Errorf("%w for %s: got %s", ErrHostKeyMismatch, hostname, ssh.FingerprintSHA256(key))
+		}
+		return nil
+	}
+}
+
+// knownHostsCallback wraps a knownhosts callback with clearer errors.
+func knownHostsCallback(cb ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return fmt.Errorf("sftp: host %s is not in known_hosts (key %s): %w", hostname, ssh.FingerprintSHA256(key), err)
+			}
+			return fmt.Errorf("sftp: host key for %s changed (key %s): %w", hostname, ssh.FingerprintSHA256(key), err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,110 @@
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func testHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("NewPublicKey: %v", err)
+	}
+	return key
+}
+
+var testAddr = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+
+func TestHostKeyFingerprint(t *testing.T) {
+	key := testHostKey(t)
+	other := testHostKey(t)
+
+	for _, fp := range []string{
+		ssh.FingerprintSHA256(key),
+		ssh.FingerprintLegacyMD5(key),
+		"MD5:" + ssh.FingerprintLegacyMD5(key),
+	} {
+		cb, err := newHostKeyCallback(Config{HostKeyFingerprint: fp})
+		if err != nil {
+			t.Fatalf("newHostKeyCallback(%q): %v", fp, err)
+		}
+		if err := cb("example.com:22", testAddr, key); err != nil {
+			t.Errorf("fingerprint %q: matching key rejected: %v", fp, err)
+		}
+		if err := cb("example.com:22", testAddr, other); !errors.Is(err, ErrHostKeyMismatch) {
+			t.Errorf("fingerprint %q: other key: err = %v, want ErrHostKeyMismatch", fp, err)
+		}
+	}
+}
+
+func TestHostKeyKnownHosts(t *testing.T) {
+	key := testHostKey(t)
+	other := testHostKey(t)
+
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{"example.com"}, key) + "\n"
+	if err := os.WriteFile(file, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cb, err := newHostKeyCallback(Config{KnownHostsFile: file})
+	if err != nil {
+		t.Fatalf("newHostKeyCallback: %v", err)
+	}
+	if err := cb("example.com:22", testAddr, key); err != nil {
+		t.Errorf("known key rejected: %v", err)
+	}
+	if err := cb("example.com:22", testAddr, other); err == nil {
+		t.Error("changed key accepted")
+	}
+	if err := cb("unknown.example.com:22", testAddr, key); err == nil {
+		t.Error("unknown host accepted")
+	}
+
+	// With both a pin and known_hosts, both must pass.
+	cb, err = newHostKeyCallback(Config{KnownHostsFile: file, HostKeyFingerprint: ssh.FingerprintSHA256(key)})
+	if err != nil {
+		t.Fatalf("newHostKeyCallback: %v", err)
+	}
+	if err := cb("example.com:22", testAddr, key); err != nil {
+		t.Errorf("pinned known key rejected: %v", err)
+	}
+	if err := cb("unknown.example.com:22", testAddr, key); err == nil {
+		t.Error("pinned key for unknown host accepted")
+	}
+}
+
+func TestHostKeyKnownHostsMissingFile(t *testing.T) {
+	_, err := newHostKeyCallback(Config{KnownHostsFile: filepath.Join(t.TempDir(), "missing")})
+	if err == nil {
+		t.Error("expected error for missing known_hosts file")
+	}
+}
+
+func TestHostKeyInsecure(t *testing.T) {
+	cb, err := newHostKeyCallback(Config{InsecureSkipHostKeyVerify: true})
+	if err != nil {
+		t.Fatalf("newHostKeyCallback: %v", err)
+	}
+	if err := cb("example.com:22", testAddr, testHostKey(t)); err != nil {
+		t.Errorf("insecure mode rejected key: %v", err)
+	}
+
+	cfg := Config{Host: "example.com", User: "u", InsecureSkipHostKeyVerify: true, HostKeyFingerprint: "SHA256:x"}
+	if err := cfg.Validate(); !errors.Is(err, ErrConflictingHostKeyOptions) {
+		t.Errorf("Validate() = %v, want ErrConflictingHostKeyOptions", err)
+	}
+}
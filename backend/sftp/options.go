@@ -4,12 +4,22 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/grokify/omnistorage"
 )
 
 // Errors specific to the SFTP backend.
 var (
 	ErrHostRequired = errors.New("sftp: host is required")
 	ErrUserRequired = errors.New("sftp: user is required")
+
+	// ErrConflictingHostKeyOptions is returned when InsecureSkipHostKeyVerify
+	// is combined with KnownHostsFile or HostKeyFingerprint.
+	ErrConflictingHostKeyOptions = errors.New("sftp: InsecureSkipHostKeyVerify cannot be combined with KnownHostsFile or HostKeyFingerprint")
 )
 
 // Config holds configuration for the SFTP backend.
@@ -24,40 +34,170 @@ type Config struct {
 	User string
 
 	// Password is the SSH password.
-	// Either Password or KeyFile must be provided.
+	// At least one of Password, KeyFile, KeyFiles, Signers or UseAgent
+	// must be provided.
 	Password string
 
 	// KeyFile is the path to an SSH private key file.
-	// Either Password or KeyFile must be provided.
 	KeyFile string
 
 	// KeyPassphrase is the passphrase for encrypted private keys.
 	KeyPassphrase string
 
+	// KeyFiles lists additional private key files to offer after KeyFile.
+	// Each key's certificate is loaded from "<key>-cert.pub" if present.
+	KeyFiles []string
+
+	// CertFile is the path to an OpenSSH certificate for KeyFile.
+	// If empty, "<KeyFile>-cert.pub" is used when it exists.
+	CertFile string
+
+	// UseAgent offers the keys held by an SSH agent, including keys
+	// backed by hardware tokens.
+	UseAgent bool
+
+	// AgentSocket is the SSH agent socket path.
+	// Default: $SSH_AUTH_SOCK.
+	AgentSocket string
+
+	// Signers are additional keys to offer, such as signers backed by an
+	// HSM (see ssh.NewSignerFromSigner).
+	Signers []ssh.Signer
+
 	// Root is the base directory on the remote server.
 	// All paths are relative to this directory.
 	Root string
 
-	// KnownHostsFile is the path to the known_hosts file.
-	// If empty, host key verification is disabled (insecure).
+	// KnownHostsFile is the path to the known_hosts file used to verify
+	// the server's host key. If empty, ~/.ssh/known_hosts is used, unless
+	// HostKeyFingerprint is set.
 	KnownHostsFile string
 
+	// HostKeyFingerprint pins the server's host key, in OpenSSH SHA256
+	// form ("SHA256:...") or legacy MD5 form ("MD5:aa:bb:..."). If set
+	// without KnownHostsFile, known_hosts is not consulted.
+	HostKeyFingerprint string
+
+	// InsecureSkipHostKeyVerify accepts any host key. This allows
+	// man-in-the-middle attacks and should only be used for testing.
+	InsecureSkipHostKeyVerify bool
+
 	// Timeout is the connection timeout in seconds.
 	// Default: 30.
 	Timeout int
 
-	// Concurrency is the maximum number of concurrent operations.
+	// OpTimeout bounds each operation other than reading or writing a
+	// file, such as Stat, List or Delete, in seconds. An operation that
+	// exceeds it fails with an error matching omnistorage.ErrTimeout and
+	// its connection is discarded.
+	// Default: 0 (no limit).
+	OpTimeout int
+
+	// Concurrency is the maximum number of concurrent operations, and so
+	// the size of the SSH connection pool.
 	// Default: 5.
 	Concurrency int
+
+	// ReconnectAttempts is the number of times a failed connection is
+	// re-dialed, and an operation interrupted by a dropped connection is
+	// retried, before giving up.
+	// Default: 3.
+	ReconnectAttempts int
+
+	// MaxPacket is the maximum SFTP packet payload in bytes. Sizes above
+	// 32768 are faster on high-latency links but are not supported by every
+	// server; OpenSSH accepts up to 262144.
+	// Default: 32768.
+	MaxPacket int
+
+	// MaxConcurrentRequests is the maximum number of requests in flight
+	// for a single file.
+	// Default: 64.
+	MaxConcurrentRequests int
+
+	// ConcurrentWrites sends large writes as concurrent requests. If an
+	// upload fails part way, the remote file may contain holes.
+	ConcurrentWrites bool
+
+	// DisableConcurrentReads reads files sequentially. Needed for some
+	// "read once" servers that remove a file once it is stat'ed.
+	DisableConcurrentReads bool
+
+	// UseFstat uses fstat on the open handle instead of stat on the path
+	// to size concurrent downloads, for servers that limit open files.
+	UseFstat bool
+
+	// BufferSize is the size in bytes of the read and write buffers that
+	// batch small reads and writes into large concurrent transfers.
+	// A negative value disables buffering.
+	// Default: 1048576.
+	BufferSize int
+
+	// HealthCheckInterval is how long, in seconds, a pooled connection may
+	// sit idle before it is probed ahead of reuse. A negative value probes
+	// before every operation.
+	// Default: 30.
+	HealthCheckInterval int
+
+	// RemoteGzip lets NewCompressedReader run gzip on the server over an
+	// SSH session, so files are sent compressed. It needs shell access
+	// and gzip on the server; SFTP-only accounts cannot use it.
+	RemoteGzip bool
 }
 
 // DefaultConfig returns a Config with default values.
 func DefaultConfig() Config {
 	return Config{
-		Port:        22,
-		Timeout:     30,
-		Concurrency: 5,
+		Port:                22,
+		Timeout:             30,
+		Concurrency:         5,
+		ReconnectAttempts:   3,
+		HealthCheckInterval: 30,
+		BufferSize:          1 << 20,
+	}
+}
+
+// withDefaults fills unset fields with their default values.
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.Port <= 0 {
+		c.Port = d.Port
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = d.Timeout
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = d.Concurrency
+	}
+	if c.ReconnectAttempts == 0 {
+		c.ReconnectAttempts = d.ReconnectAttempts
+	}
+	if c.ReconnectAttempts < 0 {
+		c.ReconnectAttempts = 0
+	}
+	if c.HealthCheckInterval == 0 {
+		c.HealthCheckInterval = d.HealthCheckInterval
+	}
+	if c.BufferSize == 0 {
+		c.BufferSize = d.BufferSize
+	}
+	return c
+}
+
+// clientOptions returns the SFTP client options for c.
+func (c Config) clientOptions() []sftp.ClientOption {
+	opts := []sftp.ClientOption{
+		sftp.UseConcurrentWrites(c.ConcurrentWrites),
+		sftp.UseConcurrentReads(!c.DisableConcurrentReads),
+		sftp.UseFstat(c.UseFstat),
+	}
+	if c.MaxPacket > 0 {
+		opts = append(opts, sftp.MaxPacketUnchecked(c.MaxPacket))
+	}
+	if c.MaxConcurrentRequests > 0 {
+		opts = append(opts, sftp.MaxConcurrentRequestsPerFile(c.MaxConcurrentRequests))
 	}
+	return opts
 }
 
 // ConfigFromEnv creates a Config from environment variables.
@@ -68,9 +208,25 @@ func DefaultConfig() Config {
 //   - OMNISTORAGE_SFTP_PASSWORD: password
 //   - OMNISTORAGE_SFTP_KEY_FILE: path to private key
 //   - OMNISTORAGE_SFTP_KEY_PASSPHRASE: passphrase for encrypted key
+//   - OMNISTORAGE_SFTP_KEY_FILES: comma-separated additional private keys
+//   - OMNISTORAGE_SFTP_CERT_FILE: path to OpenSSH certificate for the key
+//   - OMNISTORAGE_SFTP_USE_AGENT: "true" to use the SSH agent
+//   - OMNISTORAGE_SFTP_AGENT_SOCKET: SSH agent socket (default: $SSH_AUTH_SOCK)
 //   - OMNISTORAGE_SFTP_ROOT: base directory
 //   - OMNISTORAGE_SFTP_KNOWN_HOSTS: path to known_hosts file
+//   - OMNISTORAGE_SFTP_HOST_KEY_FINGERPRINT: pinned host key fingerprint
+//   - OMNISTORAGE_SFTP_INSECURE_SKIP_HOST_KEY_VERIFY: "true" to accept any host key
 //   - OMNISTORAGE_SFTP_TIMEOUT: connection timeout in seconds
+//   - OMNISTORAGE_SFTP_CONCURRENCY: connection pool size
+//   - OMNISTORAGE_SFTP_RECONNECT_ATTEMPTS: reconnect attempts (-1 disables)
+//   - OMNISTORAGE_SFTP_HEALTH_CHECK_INTERVAL: idle seconds before a health check
+//   - OMNISTORAGE_SFTP_MAX_PACKET: maximum packet payload in bytes
+//   - OMNISTORAGE_SFTP_MAX_CONCURRENT_REQUESTS: requests in flight per file
+//   - OMNISTORAGE_SFTP_CONCURRENT_WRITES: "true" to enable concurrent writes
+//   - OMNISTORAGE_SFTP_DISABLE_CONCURRENT_READS: "true" to read sequentially
+//   - OMNISTORAGE_SFTP_USE_FSTAT: "true" to size downloads with fstat
+//   - OMNISTORAGE_SFTP_BUFFER_SIZE: read/write buffer size in bytes (-1 disables)
+//   - OMNISTORAGE_SFTP_REMOTE_GZIP: "true" to compress downloads with gzip on the server
 func ConfigFromEnv() Config {
 	config := DefaultConfig()
 
@@ -94,17 +250,82 @@ func ConfigFromEnv() Config {
 	if v := os.Getenv("OMNISTORAGE_SFTP_KEY_PASSPHRASE"); v != "" {
 		config.KeyPassphrase = v
 	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_KEY_FILES"); v != "" {
+		config.KeyFiles = splitList(v)
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_CERT_FILE"); v != "" {
+		config.CertFile = v
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_USE_AGENT"); v != "" {
+		config.UseAgent, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_AGENT_SOCKET"); v != "" {
+		config.AgentSocket = v
+	}
 	if v := os.Getenv("OMNISTORAGE_SFTP_ROOT"); v != "" {
 		config.Root = v
 	}
 	if v := os.Getenv("OMNISTORAGE_SFTP_KNOWN_HOSTS"); v != "" {
 		config.KnownHostsFile = v
 	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_HOST_KEY_FINGERPRINT"); v != "" {
+		config.HostKeyFingerprint = v
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_INSECURE_SKIP_HOST_KEY_VERIFY"); v != "" {
+		config.InsecureSkipHostKeyVerify, _ = strconv.ParseBool(v)
+	}
 	if v := os.Getenv("OMNISTORAGE_SFTP_TIMEOUT"); v != "" {
 		if timeout, err := strconv.Atoi(v); err == nil && timeout > 0 {
 			config.Timeout = timeout
 		}
 	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_OP_TIMEOUT"); v != "" {
+		if timeout, err := strconv.Atoi(v); err == nil && timeout > 0 {
+			config.OpTimeout = timeout
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_CONCURRENCY"); v != "" {
+		if c, err := strconv.Atoi(v); err == nil && c > 0 {
+			config.Concurrency = c
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_RECONNECT_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.ReconnectAttempts = n
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_HEALTH_CHECK_INTERVAL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.HealthCheckInterval = n
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_MAX_PACKET"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			config.MaxPacket = n
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_MAX_CONCURRENT_REQUESTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			config.MaxConcurrentRequests = n
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_CONCURRENT_WRITES"); v != "" {
+		config.ConcurrentWrites, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_DISABLE_CONCURRENT_READS"); v != "" {
+		config.DisableConcurrentReads, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_USE_FSTAT"); v != "" {
+		config.UseFstat, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_BUFFER_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.BufferSize = n
+		}
+	}
+	if v := os.Getenv("OMNISTORAGE_SFTP_REMOTE_GZIP"); v != "" {
+		config.RemoteGzip, _ = strconv.ParseBool(v)
+	}
 
 	return config
 }
@@ -117,10 +338,25 @@ func ConfigFromEnv() Config {
 //   - pass or password: password
 //   - key_file: path to private key
 //   - key_passphrase: passphrase for encrypted key
+//   - key_files: comma-separated additional private keys
+//   - cert_file: path to OpenSSH certificate for the key
+//   - use_agent: "true" to use the SSH agent
+//   - agent_socket: SSH agent socket (default: $SSH_AUTH_SOCK)
 //   - root: base directory
 //   - known_hosts: path to known_hosts file
+//   - host_key_fingerprint: pinned host key fingerprint
+//   - insecure_skip_host_key_verify: "true" to accept any host key
 //   - timeout: connection timeout in seconds
-//   - concurrency: maximum concurrent operations
+//   - concurrency: maximum concurrent operations (connection pool size)
+//   - reconnect_attempts: reconnect attempts (-1 disables)
+//   - health_check_interval: idle seconds before a health check (-1 checks always)
+//   - max_packet: maximum packet payload in bytes
+//   - max_concurrent_requests: requests in flight per file
+//   - concurrent_writes: "true" to enable concurrent writes
+//   - disable_concurrent_reads: "true" to read sequentially
+//   - use_fstat: "true" to size downloads with fstat
+//   - buffer_size: read/write buffer size in bytes (-1 disables)
+//   - remote_gzip: "true" to compress downloads with gzip on the server
 func ConfigFromMap(m map[string]string) Config {
 	config := DefaultConfig()
 
@@ -147,26 +383,116 @@ func ConfigFromMap(m map[string]string) Config {
 	if v, ok := m["key_passphrase"]; ok {
 		config.KeyPassphrase = v
 	}
+	if v, ok := m["key_files"]; ok {
+		config.KeyFiles = splitList(v)
+	}
+	if v, ok := m["cert_file"]; ok {
+		config.CertFile = v
+	}
+	if v, ok := m["use_agent"]; ok {
+		config.UseAgent, _ = strconv.ParseBool(v)
+	}
+	if v, ok := m["agent_socket"]; ok {
+		config.AgentSocket = v
+	}
 	if v, ok := m["root"]; ok {
 		config.Root = v
 	}
 	if v, ok := m["known_hosts"]; ok {
 		config.KnownHostsFile = v
 	}
+	if v, ok := m["host_key_fingerprint"]; ok {
+		config.HostKeyFingerprint = v
+	}
+	if v, ok := m["insecure_skip_host_key_verify"]; ok {
+		config.InsecureSkipHostKeyVerify, _ = strconv.ParseBool(v)
+	}
 	if v, ok := m["timeout"]; ok {
 		if timeout, err := strconv.Atoi(v); err == nil && timeout > 0 {
 			config.Timeout = timeout
 		}
 	}
+	if v, ok := m["op_timeout"]; ok {
+		if timeout, err := strconv.Atoi(v); err == nil && timeout > 0 {
+			config.OpTimeout = timeout
+		}
+	}
 	if v, ok := m["concurrency"]; ok {
 		if c, err := strconv.Atoi(v); err == nil && c > 0 {
 			config.Concurrency = c
 		}
 	}
+	if v, ok := m["reconnect_attempts"]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.ReconnectAttempts = n
+		}
+	}
+	if v, ok := m["health_check_interval"]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.HealthCheckInterval = n
+		}
+	}
+	if v, ok := m["max_packet"]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			config.MaxPacket = n
+		}
+	}
+	if v, ok := m["max_concurrent_requests"]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			config.MaxConcurrentRequests = n
+		}
+	}
+	if v, ok := m["concurrent_writes"]; ok {
+		config.ConcurrentWrites, _ = strconv.ParseBool(v)
+	}
+	if v, ok := m["disable_concurrent_reads"]; ok {
+		config.DisableConcurrentReads, _ = strconv.ParseBool(v)
+	}
+	if v, ok := m["use_fstat"]; ok {
+		config.UseFstat, _ = strconv.ParseBool(v)
+	}
+	if v, ok := m["buffer_size"]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.BufferSize = n
+		}
+	}
+	if v, ok := m["remote_gzip"]; ok {
+		config.RemoteGzip, _ = strconv.ParseBool(v)
+	}
 
 	return config
 }
 
+// configKeys documents the keys accepted by ConfigFromMap.
+var configKeys = []omnistorage.ConfigKey{
+	{Name: "host", Description: "Server hostname", Required: true},
+	{Name: "port", Description: "SSH port", Default: "22"},
+	{Name: "user", Description: "Username", Required: true},
+	{Name: "password", Description: `Password (alias "pass")`, Secret: true},
+	{Name: "key_file", Description: "Path to private key"},
+	{Name: "key_passphrase", Description: "Passphrase for encrypted key", Secret: true},
+	{Name: "key_files", Description: "Comma-separated additional private keys"},
+	{Name: "cert_file", Description: "Path to OpenSSH certificate for the key"},
+	{Name: "use_agent", Description: "Use the SSH agent", Default: "false"},
+	{Name: "agent_socket", Description: "SSH agent socket (default: $SSH_AUTH_SOCK)"},
+	{Name: "root", Description: "Base directory"},
+	{Name: "known_hosts", Description: "Path to known_hosts file (default: ~/.ssh/known_hosts)"},
+	{Name: "host_key_fingerprint", Description: "Pinned host key fingerprint"},
+	{Name: "insecure_skip_host_key_verify", Description: "Accept any host key", Default: "false"},
+	{Name: "timeout", Description: "Connection timeout in seconds", Default: "30"},
+	{Name: "op_timeout", Description: "Per-operation timeout in seconds for Stat, List, Delete and other calls", Default: "0"},
+	{Name: "concurrency", Description: "Maximum concurrent operations (connection pool size)", Default: "5"},
+	{Name: "reconnect_attempts", Description: "Reconnect attempts (-1 disables)", Default: "3"},
+	{Name: "health_check_interval", Description: "Idle seconds before a health check (-1 checks always)", Default: "30"},
+	{Name: "max_packet", Description: "Maximum packet payload in bytes"},
+	{Name: "max_concurrent_requests", Description: "Requests in flight per file"},
+	{Name: "concurrent_writes", Description: "Enable concurrent writes", Default: "false"},
+	{Name: "disable_concurrent_reads", Description: "Read sequentially", Default: "false"},
+	{Name: "use_fstat", Description: "Size downloads with fstat", Default: "false"},
+	{Name: "buffer_size", Description: "Read/write buffer size in bytes (-1 disables)", Default: "1048576"},
+	{Name: "remote_gzip", Description: "Compress downloads with gzip on the server", Default: "false"},
+}
+
 // Validate checks if the configuration is valid.
 func (c Config) Validate() error {
 	if c.Host == "" {
@@ -175,5 +501,19 @@ func (c Config) Validate() error {
 	if c.User == "" {
 		return ErrUserRequired
 	}
+	if c.InsecureSkipHostKeyVerify && (c.KnownHostsFile != "" || c.HostKeyFingerprint != "") {
+		return ErrConflictingHostKeyOptions
+	}
 	return nil
 }
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
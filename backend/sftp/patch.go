@@ -0,0 +1,59 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/grokify/omnistorage"
+	"github.com/pkg/sftp"
+)
+
+// Ensure Backend implements omnistorage.Patcher
+var _ omnistorage.Patcher = (*Backend)(nil)
+
+// WriteAt writes p to the file at path at offset off, extending it if
+// needed. Only p is sent to the server.
+func (b *Backend) WriteAt(ctx context.Context, p string, data []byte, off int64) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fullPath := b.fullPath(p)
+	err := b.do(ctx, func(c *sftp.Client) error {
+		f, err := c.OpenFile(fullPath, os.O_WRONLY)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteAt(data, off); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("sftp: write: %w", err)
+		}
+		return f.Close()
+	})
+	return b.translateError(err, p)
+}
+
+// Truncate changes the size of the file at path.
+func (b *Backend) Truncate(ctx context.Context, p string, size int64) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fullPath := b.fullPath(p)
+	err := b.do(ctx, func(c *sftp.Client) error {
+		if _, err := c.Stat(fullPath); err != nil {
+			return err
+		}
+		return c.Truncate(fullPath, size)
+	})
+	return b.translateError(err, p)
+}
@@ -0,0 +1,38 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grokify/omnistorage"
+	"github.com/pkg/sftp"
+)
+
+// Ensure Backend implements omnistorage.Pinger
+var _ omnistorage.Pinger = (*Backend)(nil)
+
+// Ping checks that the server can be reached and that the root directory
+// exists.
+func (b *Backend) Ping(ctx context.Context) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	root := b.fullPath("")
+	if root == "" {
+		root = "."
+	}
+	return b.do(ctx, func(c *sftp.Client) error {
+		info, err := c.Stat(root)
+		if err != nil {
+			return b.translateError(err, root)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("sftp: root %s is not a directory", root)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,23 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestPing(t *testing.T) {
+	s := newMemServer()
+	b := newTestBackend(t, s, Config{Root: "/data"})
+	ctx := context.Background()
+
+	if err := b.Ping(ctx); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Ping before root exists: err = %v, want ErrNotFound", err)
+	}
+	writeFile(t, b, "a.txt", "data")
+	if err := b.Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+const (
+	// reconnectBaseDelay is the initial delay between reconnection attempts.
+	reconnectBaseDelay = 100 * time.Millisecond
+
+	// reconnectMaxDelay caps the delay between reconnection attempts.
+	reconnectMaxDelay = 5 * time.Second
+)
+
+// conn is a single SSH connection carrying one SFTP session.
+type conn struct {
+	client   *sftp.Client
+	closer   io.Closer // underlying transport, closed after the client; may be nil
+	lastUsed time.Time
+}
+
+func (c *conn) close() error {
+	err := c.client.Close()
+	if c.closer != nil {
+		if cerr := c.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// dialFunc opens a new connection.
+type dialFunc func(ctx context.Context) (*conn, error)
+
+// pool hands out up to size connections and replaces broken ones.
+// Idle connections are probed before reuse once they have been idle longer
+// than healthAfter.
+type pool struct {
+	dial        dialFunc
+	slots       chan struct{}
+	retries     int
+	healthAfter time.Duration
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+func newPool(dial dialFunc, size, retries int, healthAfter time.Duration) *pool {
+	if size < 1 {
+		size = 1
+	}
+	return &pool{
+		dial:        dial,
+		slots:       make(chan struct{}, size),
+		retries:     retries,
+		healthAfter: healthAfter,
+	}
+}
+
+// get returns a healthy connection, dialing a new one if none is idle.
+// The caller must return it with put.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.slots
+			return nil, errPoolClosed
+		}
+		var c *conn
+		if n := len(p.idle); n > 0 {
+			c = p.idle[n-1]
+			p.idle = p.idle[:n-1]
+		}
+		p.mu.Unlock()
+
+		if c == nil {
+			break
+		}
+		if time.Since(c.lastUsed) < p.healthAfter {
+			return c, nil
+		}
+		if _, err := c.client.Getwd(); err == nil {
+			return c, nil
+		}
+		_ = c.close()
+	}
+
+	c, err := p.dialWithRetry(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return c, nil
+}
+
+// put returns a connection to the pool. Broken connections are closed.
+func (p *pool) put(c *conn, broken bool) {
+	p.mu.Lock()
+	if broken || p.closed {
+		p.mu.Unlock()
+		_ = c.close()
+	} else {
+		c.lastUsed = time.Now()
+		p.idle = append(p.idle, c)
+		p.mu.Unlock()
+	}
+	<-p.slots
+}
+
+// dialWithRetry dials with exponential backoff.
+func (p *pool) dialWithRetry(ctx context.Context) (*conn, error) {
+	for attempt := 0; ; attempt++ {
+		c, err := p.dial(ctx)
+		if err == nil {
+			return c, nil
+		}
+		if attempt >= p.retries {
+			return nil, err
+		}
+		if err := sleep(ctx, backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// close closes idle connections. Connections in use are closed when returned.
+func (p *pool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	var errs []error
+	for _, c := range p.idle {
+		if err := c.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.idle = nil
+	return errors.Join(errs...)
+}
+
+var errPoolClosed = errors.New("sftp: connection pool closed")
+
+// isConnError reports whether err means the connection is unusable.
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+func backoff(attempt int) time.Duration {
+	d := reconnectBaseDelay << attempt
+	if d <= 0 || d > reconnectMaxDelay {
+		d = reconnectMaxDelay
+	}
+	return d
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,249 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/pkg/sftp"
+)
+
+// memServer serves an in-memory filesystem to every connection it dials.
+type memServer struct {
+	handlers sftp.Handlers
+	opts     []sftp.ClientOption
+	dials    atomic.Int32
+	fail     atomic.Bool
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newMemServer() *memServer {
+	return &memServer{handlers: sftp.InMemHandler()}
+}
+
+func (s *memServer) dial(ctx context.Context) (*conn, error) {
+	if s.fail.Load() {
+		return nil, errors.New("dial refused")
+	}
+	s.dials.Add(1)
+
+	clientSide, serverSide := net.Pipe()
+	server := sftp.NewRequestServer(serverSide, s.handlers)
+	go func() { _ = server.Serve() }()
+
+	client, err := sftp.NewClientPipe(clientSide, clientSide, s.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.conns = append(s.conns, serverSide)
+	s.mu.Unlock()
+	return &conn{client: client, closer: clientSide}, nil
+}
+
+// drop severs every open connection from the server side.
+func (s *memServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		_ = c.Close()
+	}
+	s.conns = nil
+}
+
+func newTestBackend(t *testing.T, s *memServer, cfg Config) *Backend {
+	t.Helper()
+	b, err := newWithDialer(cfg, s.dial)
+	if err != nil {
+		t.Fatalf("newWithDialer: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+func writeFile(t *testing.T, b *Backend, p, data string) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewWriter(%s): %v", p, err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatalf("Write(%s): %v", p, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%s): %v", p, err)
+	}
+}
+
+func readFile(t *testing.T, b *Backend, p string) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewReader(%s): %v", p, err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll(%s): %v", p, err)
+	}
+	return string(data)
+}
+
+func TestPoolReusesConnections(t *testing.T) {
+	s := newMemServer()
+	b := newTestBackend(t, s, Config{Root: "/data", Concurrency: 2})
+
+	for i := 0; i < 5; i++ {
+		writeFile(t, b, "a.txt", "hello")
+	}
+	if got := readFile(t, b, "a.txt"); got != "hello" {
+		t.Errorf("read = %q, want %q", got, "hello")
+	}
+	if n := s.dials.Load(); n != 1 {
+		t.Errorf("dials = %d, want 1", n)
+	}
+}
+
+func TestPoolBoundsConcurrency(t *testing.T) {
+	s := newMemServer()
+	b := newTestBackend(t, s, Config{Root: "/data", Concurrency: 2})
+	ctx := context.Background()
+
+	w1, err := b.NewWriter(ctx, "one.txt")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	w2, err := b.NewWriter(ctx, "two.txt")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+
+	// Both connections are checked out, so a third operation must wait.
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := b.Exists(waitCtx, "one.txt"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Exists with pool exhausted: err = %v, want deadline exceeded", err)
+	}
+
+	_ = w1.Close()
+	_ = w2.Close()
+
+	if _, err := b.Exists(ctx, "one.txt"); err != nil {
+		t.Errorf("Exists after release: %v", err)
+	}
+	if n := s.dials.Load(); n != 2 {
+		t.Errorf("dials = %d, want 2", n)
+	}
+}
+
+func TestReconnectAfterDrop(t *testing.T) {
+	s := newMemServer()
+	b := newTestBackend(t, s, Config{Root: "/data", Concurrency: 1, HealthCheckInterval: 3600})
+
+	writeFile(t, b, "a.txt", "before")
+	s.drop()
+
+	// The idle connection is dead but not yet probed; the operation fails
+	// on it and is retried on a fresh connection.
+	exists, err := b.Exists(context.Background(), "a.txt")
+	if err != nil {
+		t.Fatalf("Exists after drop: %v", err)
+	}
+	if !exists {
+		t.Error("Exists after drop = false, want true")
+	}
+	if n := s.dials.Load(); n != 2 {
+		t.Errorf("dials = %d, want 2", n)
+	}
+}
+
+func TestHealthCheckReplacesDeadConnection(t *testing.T) {
+	s := newMemServer()
+	b := newTestBackend(t, s, Config{Root: "/data", Concurrency: 1, HealthCheckInterval: -1})
+
+	writeFile(t, b, "a.txt", "data")
+	s.drop()
+
+	if got := readFile(t, b, "a.txt"); got != "data" {
+		t.Errorf("read = %q, want %q", got, "data")
+	}
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	s := newMemServer()
+	b := newTestBackend(t, s, Config{Root: "/data", Concurrency: 1, ReconnectAttempts: 1, HealthCheckInterval: 3600})
+
+	s.fail.Store(true)
+	s.drop()
+
+	if _, err := b.Exists(context.Background(), "a.txt"); err == nil {
+		t.Error("Exists with server down: expected error")
+	}
+}
+
+// stallingLister delays every list and stat request while stall is set.
+type stallingLister struct {
+	sftp.FileLister
+	stall *atomic.Bool
+}
+
+func (l stallingLister) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	if l.stall.Load() {
+		time.Sleep(500 * time.Millisecond)
+	}
+	return l.FileLister.Filelist(r)
+}
+
+func TestOpTimeout(t *testing.T) {
+	s := newMemServer()
+	var stall atomic.Bool
+	s.handlers.FileList = stallingLister{FileLister: s.handlers.FileList, stall: &stall}
+	b := newTestBackend(t, s, Config{Root: "/data", Concurrency: 1, HealthCheckInterval: 3600})
+	b.opTimeout = 50 * time.Millisecond
+	writeFile(t, b, "a.txt", "data")
+
+	stall.Store(true)
+	start := time.Now()
+	_, err := b.Stat(context.Background(), "a.txt")
+	if !errors.Is(err, omnistorage.ErrTimeout) {
+		t.Errorf("Stat on a stalled server: err = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Stat took %v, want about 50ms", elapsed)
+	}
+
+	// The abandoned connection is replaced
+	stall.Store(false)
+	if _, err := b.Stat(context.Background(), "a.txt"); err != nil {
+		t.Errorf("Stat after timeout: %v", err)
+	}
+	if n := s.dials.Load(); n != 2 {
+		t.Errorf("dials = %d, want 2", n)
+	}
+}
+
+func TestPoolClosed(t *testing.T) {
+	s := newMemServer()
+	b, err := newWithDialer(Config{Root: "/data"}, s.dial)
+	if err != nil {
+		t.Fatalf("newWithDialer: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := b.pool.close(); err != nil {
+		t.Errorf("second pool close: %v", err)
+	}
+	if _, err := b.pool.get(context.Background()); !errors.Is(err, errPoolClosed) {
+		t.Errorf("get after close: err = %v, want errPoolClosed", err)
+	}
+}
@@ -0,0 +1,54 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/grokify/omnistorage"
+)
+
+// verifyingWriter checks the data written against the checksums given
+// with omnistorage.WithContentMD5 and WithContentSHA256. SFTP writes go
+// straight to the destination, so a file that does not match is removed
+// after it is closed.
+type verifyingWriter struct {
+	io.WriteCloser
+	path   string
+	verify *omnistorage.ChecksumVerifier
+	remove func()
+}
+
+func (w *verifyingWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	_, _ = w.verify.Write(p[:n])
+	return n, err
+}
+
+// Close closes the file and verifies its content.
+func (w *verifyingWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	if err := w.verify.Verify(); err != nil {
+		w.remove()
+		return fmt.Errorf("sftp: writing %s: %w", w.path, err)
+	}
+	return nil
+}
+
+// withVerify wraps w in a verifyingWriter if cfg has checksums.
+func (b *Backend) withVerify(ctx context.Context, w io.WriteCloser, p string, cfg *omnistorage.WriterConfig) io.WriteCloser {
+	verify := omnistorage.NewChecksumVerifier(cfg)
+	if verify == nil {
+		return w
+	}
+	return &verifyingWriter{
+		WriteCloser: w,
+		path:        p,
+		verify:      verify,
+		remove: func() {
+			_ = b.Delete(context.WithoutCancel(ctx), p)
+		},
+	}
+}
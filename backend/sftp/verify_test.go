@@ -0,0 +1,58 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestWriterChecksum(t *testing.T) {
+	for _, cfg := range []Config{
+		{Root: "/data"},
+		{Root: "/data", BufferSize: 1024},
+	} {
+		b := newTestBackend(t, newMemServer(), cfg)
+		ctx := context.Background()
+		data := []byte("checked")
+
+		write := func(p string, opts ...omnistorage.WriterOption) error {
+			w, err := b.NewWriter(ctx, p, opts...)
+			if err != nil {
+				t.Fatalf("NewWriter(%s): %v", p, err)
+			}
+			_, _ = w.Write(data)
+			return w.Close()
+		}
+
+		if err := write("good.txt", omnistorage.WithContentMD5(omnistorage.HashBytes(data, omnistorage.HashMD5))); err != nil {
+			t.Errorf("BufferSize %d: Close with matching checksum: %v", cfg.BufferSize, err)
+		}
+		if got := readFile(t, b, "good.txt"); got != string(data) {
+			t.Errorf("BufferSize %d: good.txt = %q", cfg.BufferSize, got)
+		}
+
+		err := write("bad.txt", omnistorage.WithContentSHA256(omnistorage.HashBytes([]byte("other"), omnistorage.HashSHA256)))
+		if !errors.Is(err, omnistorage.ErrChecksumMismatch) {
+			t.Errorf("BufferSize %d: Close with wrong checksum = %v, want ErrChecksumMismatch", cfg.BufferSize, err)
+		}
+		if exists, _ := b.Exists(ctx, "bad.txt"); exists {
+			t.Errorf("BufferSize %d: file with wrong checksum was kept", cfg.BufferSize)
+		}
+	}
+}
+
+// Without checksums the writer keeps the io.ReaderFrom fast path
+func TestWriterWithoutChecksum(t *testing.T) {
+	b := newTestBackend(t, newMemServer(), Config{Root: "/data", BufferSize: 1024})
+	w, err := b.NewWriter(context.Background(), "plain.txt")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+	if _, ok := w.(io.ReaderFrom); !ok {
+		t.Errorf("writer %T does not implement io.ReaderFrom", w)
+	}
+}
@@ -0,0 +1,380 @@
+// Package tiered provides a backend that combines a fast hot tier with a
+// slow, cheaper cold tier.
+//
+// Writes go to the hot tier. Reads try the hot tier first and fall back to
+// the cold tier, and List returns the paths of both. Migrate moves objects
+// that have not been modified for a while down to the cold tier:
+//
+//	backend := tiered.New(fileBackend, s3Backend, tiered.WithStubs())
+//	n, err := backend.Migrate(ctx, 30*24*time.Hour)
+//
+// With WithStubs, Migrate leaves a small stub object in the hot tier in
+// place of each object it moves, so tools that look at the hot tier alone
+// still see the path. Every backend that reads a tier with stubs must be
+// created WithStubs, or it will return the stubs' content.
+package tiered
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// stubMagic starts the content of every stub.
+const stubMagic = "omnistorage-tiered-stub\n"
+
+// maxStubSize bounds the size of a stub, so larger objects are known not
+// to be stubs without reading them.
+const maxStubSize = 4096
+
+// stub is the JSON that follows stubMagic, for the benefit of people
+// looking at the hot tier.
+type stub struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time,omitzero"`
+	Migrated time.Time `json:"migrated"`
+}
+
+// Option configures a tiered backend.
+type Option func(*Backend)
+
+// WithStubs makes Migrate leave a stub in the hot tier in place of each
+// object it moves, and makes reads recognize stubs and follow them to the
+// cold tier.
+func WithStubs() Option {
+	return func(b *Backend) {
+		b.stubs = true
+	}
+}
+
+// WithClock sets the function Migrate uses to tell the age of objects.
+// The default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(b *Backend) {
+		if now != nil {
+			b.now = now
+		}
+	}
+}
+
+// Backend combines a hot and a cold tier. Extended operations return
+// omnistorage.ErrNotSupported if the hot tier does not implement
+// omnistorage.ExtendedBackend; Stat of a cold object also needs the cold
+// tier to implement it.
+type Backend struct {
+	hot   omnistorage.Backend
+	cold  omnistorage.Backend
+	stubs bool
+	now   func() time.Time
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New combines the hot and cold tiers.
+func New(hot, cold omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{hot: hot, cold: cold, now: time.Now}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Hot returns the hot tier.
+func (b *Backend) Hot() omnistorage.Backend {
+	return b.hot
+}
+
+// Cold returns the cold tier.
+func (b *Backend) Cold() omnistorage.Backend {
+	return b.cold
+}
+
+// NewWriter creates a writer for the given path in the hot tier. An older
+// copy in the cold tier is hidden by the new object and replaced when it
+// is migrated.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	return b.hot.NewWriter(ctx, path, opts...)
+}
+
+// NewReader creates a reader for the given path, from the hot tier if it
+// holds the object and from the cold tier otherwise.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	tier, err := b.tierOf(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return tier.NewReader(ctx, path, opts...)
+}
+
+// Exists checks if a path exists in either tier.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	exists, err := b.hot.Exists(ctx, path)
+	if err != nil || exists {
+		return exists, err
+	}
+	return b.cold.Exists(ctx, path)
+}
+
+// Delete deletes path from both tiers. Like the tiers' Delete, it succeeds
+// if path does not exist.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	if err := b.hot.Delete(ctx, path); err != nil {
+		return err
+	}
+	return b.cold.Delete(ctx, path)
+}
+
+// List lists paths with the given prefix in either tier, sorted.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	hot, err := b.hot.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	cold, err := b.cold.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	paths := append(hot, cold...)
+	slices.Sort(paths)
+	return slices.Compact(paths), nil
+}
+
+// Close closes both tiers.
+func (b *Backend) Close() error {
+	return errors.Join(b.hot.Close(), b.cold.Close())
+}
+
+// tierOf returns the tier that holds the content of path: the hot tier if
+// it has the object and it is not a stub, and the cold tier otherwise.
+func (b *Backend) tierOf(ctx context.Context, path string) (omnistorage.Backend, error) {
+	if !b.stubs {
+		exists, err := b.hot.Exists(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return b.hot, nil
+		}
+		return b.cold, nil
+	}
+	isStub, err := b.isStub(ctx, path)
+	switch {
+	case errors.Is(err, omnistorage.ErrNotFound) || isStub:
+		return b.cold, nil
+	case err != nil:
+		return nil, err
+	}
+	return b.hot, nil
+}
+
+// isStub reports whether path in the hot tier is a stub.
+func (b *Backend) isStub(ctx context.Context, path string) (bool, error) {
+	r, err := b.hot.NewReader(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = r.Close() }()
+	head := make([]byte, len(stubMagic))
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return string(head[:n]) == stubMagic, nil
+}
+
+// Migrate moves objects in the hot tier that were last modified more than
+// olderThan ago, or every object if olderThan is 0, to the cold tier, and
+// returns the number moved. With WithStubs, each is replaced with a stub;
+// otherwise it is deleted from the hot tier. An object modified while it
+// is being moved is left in the hot tier. Migrate needs the hot tier to
+// implement omnistorage.ExtendedBackend, for modification times.
+func (b *Backend) Migrate(ctx context.Context, olderThan time.Duration) (int, error) {
+	hot, ok := omnistorage.AsExtended(b.hot)
+	if !ok {
+		return 0, omnistorage.ErrNotSupported
+	}
+	paths, err := hot.List(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	now := b.now()
+	cutoff := now.Add(-olderThan)
+	moved := 0
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return moved, err
+		}
+		info, err := hot.Stat(ctx, p)
+		if errors.Is(err, omnistorage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+		if info.IsDir() || (olderThan > 0 && !info.ModTime().Before(cutoff)) {
+			continue
+		}
+		if b.stubs && info.Size() <= maxStubSize {
+			isStub, err := b.isStub(ctx, p)
+			if err != nil {
+				return moved, err
+			}
+			if isStub {
+				continue
+			}
+		}
+		ok, err := b.migrate(ctx, hot, info, now)
+		if err != nil {
+			return moved, err
+		}
+		if ok {
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+// migrate copies the object described by info to the cold tier, then
+// replaces it in the hot tier with a stub or deletes it, unless it changed
+// during the copy.
+func (b *Backend) migrate(ctx context.Context, hot omnistorage.ExtendedBackend, info omnistorage.ObjectInfo, now time.Time) (bool, error) {
+	p := info.Path()
+	var opts []omnistorage.WriterOption
+	if ct := info.ContentType(); ct != "" {
+		opts = append(opts, omnistorage.WithContentType(ct))
+	}
+	if md := info.Metadata(); len(md) > 0 {
+		opts = append(opts, omnistorage.WithMetadata(md))
+	}
+	if err := omnistorage.CopyPath(ctx, hot, p, b.cold, p, opts...); err != nil {
+		return false, err
+	}
+
+	after, err := hot.Stat(ctx, p)
+	if errors.Is(err, omnistorage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if after.Size() != info.Size() || !after.ModTime().Equal(info.ModTime()) {
+		return false, nil
+	}
+
+	if !b.stubs {
+		return true, hot.Delete(ctx, p)
+	}
+	data, err := json.Marshal(stub{Size: info.Size(), ModTime: info.ModTime(), Migrated: now})
+	if err != nil {
+		return false, err
+	}
+	w, err := hot.NewWriter(ctx, p)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(w, io.MultiReader(strings.NewReader(stubMagic), bytes.NewReader(data))); err != nil {
+		_ = w.Close()
+		return false, err
+	}
+	return true, w.Close()
+}
+
+// Stat returns metadata about an object, from the tier that holds its
+// content.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	hot, ok := omnistorage.AsExtended(b.hot)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	info, err := hot.Stat(ctx, path)
+	if err == nil && !info.IsDir() && b.stubs && info.Size() <= maxStubSize {
+		var isStub bool
+		if isStub, err = b.isStub(ctx, path); err == nil && isStub {
+			err = omnistorage.ErrNotFound
+		}
+	}
+	if !errors.Is(err, omnistorage.ErrNotFound) {
+		return info, err
+	}
+	cold, ok := omnistorage.AsExtended(b.cold)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return cold.Stat(ctx, path)
+}
+
+// Mkdir creates a directory in the hot tier.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.hot)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory from both tiers. It fails with
+// omnistorage.ErrNotFound only if neither tier has the directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	hot, ok := omnistorage.AsExtended(b.hot)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	hotErr := hot.Rmdir(ctx, path)
+	if hotErr != nil && !errors.Is(hotErr, omnistorage.ErrNotFound) {
+		return hotErr
+	}
+	cold, ok := omnistorage.AsExtended(b.cold)
+	if !ok {
+		return hotErr
+	}
+	coldErr := cold.Rmdir(ctx, path)
+	if errors.Is(coldErr, omnistorage.ErrNotFound) {
+		return hotErr
+	}
+	return coldErr
+}
+
+// Copy copies an object to dst in the hot tier, streaming it through the
+// client.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	return omnistorage.CopyPath(ctx, b, src, b, dst)
+}
+
+// Move copies an object to dst in the hot tier, then deletes src from both
+// tiers.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	return omnistorage.MovePath(ctx, b, src, b, dst)
+}
+
+// Features returns the features both tiers support. Copy and Move stream
+// through the client, so they are reported as unsupported server-side.
+func (b *Backend) Features() omnistorage.Features {
+	hot, ok := omnistorage.AsExtended(b.hot)
+	if !ok {
+		return omnistorage.Features{}
+	}
+	f := hot.Features()
+	f.Copy, f.Move = false, false
+	cold := omnistorage.Features{}
+	if ext, ok := omnistorage.AsExtended(b.cold); ok {
+		cold = ext.Features()
+	}
+	f.Stat = f.Stat && cold.Stat
+	f.Rmdir = f.Rmdir && cold.Rmdir
+	f.RangeRead = f.RangeRead && cold.RangeRead
+	f.ListPrefix = f.ListPrefix && cold.ListPrefix
+	f.SetModTime = f.SetModTime && cold.SetModTime
+	f.CustomMetadata = f.CustomMetadata && cold.CustomMetadata
+	f.Hashes = slices.DeleteFunc(slices.Clone(f.Hashes), func(h omnistorage.HashType) bool {
+		return !cold.SupportsHash(h)
+	})
+	return f
+}
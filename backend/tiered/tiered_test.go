@@ -0,0 +1,179 @@
+package tiered
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/file"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), memory.New())
+	})
+}
+
+func TestConformanceStubs(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), memory.New(), WithStubs())
+	})
+}
+
+func write(t *testing.T, b omnistorage.Backend, p, data string) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewWriter(%s) error = %v", p, err)
+	}
+	_, _ = io.WriteString(w, data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%s) error = %v", p, err)
+	}
+}
+
+func read(t *testing.T, b omnistorage.Backend, p string, opts ...omnistorage.ReaderOption) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), p, opts...)
+	if err != nil {
+		t.Fatalf("NewReader(%s) error = %v", p, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func exists(t *testing.T, b omnistorage.Backend, p string) bool {
+	t.Helper()
+	ok, err := b.Exists(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Exists(%s) error = %v", p, err)
+	}
+	return ok
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	hot, cold := memory.New(), memory.New()
+	b := New(hot, cold)
+
+	write(t, b, "new.txt", "new")
+	write(t, cold, "old.txt", "old")
+	write(t, cold, "new.txt", "stale")
+
+	if !exists(t, hot, "new.txt") {
+		t.Error("write did not go to the hot tier")
+	}
+	if got := read(t, b, "new.txt"); got != "new" {
+		t.Errorf("new.txt = %q, want the hot copy", got)
+	}
+	if got := read(t, b, "old.txt"); got != "old" {
+		t.Errorf("old.txt = %q, want the cold copy", got)
+	}
+	if !exists(t, b, "old.txt") {
+		t.Error("old.txt does not exist")
+	}
+	paths, err := b.List(ctx, "")
+	if err != nil || !slices.Equal(paths, []string{"new.txt", "old.txt"}) {
+		t.Errorf("List = %v, %v", paths, err)
+	}
+
+	if err := b.Delete(ctx, "new.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(t, hot, "new.txt") || exists(t, cold, "new.txt") {
+		t.Error("Delete left a copy in a tier")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	for _, stubs := range []bool{false, true} {
+		dir := t.TempDir()
+		hot, cold := file.New(file.Config{Root: dir, CreateDirs: true}), memory.New()
+		var opts []Option
+		if stubs {
+			opts = append(opts, WithStubs())
+		}
+		b := New(hot, cold, opts...)
+		write(t, b, "logs/2023.log", "last year")
+		write(t, b, "logs/today.log", "today")
+		old := time.Now().Add(-48 * time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, "logs", "2023.log"), old, old); err != nil {
+			t.Fatal(err)
+		}
+
+		// Nothing is old enough yet
+		if n, err := b.Migrate(ctx, 72*time.Hour); err != nil || n != 0 {
+			t.Fatalf("stubs=%v: Migrate = %d, %v, want 0", stubs, n, err)
+		}
+
+		if n, err := b.Migrate(ctx, time.Hour); err != nil || n != 1 {
+			t.Fatalf("stubs=%v: Migrate = %d, %v, want 1", stubs, n, err)
+		}
+		if got := read(t, cold, "logs/2023.log"); got != "last year" {
+			t.Errorf("stubs=%v: cold copy = %q", stubs, got)
+		}
+		if exists(t, cold, "logs/today.log") {
+			t.Errorf("stubs=%v: recent log was migrated", stubs)
+		}
+		if got := exists(t, hot, "logs/2023.log"); got != stubs {
+			t.Errorf("stubs=%v: hot tier has logs/2023.log = %v", stubs, got)
+		}
+		if stubs {
+			if got := read(t, hot, "logs/2023.log"); !strings.HasPrefix(got, stubMagic) {
+				t.Errorf("hot copy = %q, want a stub", got)
+			}
+		}
+
+		// Reads, ranges, Stat and List see through the migration
+		if got := read(t, b, "logs/2023.log"); got != "last year" {
+			t.Errorf("stubs=%v: read = %q", stubs, got)
+		}
+		if got := read(t, b, "logs/2023.log", omnistorage.WithOffset(5)); got != "year" {
+			t.Errorf("stubs=%v: range read = %q", stubs, got)
+		}
+		info, err := b.Stat(ctx, "logs/2023.log")
+		if err != nil || info.Size() != int64(len("last year")) {
+			t.Errorf("stubs=%v: Stat = %v, %v", stubs, info, err)
+		}
+		paths, err := b.List(ctx, "logs/")
+		if err != nil || !slices.Equal(paths, []string{"2023.log", "today.log"}) {
+			t.Errorf("stubs=%v: List = %v, %v", stubs, paths, err)
+		}
+
+		// Stubs are not migrated again, and everything moves with 0
+		if n, err := b.Migrate(ctx, 0); err != nil || n != 1 {
+			t.Errorf("stubs=%v: Migrate(0) = %d, %v, want 1", stubs, n, err)
+		}
+		if got := read(t, cold, "logs/2023.log"); got != "last year" {
+			t.Errorf("stubs=%v: cold copy after second Migrate = %q", stubs, got)
+		}
+
+		// A rewrite goes to the hot tier and hides the cold copy
+		write(t, b, "logs/2023.log", "rewritten")
+		if got := read(t, b, "logs/2023.log"); got != "rewritten" {
+			t.Errorf("stubs=%v: read after rewrite = %q", stubs, got)
+		}
+	}
+}
+
+func TestMigrateNotSupported(t *testing.T) {
+	b := New(basicBackend{memory.New()}, memory.New())
+	if _, err := b.Migrate(context.Background(), 0); err != omnistorage.ErrNotSupported {
+		t.Errorf("Migrate error = %v, want ErrNotSupported", err)
+	}
+}
+
+// basicBackend hides the extended methods of a backend.
+type basicBackend struct {
+	omnistorage.Backend
+}
@@ -0,0 +1,684 @@
+// Package backendtest provides a conformance test suite for omnistorage
+// backend implementations.
+//
+// Backend authors call RunConformanceTests from a test with a factory that
+// returns a fresh, empty backend:
+//
+//	func TestConformance(t *testing.T) {
+//	    backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+//	        return mybackend.New(mybackend.Config{Root: t.TempDir()})
+//	    })
+//	}
+//
+// The suite checks the documented Backend contract: not-found semantics,
+// path normalization, range reads, idempotent delete, closed-backend errors,
+// context cancellation and concurrent writers. Backends that implement
+// ExtendedBackend are also checked against the ExtendedBackend contract;
+// operations that return ErrNotSupported are skipped, not failed.
+package backendtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Factory returns a new, empty backend for a single test. Backends are
+// closed when the test finishes, so the factory need not register cleanup
+// for the backend itself.
+type Factory func(t *testing.T) omnistorage.Backend
+
+// Option configures RunConformanceTests.
+type Option func(*config)
+
+type config struct {
+	skip        map[string]string
+	concurrency int
+}
+
+// WithSkip skips the named tests, such as "ClosedBackend" or
+// "Extended/Mkdir", logging reason. Use it for documented deviations from
+// the contract.
+func WithSkip(reason string, names ...string) Option {
+	return func(c *config) {
+		for _, name := range names {
+			c.skip[name] = reason
+		}
+	}
+}
+
+// WithConcurrency sets the number of goroutines used by the concurrency
+// tests. Default: 8.
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// RunConformanceTests runs the conformance suite against backends created
+// by factory. Each test runs as a subtest with its own backend.
+func RunConformanceTests(t *testing.T, factory Factory, opts ...Option) {
+	t.Helper()
+
+	cfg := &config{skip: make(map[string]string), concurrency: 8}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	s := &suite{factory: factory, cfg: cfg}
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b omnistorage.Backend)
+	}{
+		{"WriteRead", s.testWriteRead},
+		{"Overwrite", s.testOverwrite},
+		{"EmptyObject", s.testEmptyObject},
+		{"NotFound", s.testNotFound},
+		{"Exists", s.testExists},
+		{"DeleteIdempotent", s.testDeleteIdempotent},
+		{"List", s.testList},
+		{"PathNormalization", s.testPathNormalization},
+		{"RangeRead", s.testRangeRead},
+		{"WriterClose", s.testWriterClose},
+		{"ContextCanceled", s.testContextCanceled},
+		{"ConcurrentWriters", s.testConcurrentWriters},
+		{"ConcurrentSamePath", s.testConcurrentSamePath},
+		{"ClosedBackend", s.testClosedBackend},
+	}
+	for _, tt := range tests {
+		s.run(t, tt.name, tt.fn)
+	}
+
+	t.Run("Extended", func(t *testing.T) {
+		probe := factory(t)
+		_, ok := omnistorage.AsExtended(probe)
+		_ = probe.Close()
+		if !ok {
+			t.Skip("backend does not implement ExtendedBackend")
+		}
+
+		extTests := []struct {
+			name string
+			fn   func(t *testing.T, b omnistorage.ExtendedBackend)
+		}{
+			{"Stat", s.testStat},
+			{"StatNotFound", s.testStatNotFound},
+			{"Mkdir", s.testMkdir},
+			{"Rmdir", s.testRmdir},
+			{"Copy", s.testCopy},
+			{"CopyNotFound", s.testCopyNotFound},
+			{"Move", s.testMove},
+			{"MoveNotFound", s.testMoveNotFound},
+		}
+		for _, tt := range extTests {
+			fn := tt.fn
+			s.run(t, "Extended/"+tt.name, func(t *testing.T, b omnistorage.Backend) {
+				fn(t, omnistorage.MustExtended(b))
+			})
+		}
+	})
+}
+
+type suite struct {
+	factory Factory
+	cfg     *config
+}
+
+// run runs fn as a subtest named by the last element of name, unless name
+// is skipped.
+func (s *suite) run(t *testing.T, name string, fn func(t *testing.T, b omnistorage.Backend)) {
+	t.Helper()
+	short := name
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		short = name[i+1:]
+	}
+	t.Run(short, func(t *testing.T) {
+		if reason, ok := s.cfg.skip[name]; ok {
+			t.Skip(reason)
+		}
+		b := s.factory(t)
+		t.Cleanup(func() { _ = b.Close() })
+		fn(t, b)
+	})
+}
+
+func (s *suite) testWriteRead(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	data := []byte("hello, conformance")
+	mustWrite(t, b, "file.txt", data)
+
+	if got := mustRead(t, b, "file.txt"); !bytes.Equal(got, data) {
+		t.Errorf("read %q, want %q", got, data)
+	}
+
+	// Larger than typical buffers, with every byte value.
+	big := make([]byte, 1<<20+17)
+	for i := range big {
+		big[i] = byte(i)
+	}
+	mustWrite(t, b, "dir/big.bin", big)
+	if got := mustRead(t, b, "dir/big.bin"); !bytes.Equal(got, big) {
+		t.Errorf("read %d bytes of big.bin, want %d matching bytes", len(got), len(big))
+	}
+
+	// A nested write must not disturb the first object.
+	if ok, err := b.Exists(ctx, "file.txt"); err != nil || !ok {
+		t.Errorf("Exists(file.txt) = %v, %v, want true, nil", ok, err)
+	}
+}
+
+func (s *suite) testOverwrite(t *testing.T, b omnistorage.Backend) {
+	mustWrite(t, b, "file.txt", []byte("first version, longer"))
+	mustWrite(t, b, "file.txt", []byte("second"))
+
+	if got := mustRead(t, b, "file.txt"); string(got) != "second" {
+		t.Errorf("read %q after overwrite, want %q", got, "second")
+	}
+}
+
+func (s *suite) testEmptyObject(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	mustWrite(t, b, "empty.txt", nil)
+
+	if ok, err := b.Exists(ctx, "empty.txt"); err != nil || !ok {
+		t.Errorf("Exists(empty.txt) = %v, %v, want true, nil", ok, err)
+	}
+	if got := mustRead(t, b, "empty.txt"); len(got) != 0 {
+		t.Errorf("read %q, want empty", got)
+	}
+}
+
+func (s *suite) testNotFound(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+
+	r, err := b.NewReader(ctx, "missing.txt")
+	if err == nil {
+		_ = r.Close()
+		t.Fatal("NewReader(missing.txt) succeeded, want ErrNotFound")
+	}
+	if !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("NewReader(missing.txt) error = %v, want ErrNotFound", err)
+	}
+
+	if ok, err := b.Exists(ctx, "missing.txt"); err != nil || ok {
+		t.Errorf("Exists(missing.txt) = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func (s *suite) testExists(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	mustWrite(t, b, "a/b.txt", []byte("x"))
+
+	if ok, err := b.Exists(ctx, "a/b.txt"); err != nil || !ok {
+		t.Errorf("Exists(a/b.txt) = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := b.Exists(ctx, "a/b.tx"); err != nil || ok {
+		t.Errorf("Exists(a/b.tx) = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func (s *suite) testDeleteIdempotent(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	mustWrite(t, b, "file.txt", []byte("x"))
+
+	if err := b.Delete(ctx, "file.txt"); err != nil {
+		t.Fatalf("Delete(file.txt) error = %v", err)
+	}
+	if ok, err := b.Exists(ctx, "file.txt"); err != nil || ok {
+		t.Errorf("Exists after Delete = %v, %v, want false, nil", ok, err)
+	}
+	if err := b.Delete(ctx, "file.txt"); err != nil {
+		t.Errorf("second Delete error = %v, want nil", err)
+	}
+	if err := b.Delete(ctx, "never/existed.txt"); err != nil {
+		t.Errorf("Delete(never/existed.txt) error = %v, want nil", err)
+	}
+}
+
+func (s *suite) testList(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	for _, p := range []string{"logs/a.txt", "logs/b.txt", "logs/sub/c.txt", "logs2.txt", "other.txt"} {
+		mustWrite(t, b, p, []byte(p))
+	}
+
+	// Prefixes name directories, and paths are relative to them
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"logs/a.txt", "logs/b.txt", "logs/sub/c.txt", "logs2.txt", "other.txt"}},
+		{"/", []string{"logs/a.txt", "logs/b.txt", "logs/sub/c.txt", "logs2.txt", "other.txt"}},
+		{"logs", []string{"a.txt", "b.txt", "sub/c.txt"}},
+		{"logs/", []string{"a.txt", "b.txt", "sub/c.txt"}},
+		{"/logs", []string{"a.txt", "b.txt", "sub/c.txt"}},
+		{"log", nil},
+		{"logs/sub", []string{"c.txt"}},
+		{"logs/su", nil},
+		{"logs/a.txt", nil},
+		{"nothing", nil},
+		{"logs/nothing/", nil},
+	}
+	for _, tt := range tests {
+		got, err := b.List(ctx, tt.prefix)
+		if err != nil {
+			t.Errorf("List(%q) error = %v", tt.prefix, err)
+			continue
+		}
+		got = withoutDirs(got)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("List(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func (s *suite) testPathNormalization(t *testing.T, b omnistorage.Backend) {
+	mustWrite(t, b, "/a/b/c.txt", []byte("leading slash"))
+
+	if got := mustRead(t, b, "a/b/c.txt"); string(got) != "leading slash" {
+		t.Errorf("read a/b/c.txt = %q, want %q", got, "leading slash")
+	}
+
+	paths, err := b.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	if got := withoutDirs(paths); !slices.Equal(got, []string{"a/b/c.txt"}) {
+		t.Errorf("List = %q, want relative path [a/b/c.txt]", got)
+	}
+}
+
+func (s *suite) testRangeRead(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	data := []byte("0123456789")
+	mustWrite(t, b, "range.txt", data)
+
+	tests := []struct {
+		name string
+		opts []omnistorage.ReaderOption
+		want string
+	}{
+		{"offset", []omnistorage.ReaderOption{omnistorage.WithOffset(3)}, "3456789"},
+		{"limit", []omnistorage.ReaderOption{omnistorage.WithLimit(4)}, "0123"},
+		{"offset and limit", []omnistorage.ReaderOption{omnistorage.WithOffset(2), omnistorage.WithLimit(5)}, "23456"},
+		{"limit past end", []omnistorage.ReaderOption{omnistorage.WithOffset(8), omnistorage.WithLimit(10)}, "89"},
+		{"offset at end", []omnistorage.ReaderOption{omnistorage.WithOffset(10)}, ""},
+	}
+	for _, tt := range tests {
+		r, err := b.NewReader(ctx, "range.txt", tt.opts...)
+		if err != nil {
+			t.Errorf("%s: NewReader error = %v", tt.name, err)
+			continue
+		}
+		got, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			t.Errorf("%s: read error = %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: read %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func (s *suite) testWriterClose(t *testing.T, b omnistorage.Backend) {
+	w, err := b.NewWriter(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatalf("NewWriter error = %v", err)
+	}
+	if _, err := w.Write([]byte("data")); err != nil {
+		t.Fatalf("Write error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	if _, err := w.Write([]byte("more")); err == nil {
+		t.Error("Write after Close succeeded, want error")
+	}
+
+	r, err := b.NewReader(context.Background(), "file.txt")
+	if err != nil {
+		t.Fatalf("NewReader error = %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("reader Close error = %v", err)
+	}
+	if _, err := r.Read(make([]byte, 1)); err == nil {
+		t.Error("Read after Close succeeded, want error")
+	}
+}
+
+func (s *suite) testContextCanceled(t *testing.T, b omnistorage.Backend) {
+	mustWrite(t, b, "file.txt", []byte("x"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if w, err := b.NewWriter(ctx, "new.txt"); err == nil {
+		// Some backends only observe the context when the write is
+		// committed.
+		_, _ = w.Write([]byte("x"))
+		if err := w.Close(); err == nil {
+			t.Error("write with canceled context succeeded, want error")
+		}
+	} else if !errors.Is(err, context.Canceled) {
+		t.Errorf("NewWriter error = %v, want context.Canceled", err)
+	}
+
+	if r, err := b.NewReader(ctx, "file.txt"); err == nil {
+		_ = r.Close()
+		t.Error("NewReader with canceled context succeeded, want error")
+	} else if !errors.Is(err, context.Canceled) {
+		t.Errorf("NewReader error = %v, want context.Canceled", err)
+	}
+
+	if _, err := b.Exists(ctx, "file.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Exists error = %v, want context.Canceled", err)
+	}
+	if err := b.Delete(ctx, "file.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Delete error = %v, want context.Canceled", err)
+	}
+	if _, err := b.List(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("List error = %v, want context.Canceled", err)
+	}
+}
+
+func (s *suite) testConcurrentWriters(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	n := s.cfg.concurrency
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := fmt.Sprintf("concurrent/%d.txt", i)
+			errs <- write(ctx, b, p, bytes.Repeat([]byte{byte('a' + i%26)}, 1000+i))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent write error = %v", err)
+		}
+	}
+
+	for i := range n {
+		p := fmt.Sprintf("concurrent/%d.txt", i)
+		want := bytes.Repeat([]byte{byte('a' + i%26)}, 1000+i)
+		if got := mustRead(t, b, p); !bytes.Equal(got, want) {
+			t.Errorf("read %s: %d bytes, want %d bytes of %q", p, len(got), len(want), want[0])
+		}
+	}
+
+	paths, err := b.List(ctx, "concurrent")
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	if got := len(withoutDirs(paths)); got != n {
+		t.Errorf("List returned %d paths, want %d", got, n)
+	}
+}
+
+// testConcurrentSamePath checks that concurrent writes to one path never
+// interleave: the result must be exactly one writer's data.
+func (s *suite) testConcurrentSamePath(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	n := s.cfg.concurrency
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Errors are allowed; backends may reject concurrent writers.
+			_ = write(ctx, b, "same.txt", bytes.Repeat([]byte{byte('a' + i%26)}, 64<<10))
+		}()
+	}
+	wg.Wait()
+
+	got := mustRead(t, b, "same.txt")
+	if len(got) != 64<<10 {
+		t.Fatalf("read %d bytes, want %d", len(got), 64<<10)
+	}
+	if bytes.Count(got, got[:1]) != len(got) {
+		t.Error("concurrent writes to the same path were interleaved")
+	}
+}
+
+func (s *suite) testClosedBackend(t *testing.T, b omnistorage.Backend) {
+	ctx := context.Background()
+	mustWrite(t, b, "file.txt", []byte("x"))
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	if _, err := b.NewWriter(ctx, "file.txt"); !errors.Is(err, omnistorage.ErrBackendClosed) {
+		t.Errorf("NewWriter after Close error = %v, want ErrBackendClosed", err)
+	}
+	if _, err := b.NewReader(ctx, "file.txt"); !errors.Is(err, omnistorage.ErrBackendClosed) {
+		t.Errorf("NewReader after Close error = %v, want ErrBackendClosed", err)
+	}
+	if _, err := b.Exists(ctx, "file.txt"); !errors.Is(err, omnistorage.ErrBackendClosed) {
+		t.Errorf("Exists after Close error = %v, want ErrBackendClosed", err)
+	}
+	if err := b.Delete(ctx, "file.txt"); !errors.Is(err, omnistorage.ErrBackendClosed) {
+		t.Errorf("Delete after Close error = %v, want ErrBackendClosed", err)
+	}
+	if _, err := b.List(ctx, ""); !errors.Is(err, omnistorage.ErrBackendClosed) {
+		t.Errorf("List after Close error = %v, want ErrBackendClosed", err)
+	}
+
+	if ext, ok := omnistorage.AsExtended(b); ok {
+		if _, err := ext.Stat(ctx, "file.txt"); !errors.Is(err, omnistorage.ErrBackendClosed) {
+			t.Errorf("Stat after Close error = %v, want ErrBackendClosed", err)
+		}
+	}
+}
+
+func (s *suite) testStat(t *testing.T, b omnistorage.ExtendedBackend) {
+	ctx := context.Background()
+	mustWrite(t, b, "dir/file.txt", []byte("hello"))
+
+	info, err := b.Stat(ctx, "dir/file.txt")
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Skip("Stat not supported")
+	}
+	if err != nil {
+		t.Fatalf("Stat error = %v", err)
+	}
+	if info.Path() != "dir/file.txt" {
+		t.Errorf("Path() = %q, want %q", info.Path(), "dir/file.txt")
+	}
+	if info.Size() != 5 {
+		t.Errorf("Size() = %d, want 5", info.Size())
+	}
+	if info.IsDir() {
+		t.Error("IsDir() = true, want false")
+	}
+	if !b.Features().Stat {
+		t.Error("Stat succeeded but Features().Stat is false")
+	}
+}
+
+func (s *suite) testStatNotFound(t *testing.T, b omnistorage.ExtendedBackend) {
+	_, err := b.Stat(context.Background(), "missing.txt")
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Skip("Stat not supported")
+	}
+	if !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Stat(missing.txt) error = %v, want ErrNotFound", err)
+	}
+}
+
+func (s *suite) testMkdir(t *testing.T, b omnistorage.ExtendedBackend) {
+	ctx := context.Background()
+
+	err := b.Mkdir(ctx, "a/b/c")
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		if b.Features().Mkdir {
+			t.Error("Mkdir returned ErrNotSupported but Features().Mkdir is true")
+		}
+		t.Skip("Mkdir not supported")
+	}
+	if err != nil {
+		t.Fatalf("Mkdir(a/b/c) error = %v", err)
+	}
+	if err := b.Mkdir(ctx, "a/b/c"); err != nil {
+		t.Errorf("second Mkdir(a/b/c) error = %v, want nil", err)
+	}
+
+	// Files can be written into the new directory.
+	mustWrite(t, b, "a/b/c/file.txt", []byte("x"))
+}
+
+func (s *suite) testRmdir(t *testing.T, b omnistorage.ExtendedBackend) {
+	ctx := context.Background()
+
+	if err := b.Mkdir(ctx, "dir"); errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Skip("Mkdir not supported")
+	} else if err != nil {
+		t.Fatalf("Mkdir(dir) error = %v", err)
+	}
+	mustWrite(t, b, "dir/file.txt", []byte("x"))
+
+	err := b.Rmdir(ctx, "dir")
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Skip("Rmdir not supported")
+	}
+	if err == nil {
+		t.Error("Rmdir of non-empty directory succeeded, want error")
+	}
+
+	if err := b.Delete(ctx, "dir/file.txt"); err != nil {
+		t.Fatalf("Delete error = %v", err)
+	}
+	if err := b.Rmdir(ctx, "dir"); err != nil {
+		t.Errorf("Rmdir of empty directory error = %v", err)
+	}
+	if err := b.Rmdir(ctx, "missing"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Rmdir(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func (s *suite) testCopy(t *testing.T, b omnistorage.ExtendedBackend) {
+	mustWrite(t, b, "src.txt", []byte("copy me"))
+
+	err := b.Copy(context.Background(), "src.txt", "dir/dst.txt")
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		if b.Features().Copy {
+			t.Error("Copy returned ErrNotSupported but Features().Copy is true")
+		}
+		t.Skip("Copy not supported")
+	}
+	if err != nil {
+		t.Fatalf("Copy error = %v", err)
+	}
+	if got := mustRead(t, b, "dir/dst.txt"); string(got) != "copy me" {
+		t.Errorf("read dst = %q, want %q", got, "copy me")
+	}
+	if got := mustRead(t, b, "src.txt"); string(got) != "copy me" {
+		t.Errorf("read src after Copy = %q, want %q", got, "copy me")
+	}
+}
+
+func (s *suite) testCopyNotFound(t *testing.T, b omnistorage.ExtendedBackend) {
+	err := b.Copy(context.Background(), "missing.txt", "dst.txt")
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Skip("Copy not supported")
+	}
+	if !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Copy(missing.txt) error = %v, want ErrNotFound", err)
+	}
+}
+
+func (s *suite) testMove(t *testing.T, b omnistorage.ExtendedBackend) {
+	ctx := context.Background()
+	mustWrite(t, b, "src.txt", []byte("move me"))
+
+	err := b.Move(ctx, "src.txt", "dir/dst.txt")
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		if b.Features().Move {
+			t.Error("Move returned ErrNotSupported but Features().Move is true")
+		}
+		t.Skip("Move not supported")
+	}
+	if err != nil {
+		t.Fatalf("Move error = %v", err)
+	}
+	if got := mustRead(t, b, "dir/dst.txt"); string(got) != "move me" {
+		t.Errorf("read dst = %q, want %q", got, "move me")
+	}
+	if ok, err := b.Exists(ctx, "src.txt"); err != nil || ok {
+		t.Errorf("Exists(src.txt) after Move = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func (s *suite) testMoveNotFound(t *testing.T, b omnistorage.ExtendedBackend) {
+	err := b.Move(context.Background(), "missing.txt", "dst.txt")
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Skip("Move not supported")
+	}
+	if !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Move(missing.txt) error = %v, want ErrNotFound", err)
+	}
+}
+
+func write(ctx context.Context, b omnistorage.Backend, p string, data []byte) error {
+	w, err := b.NewWriter(ctx, p)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func mustWrite(t *testing.T, b omnistorage.Backend, p string, data []byte) {
+	t.Helper()
+	if err := write(context.Background(), b, p, data); err != nil {
+		t.Fatalf("write %s: %v", p, err)
+	}
+}
+
+func mustRead(t *testing.T, b omnistorage.Backend, p string) []byte {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewReader(%s) error = %v", p, err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read %s: %v", p, err)
+	}
+	return data
+}
+
+// withoutDirs drops directory entries, which some backends include in List
+// results with a trailing slash.
+func withoutDirs(paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		if p != "" && p[len(p)-1] != '/' {
+			out = append(out, p)
+		}
+	}
+	return out
+}
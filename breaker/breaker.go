@@ -0,0 +1,425 @@
+// Package breaker provides a backend wrapper with a circuit breaker.
+//
+// When a backend starts failing, for example an SFTP server that stops
+// responding, every worker of a sync would otherwise wait out its own
+// timeout on every file. The breaker trips after a number of consecutive
+// failures and then fails fast with ErrOpen for a cooldown period. After
+// the cooldown, a single request is let through as a probe: if it
+// succeeds the breaker closes and traffic resumes, otherwise it opens for
+// another cooldown.
+//
+//	backend := breaker.New(sftpBackend,
+//	    breaker.WithThreshold(5),
+//	    breaker.WithCooldown(time.Minute),
+//	)
+//
+// Errors that a healthy backend returns, such as omnistorage.ErrNotFound
+// or ErrPermissionDenied, and context cancellation by the caller do not
+// count as failures. Use WithFailure to change that.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// ErrOpen is returned without calling the wrapped backend while the
+// breaker is open.
+var ErrOpen = errors.New("breaker: circuit open")
+
+// Defaults.
+const (
+	DefaultThreshold = 5
+	DefaultCooldown  = 30 * time.Second
+)
+
+// State is the state of a breaker.
+type State int
+
+const (
+	// Closed passes requests through and counts consecutive failures.
+	Closed State = iota
+
+	// Open fails requests with ErrOpen until the cooldown has elapsed.
+	Open
+
+	// HalfOpen lets a single probe request through; others fail with
+	// ErrOpen until it completes.
+	HalfOpen
+)
+
+// String returns the state name.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Option configures a breaker.
+type Option func(*Backend)
+
+// WithThreshold sets the number of consecutive failures that trips the
+// breaker. Default: DefaultThreshold.
+func WithThreshold(n int) Option {
+	return func(b *Backend) {
+		if n > 0 {
+			b.threshold = n
+		}
+	}
+}
+
+// WithCooldown sets how long the breaker stays open before probing.
+// Default: DefaultCooldown.
+func WithCooldown(d time.Duration) Option {
+	return func(b *Backend) {
+		if d > 0 {
+			b.cooldown = d
+		}
+	}
+}
+
+// WithFailure sets the function that decides whether an error counts as
+// a backend failure. Default: IsFailure.
+func WithFailure(fn func(error) bool) Option {
+	return func(b *Backend) {
+		b.isFailure = fn
+	}
+}
+
+// WithOnStateChange sets a function called on every state change, for
+// logging or alerting. It is called with the breaker's lock held and must
+// not call the breaker.
+func WithOnStateChange(fn func(from, to State)) Option {
+	return func(b *Backend) {
+		b.onChange = fn
+	}
+}
+
+// IsFailure reports whether err indicates an unhealthy backend. Errors
+// that a healthy backend returns, such as ErrNotFound, and cancellation
+// by the caller are not failures; timeouts are.
+func IsFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, ErrOpen),
+		errors.Is(err, omnistorage.ErrNotFound),
+		errors.Is(err, omnistorage.ErrAlreadyExists),
+		errors.Is(err, omnistorage.ErrPermissionDenied),
+		errors.Is(err, omnistorage.ErrInvalidPath),
+		errors.Is(err, omnistorage.ErrNotSupported),
+		errors.Is(err, omnistorage.ErrBackendClosed):
+		return false
+	default:
+		return true
+	}
+}
+
+// Backend wraps a backend with a circuit breaker. Extended operations
+// return omnistorage.ErrNotSupported if the wrapped backend does not
+// implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend   omnistorage.Backend
+	threshold int
+	cooldown  time.Duration
+	isFailure func(error) bool
+	onChange  func(from, to State)
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int // consecutive failures
+	openedAt time.Time
+	probing  bool
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend with a circuit breaker.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{
+		backend:   backend,
+		threshold: DefaultThreshold,
+		cooldown:  DefaultCooldown,
+		isFailure: IsFailure,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// State returns the current state. An open breaker whose cooldown has
+// elapsed reports Open until the next request probes it.
+func (b *Backend) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Reset closes the breaker and clears the failure count.
+func (b *Backend) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	b.setState(Closed)
+}
+
+func (b *Backend) setState(s State) {
+	if b.state == s {
+		return
+	}
+	from := b.state
+	b.state = s
+	if s == Open {
+		b.openedAt = b.now()
+	}
+	if b.onChange != nil {
+		b.onChange(from, s)
+	}
+}
+
+// allow reports whether a request may proceed, and whether it is the
+// probe of a half-open breaker.
+func (b *Backend) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false, ErrOpen
+		}
+		b.setState(HalfOpen)
+	case HalfOpen:
+	default:
+		return false, nil
+	}
+
+	if b.probing {
+		return false, ErrOpen
+	}
+	b.probing = true
+	return true, nil
+}
+
+// record records the outcome of a request.
+func (b *Backend) record(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	if err != nil && b.isFailure(err) {
+		b.failures++
+		if b.state == HalfOpen || b.failures >= b.threshold {
+			b.setState(Open)
+		}
+		return
+	}
+	b.failures = 0
+	if probe && b.state == HalfOpen {
+		b.setState(Closed)
+	}
+}
+
+// call runs f through the breaker.
+func (b *Backend) call(f func() error) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = f()
+	b.record(probe, err)
+	return err
+}
+
+// NewWriter creates a writer. Write and Close errors count as failures.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	var w io.WriteCloser
+	err := b.call(func() (err error) {
+		w, err = b.backend.NewWriter(ctx, path, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &writer{w: w, b: b}, nil
+}
+
+// NewReader creates a reader. Read errors other than io.EOF count as
+// failures.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	var r io.ReadCloser
+	err := b.call(func() (err error) {
+		r, err = b.backend.NewReader(ctx, path, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reader{r: r, b: b}, nil
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	var ok bool
+	err := b.call(func() (err error) {
+		ok, err = b.backend.Exists(ctx, path)
+		return err
+	})
+	return ok, err
+}
+
+// Delete removes a path.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	return b.call(func() error {
+		return b.backend.Delete(ctx, path)
+	})
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	var paths []string
+	err := b.call(func() (err error) {
+		paths, err = b.backend.List(ctx, prefix)
+		return err
+	})
+	return paths, err
+}
+
+// Close closes the wrapped backend. It is not guarded by the breaker.
+func (b *Backend) Close() error {
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	var info omnistorage.ObjectInfo
+	err := b.call(func() (err error) {
+		info, err = ext.Stat(ctx, path)
+		return err
+	})
+	return info, err
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return b.call(func() error {
+		return ext.Mkdir(ctx, path)
+	})
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return b.call(func() error {
+		return ext.Rmdir(ctx, path)
+	})
+}
+
+// Copy copies an object within the wrapped backend.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return b.call(func() error {
+		return ext.Copy(ctx, src, dst)
+	})
+}
+
+// Move moves an object within the wrapped backend.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return b.call(func() error {
+		return ext.Move(ctx, src, dst)
+	})
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// reader records the first read error other than io.EOF as a failure.
+type reader struct {
+	r      io.ReadCloser
+	b      *Backend
+	failed bool
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && !r.failed && !errors.Is(err, io.EOF) {
+		r.failed = true
+		r.b.record(false, err)
+	}
+	return n, err
+}
+
+func (r *reader) Close() error {
+	return r.r.Close()
+}
+
+// writer records the outcome of the write when it is closed.
+type writer struct {
+	w    io.WriteCloser
+	b    *Backend
+	err  error // first write error
+	once sync.Once
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *writer) Close() error {
+	err := w.w.Close()
+	w.once.Do(func() {
+		w.b.record(false, errors.Join(w.err, err))
+	})
+	return err
+}
@@ -0,0 +1,234 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New())
+	})
+}
+
+// errDown is returned by a failing backend.
+var errDown = errors.New("connection timed out")
+
+// flakyBackend fails every operation while down is set.
+type flakyBackend struct {
+	omnistorage.Backend
+	down  bool
+	calls int
+}
+
+func (f *flakyBackend) Exists(ctx context.Context, path string) (bool, error) {
+	f.calls++
+	if f.down {
+		return false, errDown
+	}
+	return f.Backend.Exists(ctx, path)
+}
+
+func (f *flakyBackend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	f.calls++
+	if f.down {
+		return nil, errDown
+	}
+	return f.Backend.NewReader(ctx, path, opts...)
+}
+
+// clock is a manually advanced time source.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestBreaker(inner omnistorage.Backend, opts ...Option) (*Backend, *clock) {
+	c := &clock{t: time.Unix(0, 0)}
+	b := New(inner, opts...)
+	b.now = c.now
+	return b, c
+}
+
+func TestTripAndRecover(t *testing.T) {
+	inner := &flakyBackend{Backend: memory.New(), down: true}
+	var changes []string
+	b, clk := newTestBreaker(inner,
+		WithThreshold(3),
+		WithCooldown(time.Minute),
+		WithOnStateChange(func(from, to State) {
+			changes = append(changes, from.String()+"->"+to.String())
+		}),
+	)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := b.Exists(ctx, "x"); !errors.Is(err, errDown) {
+			t.Fatalf("Exists error = %v, want errDown", err)
+		}
+	}
+	if b.State() != Open {
+		t.Fatalf("State() = %v after 3 failures, want open", b.State())
+	}
+
+	// Open: fail fast without calling the backend.
+	if _, err := b.Exists(ctx, "x"); !errors.Is(err, ErrOpen) {
+		t.Errorf("Exists error = %v, want ErrOpen", err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("backend called %d times, want 3", inner.calls)
+	}
+
+	// A failed probe re-opens the breaker for another cooldown.
+	clk.t = clk.t.Add(time.Minute)
+	if _, err := b.Exists(ctx, "x"); !errors.Is(err, errDown) {
+		t.Errorf("probe error = %v, want errDown", err)
+	}
+	if b.State() != Open {
+		t.Errorf("State() = %v after failed probe, want open", b.State())
+	}
+	if _, err := b.Exists(ctx, "x"); !errors.Is(err, ErrOpen) {
+		t.Errorf("Exists error = %v, want ErrOpen", err)
+	}
+
+	// A successful probe closes it.
+	inner.down = false
+	clk.t = clk.t.Add(time.Minute)
+	if _, err := b.Exists(ctx, "x"); err != nil {
+		t.Errorf("probe error = %v", err)
+	}
+	if b.State() != Closed {
+		t.Errorf("State() = %v after successful probe, want closed", b.State())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(changes) != len(want) {
+		t.Fatalf("state changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("state changes = %v, want %v", changes, want)
+			break
+		}
+	}
+}
+
+func TestSuccessResetsCount(t *testing.T) {
+	inner := &flakyBackend{Backend: memory.New()}
+	b, _ := newTestBreaker(inner, WithThreshold(2))
+	ctx := context.Background()
+
+	for range 3 {
+		inner.down = true
+		_, _ = b.Exists(ctx, "x")
+		inner.down = false
+		_, _ = b.Exists(ctx, "x")
+	}
+	if b.State() != Closed {
+		t.Errorf("State() = %v, want closed: failures were not consecutive", b.State())
+	}
+}
+
+func TestHealthyErrorsNotCounted(t *testing.T) {
+	b, _ := newTestBreaker(memory.New(), WithThreshold(1))
+	ctx := context.Background()
+
+	if _, err := b.NewReader(ctx, "missing"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Fatalf("NewReader error = %v, want ErrNotFound", err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _ = b.NewReader(canceled, "missing")
+
+	if b.State() != Closed {
+		t.Errorf("State() = %v, want closed", b.State())
+	}
+}
+
+func TestSingleProbe(t *testing.T) {
+	inner := &flakyBackend{Backend: memory.New(), down: true}
+	b, clk := newTestBreaker(inner, WithThreshold(1), WithCooldown(time.Second))
+	ctx := context.Background()
+
+	_, _ = b.Exists(ctx, "x")
+	clk.t = clk.t.Add(time.Second)
+
+	// Take the probe without completing it.
+	inner.down = false
+	probe, err := b.allow()
+	if err != nil || !probe {
+		t.Fatalf("allow() = %v, %v; want probe", probe, err)
+	}
+	if _, err := b.Exists(ctx, "x"); !errors.Is(err, ErrOpen) {
+		t.Errorf("Exists during probe error = %v, want ErrOpen", err)
+	}
+	b.record(probe, nil)
+	if _, err := b.Exists(ctx, "x"); err != nil {
+		t.Errorf("Exists after probe error = %v", err)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	inner := memory.New()
+	ctx := context.Background()
+	w, _ := inner.NewWriter(ctx, "f")
+	_, _ = w.Write([]byte("data"))
+	_ = w.Close()
+
+	b, _ := newTestBreaker(inner, WithThreshold(1),
+		WithFailure(func(err error) bool { return errors.Is(err, errDown) }))
+
+	r, err := b.NewReader(ctx, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("ReadAll error = %v", err)
+	}
+	_ = r.Close()
+	if b.State() != Closed {
+		t.Fatalf("State() = %v after clean read, want closed", b.State())
+	}
+
+	fr := &reader{r: io.NopCloser(errReader{}), b: b}
+	if _, err := fr.Read(make([]byte, 1)); !errors.Is(err, errDown) {
+		t.Fatalf("Read error = %v", err)
+	}
+	if b.State() != Open {
+		t.Errorf("State() = %v after read failure, want open", b.State())
+	}
+
+	b.Reset()
+	if b.State() != Closed {
+		t.Errorf("State() = %v after Reset, want closed", b.State())
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errDown }
+
+func TestNotExtended(t *testing.T) {
+	b := New(basicBackend{memory.New()})
+	ctx := context.Background()
+
+	if _, err := b.Stat(ctx, "x"); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Stat error = %v, want ErrNotSupported", err)
+	}
+	if f := b.Features(); f.Copy || f.Stat {
+		t.Errorf("Features() = %+v, want none", f)
+	}
+}
+
+// basicBackend hides the extended methods of a backend.
+type basicBackend struct {
+	omnistorage.Backend
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/sync"
+	"github.com/grokify/omnistorage/sync/filter"
+)
+
+// command is a subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"ls", "[-l] <remote>", "list objects", runLs},
+	{"cat", "[-offset n] [-count n] <remote>", "write an object to stdout", runCat},
+	{"cp", "[flags] <src> <dst>", "copy new and changed files", runCp},
+	{"sync", "[flags] <src> <dst>", "make dst match src, deleting extra files", runSync},
+	{"bisync", "[flags] <path1> <path2>", "sync changes in both directions", runBisync},
+	{"check", "[flags] <src> <dst>", "compare files by size and time, or checksum", runCheck},
+	{"verify", "[flags] <src> <dst>", "compare file contents by checksum", runVerify},
+	{"du", "[-depth n] [-json] <remote>", "show space used per directory", runDu},
+	{"dedupe", "[flags] <remote>", "find files with identical content and remove the duplicates", runDedupe},
+	{"rm", "[-r] [-dry-run] <remote>", "delete an object, or everything under a prefix", runRm},
+	{"mkdir", "<remote>", "create a directory", runMkdir},
+	{"remotes", "", "list remotes defined in the config file", runRemotes},
+}
+
+var (
+	// errDiffer is returned when check or verify finds differences.
+	errDiffer = errors.New("differences found")
+
+	// errFailed is returned when a transfer completed with file errors,
+	// which have already been reported.
+	errFailed = errors.New("completed with errors")
+)
+
+// usageError is a command line error. An empty message means the error
+// has already been reported.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// cli holds the output streams shared by all commands.
+type cli struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// flagSet creates the flag set for cmd, with usage printed to stderr.
+func (c *cli) flagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(c.stderr, "Usage: omnistorage %s %s\n\n%s.\n\n", cmd.name, cmd.args, capitalize(cmd.summary))
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses a command's flags and checks it was given n remotes.
+func parse(fs *flag.FlagSet, args []string, n int) ([]remote, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		// The flag package has already reported the error.
+		return nil, usageError("")
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return nil, usageError(fmt.Sprintf("%s: expected %d argument(s), got %d", fs.Name(), n, fs.NArg()))
+	}
+	remotes := make([]remote, n)
+	for i, arg := range fs.Args() {
+		r, err := parseRemote(arg)
+		if err != nil {
+			return nil, err
+		}
+		remotes[i] = r
+	}
+	return remotes, nil
+}
+
+// openAll opens the backends of remotes. The returned function closes them.
+func openAll(remotes []remote) ([]omnistorage.Backend, func(), error) {
+	backends := make([]omnistorage.Backend, 0, len(remotes))
+	closeAll := func() {
+		for _, b := range backends {
+			_ = b.Close()
+		}
+	}
+	for _, r := range remotes {
+		b, err := r.open()
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		backends = append(backends, b)
+	}
+	return backends, closeAll, nil
+}
+
+func runLs(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	long := fs.Bool("l", false, "show size and modification time")
+	var out outputFlags
+	out.register(fs)
+	remotes, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+	b := backends[0]
+
+	ext, _ := omnistorage.AsExtended(b)
+	var entries []entry
+	add := func(p string, info omnistorage.ObjectInfo) {
+		e := entry{Path: p}
+		if info != nil {
+			size, modTime := info.Size(), info.ModTime()
+			e.Size, e.IsDir = &size, info.IsDir()
+			if !modTime.IsZero() {
+				e.ModTime = &modTime
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	switch {
+	case *long && ext != nil && !omnistorage.HasGlob(remotes[0].path):
+		// Walk gets the info of whole directories from backends that
+		// list it, rather than a Stat per file
+		err = omnistorage.Walk(ctx, b, remotes[0].path, func(p string, info omnistorage.ObjectInfo) error {
+			if !info.IsDir() {
+				add(p, info)
+			}
+			return nil
+		}, omnistorage.WithWalkConcurrency(8))
+		if err != nil {
+			return err
+		}
+	default:
+		list := b.List
+		if omnistorage.HasGlob(remotes[0].path) {
+			list = func(ctx context.Context, pattern string) ([]string, error) {
+				return omnistorage.Glob(ctx, b, pattern)
+			}
+		}
+		paths, err := list(ctx, remotes[0].path)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			var info omnistorage.ObjectInfo
+			if *long && ext != nil {
+				if info, err = ext.Stat(ctx, p); err != nil {
+					return err
+				}
+			}
+			add(p, info)
+		}
+	}
+
+	if out.json {
+		return writeJSON(c.stdout, entries)
+	}
+	for _, e := range entries {
+		if !*long {
+			_, _ = fmt.Fprintln(c.stdout, e.Path)
+			continue
+		}
+		size, modTime := "-", "-"
+		if e.Size != nil {
+			size = fmt.Sprint(*e.Size)
+		}
+		if e.ModTime != nil {
+			modTime = e.ModTime.Format("2006-01-02 15:04:05")
+		}
+		_, _ = fmt.Fprintf(c.stdout, "%12s  %19s  %s\n", size, modTime, e.Path)
+	}
+	return nil
+}
+
+func runCat(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	offset := fs.Int64("offset", 0, "start reading at this byte offset")
+	count := fs.Int64("count", 0, "read at most this many bytes (0 reads to the end)")
+	remotes, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	var opts []omnistorage.ReaderOption
+	if *offset > 0 {
+		opts = append(opts, omnistorage.WithOffset(*offset))
+	}
+	if *count > 0 {
+		opts = append(opts, omnistorage.WithLimit(*count))
+	}
+	r, err := backends[0].NewReader(ctx, remotes[0].path, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	_, err = io.Copy(c.stdout, r)
+	return err
+}
+
+// transfer runs cp or sync.
+func transfer(ctx context.Context, c *cli, fs *flag.FlagSet, args []string,
+	fn func(context.Context, omnistorage.Backend, omnistorage.Backend, string, string, sync.Options) (*sync.Result, error),
+) error {
+	var f transferFlags
+	f.register(fs)
+	var deleteExcluded bool
+	if fs.Name() == "sync" {
+		fs.BoolVar(&deleteExcluded, "delete-excluded", false, "also delete destination files excluded by filters")
+	}
+	remotes, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	opts, err := f.options(c.stderr)
+	if err != nil {
+		return err
+	}
+	opts.DeleteExcluded = deleteExcluded
+
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	result, err := fn(ctx, backends[0], backends[1], remotes[0].path, remotes[1].path, opts)
+	if result == nil {
+		return err
+	}
+	tr := newTransferResult(result)
+	if f.json {
+		if jerr := writeJSON(c.stdout, tr); jerr != nil {
+			return jerr
+		}
+	} else {
+		tr.text(c.stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if !result.Success() {
+		return errFailed
+	}
+	return nil
+}
+
+func runCp(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	return transfer(ctx, c, fs, args, sync.Copy)
+}
+
+func runSync(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	return transfer(ctx, c, fs, args, func(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts sync.Options) (*sync.Result, error) {
+		opts.DeleteExtra = true
+		return sync.Sync(ctx, src, dst, srcPath, dstPath, opts)
+	})
+}
+
+// conflictStrategies maps --conflict values to strategies.
+var conflictStrategies = map[string]sync.ConflictStrategy{
+	"newer":     sync.ConflictNewerWins,
+	"larger":    sync.ConflictLargerWins,
+	"path1":     sync.ConflictSourceWins,
+	"path2":     sync.ConflictDestWins,
+	"keep-both": sync.ConflictKeepBoth,
+	"skip":      sync.ConflictSkip,
+	"error":     sync.ConflictError,
+}
+
+func runBisync(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	var f transferFlags
+	f.register(fs)
+	conflictFlag := fs.String("conflict", "newer", "conflict resolution: newer, larger, path1, path2, keep-both, skip or error")
+	deleteMissing := fs.Bool("delete-missing", false, "delete files missing from the other side")
+	remotes, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	strategy, ok := conflictStrategies[*conflictFlag]
+	if !ok {
+		return usageError(fmt.Sprintf("bisync: unknown conflict resolution %q", *conflictFlag))
+	}
+	opts, err := f.options(c.stderr)
+	if err != nil {
+		return err
+	}
+
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	bopts := sync.DefaultBisyncOptions()
+	bopts.ConflictStrategy = strategy
+	bopts.DeleteMissing = *deleteMissing
+	bopts.DryRun = opts.DryRun
+	bopts.Checksum = opts.Checksum
+	bopts.Progress = opts.Progress
+	bopts.MaxErrors = opts.MaxErrors
+	bopts.Concurrency = opts.Concurrency
+	bopts.Filter = opts.Filter
+	bopts.BandwidthLimit = opts.BandwidthLimit
+	bopts.Retry = opts.Retry
+
+	result, err := sync.Bisync(ctx, backends[0], backends[1], remotes[0].path, remotes[1].path, bopts)
+	if result == nil {
+		return err
+	}
+	br := newBisyncResult(result)
+	if f.json {
+		if jerr := writeJSON(c.stdout, br); jerr != nil {
+			return jerr
+		}
+	} else {
+		br.text(c.stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if !result.Success() {
+		return errFailed
+	}
+	return nil
+}
+
+// compare runs check or verify.
+func compare(ctx context.Context, c *cli, fs *flag.FlagSet, args []string, checksum bool) error {
+	var f transferFlags
+	f.register(fs)
+	remotes, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	opts, err := f.options(c.stderr)
+	if err != nil {
+		return err
+	}
+	if checksum {
+		opts.Checksum = true
+	}
+
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	result, err := sync.Check(ctx, backends[0], backends[1], remotes[0].path, remotes[1].path, opts)
+	if err != nil {
+		return err
+	}
+	cr := newCheckResult(result)
+	if f.json {
+		if err := writeJSON(c.stdout, cr); err != nil {
+			return err
+		}
+	} else {
+		cr.text(c.stdout)
+	}
+	if !cr.InSync {
+		return errDiffer
+	}
+	return nil
+}
+
+func runCheck(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	return compare(ctx, c, fs, args, false)
+}
+
+func runVerify(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	return compare(ctx, c, fs, args, true)
+}
+
+func runDu(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	var out outputFlags
+	out.register(fs)
+	depth := fs.Int("depth", 1, "directory levels to show (0 for all)")
+	remotes, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	usage, err := sync.Usage(ctx, backends[0], remotes[0].path, sync.UsageOptions{Depth: *depth})
+	if err != nil {
+		return err
+	}
+	if out.json {
+		return usage.WriteJSON(c.stdout)
+	}
+	return usage.WriteText(c.stdout)
+}
+
+func runDedupe(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	var out outputFlags
+	out.register(fs)
+	action := fs.String("action", "report", "what to do with duplicates: report, delete, rename or link")
+	keep := fs.String("keep", string(sync.KeepFirst), "file to keep: first, newest or oldest")
+	dryRun := fs.Bool("dry-run", false, "report what would be done without changing anything")
+	var minSize sizeFlag
+	fs.Var(&minSize, "min-size", "skip files smaller than this size")
+	remotes, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	opts := sync.DedupeOptions{
+		Action: sync.DedupeAction(*action),
+		Keep:   sync.DedupeKeep(*keep),
+		DryRun: *dryRun,
+	}
+	if *action == "report" {
+		opts.Action = sync.DedupeReport
+	}
+	if minSize > 0 {
+		opts.Filter = filter.New(filter.MinSize(int64(minSize)))
+	}
+
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	result, err := sync.Dedupe(ctx, backends[0], remotes[0].path, opts)
+	if err != nil {
+		return err
+	}
+	dr := newDedupeResult(result, opts.Action)
+	if out.json {
+		if err := writeJSON(c.stdout, dr); err != nil {
+			return err
+		}
+	} else {
+		dr.text(c.stdout)
+	}
+	if len(result.Errors) > 0 {
+		return errFailed
+	}
+	return nil
+}
+
+func runRm(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	recursive := fs.Bool("r", false, "delete everything under the path")
+	dryRun := fs.Bool("dry-run", false, "print what would be deleted without deleting")
+	remotes, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	p := remotes[0].path
+	if p == "" && !*recursive {
+		return usageError("rm: refusing to delete a backend root without -r")
+	}
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+	b := backends[0]
+
+	paths := []string{p}
+	if *recursive {
+		if paths, err = b.List(ctx, p); err != nil {
+			return err
+		}
+		for i := range paths {
+			paths[i] = omnistorage.ListedPath(p, paths[i])
+		}
+		// Remove the prefix itself last, such as a directory once it
+		// has been emptied.
+		if p != "" && !slices.Contains(paths, p) {
+			exists, err := b.Exists(ctx, p)
+			if err != nil {
+				return err
+			}
+			if exists {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		if *dryRun {
+			_, _ = fmt.Fprintf(c.stdout, "would delete %s\n", path)
+			continue
+		}
+		if err := b.Delete(ctx, path); err != nil {
+			return fmt.Errorf("delete %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func runMkdir(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	remotes, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	backends, closeAll, err := openAll(remotes)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	ext, ok := omnistorage.AsExtended(backends[0])
+	if !ok {
+		return fmt.Errorf("mkdir: %w", omnistorage.ErrNotSupported)
+	}
+	return ext.Mkdir(ctx, remotes[0].path)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func runRemotes(_ context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	var out outputFlags
+	out.register(fs)
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	type remoteInfo struct {
+		Name    string `json:"name"`
+		Backend string `json:"backend"`
+	}
+	infos := []remoteInfo{}
+	for _, name := range omnistorage.Remotes() {
+		r, _ := omnistorage.LookupRemote(name)
+		infos = append(infos, remoteInfo{Name: name, Backend: r.Backend})
+	}
+
+	if out.json {
+		return writeJSON(c.stdout, infos)
+	}
+	for _, r := range infos {
+		_, _ = fmt.Fprintf(c.stdout, "%-20s %s\n", r.Name+":", r.Backend)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/grokify/omnistorage/sync"
+	"github.com/grokify/omnistorage/sync/filter"
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// sizeFlag is a byte size flag accepting binary suffixes: 10K, 1.5M, 2G.
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeFlag) Set(v string) error {
+	n, err := parseSize(v)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(n)
+	return nil
+}
+
+// parseSize parses a byte size such as "512", "10K", "1.5MB" or "2G".
+// Suffixes are binary (K = 1024).
+func parseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "IB"), "B")
+
+	mult := int64(1)
+	if v != "" {
+		switch v[len(v)-1] {
+		case 'K':
+			mult = filter.KB
+		case 'M':
+			mult = filter.MB
+		case 'G':
+			mult = filter.GB
+		case 'T':
+			mult = filter.TB
+		}
+		if mult > 1 {
+			v = v[:len(v)-1]
+		}
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(f * float64(mult)), nil
+}
+
+// outputFlags are shared by every command that prints a result.
+type outputFlags struct {
+	json bool
+}
+
+func (f *outputFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.json, "json", false, "print the result as JSON")
+}
+
+// transferFlags configure commands that compare or transfer files.
+type transferFlags struct {
+	outputFlags
+
+	dryRun      bool
+	progress    bool
+	checksum    bool
+	sizeOnly    bool
+	ignoreExist bool
+	noClobber   bool
+	update      bool
+	emptyDirs   bool
+	skipEmpty   bool
+	ignoreCase  bool
+	normalize   string
+	concurrency int
+	maxErrors   int
+	retries     int
+	bwlimit     sizeFlag
+	maxTransfer sizeFlag
+	maxDuration time.Duration
+	order       string
+
+	includes   stringList
+	excludes   stringList
+	filterFrom string
+	minSize    sizeFlag
+	maxSize    sizeFlag
+	minAge     time.Duration
+	maxAge     time.Duration
+}
+
+func (f *transferFlags) register(fs *flag.FlagSet) {
+	f.outputFlags.register(fs)
+	fs.BoolVar(&f.dryRun, "dry-run", false, "report what would be done without changing anything")
+	fs.BoolVar(&f.progress, "progress", false, "print progress to stderr")
+	fs.BoolVar(&f.checksum, "checksum", false, "compare files by checksum instead of size and time")
+	fs.BoolVar(&f.sizeOnly, "size-only", false, "compare files by size only")
+	fs.BoolVar(&f.ignoreExist, "ignore-existing", false, "skip files that exist in the destination")
+	fs.BoolVar(&f.noClobber, "no-clobber", false, "never overwrite files in the destination, even if created during the run")
+	fs.BoolVar(&f.update, "update", false, "only overwrite destination files that are older than the source")
+	fs.BoolVar(&f.emptyDirs, "create-empty-dirs", false, "create directories that are empty in the source")
+	fs.BoolVar(&f.skipEmpty, "skip-empty-files", false, "ignore zero-byte files")
+	fs.BoolVar(&f.ignoreCase, "ignore-case", false, "match source and destination paths regardless of case")
+	fs.StringVar(&f.normalize, "normalize", "", "match and write paths in Unicode normalization `form` nfc or nfd")
+	fs.IntVar(&f.concurrency, "transfers", 4, "number of concurrent transfers")
+	fs.IntVar(&f.maxErrors, "max-errors", 0, "stop after this many errors (0 stops on the first)")
+	fs.IntVar(&f.retries, "retries", 0, "retry failed transfers this many times")
+	fs.Var(&f.bwlimit, "bwlimit", "bandwidth limit in bytes per second, e.g. 10M")
+	fs.Var(&f.maxTransfer, "max-transfer", "stop starting transfers after this many bytes, e.g. 50G")
+	fs.DurationVar(&f.maxDuration, "max-duration", 0, "stop starting transfers after this long, e.g. 4h")
+	fs.StringVar(&f.order, "order", "", "transfer order: largest-first, smallest-first, newest-first or alphabetical")
+
+	fs.Var(&f.includes, "include", "include files matching `pattern` (repeatable)")
+	fs.Var(&f.excludes, "exclude", "exclude files matching `pattern` (repeatable)")
+	fs.StringVar(&f.filterFrom, "filter-from", "", "read include (+) and exclude (-) rules from `file`")
+	fs.Var(&f.minSize, "min-size", "skip files smaller than this size")
+	fs.Var(&f.maxSize, "max-size", "skip files larger than this size")
+	fs.DurationVar(&f.minAge, "min-age", 0, "skip files modified more recently than this")
+	fs.DurationVar(&f.maxAge, "max-age", 0, "skip files modified longer ago than this")
+}
+
+// filter builds the filter, or returns nil if no filter flags were set.
+func (f *transferFlags) filter() (*filter.Filter, error) {
+	var opts []filter.Option
+	for _, p := range f.includes {
+		opts = append(opts, filter.Include(p))
+	}
+	for _, p := range f.excludes {
+		opts = append(opts, filter.Exclude(p))
+	}
+	if f.filterFrom != "" {
+		opt, err := filter.FromFile(f.filterFrom)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	if f.minSize > 0 {
+		opts = append(opts, filter.MinSize(int64(f.minSize)))
+	}
+	if f.maxSize > 0 {
+		opts = append(opts, filter.MaxSize(int64(f.maxSize)))
+	}
+	if f.minAge > 0 {
+		opts = append(opts, filter.MinAge(f.minAge))
+	}
+	if f.maxAge > 0 {
+		opts = append(opts, filter.MaxAge(f.maxAge))
+	}
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	return filter.New(opts...), nil
+}
+
+// options builds sync options, printing progress to stderr if requested.
+func (f *transferFlags) options(stderr io.Writer) (sync.Options, error) {
+	flt, err := f.filter()
+	if err != nil {
+		return sync.Options{}, err
+	}
+	opts := sync.Options{
+		DryRun:           f.dryRun,
+		Checksum:         f.checksum,
+		SizeOnly:         f.sizeOnly,
+		IgnoreExisting:   f.ignoreExist,
+		NoOverwrite:      f.noClobber,
+		UpdateOnly:       f.update,
+		CreateEmptyDirs:  f.emptyDirs,
+		SkipEmptyFiles:   f.skipEmpty,
+		CaseInsensitive:  f.ignoreCase,
+		Normalize:        sync.Normalization(f.normalize),
+		Concurrency:      f.concurrency,
+		MaxErrors:        f.maxErrors,
+		BandwidthLimit:   int64(f.bwlimit),
+		MaxTransferBytes: int64(f.maxTransfer),
+		MaxDuration:      f.maxDuration,
+		TransferOrder:    sync.TransferOrder(f.order),
+		Filter:           flt,
+	}
+	if f.retries > 0 {
+		retry := sync.DefaultRetryConfig()
+		retry.MaxRetries = f.retries
+		opts.Retry = &retry
+	}
+	if f.progress {
+		opts.Progress = newProgressPrinter(stderr).print
+	}
+	return opts, nil
+}
+
+// progressPrinter prints sync progress, at most a few times a second.
+type progressPrinter struct {
+	w io.Writer
+
+	mu   gosync.Mutex
+	last time.Time
+}
+
+func newProgressPrinter(w io.Writer) *progressPrinter {
+	return &progressPrinter{w: w}
+}
+
+func (p *progressPrinter) print(pr sync.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pr.Phase != sync.PhaseComplete && time.Since(p.last) < 250*time.Millisecond {
+		return
+	}
+	p.last = time.Now()
+
+	switch pr.Phase {
+	case sync.PhaseTransferring:
+		_, _ = fmt.Fprintf(p.w, "%s: %d/%d files, %s/%s %s\n", pr.Phase,
+			pr.FilesTransferred, pr.TotalFiles,
+			formatSize(pr.BytesTransferred), formatSize(pr.TotalBytes), pr.CurrentFile)
+	case sync.PhaseDeleting:
+		_, _ = fmt.Fprintf(p.w, "%s: %d/%d files %s\n", pr.Phase, pr.FilesDeleted, pr.TotalFiles, pr.CurrentFile)
+	case sync.PhaseComplete:
+		_, _ = fmt.Fprintf(p.w, "%s: %d files, %s transferred, %d deleted, %d errors\n", pr.Phase,
+			pr.FilesTransferred, formatSize(pr.BytesTransferred), pr.FilesDeleted, pr.Errors)
+	default:
+		_, _ = fmt.Fprintf(p.w, "%s %s\n", pr.Phase, pr.CurrentFile)
+	}
+}
+
+// formatSize formats a byte count with a binary suffix.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,113 @@
+// Command omnistorage lists, copies and synchronizes files between
+// omnistorage backends.
+//
+// Usage:
+//
+//	omnistorage <command> [flags] <remote>...
+//
+// Remotes are local paths or URLs naming a backend:
+//
+//	./photos, /srv/data, file:///srv/data
+//	s3://bucket/prefix?region=eu-west-1
+//	sftp://user@host:22/path?key_file=/home/user/.ssh/id_ed25519
+//	memory:path
+//
+// Query parameters are passed to the backend as configuration.
+//
+// Named remotes are read from the config file at $OMNISTORAGE_CONFIG, or
+// omnistorage/config.toml in the user config directory, and used as
+// name:path. See the config package for the file format.
+//
+// Examples:
+//
+//	omnistorage ls -l s3://bucket/logs/
+//	omnistorage sync --progress --exclude '*.tmp' ./site s3://bucket/site
+//	omnistorage check --json ./site s3://bucket/site
+//
+// The exit status is 0 on success, 1 if an operation failed or check or
+// verify found differences, and 2 for usage errors.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/grokify/omnistorage/config"
+
+	_ "github.com/grokify/omnistorage/backend/file"
+	_ "github.com/grokify/omnistorage/backend/memory"
+	_ "github.com/grokify/omnistorage/backend/s3"
+	_ "github.com/grokify/omnistorage/backend/sftp"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command line args and returns the exit status.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "omnistorage: %v\n", err)
+		return 1
+	}
+	cfg.Register()
+
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	name, args := args[0], args[1:]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage(stdout)
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(ctx, c, c.flagSet(cmd), args)
+		var uerr usageError
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.As(err, &uerr):
+			if uerr != "" {
+				_, _ = fmt.Fprintf(stderr, "omnistorage: %v\n", err)
+			}
+			return 2
+		case errors.Is(err, errDiffer), errors.Is(err, errFailed):
+			return 1
+		default:
+			_, _ = fmt.Fprintf(stderr, "omnistorage: %v\n", err)
+			return 1
+		}
+	}
+
+	_, _ = fmt.Fprintf(stderr, "omnistorage: unknown command %q\n\n", name)
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: omnistorage <command> [flags] <remote>...")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Run 'omnistorage <command> -h' for command flags.")
+}
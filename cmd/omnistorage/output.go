@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/grokify/omnistorage/sync"
+)
+
+// fileError is the JSON form of sync.FileError.
+type fileError struct {
+	Path  string `json:"path"`
+	Op    string `json:"op"`
+	Error string `json:"error"`
+}
+
+func fileErrors(errs []sync.FileError) []fileError {
+	out := make([]fileError, 0, len(errs))
+	for _, e := range errs {
+		out = append(out, fileError{Path: e.Path, Op: e.Op, Error: e.Err.Error()})
+	}
+	return out
+}
+
+// entry is a listed object.
+type entry struct {
+	Path    string     `json:"path"`
+	Size    *int64     `json:"size,omitempty"`
+	ModTime *time.Time `json:"mod_time,omitempty"`
+	IsDir   bool       `json:"is_dir,omitempty"`
+}
+
+// transferResult is the JSON form of sync.Result.
+type transferResult struct {
+	Copied           int         `json:"copied"`
+	Updated          int         `json:"updated"`
+	Deleted          int         `json:"deleted"`
+	DirsCreated      int         `json:"dirs_created"`
+	Skipped          int         `json:"skipped"`
+	BytesTransferred int64       `json:"bytes_transferred"`
+	DurationSeconds  float64     `json:"duration_seconds"`
+	DryRun           bool        `json:"dry_run"`
+	Collisions       []collision `json:"collisions,omitempty"`
+	Errors           []fileError `json:"errors"`
+}
+
+// collision is the JSON form of sync.Collision.
+type collision struct {
+	DstPath string   `json:"dst_path"`
+	Paths   []string `json:"paths"`
+}
+
+func newTransferResult(r *sync.Result) transferResult {
+	return transferResult{
+		Copied:           r.Copied,
+		Updated:          r.Updated,
+		Deleted:          r.Deleted,
+		DirsCreated:      r.DirsCreated,
+		Skipped:          r.Skipped,
+		BytesTransferred: r.BytesTransferred,
+		DurationSeconds:  r.Duration.Seconds(),
+		DryRun:           r.DryRun,
+		Collisions:       collisions(r.Collisions),
+		Errors:           fileErrors(r.Errors),
+	}
+}
+
+func collisions(cs []sync.Collision) []collision {
+	var out []collision
+	for _, c := range cs {
+		out = append(out, collision{DstPath: c.DstPath, Paths: c.Paths})
+	}
+	return out
+}
+
+func (r transferResult) text(w io.Writer) {
+	prefix := ""
+	if r.DryRun {
+		prefix = "(dry run) "
+	}
+	_, _ = fmt.Fprintf(w, "%scopied %d, updated %d, deleted %d, skipped %d, %s in %s\n",
+		prefix, r.Copied, r.Updated, r.Deleted, r.Skipped,
+		formatSize(r.BytesTransferred), time.Duration(r.DurationSeconds*float64(time.Second)).Round(time.Millisecond))
+	for _, c := range r.Collisions {
+		_, _ = fmt.Fprintf(w, "collision: %s -> %s, only the first was synced\n", strings.Join(c.Paths, ", "), c.DstPath)
+	}
+	printErrors(w, r.Errors)
+}
+
+// bisyncResult is the JSON form of sync.BisyncResult.
+type bisyncResult struct {
+	CopiedToPath1    int         `json:"copied_to_path1"`
+	CopiedToPath2    int         `json:"copied_to_path2"`
+	UpdatedInPath1   int         `json:"updated_in_path1"`
+	UpdatedInPath2   int         `json:"updated_in_path2"`
+	DeletedFromPath1 int         `json:"deleted_from_path1"`
+	DeletedFromPath2 int         `json:"deleted_from_path2"`
+	Skipped          int         `json:"skipped"`
+	Conflicts        []conflict  `json:"conflicts"`
+	BytesTransferred int64       `json:"bytes_transferred"`
+	DurationSeconds  float64     `json:"duration_seconds"`
+	DryRun           bool        `json:"dry_run"`
+	Errors           []fileError `json:"errors"`
+}
+
+type conflict struct {
+	Path       string `json:"path"`
+	Resolution string `json:"resolution"`
+	Error      string `json:"error,omitempty"`
+}
+
+func newBisyncResult(r *sync.BisyncResult) bisyncResult {
+	out := bisyncResult{
+		CopiedToPath1:    r.CopiedToPath1,
+		CopiedToPath2:    r.CopiedToPath2,
+		UpdatedInPath1:   r.UpdatedInPath1,
+		UpdatedInPath2:   r.UpdatedInPath2,
+		DeletedFromPath1: r.DeletedFromPath1,
+		DeletedFromPath2: r.DeletedFromPath2,
+		Skipped:          r.Skipped,
+		Conflicts:        make([]conflict, 0, len(r.Conflicts)),
+		BytesTransferred: r.BytesTransferred,
+		DurationSeconds:  r.Duration.Seconds(),
+		DryRun:           r.DryRun,
+		Errors:           fileErrors(r.Errors),
+	}
+	for _, c := range r.Conflicts {
+		cj := conflict{Path: c.Path, Resolution: c.Resolution}
+		if c.Error != nil {
+			cj.Error = c.Error.Error()
+		}
+		out.Conflicts = append(out.Conflicts, cj)
+	}
+	return out
+}
+
+func (r bisyncResult) text(w io.Writer) {
+	prefix := ""
+	if r.DryRun {
+		prefix = "(dry run) "
+	}
+	_, _ = fmt.Fprintf(w, "%spath1 -> path2: copied %d, updated %d, deleted %d\n",
+		prefix, r.CopiedToPath2, r.UpdatedInPath2, r.DeletedFromPath1)
+	_, _ = fmt.Fprintf(w, "%spath2 -> path1: copied %d, updated %d, deleted %d\n",
+		prefix, r.CopiedToPath1, r.UpdatedInPath1, r.DeletedFromPath2)
+	_, _ = fmt.Fprintf(w, "skipped %d, %s transferred\n", r.Skipped, formatSize(r.BytesTransferred))
+	for _, c := range r.Conflicts {
+		if c.Error != "" {
+			_, _ = fmt.Fprintf(w, "conflict %s: %s\n", c.Path, c.Error)
+		} else {
+			_, _ = fmt.Fprintf(w, "conflict %s: %s\n", c.Path, c.Resolution)
+		}
+	}
+	printErrors(w, r.Errors)
+}
+
+// checkResult is the JSON form of check and verify results.
+type checkResult struct {
+	InSync  bool        `json:"in_sync"`
+	Match   int         `json:"match"`
+	Differ  []string    `json:"differ"`
+	SrcOnly []string    `json:"src_only"`
+	DstOnly []string    `json:"dst_only"`
+	Errors  []fileError `json:"errors"`
+}
+
+func newCheckResult(r *sync.CheckResult) checkResult {
+	return checkResult{
+		InSync:  r.InSync() && len(r.Errors) == 0,
+		Match:   len(r.Match),
+		Differ:  nonNil(r.Differ),
+		SrcOnly: nonNil(r.SrcOnly),
+		DstOnly: nonNil(r.DstOnly),
+		Errors:  fileErrors(r.Errors),
+	}
+}
+
+func (r checkResult) text(w io.Writer) {
+	for _, p := range r.Differ {
+		_, _ = fmt.Fprintf(w, "differ   %s\n", p)
+	}
+	for _, p := range r.SrcOnly {
+		_, _ = fmt.Fprintf(w, "src only %s\n", p)
+	}
+	for _, p := range r.DstOnly {
+		_, _ = fmt.Fprintf(w, "dst only %s\n", p)
+	}
+	printErrors(w, r.Errors)
+	_, _ = fmt.Fprintf(w, "%d matching, %d differ, %d source only, %d destination only\n",
+		r.Match, len(r.Differ), len(r.SrcOnly), len(r.DstOnly))
+}
+
+// dedupeResult is the JSON form of sync.DedupeResult.
+type dedupeResult struct {
+	Groups         []duplicateGroup `json:"groups"`
+	Duplicates     int              `json:"duplicates"`
+	Removed        int              `json:"removed"`
+	BytesReclaimed int64            `json:"bytes_reclaimed"`
+	Action         string           `json:"action"`
+	DryRun         bool             `json:"dry_run"`
+	Errors         []fileError      `json:"errors"`
+}
+
+type duplicateGroup struct {
+	Size  int64    `json:"size"`
+	Hash  string   `json:"hash"`
+	Keep  string   `json:"keep,omitempty"`
+	Paths []string `json:"paths"`
+}
+
+func newDedupeResult(r *sync.DedupeResult, action sync.DedupeAction) dedupeResult {
+	out := dedupeResult{
+		Groups:         make([]duplicateGroup, 0, len(r.Groups)),
+		Duplicates:     r.Duplicates(),
+		Removed:        r.Removed,
+		BytesReclaimed: r.BytesReclaimed,
+		Action:         string(action),
+		DryRun:         r.DryRun,
+		Errors:         fileErrors(r.Errors),
+	}
+	if action == sync.DedupeReport {
+		out.Action = "report"
+	}
+	for _, g := range r.Groups {
+		dg := duplicateGroup{Size: g.Size, Hash: g.Hash, Keep: g.Keep, Paths: []string{}}
+		for _, f := range g.Files {
+			dg.Paths = append(dg.Paths, f.Path)
+		}
+		out.Groups = append(out.Groups, dg)
+	}
+	return out
+}
+
+func (r dedupeResult) text(w io.Writer) {
+	var reclaimable int64
+	for _, g := range r.Groups {
+		_, _ = fmt.Fprintf(w, "%d files of %s, %s\n", len(g.Paths), formatSize(g.Size), g.Hash)
+		for _, p := range g.Paths {
+			mark := "dup "
+			if p == g.Keep {
+				mark = "keep"
+			}
+			_, _ = fmt.Fprintf(w, "  %s %s\n", mark, p)
+		}
+		reclaimable += g.Size * int64(len(g.Paths)-1)
+	}
+	printErrors(w, r.Errors)
+	if r.Action == "report" {
+		_, _ = fmt.Fprintf(w, "%d duplicates in %d groups, %s reclaimable\n", r.Duplicates, len(r.Groups), formatSize(reclaimable))
+		return
+	}
+	prefix := ""
+	if r.DryRun {
+		prefix = "(dry run) "
+	}
+	_, _ = fmt.Fprintf(w, "%s%s: %d of %d duplicates, %s reclaimed\n", prefix, r.Action, r.Removed, r.Duplicates, formatSize(r.BytesReclaimed))
+}
+
+func printErrors(w io.Writer, errs []fileError) {
+	for _, e := range errs {
+		_, _ = fmt.Fprintf(w, "error: %s %s: %s\n", e.Op, e.Path, e.Error)
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
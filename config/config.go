@@ -0,0 +1,304 @@
+// Package config loads named remotes from a TOML, YAML or JSON file.
+//
+// A config file maps remote names to a backend type and its options:
+//
+//	[remotes.archive]
+//	type = "s3"
+//	bucket = "company-archive"
+//	region = "eu-west-1"
+//	access_key_id = "${ARCHIVE_ACCESS_KEY_ID}"
+//	secret_access_key = "keyring:omnistorage/archive"
+//
+//	[remotes.backup]
+//	type = "sftp"
+//	host = "backup.example.com"
+//	user = "deploy"
+//	key_file = "${HOME}/.ssh/id_ed25519"
+//
+// Option values may reference secrets instead of holding them:
+//
+//   - ${NAME} is replaced by the environment variable NAME.
+//   - A value of the form keyring:service/user is read from the system
+//     keyring (macOS Keychain, Windows Credential Manager, or the Secret
+//     Service on Linux).
+//
+// References are resolved when a remote is opened, not when the file is
+// loaded, so unused remotes never touch the keyring.
+//
+// Register adds the remotes to omnistorage, so they can be opened by name:
+//
+//	cfg, err := config.Load("omnistorage.toml")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	cfg.Register()
+//	backend, err := omnistorage.OpenRemote("archive")
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/zalando/go-keyring"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Format is a config file format.
+type Format string
+
+// Supported formats.
+const (
+	FormatTOML Format = "toml"
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// EnvConfig is the environment variable that overrides DefaultPath.
+const EnvConfig = "OMNISTORAGE_CONFIG"
+
+// KeyringPrefix marks an option value read from the system keyring.
+const KeyringPrefix = "keyring:"
+
+// Config holds the remotes defined in a config file.
+type Config struct {
+	// Remotes maps remote names to their definitions.
+	Remotes map[string]omnistorage.Remote
+}
+
+// file is the decoded form of a config file.
+type file struct {
+	Remotes map[string]map[string]any `toml:"remotes" yaml:"remotes" json:"remotes"`
+}
+
+// DefaultPath returns the default config file path: $OMNISTORAGE_CONFIG
+// if set, otherwise omnistorage/config.toml in the user config directory.
+func DefaultPath() (string, error) {
+	if p := os.Getenv(EnvConfig); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "omnistorage", "config.toml"), nil
+}
+
+// LoadDefault loads the config file at DefaultPath. A missing file is not
+// an error; it returns an empty Config.
+func LoadDefault() (*Config, error) {
+	p, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Load(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{Remotes: map[string]omnistorage.Remote{}}, nil
+	}
+	return cfg, err
+}
+
+// Load loads a config file. The format is chosen by the file extension:
+// .toml, .yaml, .yml or .json.
+func Load(path string) (*Config, error) {
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func formatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return FormatTOML, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("config: unknown format for %s (use .toml, .yaml or .json)", path)
+	}
+}
+
+// Parse parses config data in the given format.
+func Parse(data []byte, format Format) (*Config, error) {
+	var f file
+	var err error
+	switch format {
+	case FormatTOML:
+		err = toml.Unmarshal(data, &f)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &f)
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&f)
+	default:
+		return nil, fmt.Errorf("config: unknown format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	cfg := &Config{Remotes: make(map[string]omnistorage.Remote, len(f.Remotes))}
+	for name, options := range f.Remotes {
+		r, err := newRemote(name, options)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Remotes[name] = r
+	}
+	return cfg, nil
+}
+
+func newRemote(name string, options map[string]any) (omnistorage.Remote, error) {
+	if name == "" || strings.ContainsAny(name, ":/") {
+		return omnistorage.Remote{}, fmt.Errorf("config: invalid remote name %q", name)
+	}
+	r := omnistorage.Remote{
+		Name:    name,
+		Config:  make(map[string]string, len(options)),
+		Resolve: Resolve,
+	}
+	for key, value := range options {
+		s, err := stringValue(value)
+		if err != nil {
+			return omnistorage.Remote{}, fmt.Errorf("config: remote %s: option %s: %w", name, key, err)
+		}
+		if key == "type" {
+			r.Backend = s
+			continue
+		}
+		r.Config[key] = s
+	}
+	if r.Backend == "" {
+		return omnistorage.Remote{}, fmt.Errorf("config: remote %s: missing type", name)
+	}
+	return r, nil
+}
+
+// stringValue converts a scalar option value to its string form.
+func stringValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v (%T); use a string", v, v)
+	}
+}
+
+// Names returns the sorted remote names.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Remotes))
+	for name := range c.Remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register adds the remotes to omnistorage, replacing remotes with the
+// same names, so they can be opened with omnistorage.OpenRemote.
+func (c *Config) Register() {
+	for _, r := range c.Remotes {
+		omnistorage.AddRemote(r)
+	}
+}
+
+// Open opens the named remote's backend without registering it.
+func (c *Config) Open(name string) (omnistorage.Backend, error) {
+	r, ok := c.Remotes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", omnistorage.ErrUnknownRemote, name)
+	}
+	config, err := Resolve(r.Config)
+	if err != nil {
+		return nil, fmt.Errorf("config: remote %s: %w", name, err)
+	}
+	return omnistorage.Open(r.Backend, config)
+}
+
+// Resolve returns a copy of config with secret references resolved:
+// ${NAME} is replaced by the environment variable NAME, and values of
+// the form keyring:service/user are read from the system keyring. A
+// reference to an unset variable or a missing keyring entry is an error.
+func Resolve(config map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(config))
+	for key, value := range config {
+		v, err := resolveValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("option %s: %w", key, err)
+		}
+		resolved[key] = v
+	}
+	return resolved, nil
+}
+
+func resolveValue(value string) (string, error) {
+	if ref, ok := strings.CutPrefix(value, KeyringPrefix); ok {
+		service, user, ok := strings.Cut(ref, "/")
+		if !ok || service == "" || user == "" {
+			return "", fmt.Errorf("invalid keyring reference %q (want keyring:service/user)", value)
+		}
+		secret, err := keyring.Get(service, user)
+		if err != nil {
+			return "", fmt.Errorf("keyring %s/%s: %w", service, user, err)
+		}
+		return secret, nil
+	}
+	return expandEnv(value)
+}
+
+// expandEnv replaces ${NAME} with the environment variable NAME. Unlike
+// os.ExpandEnv, a bare $ is left alone, since it is common in passwords,
+// and an unset variable is an error rather than an empty string.
+func expandEnv(s string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", s)
+		}
+		name := s[start+2 : start+end]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		b.WriteString(s[:start])
+		b.WriteString(v)
+		s = s[start+end+1:]
+	}
+}
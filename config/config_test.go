@@ -0,0 +1,221 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+const tomlConfig = `
+[remotes.archive]
+type = "s3"
+bucket = "company-archive"
+part_size = 10485760
+use_path_style = true
+
+[remotes.scratch]
+type = "memory"
+`
+
+const yamlConfig = `
+remotes:
+  archive:
+    type: s3
+    bucket: company-archive
+    part_size: 10485760
+    use_path_style: true
+  scratch:
+    type: memory
+`
+
+const jsonConfig = `{
+  "remotes": {
+    "archive": {"type": "s3", "bucket": "company-archive", "part_size": 10485760, "use_path_style": true},
+    "scratch": {"type": "memory"}
+  }
+}`
+
+func TestParseFormats(t *testing.T) {
+	for format, data := range map[Format]string{
+		FormatTOML: tomlConfig,
+		FormatYAML: yamlConfig,
+		FormatJSON: jsonConfig,
+	} {
+		t.Run(string(format), func(t *testing.T) {
+			cfg, err := Parse([]byte(data), format)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if names := cfg.Names(); len(names) != 2 || names[0] != "archive" || names[1] != "scratch" {
+				t.Fatalf("Names() = %v", names)
+			}
+			archive := cfg.Remotes["archive"]
+			if archive.Name != "archive" || archive.Backend != "s3" {
+				t.Errorf("archive = %+v", archive)
+			}
+			want := map[string]string{"bucket": "company-archive", "part_size": "10485760", "use_path_style": "true"}
+			if len(archive.Config) != len(want) {
+				t.Errorf("archive config = %v, want %v", archive.Config, want)
+			}
+			for k, v := range want {
+				if archive.Config[k] != v {
+					t.Errorf("archive config[%s] = %q, want %q", k, archive.Config[k], v)
+				}
+			}
+			if cfg.Remotes["scratch"].Backend != "memory" {
+				t.Errorf("scratch = %+v", cfg.Remotes["scratch"])
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing type": "[remotes.a]\nbucket = \"b\"\n",
+		"nested value": "[remotes.a]\ntype = \"s3\"\n[remotes.a.tags]\nteam = \"data\"\n",
+		"invalid name": "[remotes.\"a:b\"]\ntype = \"s3\"\n",
+		"invalid toml": "[remotes.a\n",
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data), FormatTOML); err == nil {
+			t.Errorf("%s: Parse succeeded, want error", name)
+		}
+	}
+	if _, err := Parse(nil, "ini"); err == nil {
+		t.Error("Parse with unknown format succeeded")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "omnistorage.yml")
+	if err := os.WriteFile(p, []byte(yamlConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(p)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Remotes) != 2 {
+		t.Errorf("Remotes = %v", cfg.Remotes)
+	}
+
+	if _, err := Load(filepath.Join(dir, "config.ini")); err == nil {
+		t.Error("Load with unknown extension succeeded")
+	}
+	if _, err := Load(filepath.Join(dir, "missing.toml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load missing file: err = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestLoadDefault(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "config.toml")
+	t.Setenv(EnvConfig, p)
+
+	cfg, err := LoadDefault()
+	if err != nil || len(cfg.Remotes) != 0 {
+		t.Fatalf("LoadDefault with missing file = %v, %v; want empty config", cfg, err)
+	}
+
+	if err := os.WriteFile(p, []byte(tomlConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadDefault()
+	if err != nil || len(cfg.Remotes) != 2 {
+		t.Fatalf("LoadDefault = %v, %v", cfg, err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	keyring.MockInit()
+	if err := keyring.Set("omnistorage", "archive", "from-keyring"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OMNISTORAGE_TEST_KEY", "from-env")
+	t.Setenv("OMNISTORAGE_TEST_HOME", "/home/test")
+
+	got, err := Resolve(map[string]string{
+		"access_key_id":     "${OMNISTORAGE_TEST_KEY}",
+		"key_file":          "${OMNISTORAGE_TEST_HOME}/.ssh/id_ed25519",
+		"secret_access_key": "keyring:omnistorage/archive",
+		"password":          "pa$$word",
+	})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	want := map[string]string{
+		"access_key_id":     "from-env",
+		"key_file":          "/home/test/.ssh/id_ed25519",
+		"secret_access_key": "from-keyring",
+		"password":          "pa$$word",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Resolve()[%s] = %q, want %q", k, got[k], v)
+		}
+	}
+
+	for _, value := range []string{
+		"${OMNISTORAGE_TEST_UNSET}",
+		"${OMNISTORAGE_TEST_KEY",
+		"keyring:omnistorage/missing",
+		"keyring:no-user",
+	} {
+		if _, err := Resolve(map[string]string{"k": value}); err == nil {
+			t.Errorf("Resolve(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestRegisterAndOpenRemote(t *testing.T) {
+	t.Setenv("OMNISTORAGE_TEST_ROOT", "ignored")
+	cfg, err := Parse([]byte(`
+[remotes.test-config-scratch]
+type = "memory"
+root = "${OMNISTORAGE_TEST_ROOT}"
+
+[remotes.test-config-unset]
+type = "memory"
+root = "${OMNISTORAGE_TEST_UNSET}"
+`), FormatTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Register()
+	defer func() {
+		for _, name := range cfg.Names() {
+			omnistorage.RemoveRemote(name)
+		}
+	}()
+
+	backend, err := omnistorage.OpenRemote("test-config-scratch")
+	if err != nil {
+		t.Fatalf("OpenRemote: %v", err)
+	}
+	if _, ok := backend.(*memory.Backend); !ok {
+		t.Errorf("OpenRemote returned %T, want *memory.Backend", backend)
+	}
+	_ = backend.Close()
+
+	// Secrets are resolved on open, not on load.
+	if _, err := omnistorage.OpenRemote("test-config-unset"); err == nil {
+		t.Error("OpenRemote with unset variable succeeded")
+	}
+
+	backend, err = cfg.Open("test-config-scratch")
+	if err != nil {
+		t.Fatalf("Config.Open: %v", err)
+	}
+	_ = backend.Close()
+	if _, err := cfg.Open("missing"); !errors.Is(err, omnistorage.ErrUnknownRemote) {
+		t.Errorf("Config.Open missing: err = %v, want ErrUnknownRemote", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package crypt provides client-side encryption of individual objects.
+//
+// EncryptingWriter and DecryptingReader wrap any backend writer and
+// reader, so objects are encrypted before they leave the client:
+//
+//	kw, _ := crypt.StaticKey(key)
+//	w, _ := backend.NewWriter(ctx, "secret.bin")
+//	ew, err := crypt.NewEncryptingWriter(ctx, w, kw)
+//	...
+//	r, _ := backend.NewReader(ctx, "secret.bin")
+//	dr, err := crypt.NewDecryptingReader(ctx, r, kw)
+//
+// Each object is encrypted with its own random AES-256 data key, in
+// chunks sealed with AES-GCM. The chunk nonces are a counter with a flag
+// on the last chunk, so reordered, duplicated or truncated chunks are
+// detected. The data key is stored in the object header, wrapped by a
+// KeyWrapper: a static key (StaticKey), a passphrase (Passphrase), age
+// recipients (Age) or a key management service (KeyWrapperFuncs).
+//
+// Unlike an encrypting backend, crypt leaves paths, sizes and listing
+// alone and lets callers manage keys themselves. EncryptTransform and
+// DecryptTransform plug it into sync.Options.TransformReader.
+package crypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Errors returned by crypt.
+var (
+	// ErrInvalidHeader is returned for data that was not written by an
+	// EncryptingWriter.
+	ErrInvalidHeader = errors.New("crypt: invalid header")
+
+	// ErrAuthentication is returned when a chunk fails authentication:
+	// the data was modified, truncated, or encrypted with another key.
+	ErrAuthentication = errors.New("crypt: message authentication failed")
+)
+
+const (
+	// ChunkSize is the size of the plaintext chunks that are sealed
+	// separately.
+	ChunkSize = 64 * 1024
+
+	magic        = "OSCRYPT1"
+	keySize      = 32
+	maxChunkSize = 16 * 1024 * 1024
+	lastChunk    = 1
+)
+
+// KeyWrapper protects the data keys of objects. WrapKey encrypts a data
+// key for storage in the object header and UnwrapKey recovers it.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// header is the object header: magic, chunk size and the wrapped data
+// key. Its encoding is authenticated with every chunk.
+type header struct {
+	chunkSize  uint32
+	wrappedKey []byte
+}
+
+func (h header) marshal() []byte {
+	b := make([]byte, 0, len(magic)+6+len(h.wrappedKey))
+	b = append(b, magic...)
+	b = binary.BigEndian.AppendUint32(b, h.chunkSize)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(h.wrappedKey))) //nolint:gosec // checked by newHeader
+	return append(b, h.wrappedKey...)
+}
+
+func readHeader(r io.Reader) (header, []byte, error) {
+	fixed := make([]byte, len(magic)+6)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return header{}, nil, ErrInvalidHeader
+		}
+		return header{}, nil, err
+	}
+	if string(fixed[:len(magic)]) != magic {
+		return header{}, nil, ErrInvalidHeader
+	}
+	h := header{chunkSize: binary.BigEndian.Uint32(fixed[len(magic):])}
+	if h.chunkSize == 0 || h.chunkSize > maxChunkSize {
+		return header{}, nil, fmt.Errorf("%w: chunk size %d", ErrInvalidHeader, h.chunkSize)
+	}
+	h.wrappedKey = make([]byte, binary.BigEndian.Uint16(fixed[len(magic)+4:]))
+	if _, err := io.ReadFull(r, h.wrappedKey); err != nil {
+		return header{}, nil, ErrInvalidHeader
+	}
+	return h, append(fixed, h.wrappedKey...), nil
+}
+
+// newHeader generates a data key and wraps it with kw.
+func newHeader(ctx context.Context, kw KeyWrapper) (header, []byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return header{}, nil, err
+	}
+	wrapped, err := kw.WrapKey(ctx, key)
+	if err != nil {
+		return header{}, nil, fmt.Errorf("crypt: wrapping data key: %w", err)
+	}
+	if len(wrapped) > 0xffff {
+		return header{}, nil, fmt.Errorf("crypt: wrapped data key is %d bytes, more than 65535", len(wrapped))
+	}
+	return header{chunkSize: ChunkSize, wrappedKey: wrapped}, key, nil
+}
+
+// newAEAD returns AES-GCM for key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of chunk n: zeros, the big-endian counter
+// and the last-chunk flag. Data keys are never reused, so a counter is
+// enough.
+func chunkNonce(nonce []byte, n uint64, last bool) {
+	clear(nonce)
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], n)
+	if last {
+		nonce[len(nonce)-1] = lastChunk
+	}
+}
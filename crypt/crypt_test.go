@@ -0,0 +1,212 @@
+package crypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/sync"
+)
+
+// testWriteCloser wraps a bytes.Buffer with a Close method.
+type testWriteCloser struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func (t *testWriteCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+func testKey(t *testing.T) KeyWrapper {
+	t.Helper()
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	kw, err := StaticKey(key)
+	if err != nil {
+		t.Fatalf("StaticKey failed: %v", err)
+	}
+	return kw
+}
+
+func encrypt(t *testing.T, kw KeyWrapper, data []byte) []byte {
+	t.Helper()
+	buf := &testWriteCloser{Buffer: new(bytes.Buffer)}
+	w, err := NewEncryptingWriter(context.Background(), buf, kw)
+	if err != nil {
+		t.Fatalf("NewEncryptingWriter failed: %v", err)
+	}
+	// Write in uneven pieces to cross chunk boundaries
+	for len(data) > 0 {
+		n := min(len(data), 10000)
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !buf.closed {
+		t.Error("underlying writer was not closed")
+	}
+	return buf.Bytes()
+}
+
+func decrypt(kw KeyWrapper, data []byte) ([]byte, error) {
+	r, err := NewDecryptingReader(context.Background(), io.NopCloser(bytes.NewReader(data)), kw)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	kw := testKey(t)
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3 * ChunkSize} {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+
+		enc := encrypt(t, kw, data)
+		if size >= 64 && bytes.Contains(enc, data[:64]) {
+			t.Errorf("size %d: ciphertext contains plaintext", size)
+		}
+		got, err := decrypt(kw, enc)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	kw := testKey(t)
+	data := bytes.Repeat([]byte("x"), 2*ChunkSize+100)
+	enc := encrypt(t, kw, data)
+	hdrLen := len(enc) - (len(data) + 3*16)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"flipped byte", func() []byte {
+			b := bytes.Clone(enc)
+			b[len(b)/2] ^= 1
+			return b
+		}()},
+		{"truncated at chunk boundary", enc[:hdrLen+2*(ChunkSize+16)]},
+		{"truncated mid-chunk", enc[:len(enc)-10]},
+		{"header only", enc[:hdrLen]},
+		{"chunks swapped", func() []byte {
+			b := bytes.Clone(enc[:hdrLen])
+			c0 := enc[hdrLen : hdrLen+ChunkSize+16]
+			c1 := enc[hdrLen+ChunkSize+16 : hdrLen+2*(ChunkSize+16)]
+			b = append(b, c1...)
+			b = append(b, c0...)
+			return append(b, enc[hdrLen+2*(ChunkSize+16):]...)
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decrypt(kw, tt.data); !errors.Is(err, ErrAuthentication) {
+				t.Errorf("decrypt error = %v, want ErrAuthentication", err)
+			}
+		})
+	}
+
+	if _, err := decrypt(testKey(t), enc); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("decrypt with wrong key error = %v, want ErrAuthentication", err)
+	}
+	if _, err := decrypt(kw, []byte("plain text, not encrypted")); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("decrypt of plaintext error = %v, want ErrInvalidHeader", err)
+	}
+}
+
+func TestKeyWrappers(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	static := testKey(t)
+
+	tests := []struct {
+		name   string
+		writer KeyWrapper
+		reader KeyWrapper
+	}{
+		{"Passphrase", Passphrase("correct horse"), Passphrase("correct horse")},
+		{"Age", Age([]age.Recipient{identity.Recipient()}), Age(nil, identity)},
+		{"KeyWrapperFuncs", KeyWrapperFuncs{Wrap: static.WrapKey, Unwrap: static.UnwrapKey}, static},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte("secret data")
+			enc := encrypt(t, tt.writer, data)
+			enc2 := encrypt(t, tt.writer, data)
+			if bytes.Equal(enc, enc2) {
+				t.Error("two encryptions are identical")
+			}
+			got, err := decrypt(tt.reader, enc)
+			if err != nil {
+				t.Fatalf("decrypt failed: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("decrypted %q, want %q", got, data)
+			}
+		})
+	}
+
+	enc := encrypt(t, Passphrase("correct horse"), []byte("data"))
+	if _, err := decrypt(Passphrase("wrong"), enc); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("wrong passphrase error = %v, want ErrAuthentication", err)
+	}
+}
+
+func TestSyncTransforms(t *testing.T) {
+	ctx := context.Background()
+	kw := testKey(t)
+	src, vault, restored := memory.New(), memory.New(), memory.New()
+
+	data := bytes.Repeat([]byte("confidential "), 10000)
+	w, _ := src.NewWriter(ctx, "docs/report.txt")
+	_, _ = w.Write(data)
+	_ = w.Close()
+
+	opts := sync.Options{IgnoreSize: true, TransformReader: EncryptTransform(kw)}
+	if _, err := sync.Sync(ctx, src, vault, "", "", opts); err != nil {
+		t.Fatalf("encrypting Sync failed: %v", err)
+	}
+	r, _ := vault.NewReader(ctx, "docs/report.txt")
+	enc, _ := io.ReadAll(r)
+	_ = r.Close()
+	if bytes.Contains(enc, []byte("confidential")) {
+		t.Error("vault holds plaintext")
+	}
+
+	opts.TransformReader = DecryptTransform(kw)
+	if _, err := sync.Sync(ctx, vault, restored, "", "", opts); err != nil {
+		t.Fatalf("decrypting Sync failed: %v", err)
+	}
+	r, _ = restored.NewReader(ctx, "docs/report.txt")
+	got, _ := io.ReadAll(r)
+	_ = r.Close()
+	if !bytes.Equal(got, data) {
+		t.Error("restored data differs from source")
+	}
+
+	// A wrong key fails the copy instead of writing garbage
+	opts.TransformReader = DecryptTransform(testKey(t))
+	result, err := sync.Sync(ctx, vault, memory.New(), "", "", opts)
+	if err == nil && result.Success() {
+		t.Error("decrypting with the wrong key should fail")
+	}
+}
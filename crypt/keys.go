@@ -0,0 +1,217 @@
+package crypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/scrypt"
+)
+
+// staticKey wraps data keys with AES-GCM under a fixed key.
+type staticKey struct {
+	aead cipher.AEAD
+}
+
+// StaticKey returns a KeyWrapper that wraps data keys with key, which
+// must be 16, 24 or 32 bytes for AES-128, AES-192 or AES-256.
+func StaticKey(key []byte) (KeyWrapper, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: %w", err)
+	}
+	return &staticKey{aead: aead}, nil
+}
+
+func (k *staticKey) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	return seal(k.aead, key)
+}
+
+func (k *staticKey) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	return open(k.aead, wrapped)
+}
+
+// seal encrypts key with a random nonce, which it prepends.
+func seal(aead cipher.AEAD, key []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(key)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, key, nil), nil
+}
+
+func open(aead cipher.AEAD, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrAuthentication
+	}
+	key, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+	return key, nil
+}
+
+// scrypt parameters for Passphrase, as recommended for interactive
+// logins in 2017 and still the Go documentation's example.
+const (
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	saltLength = 16
+)
+
+// passphrase wraps data keys with a key derived from a passphrase.
+type passphrase struct {
+	passphrase []byte
+
+	once    sync.Once
+	salt    []byte
+	aead    cipher.AEAD
+	initErr error
+
+	mu   sync.Mutex
+	keys map[string]cipher.AEAD // by salt, for unwrapping
+}
+
+// Passphrase returns a KeyWrapper that wraps data keys with a key derived
+// from passphrase with scrypt. The key is derived once per wrapper for
+// writing, with a random salt stored in each header, and once per salt
+// for reading.
+func Passphrase(pass string) KeyWrapper {
+	return &passphrase{passphrase: []byte(pass), keys: make(map[string]cipher.AEAD)}
+}
+
+func (p *passphrase) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	p.once.Do(func() {
+		p.salt = make([]byte, saltLength)
+		if _, p.initErr = rand.Read(p.salt); p.initErr != nil {
+			return
+		}
+		p.aead, p.initErr = p.derive(p.salt)
+	})
+	if p.initErr != nil {
+		return nil, p.initErr
+	}
+	wrapped, err := seal(p.aead, key)
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes.Clone(p.salt), wrapped...), nil
+}
+
+func (p *passphrase) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < saltLength {
+		return nil, ErrAuthentication
+	}
+	salt := string(wrapped[:saltLength])
+
+	p.mu.Lock()
+	aead, ok := p.keys[salt]
+	p.mu.Unlock()
+	if !ok {
+		var err error
+		if aead, err = p.derive([]byte(salt)); err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		p.keys[salt] = aead
+		p.mu.Unlock()
+	}
+	return open(aead, wrapped[saltLength:])
+}
+
+func (p *passphrase) derive(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(p.passphrase, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+// ageKey wraps data keys as age-encrypted files.
+type ageKey struct {
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+// Age returns a KeyWrapper that encrypts data keys to age recipients,
+// such as X25519 or SSH public keys, and decrypts them with identities.
+// A writer needs only recipients and a reader only identities.
+func Age(recipients []age.Recipient, identities ...age.Identity) KeyWrapper {
+	return &ageKey{recipients: recipients, identities: identities}
+}
+
+func (k *ageKey) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	if len(k.recipients) == 0 {
+		return nil, errors.New("no age recipients")
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, k.recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(key); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (k *ageKey) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if len(k.identities) == 0 {
+		return nil, errors.New("no age identities")
+	}
+	r, err := age.Decrypt(bytes.NewReader(wrapped), k.identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// KeyWrapperFuncs adapts a pair of functions to KeyWrapper, typically the
+// encrypt and decrypt calls of a key management service:
+//
+//	kw := crypt.KeyWrapperFuncs{
+//	    Wrap: func(ctx context.Context, key []byte) ([]byte, error) {
+//	        out, err := kmsClient.Encrypt(ctx, &kms.EncryptInput{KeyId: &keyID, Plaintext: key})
+//	        if err != nil {
+//	            return nil, err
+//	        }
+//	        return out.CiphertextBlob, nil
+//	    },
+//	    Unwrap: func(ctx context.Context, wrapped []byte) ([]byte, error) {
+//	        out, err := kmsClient.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: wrapped})
+//	        if err != nil {
+//	            return nil, err
+//	        }
+//	        return out.Plaintext, nil
+//	    },
+//	}
+type KeyWrapperFuncs struct {
+	Wrap   func(ctx context.Context, key []byte) ([]byte, error)
+	Unwrap func(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// WrapKey calls Wrap.
+func (f KeyWrapperFuncs) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	if f.Wrap == nil {
+		return nil, errors.New("no wrap function")
+	}
+	return f.Wrap(ctx, key)
+}
+
+// UnwrapKey calls Unwrap.
+func (f KeyWrapperFuncs) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	if f.Unwrap == nil {
+		return nil, errors.New("no unwrap function")
+	}
+	return f.Unwrap(ctx, wrapped)
+}
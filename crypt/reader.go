@@ -0,0 +1,122 @@
+package crypt
+
+import (
+	"bufio"
+	"context"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DecryptingReader decrypts data written by an EncryptingWriter. Each
+// chunk is authenticated before any of it is returned.
+type DecryptingReader struct {
+	r      *bufio.Reader
+	closer io.Closer
+	aead   cipher.AEAD
+	aad    []byte
+	nonce  []byte
+	in     []byte
+	buf    []byte // decrypted, unread data
+	chunk  uint64
+	done   bool
+	err    error
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewDecryptingReader reads the object header from r, unwraps the data
+// key with kw and returns a reader of the plaintext. Closing it closes
+// r.
+func NewDecryptingReader(ctx context.Context, r io.ReadCloser, kw KeyWrapper) (*DecryptingReader, error) {
+	br := bufio.NewReader(r)
+	h, hdr, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	key, err := kw.UnwrapKey(ctx, h.wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: unwrapping data key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &DecryptingReader{
+		r:      br,
+		closer: r,
+		aead:   aead,
+		aad:    hdr,
+		nonce:  make([]byte, aead.NonceSize()),
+		in:     make([]byte, int(h.chunkSize)+aead.Overhead()),
+	}, nil
+}
+
+// Read reads decrypted data.
+func (r *DecryptingReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.open()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk. The last chunk is the one that
+// is followed by the end of the data.
+func (r *DecryptingReader) open() error {
+	n, err := io.ReadFull(r.r, r.in)
+	switch {
+	case errors.Is(err, io.EOF):
+		// The last chunk was missing
+		return ErrAuthentication
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		r.done = true
+	case err != nil:
+		return err
+	default:
+		if _, err := r.r.Peek(1); errors.Is(err, io.EOF) {
+			r.done = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	chunkNonce(r.nonce, r.chunk, r.done)
+	plain, err := r.aead.Open(r.in[:0], r.nonce, r.in[:n], r.aad)
+	if err != nil {
+		return ErrAuthentication
+	}
+	r.chunk++
+	r.buf = plain
+	return nil
+}
+
+// Close closes the underlying reader.
+func (r *DecryptingReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.closer.Close()
+}
+
+// Ensure DecryptingReader implements io.ReadCloser
+var _ io.ReadCloser = (*DecryptingReader)(nil)
@@ -0,0 +1,59 @@
+package crypt
+
+import (
+	"context"
+	"io"
+)
+
+// EncryptTransform returns a transform for sync.Options.TransformReader
+// that encrypts every file copied with a new data key wrapped by kw.
+// Key wrapping runs with a background context.
+func EncryptTransform(kw KeyWrapper) func(path string, r io.Reader) io.Reader {
+	return func(_ string, r io.Reader) io.Reader {
+		pr, pw := io.Pipe()
+		go func() {
+			// The pipe is closed here, so that a failed copy is an error
+			// for the reader rather than a shorter, valid object.
+			ew, err := NewEncryptingWriter(context.Background(), nopCloser{pw}, kw)
+			if err == nil {
+				_, err = io.Copy(ew, r)
+			}
+			if err == nil {
+				err = ew.Close()
+			}
+			_ = pw.CloseWithError(err)
+		}()
+		return pr
+	}
+}
+
+// DecryptTransform returns a transform for sync.Options.TransformReader
+// that decrypts every file copied, for restoring files encrypted with
+// EncryptTransform. Key unwrapping runs with a background context.
+func DecryptTransform(kw KeyWrapper) func(path string, r io.Reader) io.Reader {
+	return func(_ string, r io.Reader) io.Reader {
+		dr, err := NewDecryptingReader(context.Background(), io.NopCloser(r), kw)
+		if err != nil {
+			return errReader{err}
+		}
+		return dr
+	}
+}
+
+// errReader returns err from every Read.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// nopCloser is a writer with a Close that does nothing.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,112 @@
+package crypt
+
+import (
+	"context"
+	"crypto/cipher"
+	"io"
+	"sync"
+)
+
+// EncryptingWriter encrypts data written to it and writes it to an
+// underlying writer. Close must be called to write the last chunk.
+type EncryptingWriter struct {
+	w      io.WriteCloser
+	aead   cipher.AEAD
+	aad    []byte
+	nonce  []byte
+	buf    []byte
+	out    []byte
+	chunk  uint64
+	err    error
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewEncryptingWriter writes the object header to w and returns a writer
+// that encrypts data with a new data key wrapped by kw. Closing it closes
+// w.
+func NewEncryptingWriter(ctx context.Context, w io.WriteCloser, kw KeyWrapper) (*EncryptingWriter, error) {
+	h, key, err := newHeader(ctx, kw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	hdr := h.marshal()
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &EncryptingWriter{
+		w:     w,
+		aead:  aead,
+		aad:   hdr,
+		nonce: make([]byte, aead.NonceSize()),
+		buf:   make([]byte, 0, h.chunkSize),
+		out:   make([]byte, 0, int(h.chunkSize)+aead.Overhead()),
+	}, nil
+}
+
+// Write encrypts p. Full chunks are written once more data follows them,
+// so that the last chunk can be marked on Close.
+func (w *EncryptingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			if err := w.seal(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// seal encrypts and writes the buffered chunk.
+func (w *EncryptingWriter) seal(last bool) error {
+	chunkNonce(w.nonce, w.chunk, last)
+	w.out = w.aead.Seal(w.out[:0], w.nonce, w.buf, w.aad)
+	w.chunk++
+	w.buf = w.buf[:0]
+	if _, err := w.w.Write(w.out); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Close writes the last chunk and closes the underlying writer.
+func (w *EncryptingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.err == nil {
+		if err := w.seal(true); err != nil {
+			_ = w.w.Close()
+			return err
+		}
+	}
+	return w.w.Close()
+}
+
+// Ensure EncryptingWriter implements io.WriteCloser
+var _ io.WriteCloser = (*EncryptingWriter)(nil)
@@ -0,0 +1,296 @@
+// Package dedup provides a backend wrapper that coalesces identical
+// concurrent uploads.
+//
+// Writers buffer their data and hash it with SHA-256. On Close, a write of
+// the same content to the same path as an upload already in flight waits
+// for that upload and returns its result, instead of uploading again:
+//
+//	backend := dedup.New(s3Backend)
+//	// Workers writing the same report at the same time upload it once
+//	w, _ := backend.NewWriter(ctx, "reports/daily.json")
+//
+// Only uploads in flight are shared: a write closed after an identical one
+// has finished uploads again. Writes of different content to one path are
+// not coalesced, and the last to finish wins, as without the wrapper.
+// Writes larger than the buffer limit stream to the backend once it is
+// reached and are never coalesced.
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/grokify/omnistorage"
+)
+
+// DefaultMaxBuffer is the buffer limit used unless WithMaxBuffer is given.
+const DefaultMaxBuffer = 64 << 20
+
+// Option configures a dedup backend.
+type Option func(*Backend)
+
+// WithMaxBuffer sets how many bytes a writer buffers before it gives up on
+// coalescing and streams to the backend. Default: DefaultMaxBuffer.
+func WithMaxBuffer(n int64) Option {
+	return func(b *Backend) {
+		if n > 0 {
+			b.maxBuffer = n
+		}
+	}
+}
+
+// Stats counts the writes of a dedup backend.
+type Stats struct {
+	// Uploads is the number of writes that reached the wrapped backend.
+	Uploads int64
+
+	// Coalesced is the number of writes that shared another's upload.
+	Coalesced int64
+}
+
+// Backend wraps a backend, coalescing identical concurrent uploads.
+// Extended operations return omnistorage.ErrNotSupported if the wrapped
+// backend does not implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend   omnistorage.Backend
+	maxBuffer int64
+	flights   group
+
+	uploads   atomic.Int64
+	coalesced atomic.Int64
+	closed    atomic.Bool
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend, coalescing identical concurrent uploads.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{backend: backend, maxBuffer: DefaultMaxBuffer}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// Stats returns the write counts so far.
+func (b *Backend) Stats() Stats {
+	return Stats{Uploads: b.uploads.Load(), Coalesced: b.coalesced.Load()}
+}
+
+// NewWriter returns a writer that buffers the data for path and uploads it
+// on Close, unless an identical upload is in flight. A coalesced write
+// returns the result of the upload it joins, which used the options and
+// context of the writer that started it.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	if b.closed.Load() {
+		return nil, omnistorage.ErrBackendClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &writer{ctx: ctx, b: b, path: strings.TrimPrefix(path, "/"), opts: opts, hash: sha256.New()}, nil
+}
+
+// NewReader creates a reader for the given path.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	return b.backend.NewReader(ctx, path, opts...)
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	return b.backend.Exists(ctx, path)
+}
+
+// Delete removes a path.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	return b.backend.Delete(ctx, path)
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	return b.backend.List(ctx, prefix)
+}
+
+// Close closes the wrapped backend.
+func (b *Backend) Close() error {
+	b.closed.Store(true)
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Copy(ctx, src, dst)
+}
+
+// Move moves an object.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Move(ctx, src, dst)
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// upload writes data to path in the wrapped backend.
+func (b *Backend) upload(ctx context.Context, path string, data []byte, opts []omnistorage.WriterOption) error {
+	w, err := b.backend.NewWriter(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+	b.uploads.Add(1)
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// writer buffers and hashes data until Close, or streams it once the
+// buffer limit is reached.
+type writer struct {
+	ctx  context.Context
+	b    *Backend
+	path string
+	opts []omnistorage.WriterOption
+
+	buf    bytes.Buffer
+	hash   hash.Hash
+	stream io.WriteCloser // set once the buffer limit is reached
+	closed bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, omnistorage.ErrWriterClosed
+	}
+	if w.stream != nil {
+		return w.stream.Write(p)
+	}
+	if int64(w.buf.Len()+len(p)) <= w.b.maxBuffer {
+		w.hash.Write(p)
+		return w.buf.Write(p)
+	}
+
+	// Too large to coalesce: stream the buffer and the rest
+	stream, err := w.b.backend.NewWriter(w.ctx, w.path, w.opts...)
+	if err != nil {
+		return 0, err
+	}
+	w.b.uploads.Add(1)
+	w.stream = stream
+	if _, err := stream.Write(w.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	w.buf = bytes.Buffer{}
+	return stream.Write(p)
+}
+
+func (w *writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.stream != nil {
+		return w.stream.Close()
+	}
+
+	key := w.path + "\x00" + hex.EncodeToString(w.hash.Sum(nil))
+	shared, err := w.b.flights.do(key, func() error {
+		return w.b.upload(w.ctx, w.path, w.buf.Bytes(), w.opts)
+	})
+	if shared {
+		w.b.coalesced.Add(1)
+	}
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+// call is an upload in flight.
+type call struct {
+	done chan struct{}
+	err  error
+}
+
+// group runs one upload per key at a time, sharing its result with the
+// callers that ask for the same key meanwhile.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do runs fn, or waits for the call of key in flight and returns its
+// error, with shared set.
+func (g *group) do(key string, fn func() error) (shared bool, err error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return true, c.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.err = fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+	return false, c.err
+}
@@ -0,0 +1,128 @@
+package dedup
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), WithMaxBuffer(1024))
+	})
+}
+
+// gatedBackend holds every upload in Close until the gate is opened, and
+// signals started when one begins.
+type gatedBackend struct {
+	*memory.Backend
+	gate    chan struct{}
+	started chan struct{}
+}
+
+func (b *gatedBackend) NewWriter(ctx context.Context, p string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	w, err := b.Backend.NewWriter(ctx, p, opts...)
+	return gatedWriter{WriteCloser: w, b: b}, err
+}
+
+type gatedWriter struct {
+	io.WriteCloser
+	b *gatedBackend
+}
+
+func (w gatedWriter) Close() error {
+	w.b.started <- struct{}{}
+	<-w.b.gate
+	return w.WriteCloser.Close()
+}
+
+func write(b omnistorage.Backend, p, data string) error {
+	w, err := b.NewWriter(context.Background(), p)
+	if err != nil {
+		return err
+	}
+	_, _ = io.WriteString(w, data)
+	return w.Close()
+}
+
+func TestCoalesce(t *testing.T) {
+	inner := &gatedBackend{Backend: memory.New(), gate: make(chan struct{}), started: make(chan struct{}, 10)}
+	b := New(inner)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	run := func(p, data string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- write(b, p, data)
+		}()
+	}
+
+	// The first upload is held while identical writes join it
+	run("a.txt", "same")
+	<-inner.started
+	for range 4 {
+		run("a.txt", "same")
+	}
+	run("a.txt", "other")
+	run("b.txt", "same")
+	<-inner.started
+	<-inner.started
+	time.Sleep(20 * time.Millisecond)
+	close(inner.gate)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("write error = %v", err)
+		}
+	}
+
+	if got, want := b.Stats(), (Stats{Uploads: 3, Coalesced: 4}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// Once the upload is done, an identical write uploads again
+	if err := write(b, "b.txt", "same"); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Stats().Uploads; got != 4 {
+		t.Errorf("Uploads = %d after a later write, want 4", got)
+	}
+}
+
+func TestLargeWriteStreams(t *testing.T) {
+	inner := memory.New()
+	b := New(inner, WithMaxBuffer(8))
+
+	data := strings.Repeat("x", 20)
+	w, _ := b.NewWriter(context.Background(), "big.txt")
+	for i := 0; i < len(data); i += 5 {
+		if _, err := io.WriteString(w, data[i:i+5]); err != nil {
+			t.Fatalf("Write error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	r, err := inner.NewReader(context.Background(), "big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, _ := io.ReadAll(r); string(got) != data {
+		t.Errorf("big.txt = %q, want %q", got, data)
+	}
+	if got := b.Stats(); got != (Stats{Uploads: 1}) {
+		t.Errorf("Stats() = %+v", got)
+	}
+}
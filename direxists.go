@@ -0,0 +1,36 @@
+package omnistorage
+
+import (
+	"context"
+	"strings"
+)
+
+// DirExists reports whether path is a directory of backend. It uses the
+// backend's DirExists if it is a DirExister, and otherwise Stat, for
+// backends that implement ExtendedBackend, or lists path. The root ("")
+// always exists.
+func DirExists(ctx context.Context, backend Backend, path string) (bool, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return true, ctx.Err()
+	}
+	if d, ok := backend.(DirExister); ok {
+		return d.DirExists(ctx, path)
+	}
+	if ext, ok := AsExtended(backend); ok {
+		info, err := ext.Stat(ctx, path)
+		if err == nil {
+			return info.IsDir(), nil
+		}
+		if !IsNotFound(err) {
+			return false, err
+		}
+	}
+	// Backends without directories of their own, such as memory, have a
+	// directory where objects share the prefix
+	files, err := backend.List(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	return len(files) > 0, nil
+}
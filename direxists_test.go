@@ -0,0 +1,35 @@
+package omnistorage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/file"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestDirExists(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	fs := file.New(file.Config{Root: t.TempDir(), CreateDirs: true})
+	for name, backend := range map[string]omnistorage.Backend{
+		"memory": mem,
+		"file":   fs,
+		"basic":  basicBackend{memory.New()},
+	} {
+		writeObject(t, backend, "data/2024/report.csv", []byte("a,b"))
+		for p, want := range map[string]bool{
+			"":                     true,
+			"data":                 true,
+			"data/2024/":           true,
+			"data/20":              false,
+			"data/2024/report.csv": false,
+			"missing":              false,
+		} {
+			if got, err := omnistorage.DirExists(ctx, backend, p); err != nil || got != want {
+				t.Errorf("%s: DirExists(%q) = %v, %v, want %v", name, p, got, err, want)
+			}
+		}
+	}
+}
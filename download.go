@@ -0,0 +1,189 @@
+package omnistorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DefaultParallelChunkSize is the chunk size of a parallel read unless
+// ParallelReadOptions.ChunkSize is set.
+const DefaultParallelChunkSize = 8 << 20
+
+// ParallelReadOptions configures NewParallelReader and DownloadFile.
+type ParallelReadOptions struct {
+	// ChunkSize is the size of each ranged read.
+	// Default: DefaultParallelChunkSize (8 MiB).
+	ChunkSize int64
+
+	// Concurrency is the number of chunks read at once. At most
+	// Concurrency chunks are held in memory. Default: 4.
+	Concurrency int
+
+	// ReaderOptions are passed to each ranged read, such as a customer
+	// encryption key.
+	ReaderOptions []ReaderOption
+
+	// Offset is where NewParallelReader starts reading. Default: 0.
+	Offset int64
+}
+
+func (o ParallelReadOptions) withDefaults() ParallelReadOptions {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = DefaultParallelChunkSize
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 4
+	}
+	return o
+}
+
+// NewParallelReader returns a reader of the size bytes of the object at
+// path that fetches it in concurrent ranged chunks and returns them in
+// order, so a single large object is read faster than one stream allows.
+// The backend must support range reads (Features().RangeRead), and size
+// must be the object's size, as reported by Stat, or where to stop. The
+// reader starts at opts.Offset.
+//
+// A chunk that fails or is shorter than expected, as when the object
+// changes during the read, fails the reader. Close stops the reads in
+// progress.
+func NewParallelReader(ctx context.Context, backend Backend, path string, size int64, opts ParallelReadOptions) io.ReadCloser {
+	opts = opts.withDefaults()
+	ctx, cancel := context.WithCancel(ctx)
+	start := min(max(opts.Offset, 0), size)
+	n := (size - start + opts.ChunkSize - 1) / opts.ChunkSize
+	r := &parallelReader{
+		cancel:  cancel,
+		ctx:     ctx,
+		results: make([]chan chunkResult, n),
+		slots:   make(chan struct{}, opts.Concurrency),
+	}
+	for i := range r.results {
+		r.results[i] = make(chan chunkResult, 1)
+	}
+
+	go func() {
+		for i := range r.results {
+			select {
+			case r.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			off := start + int64(i)*opts.ChunkSize
+			length := min(opts.ChunkSize, size-off)
+			go func() {
+				data, err := readChunk(ctx, backend, path, off, length, opts.ReaderOptions)
+				r.results[i] <- chunkResult{data: data, err: err}
+			}()
+		}
+	}()
+	return r
+}
+
+// readChunk reads length bytes of path at off.
+func readChunk(ctx context.Context, backend Backend, path string, off, length int64, opts []ReaderOption) ([]byte, error) {
+	opts = append(opts[:len(opts):len(opts)], WithOffset(off), WithLimit(length))
+	rc, err := backend.NewReader(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	data := make([]byte, length)
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return nil, fmt.Errorf("read %s at %d: %w", path, off, err)
+	}
+	return data, nil
+}
+
+type chunkResult struct {
+	data []byte
+	err  error
+}
+
+// parallelReader returns the chunks fetched by NewParallelReader in order.
+// A slot is taken for each chunk fetched and freed when the chunk is
+// consumed, which bounds the chunks in memory.
+type parallelReader struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results []chan chunkResult
+	slots   chan struct{}
+	next    int
+	cur     []byte
+	err     error
+}
+
+func (r *parallelReader) Read(p []byte) (int, error) {
+	for len(r.cur) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.next == len(r.results) {
+			return 0, io.EOF
+		}
+		select {
+		case res := <-r.results[r.next]:
+			<-r.slots
+			r.next++
+			r.cur, r.err = res.data, res.err
+		case <-r.ctx.Done():
+			r.err = r.ctx.Err()
+		}
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+// Close stops the reads in progress.
+func (r *parallelReader) Close() error {
+	r.cancel()
+	if r.err == nil {
+		r.err = ErrReaderClosed
+	}
+	return nil
+}
+
+// DownloadFile copies the object at path to the local file localPath,
+// reading it in concurrent ranged chunks if the backend supports range
+// reads and the object is larger than one chunk. It returns the number of
+// bytes written. On failure the local file is removed.
+func DownloadFile(ctx context.Context, backend Backend, path, localPath string, opts ParallelReadOptions) (int64, error) {
+	opts = opts.withDefaults()
+
+	var r io.ReadCloser
+	ext, ok := AsExtended(backend)
+	if ok && ext.Features().RangeRead {
+		info, err := ext.Stat(ctx, path)
+		if err != nil {
+			return 0, err
+		}
+		if info.Size() > opts.ChunkSize {
+			r = NewParallelReader(ctx, backend, path, info.Size(), opts)
+		}
+	}
+	if r == nil {
+		var err error
+		r, err = backend.NewReader(ctx, path, opts.ReaderOptions...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer func() { _ = r.Close() }()
+
+	f, err := os.Create(localPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(localPath)
+		return n, err
+	}
+	return n, nil
+}
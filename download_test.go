@@ -0,0 +1,133 @@
+package omnistorage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// rangeCounter counts the reads of a memory backend, failing those at
+// failOffset if it is set.
+type rangeCounter struct {
+	*memory.Backend
+	reads      atomic.Int32
+	failOffset int64
+}
+
+func (b *rangeCounter) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	b.reads.Add(1)
+	cfg := omnistorage.ApplyReaderOptions(opts...)
+	if b.failOffset > 0 && cfg.Offset == b.failOffset {
+		return nil, errors.New("connection reset")
+	}
+	return b.Backend.NewReader(ctx, p, opts...)
+}
+
+func TestParallelReader(t *testing.T) {
+	ctx := context.Background()
+	backend := &rangeCounter{Backend: memory.New()}
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	writeObject(t, backend, "big.bin", data)
+
+	r := omnistorage.NewParallelReader(ctx, backend, "big.bin", int64(len(data)),
+		omnistorage.ParallelReadOptions{ChunkSize: 1000, Concurrency: 3})
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll error = %v", err)
+	}
+	_ = r.Close()
+	if !bytes.Equal(got, data) {
+		t.Errorf("read %d bytes, want %d in order", len(got), len(data))
+	}
+	if n := backend.reads.Load(); n != 16 {
+		t.Errorf("ranged reads = %d, want 16", n)
+	}
+
+	// A read from an offset
+	r = omnistorage.NewParallelReader(ctx, backend, "big.bin", 4500,
+		omnistorage.ParallelReadOptions{ChunkSize: 1000, Concurrency: 3, Offset: 2500})
+	got, err = io.ReadAll(r)
+	_ = r.Close()
+	if err != nil || !bytes.Equal(got, data[2500:4500]) {
+		t.Errorf("read from offset = %d bytes, %v, want %d", len(got), err, 2000)
+	}
+
+	// A failed chunk fails the reader
+	backend.failOffset = 5000
+	r = omnistorage.NewParallelReader(ctx, backend, "big.bin", int64(len(data)),
+		omnistorage.ParallelReadOptions{ChunkSize: 1000, Concurrency: 3})
+	defer r.Close()
+	got, err = io.ReadAll(r)
+	if err == nil {
+		t.Fatal("ReadAll succeeded with a failed chunk")
+	}
+	if len(got) != 5000 {
+		t.Errorf("read %d bytes before the error, want 5000", len(got))
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	ctx := context.Background()
+	backend := &rangeCounter{Backend: memory.New()}
+	data := bytes.Repeat([]byte("x"), 10000)
+	writeObject(t, backend, "big.bin", data)
+	writeObject(t, backend, "small.txt", []byte("hello"))
+	dir := t.TempDir()
+	opts := omnistorage.ParallelReadOptions{ChunkSize: 4096, Concurrency: 2}
+
+	local := filepath.Join(dir, "big.bin")
+	n, err := omnistorage.DownloadFile(ctx, backend, "big.bin", local, opts)
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("DownloadFile = %d, %v", n, err)
+	}
+	if got, _ := os.ReadFile(local); !bytes.Equal(got, data) {
+		t.Errorf("downloaded %d bytes, want %d", len(got), len(data))
+	}
+	if n := backend.reads.Load(); n != 3 {
+		t.Errorf("reads = %d, want 3", n)
+	}
+
+	// Objects of one chunk or less are read in one stream
+	backend.reads.Store(0)
+	if _, err := omnistorage.DownloadFile(ctx, backend, "small.txt", filepath.Join(dir, "small.txt"), opts); err != nil {
+		t.Fatalf("DownloadFile error = %v", err)
+	}
+	if n := backend.reads.Load(); n != 1 {
+		t.Errorf("reads = %d, want 1", n)
+	}
+
+	// A failed download leaves no file behind
+	backend.failOffset = 4096
+	failed := filepath.Join(dir, "failed.bin")
+	if _, err := omnistorage.DownloadFile(ctx, backend, "big.bin", failed, opts); err == nil {
+		t.Fatal("DownloadFile succeeded with a failed chunk")
+	}
+	if _, err := os.Stat(failed); !os.IsNotExist(err) {
+		t.Errorf("Stat(failed) error = %v, want not exist", err)
+	}
+	if _, err := omnistorage.DownloadFile(ctx, backend, "missing", filepath.Join(dir, "missing"), opts); !omnistorage.IsNotFound(err) {
+		t.Errorf("DownloadFile(missing) error = %v, want not found", err)
+	}
+}
+
+func writeObject(t *testing.T, backend omnistorage.Backend, p string, data []byte) {
+	t.Helper()
+	w, err := backend.NewWriter(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,10 @@
 package omnistorage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"strings"
+)
 
 // Common errors returned by omnistorage backends and utilities.
 var (
@@ -29,8 +33,34 @@ var (
 	// ErrNotSupported is returned when an operation is not supported by the backend.
 	ErrNotSupported = errors.New("omnistorage: operation not supported")
 
+	// ErrChecksumMismatch is returned when written data does not match the
+	// checksum given with WithContentMD5 or WithContentSHA256.
+	ErrChecksumMismatch = errors.New("omnistorage: checksum mismatch")
+
+	// ErrThrottled is returned when a request was rejected because of a
+	// rate limit.
+	ErrThrottled = errors.New("omnistorage: throttled")
+
+	// ErrTimeout is returned when an operation did not complete in time.
+	ErrTimeout = errors.New("omnistorage: timeout")
+
+	// ErrQuotaExceeded is returned when a write would exceed a storage
+	// quota.
+	ErrQuotaExceeded = errors.New("omnistorage: quota exceeded")
+
+	// ErrObjectLocked is returned when an object cannot be deleted because
+	// of a retention period or legal hold.
+	ErrObjectLocked = errors.New("omnistorage: object locked")
+
+	// ErrImmutable is returned when a write or delete would replace or
+	// remove an object that must not change, such as under a WORM prefix.
+	ErrImmutable = errors.New("omnistorage: immutable")
+
 	// ErrUnknownBackend is returned by Open when the backend name is not registered.
 	ErrUnknownBackend = errors.New("omnistorage: unknown backend")
+
+	// ErrUnknownRemote is returned by OpenRemote when the remote name has not been added.
+	ErrUnknownRemote = errors.New("omnistorage: unknown remote")
 )
 
 // IsNotFound returns true if the error indicates a path was not found.
@@ -47,3 +77,166 @@ func IsPermissionDenied(err error) bool {
 func IsNotSupported(err error) bool {
 	return errors.Is(err, ErrNotSupported)
 }
+
+// IsThrottled returns true if the error indicates a request was rate limited.
+func IsThrottled(err error) bool {
+	return errors.Is(err, ErrThrottled)
+}
+
+// IsTimeout returns true if the error indicates an operation timed out.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// ErrorKind is the kind of failure an Error describes.
+type ErrorKind int
+
+const (
+	// KindOther is a failure of no specific kind.
+	KindOther ErrorKind = iota
+
+	// KindNotFound means the path does not exist. Errors of this kind
+	// match ErrNotFound.
+	KindNotFound
+
+	// KindPermissionDenied means access to the path was denied. Errors of
+	// this kind match ErrPermissionDenied.
+	KindPermissionDenied
+
+	// KindThrottled means the request was rate limited. Errors of this
+	// kind match ErrThrottled.
+	KindThrottled
+
+	// KindTimeout means the operation did not complete in time. Errors of
+	// this kind match ErrTimeout.
+	KindTimeout
+
+	// KindQuotaExceeded means a storage quota was exceeded. Errors of
+	// this kind match ErrQuotaExceeded.
+	KindQuotaExceeded
+)
+
+// kindErrors maps each kind to the sentinel errors of that kind match.
+var kindErrors = map[ErrorKind]error{
+	KindNotFound:         ErrNotFound,
+	KindPermissionDenied: ErrPermissionDenied,
+	KindThrottled:        ErrThrottled,
+	KindTimeout:          ErrTimeout,
+	KindQuotaExceeded:    ErrQuotaExceeded,
+}
+
+// String returns the kind name.
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindPermissionDenied:
+		return "permission denied"
+	case KindThrottled:
+		return "throttled"
+	case KindTimeout:
+		return "timeout"
+	case KindQuotaExceeded:
+		return "quota exceeded"
+	default:
+		return "other"
+	}
+}
+
+// Error is an error from an operation on a path of a backend. It matches
+// the sentinel error of its Kind with errors.Is, so callers can check
+// errors.Is(err, ErrNotFound) and still recover the failing path with
+// errors.As:
+//
+//	var e *omnistorage.Error
+//	if errors.As(err, &e) {
+//	    log.Printf("%s %s failed: %v", e.Op, e.Path, e.Kind)
+//	}
+type Error struct {
+	// Op is the operation that failed, such as "stat" or "copy".
+	Op string
+
+	// Backend is the name of the backend, such as "s3".
+	Backend string
+
+	// Path is the path the operation failed on, if any.
+	Path string
+
+	// Kind is the kind of failure.
+	Kind ErrorKind
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the error message, prefixed with the backend, operation
+// and path.
+func (e *Error) Error() string {
+	var b strings.Builder
+	for _, s := range []string{e.Backend, e.Op, e.Path} {
+		if s == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s)
+	}
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	switch {
+	case e.Err != nil:
+		b.WriteString(e.Err.Error())
+	case kindErrors[e.Kind] != nil:
+		b.WriteString(kindErrors[e.Kind].Error())
+	default:
+		b.WriteString("omnistorage: error")
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error of e's Kind.
+func (e *Error) Is(target error) bool {
+	sentinel, ok := kindErrors[e.Kind]
+	return ok && target == sentinel
+}
+
+// WrapError returns err as an *Error for the operation op on path of
+// backend, with its Kind set by KindOf. It returns nil if err is nil.
+func WrapError(op, backend, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Op: op, Backend: backend, Path: path, Kind: KindOf(err), Err: err}
+}
+
+// KindOf returns the kind of err: the Kind of the first *Error it wraps,
+// or else the kind of the sentinel error it matches. Context deadlines are
+// KindTimeout and errors ClassifyError finds throttled are KindThrottled.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) && e.Kind != KindOther {
+		return e.Kind
+	}
+	switch {
+	case err == nil:
+		return KindOther
+	case errors.Is(err, ErrNotFound):
+		return KindNotFound
+	case errors.Is(err, ErrPermissionDenied):
+		return KindPermissionDenied
+	case errors.Is(err, ErrQuotaExceeded):
+		return KindQuotaExceeded
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return KindTimeout
+	case errors.Is(err, ErrThrottled), ClassifyError(err) == ErrorThrottled:
+		return KindThrottled
+	}
+	return KindOther
+}
@@ -0,0 +1,73 @@
+package omnistorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	cause := errors.New("NoSuchKey: the key does not exist")
+	err := fmt.Errorf("sync: %w", &Error{Op: "stat", Backend: "s3", Path: "a/b.txt", Kind: KindNotFound, Err: cause})
+
+	if !errors.Is(err, ErrNotFound) || !IsNotFound(err) {
+		t.Errorf("errors.Is(%v, ErrNotFound) = false", err)
+	}
+	if errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("errors.Is(%v, ErrPermissionDenied) = true", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("errors.Is(%v, cause) = false", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Path != "a/b.txt" {
+		t.Fatalf("errors.As(%v) = %+v", err, e)
+	}
+	if got, want := e.Error(), "s3 stat a/b.txt: NoSuchKey: the key does not exist"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if got, want := (&Error{Op: "list", Kind: KindThrottled}).Error(), "list: omnistorage: throttled"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if ClassifyError(&Error{Kind: KindThrottled}) != ErrorThrottled {
+		t.Error("ClassifyError(KindThrottled) is not ErrorThrottled")
+	}
+	if ClassifyError(&Error{Kind: KindNotFound}) != ErrorPermanent {
+		t.Error("ClassifyError(KindNotFound) is not ErrorPermanent")
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorKind
+	}{
+		{nil, KindOther},
+		{errors.New("boom"), KindOther},
+		{ErrNotFound, KindNotFound},
+		{fmt.Errorf("read: %w", ErrPermissionDenied), KindPermissionDenied},
+		{context.DeadlineExceeded, KindTimeout},
+		{ErrQuotaExceeded, KindQuotaExceeded},
+		{httpError{status: 429}, KindThrottled},
+		{&Error{Kind: KindTimeout, Err: ErrNotFound}, KindTimeout},
+	}
+	for _, tt := range tests {
+		if got := KindOf(tt.err); got != tt.want {
+			t.Errorf("KindOf(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+
+	wrapped := WrapError("read", "sftp", "x", context.DeadlineExceeded)
+	if !IsTimeout(wrapped) || !errors.Is(wrapped, context.DeadlineExceeded) {
+		t.Errorf("WrapError = %v, want a timeout wrapping DeadlineExceeded", wrapped)
+	}
+	if WrapError("read", "sftp", "x", nil) != nil {
+		t.Error("WrapError(nil) != nil")
+	}
+}
+
+type httpError struct{ status int }
+
+func (e httpError) Error() string       { return fmt.Sprintf("http %d", e.status) }
+func (e httpError) HTTPStatusCode() int { return e.status }
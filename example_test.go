@@ -245,6 +245,20 @@ func TestIntegrationBackendsList(t *testing.T) {
 	}
 }
 
+// TestIntegrationDescribe verifies registered backends describe themselves.
+func TestIntegrationDescribe(t *testing.T) {
+	info, err := omnistorage.Describe("file")
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if info.Description == "" || len(info.ConfigKeys) == 0 {
+		t.Errorf("Describe(file) = %+v, want description and config keys", info)
+	}
+	if !info.Features.Copy || !info.Features.RangeRead {
+		t.Errorf("Describe(file).Features = %+v", info.Features)
+	}
+}
+
 // gzipWriteCloser wraps gzip.Writer to implement io.WriteCloser for ndjson.
 type gzipWriteCloser struct {
 	*gzip.Writer
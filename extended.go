@@ -1,6 +1,9 @@
 package omnistorage
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 // ExtendedBackend extends Backend with additional operations for
 // metadata access, directory management, and server-side operations.
@@ -67,3 +70,122 @@ func MustExtended(b Backend) ExtendedBackend {
 	}
 	return ext
 }
+
+// Linker is implemented by backends that can make one path share the
+// content of another without copying it, such as hard links on a local
+// filesystem.
+type Linker interface {
+	// Link makes path refer to the content of target, replacing path if
+	// it exists. Returns ErrNotFound if target does not exist.
+	Link(ctx context.Context, target, path string) error
+}
+
+// DirLister is implemented by backends that keep directories separately
+// from the objects in them, so that empty directories can be found. List
+// returns only objects.
+type DirLister interface {
+	// ListDirs returns the directories under the directory prefix,
+	// including empty ones, in lexical order and, as List does, relative
+	// to prefix. The prefix itself is not included.
+	ListDirs(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Patcher is implemented by backends that can modify an object in place,
+// so that a delta transfer writes only the parts of a file that changed
+// (see sync.Options.DeltaTransfer).
+type Patcher interface {
+	// WriteAt writes p to the object at path at offset off, extending the
+	// object if needed. Returns ErrNotFound if path does not exist.
+	WriteAt(ctx context.Context, path string, p []byte, off int64) error
+
+	// Truncate changes the size of the object at path, extending it with
+	// zeros if size is larger. Returns ErrNotFound if path does not exist.
+	Truncate(ctx context.Context, path string, size int64) error
+}
+
+// CompressedReader is implemented by backends that can send an object
+// compressed over the network, so that a copy through the client moves
+// fewer bytes over a slow link (see sync.Options.TransferCompression).
+type CompressedReader interface {
+	// NewCompressedReader returns a reader of the gzip-compressed content
+	// of path. Returns ErrNotFound if path does not exist, and
+	// ErrNotSupported if compression is not available, in which case the
+	// caller reads the object with NewReader.
+	NewCompressedReader(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// Uploader is implemented by backends whose writers hold the whole object
+// in memory until Close, such as S3, and that can instead upload an object
+// from a reader a part at a time.
+type Uploader interface {
+	// Upload writes the content of r to path, replacing it if it exists,
+	// reading r as the upload proceeds.
+	Upload(ctx context.Context, path string, r io.Reader, opts ...WriterOption) error
+}
+
+// MetadataSetter is implemented by backends that can change the content
+// type and custom metadata of an object without rewriting its content.
+type MetadataSetter interface {
+	// SetMetadata replaces the custom metadata of the object at path with
+	// metadata, removing it if metadata is empty, and sets the object's
+	// content type unless contentType is empty. Returns ErrNotFound if
+	// path does not exist.
+	SetMetadata(ctx context.Context, path string, metadata map[string]string, contentType string) error
+}
+
+// Tagger is implemented by backends that keep key-value tags on objects
+// apart from their metadata, such as S3 object tags, which can be changed
+// without rewriting the object.
+type Tagger interface {
+	// Tags returns the tags of the object at path, or nil if it has none.
+	// Returns ErrNotFound if path does not exist.
+	Tags(ctx context.Context, path string) (map[string]string, error)
+
+	// SetTags replaces the tags of the object at path. An empty map
+	// removes all tags.
+	SetTags(ctx context.Context, path string, tags map[string]string) error
+}
+
+// Pinger is implemented by backends that can check they are reachable
+// and usable without reading or writing an object, such as by checking
+// that a bucket exists. Use Probe to check any backend.
+type Pinger interface {
+	// Ping returns an error if the backend cannot be reached or its
+	// configured location, such as a bucket or root directory, is not
+	// accessible.
+	Ping(ctx context.Context) error
+}
+
+// Abouter is implemented by backends that can report their capacity and
+// usage, such as for showing remaining space on a dashboard.
+type Abouter interface {
+	// About returns the capacity and usage of the backend. Values the
+	// backend cannot report are -1.
+	About(ctx context.Context) (Usage, error)
+}
+
+// Usage is the capacity and usage of a backend, as returned by About.
+// Values that are not known are -1.
+type Usage struct {
+	// Total is the capacity in bytes.
+	Total int64
+
+	// Used is the number of bytes stored.
+	Used int64
+
+	// Free is the number of bytes that can still be stored.
+	Free int64
+
+	// Objects is the number of objects stored.
+	Objects int64
+}
+
+// DirExister is implemented by backends that can check whether a
+// directory exists cheaply, such as object stores, where a directory is
+// a key prefix shared by objects. Use DirExists to check any backend.
+type DirExister interface {
+	// DirExists reports whether path is a directory: a directory on a
+	// filesystem, or a prefix of at least one object, or a directory
+	// marker, on an object store.
+	DirExists(ctx context.Context, path string) (bool, error)
+}
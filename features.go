@@ -57,6 +57,11 @@ type Features struct {
 	// CustomMetadata indicates the backend supports custom metadata.
 	// When true, arbitrary key-value metadata can be stored with objects.
 	CustomMetadata bool
+
+	// ObjectLock indicates the backend can protect objects from deletion
+	// with retention periods and legal holds (see ObjectLocker). Sync
+	// checks the lock of each object it deletes on such backends.
+	ObjectLock bool
 }
 
 // SupportsHash returns true if the backend supports the given hash type.
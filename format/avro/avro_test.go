@@ -0,0 +1,388 @@
+package avro
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+const eventSchema = `{
+	"type": "record",
+	"name": "Event",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "msg", "type": "string"},
+		{"name": "level", "type": {"type": "enum", "name": "Level", "symbols": ["DEBUG", "INFO", "ERROR"]}},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "attrs", "type": {"type": "map", "values": "double"}},
+		{"name": "parent", "type": ["null", "long"]},
+		{"name": "digest", "type": {"type": "fixed", "name": "MD5", "size": 4}},
+		{"name": "ok", "type": "boolean"},
+		{"name": "ratio", "type": "float"},
+		{"name": "count", "type": "int"},
+		{"name": "raw", "type": "bytes"}
+	]
+}`
+
+// testWriteCloser wraps a bytes.Buffer with a Close method.
+type testWriteCloser struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func newTestWriteCloser() *testWriteCloser {
+	return &testWriteCloser{Buffer: new(bytes.Buffer)}
+}
+
+func (t *testWriteCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+// testReadCloser wraps a bytes.Reader with a Close method.
+type testReadCloser struct {
+	*bytes.Reader
+	closed bool
+}
+
+func newTestReadCloser(data []byte) *testReadCloser {
+	return &testReadCloser{Reader: bytes.NewReader(data)}
+}
+
+func (t *testReadCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+func sampleEvent(id int64) map[string]any {
+	var parent any
+	if id > 0 {
+		parent = id - 1
+	}
+	return map[string]any{
+		"id":     id,
+		"msg":    "hello",
+		"level":  "INFO",
+		"tags":   []any{"a", "b"},
+		"attrs":  map[string]any{"cpu": 0.5},
+		"parent": parent,
+		"digest": []byte{1, 2, 3, 4},
+		"ok":     true,
+		"ratio":  float32(1.5),
+		"count":  int32(7),
+		"raw":    []byte("xyz"),
+	}
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	schema := MustParseSchema(eventSchema)
+	if schema.Type() != "record" {
+		t.Errorf("Type() = %q, want record", schema.Type())
+	}
+
+	for _, id := range []int64{0, 42} {
+		in := sampleEvent(id)
+		data, err := schema.Encode(in)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		out, err := schema.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("Decode = %#v, want %#v", out, in)
+		}
+	}
+}
+
+func TestSchemaEncodeErrors(t *testing.T) {
+	schema := MustParseSchema(eventSchema)
+
+	bad := sampleEvent(1)
+	bad["level"] = "TRACE"
+	if _, err := schema.Encode(bad); !errors.Is(err, ErrInvalidDatum) {
+		t.Errorf("Encode unknown enum: error = %v, want ErrInvalidDatum", err)
+	}
+
+	bad = sampleEvent(1)
+	bad["count"] = int64(1 << 40)
+	if _, err := schema.Encode(bad); !errors.Is(err, ErrInvalidDatum) {
+		t.Errorf("Encode int overflow: error = %v, want ErrInvalidDatum", err)
+	}
+}
+
+func TestParseSchemaInvalid(t *testing.T) {
+	tests := []string{
+		`not json`,
+		`"unknown"`,
+		`{"type": "record", "fields": []}`,
+		`[]`,
+	}
+	for _, s := range tests {
+		if _, err := ParseSchema(s); !errors.Is(err, ErrInvalidSchema) {
+			t.Errorf("ParseSchema(%q): error = %v, want ErrInvalidSchema", s, err)
+		}
+	}
+}
+
+func TestWriterReaderCodecs(t *testing.T) {
+	for _, codec := range []Codec{CodecNull, CodecDeflate, CodecSnappy} {
+		t.Run(string(codec), func(t *testing.T) {
+			schema := MustParseSchema(eventSchema)
+			buf := newTestWriteCloser()
+
+			w, err := NewWriter(buf, schema, WithCodec(codec), WithBlockLength(3))
+			if err != nil {
+				t.Fatalf("NewWriter failed: %v", err)
+			}
+			for i := int64(0); i < 10; i++ {
+				if err := w.WriteValue(sampleEvent(i)); err != nil {
+					t.Fatalf("WriteValue failed: %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if !buf.closed {
+				t.Error("Underlying writer should be closed")
+			}
+
+			src := newTestReadCloser(buf.Bytes())
+			r, err := NewReader(src)
+			if err != nil {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			if r.Codec() != codec {
+				t.Errorf("Codec() = %q, want %q", r.Codec(), codec)
+			}
+			if r.Schema().String() != eventSchema {
+				t.Error("Schema() should match the embedded writer schema")
+			}
+
+			for i := int64(0); i < 10; i++ {
+				v, err := r.ReadValue()
+				if err != nil {
+					t.Fatalf("ReadValue %d failed: %v", i, err)
+				}
+				if !reflect.DeepEqual(v, sampleEvent(i)) {
+					t.Errorf("ReadValue %d = %#v", i, v)
+				}
+			}
+			if _, err := r.Read(); err != io.EOF {
+				t.Errorf("Expected EOF, got: %v", err)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if !src.closed {
+				t.Error("Underlying reader should be closed")
+			}
+		})
+	}
+}
+
+func TestWriterRawRecords(t *testing.T) {
+	schema := MustParseSchema(`"string"`)
+	buf := newTestWriteCloser()
+
+	w, err := NewWriter(buf, schema, WithMetadata("producer", []byte("test")))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	records := []string{"alpha", "beta", "gamma"}
+	for _, rec := range records {
+		data, _ := schema.Encode(rec)
+		if err := w.Write(data); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	_ = w.Close()
+
+	r, err := NewReader(newTestReadCloser(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	if got := string(r.Metadata("producer")); got != "test" {
+		t.Errorf("Metadata(producer) = %q, want %q", got, "test")
+	}
+
+	for _, want := range records {
+		data, err := r.Read()
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		got, _ := schema.Decode(data)
+		if got != want {
+			t.Errorf("Read = %v, want %q", got, want)
+		}
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	w, err := NewWriter(newTestWriteCloser(), MustParseSchema(`"long"`))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := w.Write([]byte{0}); err != omnistorage.ErrWriterClosed {
+		t.Errorf("Write after Close: error = %v, want %v", err, omnistorage.ErrWriterClosed)
+	}
+	if err := w.Flush(); err != omnistorage.ErrWriterClosed {
+		t.Errorf("Flush after Close: error = %v, want %v", err, omnistorage.ErrWriterClosed)
+	}
+}
+
+func TestReaderClosed(t *testing.T) {
+	buf := newTestWriteCloser()
+	w, _ := NewWriter(buf, MustParseSchema(`"long"`))
+	_ = w.WriteValue(int64(1))
+	_ = w.Close()
+
+	r, err := NewReader(newTestReadCloser(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	_ = r.Close()
+
+	if _, err := r.Read(); err != omnistorage.ErrReaderClosed {
+		t.Errorf("Read after Close: error = %v, want %v", err, omnistorage.ErrReaderClosed)
+	}
+}
+
+func TestReaderInvalidFile(t *testing.T) {
+	if _, err := NewReader(newTestReadCloser([]byte("not avro"))); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("NewReader: error = %v, want ErrInvalidFile", err)
+	}
+
+	// Corrupt the sync marker of the first block.
+	buf := newTestWriteCloser()
+	w, _ := NewWriter(buf, MustParseSchema(`"long"`))
+	_ = w.WriteValue(int64(1))
+	_ = w.Close()
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	r, err := NewReader(newTestReadCloser(data))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	if _, err := r.Read(); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("Read: error = %v, want ErrInvalidFile", err)
+	}
+}
+
+func TestReaderCorruptSizes(t *testing.T) {
+	buf := newTestWriteCloser()
+	w, _ := NewWriter(buf, MustParseSchema(`"long"`))
+	_ = w.Close()
+	header := buf.Bytes()
+
+	long := func(v int64) []byte { return binary.AppendVarint(nil, v) }
+	for name, block := range map[string][]byte{
+		"huge block":    append(long(1), long(1<<62)...),
+		"negative size": append(long(1), long(-5)...),
+		"over maximum":  append(long(1), long(1025)...),
+	} {
+		data := append(slices.Clone(header), block...)
+		r, err := NewReaderSize(newTestReadCloser(data), 1024)
+		if err != nil {
+			t.Fatalf("%s: NewReaderSize failed: %v", name, err)
+		}
+		if _, err := r.Read(); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("%s: Read error = %v, want ErrInvalidFile", name, err)
+		}
+	}
+
+	// A metadata value longer than the maximum
+	data := append(slices.Clone(magic), long(1)...)
+	data = append(data, long(1)...)
+	data = append(data, 'k')
+	data = append(data, long(1<<62)...)
+	if _, err := NewReader(newTestReadCloser(data)); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("NewReader with a huge metadata value: error = %v, want ErrInvalidFile", err)
+	}
+
+	// Blocks decompressing past the maximum
+	buf = newTestWriteCloser()
+	w, _ = NewWriter(buf, MustParseSchema(`"string"`), WithCodec(CodecDeflate))
+	_ = w.WriteValue(strings.Repeat("a", 4096))
+	_ = w.Close()
+	r, err := NewReaderSize(newTestReadCloser(buf.Bytes()), 1024)
+	if err != nil {
+		t.Fatalf("NewReaderSize failed: %v", err)
+	}
+	if _, err := r.Read(); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("Read of an oversized deflate block: error = %v, want ErrInvalidFile", err)
+	}
+}
+
+func TestWithBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	schema := MustParseSchema(`{"type":"record","name":"KV","fields":[{"name":"k","type":"string"},{"name":"v","type":"long"}]}`)
+
+	raw, err := backend.NewWriter(ctx, "data/kv.avro")
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	w, err := NewWriter(raw, schema, WithCodec(CodecDeflate))
+	if err != nil {
+		t.Fatalf("avro.NewWriter failed: %v", err)
+	}
+	for i := int64(0); i < 100; i++ {
+		if err := w.WriteValue(map[string]any{"k": "key", "v": i}); err != nil {
+			t.Fatalf("WriteValue failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	rc, err := backend.NewReader(ctx, "data/kv.avro")
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	r, err := NewReader(rc)
+	if err != nil {
+		t.Fatalf("avro.NewReader failed: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	var n int64
+	for {
+		v, err := r.ReadValue()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadValue failed: %v", err)
+		}
+		if got := v.(map[string]any)["v"]; got != n {
+			t.Errorf("record %d: v = %v", n, got)
+		}
+		n++
+	}
+	if n != 100 {
+		t.Errorf("read %d records, want 100", n)
+	}
+}
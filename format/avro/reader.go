@@ -0,0 +1,300 @@
+package avro
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+
+	"github.com/grokify/omnistorage"
+)
+
+// DefaultMaxBlockSize is the default maximum size of a block, before and
+// after decompression, and of a header metadata value. Larger sizes are
+// rejected to avoid unbounded allocations on corrupt input.
+const DefaultMaxBlockSize = 64 * 1024 * 1024 // 64MB
+
+// ErrInvalidFile is returned when the input is not a valid Object Container File.
+var ErrInvalidFile = errors.New("avro: invalid object container file")
+
+// Reader implements omnistorage.RecordReader for Avro Object Container Files.
+// Each record is returned as a single Avro binary datum encoded with the
+// file's schema; use ReadValue to decode records into Go values.
+type Reader struct {
+	r        *bufio.Reader
+	closer   io.Closer
+	schema   *Schema
+	codec    Codec
+	metadata map[string][]byte
+	sync     [syncSize]byte
+	block    decoder
+	remain   int64
+	maxSize  int64
+	closed   bool
+	mu       sync.Mutex
+}
+
+// NewReader creates a new OCF reader that reads from the given io.ReadCloser.
+// The file header is read and validated immediately.
+// The reader will be closed when the OCF reader is closed.
+func NewReader(r io.ReadCloser) (*Reader, error) {
+	return NewReaderSize(r, DefaultMaxBlockSize)
+}
+
+// NewReaderSize creates a new OCF reader with the specified maximum block
+// size. Blocks and metadata values larger than maxBlockSize fail with
+// ErrInvalidFile.
+func NewReaderSize(r io.ReadCloser, maxBlockSize int) (*Reader, error) {
+	ar := &Reader{
+		r:       bufio.NewReader(r),
+		closer:  r,
+		maxSize: int64(maxBlockSize),
+	}
+	if err := ar.readHeader(); err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
+
+// readHeader reads the magic bytes, file metadata, and sync marker.
+func (r *Reader) readHeader() error {
+	var m [4]byte
+	if _, err := io.ReadFull(r.r, m[:]); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	if !bytes.Equal(m[:], magic) {
+		return fmt.Errorf("%w: bad magic", ErrInvalidFile)
+	}
+
+	r.metadata = make(map[string][]byte)
+	for {
+		count, err := r.readLong()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			count = -count
+			if _, err := r.readLong(); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+		}
+		for ; count > 0; count-- {
+			k, err := r.readBytes()
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			v, err := r.readBytes()
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			r.metadata[string(k)] = v
+		}
+	}
+
+	if _, err := io.ReadFull(r.r, r.sync[:]); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	schema, err := ParseSchema(string(r.metadata[metaSchema]))
+	if err != nil {
+		return err
+	}
+	r.schema = schema
+
+	r.codec = CodecNull
+	if c, ok := r.metadata[metaCodec]; ok && len(c) > 0 {
+		r.codec = Codec(c)
+	}
+	switch r.codec {
+	case CodecNull, CodecDeflate, CodecSnappy:
+	default:
+		return fmt.Errorf("avro: unsupported codec %q", r.codec)
+	}
+	return nil
+}
+
+// Schema returns the writer schema embedded in the file header.
+func (r *Reader) Schema() *Schema {
+	return r.schema
+}
+
+// Codec returns the block compression codec of the file.
+func (r *Reader) Codec() Codec {
+	return r.codec
+}
+
+// Metadata returns the value of a header metadata entry, or nil if absent.
+func (r *Reader) Metadata(key string) []byte {
+	return r.metadata[key]
+}
+
+// Read reads the next record as an Avro binary datum.
+// Returns io.EOF when no more records are available.
+// The returned slice is valid until the next call to Read.
+func (r *Reader) Read() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, omnistorage.ErrReaderClosed
+	}
+
+	for r.remain == 0 {
+		if err := r.nextBlock(); err != nil {
+			return nil, err
+		}
+	}
+
+	start := r.block.pos
+	if _, err := r.block.value(r.schema.node); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	r.remain--
+
+	if r.remain == 0 && r.block.pos != len(r.block.buf) {
+		return nil, fmt.Errorf("%w: %d trailing bytes in block", ErrInvalidFile, len(r.block.buf)-r.block.pos)
+	}
+
+	return r.block.buf[start:r.block.pos], nil
+}
+
+// ReadValue reads the next record and decodes it with the file's schema.
+// Returns io.EOF when no more records are available.
+func (r *Reader) ReadValue() (any, error) {
+	data, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	return r.schema.Decode(data)
+}
+
+// nextBlock reads and decompresses the next block. Caller must hold mu.
+func (r *Reader) nextBlock() error {
+	count, err := r.readLong()
+	if err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	size, err := r.readLong()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFile, unexpected(err))
+	}
+	if count < 0 || size < 0 {
+		return fmt.Errorf("%w: negative block header", ErrInvalidFile)
+	}
+	if size > r.maxSize {
+		return fmt.Errorf("%w: block of %d bytes (max %d)", ErrInvalidFile, size, r.maxSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFile, unexpected(err))
+	}
+
+	var marker [syncSize]byte
+	if _, err := io.ReadFull(r.r, marker[:]); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFile, unexpected(err))
+	}
+	if marker != r.sync {
+		return fmt.Errorf("%w: sync marker mismatch", ErrInvalidFile)
+	}
+
+	data, err = decompressBlock(r.codec, data, r.maxSize)
+	if err != nil {
+		return err
+	}
+
+	r.block = decoder{buf: data}
+	r.remain = count
+	return nil
+}
+
+// Close releases any resources held by the reader.
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	return r.closer.Close()
+}
+
+func (r *Reader) readLong() (int64, error) {
+	return binary.ReadVarint(r.r)
+}
+
+func (r *Reader) readBytes() ([]byte, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("negative length %d", n)
+	}
+	if n > r.maxSize {
+		return nil, fmt.Errorf("length %d (max %d)", n, r.maxSize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// unexpected converts io.EOF into io.ErrUnexpectedEOF for mid-block reads.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decompressBlock decompresses block data with the given codec, to at
+// most maxSize bytes.
+func decompressBlock(codec Codec, data []byte, maxSize int64) ([]byte, error) {
+	switch codec {
+	case CodecDeflate:
+		fr := flate.NewReader(bytes.NewReader(data))
+		defer func() { _ = fr.Close() }()
+		out, err := io.ReadAll(io.LimitReader(fr, maxSize+1))
+		if err == nil && int64(len(out)) > maxSize {
+			return nil, fmt.Errorf("%w: decompressed block over %d bytes", ErrInvalidFile, maxSize)
+		}
+		return out, err
+	case CodecSnappy:
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: short snappy block", ErrInvalidFile)
+		}
+		if n, err := snappy.DecodedLen(data[:len(data)-4]); err == nil && int64(n) > maxSize {
+			return nil, fmt.Errorf("%w: decompressed block of %d bytes (max %d)", ErrInvalidFile, n, maxSize)
+		}
+		out, err := snappy.Decode(nil, data[:len(data)-4])
+		if err != nil {
+			return nil, err
+		}
+		if crc32.ChecksumIEEE(out) != binary.BigEndian.Uint32(data[len(data)-4:]) {
+			return nil, fmt.Errorf("%w: snappy checksum mismatch", ErrInvalidFile)
+		}
+		return out, nil
+	default:
+		return data, nil
+	}
+}
+
+// Ensure Reader implements omnistorage.RecordReader
+var _ omnistorage.RecordReader = (*Reader)(nil)
@@ -0,0 +1,633 @@
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Errors returned by schema parsing and datum encoding.
+var (
+	// ErrInvalidSchema is returned when a schema cannot be parsed.
+	ErrInvalidSchema = errors.New("avro: invalid schema")
+
+	// ErrInvalidDatum is returned when a value does not match the schema
+	// or encoded data is malformed.
+	ErrInvalidDatum = errors.New("avro: invalid datum")
+)
+
+// Schema is a parsed Avro schema.
+//
+// A Schema can encode Go values to Avro binary datums and decode them back.
+// Values are represented generically:
+//   - null: nil
+//   - boolean: bool
+//   - int: int32
+//   - long: int64
+//   - float: float32
+//   - double: float64
+//   - bytes, fixed: []byte
+//   - string, enum: string
+//   - array: []any
+//   - map: map[string]any
+//   - record: map[string]any keyed by field name
+//   - union: the value of the selected branch
+//
+// Encode also accepts other Go integer and float types where they fit.
+type Schema struct {
+	raw  string
+	node *schemaNode
+}
+
+// schemaNode is a single node of a parsed schema tree.
+type schemaNode struct {
+	kind    string
+	name    string
+	fields  []schemaField
+	symbols []string
+	items   *schemaNode
+	values  *schemaNode
+	size    int
+	union   []*schemaNode
+}
+
+// schemaField is a field of a record schema.
+type schemaField struct {
+	name string
+	node *schemaNode
+}
+
+// ParseSchema parses an Avro schema from its JSON representation.
+func ParseSchema(schema string) (*Schema, error) {
+	var v any
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	p := &schemaParser{named: make(map[string]*schemaNode)}
+	node, err := p.parse(v, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{raw: schema, node: node}, nil
+}
+
+// MustParseSchema is like ParseSchema but panics on error.
+func MustParseSchema(schema string) *Schema {
+	s, err := ParseSchema(schema)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the schema's JSON representation as provided to ParseSchema.
+func (s *Schema) String() string {
+	return s.raw
+}
+
+// Type returns the top-level type of the schema (e.g., "record", "string").
+func (s *Schema) Type() string {
+	return s.node.kind
+}
+
+// Encode encodes a value as an Avro binary datum.
+func (s *Schema) Encode(v any) ([]byte, error) {
+	return appendValue(nil, s.node, v)
+}
+
+// Decode decodes an Avro binary datum.
+// It returns an error if data contains trailing bytes.
+func (s *Schema) Decode(data []byte) (any, error) {
+	d := &decoder{buf: data}
+	v, err := d.value(s.node)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidDatum, len(data)-d.pos)
+	}
+	return v, nil
+}
+
+// schemaParser tracks named types while parsing.
+type schemaParser struct {
+	named map[string]*schemaNode
+}
+
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+func (p *schemaParser) parse(v any, namespace string) (*schemaNode, error) {
+	switch t := v.(type) {
+	case string:
+		if primitiveTypes[t] {
+			return &schemaNode{kind: t}, nil
+		}
+		if n, ok := p.named[fullName(t, namespace)]; ok {
+			return n, nil
+		}
+		if n, ok := p.named[t]; ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidSchema, t)
+	case []any:
+		node := &schemaNode{kind: "union"}
+		for _, branch := range t {
+			b, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			node.union = append(node.union, b)
+		}
+		if len(node.union) == 0 {
+			return nil, fmt.Errorf("%w: empty union", ErrInvalidSchema)
+		}
+		return node, nil
+	case map[string]any:
+		return p.parseObject(t, namespace)
+	default:
+		return nil, fmt.Errorf("%w: unexpected %T", ErrInvalidSchema, v)
+	}
+}
+
+func (p *schemaParser) parseObject(m map[string]any, namespace string) (*schemaNode, error) {
+	typ, ok := m["type"]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing type", ErrInvalidSchema)
+	}
+	kind, ok := typ.(string)
+	if !ok {
+		// {"type": {...}} or {"type": [...]} wraps another schema.
+		return p.parse(typ, namespace)
+	}
+
+	switch kind {
+	case "record", "error", "enum", "fixed":
+		name, _ := m["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("%w: %s requires a name", ErrInvalidSchema, kind)
+		}
+		if ns, ok := m["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		full := fullName(name, namespace)
+		if i := strings.LastIndex(full, "."); i >= 0 {
+			namespace = full[:i]
+		}
+		node := &schemaNode{kind: kind, name: full}
+		if kind == "error" {
+			node.kind = "record"
+		}
+		p.named[full] = node
+
+		switch node.kind {
+		case "record":
+			fields, _ := m["fields"].([]any)
+			for _, f := range fields {
+				fm, ok := f.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("%w: invalid field in %s", ErrInvalidSchema, full)
+				}
+				fname, _ := fm["name"].(string)
+				if fname == "" {
+					return nil, fmt.Errorf("%w: unnamed field in %s", ErrInvalidSchema, full)
+				}
+				fnode, err := p.parse(fm["type"], namespace)
+				if err != nil {
+					return nil, err
+				}
+				node.fields = append(node.fields, schemaField{name: fname, node: fnode})
+			}
+		case "enum":
+			symbols, _ := m["symbols"].([]any)
+			for _, s := range symbols {
+				sym, ok := s.(string)
+				if !ok {
+					return nil, fmt.Errorf("%w: invalid symbol in %s", ErrInvalidSchema, full)
+				}
+				node.symbols = append(node.symbols, sym)
+			}
+		case "fixed":
+			size, ok := m["size"].(float64)
+			if !ok || size < 0 {
+				return nil, fmt.Errorf("%w: invalid size for %s", ErrInvalidSchema, full)
+			}
+			node.size = int(size)
+		}
+		return node, nil
+	case "array":
+		items, err := p.parse(m["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schemaNode{kind: "array", items: items}, nil
+	case "map":
+		values, err := p.parse(m["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schemaNode{kind: "map", values: values}, nil
+	default:
+		// Primitive with attributes (e.g., logical types) or a named reference.
+		return p.parse(kind, namespace)
+	}
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// appendLong appends a zig-zag varint encoded long.
+func appendLong(buf []byte, v int64) []byte {
+	return binary.AppendVarint(buf, v)
+}
+
+// appendBytes appends a length-prefixed byte slice.
+func appendBytes(buf, b []byte) []byte {
+	buf = appendLong(buf, int64(len(b)))
+	return append(buf, b...)
+}
+
+func appendValue(buf []byte, n *schemaNode, v any) ([]byte, error) {
+	switch n.kind {
+	case "null":
+		if v != nil {
+			return nil, fmt.Errorf("%w: expected null, got %T", ErrInvalidDatum, v)
+		}
+		return buf, nil
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected boolean, got %T", ErrInvalidDatum, v)
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case "int", "long":
+		i, ok := toInt64(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected %s, got %T", ErrInvalidDatum, n.kind, v)
+		}
+		if n.kind == "int" && (i < math.MinInt32 || i > math.MaxInt32) {
+			return nil, fmt.Errorf("%w: %d overflows int", ErrInvalidDatum, i)
+		}
+		return appendLong(buf, i), nil
+	case "float":
+		f, ok := toFloat64(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected float, got %T", ErrInvalidDatum, v)
+		}
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(f))), nil
+	case "double":
+		f, ok := toFloat64(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected double, got %T", ErrInvalidDatum, v)
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	case "bytes":
+		switch b := v.(type) {
+		case []byte:
+			return appendBytes(buf, b), nil
+		case string:
+			return appendBytes(buf, []byte(b)), nil
+		}
+		return nil, fmt.Errorf("%w: expected bytes, got %T", ErrInvalidDatum, v)
+	case "string":
+		switch s := v.(type) {
+		case string:
+			return appendBytes(buf, []byte(s)), nil
+		case []byte:
+			return appendBytes(buf, s), nil
+		}
+		return nil, fmt.Errorf("%w: expected string, got %T", ErrInvalidDatum, v)
+	case "fixed":
+		b, ok := v.([]byte)
+		if !ok || len(b) != n.size {
+			return nil, fmt.Errorf("%w: expected fixed(%d) for %s", ErrInvalidDatum, n.size, n.name)
+		}
+		return append(buf, b...), nil
+	case "enum":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected enum symbol, got %T", ErrInvalidDatum, v)
+		}
+		for i, sym := range n.symbols {
+			if sym == s {
+				return appendLong(buf, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("%w: unknown symbol %q for %s", ErrInvalidDatum, s, n.name)
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected []any, got %T", ErrInvalidDatum, v)
+		}
+		if len(items) > 0 {
+			buf = appendLong(buf, int64(len(items)))
+			for _, item := range items {
+				var err error
+				if buf, err = appendValue(buf, n.items, item); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(buf, 0), nil
+	case "map":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected map[string]any, got %T", ErrInvalidDatum, v)
+		}
+		if len(m) > 0 {
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			buf = appendLong(buf, int64(len(keys)))
+			for _, k := range keys {
+				buf = appendBytes(buf, []byte(k))
+				var err error
+				if buf, err = appendValue(buf, n.values, m[k]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(buf, 0), nil
+	case "record":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected map[string]any for %s, got %T", ErrInvalidDatum, n.name, v)
+		}
+		for _, f := range n.fields {
+			var err error
+			if buf, err = appendValue(buf, f.node, m[f.name]); err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+		}
+		return buf, nil
+	case "union":
+		for i, branch := range n.union {
+			if !matchesBranch(branch, v) {
+				continue
+			}
+			out, err := appendValue(appendLong(buf, int64(i)), branch, v)
+			if err == nil {
+				return out, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: no union branch matches %T", ErrInvalidDatum, v)
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %s", ErrInvalidDatum, n.kind)
+	}
+}
+
+// matchesBranch reports whether v plausibly belongs to a union branch.
+func matchesBranch(n *schemaNode, v any) bool {
+	switch n.kind {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "int", "long":
+		_, ok := toInt64(v)
+		return ok
+	case "float", "double":
+		_, ok := toFloat64(v)
+		return ok
+	case "bytes", "fixed":
+		_, ok := v.([]byte)
+		return ok
+	case "string", "enum":
+		_, ok := v.(string)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "map", "record":
+		_, ok := v.(map[string]any)
+		return ok
+	default:
+		return false
+	}
+}
+
+func toInt64(v any) (int64, bool) {
+	switch i := v.(type) {
+	case int:
+		return int64(i), true
+	case int8:
+		return int64(i), true
+	case int16:
+		return int64(i), true
+	case int32:
+		return int64(i), true
+	case int64:
+		return i, true
+	case uint8:
+		return int64(i), true
+	case uint16:
+		return int64(i), true
+	case uint32:
+		return int64(i), true
+	default:
+		return 0, false
+	}
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), true
+	case float64:
+		return f, true
+	default:
+		if i, ok := toInt64(v); ok {
+			return float64(i), true
+		}
+		return 0, false
+	}
+}
+
+// decoder decodes Avro binary data from a byte slice.
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) long() (int64, error) {
+	v, n := binary.Varint(d.buf[d.pos:])
+	if n <= 0 {
+		if n == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 0, fmt.Errorf("%w: varint overflow", ErrInvalidDatum)
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.buf)-d.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.long()
+	if err != nil {
+		return nil, err
+	}
+	return d.next(int(n))
+}
+
+// blockCount reads an array/map block count, handling the negative-count form
+// where the count is followed by the block size in bytes.
+func (d *decoder) blockCount() (int64, error) {
+	count, err := d.long()
+	if err != nil {
+		return 0, err
+	}
+	if count < 0 {
+		count = -count
+		if _, err := d.long(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func (d *decoder) value(n *schemaNode) (any, error) {
+	switch n.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int":
+		v, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		return int32(v), nil //nolint:gosec // Avro int values are 32-bit by definition
+	case "long":
+		return d.long()
+	case "float":
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		b, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case "string":
+		b, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case "fixed":
+		b, err := d.next(n.size)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case "enum":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(n.symbols)) {
+			return nil, fmt.Errorf("%w: enum index %d out of range for %s", ErrInvalidDatum, i, n.name)
+		}
+		return n.symbols[i], nil
+	case "array":
+		items := []any{}
+		for {
+			count, err := d.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return items, nil
+			}
+			for ; count > 0; count-- {
+				item, err := d.value(n.items)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+		}
+	case "map":
+		m := map[string]any{}
+		for {
+			count, err := d.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return m, nil
+			}
+			for ; count > 0; count-- {
+				k, err := d.bytes()
+				if err != nil {
+					return nil, err
+				}
+				v, err := d.value(n.values)
+				if err != nil {
+					return nil, err
+				}
+				m[string(k)] = v
+			}
+		}
+	case "record":
+		m := make(map[string]any, len(n.fields))
+		for _, f := range n.fields {
+			v, err := d.value(f.node)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			m[f.name] = v
+		}
+		return m, nil
+	case "union":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(n.union)) {
+			return nil, fmt.Errorf("%w: union index %d out of range", ErrInvalidDatum, i)
+		}
+		return d.value(n.union[i])
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %s", ErrInvalidDatum, n.kind)
+	}
+}
@@ -0,0 +1,310 @@
+// Package avro provides Avro Object Container File (OCF) format support for omnistorage.
+//
+// An OCF file embeds its writer schema in the header and stores records in
+// optionally compressed blocks separated by a sync marker. The Writer and
+// Reader types follow the same record-oriented ergonomics as the ndjson
+// package: each record is a single Avro binary datum.
+//
+// Basic usage:
+//
+//	schema := avro.MustParseSchema(`{"type":"record","name":"Event","fields":[
+//	    {"name":"id","type":"long"},{"name":"msg","type":"string"}]}`)
+//
+//	raw, _ := backend.NewWriter(ctx, "events.avro")
+//	w, _ := avro.NewWriter(raw, schema, avro.WithCodec(avro.CodecDeflate))
+//	w.WriteValue(map[string]any{"id": int64(1), "msg": "hello"})
+//	w.Close()
+package avro
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+
+	"github.com/grokify/omnistorage"
+)
+
+const (
+	// DefaultBlockLength is the default maximum number of records per block.
+	DefaultBlockLength = 1000
+
+	// DefaultBlockSize is the default maximum uncompressed block size in bytes.
+	// A block is flushed when either limit is reached.
+	DefaultBlockSize = 64 * 1024 // 64KB
+
+	// syncSize is the length of the OCF sync marker.
+	syncSize = 16
+
+	// Metadata keys reserved by the Avro specification.
+	metaSchema = "avro.schema"
+	metaCodec  = "avro.codec"
+)
+
+// magic is the four-byte header that starts every OCF file.
+var magic = []byte{'O', 'b', 'j', 1}
+
+// Codec identifies the block compression codec of an OCF file.
+type Codec string
+
+const (
+	// CodecNull stores blocks uncompressed.
+	CodecNull Codec = "null"
+
+	// CodecDeflate compresses blocks with raw DEFLATE (RFC 1951).
+	CodecDeflate Codec = "deflate"
+
+	// CodecSnappy compresses blocks with Snappy, followed by a CRC32 checksum.
+	CodecSnappy Codec = "snappy"
+)
+
+// WriterOption configures a Writer.
+type WriterOption func(*writerConfig)
+
+type writerConfig struct {
+	codec       Codec
+	level       int
+	blockLength int
+	blockSize   int
+	metadata    map[string][]byte
+}
+
+// WithCodec sets the block compression codec. Default: CodecNull.
+func WithCodec(codec Codec) WriterOption {
+	return func(c *writerConfig) {
+		c.codec = codec
+	}
+}
+
+// WithCompressionLevel sets the DEFLATE compression level (flate.BestSpeed
+// through flate.BestCompression). Only used with CodecDeflate.
+func WithCompressionLevel(level int) WriterOption {
+	return func(c *writerConfig) {
+		c.level = level
+	}
+}
+
+// WithBlockLength sets the maximum number of records per block.
+func WithBlockLength(n int) WriterOption {
+	return func(c *writerConfig) {
+		c.blockLength = n
+	}
+}
+
+// WithBlockSize sets the maximum uncompressed block size in bytes.
+func WithBlockSize(size int) WriterOption {
+	return func(c *writerConfig) {
+		c.blockSize = size
+	}
+}
+
+// WithMetadata adds a user metadata entry to the file header.
+// Keys starting with "avro." are reserved and are ignored.
+func WithMetadata(key string, value []byte) WriterOption {
+	return func(c *writerConfig) {
+		if c.metadata == nil {
+			c.metadata = make(map[string][]byte)
+		}
+		c.metadata[key] = value
+	}
+}
+
+// Writer implements omnistorage.RecordWriter for Avro Object Container Files.
+// Each record passed to Write must be a single Avro binary datum encoded
+// with the writer's schema; use WriteValue to encode Go values directly.
+type Writer struct {
+	w      io.Writer
+	closer io.Closer
+	schema *Schema
+	config writerConfig
+	sync   [syncSize]byte
+	block  bytes.Buffer
+	count  int64
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewWriter creates a new OCF writer that writes to the given io.WriteCloser.
+// The file header, including the schema, is written immediately.
+// The writer will be closed when the OCF writer is closed.
+func NewWriter(w io.WriteCloser, schema *Schema, opts ...WriterOption) (*Writer, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("%w: schema is required", ErrInvalidSchema)
+	}
+
+	config := writerConfig{
+		codec:       CodecNull,
+		level:       flate.DefaultCompression,
+		blockLength: DefaultBlockLength,
+		blockSize:   DefaultBlockSize,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	switch config.codec {
+	case CodecNull, CodecDeflate, CodecSnappy:
+	default:
+		return nil, fmt.Errorf("avro: unsupported codec %q", config.codec)
+	}
+
+	aw := &Writer{
+		w:      w,
+		closer: w,
+		schema: schema,
+		config: config,
+	}
+	if _, err := rand.Read(aw.sync[:]); err != nil {
+		return nil, err
+	}
+	if err := aw.writeHeader(); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// writeHeader writes the magic bytes, file metadata, and sync marker.
+func (w *Writer) writeHeader() error {
+	meta := map[string][]byte{
+		metaSchema: []byte(w.schema.String()),
+		metaCodec:  []byte(w.config.codec),
+	}
+	for k, v := range w.config.metadata {
+		if len(k) >= 5 && k[:5] == "avro." {
+			continue
+		}
+		meta[k] = v
+	}
+
+	header := append([]byte(nil), magic...)
+	header = appendLong(header, int64(len(meta)))
+	for k, v := range meta {
+		header = appendBytes(header, []byte(k))
+		header = appendBytes(header, v)
+	}
+	header = appendLong(header, 0)
+	header = append(header, w.sync[:]...)
+
+	_, err := w.w.Write(header)
+	return err
+}
+
+// Schema returns the writer's schema.
+func (w *Writer) Schema() *Schema {
+	return w.schema
+}
+
+// Write writes a single record as a pre-encoded Avro binary datum.
+// Records are buffered into blocks; call Flush to write a partial block.
+func (w *Writer) Write(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return omnistorage.ErrWriterClosed
+	}
+
+	w.block.Write(data)
+	w.count++
+
+	if w.count >= int64(w.config.blockLength) || w.block.Len() >= w.config.blockSize {
+		return w.flushBlock()
+	}
+	return nil
+}
+
+// WriteValue encodes v with the writer's schema and writes it as a record.
+func (w *Writer) WriteValue(v any) error {
+	data, err := w.schema.Encode(v)
+	if err != nil {
+		return err
+	}
+	return w.Write(data)
+}
+
+// Flush writes any buffered records to the underlying writer as a block.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return omnistorage.ErrWriterClosed
+	}
+
+	return w.flushBlock()
+}
+
+// flushBlock writes the pending block. Caller must hold mu.
+func (w *Writer) flushBlock() error {
+	if w.count == 0 {
+		return nil
+	}
+
+	data, err := compressBlock(w.config.codec, w.config.level, w.block.Bytes())
+	if err != nil {
+		return err
+	}
+
+	out := appendLong(nil, w.count)
+	out = appendLong(out, int64(len(data)))
+	out = append(out, data...)
+	out = append(out, w.sync[:]...)
+
+	w.block.Reset()
+	w.count = 0
+
+	_, err = w.w.Write(out)
+	return err
+}
+
+// Close flushes any remaining records and closes the writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	if err := w.flushBlock(); err != nil {
+		_ = w.closer.Close()
+		return err
+	}
+
+	return w.closer.Close()
+}
+
+// compressBlock compresses block data with the given codec.
+func compressBlock(codec Codec, level int, data []byte) ([]byte, error) {
+	switch codec {
+	case CodecDeflate:
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, level)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := fw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CodecSnappy:
+		out := snappy.Encode(nil, data)
+		return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(data)), nil
+	default:
+		return append([]byte(nil), data...), nil
+	}
+}
+
+// Ensure Writer implements omnistorage.RecordWriter
+var _ omnistorage.RecordWriter = (*Writer)(nil)
@@ -0,0 +1,258 @@
+package msgpack
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/channel"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// testWriteCloser wraps a bytes.Buffer with a Close method.
+type testWriteCloser struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func newTestWriteCloser() *testWriteCloser {
+	return &testWriteCloser{Buffer: new(bytes.Buffer)}
+}
+
+func (t *testWriteCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+// testReadCloser wraps a bytes.Reader with a Close method.
+type testReadCloser struct {
+	*bytes.Reader
+	closed bool
+}
+
+func newTestReadCloser(data []byte) *testReadCloser {
+	return &testReadCloser{Reader: bytes.NewReader(data)}
+}
+
+func (t *testReadCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+type event struct {
+	ID   int64    `msgpack:"id"`
+	Name string   `msgpack:"name"`
+	Tags []string `msgpack:"tags"`
+}
+
+func TestEncodeDecode(t *testing.T) {
+	buf := newTestWriteCloser()
+	w := NewWriter(buf)
+
+	events := []event{
+		{ID: 1, Name: "alice", Tags: []string{"a"}},
+		{ID: 2, Name: "bob"},
+		{ID: 3, Name: "charlie", Tags: []string{"x", "y"}},
+	}
+	for _, e := range events {
+		if err := w.Encode(e); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !buf.closed {
+		t.Error("Underlying writer should be closed")
+	}
+
+	src := newTestReadCloser(buf.Bytes())
+	r := NewReader(src)
+	for i, want := range events {
+		var got event
+		if err := r.Decode(&got); err != nil {
+			t.Fatalf("Decode %d failed: %v", i, err)
+		}
+		if got.ID != want.ID || got.Name != want.Name || len(got.Tags) != len(want.Tags) {
+			t.Errorf("Decode %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	var extra event
+	if err := r.Decode(&extra); err != io.EOF {
+		t.Errorf("Expected EOF, got: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !src.closed {
+		t.Error("Underlying reader should be closed")
+	}
+}
+
+func TestRawRecords(t *testing.T) {
+	buf := newTestWriteCloser()
+	w := NewWriter(buf)
+
+	values := []any{"hello", int64(42), map[string]any{"k": "v"}, []any{true, nil}}
+	for _, v := range values {
+		data, err := msgpack.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if err := w.Write(data); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	_ = w.Close()
+
+	r := NewReader(newTestReadCloser(buf.Bytes()))
+	defer func() { _ = r.Close() }()
+
+	for i, v := range values {
+		want, _ := msgpack.Marshal(v)
+		got, err := r.Read()
+		if err != nil {
+			t.Fatalf("Read %d failed: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Read %d = %x, want %x", i, got, want)
+		}
+	}
+
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected EOF, got: %v", err)
+	}
+}
+
+func TestCustomStructTag(t *testing.T) {
+	type jsonTagged struct {
+		Value string `json:"value"`
+	}
+
+	buf := newTestWriteCloser()
+	w := NewWriter(buf)
+	w.SetCustomStructTag("json")
+	_ = w.Encode(jsonTagged{Value: "x"})
+	_ = w.Close()
+
+	r := NewReader(newTestReadCloser(buf.Bytes()))
+	var m map[string]any
+	if err := r.Decode(&m); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if m["value"] != "x" {
+		t.Errorf("Decode = %v, want key %q from json tag", m, "value")
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	w := NewWriter(newTestWriteCloser())
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := w.Write([]byte{0xc0}); err != omnistorage.ErrWriterClosed {
+		t.Errorf("Write after Close: error = %v, want %v", err, omnistorage.ErrWriterClosed)
+	}
+	if err := w.Encode(1); err != omnistorage.ErrWriterClosed {
+		t.Errorf("Encode after Close: error = %v, want %v", err, omnistorage.ErrWriterClosed)
+	}
+	if err := w.Flush(); err != omnistorage.ErrWriterClosed {
+		t.Errorf("Flush after Close: error = %v, want %v", err, omnistorage.ErrWriterClosed)
+	}
+}
+
+func TestReaderClosed(t *testing.T) {
+	r := NewReader(newTestReadCloser([]byte{0xc0}))
+	_ = r.Close()
+
+	if _, err := r.Read(); err != omnistorage.ErrReaderClosed {
+		t.Errorf("Read after Close: error = %v, want %v", err, omnistorage.ErrReaderClosed)
+	}
+	var v any
+	if err := r.Decode(&v); err != omnistorage.ErrReaderClosed {
+		t.Errorf("Decode after Close: error = %v, want %v", err, omnistorage.ErrReaderClosed)
+	}
+}
+
+func TestWithMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+
+	raw, _ := backend.NewWriter(ctx, "artifacts/run.msgpack")
+	w := NewWriter(raw)
+	for i := int64(0); i < 50; i++ {
+		if err := w.Encode(event{ID: i}); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	rc, err := backend.NewReader(ctx, "artifacts/run.msgpack")
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	r := NewReader(rc)
+	defer func() { _ = r.Close() }()
+
+	for i := int64(0); i < 50; i++ {
+		var e event
+		if err := r.Decode(&e); err != nil {
+			t.Fatalf("Decode %d failed: %v", i, err)
+		}
+		if e.ID != i {
+			t.Errorf("Decode %d: ID = %d", i, e.ID)
+		}
+	}
+}
+
+func TestWithChannelBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := channel.New()
+	defer func() { _ = backend.Close() }()
+
+	done := make(chan []event, 1)
+	go func() {
+		rc, err := backend.NewReader(ctx, "stream")
+		if err != nil {
+			done <- nil
+			return
+		}
+		r := NewReader(rc)
+		defer func() { _ = r.Close() }()
+
+		var got []event
+		for {
+			var e event
+			if err := r.Decode(&e); err != nil {
+				break
+			}
+			got = append(got, e)
+		}
+		done <- got
+	}()
+
+	raw, err := backend.NewWriter(ctx, "stream")
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	w := NewWriter(raw)
+	for i := int64(0); i < 3; i++ {
+		_ = w.Encode(event{ID: i, Name: "n"})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	got := <-done
+	if len(got) != 3 {
+		t.Fatalf("received %d events over channel backend, want 3", len(got))
+	}
+}
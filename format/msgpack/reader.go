@@ -0,0 +1,96 @@
+package msgpack
+
+import (
+	"bufio"
+	"io"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Reader implements omnistorage.RecordReader for MessagePack streams.
+// Each record is a single MessagePack object.
+type Reader struct {
+	dec    *msgpack.Decoder
+	closer io.Closer
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewReader creates a new MessagePack reader that reads from the given io.ReadCloser.
+// The reader will be closed when the MessagePack reader is closed.
+func NewReader(r io.ReadCloser) *Reader {
+	return NewReaderSize(r, DefaultBufferSize)
+}
+
+// NewReaderSize creates a new MessagePack reader with the specified buffer size.
+func NewReaderSize(r io.ReadCloser, bufferSize int) *Reader {
+	return &Reader{
+		dec:    msgpack.NewDecoder(bufio.NewReaderSize(r, bufferSize)),
+		closer: r,
+	}
+}
+
+// SetCustomStructTag causes Decode to use the given struct tag (e.g., "json")
+// as a fallback when a field has no msgpack tag.
+func (r *Reader) SetCustomStructTag(tag string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dec.SetCustomStructTag(tag)
+}
+
+// UseLooseInterfaceDecoding causes Decode into interface{} values to use
+// int64, uint64, and float64 for all numbers instead of the narrowest type.
+func (r *Reader) UseLooseInterfaceDecoding(on bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dec.UseLooseInterfaceDecoding(on)
+}
+
+// Read reads the next record as a raw encoded MessagePack object.
+// Returns io.EOF when no more records are available.
+func (r *Reader) Read() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, omnistorage.ErrReaderClosed
+	}
+
+	raw, err := r.dec.DecodeRaw()
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Decode reads the next record and unmarshals it into v.
+// Returns io.EOF when no more records are available.
+func (r *Reader) Decode(v any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return omnistorage.ErrReaderClosed
+	}
+
+	return r.dec.Decode(v)
+}
+
+// Close releases any resources held by the reader.
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	return r.closer.Close()
+}
+
+// Ensure Reader implements omnistorage.RecordReader
+var _ omnistorage.RecordReader = (*Reader)(nil)
@@ -0,0 +1,124 @@
+// Package msgpack provides MessagePack stream format support for omnistorage.
+//
+// A MessagePack stream is a concatenation of self-delimiting MessagePack
+// objects, so no additional framing is needed. The Writer and Reader types
+// mirror the ndjson package: Write and Read handle raw encoded records,
+// while Encode and Decode marshal Go values directly.
+//
+// Basic usage:
+//
+//	raw, _ := backend.NewWriter(ctx, "events.msgpack")
+//	w := msgpack.NewWriter(raw)
+//	w.Encode(map[string]any{"msg": "hello"})
+//	w.Close()
+package msgpack
+
+import (
+	"bufio"
+	"io"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/grokify/omnistorage"
+)
+
+const (
+	// DefaultBufferSize is the default buffer size for writers and readers.
+	DefaultBufferSize = 64 * 1024 // 64KB
+)
+
+// Writer implements omnistorage.RecordWriter for MessagePack streams.
+// Each record is a single encoded MessagePack object.
+type Writer struct {
+	w      *bufio.Writer
+	enc    *msgpack.Encoder
+	closer io.Closer
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewWriter creates a new MessagePack writer that writes to the given io.WriteCloser.
+// The writer will be closed when the MessagePack writer is closed.
+func NewWriter(w io.WriteCloser) *Writer {
+	return NewWriterSize(w, DefaultBufferSize)
+}
+
+// NewWriterSize creates a new MessagePack writer with the specified buffer size.
+func NewWriterSize(w io.WriteCloser, bufferSize int) *Writer {
+	bw := bufio.NewWriterSize(w, bufferSize)
+	return &Writer{
+		w:      bw,
+		enc:    msgpack.NewEncoder(bw),
+		closer: w,
+	}
+}
+
+// SetCustomStructTag causes Encode to use the given struct tag (e.g., "json")
+// as a fallback when a field has no msgpack tag.
+func (w *Writer) SetCustomStructTag(tag string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.enc.SetCustomStructTag(tag)
+}
+
+// Write writes a single record consisting of an already-encoded MessagePack object.
+// The data is written as-is; it must contain exactly one complete object.
+func (w *Writer) Write(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return omnistorage.ErrWriterClosed
+	}
+
+	_, err := w.w.Write(data)
+	return err
+}
+
+// Encode marshals v as MessagePack and writes it as a single record.
+func (w *Writer) Encode(v any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return omnistorage.ErrWriterClosed
+	}
+
+	return w.enc.Encode(v)
+}
+
+// Flush flushes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return omnistorage.ErrWriterClosed
+	}
+
+	return w.w.Flush()
+}
+
+// Close flushes any remaining data and closes the writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	// Flush buffered data
+	if err := w.w.Flush(); err != nil {
+		_ = w.closer.Close()
+		return err
+	}
+
+	return w.closer.Close()
+}
+
+// Ensure Writer implements omnistorage.RecordWriter
+var _ omnistorage.RecordWriter = (*Writer)(nil)
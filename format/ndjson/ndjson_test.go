@@ -2,7 +2,9 @@ package ndjson
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/grokify/omnistorage"
@@ -371,3 +373,187 @@ func TestReaderRecordCopy(t *testing.T) {
 
 	_ = r.Close()
 }
+
+type testEvent struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestReadAsWriteAs(t *testing.T) {
+	buf := newTestWriteCloser()
+	w := NewWriter(buf)
+
+	events := []testEvent{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}}
+	for _, e := range events {
+		if err := WriteAs(w, e); err != nil {
+			t.Fatalf("WriteAs failed: %v", err)
+		}
+	}
+	_ = w.Close()
+
+	r := NewReader(newTestReadCloser(buf.Bytes()))
+	first, err := ReadAs[testEvent](r)
+	if err != nil {
+		t.Fatalf("ReadAs failed: %v", err)
+	}
+	if first != events[0] {
+		t.Errorf("ReadAs = %+v, want %+v", first, events[0])
+	}
+
+	rest, err := ReadAllAs[testEvent](r)
+	if err != nil {
+		t.Fatalf("ReadAllAs failed: %v", err)
+	}
+	if len(rest) != 1 || rest[0] != events[1] {
+		t.Errorf("ReadAllAs = %+v, want %+v", rest, events[1:])
+	}
+
+	if _, err := ReadAs[testEvent](r); err != io.EOF {
+		t.Errorf("Expected EOF, got: %v", err)
+	}
+}
+
+func TestReadAsInvalidJSON(t *testing.T) {
+	r := NewReader(newTestReadCloser([]byte("not json\n")))
+	if _, err := ReadAs[testEvent](r); err == nil {
+		t.Error("ReadAs should fail on invalid JSON")
+	}
+}
+
+func TestReaderMaxLineSize(t *testing.T) {
+	data := []byte("{\"a\":1}\n{\"long\":\"" + strings.Repeat("x", 100) + "\"}\n")
+	r := NewReader(newTestReadCloser(data), WithMaxLineSize(32))
+
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("Read 1 failed: %v", err)
+	}
+
+	_, err := r.Read()
+	if !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("Read 2: error = %v, want ErrLineTooLong", err)
+	}
+	var recErr *RecordError
+	if !errors.As(err, &recErr) || recErr.Line != 2 {
+		t.Errorf("Read 2: error = %v, want RecordError at line 2", err)
+	}
+}
+
+func TestReaderMaxLineSizeExceedsBuffer(t *testing.T) {
+	long := "{\"long\":\"" + strings.Repeat("x", 4096) + "\"}"
+	r := NewReaderSize(newTestReadCloser([]byte(long+"\n")), 128, WithMaxLineSize(8192))
+
+	record, err := r.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(record) != long {
+		t.Errorf("Read returned %d bytes, want %d", len(record), len(long))
+	}
+}
+
+func TestReaderMaxLineSizeExact(t *testing.T) {
+	line := strings.Repeat("1", 16)
+	for _, data := range []string{line + "\n", line, line + "\r\n"} {
+		r := NewReader(newTestReadCloser([]byte(data)), WithMaxLineSize(16))
+		if _, err := r.Read(); err != nil {
+			t.Errorf("Read %q failed: %v", data, err)
+		}
+	}
+
+	r := NewReader(newTestReadCloser([]byte(line+"1")), WithMaxLineSize(16))
+	if _, err := r.Read(); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("Read at EOF: error = %v, want ErrLineTooLong", err)
+	}
+}
+
+func TestWriterMaxLineSize(t *testing.T) {
+	buf := newTestWriteCloser()
+	w := NewWriter(buf, WithMaxLineSize(8))
+
+	if err := w.Write([]byte(`{"a":1}`)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Write([]byte(`{"a":1234}`)); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("Write oversized: error = %v, want ErrLineTooLong", err)
+	}
+	_ = w.Close()
+
+	if buf.String() != "{\"a\":1}\n" {
+		t.Errorf("Written content = %q", buf.String())
+	}
+}
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string"}
+	},
+	"required": ["id", "name"]
+}`
+
+func TestSchemaValidatorReader(t *testing.T) {
+	v, err := NewSchemaValidator([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("NewSchemaValidator failed: %v", err)
+	}
+
+	data := []byte(`{"id":1,"name":"ok"}
+
+{"id":0,"name":"bad"}
+`)
+	r := NewReader(newTestReadCloser(data), WithValidator(v))
+
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("Read 1 failed: %v", err)
+	}
+
+	_, err = r.Read()
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("Read 2: error = %v, want ErrInvalidRecord", err)
+	}
+	var recErr *RecordError
+	if !errors.As(err, &recErr) || recErr.Line != 3 {
+		t.Errorf("Read 2: error = %v, want RecordError at line 3", err)
+	}
+}
+
+func TestSchemaValidatorWriter(t *testing.T) {
+	buf := newTestWriteCloser()
+	w := NewWriter(buf, WithValidator(MustSchemaValidator([]byte(testSchema))))
+
+	if err := WriteAs(w, testEvent{ID: 1, Name: "ok"}); err != nil {
+		t.Fatalf("WriteAs valid failed: %v", err)
+	}
+	if err := w.Write([]byte(`{"name":"missing id"}`)); !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("Write invalid: error = %v, want ErrInvalidRecord", err)
+	}
+	if err := w.Write([]byte(`not json`)); !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("Write malformed: error = %v, want ErrInvalidRecord", err)
+	}
+	_ = w.Close()
+
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("Written content = %q, want one record", buf.String())
+	}
+}
+
+func TestNewSchemaValidatorInvalid(t *testing.T) {
+	if _, err := NewSchemaValidator([]byte(`{"type": 5}`)); err == nil {
+		t.Error("NewSchemaValidator should fail on invalid schema")
+	}
+}
+
+func TestValidatorFunc(t *testing.T) {
+	reject := ValidatorFunc(func(record []byte) error {
+		if bytes.Contains(record, []byte("secret")) {
+			return errors.New("contains secret")
+		}
+		return nil
+	})
+
+	r := NewReader(newTestReadCloser([]byte("{\"a\":\"secret\"}\n")), WithValidator(reject))
+	if _, err := r.Read(); !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("Read: error = %v, want ErrInvalidRecord", err)
+	}
+}
@@ -0,0 +1,68 @@
+package ndjson
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrLineTooLong is returned when a line exceeds the configured maximum
+	// line size, either when reading or when writing.
+	ErrLineTooLong = errors.New("ndjson: line too long")
+
+	// ErrInvalidRecord is returned (wrapped in a *RecordError) when a record
+	// fails validation.
+	ErrInvalidRecord = errors.New("ndjson: invalid record")
+)
+
+// RecordError describes a record that could not be read or written.
+// Line is the 1-based line number in the stream.
+type RecordError struct {
+	Line int64
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("ndjson: line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Option configures a Reader or Writer.
+type Option func(*config)
+
+type config struct {
+	maxLineSize int
+	validator   Validator
+}
+
+// WithMaxLineSize sets the maximum length of a single line, excluding the
+// newline delimiter. Readers return ErrLineTooLong for longer lines instead
+// of stopping silently, and writers reject records that readers with the
+// same limit could not read back. Zero or negative means the default for
+// readers (the buffer size) and no limit for writers.
+func WithMaxLineSize(n int) Option {
+	return func(c *config) {
+		c.maxLineSize = n
+	}
+}
+
+// WithValidator validates every record against v. Readers and writers
+// return a *RecordError wrapping ErrInvalidRecord when validation fails.
+func WithValidator(v Validator) Option {
+	return func(c *config) {
+		c.validator = v
+	}
+}
+
+func applyOptions(opts []Option) config {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
@@ -3,6 +3,7 @@ package ndjson
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"sync"
 
@@ -12,32 +13,49 @@ import (
 // Reader implements omnistorage.RecordReader for NDJSON format.
 // Each record is read as a single line (delimited by newlines).
 type Reader struct {
-	scanner *bufio.Scanner
-	closer  io.Closer
-	closed  bool
-	mu      sync.Mutex
+	scanner     *bufio.Scanner
+	closer      io.Closer
+	maxLineSize int
+	validator   Validator
+	line        int64
+	closed      bool
+	mu          sync.Mutex
 }
 
 // NewReader creates a new NDJSON reader that reads from the given io.ReadCloser.
 // The reader will be closed when the NDJSON reader is closed.
-func NewReader(r io.ReadCloser) *Reader {
-	return NewReaderSize(r, DefaultBufferSize)
+func NewReader(r io.ReadCloser, opts ...Option) *Reader {
+	return NewReaderSize(r, DefaultBufferSize, opts...)
 }
 
 // NewReaderSize creates a new NDJSON reader with the specified buffer size.
-// The buffer size determines the maximum line length that can be read.
-func NewReaderSize(r io.ReadCloser, bufferSize int) *Reader {
+// Unless WithMaxLineSize is given, the buffer size also determines the
+// maximum line length that can be read.
+func NewReaderSize(r io.ReadCloser, bufferSize int, opts ...Option) *Reader {
+	cfg := applyOptions(opts)
+
+	maxLineSize := bufferSize
+	if cfg.maxLineSize > 0 {
+		maxLineSize = cfg.maxLineSize
+	}
+
+	// The scanner's limit includes the "\r\n" delimiter; longer lines that
+	// still fit are rejected after scanning.
 	scanner := bufio.NewScanner(r)
-	scanner.Buffer(make([]byte, bufferSize), bufferSize)
+	scanner.Buffer(make([]byte, 0, min(bufferSize, maxLineSize+2)), maxLineSize+2)
 	return &Reader{
-		scanner: scanner,
-		closer:  r,
+		scanner:     scanner,
+		closer:      r,
+		maxLineSize: maxLineSize,
+		validator:   cfg.validator,
 	}
 }
 
 // Read reads the next record (JSON line) from the reader.
 // Returns io.EOF when no more records are available.
 // Empty lines are skipped.
+// A line longer than the maximum line size results in a *RecordError
+// wrapping ErrLineTooLong; the reader cannot continue after such an error.
 // The returned slice is a copy and is valid until the next call to Read.
 func (r *Reader) Read() ([]byte, error) {
 	r.mu.Lock()
@@ -48,11 +66,20 @@ func (r *Reader) Read() ([]byte, error) {
 	}
 
 	for r.scanner.Scan() {
+		r.line++
 		line := r.scanner.Bytes()
 		// Skip empty lines
 		if len(bytes.TrimSpace(line)) == 0 {
 			continue
 		}
+		if len(line) > r.maxLineSize {
+			return nil, r.tooLong(r.line)
+		}
+		if r.validator != nil {
+			if err := r.validator.Validate(line); err != nil {
+				return nil, &RecordError{Line: r.line, Err: fmt.Errorf("%w: %w", ErrInvalidRecord, err)}
+			}
+		}
 		// Return a copy since scanner reuses the buffer
 		result := make([]byte, len(line))
 		copy(result, line)
@@ -60,12 +87,29 @@ func (r *Reader) Read() ([]byte, error) {
 	}
 
 	if err := r.scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return nil, r.tooLong(r.line + 1)
+		}
 		return nil, err
 	}
 
 	return nil, io.EOF
 }
 
+// Line returns the number of lines consumed so far, including empty lines.
+func (r *Reader) Line() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.line
+}
+
+func (r *Reader) tooLong(line int64) error {
+	return &RecordError{
+		Line: line,
+		Err:  fmt.Errorf("%w: exceeds %d bytes", ErrLineTooLong, r.maxLineSize),
+	}
+}
+
 // Close releases any resources held by the reader.
 func (r *Reader) Close() error {
 	r.mu.Lock()
@@ -0,0 +1,66 @@
+package ndjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Validator validates a single NDJSON record.
+type Validator interface {
+	Validate(record []byte) error
+}
+
+// ValidatorFunc adapts an ordinary function to the Validator interface.
+type ValidatorFunc func(record []byte) error
+
+// Validate calls f(record).
+func (f ValidatorFunc) Validate(record []byte) error {
+	return f(record)
+}
+
+// SchemaValidator validates records against a JSON Schema.
+// Drafts 4, 6, 7, 2019-09 and 2020-12 are supported; the draft is taken
+// from the schema's "$schema" keyword and defaults to 2020-12.
+type SchemaValidator struct {
+	schema *jsonschema.Schema
+}
+
+// NewSchemaValidator compiles the given JSON Schema document.
+func NewSchemaValidator(schema []byte) (*SchemaValidator, error) {
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("schema.json", bytes.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("ndjson: invalid schema: %w", err)
+	}
+	s, err := c.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("ndjson: invalid schema: %w", err)
+	}
+	return &SchemaValidator{schema: s}, nil
+}
+
+// MustSchemaValidator is like NewSchemaValidator but panics on error.
+func MustSchemaValidator(schema []byte) *SchemaValidator {
+	v, err := NewSchemaValidator(schema)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Validate decodes the record as JSON and validates it against the schema.
+func (v *SchemaValidator) Validate(record []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	return v.schema.Validate(doc)
+}
+
+// Ensure SchemaValidator implements Validator
+var _ Validator = (*SchemaValidator)(nil)
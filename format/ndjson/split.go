@@ -0,0 +1,179 @@
+package ndjson
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/grokify/omnistorage"
+)
+
+// SplitPath returns the path of the i-th (0-based) part written by Split.
+func SplitPath(dstPrefix string, i int) string {
+	return fmt.Sprintf("%s%05d.ndjson", dstPrefix, i)
+}
+
+// Split streams the NDJSON object at srcPath into parts of at most
+// linesPerFile records each, written to SplitPath(dstPrefix, 0),
+// SplitPath(dstPrefix, 1), and so on. Records are copied one at a time,
+// so memory use is bounded by the longest line rather than the object size.
+// Empty lines are dropped. Options apply to both the reader and the writers.
+//
+// Split returns the paths written, in order. An empty source produces no parts.
+func Split(ctx context.Context, backend omnistorage.Backend, srcPath, dstPrefix string, linesPerFile int, opts ...Option) ([]string, error) {
+	if linesPerFile <= 0 {
+		return nil, fmt.Errorf("ndjson: linesPerFile must be positive, got %d", linesPerFile)
+	}
+
+	rc, err := backend.NewReader(ctx, srcPath)
+	if err != nil {
+		return nil, err
+	}
+	r := NewReader(rc, opts...)
+	defer func() { _ = r.Close() }()
+
+	var (
+		paths []string
+		w     *Writer
+		count int
+	)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			if w != nil {
+				_ = w.Close()
+			}
+			return paths, err
+		}
+
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if w != nil {
+				_ = w.Close()
+			}
+			return paths, err
+		}
+
+		if w == nil {
+			path := SplitPath(dstPrefix, len(paths))
+			wc, err := backend.NewWriter(ctx, path, omnistorage.WithContentType(ContentType))
+			if err != nil {
+				return paths, err
+			}
+			w = NewWriter(wc, opts...)
+			paths = append(paths, path)
+		}
+
+		if err := w.Write(record); err != nil {
+			_ = w.Close()
+			return paths, err
+		}
+
+		count++
+		if count == linesPerFile {
+			if err := w.Close(); err != nil {
+				return paths, err
+			}
+			w = nil
+			count = 0
+		}
+	}
+
+	if w != nil {
+		if err := w.Close(); err != nil {
+			return paths, err
+		}
+	}
+
+	return paths, nil
+}
+
+// Merge concatenates the records of the NDJSON objects at srcPaths, in order,
+// into a single object at dstPath. Each record is copied as a whole line, so
+// record boundaries are preserved even when a source lacks a trailing newline.
+// Empty lines are dropped.
+//
+// Merge returns the number of records written.
+func Merge(ctx context.Context, backend omnistorage.Backend, srcPaths []string, dstPath string, opts ...Option) (int64, error) {
+	wc, err := backend.NewWriter(ctx, dstPath, omnistorage.WithContentType(ContentType))
+	if err != nil {
+		return 0, err
+	}
+	w := NewWriter(wc, opts...)
+
+	var n int64
+	for _, src := range srcPaths {
+		copied, err := mergeOne(ctx, backend, src, w, opts)
+		n += copied
+		if err != nil {
+			_ = w.Close()
+			return n, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// MergePrefix merges all objects whose paths begin with srcPrefix, in
+// lexical path order, into dstPath. It is the counterpart of Split:
+// MergePrefix(ctx, b, p, dst) reassembles the parts written by
+// Split(ctx, b, src, p, n). Only the directory of srcPrefix, "parts" for
+// "parts/part-", is listed.
+func MergePrefix(ctx context.Context, backend omnistorage.Backend, srcPrefix, dstPath string, opts ...Option) (int64, error) {
+	dir := srcPrefix[:strings.LastIndex(srcPrefix, "/")+1]
+	paths, err := backend.List(ctx, dir)
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(paths)
+
+	// Never read the destination while writing it.
+	namePrefix := omnistorage.ListedPath(dir, srcPrefix[len(dir):])
+	srcPaths := paths[:0]
+	for _, p := range paths {
+		p = omnistorage.ListedPath(dir, p)
+		if strings.HasPrefix(p, namePrefix) && p != dstPath {
+			srcPaths = append(srcPaths, p)
+		}
+	}
+
+	return Merge(ctx, backend, srcPaths, dstPath, opts...)
+}
+
+func mergeOne(ctx context.Context, backend omnistorage.Backend, src string, w *Writer, opts []Option) (int64, error) {
+	rc, err := backend.NewReader(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	r := NewReader(rc, opts...)
+	defer func() { _ = r.Close() }()
+
+	var n int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", src, err)
+		}
+
+		if err := w.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
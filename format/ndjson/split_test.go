@@ -0,0 +1,171 @@
+package ndjson
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func writeLines(t *testing.T, backend *memory.Backend, path string, n int) {
+	t.Helper()
+	wc, err := backend.NewWriter(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	w := NewWriter(wc)
+	for i := 0; i < n; i++ {
+		if err := w.Write([]byte(fmt.Sprintf(`{"n":%d}`, i))); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func countLines(t *testing.T, backend *memory.Backend, path string) int {
+	t.Helper()
+	rc, err := backend.NewReader(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewReader(%s) failed: %v", path, err)
+	}
+	r := NewReader(rc)
+	defer func() { _ = r.Close() }()
+
+	n := 0
+	for {
+		if _, err := r.Read(); err == io.EOF {
+			return n
+		} else if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		n++
+	}
+}
+
+func TestSplitMerge(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeLines(t, backend, "src.ndjson", 25)
+
+	paths, err := Split(ctx, backend, "src.ndjson", "parts/part-", 10)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	want := []string{"parts/part-00000.ndjson", "parts/part-00001.ndjson", "parts/part-00002.ndjson"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("Split paths = %v, want %v", paths, want)
+	}
+	for i, p := range paths {
+		wantLines := 10
+		if i == 2 {
+			wantLines = 5
+		}
+		if got := countLines(t, backend, p); got != wantLines {
+			t.Errorf("%s has %d lines, want %d", p, got, wantLines)
+		}
+	}
+
+	n, err := MergePrefix(ctx, backend, "parts/", "merged.ndjson")
+	if err != nil {
+		t.Fatalf("MergePrefix failed: %v", err)
+	}
+	if n != 25 {
+		t.Errorf("MergePrefix wrote %d records, want 25", n)
+	}
+
+	src, _ := backend.NewReader(ctx, "src.ndjson")
+	srcData, _ := io.ReadAll(src)
+	merged, _ := backend.NewReader(ctx, "merged.ndjson")
+	mergedData, _ := io.ReadAll(merged)
+	if string(srcData) != string(mergedData) {
+		t.Error("Merged content differs from source")
+	}
+}
+
+func TestSplitExactMultiple(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeLines(t, backend, "src.ndjson", 20)
+
+	paths, err := Split(ctx, backend, "src.ndjson", "out/", 10)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("Split produced %d parts, want 2", len(paths))
+	}
+}
+
+func TestSplitEmpty(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeLines(t, backend, "empty.ndjson", 0)
+
+	paths, err := Split(ctx, backend, "empty.ndjson", "out/", 10)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("Split produced %d parts, want 0", len(paths))
+	}
+}
+
+func TestSplitInvalidLinesPerFile(t *testing.T) {
+	if _, err := Split(context.Background(), memory.New(), "src", "out/", 0); err == nil {
+		t.Error("Split should fail with linesPerFile=0")
+	}
+}
+
+func TestMergeNoTrailingNewline(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+
+	for path, content := range map[string]string{
+		"a.ndjson": `{"a":1}`,
+		"b.ndjson": "\n{\"b\":2}\n",
+	} {
+		w, _ := backend.NewWriter(ctx, path)
+		_, _ = w.Write([]byte(content))
+		_ = w.Close()
+	}
+
+	n, err := Merge(ctx, backend, []string{"a.ndjson", "b.ndjson"}, "out.ndjson")
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Merge wrote %d records, want 2", n)
+	}
+
+	r, _ := backend.NewReader(ctx, "out.ndjson")
+	data, _ := io.ReadAll(r)
+	if string(data) != "{\"a\":1}\n{\"b\":2}\n" {
+		t.Errorf("Merged content = %q", string(data))
+	}
+}
+
+func TestMergeMissingSource(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+
+	if _, err := Merge(ctx, backend, []string{"missing.ndjson"}, "out.ndjson"); err == nil {
+		t.Error("Merge should fail on missing source")
+	}
+}
+
+func TestSplitCanceled(t *testing.T) {
+	backend := memory.New()
+	writeLines(t, backend, "src.ndjson", 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Split(ctx, backend, "src.ndjson", "out/", 2); err == nil {
+		t.Error("Split should fail with canceled context")
+	}
+}
@@ -0,0 +1,45 @@
+package ndjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ReadAs reads the next record and unmarshals it into a value of type T.
+// Returns io.EOF when no more records are available.
+func ReadAs[T any](r *Reader) (T, error) {
+	var v T
+	data, err := r.Read()
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("ndjson: decode record: %w", err)
+	}
+	return v, nil
+}
+
+// ReadAllAs reads all remaining records, unmarshaling each into a value of type T.
+func ReadAllAs[T any](r *Reader) ([]T, error) {
+	var out []T
+	for {
+		v, err := ReadAs[T](r)
+		if err != nil {
+			if err == io.EOF {
+				return out, nil
+			}
+			return out, err
+		}
+		out = append(out, v)
+	}
+}
+
+// WriteAs marshals v as JSON and writes it as a single record.
+func WriteAs[T any](w *Writer, v T) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("ndjson: encode record: %w", err)
+	}
+	return w.Write(data)
+}
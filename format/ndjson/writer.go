@@ -4,6 +4,7 @@ package ndjson
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"sync"
 
@@ -13,36 +14,47 @@ import (
 const (
 	// DefaultBufferSize is the default buffer size for writers.
 	DefaultBufferSize = 64 * 1024 // 64KB
+
+	// ContentType is the MIME type used for NDJSON objects.
+	ContentType = "application/x-ndjson"
 )
 
 // Writer implements omnistorage.RecordWriter for NDJSON format.
 // Each record is written as a single line followed by a newline character.
 type Writer struct {
-	w       *bufio.Writer
-	closer  io.Closer
-	closed  bool
-	mu      sync.Mutex
-	newline []byte
+	w           *bufio.Writer
+	closer      io.Closer
+	maxLineSize int
+	validator   Validator
+	line        int64
+	closed      bool
+	mu          sync.Mutex
+	newline     []byte
 }
 
 // NewWriter creates a new NDJSON writer that writes to the given io.WriteCloser.
 // The writer will be closed when the NDJSON writer is closed.
-func NewWriter(w io.WriteCloser) *Writer {
-	return NewWriterSize(w, DefaultBufferSize)
+func NewWriter(w io.WriteCloser, opts ...Option) *Writer {
+	return NewWriterSize(w, DefaultBufferSize, opts...)
 }
 
 // NewWriterSize creates a new NDJSON writer with the specified buffer size.
-func NewWriterSize(w io.WriteCloser, bufferSize int) *Writer {
+func NewWriterSize(w io.WriteCloser, bufferSize int, opts ...Option) *Writer {
+	cfg := applyOptions(opts)
 	return &Writer{
-		w:       bufio.NewWriterSize(w, bufferSize),
-		closer:  w,
-		newline: []byte{'\n'},
+		w:           bufio.NewWriterSize(w, bufferSize),
+		closer:      w,
+		maxLineSize: cfg.maxLineSize,
+		validator:   cfg.validator,
+		newline:     []byte{'\n'},
 	}
 }
 
 // Write writes a single record (JSON line) to the writer.
 // The record should not contain embedded newlines; if it does, they will be
 // preserved but may cause issues when reading.
+// If a maximum line size or validator is configured, records that fail
+// either check are rejected with a *RecordError and nothing is written.
 func (w *Writer) Write(data []byte) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -51,6 +63,18 @@ func (w *Writer) Write(data []byte) error {
 		return omnistorage.ErrWriterClosed
 	}
 
+	if w.maxLineSize > 0 && len(data) > w.maxLineSize {
+		return &RecordError{
+			Line: w.line + 1,
+			Err:  fmt.Errorf("%w: %d bytes exceeds %d", ErrLineTooLong, len(data), w.maxLineSize),
+		}
+	}
+	if w.validator != nil {
+		if err := w.validator.Validate(data); err != nil {
+			return &RecordError{Line: w.line + 1, Err: fmt.Errorf("%w: %w", ErrInvalidRecord, err)}
+		}
+	}
+
 	// Write the data
 	if _, err := w.w.Write(data); err != nil {
 		return err
@@ -61,6 +85,7 @@ func (w *Writer) Write(data []byte) error {
 		return err
 	}
 
+	w.line++
 	return nil
 }
 
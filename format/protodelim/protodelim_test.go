@@ -0,0 +1,166 @@
+package protodelim
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	stdprotodelim "google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/grokify/omnistorage"
+)
+
+// testWriteCloser wraps a bytes.Buffer with a Close method.
+type testWriteCloser struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func newTestWriteCloser() *testWriteCloser {
+	return &testWriteCloser{Buffer: new(bytes.Buffer)}
+}
+
+func (t *testWriteCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+// testReadCloser wraps a bytes.Reader with a Close method.
+type testReadCloser struct {
+	*bytes.Reader
+	closed bool
+}
+
+func newTestReadCloser(data []byte) *testReadCloser {
+	return &testReadCloser{Reader: bytes.NewReader(data)}
+}
+
+func (t *testReadCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	buf := newTestWriteCloser()
+	w := NewWriter(buf)
+
+	values := []string{"alpha", "", "gamma with a longer payload"}
+	for _, v := range values {
+		if err := w.WriteMessage(wrapperspb.String(v)); err != nil {
+			t.Fatalf("WriteMessage failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !buf.closed {
+		t.Error("Underlying writer should be closed")
+	}
+
+	src := newTestReadCloser(buf.Bytes())
+	r := NewReader(src)
+	for i, want := range values {
+		var msg wrapperspb.StringValue
+		if err := r.ReadMessage(&msg); err != nil {
+			t.Fatalf("ReadMessage %d failed: %v", i, err)
+		}
+		if msg.GetValue() != want {
+			t.Errorf("ReadMessage %d = %q, want %q", i, msg.GetValue(), want)
+		}
+	}
+
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected EOF, got: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !src.closed {
+		t.Error("Underlying reader should be closed")
+	}
+}
+
+func TestInteropWithProtodelim(t *testing.T) {
+	// Stream written by the standard protodelim package
+	var std bytes.Buffer
+	for _, v := range []int64{1, 300, -5} {
+		if _, err := stdprotodelim.MarshalTo(&std, wrapperspb.Int64(v)); err != nil {
+			t.Fatalf("MarshalTo failed: %v", err)
+		}
+	}
+
+	// Our writer must produce identical bytes
+	buf := newTestWriteCloser()
+	w := NewWriter(buf)
+	for _, v := range []int64{1, 300, -5} {
+		_ = w.WriteMessage(wrapperspb.Int64(v))
+	}
+	_ = w.Close()
+
+	if !bytes.Equal(buf.Bytes(), std.Bytes()) {
+		t.Errorf("Writer output differs from protodelim.MarshalTo")
+	}
+
+	// And the standard reader must consume our stream
+	br := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	for _, want := range []int64{1, 300, -5} {
+		var msg wrapperspb.Int64Value
+		if err := stdprotodelim.UnmarshalFrom(br, &msg); err != nil {
+			t.Fatalf("UnmarshalFrom failed: %v", err)
+		}
+		if msg.GetValue() != want {
+			t.Errorf("UnmarshalFrom = %d, want %d", msg.GetValue(), want)
+		}
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	buf := newTestWriteCloser()
+	w := NewWriter(buf)
+	_ = w.Write([]byte("0123456789"))
+	_ = w.Close()
+
+	data := buf.Bytes()
+	r := NewReader(newTestReadCloser(data[:len(data)-3]))
+	if _, err := r.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Read truncated: error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReaderMaxMessageSize(t *testing.T) {
+	buf := newTestWriteCloser()
+	w := NewWriter(buf)
+	_ = w.Write(make([]byte, 100))
+	_ = w.Close()
+
+	r := NewReaderSize(newTestReadCloser(buf.Bytes()), 10)
+	if _, err := r.Read(); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Read oversized: error = %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	w := NewWriter(newTestWriteCloser())
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := w.Write([]byte("x")); err != omnistorage.ErrWriterClosed {
+		t.Errorf("Write after Close: error = %v, want %v", err, omnistorage.ErrWriterClosed)
+	}
+	if err := w.Flush(); err != omnistorage.ErrWriterClosed {
+		t.Errorf("Flush after Close: error = %v, want %v", err, omnistorage.ErrWriterClosed)
+	}
+}
+
+func TestReaderClosed(t *testing.T) {
+	r := NewReader(newTestReadCloser(nil))
+	_ = r.Close()
+
+	if _, err := r.Read(); err != omnistorage.ErrReaderClosed {
+		t.Errorf("Read after Close: error = %v, want %v", err, omnistorage.ErrReaderClosed)
+	}
+}
@@ -0,0 +1,111 @@
+package protodelim
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/grokify/omnistorage"
+)
+
+const (
+	// DefaultMaxMessageSize is the default maximum size of a single message.
+	// Larger length prefixes are rejected to avoid unbounded allocations
+	// on corrupt input.
+	DefaultMaxMessageSize = 64 * 1024 * 1024 // 64MB
+)
+
+// ErrMessageTooLarge is returned when a length prefix exceeds the reader's
+// maximum message size.
+var ErrMessageTooLarge = errors.New("protodelim: message too large")
+
+// Reader implements omnistorage.RecordReader for length-delimited protobuf streams.
+type Reader struct {
+	r       *bufio.Reader
+	closer  io.Closer
+	maxSize int
+	buf     []byte
+	closed  bool
+	mu      sync.Mutex
+}
+
+// NewReader creates a new delimited reader that reads from the given io.ReadCloser.
+// The reader will be closed when the delimited reader is closed.
+func NewReader(r io.ReadCloser) *Reader {
+	return NewReaderSize(r, DefaultMaxMessageSize)
+}
+
+// NewReaderSize creates a new delimited reader with the specified maximum message size.
+func NewReaderSize(r io.ReadCloser, maxMessageSize int) *Reader {
+	return &Reader{
+		r:       bufio.NewReaderSize(r, DefaultBufferSize),
+		closer:  r,
+		maxSize: maxMessageSize,
+	}
+}
+
+// Read reads the next record (serialized message bytes) from the reader.
+// Returns io.EOF when no more records are available, and io.ErrUnexpectedEOF
+// if the stream ends in the middle of a record.
+// The returned slice is valid until the next call to Read.
+func (r *Reader) Read() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, omnistorage.ErrReaderClosed
+	}
+
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(r.maxSize) {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrMessageTooLarge, size, r.maxSize)
+	}
+
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return r.buf, nil
+}
+
+// ReadMessage reads the next record and unmarshals it into m.
+// Returns io.EOF when no more records are available.
+func (r *Reader) ReadMessage(m proto.Message) error {
+	data, err := r.Read()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// Close releases any resources held by the reader.
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	return r.closer.Close()
+}
+
+// Ensure Reader implements omnistorage.RecordReader
+var _ omnistorage.RecordReader = (*Reader)(nil)
@@ -0,0 +1,121 @@
+// Package protodelim provides length-delimited protobuf format support for omnistorage.
+//
+// Each record is a protobuf message prefixed by its length encoded as an
+// unsigned varint. This is the framing produced by Java's writeDelimitedTo,
+// C++'s SerializeDelimitedToOstream, and Go's encoding/protodelim, so streams
+// written here can be consumed by those tools and vice versa.
+//
+// Basic usage:
+//
+//	raw, _ := backend.NewWriter(ctx, "events.binpb")
+//	w := protodelim.NewWriter(raw)
+//	w.WriteMessage(event)
+//	w.Close()
+package protodelim
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/grokify/omnistorage"
+)
+
+const (
+	// DefaultBufferSize is the default buffer size for writers.
+	DefaultBufferSize = 64 * 1024 // 64KB
+)
+
+// Writer implements omnistorage.RecordWriter for length-delimited protobuf streams.
+// Each record is written as a varint length prefix followed by the message bytes.
+type Writer struct {
+	w      *bufio.Writer
+	closer io.Closer
+	closed bool
+	mu     sync.Mutex
+	prefix [binary.MaxVarintLen64]byte
+}
+
+// NewWriter creates a new delimited writer that writes to the given io.WriteCloser.
+// The writer will be closed when the delimited writer is closed.
+func NewWriter(w io.WriteCloser) *Writer {
+	return NewWriterSize(w, DefaultBufferSize)
+}
+
+// NewWriterSize creates a new delimited writer with the specified buffer size.
+func NewWriterSize(w io.WriteCloser, bufferSize int) *Writer {
+	return &Writer{
+		w:      bufio.NewWriterSize(w, bufferSize),
+		closer: w,
+	}
+}
+
+// Write writes a single record consisting of already-serialized message bytes.
+func (w *Writer) Write(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return omnistorage.ErrWriterClosed
+	}
+
+	// Write the length prefix
+	n := binary.PutUvarint(w.prefix[:], uint64(len(data)))
+	if _, err := w.w.Write(w.prefix[:n]); err != nil {
+		return err
+	}
+
+	// Write the message
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WriteMessage marshals a protobuf message and writes it as a single record.
+func (w *Writer) WriteMessage(m proto.Message) error {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return w.Write(data)
+}
+
+// Flush flushes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return omnistorage.ErrWriterClosed
+	}
+
+	return w.w.Flush()
+}
+
+// Close flushes any remaining data and closes the writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	// Flush buffered data
+	if err := w.w.Flush(); err != nil {
+		_ = w.closer.Close()
+		return err
+	}
+
+	return w.closer.Close()
+}
+
+// Ensure Writer implements omnistorage.RecordWriter
+var _ omnistorage.RecordWriter = (*Writer)(nil)
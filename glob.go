@@ -0,0 +1,216 @@
+package omnistorage
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+)
+
+// globMeta are the characters that make a path a glob pattern.
+const globMeta = `*?[{\`
+
+// HasGlob reports whether s contains glob metacharacters, so it is a
+// pattern for Glob rather than a path.
+func HasGlob(s string) bool {
+	return strings.ContainsAny(s, globMeta)
+}
+
+// GlobBase returns the directory of pattern before its first segment with
+// glob metacharacters: "logs" for "logs/2024-*/**/*.json.gz", or "" if
+// the first segment has them. Paths matching pattern are under it.
+func GlobBase(pattern string) string {
+	segs := strings.Split(strings.Trim(pattern, "/"), "/")
+	var base []string
+	for _, seg := range segs[:len(segs)-1] {
+		if HasGlob(seg) {
+			break
+		}
+		base = append(base, seg)
+	}
+	return strings.Join(base, "/")
+}
+
+// MatchGlob reports whether p matches the glob pattern. A pattern is a
+// path whose segments use path.Match syntax (*, ?, [...], and \ to
+// escape), where a segment of ** matches any number of segments,
+// including none, and {a,b} matches either alternative. Leading and
+// trailing slashes are ignored. The only possible error is
+// path.ErrBadPattern.
+func MatchGlob(pattern, p string) (bool, error) {
+	globs, err := compileGlob(pattern)
+	if err != nil {
+		return false, err
+	}
+	return globs.match(p), nil
+}
+
+// Glob returns the paths of the files in backend matching pattern (see
+// MatchGlob), sorted, such as "logs/2024-*/**/*.json.gz". It lists only
+// the directory of the pattern's literal prefix, "logs" here, once for
+// each {a,b} alternative with a different one, so the fewer paths the
+// directory holds, the cheaper the call. A pattern without
+// metacharacters returns the path if it exists.
+func Glob(ctx context.Context, backend Backend, pattern string) ([]string, error) {
+	globs, err := compileGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []string
+	for _, g := range globs {
+		if !slices.Contains(prefixes, g.prefix) {
+			prefixes = append(prefixes, g.prefix)
+		}
+	}
+
+	literal := !HasGlob(pattern)
+	var matches []string
+	for _, prefix := range prefixes {
+		if literal {
+			ok, err := backend.Exists(ctx, prefix)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matches = append(matches, prefix)
+			}
+			continue
+		}
+		paths, err := backend.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			if p = ListedPath(prefix, p); globs.match(p) {
+				matches = append(matches, p)
+			}
+		}
+	}
+	slices.Sort(matches)
+	return slices.Compact(matches), nil
+}
+
+// glob is a compiled pattern without {a,b} alternatives.
+type glob struct {
+	segs   []string
+	prefix string // the path, or the directory before the first metacharacter
+}
+
+// globs are the alternatives of a pattern.
+type globs []glob
+
+// compileGlob expands the alternatives of pattern and checks their
+// syntax.
+func compileGlob(pattern string) (globs, error) {
+	expanded, err := expandBraces(strings.Trim(pattern, "/"))
+	if err != nil {
+		return nil, err
+	}
+	gs := make(globs, 0, len(expanded))
+	for _, pat := range expanded {
+		g := glob{segs: strings.Split(pat, "/")}
+		for _, seg := range g.segs {
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, err
+			}
+		}
+		if i := strings.IndexAny(pat, globMeta); i >= 0 {
+			g.prefix = pat[:strings.LastIndex(pat[:i], "/")+1]
+		} else {
+			g.prefix = pat
+		}
+		gs = append(gs, g)
+	}
+	return gs, nil
+}
+
+// match reports whether p matches any of the alternatives.
+func (gs globs) match(p string) bool {
+	segs := strings.Split(strings.Trim(p, "/"), "/")
+	for _, g := range gs {
+		if matchSegments(g.segs, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where **
+// matches any number of path segments.
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := range len(segs) + 1 {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// expandBraces returns the patterns of the {a,b} alternatives in pattern,
+// which may be nested.
+func expandBraces(pattern string) ([]string, error) {
+	start := -1
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' {
+			i++
+			continue
+		}
+		if pattern[i] == '{' {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return []string{pattern}, nil
+	}
+
+	// Split the alternatives at top-level commas up to the matching brace
+	var alts []string
+	depth, from := 0, start+1
+	end := -1
+	for i := start + 1; i < len(pattern) && end < 0; i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				alts = append(alts, pattern[from:i])
+				end = i
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, pattern[from:i])
+				from = i + 1
+			}
+		}
+	}
+	if end < 0 {
+		return nil, path.ErrBadPattern
+	}
+
+	var out []string
+	for _, alt := range alts {
+		expanded, err := expandBraces(pattern[:start] + alt + pattern[end+1:])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
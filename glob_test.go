@@ -0,0 +1,120 @@
+package omnistorage_test
+
+import (
+	"context"
+	"errors"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.json", "a.json", true},
+		{"*.json", "dir/a.json", false},
+		{"**/*.json", "a.json", true},
+		{"**/*.json", "dir/sub/a.json", true},
+		{"logs/2024-*/**/*.json.gz", "logs/2024-01/a.json.gz", true},
+		{"logs/2024-*/**/*.json.gz", "logs/2024-01/x/y/a.json.gz", true},
+		{"logs/2024-*/**/*.json.gz", "logs/2023-01/a.json.gz", false},
+		{"logs/2024-*/**/*.json.gz", "logs/2024-01/a.json", false},
+		{"logs/**", "logs/a/b", true},
+		{"logs/**", "logs2/a", false},
+		{"data/{raw,clean}/*.csv", "data/clean/a.csv", true},
+		{"data/{raw,clean}/*.csv", "data/tmp/a.csv", false},
+		{"img.{png,jp{e,}g}", "img.jpg", true},
+		{"img.{png,jp{e,}g}", "img.jpeg", true},
+		{"img.{png,jp{e,}g}", "img.gif", false},
+		{"/a/?.txt/", "a/b.txt", true},
+		{`a/\*.txt`, "a/*.txt", true},
+		{`a/\*.txt`, "a/b.txt", false},
+	}
+	for _, tc := range tests {
+		got, err := omnistorage.MatchGlob(tc.pattern, tc.path)
+		if err != nil || got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, %v, want %v", tc.pattern, tc.path, got, err, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"a/[b", "a/{b,c"} {
+		if _, err := omnistorage.MatchGlob(bad, "a/b"); !errors.Is(err, path.ErrBadPattern) {
+			t.Errorf("MatchGlob(%q) error = %v, want ErrBadPattern", bad, err)
+		}
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"logs/2024-*/**/*.json.gz": "logs",
+		"logs/app/*.log":           "logs/app",
+		"*.json":                   "",
+		"/data/{a,b}/x":            "data",
+		"data/file.txt":            "data",
+	} {
+		if got := omnistorage.GlobBase(pattern); got != want {
+			t.Errorf("GlobBase(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+// listCounter counts the paths List returns.
+type listCounter struct {
+	*memory.Backend
+	listed int
+}
+
+func (b *listCounter) List(ctx context.Context, prefix string) ([]string, error) {
+	paths, err := b.Backend.List(ctx, prefix)
+	b.listed += len(paths)
+	return paths, err
+}
+
+func TestGlob(t *testing.T) {
+	ctx := context.Background()
+	b := &listCounter{Backend: memory.New()}
+	for _, p := range []string{
+		"logs/2024-01/a.json.gz",
+		"logs/2024-01/x/b.json.gz",
+		"logs/2024-02/c.json",
+		"logs/2023-12/d.json.gz",
+		"other/e.json.gz",
+	} {
+		w, err := b.NewWriter(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = w.Close()
+	}
+
+	got, err := omnistorage.Glob(ctx, b, "logs/2024-*/**/*.json.gz")
+	if err != nil {
+		t.Fatalf("Glob error = %v", err)
+	}
+	if want := []string{"logs/2024-01/a.json.gz", "logs/2024-01/x/b.json.gz"}; !slices.Equal(got, want) {
+		t.Errorf("Glob = %v, want %v", got, want)
+	}
+	// Only the paths in logs, the directory of the literal prefix, are
+	// listed.
+	if b.listed != 4 {
+		t.Errorf("listed %d paths, want 4", b.listed)
+	}
+
+	got, _ = omnistorage.Glob(ctx, b, "{other,logs/2023-12}/*.json.gz")
+	if want := []string{"logs/2023-12/d.json.gz", "other/e.json.gz"}; !slices.Equal(got, want) {
+		t.Errorf("Glob with alternatives = %v, want %v", got, want)
+	}
+
+	got, _ = omnistorage.Glob(ctx, b, "other/e.json.gz")
+	if !slices.Equal(got, []string{"other/e.json.gz"}) {
+		t.Errorf("Glob of a path = %v", got)
+	}
+	if got, _ = omnistorage.Glob(ctx, b, "missing/*.txt"); len(got) != 0 {
+		t.Errorf("Glob with no matches = %v", got)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/sha1" //nolint:gosec // SHA1 used for content verification, not security
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"hash"
 	"hash/crc32"
 	"io"
+	"strings"
 )
 
 // HashType represents a hash algorithm used for content verification.
@@ -135,3 +137,60 @@ func (hs HashSet) Equal(other HashSet) bool {
 
 	return foundCommon
 }
+
+// ChecksumVerifier hashes data as it is written and checks it against the
+// checksums set with WithContentMD5 and WithContentSHA256. Backends use it
+// to implement those options. A nil ChecksumVerifier accepts any data.
+type ChecksumVerifier struct {
+	want   HashSet
+	hashes map[HashType]hash.Hash
+}
+
+// NewChecksumVerifier returns a verifier for the checksums in c, or nil if
+// c has none.
+func NewChecksumVerifier(c *WriterConfig) *ChecksumVerifier {
+	want := HashSet{}
+	if c.ContentMD5 != "" {
+		want[HashMD5] = c.ContentMD5
+	}
+	if c.ContentSHA256 != "" {
+		want[HashSHA256] = c.ContentSHA256
+	}
+	if len(want) == 0 {
+		return nil
+	}
+	v := &ChecksumVerifier{want: want, hashes: make(map[HashType]hash.Hash, len(want))}
+	for t := range want {
+		v.hashes[t] = NewHash(t)
+	}
+	return v
+}
+
+// Write adds p to the hashes. It never fails.
+func (v *ChecksumVerifier) Write(p []byte) (int, error) {
+	if v != nil {
+		for _, h := range v.hashes {
+			h.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// Verify returns an error wrapping ErrChecksumMismatch if the data written
+// does not match every expected checksum.
+func (v *ChecksumVerifier) Verify() error {
+	if v == nil {
+		return nil
+	}
+	for _, t := range SupportedHashes() {
+		h, ok := v.hashes[t]
+		if !ok {
+			continue
+		}
+		got := hex.EncodeToString(h.Sum(nil))
+		if !strings.EqualFold(got, v.want[t]) {
+			return fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, t, got, v.want[t])
+		}
+	}
+	return nil
+}
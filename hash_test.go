@@ -2,6 +2,8 @@ package omnistorage
 
 import (
 	"bytes"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -167,3 +169,44 @@ func TestSupportedHashes(t *testing.T) {
 		}
 	}
 }
+
+func TestChecksumVerifier(t *testing.T) {
+	data := []byte("hello world")
+	md5sum := HashBytes(data, HashMD5)
+	sha := HashBytes(data, HashSHA256)
+
+	if v := NewChecksumVerifier(ApplyWriterOptions()); v != nil {
+		t.Errorf("NewChecksumVerifier without checksums = %v, want nil", v)
+	}
+	var v *ChecksumVerifier
+	_, _ = v.Write(data)
+	if err := v.Verify(); err != nil {
+		t.Errorf("nil Verify() = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts []WriterOption
+		ok   bool
+	}{
+		{"md5", []WriterOption{WithContentMD5(md5sum)}, true},
+		{"sha256 upper case", []WriterOption{WithContentSHA256(strings.ToUpper(sha))}, true},
+		{"both", []WriterOption{WithContentMD5(md5sum), WithContentSHA256(sha)}, true},
+		{"wrong md5", []WriterOption{WithContentMD5(HashBytes([]byte("x"), HashMD5))}, false},
+		{"one wrong", []WriterOption{WithContentMD5(md5sum), WithContentSHA256(md5sum)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewChecksumVerifier(ApplyWriterOptions(tt.opts...))
+			_, _ = v.Write(data[:5])
+			_, _ = v.Write(data[5:])
+			err := v.Verify()
+			if tt.ok && err != nil {
+				t.Errorf("Verify() = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("Verify() = %v, want ErrChecksumMismatch", err)
+			}
+		})
+	}
+}
@@ -51,9 +51,16 @@ type Backend interface {
 	// Returns nil if the path does not exist (idempotent).
 	Delete(ctx context.Context, path string) error
 
-	// List lists paths with the given prefix.
+	// List lists the objects in the directory prefix and its
+	// subdirectories, with paths relative to prefix.
 	// Returns an empty slice if no paths match.
-	// The returned paths are relative to the backend root.
+	//
+	// The prefix names a directory and is cleaned with CleanPrefix, so
+	// "logs" and "/logs/" both list "logs/a.txt" as "a.txt" and
+	// "logs/sub/b.txt" as "sub/b.txt", but not "logs2.txt". The returned
+	// paths use forward slashes and have no leading slash; ListedPath
+	// turns them back into paths relative to the backend root.
+	// Directories are not listed.
 	List(ctx context.Context, prefix string) ([]string, error)
 
 	// Close releases any resources held by the backend.
@@ -0,0 +1,29 @@
+package omnistorage
+
+import (
+	"path"
+	"strings"
+)
+
+// CleanPrefix cleans a List prefix, which names a directory, to the form
+// backends match object paths against: without a leading slash and with a
+// trailing one, or "" for the root. "logs", "/logs/" and "x/../logs" all
+// become "logs/".
+func CleanPrefix(prefix string) string {
+	p := strings.TrimPrefix(path.Clean("/"+prefix), "/")
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
+
+// ListedPath returns the path, relative to the backend root, of p, a path
+// returned by List(ctx, prefix):
+//
+//	paths, _ := backend.List(ctx, "logs")
+//	for _, p := range paths {
+//	    r, _ := backend.NewReader(ctx, omnistorage.ListedPath("logs", p)) // logs/a.txt for a.txt
+//	}
+func ListedPath(prefix, p string) string {
+	return CleanPrefix(prefix) + p
+}
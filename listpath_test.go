@@ -0,0 +1,32 @@
+package omnistorage
+
+import "testing"
+
+func TestCleanPrefix(t *testing.T) {
+	for prefix, want := range map[string]string{
+		"":           "",
+		"/":          "",
+		".":          "",
+		"logs":       "logs/",
+		"/logs/":     "logs/",
+		"logs//sub/": "logs/sub/",
+		"./logs/a":   "logs/a/",
+		"../logs":    "logs/",
+	} {
+		if got := CleanPrefix(prefix); got != want {
+			t.Errorf("CleanPrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+func TestListedPath(t *testing.T) {
+	for _, tt := range []struct{ prefix, p, want string }{
+		{"", "logs/a.txt", "logs/a.txt"},
+		{"logs", "a.txt", "logs/a.txt"},
+		{"/logs/", "sub/b.txt", "logs/sub/b.txt"},
+	} {
+		if got := ListedPath(tt.prefix, tt.p); got != tt.want {
+			t.Errorf("ListedPath(%q, %q) = %q, want %q", tt.prefix, tt.p, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package omnistorage
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// RetentionMode is the mode of an object's retention period.
+type RetentionMode string
+
+const (
+	// RetentionGovernance protects an object until its retention date,
+	// except from users with permission to bypass governance retention,
+	// who can also shorten or remove it.
+	RetentionGovernance RetentionMode = "GOVERNANCE"
+
+	// RetentionCompliance protects an object until its retention date
+	// from everyone. The retention can be extended but not shortened.
+	RetentionCompliance RetentionMode = "COMPLIANCE"
+)
+
+// ObjectLock is the lock state of an object.
+type ObjectLock struct {
+	// Mode is the retention mode, or empty if the object has no
+	// retention period.
+	Mode RetentionMode
+
+	// RetainUntil is the end of the retention period.
+	RetainUntil time.Time
+
+	// LegalHold is true if the object is under a legal hold, which
+	// protects it until the hold is removed, regardless of retention.
+	LegalHold bool
+}
+
+// LockedAt reports whether the object cannot be deleted at time t.
+func (l ObjectLock) LockedAt(t time.Time) bool {
+	return l.LegalHold || (l.Mode != "" && t.Before(l.RetainUntil))
+}
+
+// String describes the lock, such as "COMPLIANCE retention until
+// 2030-01-01T00:00:00Z, legal hold", or "unlocked".
+func (l ObjectLock) String() string {
+	var parts []string
+	if l.Mode != "" {
+		parts = append(parts, string(l.Mode)+" retention until "+l.RetainUntil.UTC().Format(time.RFC3339))
+	}
+	if l.LegalHold {
+		parts = append(parts, "legal hold")
+	}
+	if len(parts) == 0 {
+		return "unlocked"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ObjectLocker is implemented by backends that can protect objects from
+// deletion with retention periods and legal holds, such as S3 buckets
+// with Object Lock. Objects can also be locked when written, with
+// WithRetention and WithLegalHold.
+type ObjectLocker interface {
+	// ObjectLock returns the lock state of the object at path. Returns
+	// ErrNotFound if path does not exist.
+	ObjectLock(ctx context.Context, path string) (ObjectLock, error)
+
+	// SetRetention sets the retention period of the object at path. An
+	// empty mode removes a governance retention, where the backend
+	// allows it.
+	SetRetention(ctx context.Context, path string, mode RetentionMode, until time.Time) error
+
+	// SetLegalHold places or removes a legal hold on the object at path.
+	SetLegalHold(ctx context.Context, path string, on bool) error
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Backend wraps a backend, reporting every operation to a Collector.
+// Extended operations return omnistorage.ErrNotSupported if the wrapped
+// backend does not implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend   omnistorage.Backend
+	name      string
+	collector Collector
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// Wrap instruments backend, reporting its operations to collector under
+// name, such as "s3" or "archive".
+func Wrap(backend omnistorage.Backend, name string, collector Collector) *Backend {
+	return &Backend{backend: backend, name: name, collector: collector}
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+func (b *Backend) observe(op string, start time.Time, bytes int64, err error) {
+	b.collector.ObserveOperation(Operation{
+		Backend:  b.name,
+		Op:       op,
+		Bytes:    bytes,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+// NewWriter creates a writer. The write is reported when the writer is
+// closed.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	start := time.Now()
+	w, err := b.backend.NewWriter(ctx, path, opts...)
+	if err != nil {
+		b.observe(OpWrite, start, 0, err)
+		return nil, err
+	}
+	return &writer{w: w, b: b, start: start}, nil
+}
+
+// NewReader creates a reader. The read is reported when the reader is
+// closed.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	start := time.Now()
+	r, err := b.backend.NewReader(ctx, path, opts...)
+	if err != nil {
+		b.observe(OpRead, start, 0, err)
+		return nil, err
+	}
+	return &reader{r: r, b: b, start: start}, nil
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	start := time.Now()
+	ok, err := b.backend.Exists(ctx, path)
+	b.observe(OpExists, start, 0, err)
+	return ok, err
+}
+
+// Delete removes a path.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	start := time.Now()
+	err := b.backend.Delete(ctx, path)
+	b.observe(OpDelete, start, 0, err)
+	return err
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	start := time.Now()
+	paths, err := b.backend.List(ctx, prefix)
+	b.observe(OpList, start, 0, err)
+	return paths, err
+}
+
+// Close closes the wrapped backend. It is not reported.
+func (b *Backend) Close() error {
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	start := time.Now()
+	info, err := ext.Stat(ctx, path)
+	b.observe(OpStat, start, 0, err)
+	return info, err
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	start := time.Now()
+	err := ext.Mkdir(ctx, path)
+	b.observe(OpMkdir, start, 0, err)
+	return err
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	start := time.Now()
+	err := ext.Rmdir(ctx, path)
+	b.observe(OpRmdir, start, 0, err)
+	return err
+}
+
+// Copy copies an object within the wrapped backend.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	start := time.Now()
+	err := ext.Copy(ctx, src, dst)
+	b.observe(OpCopy, start, 0, err)
+	return err
+}
+
+// Move moves an object within the wrapped backend.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	start := time.Now()
+	err := ext.Move(ctx, src, dst)
+	b.observe(OpMove, start, 0, err)
+	return err
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// reader counts bytes read and reports the read once, on Close.
+type reader struct {
+	r     io.ReadCloser
+	b     *Backend
+	start time.Time
+
+	bytes int64
+	err   error // first read error other than io.EOF
+	once  sync.Once
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.bytes += int64(n)
+	if err != nil && r.err == nil && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *reader) Close() error {
+	err := r.r.Close()
+	r.once.Do(func() {
+		r.b.observe(OpRead, r.start, r.bytes, errors.Join(r.err, err))
+	})
+	return err
+}
+
+// writer counts bytes written and reports the write once, on Close.
+type writer struct {
+	w     io.WriteCloser
+	b     *Backend
+	start time.Time
+
+	bytes int64
+	err   error // first write error
+	once  sync.Once
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.bytes += int64(n)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *writer) Close() error {
+	err := w.w.Close()
+	w.once.Do(func() {
+		w.b.observe(OpWrite, w.start, w.bytes, errors.Join(w.err, err))
+	})
+	return err
+}
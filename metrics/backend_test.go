@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+// recorder is a Collector that keeps every event.
+type recorder struct {
+	mu        sync.Mutex
+	ops       []Operation
+	transfers []Transfer
+}
+
+func (r *recorder) ObserveOperation(op Operation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = append(r.ops, op)
+}
+
+func (r *recorder) ObserveTransfer(t Transfer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transfers = append(r.transfers, t)
+}
+
+func (r *recorder) last(t *testing.T) Operation {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ops) == 0 {
+		t.Fatal("no operations recorded")
+	}
+	return r.ops[len(r.ops)-1]
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return Wrap(memory.New(), "memory", &recorder{})
+	})
+}
+
+func TestWrapReadWrite(t *testing.T) {
+	rec := &recorder{}
+	b := Wrap(memory.New(), "mem", rec)
+	ctx := context.Background()
+
+	w, err := b.NewWriter(ctx, "file.txt")
+	if err != nil {
+		t.Fatalf("NewWriter error = %v", err)
+	}
+	_, _ = w.Write([]byte("hello"))
+	_, _ = w.Write([]byte(" world"))
+	if len(rec.ops) != 0 {
+		t.Errorf("write reported before Close: %+v", rec.ops)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	_ = w.Close()
+
+	op := rec.last(t)
+	if op.Backend != "mem" || op.Op != OpWrite || op.Bytes != 11 || op.Err != nil {
+		t.Errorf("write operation = %+v, want mem/write/11 bytes", op)
+	}
+	if len(rec.ops) != 1 {
+		t.Errorf("recorded %d operations after double Close, want 1", len(rec.ops))
+	}
+
+	r, err := b.NewReader(ctx, "file.txt", omnistorage.WithOffset(6))
+	if err != nil {
+		t.Fatalf("NewReader error = %v", err)
+	}
+	_, _ = io.ReadAll(r)
+	_ = r.Close()
+
+	op = rec.last(t)
+	if op.Op != OpRead || op.Bytes != 5 || op.Err != nil {
+		t.Errorf("read operation = %+v, want read/5 bytes", op)
+	}
+}
+
+func TestWrapErrors(t *testing.T) {
+	rec := &recorder{}
+	b := Wrap(memory.New(), "mem", rec)
+	ctx := context.Background()
+
+	if _, err := b.NewReader(ctx, "missing.txt"); err == nil {
+		t.Fatal("NewReader(missing.txt) succeeded")
+	}
+	op := rec.last(t)
+	if op.Op != OpRead || !errors.Is(op.Err, omnistorage.ErrNotFound) {
+		t.Errorf("operation = %+v, want read with ErrNotFound", op)
+	}
+
+	if _, err := b.Stat(ctx, "missing.txt"); err == nil {
+		t.Fatal("Stat(missing.txt) succeeded")
+	}
+	if op := rec.last(t); op.Op != OpStat || !errors.Is(op.Err, omnistorage.ErrNotFound) {
+		t.Errorf("operation = %+v, want stat with ErrNotFound", op)
+	}
+}
+
+func TestWrapOperations(t *testing.T) {
+	rec := &recorder{}
+	b := Wrap(memory.New(), "mem", rec)
+	ctx := context.Background()
+
+	w, _ := b.NewWriter(ctx, "a.txt")
+	_ = w.Close()
+	_, _ = b.Exists(ctx, "a.txt")
+	_, _ = b.List(ctx, "")
+	_ = b.Copy(ctx, "a.txt", "b.txt")
+	_ = b.Move(ctx, "b.txt", "c.txt")
+	_ = b.Delete(ctx, "c.txt")
+	_ = b.Mkdir(ctx, "dir")
+	_ = b.Rmdir(ctx, "dir")
+
+	want := []string{OpWrite, OpExists, OpList, OpCopy, OpMove, OpDelete, OpMkdir, OpRmdir}
+	if len(rec.ops) != len(want) {
+		t.Fatalf("recorded %d operations, want %d", len(rec.ops), len(want))
+	}
+	for i, op := range rec.ops {
+		if op.Op != want[i] {
+			t.Errorf("operation %d = %q, want %q", i, op.Op, want[i])
+		}
+	}
+}
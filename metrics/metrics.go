@@ -0,0 +1,97 @@
+// Package metrics defines instrumentation hooks for omnistorage backends
+// and sync operations.
+//
+// A Collector receives one event per backend operation and one per file
+// transferred by sync. Wrap instruments any backend:
+//
+//	collector, _ := prometheus.NewCollector(prom.DefaultRegisterer)
+//	backend := metrics.Wrap(s3Backend, "s3", collector)
+//
+// and sync reports transfers through Options.Metrics:
+//
+//	result, err := sync.Sync(ctx, src, dst, "", "", sync.Options{
+//	    Metrics: collector,
+//	})
+//
+// This package has no dependencies beyond omnistorage; the Prometheus
+// implementation lives in the metrics/prometheus subpackage.
+package metrics
+
+import (
+	"time"
+)
+
+// Backend operation names.
+const (
+	OpRead   = "read"
+	OpWrite  = "write"
+	OpExists = "exists"
+	OpDelete = "delete"
+	OpList   = "list"
+	OpStat   = "stat"
+	OpMkdir  = "mkdir"
+	OpRmdir  = "rmdir"
+	OpCopy   = "copy"
+	OpMove   = "move"
+)
+
+// Sync transfer operation names.
+const (
+	// TransferCopy is a file copied to a destination where it did not exist.
+	TransferCopy = "copy"
+
+	// TransferUpdate is a file copied over an existing, different file.
+	TransferUpdate = "update"
+
+	// TransferDelete is a file deleted from the destination.
+	TransferDelete = "delete"
+)
+
+// Operation describes a completed backend operation.
+type Operation struct {
+	// Backend is the name given to Wrap.
+	Backend string
+
+	// Op is the operation, one of the Op constants.
+	Op string
+
+	// Bytes is the number of bytes read or written. It is zero for
+	// operations other than OpRead and OpWrite.
+	Bytes int64
+
+	// Duration is how long the operation took. For reads and writes, it
+	// runs from opening the reader or writer until it is closed.
+	Duration time.Duration
+
+	// Err is the error the operation returned, if any.
+	Err error
+}
+
+// Transfer describes a file copied or deleted by sync.
+type Transfer struct {
+	// Op is the transfer, one of the Transfer constants.
+	Op string
+
+	// Path is the file path, relative to the sync root.
+	Path string
+
+	// Bytes is the size of the file copied. It is zero for deletes and
+	// failed copies.
+	Bytes int64
+
+	// Duration is how long the transfer took.
+	Duration time.Duration
+
+	// Err is the error the transfer failed with, if any.
+	Err error
+}
+
+// Collector receives metrics events. Implementations must be safe for
+// concurrent use and should return quickly, since they are called inline.
+type Collector interface {
+	// ObserveOperation records a backend operation.
+	ObserveOperation(Operation)
+
+	// ObserveTransfer records a sync transfer.
+	ObserveTransfer(Transfer)
+}
@@ -0,0 +1,199 @@
+// Package prometheus implements a metrics.Collector that records
+// Prometheus metrics.
+//
+// Usage:
+//
+//	collector, err := prometheus.NewCollector(prom.DefaultRegisterer)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	backend := metrics.Wrap(s3Backend, "s3", collector)
+//	http.Handle("/metrics", promhttp.Handler())
+//
+// The following metrics are recorded, with the default namespace:
+//
+//	omnistorage_operations_total{backend,op,result}
+//	omnistorage_operation_duration_seconds{backend,op}
+//	omnistorage_bytes_total{backend,op}
+//	omnistorage_sync_files_total{op,result}
+//	omnistorage_sync_bytes_total{op}
+//	omnistorage_sync_transfer_duration_seconds{op}
+//
+// The result label is "success", "not_found" or "error". Paths are not
+// used as labels, to keep cardinality bounded.
+package prometheus
+
+import (
+	"errors"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/metrics"
+)
+
+// DefaultNamespace is the default metric name prefix.
+const DefaultNamespace = "omnistorage"
+
+// Result label values.
+const (
+	ResultSuccess  = "success"
+	ResultNotFound = "not_found"
+	ResultError    = "error"
+)
+
+// Option configures a Collector.
+type Option func(*config)
+
+type config struct {
+	namespace   string
+	constLabels prom.Labels
+	buckets     []float64
+}
+
+// WithNamespace sets the metric name prefix. Default: DefaultNamespace.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels adds labels with fixed values to every metric, such as
+// the job or tenant.
+func WithConstLabels(labels prom.Labels) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithBuckets sets the histogram buckets, in seconds, for duration
+// metrics. Default: prometheus.DefBuckets.
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// Collector records metrics events as Prometheus metrics.
+type Collector struct {
+	operations    *prom.CounterVec
+	opDuration    *prom.HistogramVec
+	bytes         *prom.CounterVec
+	syncFiles     *prom.CounterVec
+	syncBytes     *prom.CounterVec
+	syncDurations *prom.HistogramVec
+}
+
+// Ensure Collector implements metrics.Collector and prometheus.Collector.
+var (
+	_ metrics.Collector = (*Collector)(nil)
+	_ prom.Collector    = (*Collector)(nil)
+)
+
+// NewCollector creates a Collector and registers its metrics with reg.
+// If reg is nil, the metrics are not registered; register the Collector
+// itself later.
+func NewCollector(reg prom.Registerer, opts ...Option) (*Collector, error) {
+	cfg := &config{namespace: DefaultNamespace, buckets: prom.DefBuckets}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	c := &Collector{
+		operations: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   cfg.namespace,
+			Name:        "operations_total",
+			Help:        "Backend operations, by backend, operation and result.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"backend", "op", "result"}),
+		opDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace:   cfg.namespace,
+			Name:        "operation_duration_seconds",
+			Help:        "Backend operation latency; reads and writes run until the stream is closed.",
+			ConstLabels: cfg.constLabels,
+			Buckets:     cfg.buckets,
+		}, []string{"backend", "op"}),
+		bytes: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   cfg.namespace,
+			Name:        "bytes_total",
+			Help:        "Bytes read from or written to backends.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"backend", "op"}),
+		syncFiles: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   "sync",
+			Name:        "files_total",
+			Help:        "Files copied, updated or deleted by sync, by result.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"op", "result"}),
+		syncBytes: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   "sync",
+			Name:        "bytes_total",
+			Help:        "Bytes transferred by sync.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"op"}),
+		syncDurations: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   "sync",
+			Name:        "transfer_duration_seconds",
+			Help:        "Time to copy or delete a single file during sync.",
+			ConstLabels: cfg.constLabels,
+			Buckets:     cfg.buckets,
+		}, []string{"op"}),
+	}
+
+	if reg != nil {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// ObserveOperation records a backend operation.
+func (c *Collector) ObserveOperation(op metrics.Operation) {
+	c.operations.WithLabelValues(op.Backend, op.Op, result(op.Err)).Inc()
+	c.opDuration.WithLabelValues(op.Backend, op.Op).Observe(op.Duration.Seconds())
+	if op.Bytes > 0 {
+		c.bytes.WithLabelValues(op.Backend, op.Op).Add(float64(op.Bytes))
+	}
+}
+
+// ObserveTransfer records a sync transfer.
+func (c *Collector) ObserveTransfer(t metrics.Transfer) {
+	c.syncFiles.WithLabelValues(t.Op, result(t.Err)).Inc()
+	c.syncDurations.WithLabelValues(t.Op).Observe(t.Duration.Seconds())
+	if t.Bytes > 0 {
+		c.syncBytes.WithLabelValues(t.Op).Add(float64(t.Bytes))
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
+
+func (c *Collector) collectors() []prom.Collector {
+	return []prom.Collector{c.operations, c.opDuration, c.bytes, c.syncFiles, c.syncBytes, c.syncDurations}
+}
+
+func result(err error) string {
+	switch {
+	case err == nil:
+		return ResultSuccess
+	case errors.Is(err, omnistorage.ErrNotFound):
+		return ResultNotFound
+	default:
+		return ResultError
+	}
+}
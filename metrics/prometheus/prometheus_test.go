@@ -0,0 +1,92 @@
+package prometheus
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/metrics"
+)
+
+func TestCollector(t *testing.T) {
+	reg := prom.NewRegistry()
+	c, err := NewCollector(reg)
+	if err != nil {
+		t.Fatalf("NewCollector error = %v", err)
+	}
+
+	b := metrics.Wrap(memory.New(), "mem", c)
+	ctx := context.Background()
+
+	w, _ := b.NewWriter(ctx, "file.txt")
+	_, _ = w.Write([]byte("hello"))
+	_ = w.Close()
+
+	r, _ := b.NewReader(ctx, "file.txt")
+	_, _ = io.ReadAll(r)
+	_ = r.Close()
+
+	_, _ = b.NewReader(ctx, "missing.txt")
+
+	expected := `
+# HELP omnistorage_bytes_total Bytes read from or written to backends.
+# TYPE omnistorage_bytes_total counter
+omnistorage_bytes_total{backend="mem",op="read"} 5
+omnistorage_bytes_total{backend="mem",op="write"} 5
+# HELP omnistorage_operations_total Backend operations, by backend, operation and result.
+# TYPE omnistorage_operations_total counter
+omnistorage_operations_total{backend="mem",op="read",result="not_found"} 1
+omnistorage_operations_total{backend="mem",op="read",result="success"} 1
+omnistorage_operations_total{backend="mem",op="write",result="success"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"omnistorage_bytes_total", "omnistorage_operations_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(c, "omnistorage_operation_duration_seconds"); n != 2 {
+		t.Errorf("duration series = %d, want 2", n)
+	}
+}
+
+func TestCollectorTransfers(t *testing.T) {
+	reg := prom.NewRegistry()
+	c, err := NewCollector(reg, WithNamespace("app"), WithConstLabels(prom.Labels{"job": "backup"}))
+	if err != nil {
+		t.Fatalf("NewCollector error = %v", err)
+	}
+
+	c.ObserveTransfer(metrics.Transfer{Op: metrics.TransferCopy, Path: "a", Bytes: 100, Duration: time.Second})
+	c.ObserveTransfer(metrics.Transfer{Op: metrics.TransferCopy, Path: "b", Bytes: 50, Duration: time.Second})
+	c.ObserveTransfer(metrics.Transfer{Op: metrics.TransferDelete, Path: "c", Err: omnistorage.ErrPermissionDenied})
+
+	expected := `
+# HELP app_sync_bytes_total Bytes transferred by sync.
+# TYPE app_sync_bytes_total counter
+app_sync_bytes_total{job="backup",op="copy"} 150
+# HELP app_sync_files_total Files copied, updated or deleted by sync, by result.
+# TYPE app_sync_files_total counter
+app_sync_files_total{job="backup",op="copy",result="success"} 2
+app_sync_files_total{job="backup",op="delete",result="error"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"app_sync_bytes_total", "app_sync_files_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewCollectorDuplicate(t *testing.T) {
+	reg := prom.NewRegistry()
+	if _, err := NewCollector(reg); err != nil {
+		t.Fatalf("NewCollector error = %v", err)
+	}
+	if _, err := NewCollector(reg); err == nil {
+		t.Error("second NewCollector on the same registry succeeded, want error")
+	}
+}
@@ -0,0 +1,331 @@
+package mount
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// entry holds the attributes of a file or directory.
+type entry struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+}
+
+// dirent is a directory listing entry.
+type dirent struct {
+	name  string
+	isDir bool
+}
+
+// dirLister is implemented by backends that can list one directory level,
+// such as s3.Backend.
+type dirLister interface {
+	ListDir(ctx context.Context, dir string) ([]omnistorage.ObjectInfo, error)
+}
+
+// sizer reports the current size of a file open for writing.
+type sizer interface {
+	size() int64
+}
+
+// fsys maps filesystem operations onto a backend. Paths are slash-separated
+// and relative to the backend root; the root is "".
+type fsys struct {
+	backend omnistorage.ExtendedBackend
+	opts    Options
+	cache   *cache
+
+	mu      sync.Mutex
+	writing map[string]sizer // files open for writing, not yet uploaded
+}
+
+func newFsys(backend omnistorage.ExtendedBackend, opts Options) *fsys {
+	return &fsys{
+		backend: backend,
+		opts:    opts,
+		cache:   newCache(opts.AttrTimeout),
+		writing: make(map[string]sizer),
+	}
+}
+
+// stat returns the attributes of p. Paths that only exist as a prefix of
+// other objects are reported as directories.
+func (f *fsys) stat(ctx context.Context, p string) (entry, error) {
+	if p == "" {
+		return entry{isDir: true}, nil
+	}
+
+	f.mu.Lock()
+	w, ok := f.writing[p]
+	f.mu.Unlock()
+	if ok {
+		return entry{size: w.size(), modTime: time.Now()}, nil
+	}
+
+	if e, ok := f.cache.attr(p); ok {
+		return e, nil
+	}
+
+	info, err := f.backend.Stat(ctx, p)
+	if err == nil {
+		e := entry{isDir: info.IsDir(), size: info.Size(), modTime: info.ModTime()}
+		f.cache.setAttr(p, e)
+		return e, nil
+	}
+	if !omnistorage.IsNotFound(err) {
+		return entry{}, err
+	}
+
+	// Object stores have no directories, only key prefixes and, at most,
+	// zero-byte markers ending in "/".
+	children, err := f.readDir(ctx, p)
+	if err != nil {
+		return entry{}, err
+	}
+	if len(children) == 0 {
+		if _, err := f.backend.Stat(ctx, p+"/"); err != nil {
+			return entry{}, omnistorage.ErrNotFound
+		}
+	}
+	e := entry{isDir: true}
+	f.cache.setAttr(p, e)
+	return e, nil
+}
+
+// readDir lists the immediate children of dir, sorted by name.
+func (f *fsys) readDir(ctx context.Context, dir string) ([]dirent, error) {
+	if list, ok := f.cache.dir(dir); ok {
+		return f.withWriting(dir, list), nil
+	}
+
+	var list []dirent
+	if lister, ok := f.backend.(dirLister); ok {
+		infos, err := lister.ListDir(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			name := path.Base(info.Path())
+			list = append(list, dirent{name: name, isDir: info.IsDir()})
+			f.cache.setAttr(join(dir, name), entry{isDir: info.IsDir(), size: info.Size(), modTime: info.ModTime()})
+		}
+	} else {
+		paths, err := f.backend.List(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, p := range paths {
+			name, _, isDir := strings.Cut(p, "/")
+			if !seen[name] {
+				seen[name] = true
+				list = append(list, dirent{name: name, isDir: isDir})
+			}
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	f.cache.setDir(dir, list)
+	return f.withWriting(dir, list), nil
+}
+
+// withWriting adds files being written in dir that are not yet on the
+// backend.
+func (f *fsys) withWriting(dir string, list []dirent) []dirent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var extra []dirent
+	for p := range f.writing {
+		if parent(p) != dir {
+			continue
+		}
+		name := path.Base(p)
+		i := sort.Search(len(list), func(i int) bool { return list[i].name >= name })
+		if i == len(list) || list[i].name != name {
+			extra = append(extra, dirent{name: name})
+		}
+	}
+	if len(extra) == 0 {
+		return list
+	}
+	out := append(append([]dirent(nil), list...), extra...)
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func (f *fsys) startWriting(p string, w sizer) {
+	f.mu.Lock()
+	f.writing[p] = w
+	f.mu.Unlock()
+	f.cache.invalidate(p)
+}
+
+func (f *fsys) stopWriting(p string, w sizer) {
+	f.mu.Lock()
+	if f.writing[p] == w {
+		delete(f.writing, p)
+	}
+	f.mu.Unlock()
+	f.cache.invalidate(p)
+}
+
+// rename moves a file, or every file under a directory.
+func (f *fsys) rename(ctx context.Context, src, dst string) error {
+	e, err := f.stat(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer f.cache.clear()
+
+	if !e.isDir {
+		return f.backend.Move(ctx, src, dst)
+	}
+
+	paths, err := f.backend.List(ctx, src)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := f.backend.Move(ctx, join(src, p), join(dst, p)); err != nil {
+			return err
+		}
+	}
+	if err := f.backend.Mkdir(ctx, dst); err != nil && !errors.Is(err, omnistorage.ErrAlreadyExists) {
+		return err
+	}
+	if err := f.backend.Rmdir(ctx, src); err != nil && !omnistorage.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// cache holds attributes and directory listings for a fixed time.
+type cache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	attrs map[string]cachedAttr
+	dirs  map[string]cachedDir
+}
+
+type cachedAttr struct {
+	entry   entry
+	expires time.Time
+}
+
+type cachedDir struct {
+	list    []dirent
+	expires time.Time
+}
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{
+		ttl:   ttl,
+		attrs: make(map[string]cachedAttr),
+		dirs:  make(map[string]cachedDir),
+	}
+}
+
+func (c *cache) attr(p string) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.attrs[p]
+	if !ok || time.Now().After(a.expires) {
+		delete(c.attrs, p)
+		return entry{}, false
+	}
+	return a.entry, true
+}
+
+func (c *cache) setAttr(p string, e entry) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attrs[p] = cachedAttr{entry: e, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *cache) dir(p string) ([]dirent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.dirs[p]
+	if !ok || time.Now().After(d.expires) {
+		delete(c.dirs, p)
+		return nil, false
+	}
+	return d.list, true
+}
+
+func (c *cache) setDir(p string, list []dirent) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirs[p] = cachedDir{list: list, expires: time.Now().Add(c.ttl)}
+}
+
+// invalidate drops p and its parent's listing.
+func (c *cache) invalidate(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.attrs, p)
+	delete(c.dirs, p)
+	delete(c.dirs, parent(p))
+}
+
+func (c *cache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attrs = make(map[string]cachedAttr)
+	c.dirs = make(map[string]cachedDir)
+}
+
+func join(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+func parent(p string) string {
+	if i := strings.LastIndexByte(p, '/'); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// toErrno converts a backend error to an errno.
+func toErrno(err error) syscall.Errno {
+	var errno syscall.Errno
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &errno):
+		return errno
+	case omnistorage.IsNotFound(err), errors.Is(err, os.ErrNotExist):
+		return syscall.ENOENT
+	case omnistorage.IsPermissionDenied(err), errors.Is(err, os.ErrPermission):
+		return syscall.EACCES
+	case errors.Is(err, omnistorage.ErrAlreadyExists), errors.Is(err, os.ErrExist):
+		return syscall.EEXIST
+	case omnistorage.IsNotSupported(err):
+		return syscall.ENOTSUP
+	case errors.Is(err, context.Canceled):
+		return syscall.EINTR
+	default:
+		return syscall.EIO
+	}
+}
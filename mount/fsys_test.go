@@ -0,0 +1,206 @@
+//go:build !windows
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func newTestFsys(t *testing.T, ttl time.Duration, files map[string]string) (*fsys, *memory.Backend) {
+	t.Helper()
+	backend := memory.New()
+	ctx := context.Background()
+	for p, data := range files {
+		w, err := backend.NewWriter(ctx, p)
+		if err != nil {
+			t.Fatalf("NewWriter(%q) error = %v", p, err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatalf("Write(%q) error = %v", p, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close(%q) error = %v", p, err)
+		}
+	}
+	return newFsys(backend, Options{AttrTimeout: ttl}.withDefaults()), backend
+}
+
+func TestStat(t *testing.T) {
+	f, _ := newTestFsys(t, -1, map[string]string{
+		"a.txt":     "hello",
+		"dir/b.txt": "world!",
+	})
+	ctx := context.Background()
+
+	e, err := f.stat(ctx, "")
+	if err != nil || !e.isDir {
+		t.Errorf("stat(root) = %+v, %v; want directory", e, err)
+	}
+
+	e, err = f.stat(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("stat(a.txt) error = %v", err)
+	}
+	if e.isDir || e.size != 5 {
+		t.Errorf("stat(a.txt) = %+v; want 5-byte file", e)
+	}
+
+	e, err = f.stat(ctx, "dir")
+	if err != nil || !e.isDir {
+		t.Errorf("stat(dir) = %+v, %v; want directory", e, err)
+	}
+
+	if _, err := f.stat(ctx, "missing"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("stat(missing) error = %v; want ErrNotFound", err)
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	f, _ := newTestFsys(t, -1, map[string]string{
+		"b.txt":         "b",
+		"a.txt":         "a",
+		"dir/c.txt":     "c",
+		"dir/sub/d.txt": "d",
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		dir  string
+		want []dirent
+	}{
+		{"", []dirent{{name: "a.txt"}, {name: "b.txt"}, {name: "dir", isDir: true}}},
+		{"dir", []dirent{{name: "c.txt"}, {name: "sub", isDir: true}}},
+		{"dir/sub", []dirent{{name: "d.txt"}}},
+	}
+	for _, tt := range tests {
+		got, err := f.readDir(ctx, tt.dir)
+		if err != nil {
+			t.Fatalf("readDir(%q) error = %v", tt.dir, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("readDir(%q) = %v; want %v", tt.dir, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("readDir(%q)[%d] = %v; want %v", tt.dir, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestReadDirIncludesWriting(t *testing.T) {
+	f, _ := newTestFsys(t, time.Minute, map[string]string{"a.txt": "a"})
+	ctx := context.Background()
+
+	if _, err := f.readDir(ctx, ""); err != nil {
+		t.Fatalf("readDir error = %v", err)
+	}
+
+	h, err := newStreamHandle(f, "new.txt")
+	if err != nil {
+		t.Fatalf("newStreamHandle error = %v", err)
+	}
+	if _, errno := h.Write(ctx, []byte("abc"), 0); errno != 0 {
+		t.Fatalf("Write errno = %v", errno)
+	}
+
+	list, err := f.readDir(ctx, "")
+	if err != nil {
+		t.Fatalf("readDir error = %v", err)
+	}
+	if len(list) != 2 || list[1].name != "new.txt" {
+		t.Errorf("readDir = %v; want a.txt and new.txt", list)
+	}
+	if e, err := f.stat(ctx, "new.txt"); err != nil || e.size != 3 {
+		t.Errorf("stat(new.txt) = %+v, %v; want size 3", e, err)
+	}
+
+	if errno := h.Release(ctx); errno != 0 {
+		t.Fatalf("Release errno = %v", errno)
+	}
+	if e, err := f.stat(ctx, "new.txt"); err != nil || e.size != 3 {
+		t.Errorf("stat(new.txt) after release = %+v, %v; want size 3", e, err)
+	}
+}
+
+func TestAttrCache(t *testing.T) {
+	f, backend := newTestFsys(t, time.Minute, map[string]string{"a.txt": "a"})
+	ctx := context.Background()
+
+	if _, err := f.stat(ctx, "a.txt"); err != nil {
+		t.Fatalf("stat error = %v", err)
+	}
+	if err := backend.Delete(ctx, "a.txt"); err != nil {
+		t.Fatalf("Delete error = %v", err)
+	}
+
+	// Changes made behind the mount's back are hidden until the entry expires.
+	if _, err := f.stat(ctx, "a.txt"); err != nil {
+		t.Errorf("stat(cached) error = %v; want cached entry", err)
+	}
+
+	f.cache.invalidate("a.txt")
+	if _, err := f.stat(ctx, "a.txt"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("stat(invalidated) error = %v; want ErrNotFound", err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	f, backend := newTestFsys(t, time.Minute, map[string]string{
+		"a.txt":         "a",
+		"dir/b.txt":     "b",
+		"dir/sub/c.txt": "c",
+	})
+	ctx := context.Background()
+
+	if err := f.rename(ctx, "a.txt", "moved.txt"); err != nil {
+		t.Fatalf("rename(file) error = %v", err)
+	}
+	if err := f.rename(ctx, "dir", "other"); err != nil {
+		t.Fatalf("rename(dir) error = %v", err)
+	}
+
+	for _, p := range []string{"moved.txt", "other/b.txt", "other/sub/c.txt"} {
+		if ok, err := backend.Exists(ctx, p); err != nil || !ok {
+			t.Errorf("Exists(%q) = %v, %v; want true", p, ok, err)
+		}
+	}
+	for _, p := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		if ok, _ := backend.Exists(ctx, p); ok {
+			t.Errorf("Exists(%q) = true; want false", p)
+		}
+	}
+	if _, err := f.stat(ctx, "dir"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("stat(dir) error = %v; want ErrNotFound", err)
+	}
+}
+
+func TestToErrno(t *testing.T) {
+	tests := []struct {
+		err  error
+		want syscall.Errno
+	}{
+		{nil, 0},
+		{omnistorage.ErrNotFound, syscall.ENOENT},
+		{os.ErrNotExist, syscall.ENOENT},
+		{omnistorage.ErrPermissionDenied, syscall.EACCES},
+		{omnistorage.ErrAlreadyExists, syscall.EEXIST},
+		{omnistorage.ErrNotSupported, syscall.ENOTSUP},
+		{context.Canceled, syscall.EINTR},
+		{syscall.ENOTEMPTY, syscall.ENOTEMPTY},
+		{errors.New("boom"), syscall.EIO},
+	}
+	for _, tt := range tests {
+		if got := toErrno(tt.err); got != tt.want {
+			t.Errorf("toErrno(%v) = %v; want %v", tt.err, got, tt.want)
+		}
+	}
+}
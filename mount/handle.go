@@ -0,0 +1,296 @@
+//go:build !windows
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Ensure handles implement the go-fuse file interfaces.
+var (
+	_ fs.FileReader   = (*readHandle)(nil)
+	_ fs.FileReleaser = (*readHandle)(nil)
+
+	_ fs.FileWriter   = (*streamHandle)(nil)
+	_ fs.FileFlusher  = (*streamHandle)(nil)
+	_ fs.FileFsyncer  = (*streamHandle)(nil)
+	_ fs.FileReleaser = (*streamHandle)(nil)
+
+	_ fs.FileReader   = (*writeBackHandle)(nil)
+	_ fs.FileWriter   = (*writeBackHandle)(nil)
+	_ fs.FileFlusher  = (*writeBackHandle)(nil)
+	_ fs.FileFsyncer  = (*writeBackHandle)(nil)
+	_ fs.FileReleaser = (*writeBackHandle)(nil)
+)
+
+// readHandle serves reads with ranged backend reads. Sequential reads reuse
+// one backend reader; a seek opens a new one at the requested offset.
+//
+// Backend readers outlive individual FUSE requests, so they are opened with
+// a background context rather than the request's.
+type readHandle struct {
+	fsys *fsys
+	path string
+	size int64
+
+	mu  sync.Mutex
+	r   io.ReadCloser
+	pos int64
+}
+
+func (h *readHandle) Read(_ context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if off >= h.size {
+		return fuse.ReadResultData(nil), 0
+	}
+	if h.r == nil || off != h.pos {
+		h.closeReader()
+		r, err := h.fsys.backend.NewReader(context.Background(), h.path, omnistorage.WithOffset(off))
+		if err != nil {
+			return nil, toErrno(err)
+		}
+		h.r, h.pos = r, off
+	}
+
+	n, err := io.ReadFull(h.r, dest)
+	h.pos += int64(n)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		h.closeReader()
+		return nil, toErrno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *readHandle) Release(context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closeReader()
+	return 0
+}
+
+func (h *readHandle) closeReader() {
+	if h.r != nil {
+		_ = h.r.Close()
+		h.r = nil
+	}
+}
+
+// streamHandle streams sequential writes straight to a backend writer. The
+// object is committed on the first flush or on release; writes after that,
+// or at any offset other than the current end, fail.
+type streamHandle struct {
+	fsys *fsys
+	path string
+
+	mu  sync.Mutex
+	w   io.WriteCloser // nil once committed
+	pos int64
+	err error
+}
+
+func newStreamHandle(f *fsys, p string) (*streamHandle, error) {
+	w, err := f.backend.NewWriter(context.Background(), p)
+	if err != nil {
+		return nil, err
+	}
+	h := &streamHandle{fsys: f, path: p, w: w}
+	f.startWriting(p, h)
+	return h, nil
+}
+
+func (h *streamHandle) Write(_ context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.w == nil {
+		return 0, syscall.EBADF
+	}
+	if off != h.pos {
+		// Random writes need a local copy of the file; see Options.WriteBack.
+		return 0, syscall.ENOTSUP
+	}
+	n, err := h.w.Write(data)
+	h.pos += int64(n)
+	if err != nil {
+		return uint32(n), toErrno(err) //nolint:gosec // n <= len(data)
+	}
+	return uint32(n), 0 //nolint:gosec // n <= len(data)
+}
+
+func (h *streamHandle) Flush(context.Context) syscall.Errno {
+	return toErrno(h.commit())
+}
+
+func (h *streamHandle) Fsync(context.Context, uint32) syscall.Errno {
+	return toErrno(h.commit())
+}
+
+func (h *streamHandle) Release(context.Context) syscall.Errno {
+	return toErrno(h.commit())
+}
+
+// commit closes the backend writer, uploading the object.
+func (h *streamHandle) commit() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.w == nil {
+		return h.err
+	}
+	h.err = h.w.Close()
+	h.w = nil
+	h.fsys.stopWriting(h.path, h)
+	return h.err
+}
+
+func (h *streamHandle) size() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.pos
+}
+
+// writeBackHandle stages a file in a local temporary file and uploads it
+// when flushed, synced or released, if it changed.
+type writeBackHandle struct {
+	fsys *fsys
+	path string
+
+	mu    sync.Mutex
+	file  *os.File
+	dirty bool
+}
+
+// newWriteBackHandle creates a write-back handle. If load is true, the
+// current contents are downloaded first; otherwise the file starts empty
+// and is uploaded even if nothing is written.
+func newWriteBackHandle(ctx context.Context, f *fsys, p string, load bool) (*writeBackHandle, error) {
+	file, err := os.CreateTemp(f.opts.CacheDir, "omnistorage-mount-*")
+	if err != nil {
+		return nil, err
+	}
+	h := &writeBackHandle{fsys: f, path: p, file: file, dirty: !load}
+
+	if load {
+		if err := h.load(ctx); err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+			return nil, err
+		}
+	}
+	f.startWriting(p, h)
+	return h, nil
+}
+
+func (h *writeBackHandle) load(ctx context.Context) error {
+	r, err := h.fsys.backend.NewReader(ctx, h.path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+	_, err = io.Copy(h.file, r)
+	return err
+}
+
+func (h *writeBackHandle) Read(_ context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, err := h.file.ReadAt(dest, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, toErrno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *writeBackHandle) Write(_ context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, err := h.file.WriteAt(data, off)
+	if n > 0 {
+		h.dirty = true
+	}
+	return uint32(n), toErrno(err) //nolint:gosec // n <= len(data)
+}
+
+func (h *writeBackHandle) Flush(context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return toErrno(h.upload())
+}
+
+func (h *writeBackHandle) Fsync(context.Context, uint32) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return toErrno(h.upload())
+}
+
+func (h *writeBackHandle) Release(context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := h.upload()
+	_ = h.file.Close()
+	_ = os.Remove(h.file.Name())
+	h.fsys.stopWriting(h.path, h)
+	return toErrno(err)
+}
+
+func (h *writeBackHandle) truncate(size int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.file.Truncate(size); err != nil {
+		return err
+	}
+	h.dirty = true
+	return nil
+}
+
+// upload writes the local copy to the backend if it changed.
+func (h *writeBackHandle) upload() error {
+	if !h.dirty {
+		return nil
+	}
+	size, err := h.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	w, err := h.fsys.backend.NewWriter(context.Background(), h.path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, io.NewSectionReader(h.file, 0, size)); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	h.dirty = false
+	h.fsys.cache.invalidate(h.path)
+	return nil
+}
+
+func (h *writeBackHandle) size() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	info, err := h.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
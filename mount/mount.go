@@ -0,0 +1,56 @@
+//go:build !windows
+
+package mount
+
+import (
+	"os"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Server is a mounted filesystem.
+type Server struct {
+	server *fuse.Server
+}
+
+// Mount mounts backend at mountpoint, which must be an existing directory.
+// The filesystem is served in the background until Unmount is called or
+// it is unmounted externally (for example with fusermount -u).
+func Mount(backend omnistorage.ExtendedBackend, mountpoint string, opts Options) (*Server, error) {
+	if backend == nil {
+		return nil, ErrNoBackend
+	}
+	opts = opts.withDefaults()
+
+	timeout := opts.AttrTimeout
+	server, err := fs.Mount(mountpoint, &node{fsys: newFsys(backend, opts)}, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			AllowOther:  opts.AllowOther,
+			FsName:      opts.FSName,
+			Name:        "omnistorage",
+			Debug:       opts.Debug,
+			DirectMount: true,
+		},
+		EntryTimeout: &timeout,
+		AttrTimeout:  &timeout,
+		UID:          uint32(os.Getuid()), //nolint:gosec // uid fits in uint32
+		GID:          uint32(os.Getgid()), //nolint:gosec // gid fits in uint32
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{server: server}, nil
+}
+
+// Unmount unmounts the filesystem. It fails while files are open.
+func (s *Server) Unmount() error {
+	return s.server.Unmount()
+}
+
+// Wait blocks until the filesystem is unmounted.
+func (s *Server) Wait() {
+	s.server.Wait()
+}
@@ -0,0 +1,133 @@
+//go:build !windows
+
+package mount_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/mount"
+)
+
+// mountTest mounts backend in a temporary directory, skipping the test if
+// FUSE is unavailable.
+func mountTest(t *testing.T, backend *memory.Backend, opts mount.Options) string {
+	t.Helper()
+	dir := t.TempDir()
+	server, err := mount.Mount(backend, dir, opts)
+	if err != nil {
+		t.Skipf("FUSE unavailable: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := server.Unmount(); err != nil {
+			t.Errorf("Unmount error = %v", err)
+		}
+	})
+	return dir
+}
+
+func readBackend(t *testing.T, backend *memory.Backend, p string) string {
+	t.Helper()
+	r, err := backend.NewReader(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewReader(%q) error = %v", p, err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll(%q) error = %v", p, err)
+	}
+	return string(data)
+}
+
+func TestMountNilBackend(t *testing.T) {
+	if _, err := mount.Mount(nil, t.TempDir(), mount.Options{}); err != mount.ErrNoBackend {
+		t.Errorf("Mount(nil) error = %v; want ErrNoBackend", err)
+	}
+}
+
+func TestMountStreaming(t *testing.T) {
+	backend := memory.New()
+	dir := mountTest(t, backend, mount.Options{})
+
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0755); err != nil {
+		t.Fatalf("MkdirAll error = %v", err)
+	}
+	name := filepath.Join(dir, "a", "b", "file.txt")
+	if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile error = %v", err)
+	}
+	if got := readBackend(t, backend, "a/b/file.txt"); got != "hello" {
+		t.Errorf("backend content = %q; want %q", got, "hello")
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile = %q, %v; want %q", data, err, "hello")
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "a", "b"))
+	if err != nil || len(entries) != 1 || entries[0].Name() != "file.txt" {
+		t.Errorf("ReadDir = %v, %v; want [file.txt]", entries, err)
+	}
+
+	// Appending needs a local copy.
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err == nil {
+		_ = f.Close()
+		t.Error("OpenFile(O_APPEND) succeeded without write-back")
+	}
+
+	if err := os.Rename(name, filepath.Join(dir, "moved.txt")); err != nil {
+		t.Fatalf("Rename error = %v", err)
+	}
+	if got := readBackend(t, backend, "moved.txt"); got != "hello" {
+		t.Errorf("moved content = %q; want %q", got, "hello")
+	}
+	if err := os.Remove(filepath.Join(dir, "moved.txt")); err != nil {
+		t.Errorf("Remove error = %v", err)
+	}
+}
+
+func TestMountWriteBack(t *testing.T) {
+	backend := memory.New()
+	dir := mountTest(t, backend, mount.Options{WriteBack: true, CacheDir: t.TempDir()})
+
+	name := filepath.Join(dir, "log.txt")
+	if err := os.WriteFile(name, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("WriteFile error = %v", err)
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile(O_APPEND) error = %v", err)
+	}
+	if _, err := f.WriteString("two\n"); err != nil {
+		t.Fatalf("WriteString error = %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	if got := readBackend(t, backend, "log.txt"); got != "one\ntwo\n" {
+		t.Errorf("backend content = %q; want %q", got, "one\ntwo\n")
+	}
+
+	if err := os.Truncate(name, 3); err != nil {
+		t.Fatalf("Truncate error = %v", err)
+	}
+	if got := readBackend(t, backend, "log.txt"); got != "one" {
+		t.Errorf("truncated content = %q; want %q", got, "one")
+	}
+}
+
+func TestMountReadOnly(t *testing.T) {
+	backend := memory.New()
+	dir := mountTest(t, backend, mount.Options{ReadOnly: true})
+
+	if err := os.WriteFile(filepath.Join(dir, "x"), []byte("x"), 0644); err == nil {
+		t.Error("WriteFile succeeded on read-only mount")
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package mount
+
+import (
+	"errors"
+
+	"github.com/grokify/omnistorage"
+)
+
+// ErrUnsupportedPlatform is returned by Mount on platforms without FUSE.
+var ErrUnsupportedPlatform = errors.New("mount: FUSE is not supported on windows")
+
+// Server is a mounted filesystem.
+type Server struct{}
+
+// Mount is not supported on Windows.
+func Mount(omnistorage.ExtendedBackend, string, Options) (*Server, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// Unmount does nothing on Windows.
+func (s *Server) Unmount() error {
+	return nil
+}
+
+// Wait returns immediately on Windows.
+func (s *Server) Wait() {}
@@ -0,0 +1,303 @@
+//go:build !windows
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Rename flags from renameat2(2).
+const (
+	renameNoReplace = 1
+	renameExchange  = 2
+)
+
+// node is a file or directory in the mounted tree. Its backend path is its
+// position in the tree, so nodes carry no path of their own.
+type node struct {
+	fs.Inode
+	fsys *fsys
+}
+
+// Ensure node implements the go-fuse node interfaces.
+var (
+	_ fs.NodeLookuper  = (*node)(nil)
+	_ fs.NodeGetattrer = (*node)(nil)
+	_ fs.NodeSetattrer = (*node)(nil)
+	_ fs.NodeReaddirer = (*node)(nil)
+	_ fs.NodeOpener    = (*node)(nil)
+	_ fs.NodeCreater   = (*node)(nil)
+	_ fs.NodeMkdirer   = (*node)(nil)
+	_ fs.NodeUnlinker  = (*node)(nil)
+	_ fs.NodeRmdirer   = (*node)(nil)
+	_ fs.NodeRenamer   = (*node)(nil)
+	_ fs.NodeStatfser  = (*node)(nil)
+)
+
+func (n *node) path() string {
+	return n.Path(nil)
+}
+
+func (n *node) newChild(ctx context.Context, isDir bool) *fs.Inode {
+	mode := uint32(fuse.S_IFREG)
+	if isDir {
+		mode = fuse.S_IFDIR
+	}
+	return n.NewInode(ctx, &node{fsys: n.fsys}, fs.StableAttr{Mode: mode})
+}
+
+func (n *node) fill(e entry, out *fuse.Attr) {
+	if e.isDir {
+		out.Mode = fuse.S_IFDIR | uint32(n.fsys.opts.DirMode.Perm())
+	} else {
+		out.Mode = fuse.S_IFREG | uint32(n.fsys.opts.FileMode.Perm())
+		out.Size = uint64(e.size) //nolint:gosec // sizes are non-negative
+		out.Blocks = (out.Size + 511) / 512
+	}
+	if !e.modTime.IsZero() {
+		out.SetTimes(nil, &e.modTime, &e.modTime)
+	}
+}
+
+func (n *node) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	e, err := n.fsys.stat(ctx, join(n.path(), name))
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	n.fill(e, &out.Attr)
+	return n.newChild(ctx, e.isDir), 0
+}
+
+func (n *node) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	e, err := n.fsys.stat(ctx, n.path())
+	if err != nil {
+		return toErrno(err)
+	}
+	n.fill(e, &out.Attr)
+	return 0
+}
+
+// Setattr supports truncation. Mode, owner and time changes are accepted
+// and ignored, since backends do not store them.
+func (n *node) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if size, ok := in.GetSize(); ok {
+		if n.fsys.opts.ReadOnly {
+			return syscall.EROFS
+		}
+		if err := n.truncate(ctx, fh, int64(size)); err != nil { //nolint:gosec // size is a file size
+			return toErrno(err)
+		}
+	}
+	return n.Getattr(ctx, fh, out)
+}
+
+func (n *node) truncate(ctx context.Context, fh fs.FileHandle, size int64) error {
+	p := n.path()
+	switch h := fh.(type) {
+	case *writeBackHandle:
+		return h.truncate(size)
+	case *streamHandle:
+		if size != h.size() {
+			return syscall.ENOTSUP
+		}
+		return nil
+	}
+
+	if n.fsys.opts.WriteBack {
+		h, err := newWriteBackHandle(ctx, n.fsys, p, size > 0)
+		if err != nil {
+			return err
+		}
+		err = h.truncate(size)
+		if errno := h.Release(ctx); err == nil && errno != 0 {
+			err = errno
+		}
+		return err
+	}
+
+	e, err := n.fsys.stat(ctx, p)
+	if err != nil {
+		return err
+	}
+	switch {
+	case e.size == size:
+		return nil
+	case size != 0:
+		return syscall.ENOTSUP
+	}
+	w, err := n.fsys.backend.NewWriter(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer n.fsys.cache.invalidate(p)
+	return w.Close()
+}
+
+func (n *node) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	list, err := n.fsys.readDir(ctx, n.path())
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	entries := make([]fuse.DirEntry, len(list))
+	for i, d := range list {
+		mode := uint32(fuse.S_IFREG)
+		if d.isDir {
+			mode = fuse.S_IFDIR
+		}
+		entries[i] = fuse.DirEntry{Name: d.name, Mode: mode}
+	}
+	return fs.NewListDirStream(entries), 0
+}
+
+func (n *node) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	p := n.path()
+	if flags&syscall.O_ACCMODE == syscall.O_RDONLY {
+		e, err := n.fsys.stat(ctx, p)
+		if err != nil {
+			return nil, 0, toErrno(err)
+		}
+		return &readHandle{fsys: n.fsys, path: p, size: e.size}, 0, 0
+	}
+
+	if n.fsys.opts.ReadOnly {
+		return nil, 0, syscall.EROFS
+	}
+	truncate := flags&syscall.O_TRUNC != 0
+
+	if n.fsys.opts.WriteBack {
+		h, err := newWriteBackHandle(ctx, n.fsys, p, !truncate)
+		if err != nil {
+			return nil, 0, toErrno(err)
+		}
+		return h, 0, 0
+	}
+
+	if !truncate {
+		e, err := n.fsys.stat(ctx, p)
+		if err != nil {
+			return nil, 0, toErrno(err)
+		}
+		if e.size > 0 {
+			// Without a local copy, existing content cannot be modified.
+			return nil, 0, syscall.ENOTSUP
+		}
+	}
+	h, err := newStreamHandle(n.fsys, p)
+	if err != nil {
+		return nil, 0, toErrno(err)
+	}
+	return h, 0, 0
+}
+
+func (n *node) Create(ctx context.Context, name string, _ uint32, _ uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	if n.fsys.opts.ReadOnly {
+		return nil, nil, 0, syscall.EROFS
+	}
+	p := join(n.path(), name)
+
+	var (
+		fh  fs.FileHandle
+		err error
+	)
+	if n.fsys.opts.WriteBack {
+		fh, err = newWriteBackHandle(ctx, n.fsys, p, false)
+	} else {
+		fh, err = newStreamHandle(n.fsys, p)
+	}
+	if err != nil {
+		return nil, nil, 0, toErrno(err)
+	}
+
+	n.fill(entry{modTime: time.Now()}, &out.Attr)
+	return n.newChild(ctx, false), fh, 0, 0
+}
+
+func (n *node) Mkdir(ctx context.Context, name string, _ uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if n.fsys.opts.ReadOnly {
+		return nil, syscall.EROFS
+	}
+	p := join(n.path(), name)
+	if err := n.fsys.backend.Mkdir(ctx, p); err != nil {
+		return nil, toErrno(err)
+	}
+	n.fsys.cache.invalidate(p)
+
+	e := entry{isDir: true, modTime: time.Now()}
+	n.fsys.cache.setAttr(p, e)
+	n.fill(e, &out.Attr)
+	return n.newChild(ctx, true), 0
+}
+
+func (n *node) Unlink(ctx context.Context, name string) syscall.Errno {
+	if n.fsys.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	p := join(n.path(), name)
+	defer n.fsys.cache.invalidate(p)
+	return toErrno(n.fsys.backend.Delete(ctx, p))
+}
+
+func (n *node) Rmdir(ctx context.Context, name string) syscall.Errno {
+	if n.fsys.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	p := join(n.path(), name)
+	defer n.fsys.cache.invalidate(p)
+
+	children, err := n.fsys.readDir(ctx, p)
+	if err != nil {
+		return toErrno(err)
+	}
+	if len(children) > 0 {
+		return syscall.ENOTEMPTY
+	}
+	// Implicit directories on object stores have nothing to remove.
+	if err := n.fsys.backend.Rmdir(ctx, p); err != nil && !omnistorage.IsNotFound(err) {
+		return toErrno(err)
+	}
+	return 0
+}
+
+func (n *node) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if n.fsys.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	if flags&renameExchange != 0 {
+		return syscall.ENOTSUP
+	}
+	src := join(n.path(), name)
+	dst := join(newParent.EmbeddedInode().Path(nil), newName)
+
+	if flags&renameNoReplace != 0 {
+		if _, err := n.fsys.stat(ctx, dst); err == nil {
+			return syscall.EEXIST
+		} else if !errors.Is(err, omnistorage.ErrNotFound) {
+			return toErrno(err)
+		}
+	}
+	return toErrno(n.fsys.rename(ctx, src, dst))
+}
+
+// Statfs reports a large, empty filesystem, since backends have no fixed
+// capacity. Some programs refuse to write to a filesystem that reports no
+// free space.
+func (n *node) Statfs(_ context.Context, out *fuse.StatfsOut) syscall.Errno {
+	const blocks = 1 << 32
+	out.Bsize = 4096
+	out.Frsize = 4096
+	out.Blocks = blocks
+	out.Bfree = blocks
+	out.Bavail = blocks
+	out.Files = blocks
+	out.Ffree = blocks
+	out.NameLen = 255
+	return 0
+}
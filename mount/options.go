@@ -0,0 +1,106 @@
+// Package mount exposes an omnistorage backend as a FUSE filesystem, so
+// programs that only understand file paths can read and write backend data.
+//
+// Basic usage:
+//
+//	backend, _ := s3.New(s3.Config{Bucket: "my-bucket"})
+//	server, err := mount.Mount(backend, "/mnt/data", mount.Options{
+//	    WriteBack: true,
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer server.Unmount()
+//	server.Wait()
+//
+// Reads are served with ranged reads, so seeking within large objects does
+// not download them in full. Writes depend on Options.WriteBack: without
+// it, files can only be written sequentially from the start and are
+// streamed to the backend, which suits copying files in; with it, files are
+// staged in a local temporary file, allowing random writes and appends, and
+// uploaded when the file is flushed or closed.
+//
+// Directories are taken from the backend's listing. On object stores
+// without real directories, any path prefix appears as a directory.
+//
+// Mounting requires FUSE: /dev/fuse and fusermount on Linux, or macFUSE on
+// macOS. On Windows, Mount returns ErrUnsupportedPlatform.
+package mount
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultAttrTimeout is the default time attributes and directory
+	// listings are cached.
+	DefaultAttrTimeout = time.Second
+
+	// DefaultFSName is the default filesystem name shown by mount(8).
+	DefaultFSName = "omnistorage"
+)
+
+// ErrNoBackend is returned by Mount when backend is nil.
+var ErrNoBackend = errors.New("mount: backend is nil")
+
+// Options configures a mount.
+type Options struct {
+	// ReadOnly rejects all modifications with EROFS.
+	ReadOnly bool
+
+	// AttrTimeout is how long file attributes and directory listings are
+	// cached, by both the kernel and the mount. Changes made through the
+	// mount are visible immediately; changes made directly on the backend
+	// may take this long to appear.
+	// Default: DefaultAttrTimeout. Negative disables caching.
+	AttrTimeout time.Duration
+
+	// WriteBack stages written files in a local temporary file and uploads
+	// them when the file is flushed or closed. This allows random writes,
+	// appends and in-place edits. Without it, files can only be written
+	// sequentially from the start.
+	WriteBack bool
+
+	// CacheDir is the directory for write-back files.
+	// Default: os.TempDir().
+	CacheDir string
+
+	// FSName is the filesystem name shown by mount(8).
+	// Default: DefaultFSName.
+	FSName string
+
+	// AllowOther lets other users access the mount. On Linux this requires
+	// user_allow_other in /etc/fuse.conf.
+	AllowOther bool
+
+	// FileMode and DirMode are the permission bits reported for files and
+	// directories. Defaults: 0644 and 0755.
+	FileMode os.FileMode
+	DirMode  os.FileMode
+
+	// Debug logs every FUSE request.
+	Debug bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.AttrTimeout == 0 {
+		o.AttrTimeout = DefaultAttrTimeout
+	} else if o.AttrTimeout < 0 {
+		o.AttrTimeout = 0
+	}
+	if o.CacheDir == "" {
+		o.CacheDir = os.TempDir()
+	}
+	if o.FSName == "" {
+		o.FSName = DefaultFSName
+	}
+	if o.FileMode == 0 {
+		o.FileMode = 0644
+	}
+	if o.DirMode == 0 {
+		o.DirMode = 0755
+	}
+	return o
+}
@@ -0,0 +1,512 @@
+package multi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+const (
+	// DefaultRetryBaseDelay is the default initial backoff for failed replications.
+	DefaultRetryBaseDelay = time.Second
+
+	// DefaultRetryMaxDelay is the default maximum backoff for failed replications.
+	DefaultRetryMaxDelay = 5 * time.Minute
+)
+
+// ReplicationOp is the kind of change queued for a secondary backend.
+type ReplicationOp string
+
+const (
+	// ReplicatePut copies the object from the primary to the secondary.
+	ReplicatePut ReplicationOp = "put"
+
+	// ReplicateDelete removes the object from the secondary.
+	ReplicateDelete ReplicationOp = "delete"
+)
+
+// ReplicationTask is a pending change for one secondary backend.
+type ReplicationTask struct {
+	// Op is the change to apply.
+	Op ReplicationOp `json:"op"`
+
+	// Path is the object path.
+	Path string `json:"path"`
+
+	// Backend is the index of the secondary backend, as given to the Writer.
+	Backend int `json:"backend"`
+
+	// Attempts is the number of failed attempts so far.
+	Attempts int `json:"attempts,omitempty"`
+
+	// LastError is the most recent failure, if any.
+	LastError string `json:"last_error,omitempty"`
+
+	// NextAttempt is the earliest time the task will be retried.
+	NextAttempt time.Time `json:"next_attempt,omitempty"`
+}
+
+// WithJournal persists the replication queue to path on the given backend,
+// so pending replications survive a restart. The journal is rewritten in
+// full after changes, outside the queue lock, with changes made during a
+// rewrite saved together by the next one. It is loaded when the Writer is
+// created. A failure to save it is returned by the Close of the writer
+// whose write was queued, or, for replications applied in the background,
+// by Drain. Only used with WriteAsync.
+func WithJournal(backend omnistorage.Backend, path string) Option {
+	return func(w *Writer) {
+		w.asyncCfg.journal = backend
+		w.asyncCfg.journalPath = path
+	}
+}
+
+// WithRetryBackoff sets the exponential backoff bounds for failed
+// replications. Only used with WriteAsync.
+func WithRetryBackoff(base, max time.Duration) Option {
+	return func(w *Writer) {
+		w.asyncCfg.baseDelay = base
+		w.asyncCfg.maxDelay = max
+	}
+}
+
+// WithMaxAttempts drops a replication task after n failed attempts.
+// Zero (the default) retries forever. Only used with WriteAsync.
+func WithMaxAttempts(n int) Option {
+	return func(w *Writer) {
+		w.asyncCfg.maxAttempts = n
+	}
+}
+
+// WithReplicationErrorHandler sets a function called each time a
+// replication attempt fails. Only used with WriteAsync.
+func WithReplicationErrorHandler(fn func(ReplicationTask, error)) Option {
+	return func(w *Writer) {
+		w.asyncCfg.onError = fn
+	}
+}
+
+type asyncConfig struct {
+	journal     omnistorage.Backend
+	journalPath string
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	maxAttempts int
+	onError     func(ReplicationTask, error)
+}
+
+type taskKey struct {
+	backend int
+	path    string
+}
+
+// replicator applies queued changes from the primary to the secondaries in
+// the background, one worker per secondary.
+type replicator struct {
+	primary  omnistorage.Backend
+	backends []omnistorage.Backend
+	cfg      asyncConfig
+
+	mu      sync.Mutex
+	tasks   map[taskKey]*ReplicationTask
+	running map[taskKey]bool
+	version map[taskKey]int
+	changed chan struct{}
+	wake    []chan struct{}
+	gen     uint64 // number of queue changes
+
+	journalMu  sync.Mutex
+	persisted  uint64 // gen saved to the journal
+	journalErr error  // last failure to save the journal
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newReplicator(backends []omnistorage.Backend, cfg asyncConfig) (*replicator, error) {
+	if cfg.baseDelay <= 0 {
+		cfg.baseDelay = DefaultRetryBaseDelay
+	}
+	if cfg.maxDelay <= 0 {
+		cfg.maxDelay = DefaultRetryMaxDelay
+	}
+
+	r := &replicator{
+		primary:  backends[0],
+		backends: backends,
+		cfg:      cfg,
+		tasks:    make(map[taskKey]*ReplicationTask),
+		running:  make(map[taskKey]bool),
+		version:  make(map[taskKey]int),
+		changed:  make(chan struct{}),
+		wake:     make([]chan struct{}, len(backends)),
+		stop:     make(chan struct{}),
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < len(backends); i++ {
+		r.wake[i] = make(chan struct{}, 1)
+		r.wg.Add(1)
+		go r.worker(i)
+	}
+	return r, nil
+}
+
+// enqueue queues op on path for every secondary. A newer change to the same
+// path replaces any pending one.
+func (r *replicator) enqueue(op ReplicationOp, path string) error {
+	r.mu.Lock()
+	for i := 1; i < len(r.backends); i++ {
+		k := taskKey{backend: i, path: path}
+		r.tasks[k] = &ReplicationTask{Op: op, Path: path, Backend: i}
+		r.version[k]++
+	}
+	r.gen++
+	gen := r.gen
+	r.notifyLocked()
+	r.mu.Unlock()
+
+	for i := 1; i < len(r.backends); i++ {
+		select {
+		case r.wake[i] <- struct{}{}:
+		default:
+		}
+	}
+	return r.persist(gen)
+}
+
+func (r *replicator) worker(target int) {
+	defer r.wg.Done()
+
+	for {
+		task, version, wait := r.next(target)
+		if task == nil {
+			timer := time.NewTimer(wait)
+			select {
+			case <-r.stop:
+				timer.Stop()
+				return
+			case <-r.wake[target]:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+
+		err := r.apply(task)
+		r.finish(task, version, err)
+	}
+}
+
+// next returns the ready task for target with the earliest NextAttempt, or
+// how long to wait for one.
+func (r *replicator) next(target int) (*ReplicationTask, int, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var best *ReplicationTask
+	wait := time.Hour
+	for k, t := range r.tasks {
+		if k.backend != target || r.running[k] {
+			continue
+		}
+		if d := t.NextAttempt.Sub(now); d > 0 {
+			if d < wait {
+				wait = d
+			}
+			continue
+		}
+		if best == nil || t.NextAttempt.Before(best.NextAttempt) {
+			best = t
+		}
+	}
+	if best == nil {
+		return nil, 0, wait
+	}
+
+	k := taskKey{backend: target, path: best.Path}
+	r.running[k] = true
+	copied := *best
+	return &copied, r.version[k], 0
+}
+
+func (r *replicator) apply(t *ReplicationTask) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-r.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	dst := r.backends[t.Backend]
+	switch t.Op {
+	case ReplicateDelete:
+		err := dst.Delete(ctx, t.Path)
+		if omnistorage.IsNotFound(err) {
+			return nil
+		}
+		return err
+	default:
+		err := omnistorage.CopyPath(ctx, r.primary, t.Path, dst, t.Path)
+		if omnistorage.IsNotFound(err) {
+			// Gone from the primary; a queued delete will follow.
+			return nil
+		}
+		return err
+	}
+}
+
+// finish records the outcome of a task, unless a newer change to the same
+// path was queued while it ran.
+func (r *replicator) finish(t *ReplicationTask, version int, err error) {
+	r.mu.Lock()
+	k := taskKey{backend: t.Backend, path: t.Path}
+	delete(r.running, k)
+
+	var failed *ReplicationTask
+	var gen uint64
+	if r.version[k] == version {
+		switch {
+		case err == nil:
+			delete(r.tasks, k)
+			delete(r.version, k)
+		case r.cfg.maxAttempts > 0 && t.Attempts+1 >= r.cfg.maxAttempts:
+			failed = r.fail(t, err)
+			delete(r.tasks, k)
+			delete(r.version, k)
+		default:
+			failed = r.fail(t, err)
+			r.tasks[k] = failed
+		}
+		r.gen++
+		gen = r.gen
+	}
+	r.notifyLocked()
+	onError := r.cfg.onError
+	r.mu.Unlock()
+
+	if gen > 0 {
+		// The error is kept for Drain.
+		_ = r.persist(gen)
+	}
+
+	if failed != nil && onError != nil {
+		onError(*failed, err)
+	}
+	// A newer version may be waiting for this worker.
+	select {
+	case r.wake[t.Backend] <- struct{}{}:
+	default:
+	}
+}
+
+func (r *replicator) fail(t *ReplicationTask, err error) *ReplicationTask {
+	failed := *t
+	failed.Attempts++
+	failed.LastError = err.Error()
+
+	delay := r.cfg.baseDelay << (failed.Attempts - 1)
+	if delay <= 0 || delay > r.cfg.maxDelay {
+		delay = r.cfg.maxDelay
+	}
+	failed.NextAttempt = time.Now().Add(delay)
+	return &failed
+}
+
+// notifyLocked wakes goroutines blocked in drain.
+func (r *replicator) notifyLocked() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *replicator) pending() []ReplicationTask {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshotLocked()
+}
+
+func (r *replicator) snapshotLocked() []ReplicationTask {
+	out := make([]ReplicationTask, 0, len(r.tasks))
+	for _, t := range r.tasks {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Backend < out[j].Backend
+	})
+	return out
+}
+
+// drain waits until the queue is empty, retrying backed-off tasks
+// immediately.
+func (r *replicator) drain(ctx context.Context) error {
+	r.mu.Lock()
+	now := time.Now()
+	for _, t := range r.tasks {
+		if t.NextAttempt.After(now) {
+			t.NextAttempt = now
+		}
+	}
+	r.mu.Unlock()
+
+	for {
+		r.mu.Lock()
+		if len(r.tasks) == 0 {
+			r.mu.Unlock()
+			r.journalMu.Lock()
+			defer r.journalMu.Unlock()
+			return r.journalErr
+		}
+		changed := r.changed
+		r.mu.Unlock()
+
+		for i := 1; i < len(r.backends); i++ {
+			select {
+			case r.wake[i] <- struct{}{}:
+			default:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.stop:
+			return omnistorage.ErrBackendClosed
+		case <-changed:
+		}
+	}
+}
+
+func (r *replicator) close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	r.wg.Wait()
+}
+
+// persist writes the queue to the journal, if configured, unless the
+// change numbered gen was already saved by a concurrent call. Writes are
+// serialized outside r.mu, and each saves the latest queue.
+func (r *replicator) persist(gen uint64) error {
+	if r.cfg.journal == nil {
+		return nil
+	}
+
+	r.journalMu.Lock()
+	defer r.journalMu.Unlock()
+	if r.persisted >= gen {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.Marshal(r.snapshotLocked())
+	latest := r.gen
+	r.mu.Unlock()
+	if err == nil {
+		err = r.writeJournal(data)
+	}
+	r.journalErr = err
+	if err == nil {
+		r.persisted = latest
+	}
+	return err
+}
+
+// writeJournal replaces the journal with data.
+func (r *replicator) writeJournal(data []byte) error {
+	w, err := r.cfg.journal.NewWriter(context.Background(), r.cfg.journalPath,
+		omnistorage.WithContentType("application/json"))
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// load restores the queue from the journal, if configured.
+func (r *replicator) load() error {
+	if r.cfg.journal == nil {
+		return nil
+	}
+
+	rc, err := r.cfg.journal.NewReader(context.Background(), r.cfg.journalPath)
+	if err != nil {
+		if omnistorage.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = rc.Close() }()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var tasks []ReplicationTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if t.Backend < 1 || t.Backend >= len(r.backends) {
+			return errors.New("replication journal does not match backends")
+		}
+		k := taskKey{backend: t.Backend, path: t.Path}
+		r.tasks[k] = &t
+		r.version[k] = 1
+	}
+	return nil
+}
+
+// asyncWriteCloser writes to the primary and queues replication on Close.
+type asyncWriteCloser struct {
+	io.WriteCloser
+	r    *replicator
+	path string
+}
+
+func (a *asyncWriteCloser) Close() error {
+	if err := a.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return a.r.enqueue(ReplicatePut, a.path)
+}
+
+// Pending returns the replication tasks still queued under WriteAsync,
+// sorted by path and backend. It returns nil in other modes.
+func (w *Writer) Pending() []ReplicationTask {
+	if w.async == nil {
+		return nil
+	}
+	return w.async.pending()
+}
+
+// Drain blocks until every queued replication has been applied, retrying
+// backed-off tasks immediately. Tasks dropped after WithMaxAttempts count as
+// done. It returns the error of the last attempt to save the journal, if
+// that failed. It returns immediately in modes other than WriteAsync.
+func (w *Writer) Drain(ctx context.Context) error {
+	if w.async == nil {
+		return nil
+	}
+	return w.async.drain(ctx)
+}
@@ -0,0 +1,231 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// toggleBackend fails writes while down is set.
+type toggleBackend struct {
+	*memory.Backend
+	down     atomic.Bool
+	attempts atomic.Int32
+}
+
+func (b *toggleBackend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	b.attempts.Add(1)
+	if b.down.Load() {
+		return nil, errors.New("replica unavailable")
+	}
+	return b.Backend.NewWriter(ctx, path, opts...)
+}
+
+func newAsync(t *testing.T, backends []omnistorage.Backend, opts ...Option) *Writer {
+	t.Helper()
+	opts = append([]Option{WithMode(WriteAsync), WithRetryBackoff(time.Millisecond, 10*time.Millisecond)}, opts...)
+	mw, err := NewWriterWithOptions(backends, opts...)
+	if err != nil {
+		t.Fatalf("NewWriterWithOptions failed: %v", err)
+	}
+	return mw
+}
+
+func drain(t *testing.T, mw *Writer) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mw.Drain(ctx); err != nil {
+		t.Fatalf("Drain failed: %v (pending %v)", err, mw.Pending())
+	}
+}
+
+func TestAsyncReplication(t *testing.T) {
+	primary, s1, s2 := memory.New(), memory.New(), memory.New()
+	mw := newAsync(t, []omnistorage.Backend{primary, s1, s2})
+	defer func() { _ = mw.Close() }()
+
+	putObject(t, mw, "a.txt", "alpha")
+
+	// The write is visible on the primary as soon as Close returns
+	if got := readAll(t, primary, "a.txt"); got != "alpha" {
+		t.Errorf("primary a.txt = %q, want %q", got, "alpha")
+	}
+
+	drain(t, mw)
+	for _, b := range []*memory.Backend{s1, s2} {
+		if got := readAll(t, b, "a.txt"); got != "alpha" {
+			t.Errorf("secondary a.txt = %q, want %q", got, "alpha")
+		}
+	}
+	if p := mw.Pending(); len(p) != 0 {
+		t.Errorf("Pending after Drain = %v, want none", p)
+	}
+
+	if err := mw.Delete(context.Background(), "a.txt"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	drain(t, mw)
+	for _, b := range []*memory.Backend{s1, s2} {
+		if exists, _ := b.Exists(context.Background(), "a.txt"); exists {
+			t.Error("delete should replicate to secondaries")
+		}
+	}
+}
+
+func TestAsyncRetry(t *testing.T) {
+	primary := memory.New()
+	slow := &toggleBackend{Backend: memory.New()}
+	slow.down.Store(true)
+
+	var (
+		mu       sync.Mutex
+		failures int
+	)
+	mw := newAsync(t, []omnistorage.Backend{primary, slow},
+		WithReplicationErrorHandler(func(task ReplicationTask, err error) {
+			mu.Lock()
+			failures++
+			mu.Unlock()
+		}),
+	)
+	defer func() { _ = mw.Close() }()
+
+	putObject(t, mw, "a.txt", "alpha")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for slow.attempts.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	pending := mw.Pending()
+	if len(pending) != 1 || pending[0].Backend != 1 || pending[0].Attempts == 0 || pending[0].LastError == "" {
+		t.Errorf("Pending = %+v, want one retried task for backend 1", pending)
+	}
+
+	slow.down.Store(false)
+	drain(t, mw)
+
+	if got := readAll(t, slow, "a.txt"); got != "alpha" {
+		t.Errorf("replica a.txt = %q, want %q", got, "alpha")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if failures == 0 {
+		t.Error("error handler should have been called")
+	}
+}
+
+func TestAsyncMaxAttempts(t *testing.T) {
+	broken := &toggleBackend{Backend: memory.New()}
+	broken.down.Store(true)
+
+	mw := newAsync(t, []omnistorage.Backend{memory.New(), broken}, WithMaxAttempts(2))
+	defer func() { _ = mw.Close() }()
+
+	putObject(t, mw, "a.txt", "alpha")
+	drain(t, mw)
+
+	if n := broken.attempts.Load(); n != 2 {
+		t.Errorf("attempts = %d, want 2", n)
+	}
+}
+
+func TestAsyncJournal(t *testing.T) {
+	journal := memory.New()
+	primary := memory.New()
+	replica := &toggleBackend{Backend: memory.New()}
+	replica.down.Store(true)
+
+	backends := []omnistorage.Backend{primary, replica}
+	mw := newAsync(t, backends, WithJournal(journal, "replication.json"))
+	putObject(t, mw, "a.txt", "alpha")
+	putObject(t, mw, "b.txt", "bravo")
+
+	// Stop without draining; replication state lives in the journal
+	mw.async.close()
+	if exists, _ := journal.Exists(context.Background(), "replication.json"); !exists {
+		t.Fatal("journal should be written")
+	}
+
+	replica.down.Store(false)
+	restarted := newAsync(t, backends, WithJournal(journal, "replication.json"))
+	defer restarted.async.close()
+
+	if n := len(restarted.Pending()); n != 2 {
+		t.Errorf("Pending after restart = %d tasks, want 2", n)
+	}
+	drain(t, restarted)
+
+	if got := readAll(t, replica, "b.txt"); got != "bravo" {
+		t.Errorf("replica b.txt = %q, want %q", got, "bravo")
+	}
+	if got := readAll(t, journal, "replication.json"); got != "[]" {
+		t.Errorf("journal after drain = %q, want empty list", got)
+	}
+}
+
+func TestAsyncJournalFailure(t *testing.T) {
+	ctx := context.Background()
+	journal := &toggleBackend{Backend: memory.New()}
+	mw := newAsync(t, []omnistorage.Backend{memory.New(), memory.New()}, WithJournal(journal, "replication.json"))
+	defer func() { _ = mw.Close() }()
+
+	journal.down.Store(true)
+	w, err := mw.NewWriter(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	_, _ = io.WriteString(w, "alpha")
+	if err := w.Close(); err == nil {
+		t.Error("Close succeeded without saving the journal")
+	}
+	if err := mw.Drain(ctx); err == nil {
+		t.Error("Drain succeeded without saving the journal")
+	}
+
+	journal.down.Store(false)
+	putObject(t, mw, "b.txt", "bravo")
+	drain(t, mw)
+	if got := readAll(t, journal, "replication.json"); got != "[]" {
+		t.Errorf("journal after drain = %q, want empty list", got)
+	}
+}
+
+func TestAsyncMove(t *testing.T) {
+	primary, replica := memory.New(), memory.New()
+	mw := newAsync(t, []omnistorage.Backend{primary, replica})
+	defer func() { _ = mw.Close() }()
+
+	putObject(t, mw, "old.txt", "data")
+	drain(t, mw)
+
+	if err := mw.Move(context.Background(), "old.txt", "new.txt"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	drain(t, mw)
+
+	if exists, _ := replica.Exists(context.Background(), "old.txt"); exists {
+		t.Error("old.txt should be removed from the replica")
+	}
+	if got := readAll(t, replica, "new.txt"); got != "data" {
+		t.Errorf("replica new.txt = %q, want %q", got, "data")
+	}
+}
+
+func TestDrainNotAsync(t *testing.T) {
+	mw, _ := NewWriter(memory.New())
+	if err := mw.Drain(context.Background()); err != nil {
+		t.Errorf("Drain = %v, want nil outside WriteAsync", err)
+	}
+	if mw.Pending() != nil {
+		t.Error("Pending should be nil outside WriteAsync")
+	}
+}
@@ -0,0 +1,300 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/grokify/omnistorage"
+)
+
+// ExistsPolicy determines how Writer.Exists combines answers from backends.
+type ExistsPolicy int
+
+const (
+	// ExistsAny reports true if the object exists on any backend.
+	ExistsAny ExistsPolicy = iota
+
+	// ExistsAll reports true only if the object exists on every backend.
+	ExistsAll
+)
+
+// WithExistsPolicy sets how Exists combines answers from backends.
+// The default is ExistsAny.
+func WithExistsPolicy(policy ExistsPolicy) Option {
+	return func(w *Writer) {
+		w.exists = policy
+	}
+}
+
+// Primary returns the first backend, which serves reads and Stat.
+func (w *Writer) Primary() omnistorage.Backend {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.backends[0]
+}
+
+// NewReader reads from the primary backend, falling back to the other
+// backends in order if the primary cannot open the object.
+func (w *Writer) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var errs []error
+	for _, b := range w.backends {
+		r, err := b.NewReader(ctx, path, opts...)
+		if err == nil {
+			return r, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, err)
+	}
+	return nil, readError(errs)
+}
+
+// Exists checks whether path exists according to the exists policy.
+func (w *Writer) Exists(ctx context.Context, path string) (bool, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var errs []error
+	found := 0
+	for _, b := range w.backends {
+		exists, err := b.Exists(ctx, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if exists {
+			found++
+			if w.exists == ExistsAny {
+				return true, nil
+			}
+		}
+	}
+
+	if len(errs) > 0 && (w.exists == ExistsAll || len(errs) == len(w.backends)) {
+		return false, &MultiError{Errors: errs}
+	}
+	if w.exists == ExistsAll {
+		return found == len(w.backends), nil
+	}
+	return false, nil
+}
+
+// Delete removes path from all backends. Success is judged by the write mode.
+// Under WriteAsync, the delete is applied to the primary and queued for the
+// other backends.
+func (w *Writer) Delete(ctx context.Context, path string) error {
+	if w.async != nil {
+		if err := w.backends[0].Delete(ctx, path); err != nil {
+			return err
+		}
+		return w.async.enqueue(ReplicateDelete, path)
+	}
+	return w.fanOut(func(b omnistorage.Backend) error {
+		return b.Delete(ctx, path)
+	})
+}
+
+// List returns the sorted union of paths with the given prefix across all
+// backends. Under WriteAll every backend must list successfully; otherwise
+// unreachable backends are skipped as long as the write mode is satisfied.
+func (w *Writer) List(ctx context.Context, prefix string) ([]string, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var (
+		paths []string
+		errs  []error
+	)
+	for _, b := range w.backends {
+		list, err := b.List(ctx, prefix)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, p := range list {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	if err := w.modeError(len(w.backends)-len(errs), errs); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Close closes all backends. Under WriteAsync, background replication is
+// stopped first; undrained tasks remain in the journal, if one is configured.
+func (w *Writer) Close() error {
+	if w.async != nil {
+		w.async.close()
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var errs []error
+	for _, b := range w.backends {
+		if err := b.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// Stat returns metadata from the primary backend.
+func (w *Writer) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(w.Primary())
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory on every backend that supports directories.
+func (w *Writer) Mkdir(ctx context.Context, path string) error {
+	return w.fanOut(func(b omnistorage.Backend) error {
+		ext, ok := omnistorage.AsExtended(b)
+		if !ok {
+			return nil
+		}
+		if err := ext.Mkdir(ctx, path); err != nil && !errors.Is(err, omnistorage.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Rmdir removes a directory on every backend that supports directories.
+func (w *Writer) Rmdir(ctx context.Context, path string) error {
+	return w.fanOut(func(b omnistorage.Backend) error {
+		ext, ok := omnistorage.AsExtended(b)
+		if !ok {
+			return nil
+		}
+		if err := ext.Rmdir(ctx, path); err != nil && !errors.Is(err, omnistorage.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Copy copies src to dst on every backend, server-side where supported.
+// Under WriteAsync, the copy is made on the primary and dst is queued for
+// replication.
+func (w *Writer) Copy(ctx context.Context, src, dst string) error {
+	if w.async != nil {
+		if err := omnistorage.SmartCopy(ctx, w.backends[0], src, w.backends[0], dst); err != nil {
+			return err
+		}
+		return w.async.enqueue(ReplicatePut, dst)
+	}
+	return w.fanOut(func(b omnistorage.Backend) error {
+		return omnistorage.SmartCopy(ctx, b, src, b, dst)
+	})
+}
+
+// Move moves src to dst on every backend, server-side where supported.
+// Under WriteAsync, the move is made on the primary and queued for the
+// other backends as a put of dst and a delete of src.
+func (w *Writer) Move(ctx context.Context, src, dst string) error {
+	if w.async != nil {
+		if err := omnistorage.SmartMove(ctx, w.backends[0], src, w.backends[0], dst); err != nil {
+			return err
+		}
+		if err := w.async.enqueue(ReplicatePut, dst); err != nil {
+			return err
+		}
+		return w.async.enqueue(ReplicateDelete, src)
+	}
+	return w.fanOut(func(b omnistorage.Backend) error {
+		return omnistorage.SmartMove(ctx, b, src, b, dst)
+	})
+}
+
+// Features returns the combined capabilities of the backends.
+// Read-side features (Stat, Hashes, RangeRead) come from the primary;
+// server-side Copy, Move, streaming and prefix listing require every backend;
+// Mkdir and Rmdir are reported if any backend needs directories.
+func (w *Writer) Features() omnistorage.Features {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	f := omnistorage.Features{
+		Copy:       true,
+		Move:       true,
+		CanStream:  true,
+		ListPrefix: true,
+	}
+	for i, b := range w.backends {
+		ext, ok := omnistorage.AsExtended(b)
+		if !ok {
+			f.Copy, f.Move, f.CanStream, f.ListPrefix = false, false, false, false
+			continue
+		}
+		bf := ext.Features()
+		if i == 0 {
+			f.Stat = bf.Stat
+			f.Hashes = bf.Hashes
+			f.RangeRead = bf.RangeRead
+		}
+		f.Copy = f.Copy && bf.Copy
+		f.Move = f.Move && bf.Move
+		f.CanStream = f.CanStream && bf.CanStream
+		f.ListPrefix = f.ListPrefix && bf.ListPrefix
+		f.Mkdir = f.Mkdir || bf.Mkdir
+		f.Rmdir = f.Rmdir || bf.Rmdir
+	}
+	return f
+}
+
+// fanOut runs op on every backend and judges the result by the write mode.
+func (w *Writer) fanOut(op func(omnistorage.Backend) error) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var errs []error
+	for _, b := range w.backends {
+		if err := op(b); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return w.modeError(len(w.backends)-len(errs), errs)
+}
+
+// modeError returns an error if successCount does not satisfy the write mode.
+func (w *Writer) modeError(successCount int, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	switch w.mode {
+	case WriteQuorum:
+		if successCount <= len(w.backends)/2 {
+			return &MultiError{Errors: append(errs, errors.New("quorum not achieved"))}
+		}
+	case WriteBestEffort:
+		if successCount == 0 {
+			return &MultiError{Errors: errs}
+		}
+	default:
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// Ensure Writer implements omnistorage.ExtendedBackend
+var _ omnistorage.ExtendedBackend = (*Writer)(nil)
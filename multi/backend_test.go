@@ -0,0 +1,152 @@
+package multi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/sync"
+)
+
+func TestWriterAsBackend(t *testing.T) {
+	ctx := context.Background()
+	b1, b2 := memory.New(), memory.New()
+
+	mw, _ := NewWriter(b1, b2)
+	var backend omnistorage.Backend = mw
+	putObject(t, backend, "dir/a.txt", "alpha")
+
+	if got := readAll(t, mw, "dir/a.txt"); got != "alpha" {
+		t.Errorf("Read = %q, want %q", got, "alpha")
+	}
+
+	paths, err := mw.List(ctx, "dir/")
+	if err != nil || len(paths) != 1 || paths[0] != "a.txt" {
+		t.Errorf("List = %v, %v; want [a.txt]", paths, err)
+	}
+
+	info, err := mw.Stat(ctx, "dir/a.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() != 5 {
+		t.Errorf("Stat size = %d, want 5", info.Size())
+	}
+
+	if err := mw.Copy(ctx, "dir/a.txt", "dir/b.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if err := mw.Move(ctx, "dir/b.txt", "dir/c.txt"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	for _, b := range []*memory.Backend{b1, b2} {
+		if got := readAll(t, b, "dir/c.txt"); got != "alpha" {
+			t.Errorf("dir/c.txt = %q, want %q", got, "alpha")
+		}
+		if exists, _ := b.Exists(ctx, "dir/b.txt"); exists {
+			t.Error("dir/b.txt should be moved away")
+		}
+	}
+
+	if err := mw.Delete(ctx, "dir/a.txt"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	for _, b := range []*memory.Backend{b1, b2} {
+		if exists, _ := b.Exists(ctx, "dir/a.txt"); exists {
+			t.Error("dir/a.txt should be deleted from every backend")
+		}
+	}
+}
+
+func TestWriterReadFailover(t *testing.T) {
+	b1, b2 := memory.New(), memory.New()
+	putObject(t, b2, "only-secondary.txt", "x")
+
+	mw, _ := NewWriter(b1, b2)
+	if got := readAll(t, mw, "only-secondary.txt"); got != "x" {
+		t.Errorf("Read = %q, want %q", got, "x")
+	}
+	if _, err := mw.NewReader(context.Background(), "missing.txt"); !omnistorage.IsNotFound(err) {
+		t.Errorf("NewReader missing: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWriterExistsPolicy(t *testing.T) {
+	ctx := context.Background()
+	b1, b2 := memory.New(), memory.New()
+	putObject(t, b1, "partial.txt", "x")
+
+	anyW, _ := NewWriter(b1, b2)
+	if exists, err := anyW.Exists(ctx, "partial.txt"); err != nil || !exists {
+		t.Errorf("ExistsAny = %v, %v; want true", exists, err)
+	}
+
+	allW, _ := NewWriterWithOptions([]omnistorage.Backend{b1, b2}, WithExistsPolicy(ExistsAll))
+	if exists, err := allW.Exists(ctx, "partial.txt"); err != nil || exists {
+		t.Errorf("ExistsAll = %v, %v; want false", exists, err)
+	}
+}
+
+func TestWriterListBestEffort(t *testing.T) {
+	b1 := memory.New()
+	putObject(t, b1, "a.txt", "x")
+	broken := &failingBackend{fail: true}
+
+	strict, _ := NewWriter(b1, broken)
+	if _, err := strict.List(context.Background(), ""); err == nil {
+		t.Error("List under WriteAll should fail when a backend fails")
+	}
+
+	lenient, _ := NewWriterWithOptions([]omnistorage.Backend{b1, broken}, WithMode(WriteBestEffort))
+	paths, err := lenient.List(context.Background(), "")
+	if err != nil || len(paths) != 1 {
+		t.Errorf("List best effort = %v, %v; want [a.txt]", paths, err)
+	}
+}
+
+func TestWriterFeatures(t *testing.T) {
+	mw, _ := NewWriter(memory.New(), memory.New())
+	f := mw.Features()
+	want := memory.New().Features()
+	if f.Stat != want.Stat || f.Copy != want.Copy || f.Move != want.Move {
+		t.Errorf("Features = %+v, want primary-derived %+v", f, want)
+	}
+
+	mixed, _ := NewWriter(memory.New(), &failingBackend{})
+	if mixed.Features().Copy {
+		t.Error("Copy feature should require every backend")
+	}
+}
+
+func TestWriterAsSyncDestination(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	putObject(t, src, "docs/one.txt", "1")
+	putObject(t, src, "docs/two.txt", "2")
+
+	b1, b2 := memory.New(), memory.New()
+	mw, _ := NewWriter(b1, b2)
+
+	result, err := sync.Sync(ctx, src, mw, "docs", "backup", sync.Options{})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 2 {
+		t.Errorf("Copied = %d, want 2", result.Copied)
+	}
+	for _, b := range []*memory.Backend{b1, b2} {
+		if got := readAll(t, b, "backup/two.txt"); got != "2" {
+			t.Errorf("backup/two.txt = %q, want %q", got, "2")
+		}
+	}
+
+	// A second sync sees the composite as up to date
+	result, err = sync.Sync(ctx, src, mw, "docs", "backup", sync.Options{})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 0 {
+		t.Errorf("second Sync copied %d, want 0", result.Copied)
+	}
+}
@@ -0,0 +1,175 @@
+package multi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/grokify/omnistorage"
+)
+
+// ErrQuorumNotReached is returned (wrapped in a *QuorumError) when too few
+// replicas agree on an object's content.
+var ErrQuorumNotReached = errors.New("read quorum not reached")
+
+// QuorumError describes a failed quorum read.
+type QuorumError struct {
+	// Path is the object that was read.
+	Path string
+
+	// Required is the number of replicas that had to agree.
+	Required int
+
+	// Agreed is the size of the largest group of agreeing replicas.
+	Agreed int
+
+	// Errors holds the errors from replicas that could not be read.
+	Errors []error
+}
+
+// Error implements the error interface.
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("%s: %v: %d of %d required replicas agree", e.Path, ErrQuorumNotReached, e.Agreed, e.Required)
+}
+
+// Unwrap returns ErrQuorumNotReached.
+func (e *QuorumError) Unwrap() error {
+	return ErrQuorumNotReached
+}
+
+// Divergence reports replicas that did not match the majority during a
+// quorum read. Indexes refer to the backends in the order they were given.
+type Divergence struct {
+	// Path is the object that was read.
+	Path string
+
+	// Hash is the SHA-256 hex digest of the content the largest group agreed
+	// on. It is empty if no replica could be read.
+	Hash string
+
+	// Divergent lists backends whose content differed from Hash.
+	Divergent []int
+
+	// Missing lists backends where the object does not exist.
+	Missing []int
+
+	// Failed lists backends that returned an error other than not-found.
+	Failed []int
+
+	// QuorumReached reports whether the read succeeded.
+	QuorumReached bool
+}
+
+type quorumResult struct {
+	data []byte
+	hash string
+	err  error
+}
+
+// requiredQuorum returns the number of replicas that must agree.
+func (r *Reader) requiredQuorum() int {
+	if r.quorum > 0 {
+		return r.quorum
+	}
+	return len(r.backends)/2 + 1
+}
+
+// openQuorum reads path from every backend concurrently and returns the
+// content agreed on by a quorum of replicas.
+func (r *Reader) openQuorum(ctx context.Context, path string, opts []omnistorage.ReaderOption) (io.ReadCloser, error) {
+	results := make([]quorumResult, len(r.backends))
+
+	var wg sync.WaitGroup
+	for i, b := range r.backends {
+		wg.Add(1)
+		go func(i int, b omnistorage.Backend) {
+			defer wg.Done()
+			results[i] = readReplica(ctx, b, path, opts)
+		}(i, b)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Group replicas by content hash
+	votes := make(map[string][]int)
+	var errs []error
+	for i, res := range results {
+		if res.err != nil {
+			r.recordFailure(i, res.err)
+			errs = append(errs, res.err)
+			continue
+		}
+		r.recordSuccess(i)
+		votes[res.hash] = append(votes[res.hash], i)
+	}
+
+	// Largest group wins; ties go to the group containing the
+	// highest-priority backend.
+	var winner string
+	hashes := make([]string, 0, len(votes))
+	for h := range votes {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(a, b int) bool {
+		va, vb := votes[hashes[a]], votes[hashes[b]]
+		if len(va) != len(vb) {
+			return len(va) > len(vb)
+		}
+		return va[0] < vb[0]
+	})
+	if len(hashes) > 0 {
+		winner = hashes[0]
+	}
+
+	required := r.requiredQuorum()
+	agreed := len(votes[winner])
+
+	div := Divergence{Path: path, Hash: winner, QuorumReached: agreed >= required}
+	for i, res := range results {
+		switch {
+		case res.err == nil && res.hash != winner:
+			div.Divergent = append(div.Divergent, i)
+		case omnistorage.IsNotFound(res.err):
+			div.Missing = append(div.Missing, i)
+		case res.err != nil:
+			div.Failed = append(div.Failed, i)
+		}
+	}
+	if r.onDiverge != nil && len(div.Divergent)+len(div.Missing)+len(div.Failed) > 0 {
+		r.onDiverge(div)
+	}
+
+	if !div.QuorumReached {
+		if len(div.Missing) == len(r.backends) {
+			return nil, omnistorage.ErrNotFound
+		}
+		return nil, &QuorumError{Path: path, Required: required, Agreed: agreed, Errors: errs}
+	}
+
+	data := results[votes[winner][0]].data
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// readReplica reads an entire replica into memory and hashes it.
+func readReplica(ctx context.Context, b omnistorage.Backend, path string, opts []omnistorage.ReaderOption) quorumResult {
+	rc, err := b.NewReader(ctx, path, opts...)
+	if err != nil {
+		return quorumResult{err: err}
+	}
+	defer func() { _ = rc.Close() }()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return quorumResult{err: err}
+	}
+	sum := sha256.Sum256(data)
+	return quorumResult{data: data, hash: omnistorage.HashBytesFromSum(sum[:])}
+}
@@ -0,0 +1,121 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestReadQuorumAgreement(t *testing.T) {
+	b1, b2, b3 := memory.New(), memory.New(), memory.New()
+	putObject(t, b1, "file.txt", "good")
+	putObject(t, b2, "file.txt", "corrupt")
+	putObject(t, b3, "file.txt", "good")
+
+	var got []Divergence
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{b1, b2, b3},
+		WithReadStrategy(ReadQuorum),
+		WithDivergenceHandler(func(d Divergence) { got = append(got, d) }),
+	)
+
+	if data := readAll(t, mr, "file.txt"); data != "good" {
+		t.Errorf("Read = %q, want %q", data, "good")
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("divergence handler called %d times, want 1", len(got))
+	}
+	d := got[0]
+	if !d.QuorumReached || len(d.Divergent) != 1 || d.Divergent[0] != 1 {
+		t.Errorf("Divergence = %+v, want backend 1 divergent with quorum reached", d)
+	}
+	if d.Hash != omnistorage.HashBytes([]byte("good"), omnistorage.HashSHA256) {
+		t.Errorf("Divergence.Hash = %q, want SHA-256 of agreed content", d.Hash)
+	}
+}
+
+func TestReadQuorumNoDivergence(t *testing.T) {
+	b1, b2 := memory.New(), memory.New()
+	putObject(t, b1, "file.txt", "same")
+	putObject(t, b2, "file.txt", "same")
+
+	called := false
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{b1, b2},
+		WithReadStrategy(ReadQuorum),
+		WithDivergenceHandler(func(Divergence) { called = true }),
+	)
+	if data := readAll(t, mr, "file.txt"); data != "same" {
+		t.Errorf("Read = %q, want %q", data, "same")
+	}
+	if called {
+		t.Error("divergence handler should not be called when all replicas agree")
+	}
+}
+
+func TestReadQuorumNotReached(t *testing.T) {
+	b1, b2, b3 := memory.New(), memory.New(), memory.New()
+	putObject(t, b1, "file.txt", "one")
+	putObject(t, b2, "file.txt", "two")
+
+	var div Divergence
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{b1, b2, b3},
+		WithReadStrategy(ReadQuorum),
+		WithDivergenceHandler(func(d Divergence) { div = d }),
+	)
+
+	_, err := mr.NewReader(context.Background(), "file.txt")
+	if !errors.Is(err, ErrQuorumNotReached) {
+		t.Fatalf("NewReader error = %v, want ErrQuorumNotReached", err)
+	}
+	var qerr *QuorumError
+	if !errors.As(err, &qerr) || qerr.Required != 2 || qerr.Agreed != 1 {
+		t.Errorf("QuorumError = %+v, want Required=2 Agreed=1", qerr)
+	}
+	if div.QuorumReached || len(div.Missing) != 1 || div.Missing[0] != 2 {
+		t.Errorf("Divergence = %+v, want backend 2 missing and no quorum", div)
+	}
+}
+
+func TestReadQuorumCustomQuorum(t *testing.T) {
+	b1, b2, b3 := memory.New(), memory.New(), memory.New()
+	putObject(t, b1, "file.txt", "data")
+	putObject(t, b2, "file.txt", "data")
+	putObject(t, b3, "file.txt", "data")
+
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{b1, b2, &failingBackend{fail: true}},
+		WithReadStrategy(ReadQuorum),
+		WithReadQuorum(3),
+	)
+	if _, err := mr.NewReader(context.Background(), "file.txt"); !errors.Is(err, ErrQuorumNotReached) {
+		t.Errorf("NewReader error = %v, want ErrQuorumNotReached", err)
+	}
+	if mr.Health()[2].ConsecutiveFailures != 1 {
+		t.Errorf("failing backend should be recorded in health")
+	}
+
+	mr, _ = NewReaderWithOptions(
+		[]omnistorage.Backend{b1, b2, b3},
+		WithReadStrategy(ReadQuorum),
+		WithReadQuorum(3),
+	)
+	if data := readAll(t, mr, "file.txt"); data != "data" {
+		t.Errorf("Read = %q, want %q", data, "data")
+	}
+}
+
+func TestReadQuorumAllMissing(t *testing.T) {
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{memory.New(), memory.New()},
+		WithReadStrategy(ReadQuorum),
+	)
+	if _, err := mr.NewReader(context.Background(), "missing.txt"); !omnistorage.IsNotFound(err) {
+		t.Errorf("NewReader error = %v, want ErrNotFound", err)
+	}
+}
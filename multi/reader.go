@@ -0,0 +1,599 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// ReadStrategy determines how the multi-reader chooses among backends.
+type ReadStrategy int
+
+const (
+	// ReadPriority tries backends one at a time in priority order,
+	// moving to the next backend only after the previous one fails.
+	ReadPriority ReadStrategy = iota
+
+	// ReadHedged starts with the highest-priority backend and, if it has
+	// not answered within the hedge delay, races the next backend against
+	// it. The first backend to open the object wins and the others are
+	// canceled.
+	ReadHedged
+
+	// ReadQuorum reads the object from every backend, compares content
+	// hashes, and returns the data only if at least the read quorum of
+	// replicas agree. Replicas that disagree are reported through the
+	// divergence handler. Because every replica is read in full before any
+	// data is returned, the agreed content is buffered in memory.
+	ReadQuorum
+
+	// ReadRoundRobin spreads reads across the healthy backends in turn,
+	// so replicas share the load. A failed read fails over to the next
+	// backend as with ReadPriority.
+	ReadRoundRobin
+
+	// ReadLatency sends each read to the healthy backend that has opened
+	// objects fastest, by an exponentially weighted moving average of its
+	// open latency, so reads go to the nearest replica and move away from
+	// one that slows down under load. Backends not yet measured are tried
+	// first. A failed read fails over as with ReadPriority.
+	ReadLatency
+)
+
+const (
+	// DefaultHedgeDelay is the default delay before a hedged request is sent.
+	DefaultHedgeDelay = 50 * time.Millisecond
+
+	// DefaultFailureThreshold is the default number of consecutive failures
+	// after which a backend is considered unhealthy.
+	DefaultFailureThreshold = 3
+
+	// DefaultCooldown is the default time an unhealthy backend is demoted
+	// before it is tried in priority order again.
+	DefaultCooldown = 30 * time.Second
+
+	// latencyWeight is the weight of each new open latency in the moving
+	// average used by ReadLatency.
+	latencyWeight = 0.2
+)
+
+// Reader provides reads with failover across multiple backends holding the
+// same data, such as the replicas written by a Writer.
+//
+// Backends are tried in the order given. A backend that fails repeatedly is
+// marked unhealthy and demoted behind the healthy backends until its cooldown
+// expires; it is still tried as a last resort. A missing object
+// (omnistorage.ErrNotFound) triggers failover but does not count against the
+// backend's health.
+type Reader struct {
+	backends   []omnistorage.Backend
+	health     []*backendHealth
+	strategy   ReadStrategy
+	hedgeDelay time.Duration
+	threshold  int
+	cooldown   time.Duration
+	quorum     int
+	onDiverge  func(Divergence)
+	turn       atomic.Uint64 // next backend under ReadRoundRobin
+	mu         sync.RWMutex
+}
+
+// ReaderOption configures a multi-reader.
+type ReaderOption func(*Reader)
+
+// WithReadStrategy sets the read strategy.
+func WithReadStrategy(strategy ReadStrategy) ReaderOption {
+	return func(r *Reader) {
+		r.strategy = strategy
+	}
+}
+
+// WithHedgeDelay sets how long ReadHedged waits for a backend before
+// sending the same request to the next one.
+func WithHedgeDelay(d time.Duration) ReaderOption {
+	return func(r *Reader) {
+		r.hedgeDelay = d
+	}
+}
+
+// WithFailureThreshold sets the number of consecutive failures after which
+// a backend is marked unhealthy.
+func WithFailureThreshold(n int) ReaderOption {
+	return func(r *Reader) {
+		r.threshold = n
+	}
+}
+
+// WithCooldown sets how long an unhealthy backend stays demoted.
+func WithCooldown(d time.Duration) ReaderOption {
+	return func(r *Reader) {
+		r.cooldown = d
+	}
+}
+
+// WithReadQuorum sets how many replicas must agree under ReadQuorum.
+// Defaults to a majority of the backends.
+func WithReadQuorum(n int) ReaderOption {
+	return func(r *Reader) {
+		r.quorum = n
+	}
+}
+
+// WithDivergenceHandler sets a function called under ReadQuorum whenever
+// some replicas of an object are missing, unreadable, or disagree with the
+// others. It is called whether or not the quorum was reached.
+func WithDivergenceHandler(fn func(Divergence)) ReaderOption {
+	return func(r *Reader) {
+		r.onDiverge = fn
+	}
+}
+
+// BackendHealth is a snapshot of a backend's health as seen by a Reader.
+type BackendHealth struct {
+	// Healthy is false while the backend is demoted.
+	Healthy bool
+
+	// ConsecutiveFailures is the number of failures since the last success.
+	ConsecutiveFailures int
+
+	// LastError is the most recent failure, if any.
+	LastError error
+
+	// LastFailure is the time of the most recent failure.
+	LastFailure time.Time
+
+	// Latency is the moving average of the time the backend took to open
+	// objects, or 0 if it has not opened any.
+	Latency time.Duration
+}
+
+type backendHealth struct {
+	failures    int
+	lastErr     error
+	lastFailure time.Time
+	latency     time.Duration
+}
+
+// NewReader creates a new multi-reader for the given backends, in priority order.
+// At least one backend must be provided.
+func NewReader(backends ...omnistorage.Backend) (*Reader, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("at least one backend is required")
+	}
+
+	// Filter nil backends
+	var validBackends []omnistorage.Backend
+	for _, b := range backends {
+		if b != nil {
+			validBackends = append(validBackends, b)
+		}
+	}
+
+	if len(validBackends) == 0 {
+		return nil, errors.New("no valid backends provided")
+	}
+
+	health := make([]*backendHealth, len(validBackends))
+	for i := range health {
+		health[i] = &backendHealth{}
+	}
+
+	return &Reader{
+		backends:   validBackends,
+		health:     health,
+		strategy:   ReadPriority,
+		hedgeDelay: DefaultHedgeDelay,
+		threshold:  DefaultFailureThreshold,
+		cooldown:   DefaultCooldown,
+	}, nil
+}
+
+// NewReaderWithOptions creates a new multi-reader with options.
+func NewReaderWithOptions(backends []omnistorage.Backend, opts ...ReaderOption) (*Reader, error) {
+	r, err := NewReader(backends...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Backends returns the number of backends.
+func (r *Reader) Backends() int {
+	return len(r.backends)
+}
+
+// Health returns a snapshot of each backend's health, in backend order.
+func (r *Reader) Health() []BackendHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	out := make([]BackendHealth, len(r.health))
+	for i, h := range r.health {
+		out[i] = BackendHealth{
+			Healthy:             r.healthyLocked(h, now),
+			ConsecutiveFailures: h.failures,
+			LastError:           h.lastErr,
+			LastFailure:         h.lastFailure,
+			Latency:             h.latency,
+		}
+	}
+	return out
+}
+
+// NewReader opens path on the first backend that can serve it.
+//
+// With ReadPriority, a read error in the middle of the stream also fails
+// over: the object is reopened on the next backend at the current offset,
+// so callers see a single uninterrupted stream. The replicas are not
+// compared first, so if they hold different versions of the object, as
+// while a write is being replicated, the stream splices bytes of one
+// version onto the other. Callers that cannot tolerate this should verify
+// a checksum of the data read.
+func (r *Reader) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	order := r.order()
+
+	switch {
+	case r.strategy == ReadQuorum:
+		return r.openQuorum(ctx, path, opts)
+	case r.strategy == ReadHedged && len(order) > 1:
+		return r.openHedged(ctx, path, order, opts)
+	}
+
+	rc, pos, err := r.openFrom(ctx, path, order, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := omnistorage.ApplyReaderOptions(opts...)
+	return &failoverReader{
+		parent: r,
+		ctx:    ctx,
+		path:   path,
+		opts:   opts,
+		order:  order,
+		pos:    pos,
+		rc:     rc,
+		offset: cfg.Offset,
+		limit:  cfg.Limit,
+	}, nil
+}
+
+// Exists reports whether path exists on any backend.
+func (r *Reader) Exists(ctx context.Context, path string) (bool, error) {
+	var errs []error
+	for _, i := range r.order() {
+		exists, err := r.backends[i].Exists(ctx, path)
+		if err != nil {
+			r.recordFailure(i, err)
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			errs = append(errs, err)
+			continue
+		}
+		r.recordSuccess(i)
+		if exists {
+			return true, nil
+		}
+	}
+	if len(errs) == len(r.backends) {
+		return false, &MultiError{Errors: errs}
+	}
+	return false, nil
+}
+
+// List lists paths from the first backend that answers.
+func (r *Reader) List(ctx context.Context, prefix string) ([]string, error) {
+	var errs []error
+	for _, i := range r.order() {
+		paths, err := r.backends[i].List(ctx, prefix)
+		if err != nil {
+			r.recordFailure(i, err)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			errs = append(errs, err)
+			continue
+		}
+		r.recordSuccess(i)
+		return paths, nil
+	}
+	return nil, &MultiError{Errors: errs}
+}
+
+// order returns backend indexes with healthy backends first, in the order
+// of the read strategy, then the unhealthy backends in priority order.
+func (r *Reader) order() []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	healthy := make([]int, 0, len(r.backends))
+	var unhealthy []int
+	for i, h := range r.health {
+		if r.healthyLocked(h, now) {
+			healthy = append(healthy, i)
+		} else {
+			unhealthy = append(unhealthy, i)
+		}
+	}
+
+	switch r.strategy {
+	case ReadRoundRobin:
+		if n := len(healthy); n > 1 {
+			k := int((r.turn.Add(1) - 1) % uint64(n))
+			healthy = slices.Concat(healthy[k:], healthy[:k])
+		}
+	case ReadLatency:
+		sort.SliceStable(healthy, func(a, b int) bool {
+			return r.health[healthy[a]].latency < r.health[healthy[b]].latency
+		})
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (r *Reader) healthyLocked(h *backendHealth, now time.Time) bool {
+	if r.threshold <= 0 || h.failures < r.threshold {
+		return true
+	}
+	return now.Sub(h.lastFailure) >= r.cooldown
+}
+
+func (r *Reader) recordSuccess(i int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.health[i].failures = 0
+}
+
+// recordLatency adds d, the time backend i took to open an object, to its
+// moving average.
+func (r *Reader) recordLatency(i int, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.health[i]
+	if h.latency == 0 {
+		h.latency = d
+		return
+	}
+	h.latency += time.Duration(latencyWeight * float64(d-h.latency))
+}
+
+// recordFailure records err against backend i. Not-found errors mean the
+// backend answered, so they reset the failure count instead.
+func (r *Reader) recordFailure(i int, err error) {
+	if omnistorage.IsNotFound(err) {
+		r.recordSuccess(i)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.health[i]
+	h.failures++
+	h.lastErr = err
+	h.lastFailure = time.Now()
+}
+
+// openFrom tries the backends in order in sequence and returns the opened reader and
+// its position in order.
+func (r *Reader) openFrom(ctx context.Context, path string, order []int, opts []omnistorage.ReaderOption) (io.ReadCloser, int, error) {
+	var errs []error
+	for pos, i := range order {
+		start := time.Now()
+		rc, err := r.backends[i].NewReader(ctx, path, opts...)
+		if err == nil {
+			r.recordSuccess(i)
+			r.recordLatency(i, max(time.Since(start), 1))
+			return rc, pos, nil
+		}
+		r.recordFailure(i, err)
+		if ctx.Err() != nil {
+			return nil, pos, ctx.Err()
+		}
+		errs = append(errs, err)
+	}
+	return nil, len(order), readError(errs)
+}
+
+type openResult struct {
+	index int
+	rc    io.ReadCloser
+	err   error
+}
+
+// openHedged races backends in order, starting the next one whenever the
+// hedge delay elapses or an in-flight attempt fails.
+func (r *Reader) openHedged(ctx context.Context, path string, order []int, opts []omnistorage.ReaderOption) (io.ReadCloser, error) {
+	results := make(chan openResult, len(order))
+	cancels := make(map[int]context.CancelFunc, len(order))
+
+	next := 0
+	launch := func() {
+		i := order[next]
+		next++
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		go func() {
+			rc, err := r.backends[i].NewReader(attemptCtx, path, opts...)
+			results <- openResult{index: i, rc: rc, err: err}
+		}()
+	}
+
+	launch()
+	inFlight := 1
+
+	timer := time.NewTimer(r.hedgeDelay)
+	defer timer.Stop()
+
+	var errs []error
+	for inFlight > 0 {
+		select {
+		case <-ctx.Done():
+			for _, cancel := range cancels {
+				cancel()
+			}
+			go drainResults(results, inFlight)
+			return nil, ctx.Err()
+
+		case <-timer.C:
+			if next < len(order) {
+				launch()
+				inFlight++
+				timer.Reset(r.hedgeDelay)
+			}
+
+		case res := <-results:
+			inFlight--
+			if res.err != nil {
+				cancels[res.index]()
+				r.recordFailure(res.index, res.err)
+				errs = append(errs, res.err)
+				if next < len(order) {
+					launch()
+					inFlight++
+					timer.Reset(r.hedgeDelay)
+				}
+				continue
+			}
+
+			r.recordSuccess(res.index)
+
+			// Cancel the losers but keep the winner's context alive until
+			// the returned reader is closed.
+			for i, cancel := range cancels {
+				if i != res.index {
+					cancel()
+				}
+			}
+			go drainResults(results, inFlight)
+			return &hedgedReadCloser{ReadCloser: res.rc, cancel: cancels[res.index]}, nil
+		}
+	}
+
+	return nil, readError(errs)
+}
+
+// drainResults closes readers opened by attempts that lost the race.
+func drainResults(results <-chan openResult, n int) {
+	for ; n > 0; n-- {
+		if res := <-results; res.rc != nil {
+			_ = res.rc.Close()
+		}
+	}
+}
+
+// hedgedReadCloser releases the winning attempt's context on Close.
+type hedgedReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (h *hedgedReadCloser) Close() error {
+	err := h.ReadCloser.Close()
+	h.cancel()
+	return err
+}
+
+// failoverReader reopens the object on the next backend when a read fails.
+// An error returned with data is held back until the next Read, so the
+// data is delivered before failing over.
+type failoverReader struct {
+	parent *Reader
+	ctx    context.Context
+	path   string
+	opts   []omnistorage.ReaderOption
+	order  []int
+	pos    int
+	rc     io.ReadCloser
+	offset int64
+	limit  int64
+	read   int64
+	err    error // read error to fail over from on the next Read
+	closed bool
+	mu     sync.Mutex
+}
+
+func (f *failoverReader) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, omnistorage.ErrReaderClosed
+	}
+
+	for {
+		err := f.err
+		f.err = nil
+		if err == nil {
+			var n int
+			n, err = f.rc.Read(p)
+			f.read += int64(n)
+			if err == nil || err == io.EOF {
+				return n, err
+			}
+			if n > 0 {
+				f.err = err
+				return n, nil
+			}
+		}
+
+		f.parent.recordFailure(f.order[f.pos], err)
+		if f.ctx.Err() != nil || f.pos+1 >= len(f.order) {
+			return 0, err
+		}
+
+		// Resume on the next backend where this one left off.
+		opts := append(append([]omnistorage.ReaderOption(nil), f.opts...), omnistorage.WithOffset(f.offset+f.read))
+		if f.limit > 0 {
+			opts = append(opts, omnistorage.WithLimit(f.limit-f.read))
+		}
+		rc, pos, openErr := f.parent.openFrom(f.ctx, f.path, f.order[f.pos+1:], opts)
+		if openErr != nil {
+			return 0, err
+		}
+		_ = f.rc.Close()
+		f.rc = rc
+		f.pos += pos + 1
+	}
+}
+
+func (f *failoverReader) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	return f.rc.Close()
+}
+
+// readError combines per-backend errors. If every backend reported the
+// object missing, the result is omnistorage.ErrNotFound.
+func readError(errs []error) error {
+	if len(errs) == 0 {
+		return omnistorage.ErrNotFound
+	}
+	for _, err := range errs {
+		if !omnistorage.IsNotFound(err) {
+			return &MultiError{Errors: errs}
+		}
+	}
+	return omnistorage.ErrNotFound
+}
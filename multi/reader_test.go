@@ -0,0 +1,356 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func putObject(t *testing.T, b omnistorage.Backend, path, content string) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func readAll(t *testing.T, r interface {
+	NewReader(context.Context, string, ...omnistorage.ReaderOption) (io.ReadCloser, error)
+}, path string) string {
+	t.Helper()
+	rc, err := r.NewReader(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	return string(data)
+}
+
+// flakyBackend wraps a backend and fails reads after a number of bytes.
+type flakyBackend struct {
+	omnistorage.Backend
+	openErr   error
+	failAfter int
+	withData  bool // return the read error with the last data
+	delay     time.Duration
+}
+
+func (f *flakyBackend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if f.openErr != nil {
+		return nil, f.openErr
+	}
+	rc, err := f.Backend.NewReader(ctx, path, opts...)
+	if err != nil || f.failAfter <= 0 {
+		return rc, err
+	}
+	return &flakyReader{ReadCloser: rc, remaining: f.failAfter, withData: f.withData}, nil
+}
+
+type flakyReader struct {
+	io.ReadCloser
+	remaining int
+	withData  bool
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.remaining <= 0 {
+		return 0, errors.New("connection reset")
+	}
+	if len(p) > f.remaining {
+		p = p[:f.remaining]
+	}
+	n, err := f.ReadCloser.Read(p)
+	f.remaining -= n
+	if f.withData && f.remaining <= 0 && err == nil {
+		err = errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestNewReaderNoBackends(t *testing.T) {
+	if _, err := NewReader(); err == nil {
+		t.Error("NewReader with no backends should fail")
+	}
+	if _, err := NewReader(nil); err == nil {
+		t.Error("NewReader with only nil backends should fail")
+	}
+}
+
+func TestReaderPriority(t *testing.T) {
+	b1 := memory.New()
+	b2 := memory.New()
+	putObject(t, b1, "file.txt", "primary")
+	putObject(t, b2, "file.txt", "secondary")
+
+	mr, err := NewReader(b1, b2)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+
+	if got := readAll(t, mr, "file.txt"); got != "primary" {
+		t.Errorf("Read = %q, want %q", got, "primary")
+	}
+}
+
+func TestReaderFailoverNotFound(t *testing.T) {
+	b1 := memory.New()
+	b2 := memory.New()
+	putObject(t, b2, "file.txt", "secondary")
+
+	mr, _ := NewReader(b1, b2)
+	if got := readAll(t, mr, "file.txt"); got != "secondary" {
+		t.Errorf("Read = %q, want %q", got, "secondary")
+	}
+
+	// Not-found does not count against health
+	if h := mr.Health()[0]; h.ConsecutiveFailures != 0 || !h.Healthy {
+		t.Errorf("Health[0] = %+v, want healthy with no failures", h)
+	}
+}
+
+func TestReaderAllNotFound(t *testing.T) {
+	mr, _ := NewReader(memory.New(), memory.New())
+	_, err := mr.NewReader(context.Background(), "missing.txt")
+	if !omnistorage.IsNotFound(err) {
+		t.Errorf("NewReader error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReaderFailoverError(t *testing.T) {
+	b2 := memory.New()
+	putObject(t, b2, "file.txt", "secondary")
+
+	broken := &failingBackend{fail: true}
+	mr, _ := NewReaderWithOptions([]omnistorage.Backend{broken, b2}, WithFailureThreshold(2))
+
+	for i := 0; i < 2; i++ {
+		if got := readAll(t, mr, "file.txt"); got != "secondary" {
+			t.Errorf("Read = %q, want %q", got, "secondary")
+		}
+	}
+
+	health := mr.Health()
+	if health[0].Healthy || health[0].ConsecutiveFailures != 2 || health[0].LastError == nil {
+		t.Errorf("Health[0] = %+v, want unhealthy after 2 failures", health[0])
+	}
+	if !health[1].Healthy {
+		t.Errorf("Health[1] = %+v, want healthy", health[1])
+	}
+
+	// Unhealthy backend is demoted behind healthy ones
+	if order := mr.order(); order[0] != 1 || order[1] != 0 {
+		t.Errorf("order = %v, want [1 0]", order)
+	}
+}
+
+func TestReaderCooldown(t *testing.T) {
+	b2 := memory.New()
+	putObject(t, b2, "file.txt", "x")
+
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{&failingBackend{fail: true}, b2},
+		WithFailureThreshold(1),
+		WithCooldown(10*time.Millisecond),
+	)
+	readAll(t, mr, "file.txt")
+	if mr.Health()[0].Healthy {
+		t.Fatal("backend should be unhealthy")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !mr.Health()[0].Healthy {
+		t.Error("backend should be healthy again after cooldown")
+	}
+}
+
+func TestReaderMidStreamFailover(t *testing.T) {
+	content := "0123456789abcdefghij"
+	b1 := memory.New()
+	b2 := memory.New()
+	putObject(t, b1, "file.txt", content)
+	putObject(t, b2, "file.txt", content)
+
+	mr, _ := NewReader(&flakyBackend{Backend: b1, failAfter: 7}, b2)
+	if got := readAll(t, mr, "file.txt"); got != content {
+		t.Errorf("Read = %q, want %q", got, content)
+	}
+	if mr.Health()[0].ConsecutiveFailures != 1 {
+		t.Errorf("Health[0].ConsecutiveFailures = %d, want 1", mr.Health()[0].ConsecutiveFailures)
+	}
+}
+
+func TestReaderMidStreamErrorWithData(t *testing.T) {
+	content := "0123456789abcdefghij"
+	b1 := memory.New()
+	b2 := memory.New()
+	putObject(t, b1, "file.txt", content)
+	putObject(t, b2, "file.txt", content)
+
+	mr, _ := NewReader(&flakyBackend{Backend: b1, failAfter: 7, withData: true}, b2)
+	if got := readAll(t, mr, "file.txt"); got != content {
+		t.Errorf("Read = %q, want %q", got, content)
+	}
+	if mr.Health()[0].ConsecutiveFailures != 1 {
+		t.Errorf("Health[0].ConsecutiveFailures = %d, want 1", mr.Health()[0].ConsecutiveFailures)
+	}
+}
+
+func TestReaderMidStreamFailoverWithRange(t *testing.T) {
+	content := "0123456789abcdefghij"
+	b1 := memory.New()
+	b2 := memory.New()
+	putObject(t, b1, "file.txt", content)
+	putObject(t, b2, "file.txt", content)
+
+	mr, _ := NewReader(&flakyBackend{Backend: b1, failAfter: 3}, b2)
+	rc, err := mr.NewReader(context.Background(), "file.txt", omnistorage.WithOffset(5), omnistorage.WithLimit(10))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != content[5:15] {
+		t.Errorf("Read = %q, want %q", data, content[5:15])
+	}
+}
+
+func TestReaderHedged(t *testing.T) {
+	b1 := memory.New()
+	b2 := memory.New()
+	putObject(t, b1, "file.txt", "slow")
+	putObject(t, b2, "file.txt", "fast")
+
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{&flakyBackend{Backend: b1, delay: time.Second}, b2},
+		WithReadStrategy(ReadHedged),
+		WithHedgeDelay(5*time.Millisecond),
+	)
+
+	start := time.Now()
+	if got := readAll(t, mr, "file.txt"); got != "fast" {
+		t.Errorf("Read = %q, want %q", got, "fast")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("hedged read took %v, want well under the slow backend's delay", elapsed)
+	}
+}
+
+func TestReaderHedgedFailover(t *testing.T) {
+	b2 := memory.New()
+	putObject(t, b2, "file.txt", "ok")
+
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{&flakyBackend{Backend: memory.New(), openErr: errors.New("down")}, b2},
+		WithReadStrategy(ReadHedged),
+		WithHedgeDelay(time.Hour),
+	)
+	if got := readAll(t, mr, "file.txt"); got != "ok" {
+		t.Errorf("Read = %q, want %q", got, "ok")
+	}
+
+	_, err := mr.NewReader(context.Background(), "missing.txt")
+	if err == nil {
+		t.Error("NewReader of missing object should fail")
+	}
+}
+
+func TestReaderRoundRobin(t *testing.T) {
+	var backends []omnistorage.Backend
+	for _, name := range []string{"a", "b", "c"} {
+		b := memory.New()
+		putObject(t, b, "file.txt", name)
+		backends = append(backends, b)
+	}
+	backends = append(backends, &flakyBackend{Backend: memory.New(), openErr: errors.New("down")})
+
+	mr, _ := NewReaderWithOptions(backends, WithReadStrategy(ReadRoundRobin), WithFailureThreshold(1))
+
+	var got []string
+	for range 7 {
+		got = append(got, readAll(t, mr, "file.txt"))
+	}
+	// The failing backend's turn fails over to "a", then it is skipped
+	want := []string{"a", "b", "c", "a", "b", "c", "a"}
+	if !slices.Equal(got, want) {
+		t.Errorf("reads = %v, want %v", got, want)
+	}
+}
+
+func TestReaderLatency(t *testing.T) {
+	b1 := memory.New()
+	b2 := memory.New()
+	putObject(t, b1, "file.txt", "slow")
+	putObject(t, b2, "file.txt", "fast")
+
+	mr, _ := NewReaderWithOptions(
+		[]omnistorage.Backend{&flakyBackend{Backend: b1, delay: 20 * time.Millisecond}, b2},
+		WithReadStrategy(ReadLatency),
+	)
+
+	// Unmeasured backends are tried first: b1, then b2
+	if got := readAll(t, mr, "file.txt"); got != "slow" {
+		t.Errorf("first Read = %q, want %q", got, "slow")
+	}
+	for range 3 {
+		if got := readAll(t, mr, "file.txt"); got != "fast" {
+			t.Errorf("Read = %q, want %q", got, "fast")
+		}
+	}
+
+	h := mr.Health()
+	if h[0].Latency < 20*time.Millisecond || h[1].Latency <= 0 || h[1].Latency >= h[0].Latency {
+		t.Errorf("Latency = %v, %v; want the slow backend slower", h[0].Latency, h[1].Latency)
+	}
+}
+
+func TestReaderExistsAndList(t *testing.T) {
+	b1 := memory.New()
+	b2 := memory.New()
+	putObject(t, b2, "dir/a.txt", "a")
+
+	mr, _ := NewReader(&failingBackend{fail: true}, b1, b2)
+
+	exists, err := mr.Exists(context.Background(), "dir/a.txt")
+	if err != nil || !exists {
+		t.Errorf("Exists = %v, %v; want true, nil", exists, err)
+	}
+	exists, err = mr.Exists(context.Background(), "dir/missing.txt")
+	if err != nil || exists {
+		t.Errorf("Exists missing = %v, %v; want false, nil", exists, err)
+	}
+
+	// List answers from the first healthy backend (b1 is empty)
+	paths, err := mr.List(context.Background(), "dir/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("List = %v, want empty list from first answering backend", paths)
+	}
+}
@@ -0,0 +1,312 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// RepairOption configures Repair.
+type RepairOption func(*repairConfig)
+
+type repairConfig struct {
+	hashType omnistorage.HashType
+	sizeOnly bool
+	dryRun   bool
+}
+
+// WithRepairHash sets the hash used to compare replicas. The hash is taken
+// from Stat when the backend reports it and computed by reading the object
+// otherwise. Defaults to omnistorage.HashMD5.
+func WithRepairHash(t omnistorage.HashType) RepairOption {
+	return func(c *repairConfig) {
+		c.hashType = t
+	}
+}
+
+// WithRepairSizeOnly compares replicas by size only, without hashing.
+func WithRepairSizeOnly(sizeOnly bool) RepairOption {
+	return func(c *repairConfig) {
+		c.sizeOnly = sizeOnly
+	}
+}
+
+// WithRepairDryRun reports what would be repaired without copying anything.
+func WithRepairDryRun(dryRun bool) RepairOption {
+	return func(c *repairConfig) {
+		c.dryRun = dryRun
+	}
+}
+
+// RepairReport describes the result of a Repair run.
+type RepairReport struct {
+	// Objects is the number of distinct paths examined across all backends.
+	Objects int
+
+	// Backends has one entry per backend, in the order they were given.
+	Backends []BackendRepair
+
+	// Unresolved lists paths where no replica could be chosen as the source
+	// of truth, because every replica failed to be read.
+	Unresolved []string
+}
+
+// BackendRepair describes the repairs made to a single backend.
+type BackendRepair struct {
+	// Missing lists paths absent on this backend but present elsewhere.
+	Missing []string
+
+	// Divergent lists paths whose size or hash differs from the chosen replica.
+	Divergent []string
+
+	// Repaired lists paths successfully re-copied to this backend.
+	// Empty in dry-run mode.
+	Repaired []string
+
+	// Errors holds per-path failures on this backend.
+	Errors []RepairError
+}
+
+// RepairError records a failure to inspect or repair a path on a backend.
+type RepairError struct {
+	Path string
+	Err  error
+}
+
+// Error implements the error interface.
+func (e RepairError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e RepairError) Unwrap() error {
+	return e.Err
+}
+
+// OK reports whether every backend is consistent (nothing missing,
+// divergent or failed) after the run.
+func (r *RepairReport) OK() bool {
+	if len(r.Unresolved) > 0 {
+		return false
+	}
+	for _, b := range r.Backends {
+		if len(b.Errors) > 0 || len(b.Missing)+len(b.Divergent) != len(b.Repaired) {
+			return false
+		}
+	}
+	return true
+}
+
+// replica is the observed state of one path on one backend.
+type replica struct {
+	present bool
+	size    int64
+	hash    string
+	modTime time.Time
+	err     error
+}
+
+func (r replica) fingerprint() string {
+	return fmt.Sprintf("%d:%s", r.size, r.hash)
+}
+
+// Repair compares the replicas of every object under prefix across backends
+// and re-copies objects that are missing or divergent, so replicas written
+// with WriteBestEffort or WriteQuorum converge again.
+//
+// For each path, the replicas are grouped by size and hash, and the largest
+// group is taken as the source of truth. Ties are broken by the most recent
+// modification time when backends support Stat, and then by backend order.
+// Backends that cannot be listed are skipped and their error is recorded in
+// their report entry.
+//
+// Repair only adds or overwrites objects; it never deletes.
+func Repair(ctx context.Context, backends []omnistorage.Backend, prefix string, opts ...RepairOption) (*RepairReport, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("at least one backend is required")
+	}
+
+	cfg := repairConfig{hashType: omnistorage.HashMD5}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if !cfg.sizeOnly && omnistorage.NewHash(cfg.hashType) == nil {
+		return nil, fmt.Errorf("unsupported hash type %q: %w", cfg.hashType, omnistorage.ErrNotSupported)
+	}
+
+	report := &RepairReport{Backends: make([]BackendRepair, len(backends))}
+
+	// Union of paths across reachable backends
+	listed := make([]bool, len(backends))
+	seen := make(map[string]bool)
+	for i, b := range backends {
+		paths, err := b.List(ctx, prefix)
+		if err != nil {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			report.Backends[i].Errors = append(report.Backends[i].Errors, RepairError{Path: prefix, Err: err})
+			continue
+		}
+		listed[i] = true
+		for _, p := range paths {
+			seen[omnistorage.ListedPath(prefix, p)] = true
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	report.Objects = len(paths)
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		repairPath(ctx, backends, listed, path, cfg, report)
+	}
+
+	return report, nil
+}
+
+func repairPath(ctx context.Context, backends []omnistorage.Backend, listed []bool, path string, cfg repairConfig, report *RepairReport) {
+	replicas := make([]replica, len(backends))
+	for i, b := range backends {
+		if !listed[i] {
+			continue
+		}
+		replicas[i] = inspect(ctx, b, path, cfg)
+		if replicas[i].err != nil {
+			report.Backends[i].Errors = append(report.Backends[i].Errors, RepairError{Path: path, Err: replicas[i].err})
+		}
+	}
+
+	src := chooseSource(replicas)
+	if src < 0 {
+		report.Unresolved = append(report.Unresolved, path)
+		return
+	}
+	want := replicas[src].fingerprint()
+
+	for i, b := range backends {
+		r := replicas[i]
+		if !listed[i] || r.err != nil {
+			continue
+		}
+		br := &report.Backends[i]
+		switch {
+		case !r.present:
+			br.Missing = append(br.Missing, path)
+		case r.fingerprint() != want:
+			br.Divergent = append(br.Divergent, path)
+		default:
+			continue
+		}
+
+		if cfg.dryRun {
+			continue
+		}
+		if err := omnistorage.CopyPath(ctx, backends[src], path, b, path); err != nil {
+			br.Errors = append(br.Errors, RepairError{Path: path, Err: err})
+			continue
+		}
+		br.Repaired = append(br.Repaired, path)
+	}
+}
+
+// chooseSource returns the index of the replica to copy from, or -1 if no
+// replica is usable.
+func chooseSource(replicas []replica) int {
+	counts := make(map[string]int)
+	for _, r := range replicas {
+		if r.present && r.err == nil {
+			counts[r.fingerprint()]++
+		}
+	}
+
+	best := -1
+	for i, r := range replicas {
+		if !r.present || r.err != nil {
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+		b := replicas[best]
+		ci, cb := counts[r.fingerprint()], counts[b.fingerprint()]
+		if ci > cb || (ci == cb && r.modTime.After(b.modTime)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// inspect determines whether path exists on b and, if so, its size and hash.
+func inspect(ctx context.Context, b omnistorage.Backend, path string, cfg repairConfig) replica {
+	if ext, ok := omnistorage.AsExtended(b); ok {
+		info, err := ext.Stat(ctx, path)
+		switch {
+		case err == nil:
+			r := replica{present: true, size: info.Size(), modTime: info.ModTime()}
+			if cfg.sizeOnly {
+				return r
+			}
+			if h := info.Hash(cfg.hashType); h != "" {
+				r.hash = h
+				return r
+			}
+			return hashReplica(ctx, b, path, cfg, r)
+		case omnistorage.IsNotFound(err):
+			return replica{}
+		case !errors.Is(err, omnistorage.ErrNotSupported):
+			return replica{err: err}
+		}
+	}
+
+	exists, err := b.Exists(ctx, path)
+	if err != nil {
+		return replica{err: err}
+	}
+	if !exists {
+		return replica{}
+	}
+	return hashReplica(ctx, b, path, cfg, replica{present: true, size: -1})
+}
+
+// hashReplica reads the object to compute its size and hash.
+func hashReplica(ctx context.Context, b omnistorage.Backend, path string, cfg repairConfig, r replica) replica {
+	rc, err := b.NewReader(ctx, path)
+	if err != nil {
+		if omnistorage.IsNotFound(err) {
+			return replica{}
+		}
+		return replica{err: err}
+	}
+	defer func() { _ = rc.Close() }()
+
+	if cfg.sizeOnly {
+		n, err := io.Copy(io.Discard, rc)
+		if err != nil {
+			return replica{err: err}
+		}
+		r.size = n
+		return r
+	}
+
+	h := omnistorage.NewHash(cfg.hashType)
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		return replica{err: err}
+	}
+	r.size = n
+	r.hash = omnistorage.HashBytesFromSum(h.Sum(nil))
+	return r
+}
@@ -0,0 +1,134 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestRepairMissingAndDivergent(t *testing.T) {
+	ctx := context.Background()
+	b1, b2, b3 := memory.New(), memory.New(), memory.New()
+
+	// a.txt is missing from b3
+	putObject(t, b1, "data/a.txt", "alpha")
+	putObject(t, b2, "data/a.txt", "alpha")
+
+	// b.txt diverges on b2; the majority wins
+	putObject(t, b1, "data/b.txt", "bravo")
+	putObject(t, b2, "data/b.txt", "stale")
+	putObject(t, b3, "data/b.txt", "bravo")
+
+	// outside the prefix
+	putObject(t, b1, "other/c.txt", "charlie")
+
+	report, err := Repair(ctx, []omnistorage.Backend{b1, b2, b3}, "data/")
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+
+	if report.Objects != 2 {
+		t.Errorf("Objects = %d, want 2", report.Objects)
+	}
+	if got := report.Backends[2].Missing; len(got) != 1 || got[0] != "data/a.txt" {
+		t.Errorf("Backends[2].Missing = %v, want [data/a.txt]", got)
+	}
+	if got := report.Backends[1].Divergent; len(got) != 1 || got[0] != "data/b.txt" {
+		t.Errorf("Backends[1].Divergent = %v, want [data/b.txt]", got)
+	}
+	if len(report.Backends[0].Missing)+len(report.Backends[0].Divergent) != 0 {
+		t.Errorf("Backends[0] = %+v, want no repairs", report.Backends[0])
+	}
+	if !report.OK() {
+		t.Errorf("report not OK: %+v", report)
+	}
+
+	if got := readAll(t, b3, "data/a.txt"); got != "alpha" {
+		t.Errorf("b3 data/a.txt = %q, want %q", got, "alpha")
+	}
+	if got := readAll(t, b2, "data/b.txt"); got != "bravo" {
+		t.Errorf("b2 data/b.txt = %q, want %q", got, "bravo")
+	}
+	if exists, _ := b2.Exists(ctx, "other/c.txt"); exists {
+		t.Error("Repair should not copy objects outside the prefix")
+	}
+
+	// A second run finds nothing to do
+	report, err = Repair(ctx, []omnistorage.Backend{b1, b2, b3}, "data/")
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	for i, br := range report.Backends {
+		if len(br.Missing)+len(br.Divergent)+len(br.Repaired) != 0 {
+			t.Errorf("second run Backends[%d] = %+v, want no repairs", i, br)
+		}
+	}
+}
+
+func TestRepairDryRun(t *testing.T) {
+	ctx := context.Background()
+	b1, b2 := memory.New(), memory.New()
+	putObject(t, b1, "a.txt", "alpha")
+
+	report, err := Repair(ctx, []omnistorage.Backend{b1, b2}, "", WithRepairDryRun(true))
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if len(report.Backends[1].Missing) != 1 || len(report.Backends[1].Repaired) != 0 {
+		t.Errorf("Backends[1] = %+v, want one missing, none repaired", report.Backends[1])
+	}
+	if report.OK() {
+		t.Error("dry-run report should not be OK while replicas differ")
+	}
+	if exists, _ := b2.Exists(ctx, "a.txt"); exists {
+		t.Error("dry run should not copy")
+	}
+}
+
+func TestRepairSizeOnly(t *testing.T) {
+	ctx := context.Background()
+	b1, b2 := memory.New(), memory.New()
+	putObject(t, b1, "a.txt", "aaaa")
+	putObject(t, b2, "a.txt", "bbbb")
+
+	report, err := Repair(ctx, []omnistorage.Backend{b1, b2}, "", WithRepairSizeOnly(true))
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	for i, br := range report.Backends {
+		if len(br.Divergent) != 0 {
+			t.Errorf("Backends[%d].Divergent = %v, want none with size-only comparison", i, br.Divergent)
+		}
+	}
+}
+
+func TestRepairUnlistableBackend(t *testing.T) {
+	ctx := context.Background()
+	b1, b2 := memory.New(), memory.New()
+	putObject(t, b1, "a.txt", "alpha")
+
+	report, err := Repair(ctx, []omnistorage.Backend{b1, &failingBackend{fail: true}, b2}, "")
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if len(report.Backends[1].Errors) != 1 {
+		t.Errorf("Backends[1].Errors = %v, want one list error", report.Backends[1].Errors)
+	}
+	if len(report.Backends[2].Repaired) != 1 {
+		t.Errorf("Backends[2].Repaired = %v, want [a.txt]", report.Backends[2].Repaired)
+	}
+}
+
+func TestRepairErrors(t *testing.T) {
+	if _, err := Repair(context.Background(), nil, ""); err == nil {
+		t.Error("Repair with no backends should fail")
+	}
+
+	_, err := Repair(context.Background(), []omnistorage.Backend{memory.New()}, "", WithRepairHash("bogus"))
+	if !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Repair with bogus hash: error = %v, want ErrNotSupported", err)
+	}
+}
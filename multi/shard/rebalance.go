@@ -0,0 +1,199 @@
+package shard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Misplacement describes an object stored on a node that does not own it,
+// or an owner that is missing a replica.
+type Misplacement struct {
+	// Path is the object path.
+	Path string
+
+	// Holders lists the nodes currently storing the object.
+	Holders []string
+
+	// Owners lists the nodes that should store the object.
+	Owners []string
+}
+
+// RebalanceOption configures Rebalance.
+type RebalanceOption func(*rebalanceConfig)
+
+type rebalanceConfig struct {
+	dryRun     bool
+	keepSource bool
+}
+
+// WithDryRun reports what Rebalance would do without copying or deleting.
+func WithDryRun(dryRun bool) RebalanceOption {
+	return func(c *rebalanceConfig) {
+		c.dryRun = dryRun
+	}
+}
+
+// WithKeepSource leaves objects on nodes that no longer own them after
+// copying them to their owners.
+func WithKeepSource(keep bool) RebalanceOption {
+	return func(c *rebalanceConfig) {
+		c.keepSource = keep
+	}
+}
+
+// RebalanceReport describes the result of a Rebalance run.
+type RebalanceReport struct {
+	// Misplaced lists the objects that needed to move.
+	Misplaced []Misplacement
+
+	// Copied is the number of replicas copied to an owner.
+	Copied int
+
+	// Deleted is the number of replicas removed from non-owners.
+	Deleted int
+
+	// Errors holds per-object failures.
+	Errors []error
+}
+
+// Misplaced lists objects under prefix whose holders differ from their owners.
+// It lists every node, so it sees objects left behind by a previous layout.
+func (b *Backend) Misplaced(ctx context.Context, prefix string) ([]Misplacement, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	holders := make(map[string][]int)
+	for i, n := range b.nodes {
+		paths, err := n.Backend.List(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("node %q: %w", n.Name, err)
+		}
+		for _, p := range paths {
+			p = omnistorage.ListedPath(prefix, p)
+			holders[p] = append(holders[p], i)
+		}
+	}
+
+	paths := make([]string, 0, len(holders))
+	for p := range holders {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var out []Misplacement
+	for _, p := range paths {
+		owners := b.ring.Locate(p, b.replicas)
+		if sameNodes(holders[p], owners) {
+			continue
+		}
+		out = append(out, Misplacement{
+			Path:    p,
+			Holders: b.names(holders[p]),
+			Owners:  b.names(owners),
+		})
+	}
+	return out, nil
+}
+
+// Rebalance moves objects under prefix to the nodes that own them under the
+// current ring. Use it after adding, removing, or renaming nodes: construct
+// a Backend with the new node set (including any nodes being drained) and
+// call Rebalance before removing the old nodes.
+//
+// Each misplaced object is copied from one of its holders to every owner
+// that lacks it, then deleted from holders that are not owners.
+func (b *Backend) Rebalance(ctx context.Context, prefix string, opts ...RebalanceOption) (*RebalanceReport, error) {
+	var cfg rebalanceConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	misplaced, err := b.Misplaced(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RebalanceReport{Misplaced: misplaced}
+	if cfg.dryRun {
+		return report, nil
+	}
+
+	byName := make(map[string]omnistorage.Backend, len(b.nodes))
+	for _, n := range b.nodes {
+		byName[n.Name] = n.Backend
+	}
+
+	for _, m := range misplaced {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		src := byName[m.Holders[0]]
+		copyFailed := false
+		for _, owner := range m.Owners {
+			if contains(m.Holders, owner) {
+				continue
+			}
+			if err := omnistorage.CopyPath(ctx, src, m.Path, byName[owner], m.Path); err != nil {
+				report.Errors = append(report.Errors, fmt.Errorf("copy %s to %q: %w", m.Path, owner, err))
+				copyFailed = true
+				continue
+			}
+			report.Copied++
+		}
+
+		// Never delete the last good copy.
+		if copyFailed || cfg.keepSource {
+			continue
+		}
+		for _, holder := range m.Holders {
+			if contains(m.Owners, holder) {
+				continue
+			}
+			if err := byName[holder].Delete(ctx, m.Path); err != nil {
+				report.Errors = append(report.Errors, fmt.Errorf("delete %s from %q: %w", m.Path, holder, err))
+				continue
+			}
+			report.Deleted++
+		}
+	}
+
+	return report, nil
+}
+
+func (b *Backend) names(idx []int) []string {
+	out := make([]string, len(idx))
+	for i, n := range idx {
+		out[i] = b.nodes[n].Name
+	}
+	return out
+}
+
+func sameNodes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[int]bool, len(a))
+	for _, x := range a {
+		set[x] = true
+	}
+	for _, x := range b {
+		if !set[x] {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package shard
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// DefaultVirtualNodes is the default number of points each node owns on the
+// hash ring. More points give a more even key distribution.
+const DefaultVirtualNodes = 128
+
+// Ring is a consistent-hash ring mapping keys to named nodes.
+// Adding or removing a node only moves the keys owned by that node.
+// A Ring is immutable and safe for concurrent use.
+type Ring struct {
+	nodes  []string
+	points []uint64
+	owners map[uint64]int
+}
+
+// NewRing creates a ring over the given node names, each placed at
+// virtualNodes points. Names must be unique; if virtualNodes <= 0,
+// DefaultVirtualNodes is used.
+func NewRing(names []string, virtualNodes int) *Ring {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+
+	r := &Ring{
+		nodes:  append([]string(nil), names...),
+		points: make([]uint64, 0, len(names)*virtualNodes),
+		owners: make(map[uint64]int, len(names)*virtualNodes),
+	}
+	for i, name := range names {
+		for v := 0; v < virtualNodes; v++ {
+			p := hashKey(name + "#" + strconv.Itoa(v))
+			if _, taken := r.owners[p]; taken {
+				continue
+			}
+			r.owners[p] = i
+			r.points = append(r.points, p)
+		}
+	}
+	sort.Slice(r.points, func(a, b int) bool { return r.points[a] < r.points[b] })
+	return r
+}
+
+// Nodes returns the node names in the order given to NewRing.
+func (r *Ring) Nodes() []string {
+	return append([]string(nil), r.nodes...)
+}
+
+// Locate returns the indexes of the n distinct nodes responsible for key,
+// in preference order. n is capped at the number of nodes.
+func (r *Ring) Locate(key string, n int) []int {
+	if n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+	if n <= 0 || len(r.points) == 0 {
+		return nil
+	}
+
+	h := hashKey(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+
+	out := make([]int, 0, n)
+	seen := make(map[int]bool, n)
+	for i := 0; len(out) < n && i < len(r.points); i++ {
+		owner := r.owners[r.points[(start+i)%len(r.points)]]
+		if !seen[owner] {
+			seen[owner] = true
+			out = append(out, owner)
+		}
+	}
+	return out
+}
+
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return mix64(h.Sum64())
+}
+
+// mix64 spreads FNV output across the ring; FNV alone clusters keys that
+// share long prefixes.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,306 @@
+// Package shard provides a backend that distributes objects across several
+// child backends using consistent hashing.
+//
+// Sharding spreads request load and capacity over multiple buckets or
+// servers. Each path is owned by one node (or several, with a replication
+// factor), chosen by a consistent-hash ring so that adding or removing a
+// node only moves the objects that node gains or loses.
+//
+// Example usage:
+//
+//	b, _ := shard.New([]shard.Node{
+//		{Name: "bucket-a", Backend: s3a},
+//		{Name: "bucket-b", Backend: s3b},
+//		{Name: "bucket-c", Backend: s3c},
+//	}, shard.WithReplicas(2))
+//
+//	w, _ := b.NewWriter(ctx, "data/file.json")
+//	w.Write([]byte(`{"key": "value"}`))
+//	w.Close()
+//
+// After changing the set of nodes, call Rebalance to move existing objects
+// to their new owners.
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/multi"
+)
+
+// Node is a named child backend. The name determines the node's position
+// on the hash ring and must stay the same across restarts; renaming a node
+// moves its objects.
+type Node struct {
+	Name    string
+	Backend omnistorage.Backend
+}
+
+// Backend distributes objects across child backends by consistent hashing.
+type Backend struct {
+	nodes        []Node
+	ring         *Ring
+	replicas     int
+	virtualNodes int
+	closed       bool
+	mu           sync.RWMutex
+}
+
+// Option configures a shard backend.
+type Option func(*Backend)
+
+// WithReplicas sets the replication factor: the number of distinct nodes
+// each object is written to. Defaults to 1.
+func WithReplicas(n int) Option {
+	return func(b *Backend) {
+		b.replicas = n
+	}
+}
+
+// WithVirtualNodes sets the number of ring points per node.
+// Defaults to DefaultVirtualNodes.
+func WithVirtualNodes(n int) Option {
+	return func(b *Backend) {
+		b.virtualNodes = n
+	}
+}
+
+// New creates a shard backend over the given nodes.
+// At least one node must be provided, and node names must be unique.
+func New(nodes []Node, opts ...Option) (*Backend, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("at least one node is required")
+	}
+
+	names := make([]string, len(nodes))
+	seen := make(map[string]bool, len(nodes))
+	for i, n := range nodes {
+		if n.Backend == nil {
+			return nil, fmt.Errorf("node %q has no backend", n.Name)
+		}
+		if seen[n.Name] {
+			return nil, fmt.Errorf("duplicate node name %q", n.Name)
+		}
+		seen[n.Name] = true
+		names[i] = n.Name
+	}
+
+	b := &Backend{
+		nodes:        append([]Node(nil), nodes...),
+		replicas:     1,
+		virtualNodes: DefaultVirtualNodes,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if b.replicas < 1 || b.replicas > len(nodes) {
+		return nil, fmt.Errorf("replication factor %d out of range [1, %d]", b.replicas, len(nodes))
+	}
+
+	b.ring = NewRing(names, b.virtualNodes)
+	return b, nil
+}
+
+// Nodes returns the configured nodes.
+func (b *Backend) Nodes() []Node {
+	return append([]Node(nil), b.nodes...)
+}
+
+// Replicas returns the replication factor.
+func (b *Backend) Replicas() int {
+	return b.replicas
+}
+
+// Locate returns the names of the nodes that own path, in preference order.
+func (b *Backend) Locate(path string) []string {
+	owners := b.ring.Locate(cleanPath(path), b.replicas)
+	names := make([]string, len(owners))
+	for i, o := range owners {
+		names[i] = b.nodes[o].Name
+	}
+	return names
+}
+
+// owners returns the backends that own path, in preference order.
+func (b *Backend) owners(path string) []omnistorage.Backend {
+	idx := b.ring.Locate(cleanPath(path), b.replicas)
+	out := make([]omnistorage.Backend, len(idx))
+	for i, o := range idx {
+		out[i] = b.nodes[o].Backend
+	}
+	return out
+}
+
+// cleanPath returns p as the ring hashes it, without a leading slash or
+// "." and ".." elements, so "/a/b.txt" and "a/./b.txt" have one owner.
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// NewWriter creates a writer on every node that owns path.
+// With a replication factor above 1, all owners must succeed.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, omnistorage.ErrInvalidPath
+	}
+
+	owners := b.owners(path)
+	if len(owners) == 1 {
+		return owners[0].NewWriter(ctx, path, opts...)
+	}
+
+	mw, err := multi.NewWriter(owners...)
+	if err != nil {
+		return nil, err
+	}
+	return mw.NewWriter(ctx, path, opts...)
+}
+
+// NewReader opens path on its owners, failing over between replicas.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, omnistorage.ErrInvalidPath
+	}
+
+	owners := b.owners(path)
+	if len(owners) == 1 {
+		return owners[0].NewReader(ctx, path, opts...)
+	}
+
+	mr, err := multi.NewReader(owners...)
+	if err != nil {
+		return nil, err
+	}
+	return mr.NewReader(ctx, path, opts...)
+}
+
+// Exists reports whether path exists on any of its owners.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	if err := b.checkClosed(); err != nil {
+		return false, err
+	}
+
+	var lastErr error
+	for _, o := range b.owners(path) {
+		exists, err := o.Exists(ctx, path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if exists {
+			return true, nil
+		}
+	}
+	return false, lastErr
+}
+
+// Delete removes path from all of its owners.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	if err := b.checkClosed(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, o := range b.owners(path) {
+		if err := o.Delete(ctx, path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &multi.MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// List returns the sorted, de-duplicated union of paths with the given
+// prefix across all nodes.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := b.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		paths []string
+		err   error
+	}
+	results := make([]result, len(b.nodes))
+
+	var wg sync.WaitGroup
+	for i, n := range b.nodes {
+		wg.Add(1)
+		go func(i int, be omnistorage.Backend) {
+			defer wg.Done()
+			paths, err := be.List(ctx, prefix)
+			results[i] = result{paths: paths, err: err}
+		}(i, n.Backend)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	var paths []string
+	for i, r := range results {
+		if r.err != nil {
+			return nil, fmt.Errorf("node %q: %w", b.nodes[i].Name, r.err)
+		}
+		for _, p := range r.paths {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Close closes all child backends.
+func (b *Backend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+
+	var errs []error
+	for _, n := range b.nodes {
+		if err := n.Backend.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &multi.MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// checkClosed returns an error if the backend is closed.
+func (b *Backend) checkClosed() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return omnistorage.ErrBackendClosed
+	}
+	return nil
+}
+
+// Ensure Backend implements omnistorage.Backend
+var _ omnistorage.Backend = (*Backend)(nil)
@@ -0,0 +1,292 @@
+package shard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		b, err := New(newNodes("a", "b", "c"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	})
+}
+
+func newNodes(names ...string) []Node {
+	nodes := make([]Node, len(names))
+	for i, n := range names {
+		nodes[i] = Node{Name: n, Backend: memory.New()}
+	}
+	return nodes
+}
+
+func put(t *testing.T, b omnistorage.Backend, path, content string) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewWriter(%s) failed: %v", path, err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func get(t *testing.T, b omnistorage.Backend, path string) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewReader(%s) failed: %v", path, err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestRingLocate(t *testing.T) {
+	r := NewRing([]string{"a", "b", "c"}, 0)
+
+	owners := r.Locate("some/key", 2)
+	if len(owners) != 2 || owners[0] == owners[1] {
+		t.Errorf("Locate = %v, want 2 distinct nodes", owners)
+	}
+	if got := r.Locate("some/key", 10); len(got) != 3 {
+		t.Errorf("Locate capped = %v, want 3 nodes", got)
+	}
+	if got := r.Locate("some/key", 1); got[0] != owners[0] {
+		t.Errorf("Locate is not stable: %v vs %v", got, owners)
+	}
+}
+
+func TestRingDistributionAndStability(t *testing.T) {
+	const keys = 3000
+	before := NewRing([]string{"a", "b", "c"}, 0)
+	after := NewRing([]string{"a", "b", "c", "d"}, 0)
+
+	counts := make(map[int]int)
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("objects/%06d.json", i)
+		o1 := before.Locate(key, 1)[0]
+		o2 := after.Locate(key, 1)[0]
+		counts[o1]++
+		if o1 != o2 {
+			moved++
+			if o2 != 3 {
+				t.Fatalf("key %s moved between existing nodes %d -> %d", key, o1, o2)
+			}
+		}
+	}
+
+	for node, n := range counts {
+		if n < keys/6 {
+			t.Errorf("node %d owns %d of %d keys, distribution too uneven", node, n, keys)
+		}
+	}
+	// Roughly 1/4 of keys should move to the new node
+	if moved < keys/8 || moved > keys/2 {
+		t.Errorf("%d of %d keys moved after adding a node, want about a quarter", moved, keys)
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	if _, err := New(nil); err == nil {
+		t.Error("New with no nodes should fail")
+	}
+	if _, err := New([]Node{{Name: "a"}}); err == nil {
+		t.Error("New with nil backend should fail")
+	}
+	if _, err := New(append(newNodes("a"), newNodes("a")...)); err == nil {
+		t.Error("New with duplicate names should fail")
+	}
+	if _, err := New(newNodes("a", "b"), WithReplicas(3)); err == nil {
+		t.Error("New with replicas > nodes should fail")
+	}
+}
+
+func TestShardReadWrite(t *testing.T) {
+	nodes := newNodes("a", "b", "c")
+	b, err := New(nodes)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for i := 0; i < 30; i++ {
+		put(t, b, fmt.Sprintf("k/%d", i), fmt.Sprintf("v%d", i))
+	}
+
+	used := 0
+	for _, n := range nodes {
+		if n.Backend.(*memory.Backend).Count() > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("objects landed on %d nodes, want them spread out", used)
+	}
+
+	for i := 0; i < 30; i++ {
+		path := fmt.Sprintf("k/%d", i)
+		if got := get(t, b, path); got != fmt.Sprintf("v%d", i) {
+			t.Errorf("%s = %q", path, got)
+		}
+		owner := b.Locate(path)[0]
+		for _, n := range nodes {
+			exists, _ := n.Backend.Exists(context.Background(), path)
+			if exists != (n.Name == owner) {
+				t.Errorf("%s on node %s: exists=%v, owner=%s", path, n.Name, exists, owner)
+			}
+		}
+	}
+
+	paths, err := b.List(context.Background(), "k/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(paths) != 30 {
+		t.Errorf("List returned %d paths, want 30", len(paths))
+	}
+
+	if err := b.Delete(context.Background(), "k/0"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if exists, _ := b.Exists(context.Background(), "k/0"); exists {
+		t.Error("k/0 should not exist after Delete")
+	}
+}
+
+func TestShardReplicas(t *testing.T) {
+	nodes := newNodes("a", "b", "c")
+	b, _ := New(nodes, WithReplicas(2))
+
+	put(t, b, "file.txt", "data")
+
+	owners := b.Locate("file.txt")
+	if len(owners) != 2 {
+		t.Fatalf("Locate = %v, want 2 owners", owners)
+	}
+
+	holders := 0
+	for _, n := range nodes {
+		if exists, _ := n.Backend.Exists(context.Background(), "file.txt"); exists {
+			holders++
+		}
+	}
+	if holders != 2 {
+		t.Errorf("object stored on %d nodes, want 2", holders)
+	}
+
+	// Reads fail over when the primary replica is lost
+	for _, n := range nodes {
+		if n.Name == owners[0] {
+			_ = n.Backend.Delete(context.Background(), "file.txt")
+		}
+	}
+	if got := get(t, b, "file.txt"); got != "data" {
+		t.Errorf("read after primary loss = %q, want %q", got, "data")
+	}
+
+	// List de-duplicates replicas
+	paths, _ := b.List(context.Background(), "")
+	if len(paths) != 1 {
+		t.Errorf("List = %v, want one path", paths)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	ctx := context.Background()
+	old := newNodes("a", "b")
+	b, _ := New(old)
+	for i := 0; i < 50; i++ {
+		put(t, b, fmt.Sprintf("k/%d", i), fmt.Sprintf("v%d", i))
+	}
+
+	grown, _ := New(append(old, newNodes("c")...))
+
+	misplaced, err := grown.Misplaced(ctx, "")
+	if err != nil {
+		t.Fatalf("Misplaced failed: %v", err)
+	}
+	if len(misplaced) == 0 {
+		t.Fatal("expected some objects to move to the new node")
+	}
+	for _, m := range misplaced {
+		if len(m.Owners) != 1 || m.Owners[0] != "c" {
+			t.Errorf("%s: owners %v, want [c]", m.Path, m.Owners)
+		}
+	}
+
+	dry, err := grown.Rebalance(ctx, "", WithDryRun(true))
+	if err != nil {
+		t.Fatalf("Rebalance dry run failed: %v", err)
+	}
+	if dry.Copied != 0 || len(dry.Misplaced) != len(misplaced) {
+		t.Errorf("dry run report = %+v", dry)
+	}
+
+	report, err := grown.Rebalance(ctx, "")
+	if err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if report.Copied != len(misplaced) || report.Deleted != len(misplaced) || len(report.Errors) != 0 {
+		t.Errorf("report = copied %d deleted %d errors %v, want %d moved", report.Copied, report.Deleted, report.Errors, len(misplaced))
+	}
+
+	if again, _ := grown.Misplaced(ctx, ""); len(again) != 0 {
+		t.Errorf("Misplaced after Rebalance = %v, want none", again)
+	}
+	for i := 0; i < 50; i++ {
+		path := fmt.Sprintf("k/%d", i)
+		if got := get(t, grown, path); got != fmt.Sprintf("v%d", i) {
+			t.Errorf("%s = %q after rebalance", path, got)
+		}
+	}
+}
+
+func TestRebalanceKeepSource(t *testing.T) {
+	ctx := context.Background()
+	old := newNodes("a")
+	b, _ := New(old)
+	for i := 0; i < 20; i++ {
+		put(t, b, fmt.Sprintf("k/%d", i), "v")
+	}
+
+	grown, _ := New(append(old, newNodes("b")...))
+	report, err := grown.Rebalance(ctx, "", WithKeepSource(true))
+	if err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if report.Deleted != 0 {
+		t.Errorf("Deleted = %d, want 0 with WithKeepSource", report.Deleted)
+	}
+	if old[0].Backend.(*memory.Backend).Count() != 20 {
+		t.Error("source node should keep all objects")
+	}
+}
+
+func TestClose(t *testing.T) {
+	b, _ := New(newNodes("a", "b"))
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := b.NewWriter(context.Background(), "x"); err != omnistorage.ErrBackendClosed {
+		t.Errorf("NewWriter after Close: error = %v, want ErrBackendClosed", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+}
@@ -1,4 +1,5 @@
-// Package multi provides fan-out writing to multiple backends simultaneously.
+// Package multi provides fan-out writing to, and failover reading from,
+// multiple backends.
 //
 // The multi-writer allows writing the same data to multiple storage backends
 // at once, useful for:
@@ -21,6 +22,14 @@
 //	w, _ := mw.NewWriter(ctx, "data/file.json")
 //	w.Write([]byte(`{"key": "value"}`))
 //	w.Close()
+//
+// The multi-reader reads the same data back with failover, trying backends
+// in priority order (or hedging requests across them) and tracking the
+// health of each backend:
+//
+//	mr, _ := multi.NewReader(local, s3Backend, gcsBackend)
+//	r, _ := mr.NewReader(ctx, "data/file.json")
+//	defer r.Close()
 package multi
 
 import (
@@ -46,12 +55,26 @@ const (
 
 	// WriteQuorum requires a majority of backends to succeed.
 	WriteQuorum
+
+	// WriteAsync writes synchronously to the primary (first) backend only
+	// and queues the change for background replication to the other
+	// backends, with retry and backoff. Use WithJournal to persist the queue,
+	// and Pending and Drain to observe it.
+	WriteAsync
 )
 
 // Writer provides fan-out writing to multiple backends.
+//
+// Writer also implements omnistorage.ExtendedBackend, so the composite can be
+// used anywhere a Backend is expected, such as a sync destination. Reads and
+// Stat are served by the primary (first) backend, mutations fan out to all
+// backends and are judged by the write mode.
 type Writer struct {
 	backends []omnistorage.Backend
 	mode     WriteMode
+	exists   ExistsPolicy
+	asyncCfg asyncConfig
+	async    *replicator
 	mu       sync.RWMutex
 }
 
@@ -101,14 +124,31 @@ func NewWriterWithOptions(backends []omnistorage.Backend, opts ...Option) (*Writ
 		opt(w)
 	}
 
+	if w.mode == WriteAsync {
+		w.async, err = newReplicator(w.backends, w.asyncCfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return w, nil
 }
 
 // NewWriter creates a writer that fans out to all backends.
+// Under WriteAsync, only the primary is written and replication to the
+// other backends is queued when the writer is closed.
 func (w *Writer) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
+	if w.async != nil {
+		pw, err := w.backends[0].NewWriter(ctx, path, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &asyncWriteCloser{WriteCloser: pw, r: w.async, path: path}, nil
+	}
+
 	// Create writers for all backends
 	writers := make([]io.WriteCloser, 0, len(w.backends))
 	var errs []error
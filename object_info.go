@@ -34,16 +34,57 @@ type ObjectInfo interface {
 	Metadata() map[string]string
 }
 
-// BasicObjectInfo is a simple implementation of ObjectInfo.
+// ExtendedObjectInfo is implemented by ObjectInfo values that carry the
+// attributes object stores such as S3 keep with each object. Each method
+// returns an empty string if the attribute is unknown or not supported.
+//
+// Check for it with a type assertion:
+//
+//	if ext, ok := info.(omnistorage.ExtendedObjectInfo); ok {
+//	    fmt.Println(ext.StorageClass())
+//	}
+type ExtendedObjectInfo interface {
+	ObjectInfo
+
+	// ETag returns the entity tag the backend assigned to this version
+	// of the object, without quotes.
+	ETag() string
+
+	// StorageClass returns the object's storage class, such as STANDARD
+	// or GLACIER.
+	StorageClass() string
+
+	// VersionID returns the object's version ID on backends with
+	// versioning.
+	VersionID() string
+
+	// CacheControl returns the object's Cache-Control header.
+	CacheControl() string
+
+	// ContentEncoding returns the object's Content-Encoding header.
+	ContentEncoding() string
+
+	// ContentDisposition returns the object's Content-Disposition header.
+	ContentDisposition() string
+}
+
+// BasicObjectInfo is a simple implementation of ObjectInfo and
+// ExtendedObjectInfo.
 // Use this when creating ObjectInfo instances in backend implementations.
 type BasicObjectInfo struct {
-	ObjectPath        string
-	ObjectSize        int64
-	ObjectModTime     time.Time
-	ObjectIsDir       bool
-	ObjectContentType string
-	ObjectHashes      map[HashType]string
-	ObjectMetadata    map[string]string
+	ObjectPath               string
+	ObjectSize               int64
+	ObjectModTime            time.Time
+	ObjectIsDir              bool
+	ObjectContentType        string
+	ObjectHashes             map[HashType]string
+	ObjectMetadata           map[string]string
+	ObjectETag               string
+	ObjectStorageClass       string
+	ObjectVersionID          string
+	ObjectCacheControl       string
+	ObjectContentEncoding    string
+	ObjectContentDisposition string
 }
 
 // Path returns the object's path.
@@ -84,5 +125,35 @@ func (o *BasicObjectInfo) Metadata() map[string]string {
 	return o.ObjectMetadata
 }
 
-// Ensure BasicObjectInfo implements ObjectInfo
-var _ ObjectInfo = (*BasicObjectInfo)(nil)
+// ETag returns the object's entity tag.
+func (o *BasicObjectInfo) ETag() string {
+	return o.ObjectETag
+}
+
+// StorageClass returns the object's storage class.
+func (o *BasicObjectInfo) StorageClass() string {
+	return o.ObjectStorageClass
+}
+
+// VersionID returns the object's version ID.
+func (o *BasicObjectInfo) VersionID() string {
+	return o.ObjectVersionID
+}
+
+// CacheControl returns the object's Cache-Control header.
+func (o *BasicObjectInfo) CacheControl() string {
+	return o.ObjectCacheControl
+}
+
+// ContentEncoding returns the object's Content-Encoding header.
+func (o *BasicObjectInfo) ContentEncoding() string {
+	return o.ObjectContentEncoding
+}
+
+// ContentDisposition returns the object's Content-Disposition header.
+func (o *BasicObjectInfo) ContentDisposition() string {
+	return o.ObjectContentDisposition
+}
+
+// Ensure BasicObjectInfo implements ExtendedObjectInfo
+var _ ExtendedObjectInfo = (*BasicObjectInfo)(nil)
@@ -1,5 +1,7 @@
 package omnistorage
 
+import "time"
+
 // WriterOption configures a writer created by Backend.NewWriter.
 type WriterOption func(*WriterConfig)
 
@@ -17,6 +19,34 @@ type WriterConfig struct {
 	// For S3, these become object metadata.
 	// For file backend, this is ignored.
 	Metadata map[string]string
+
+	// CacheControl, ContentEncoding and ContentDisposition are standard
+	// HTTP headers stored with the object by backends that support them,
+	// such as S3. Others ignore them.
+	CacheControl       string
+	ContentEncoding    string
+	ContentDisposition string
+
+	// StorageClass is the storage class of the object, such as GLACIER,
+	// for backends that have them. Empty means the backend's default.
+	StorageClass string
+
+	// ContentMD5 and ContentSHA256 are the expected hex-encoded hashes of
+	// the content. Backends that support them verify the data on Close and
+	// fail it with ErrChecksumMismatch, without keeping the object.
+	ContentMD5    string
+	ContentSHA256 string
+
+	// RetentionMode and RetainUntil lock the object against deletion until
+	// RetainUntil, and LegalHold locks it until the hold is removed, on
+	// backends with Features().ObjectLock. Others ignore them.
+	RetentionMode RetentionMode
+	RetainUntil   time.Time
+	LegalHold     bool
+
+	// Extensions holds backend-specific options, keyed by types defined in
+	// the backend packages. Backends ignore keys they do not recognize.
+	Extensions map[any]any
 }
 
 // WithBufferSize sets the buffer size for the writer.
@@ -40,6 +70,85 @@ func WithMetadata(metadata map[string]string) WriterOption {
 	}
 }
 
+// WithCacheControl sets the Cache-Control header stored with the object.
+func WithCacheControl(v string) WriterOption {
+	return func(c *WriterConfig) {
+		c.CacheControl = v
+	}
+}
+
+// WithContentEncoding sets the Content-Encoding header stored with the
+// object, such as "gzip" for content written compressed. The content is
+// not changed.
+func WithContentEncoding(v string) WriterOption {
+	return func(c *WriterConfig) {
+		c.ContentEncoding = v
+	}
+}
+
+// WithContentDisposition sets the Content-Disposition header stored with
+// the object.
+func WithContentDisposition(v string) WriterOption {
+	return func(c *WriterConfig) {
+		c.ContentDisposition = v
+	}
+}
+
+// WithStorageClass sets the storage class of the object.
+func WithStorageClass(class string) WriterOption {
+	return func(c *WriterConfig) {
+		c.StorageClass = class
+	}
+}
+
+// WithContentMD5 sets the expected hex-encoded MD5 hash of the content.
+// S3 sends it as the Content-MD5 header; the file, memory and SFTP
+// backends verify it as the data is written.
+func WithContentMD5(sum string) WriterOption {
+	return func(c *WriterConfig) {
+		c.ContentMD5 = sum
+	}
+}
+
+// WithContentSHA256 sets the expected hex-encoded SHA-256 hash of the
+// content. S3 sends it as the x-amz-checksum-sha256 header; the file,
+// memory and SFTP backends verify it as the data is written.
+func WithContentSHA256(sum string) WriterOption {
+	return func(c *WriterConfig) {
+		c.ContentSHA256 = sum
+	}
+}
+
+// WithRetention locks the object against deletion until until, in the
+// given mode, on backends with Features().ObjectLock.
+func WithRetention(mode RetentionMode, until time.Time) WriterOption {
+	return func(c *WriterConfig) {
+		c.RetentionMode = mode
+		c.RetainUntil = until
+	}
+}
+
+// WithLegalHold places a legal hold on the object, on backends with
+// Features().ObjectLock. The object cannot be deleted until the hold is
+// removed with ObjectLocker.SetLegalHold.
+func WithLegalHold() WriterOption {
+	return func(c *WriterConfig) {
+		c.LegalHold = true
+	}
+}
+
+// WithWriterExtension sets a backend-specific writer option.
+// Backend packages use it to define their own WriterOptions; key should be
+// an unexported type so that options from different packages cannot collide.
+func WithWriterExtension(key, value any) WriterOption {
+	return func(c *WriterConfig) {
+		if c.Extensions == nil {
+			c.Extensions = make(map[any]any)
+		}
+		c.Extensions[key] = value
+	}
+}
+
 // ApplyWriterOptions applies options to a WriterConfig.
 func ApplyWriterOptions(opts ...WriterOption) *WriterConfig {
 	config := &WriterConfig{}
@@ -65,6 +174,10 @@ type ReaderConfig struct {
 	// Limit is the maximum number of bytes to read.
 	// 0 means no limit.
 	Limit int64
+
+	// Extensions holds backend-specific options, keyed by types defined in
+	// the backend packages. Backends ignore keys they do not recognize.
+	Extensions map[any]any
 }
 
 // WithReaderBufferSize sets the buffer size for the reader.
@@ -88,6 +201,17 @@ func WithLimit(limit int64) ReaderOption {
 	}
 }
 
+// WithReaderExtension sets a backend-specific reader option.
+// See WithWriterExtension.
+func WithReaderExtension(key, value any) ReaderOption {
+	return func(c *ReaderConfig) {
+		if c.Extensions == nil {
+			c.Extensions = make(map[any]any)
+		}
+		c.Extensions[key] = value
+	}
+}
+
 // ApplyReaderOptions applies options to a ReaderConfig.
 func ApplyReaderOptions(opts ...ReaderOption) *ReaderConfig {
 	config := &ReaderConfig{}
@@ -0,0 +1,195 @@
+package omnistorage
+
+import (
+	"context"
+	"hash"
+	"io"
+)
+
+// pipeChunkSize is the largest read Pipe makes at a time, so rate limits
+// and progress updates stay smooth.
+const pipeChunkSize = 32 * 1024
+
+// Limiter limits the rate of a transfer. WaitN blocks until n bytes may be
+// transferred, or returns an error if ctx is done. *rate.Limiter from
+// golang.org/x/time/rate implements it, with a burst of at least 32 KiB.
+type Limiter interface {
+	WaitN(ctx context.Context, n int) error
+}
+
+// PipeOption configures Pipe.
+type PipeOption func(*pipeConfig)
+
+type pipeConfig struct {
+	readerOpts []ReaderOption
+	writerOpts []WriterOption
+	hashes     []HashType
+	limiter    Limiter
+	progress   func(int64)
+	transform  func(io.Reader) io.Reader
+}
+
+// PipeReaderOptions sets options for the source reader.
+func PipeReaderOptions(opts ...ReaderOption) PipeOption {
+	return func(c *pipeConfig) {
+		c.readerOpts = append(c.readerOpts, opts...)
+	}
+}
+
+// PipeWriterOptions sets options for the destination writer.
+func PipeWriterOptions(opts ...WriterOption) PipeOption {
+	return func(c *pipeConfig) {
+		c.writerOpts = append(c.writerOpts, opts...)
+	}
+}
+
+// PipeHashes computes hashes of the data as it is copied. They are returned
+// in PipeResult.Hashes.
+func PipeHashes(types ...HashType) PipeOption {
+	return func(c *pipeConfig) {
+		c.hashes = append(c.hashes, types...)
+	}
+}
+
+// PipeLimiter limits the transfer rate. Share one Limiter between pipes to
+// limit their combined rate.
+func PipeLimiter(l Limiter) PipeOption {
+	return func(c *pipeConfig) {
+		c.limiter = l
+	}
+}
+
+// PipeProgress sets a function called with the total bytes copied so far,
+// after each chunk.
+func PipeProgress(fn func(bytes int64)) PipeOption {
+	return func(c *pipeConfig) {
+		c.progress = fn
+	}
+}
+
+// PipeTransform inserts a streaming transform, such as compression or
+// redaction, between the source reader and the destination writer. If the
+// reader fn returns implements io.Closer, it is closed after the copy.
+// Bytes, hashes, rate limits and progress apply to the transformed data.
+func PipeTransform(fn func(io.Reader) io.Reader) PipeOption {
+	return func(c *pipeConfig) {
+		c.transform = fn
+	}
+}
+
+// PipeResult describes a completed Pipe.
+type PipeResult struct {
+	// Bytes is the number of bytes copied.
+	Bytes int64
+
+	// Hashes holds the hex-encoded hashes requested with PipeHashes.
+	Hashes map[HashType]string
+}
+
+// Hash returns the hash of the given type, or "" if it was not requested.
+func (r *PipeResult) Hash(t HashType) string {
+	return r.Hashes[t]
+}
+
+// Pipe streams one object from srcBackend to dstBackend, optionally rate
+// limited, reporting progress and computing hashes on the fly. It is
+// lighter than sync.CopyFile, which adds retries and metadata handling, and
+// richer than CopyPath.
+//
+// On error, the destination writer is closed and the error returned; what
+// is left at dstPath then depends on the backend.
+func Pipe(ctx context.Context, srcBackend Backend, srcPath string, dstBackend Backend, dstPath string, opts ...PipeOption) (*PipeResult, error) {
+	var cfg pipeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	hashes := make(map[HashType]hash.Hash, len(cfg.hashes))
+	for _, t := range cfg.hashes {
+		h := NewHash(t)
+		if h == nil {
+			return nil, ErrNotSupported
+		}
+		hashes[t] = h
+	}
+
+	r, err := srcBackend.NewReader(ctx, srcPath, cfg.readerOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	src := io.Reader(r)
+	if cfg.transform != nil {
+		src = cfg.transform(r)
+		if c, ok := src.(io.Closer); ok {
+			defer func() { _ = c.Close() }()
+		}
+	}
+
+	w, err := dstBackend.NewWriter(ctx, dstPath, cfg.writerOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := io.Writer(w)
+	if len(hashes) > 0 {
+		writers := []io.Writer{w}
+		for _, h := range hashes {
+			writers = append(writers, h)
+		}
+		dst = io.MultiWriter(writers...)
+	}
+
+	n, err := pipeCopy(ctx, dst, src, &cfg)
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	result := &PipeResult{Bytes: n}
+	if len(hashes) > 0 {
+		result.Hashes = make(map[HashType]string, len(hashes))
+		for t, h := range hashes {
+			result.Hashes[t] = HashBytesFromSum(h.Sum(nil))
+		}
+	}
+	return result, nil
+}
+
+// pipeCopy copies r to w in chunks, waiting on the limiter and reporting
+// progress after each chunk.
+func pipeCopy(ctx context.Context, w io.Writer, r io.Reader, cfg *pipeConfig) (int64, error) {
+	if cfg.limiter == nil && cfg.progress == nil {
+		return io.Copy(w, r)
+	}
+
+	buf := make([]byte, pipeChunkSize)
+	var total int64
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if cfg.limiter != nil {
+				if err := cfg.limiter.WaitN(ctx, n); err != nil {
+					return total, err
+				}
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return total, err
+			}
+			total += int64(n)
+			if cfg.progress != nil {
+				cfg.progress(total)
+			}
+		}
+		if rerr == io.EOF {
+			return total, nil
+		}
+		if rerr != nil {
+			return total, rerr
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package omnistorage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// countingLimiter records the bytes it is asked to wait for.
+type countingLimiter struct {
+	bytes int
+	err   error
+}
+
+func (l *countingLimiter) WaitN(_ context.Context, n int) error {
+	l.bytes += n
+	return l.err
+}
+
+func newPipeFixture(t *testing.T, data []byte) (src, dst *memory.Backend) {
+	t.Helper()
+	src, dst = memory.New(), memory.New()
+	w, err := src.NewWriter(context.Background(), "src.bin")
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	_, _ = w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return src, dst
+}
+
+func TestPipe(t *testing.T) {
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	src, dst := newPipeFixture(t, data)
+
+	limiter := &countingLimiter{}
+	var progress []int64
+	result, err := omnistorage.Pipe(ctx, src, "src.bin", dst, "dst.bin",
+		omnistorage.PipeHashes(omnistorage.HashMD5, omnistorage.HashSHA256),
+		omnistorage.PipeLimiter(limiter),
+		omnistorage.PipeProgress(func(n int64) { progress = append(progress, n) }),
+		omnistorage.PipeWriterOptions(omnistorage.WithContentType("application/octet-stream")),
+	)
+	if err != nil {
+		t.Fatalf("Pipe failed: %v", err)
+	}
+
+	if result.Bytes != int64(len(data)) {
+		t.Errorf("Bytes = %d, want %d", result.Bytes, len(data))
+	}
+	for _, ht := range []omnistorage.HashType{omnistorage.HashMD5, omnistorage.HashSHA256} {
+		if got, want := result.Hash(ht), omnistorage.HashBytes(data, ht); got != want {
+			t.Errorf("Hash(%s) = %q, want %q", ht, got, want)
+		}
+	}
+	if result.Hash(omnistorage.HashSHA1) != "" {
+		t.Error("Hash(sha1) is set but was not requested")
+	}
+	if limiter.bytes != len(data) {
+		t.Errorf("limiter waited for %d bytes, want %d", limiter.bytes, len(data))
+	}
+	if len(progress) < 2 || progress[len(progress)-1] != int64(len(data)) {
+		t.Errorf("progress = %v, want several updates ending at %d", progress, len(data))
+	}
+
+	r, _ := dst.NewReader(ctx, "dst.bin")
+	got, _ := io.ReadAll(r)
+	_ = r.Close()
+	if !bytes.Equal(got, data) {
+		t.Error("destination content differs from source")
+	}
+	info, _ := dst.Stat(ctx, "dst.bin")
+	if info.ContentType() != "application/octet-stream" {
+		t.Errorf("ContentType() = %q, want writer options applied", info.ContentType())
+	}
+}
+
+func TestPipeErrors(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newPipeFixture(t, []byte("data"))
+
+	if _, err := omnistorage.Pipe(ctx, src, "missing", dst, "dst.bin"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Pipe(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := omnistorage.Pipe(ctx, src, "src.bin", dst, "dst.bin", omnistorage.PipeHashes("blake3")); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Pipe(blake3) error = %v, want ErrNotSupported", err)
+	}
+
+	limiter := &countingLimiter{err: context.DeadlineExceeded}
+	_, err := omnistorage.Pipe(ctx, src, "src.bin", dst, "dst.bin", omnistorage.PipeLimiter(limiter))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Pipe with failing limiter error = %v, want DeadlineExceeded", err)
+	}
+}
+
+// closeRecorder records whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestPipeTransform(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newPipeFixture(t, []byte("hello world"))
+
+	var rec *closeRecorder
+	upper := func(r io.Reader) io.Reader {
+		data, _ := io.ReadAll(r)
+		rec = &closeRecorder{Reader: bytes.NewReader(bytes.ToUpper(data))}
+		return rec
+	}
+	result, err := omnistorage.Pipe(ctx, src, "src.bin", dst, "dst.bin",
+		omnistorage.PipeTransform(upper),
+		omnistorage.PipeHashes(omnistorage.HashMD5),
+	)
+	if err != nil {
+		t.Fatalf("Pipe failed: %v", err)
+	}
+	want := []byte("HELLO WORLD")
+	if got := result.Hash(omnistorage.HashMD5); got != omnistorage.HashBytes(want, omnistorage.HashMD5) {
+		t.Errorf("Hash(md5) = %q, want hash of transformed data", got)
+	}
+	if !rec.closed {
+		t.Error("transformed reader was not closed")
+	}
+
+	r, _ := dst.NewReader(ctx, "dst.bin")
+	got, _ := io.ReadAll(r)
+	_ = r.Close()
+	if !bytes.Equal(got, want) {
+		t.Errorf("destination = %q, want %q", got, want)
+	}
+}
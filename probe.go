@@ -0,0 +1,108 @@
+package omnistorage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// probePath is the path Probe checks for with Exists when the backend is
+// not a Pinger.
+const probePath = ".omnistorage-probe"
+
+// ProbeOptions configures Probe.
+type ProbeOptions struct {
+	// Canary, if set, is the path of an object Probe writes, reads back
+	// and deletes to check that the backend is writable. Any object at
+	// the path is overwritten. Empty skips the write check.
+	Canary string
+}
+
+// ProbeResult reports what Probe found.
+type ProbeResult struct {
+	// Reachable is true if the connectivity check succeeded.
+	Reachable bool
+
+	// Latency is how long the connectivity check took.
+	Latency time.Duration
+
+	// Writable is true if the canary object was written, read back and
+	// deleted. It is false if ProbeOptions.Canary is empty.
+	Writable bool
+
+	// Extended is true if the backend implements ExtendedBackend.
+	Extended bool
+
+	// Features are the backend's features, or the zero Features if it
+	// does not implement ExtendedBackend.
+	Features Features
+}
+
+// Probe checks that backend is ready for use, as a readiness check for
+// configured remotes at startup. It checks connectivity with Ping if the
+// backend is a Pinger, or else with Exists, and then, if opts.Canary is
+// set, that an object can be written, read back and deleted.
+//
+// Probe returns the result so far and an error describing the first check
+// that failed.
+func Probe(ctx context.Context, backend Backend, opts ProbeOptions) (*ProbeResult, error) {
+	result := &ProbeResult{}
+	if ext, ok := AsExtended(backend); ok {
+		result.Extended = true
+		result.Features = ext.Features()
+	}
+
+	start := time.Now()
+	var err error
+	if pinger, ok := backend.(Pinger); ok {
+		err = pinger.Ping(ctx)
+	} else {
+		_, err = backend.Exists(ctx, probePath)
+	}
+	result.Latency = time.Since(start)
+	if err != nil {
+		return result, fmt.Errorf("probe: connectivity: %w", err)
+	}
+	result.Reachable = true
+
+	if opts.Canary == "" {
+		return result, nil
+	}
+	if err := probeCanary(ctx, backend, opts.Canary); err != nil {
+		return result, fmt.Errorf("probe: canary %s: %w", opts.Canary, err)
+	}
+	result.Writable = true
+	return result, nil
+}
+
+// probeCanary writes, reads back and deletes the object at p.
+func probeCanary(ctx context.Context, backend Backend, p string) error {
+	want := []byte("omnistorage probe " + time.Now().UTC().Format(time.RFC3339Nano))
+	w, err := backend.NewWriter(ctx, p, WithContentType("text/plain"))
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(want); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	r, err := backend.NewReader(ctx, p)
+	if err != nil {
+		return err
+	}
+	got, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("read back %d bytes that differ from the %d written", len(got), len(want))
+	}
+	return backend.Delete(ctx, p)
+}
@@ -0,0 +1,41 @@
+package omnistorage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestProbe(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+
+	result, err := omnistorage.Probe(ctx, backend, omnistorage.ProbeOptions{Canary: "health/canary"})
+	if err != nil {
+		t.Fatalf("Probe error = %v", err)
+	}
+	if !result.Reachable || !result.Writable || !result.Extended || !result.Features.Copy {
+		t.Errorf("result = %+v", result)
+	}
+	if exists, _ := backend.Exists(ctx, "health/canary"); exists {
+		t.Error("canary left behind")
+	}
+
+	// Without a canary, only connectivity is checked
+	result, err = omnistorage.Probe(ctx, basicBackend{backend}, omnistorage.ProbeOptions{})
+	if err != nil {
+		t.Fatalf("Probe error = %v", err)
+	}
+	if !result.Reachable || result.Writable || result.Extended {
+		t.Errorf("result = %+v", result)
+	}
+
+	_ = backend.Close()
+	result, err = omnistorage.Probe(ctx, backend, omnistorage.ProbeOptions{})
+	if !errors.Is(err, omnistorage.ErrBackendClosed) || result.Reachable {
+		t.Errorf("Probe of closed backend: result = %+v, err = %v", result, err)
+	}
+}
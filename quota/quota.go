@@ -0,0 +1,614 @@
+// Package quota provides a backend wrapper that enforces storage quotas.
+//
+// Limits cap the total bytes stored and the size of each object, either
+// under a fixed prefix or separately for every tenant, where a function
+// maps each path to its tenant:
+//
+//	backend := quota.New(s3Backend,
+//	    quota.WithLimit("shared/", quota.Limit{MaxBytes: 10 << 30}),
+//	    quota.WithTenants(quota.TopLevel, quota.Limit{
+//	        MaxBytes:      1 << 30,   // 1 GiB per tenant
+//	        MaxObjectSize: 100 << 20, // 100 MiB per object
+//	    }),
+//	    quota.WithUsage(usage),
+//	)
+//
+// A write that would exceed a limit fails with an *Error, which matches
+// ErrQuotaExceeded and omnistorage.ErrQuotaExceeded with errors.Is.
+// Writers do not know the final size of an object in advance, so the
+// check is made as data is written: the write that crosses the limit
+// fails, and on Close the partial object is deleted. A write that replaces an existing object is staged under a
+// temporary name beside it and moved into place on Close, so a rejected
+// write leaves the previous object intact.
+//
+// Usage is kept by a Usage implementation, in memory by default. Use
+// NewStoredUsage to persist it in a backend, and Recalculate to rebuild it
+// from the objects stored. The size of overwritten, deleted, copied and
+// moved objects is only known if the wrapped backend implements
+// omnistorage.ExtendedBackend.
+package quota
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/grokify/omnistorage"
+)
+
+// ErrQuotaExceeded is matched by every *Error, as is
+// omnistorage.ErrQuotaExceeded.
+var ErrQuotaExceeded = errors.New("quota: quota exceeded")
+
+// Error describes a write rejected by a limit.
+type Error struct {
+	// Scope is the prefix or tenant whose limit was exceeded.
+	Scope string
+
+	// Path is the object being written.
+	Path string
+
+	// Object is true if the object size limit was exceeded, and false if
+	// the total size limit was.
+	Object bool
+
+	// Limit is the limit in bytes.
+	Limit int64
+
+	// Size is the object size or total size the write would have reached.
+	Size int64
+}
+
+func (e *Error) Error() string {
+	if e.Object {
+		return fmt.Sprintf("quota: %s is %d bytes, over the %d byte object limit of %q", e.Path, e.Size, e.Limit, e.Scope)
+	}
+	return fmt.Sprintf("quota: writing %s takes %q to %d bytes, over its %d byte limit", e.Path, e.Scope, e.Size, e.Limit)
+}
+
+// Is reports whether target is ErrQuotaExceeded or
+// omnistorage.ErrQuotaExceeded.
+func (e *Error) Is(target error) bool {
+	return target == ErrQuotaExceeded || target == omnistorage.ErrQuotaExceeded
+}
+
+// Limit is a quota. Zero values mean no limit.
+type Limit struct {
+	// MaxBytes is the maximum total size of the objects in the scope.
+	MaxBytes int64
+
+	// MaxObjectSize is the maximum size of a single object.
+	MaxObjectSize int64
+}
+
+// Option configures a quota backend.
+type Option func(*Backend)
+
+// WithLimit applies l to all paths under the directory prefix combined. An
+// empty prefix applies l to the whole backend. The prefix is cleaned with
+// omnistorage.CleanPrefix, so "shared", "/shared/" and "shared/" all
+// cover "shared/x", and the cleaned prefix, "shared/", is the usage scope.
+func WithLimit(prefix string, l Limit) Option {
+	return func(b *Backend) {
+		b.prefixes = append(b.prefixes, prefixLimit{prefix: omnistorage.CleanPrefix(prefix), limit: l})
+	}
+}
+
+// WithTenants applies l to each tenant separately. tenant maps a path to
+// its tenant, or to "" for paths that belong to none. The usage scope is
+// the tenant name.
+func WithTenants(tenant func(path string) string, l Limit) Option {
+	return func(b *Backend) {
+		b.tenant = tenant
+		b.tenantLimit = l
+	}
+}
+
+// WithUsage sets where usage is kept. Default is a new MemoryUsage.
+func WithUsage(u Usage) Option {
+	return func(b *Backend) {
+		b.usage = u
+	}
+}
+
+// TopLevel returns the first element of path, for use with WithTenants
+// when each tenant has its own top-level directory. Paths without a
+// directory belong to no tenant.
+func TopLevel(path string) string {
+	tenant, _, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return ""
+	}
+	return tenant
+}
+
+type prefixLimit struct {
+	prefix string
+	limit  Limit
+}
+
+// scope is a limit that applies to a path.
+type scope struct {
+	name  string
+	limit Limit
+}
+
+// Backend wraps a backend with quotas. Extended operations return
+// omnistorage.ErrNotSupported if the wrapped backend does not implement
+// omnistorage.ExtendedBackend.
+type Backend struct {
+	backend     omnistorage.Backend
+	prefixes    []prefixLimit
+	tenant      func(string) string
+	tenantLimit Limit
+	usage       Usage
+
+	mu      sync.Mutex
+	pending map[string]int64 // bytes written by open writers, by scope
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend with the given quotas. With no limits, operations pass
+// through unchanged apart from usage accounting.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{
+		backend: backend,
+		pending: make(map[string]int64),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.usage == nil {
+		b.usage = NewMemoryUsage()
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// Used returns the bytes stored in a scope, a prefix given to WithLimit
+// or a tenant name.
+func (b *Backend) Used(ctx context.Context, scope string) (int64, error) {
+	return b.usage.Get(ctx, scope)
+}
+
+// scopes returns the limits that apply to path, once cleaned as backends
+// clean it.
+func (b *Backend) scopes(path string) []scope {
+	path = clean(path)
+	var scopes []scope
+	for _, p := range b.prefixes {
+		if strings.HasPrefix(path, p.prefix) {
+			scopes = append(scopes, scope{name: p.prefix, limit: p.limit})
+		}
+	}
+	if b.tenant != nil {
+		if t := b.tenant(path); t != "" {
+			scopes = append(scopes, scope{name: t, limit: b.tenantLimit})
+		}
+	}
+	return scopes
+}
+
+// clean returns p cleaned and relative to the backend root.
+func clean(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// size returns the size of the object at path, or 0 if it does not exist
+// or the wrapped backend cannot stat it.
+func (b *Backend) size(ctx context.Context, path string) (int64, error) {
+	if _, ok := omnistorage.AsExtended(b.backend); !ok {
+		return 0, nil
+	}
+	size, _, err := b.stat(ctx, path)
+	return size, err
+}
+
+// stat returns the size of the object at path, which is 0 if the wrapped
+// backend cannot stat it, and whether the object exists.
+func (b *Backend) stat(ctx context.Context, path string) (int64, bool, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		exists, err := b.backend.Exists(ctx, path)
+		return 0, exists, err
+	}
+	info, err := ext.Stat(ctx, path)
+	if omnistorage.IsNotFound(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if info.IsDir() {
+		return 0, false, nil
+	}
+	return info.Size(), true, nil
+}
+
+// stagingPath returns a new temporary name beside p.
+func stagingPath(p string) (string, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	dir, name := path.Split(clean(p))
+	return dir + "." + name + ".quota-" + hex.EncodeToString(buf[:]), nil
+}
+
+// commit moves the staged object src to dst, with Move if the wrapped
+// backend supports it, and otherwise by copying it and deleting src.
+func (b *Backend) commit(ctx context.Context, src, dst string, opts []omnistorage.WriterOption) error {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok && ext.Features().Move {
+		return ext.Move(ctx, src, dst)
+	}
+	r, err := b.backend.NewReader(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := b.backend.NewWriter(ctx, dst, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return b.backend.Delete(ctx, src)
+}
+
+// reserve checks that an object at path may grow from size-n to size
+// bytes, replacing an object of old bytes, and counts the n bytes as
+// pending. Call with b.mu held.
+func (b *Backend) reserve(ctx context.Context, path string, scopes []scope, old, size, n int64) error {
+	for _, s := range scopes {
+		if limit := s.limit.MaxObjectSize; limit > 0 && size > limit {
+			return &Error{Scope: s.name, Path: path, Object: true, Limit: limit, Size: size}
+		}
+		if limit := s.limit.MaxBytes; limit > 0 {
+			used, err := b.usage.Get(ctx, s.name)
+			if err != nil {
+				return err
+			}
+			if total := used - old + b.pending[s.name] + n; total > limit {
+				return &Error{Scope: s.name, Path: path, Limit: limit, Size: total}
+			}
+		}
+	}
+	for _, s := range scopes {
+		b.pending[s.name] += n
+	}
+	return nil
+}
+
+// release stops counting n bytes as pending and adds delta to the usage
+// of scopes.
+func (b *Backend) release(ctx context.Context, scopes []scope, n, delta int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs []error
+	for _, s := range scopes {
+		b.pending[s.name] -= n
+		if b.pending[s.name] == 0 {
+			delete(b.pending, s.name)
+		}
+		if delta != 0 {
+			errs = append(errs, b.usage.Add(ctx, s.name, delta))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewWriter creates a writer that fails once the object exceeds a limit.
+// If path exists, the writer writes to a temporary object beside it,
+// which replaces it on Close unless a limit was exceeded.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	scopes := b.scopes(path)
+	if len(scopes) == 0 {
+		return b.backend.NewWriter(ctx, path, opts...)
+	}
+	old, exists, err := b.stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	qw := &writer{backend: b, ctx: ctx, path: path, opts: opts, scopes: scopes, old: old}
+	target := path
+	if exists {
+		if qw.staged, err = stagingPath(path); err != nil {
+			return nil, err
+		}
+		target = qw.staged
+	}
+	if qw.w, err = b.backend.NewWriter(ctx, target, opts...); err != nil {
+		return nil, err
+	}
+	return qw, nil
+}
+
+// NewReader creates a reader for the given path.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	return b.backend.NewReader(ctx, path, opts...)
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	return b.backend.Exists(ctx, path)
+}
+
+// Delete removes a path and subtracts its size from the usage.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	scopes := b.scopes(path)
+	if len(scopes) == 0 {
+		return b.backend.Delete(ctx, path)
+	}
+	size, err := b.size(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := b.backend.Delete(ctx, path); err != nil {
+		return err
+	}
+	return b.release(ctx, scopes, 0, -size)
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	return b.backend.List(ctx, prefix)
+}
+
+// Close closes the wrapped backend.
+func (b *Backend) Close() error {
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object within the wrapped backend, if the copy fits the
+// destination's limits.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return b.transfer(ctx, src, dst, false, ext.Copy)
+}
+
+// Move moves an object within the wrapped backend, if it fits the
+// destination's limits.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return b.transfer(ctx, src, dst, true, ext.Move)
+}
+
+// transfer runs a copy or move, accounting the object in the scopes of dst
+// and, for a move, removing it from those of src.
+func (b *Backend) transfer(ctx context.Context, src, dst string, move bool, op func(ctx context.Context, src, dst string) error) error {
+	dstScopes := b.scopes(dst)
+	var srcScopes []scope
+	if move {
+		srcScopes = b.scopes(src)
+	}
+	if len(dstScopes) == 0 && len(srcScopes) == 0 {
+		return op(ctx, src, dst)
+	}
+
+	size, err := b.size(ctx, src)
+	if err != nil {
+		return err
+	}
+	old, err := b.size(ctx, dst)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	err = b.reserve(ctx, dst, dstScopes, old, size, size)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := op(ctx, src, dst); err != nil {
+		_ = b.release(ctx, dstScopes, size, 0)
+		return err
+	}
+	return errors.Join(
+		b.release(ctx, dstScopes, size, size-old),
+		b.release(ctx, srcScopes, 0, -size),
+	)
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// Recalculate sets the usage of every scope from the objects in the
+// wrapped backend, for example after wrapping a backend that already
+// holds data. Every prefix scope, and every tenant scope the Usage holds
+// if it has a Snapshot method as MemoryUsage and StoredUsage do, is reset
+// first, so scopes whose objects are gone are set to zero. It needs the
+// wrapped backend to implement Stat.
+func (b *Backend) Recalculate(ctx context.Context) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	paths, err := b.backend.List(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	totals := make(map[string]int64)
+	if u, ok := b.usage.(interface{ Snapshot() map[string]int64 }); ok {
+		for name := range u.Snapshot() {
+			totals[name] = 0
+		}
+	}
+	for _, p := range b.prefixes {
+		totals[p.prefix] = 0
+	}
+	for _, p := range paths {
+		scopes := b.scopes(p)
+		if len(scopes) == 0 {
+			continue
+		}
+		info, err := ext.Stat(ctx, p)
+		if omnistorage.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, s := range scopes {
+			totals[s.name] += info.Size()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for name, n := range totals {
+		if err := b.usage.Set(ctx, name, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writer counts the bytes written against the limits of its scopes.
+type writer struct {
+	backend *Backend
+	ctx     context.Context
+	w       io.WriteCloser
+	path    string
+	opts    []omnistorage.WriterOption
+	staged  string // temporary object replacing path on Close, if it exists
+	scopes  []scope
+	old     int64 // size of the object being replaced
+	written int64
+	err     error // quota error, after which the object is deleted
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, omnistorage.ErrWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := int64(len(p))
+	w.backend.mu.Lock()
+	err := w.backend.reserve(w.ctx, w.path, w.scopes, w.old, w.written+n, n)
+	w.backend.mu.Unlock()
+	if err != nil {
+		w.err = err
+		return 0, err
+	}
+
+	m, err := w.w.Write(p)
+	w.written += int64(m)
+	if m < len(p) {
+		_ = w.backend.release(w.ctx, w.scopes, n-int64(m), 0)
+	}
+	return m, err
+}
+
+// Close closes the wrapped writer and adds the object to the usage. If a
+// write exceeded a limit, the partial object is deleted instead.
+func (w *writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	ctx := context.WithoutCancel(w.ctx)
+	closeErr := w.w.Close()
+	if w.staged != "" {
+		return w.closeStaged(ctx, closeErr)
+	}
+	if w.err != nil {
+		if closeErr != nil {
+			// The backend kept the previous object, if any
+			return errors.Join(w.err, w.backend.release(ctx, w.scopes, w.written, 0))
+		}
+		delErr := w.backend.backend.Delete(ctx, w.path)
+		return errors.Join(w.err, delErr, w.backend.release(ctx, w.scopes, w.written, -w.old))
+	}
+	if closeErr != nil {
+		return errors.Join(closeErr, w.backend.release(ctx, w.scopes, w.written, 0))
+	}
+	return w.backend.release(ctx, w.scopes, w.written, w.written-w.old)
+}
+
+// closeStaged moves the staged object into place, or removes it if a write
+// exceeded a limit or failed, leaving the previous object intact.
+func (w *writer) closeStaged(ctx context.Context, closeErr error) error {
+	err := w.err
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = w.backend.commit(ctx, w.staged, w.path, w.opts)
+	}
+	if err != nil {
+		_ = w.backend.backend.Delete(ctx, w.staged)
+		return errors.Join(err, w.backend.release(ctx, w.scopes, w.written, 0))
+	}
+	return w.backend.release(ctx, w.scopes, w.written, w.written-w.old)
+}
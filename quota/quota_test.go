@@ -0,0 +1,257 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), WithLimit("", Limit{MaxBytes: 1 << 30}))
+	})
+}
+
+func write(ctx context.Context, b omnistorage.Backend, p, data string) error {
+	w, err := b.NewWriter(ctx, p)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func mustWrite(t *testing.T, b omnistorage.Backend, p, data string) {
+	t.Helper()
+	if err := write(context.Background(), b, p, data); err != nil {
+		t.Fatalf("write %s: %v", p, err)
+	}
+}
+
+func checkUsed(t *testing.T, b *Backend, scope string, want int64) {
+	t.Helper()
+	if got, _ := b.Used(context.Background(), scope); got != want {
+		t.Errorf("Used(%q) = %d, want %d", scope, got, want)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	b := New(inner, WithLimit("data/", Limit{MaxBytes: 10}))
+
+	mustWrite(t, b, "data/a", "123456")
+	mustWrite(t, b, "other/big", strings.Repeat("x", 100))
+	checkUsed(t, b, "data/", 6)
+
+	err := write(ctx, b, "data/b", "12345")
+	var qe *Error
+	if !errors.As(err, &qe) || !errors.Is(err, ErrQuotaExceeded) || !errors.Is(err, omnistorage.ErrQuotaExceeded) {
+		t.Fatalf("write over quota = %v, want *Error", err)
+	}
+	if qe.Scope != "data/" || qe.Object || qe.Limit != 10 || qe.Size != 11 {
+		t.Errorf("Error = %+v", qe)
+	}
+	if exists, _ := inner.Exists(ctx, "data/b"); exists {
+		t.Error("partial object was kept")
+	}
+	checkUsed(t, b, "data/", 6)
+
+	// Overwriting replaces the old size, and deleting frees it
+	mustWrite(t, b, "data/a", "1234567890")
+	checkUsed(t, b, "data/", 10)
+	if err := b.Delete(ctx, "data/a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	checkUsed(t, b, "data/", 0)
+}
+
+func TestRejectedOverwriteKeepsObject(t *testing.T) {
+	ctx := context.Background()
+	for name, inner := range map[string]omnistorage.Backend{
+		"extended": memory.New(),
+		"basic":    struct{ omnistorage.Backend }{memory.New()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := New(inner, WithLimit("t/", Limit{MaxBytes: 10}))
+			mustWrite(t, b, "t/a", "12345")
+
+			if err := write(ctx, b, "t/a", strings.Repeat("x", 12)); !errors.Is(err, ErrQuotaExceeded) {
+				t.Fatalf("overwrite over quota = %v, want ErrQuotaExceeded", err)
+			}
+			r, err := inner.NewReader(ctx, "t/a")
+			if err != nil {
+				t.Fatalf("previous object lost: %v", err)
+			}
+			data, _ := io.ReadAll(r)
+			r.Close()
+			if string(data) != "12345" {
+				t.Errorf("t/a = %q, want %q", data, "12345")
+			}
+			if paths, _ := inner.List(ctx, "t/"); len(paths) != 1 {
+				t.Errorf("List(t/) = %v, want only t/a", paths)
+			}
+			checkUsed(t, b, "t/", 5)
+
+			// An overwrite within the limit replaces the object
+			mustWrite(t, b, "t/a", "12")
+			r, _ = inner.NewReader(ctx, "t/a")
+			data, _ = io.ReadAll(r)
+			r.Close()
+			if string(data) != "12" {
+				t.Errorf("t/a = %q after overwrite, want %q", data, "12")
+			}
+			if name == "extended" {
+				checkUsed(t, b, "t/", 2)
+			}
+			if paths, _ := inner.List(ctx, "t/"); len(paths) != 1 {
+				t.Errorf("List(t/) = %v, want only t/a", paths)
+			}
+		})
+	}
+}
+
+func TestUncleanPaths(t *testing.T) {
+	ctx := context.Background()
+	b := New(memory.New(), WithLimit("t/", Limit{MaxBytes: 10}))
+
+	for _, p := range []string{"./t/b", "/t/b", "x/../t/b", "t//b"} {
+		if err := write(ctx, b, p, strings.Repeat("x", 100)); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("write(%q) over quota = %v, want ErrQuotaExceeded", p, err)
+		}
+	}
+	checkUsed(t, b, "t/", 0)
+}
+
+func TestMaxObjectSize(t *testing.T) {
+	b := New(memory.New(), WithLimit("", Limit{MaxObjectSize: 4}))
+
+	mustWrite(t, b, "small", "1234")
+	err := write(context.Background(), b, "large", "12345")
+	var qe *Error
+	if !errors.As(err, &qe) || !qe.Object || qe.Size != 5 {
+		t.Fatalf("write of large object = %v, want object *Error", err)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	ctx := context.Background()
+	b := New(memory.New(), WithTenants(TopLevel, Limit{MaxBytes: 5}))
+
+	mustWrite(t, b, "alice/a", "12345")
+	mustWrite(t, b, "bob/a", "12345")
+	mustWrite(t, b, "shared", strings.Repeat("x", 10))
+	if err := write(ctx, b, "alice/b", "1"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("write over alice's quota = %v, want ErrQuotaExceeded", err)
+	}
+	checkUsed(t, b, "alice", 5)
+	checkUsed(t, b, "bob", 5)
+
+	// Moving between tenants moves the usage
+	if err := b.Delete(ctx, "bob/a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := b.Move(ctx, "alice/a", "bob/a"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	checkUsed(t, b, "alice", 0)
+	checkUsed(t, b, "bob", 5)
+	if err := b.Copy(ctx, "bob/a", "bob/b"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Copy over quota = %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	b := New(memory.New(), WithLimit("", Limit{MaxBytes: 10}))
+
+	w1, _ := b.NewWriter(ctx, "one")
+	w2, _ := b.NewWriter(ctx, "two")
+	if _, err := io.WriteString(w1, "123456"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	// Bytes written but not yet closed count against the quota
+	if _, err := io.WriteString(w2, "123456"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("second Write = %v, want ErrQuotaExceeded", err)
+	}
+	if err := w1.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w2.Close(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Close after quota error = %v, want ErrQuotaExceeded", err)
+	}
+	checkUsed(t, b, "", 6)
+}
+
+func TestStoredUsage(t *testing.T) {
+	ctx := context.Background()
+	data, state := memory.New(), memory.New()
+
+	usage, err := NewStoredUsage(ctx, state, "usage.json")
+	if err != nil {
+		t.Fatalf("NewStoredUsage: %v", err)
+	}
+	b := New(data, WithTenants(TopLevel, Limit{MaxBytes: 10}), WithUsage(usage))
+	mustWrite(t, b, "alice/a", "1234")
+
+	// A new wrapper picks up the saved usage
+	usage, err = NewStoredUsage(ctx, state, "usage.json")
+	if err != nil {
+		t.Fatalf("NewStoredUsage: %v", err)
+	}
+	b = New(data, WithTenants(TopLevel, Limit{MaxBytes: 10}), WithUsage(usage))
+	checkUsed(t, b, "alice", 4)
+	if err := write(ctx, b, "alice/b", "1234567"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("write over restored quota = %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestRecalculate(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	mustWrite(t, inner, "alice/a", "123")
+	mustWrite(t, inner, "alice/b", "45")
+	mustWrite(t, inner, "bob/a", "6")
+
+	b := New(inner, WithTenants(TopLevel, Limit{}), WithLimit("", Limit{}))
+	if err := b.Recalculate(ctx); err != nil {
+		t.Fatalf("Recalculate: %v", err)
+	}
+	checkUsed(t, b, "alice", 5)
+	checkUsed(t, b, "bob", 1)
+	checkUsed(t, b, "", 6)
+
+	// Scopes whose objects are gone are reset
+	if err := inner.Delete(ctx, "bob/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Recalculate(ctx); err != nil {
+		t.Fatalf("Recalculate: %v", err)
+	}
+	checkUsed(t, b, "bob", 0)
+	checkUsed(t, b, "", 5)
+}
+
+func TestLimitPrefixCleaned(t *testing.T) {
+	ctx := context.Background()
+	for _, prefix := range []string{"/shared/", "shared", "./shared"} {
+		b := New(memory.New(), WithLimit(prefix, Limit{MaxBytes: 4}))
+		mustWrite(t, b, "shared/x", "1234")
+		checkUsed(t, b, "shared/", 4)
+		if err := write(ctx, b, "shared/y", "5"); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("WithLimit(%q): write over the limit error = %v, want ErrQuotaExceeded", prefix, err)
+		}
+		if err := write(ctx, b, "sharedfoo/y", "5"); err != nil {
+			t.Errorf("WithLimit(%q): write outside the prefix error = %v", prefix, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Usage keeps the bytes stored in each quota scope. Implementations must
+// be safe for concurrent use. A Backend serializes its own checks, but
+// backends in different processes sharing a Usage may together exceed a
+// limit by the size of their concurrent writes.
+type Usage interface {
+	// Get returns the bytes used in scope, 0 if it has none.
+	Get(ctx context.Context, scope string) (int64, error)
+
+	// Add adds delta, which may be negative, to the usage of scope.
+	Add(ctx context.Context, scope string, delta int64) error
+
+	// Set sets the usage of scope.
+	Set(ctx context.Context, scope string, n int64) error
+}
+
+// MemoryUsage keeps usage in memory.
+type MemoryUsage struct {
+	mu   sync.Mutex
+	used map[string]int64
+}
+
+// NewMemoryUsage returns an empty MemoryUsage.
+func NewMemoryUsage() *MemoryUsage {
+	return &MemoryUsage{used: make(map[string]int64)}
+}
+
+// Get returns the bytes used in scope.
+func (u *MemoryUsage) Get(_ context.Context, scope string) (int64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.used[scope], nil
+}
+
+// Add adds delta to the usage of scope. Usage does not go below zero, as
+// deleted objects may have been stored before they were counted.
+func (u *MemoryUsage) Add(_ context.Context, scope string, delta int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.used[scope] = max(u.used[scope]+delta, 0)
+	return nil
+}
+
+// Set sets the usage of scope.
+func (u *MemoryUsage) Set(_ context.Context, scope string, n int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.used[scope] = n
+	return nil
+}
+
+// Snapshot returns a copy of the usage of every scope.
+func (u *MemoryUsage) Snapshot() map[string]int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make(map[string]int64, len(u.used))
+	for scope, n := range u.used {
+		out[scope] = n
+	}
+	return out
+}
+
+// StoredUsage keeps usage in memory and saves it as a JSON object in a
+// backend after every change, so that it survives restarts. Store it
+// outside the quota's scopes, or in a different backend.
+type StoredUsage struct {
+	backend omnistorage.Backend
+	path    string
+	mem     *MemoryUsage
+	mu      sync.Mutex // serializes saves
+}
+
+// NewStoredUsage loads the usage saved at path in backend, starting empty
+// if there is none.
+func NewStoredUsage(ctx context.Context, backend omnistorage.Backend, path string) (*StoredUsage, error) {
+	u := &StoredUsage{backend: backend, path: path, mem: NewMemoryUsage()}
+
+	r, err := backend.NewReader(ctx, path)
+	if omnistorage.IsNotFound(err) {
+		return u, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("quota: loading usage: %w", err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("quota: loading usage: %w", err)
+	}
+	if err := json.Unmarshal(data, &u.mem.used); err != nil {
+		return nil, fmt.Errorf("quota: parsing usage %s: %w", path, err)
+	}
+	if u.mem.used == nil {
+		u.mem.used = make(map[string]int64)
+	}
+	return u, nil
+}
+
+// Get returns the bytes used in scope.
+func (u *StoredUsage) Get(ctx context.Context, scope string) (int64, error) {
+	return u.mem.Get(ctx, scope)
+}
+
+// Add adds delta to the usage of scope and saves it.
+func (u *StoredUsage) Add(ctx context.Context, scope string, delta int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	_ = u.mem.Add(ctx, scope, delta)
+	return u.save(ctx)
+}
+
+// Set sets the usage of scope and saves it.
+func (u *StoredUsage) Set(ctx context.Context, scope string, n int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	_ = u.mem.Set(ctx, scope, n)
+	return u.save(ctx)
+}
+
+// Snapshot returns a copy of the usage of every scope.
+func (u *StoredUsage) Snapshot() map[string]int64 {
+	return u.mem.Snapshot()
+}
+
+// save writes the usage to the backend. Call with u.mu held.
+func (u *StoredUsage) save(ctx context.Context) error {
+	data, err := json.Marshal(u.mem.Snapshot())
+	if err != nil {
+		return err
+	}
+	w, err := u.backend.NewWriter(ctx, u.path, omnistorage.WithContentType("application/json"))
+	if err != nil {
+		return fmt.Errorf("quota: saving usage: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("quota: saving usage: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("quota: saving usage: %w", err)
+	}
+	return nil
+}
+
+// Ensure the usage types implement Usage
+var (
+	_ Usage = (*MemoryUsage)(nil)
+	_ Usage = (*StoredUsage)(nil)
+)
@@ -0,0 +1,299 @@
+// Package ratelimit provides a backend wrapper that limits the request
+// rate per class of operation.
+//
+// Bandwidth limits such as sync.Options.BandwidthLimit cap bytes, but a
+// job that lists, stats or deletes many small objects can still exceed a
+// provider's request rate, for example S3's 503 SlowDown responses, or
+// overload a small SFTP server. The wrapper gives each class its own
+// token bucket:
+//
+//	backend := ratelimit.New(s3Backend,
+//	    ratelimit.WithRate(ratelimit.Read, 500, 100),
+//	    ratelimit.WithRate(ratelimit.Write, 100, 20),
+//	    ratelimit.WithRate(ratelimit.List, 10, 1),
+//	)
+//
+// A bucket holds up to burst tokens and refills at the given rate; every
+// request takes one token, waiting for it if the bucket is empty. Waiting
+// ends early with the context's error if the context is canceled. Classes
+// without a rate are not limited, and data streamed through readers and
+// writers is not counted.
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Class is a class of operations that share a rate limit.
+type Class int
+
+// Classes.
+const (
+	// Read covers NewReader, Exists and Stat.
+	Read Class = iota
+
+	// Write covers NewWriter, Mkdir, Copy and Move.
+	Write
+
+	// List covers List.
+	List
+
+	// Delete covers Delete and Rmdir.
+	Delete
+
+	numClasses
+)
+
+// String returns the class name.
+func (c Class) String() string {
+	switch c {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case List:
+		return "list"
+	case Delete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Option configures a rate-limited backend.
+type Option func(*Backend)
+
+// WithRate limits class to perSecond requests per second on average, with
+// bursts of up to burst requests. A burst below 1 is treated as 1, and a
+// rate that is not positive removes the limit.
+func WithRate(class Class, perSecond float64, burst int) Option {
+	return func(b *Backend) {
+		if class < 0 || class >= numClasses {
+			return
+		}
+		b.limiters[class] = newBucket(perSecond, burst)
+	}
+}
+
+// WithDefaultRate applies WithRate to every class that has no rate of its
+// own. Each class still gets a separate bucket.
+func WithDefaultRate(perSecond float64, burst int) Option {
+	return func(b *Backend) {
+		b.defaultRate = perSecond
+		b.defaultBurst = burst
+	}
+}
+
+// Backend wraps a backend with request-rate limits. Extended operations
+// return omnistorage.ErrNotSupported if the wrapped backend does not
+// implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend      omnistorage.Backend
+	limiters     [numClasses]*bucket
+	defaultRate  float64
+	defaultBurst int
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend with the given rate limits. With no options,
+// operations pass through unchanged.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{backend: backend}
+	for _, opt := range opts {
+		opt(b)
+	}
+	for c, l := range b.limiters {
+		if l == nil {
+			b.limiters[c] = newBucket(b.defaultRate, b.defaultBurst)
+		}
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// wait takes a token for class, waiting if none is available.
+func (b *Backend) wait(ctx context.Context, class Class) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.limiters[class].wait(ctx)
+}
+
+// NewWriter creates a writer for the given path.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	if err := b.wait(ctx, Write); err != nil {
+		return nil, err
+	}
+	return b.backend.NewWriter(ctx, path, opts...)
+}
+
+// NewReader creates a reader for the given path.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if err := b.wait(ctx, Read); err != nil {
+		return nil, err
+	}
+	return b.backend.NewReader(ctx, path, opts...)
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	if err := b.wait(ctx, Read); err != nil {
+		return false, err
+	}
+	return b.backend.Exists(ctx, path)
+}
+
+// Delete removes a path.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	if err := b.wait(ctx, Delete); err != nil {
+		return err
+	}
+	return b.backend.Delete(ctx, path)
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := b.wait(ctx, List); err != nil {
+		return nil, err
+	}
+	return b.backend.List(ctx, prefix)
+}
+
+// Close closes the wrapped backend.
+func (b *Backend) Close() error {
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, Read); err != nil {
+		return nil, err
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, Write); err != nil {
+		return err
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, Delete); err != nil {
+		return err
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object within the wrapped backend.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, Write); err != nil {
+		return err
+	}
+	return ext.Copy(ctx, src, dst)
+}
+
+// Move moves an object within the wrapped backend.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, Write); err != nil {
+		return err
+	}
+	return ext.Move(ctx, src, dst)
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// bucket is a token bucket. Tokens may go negative: a request that finds
+// none takes the next one to be refilled and waits for it, so waiting
+// requests are served in order.
+type bucket struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newBucket returns a full bucket, or nil if perSecond is not positive.
+func newBucket(perSecond float64, burst int) *bucket {
+	if perSecond <= 0 {
+		return nil
+	}
+	burst = max(burst, 1)
+	return &bucket{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait takes a token, waiting until it is available. If ctx is done first,
+// the token is returned and ctx's error is returned.
+func (l *bucket) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
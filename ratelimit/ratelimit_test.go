@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), WithDefaultRate(1e6, 1000))
+	})
+}
+
+func TestRate(t *testing.T) {
+	b := New(memory.New(), WithRate(List, 50, 2))
+	ctx := context.Background()
+
+	// The burst is available at once, then requests are spaced at 20ms
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := b.List(ctx, ""); err != nil {
+			t.Fatalf("List error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("5 lists took %v, want at least 60ms", elapsed)
+	}
+
+	// Other classes are not limited
+	start = time.Now()
+	for i := 0; i < 20; i++ {
+		_, _ = b.Exists(ctx, "x")
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("20 unlimited Exists calls took %v", elapsed)
+	}
+}
+
+func TestClassesAreSeparate(t *testing.T) {
+	b := New(memory.New(), WithDefaultRate(1, 1))
+	ctx := context.Background()
+
+	// One token per class is available at once
+	start := time.Now()
+	_, _ = b.Exists(ctx, "x")
+	_, _ = b.List(ctx, "")
+	_ = b.Delete(ctx, "x")
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("one request per class took %v", elapsed)
+	}
+}
+
+func TestRateCanceled(t *testing.T) {
+	b := New(memory.New(), WithRate(Read, 0.1, 1))
+	ctx := context.Background()
+
+	if _, err := b.Exists(ctx, "x"); err != nil {
+		t.Fatalf("first Exists error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := b.NewReader(ctx, "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NewReader error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClassString(t *testing.T) {
+	for c, want := range map[Class]string{Read: "read", Write: "write", List: "list", Delete: "delete", Class(99): "unknown"} {
+		if got := c.String(); got != want {
+			t.Errorf("Class(%d).String() = %q, want %q", c, got, want)
+		}
+	}
+}
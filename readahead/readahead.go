@@ -0,0 +1,202 @@
+// Package readahead provides a backend wrapper whose readers prefetch the
+// chunks ahead of the reader with concurrent ranged reads.
+//
+// A single stream from a high-latency backend, such as S3 over a VPN or
+// SFTP, spends most of its time waiting. Readers of the wrapper keep the
+// next chunks in flight while the consumer works on the current one, so
+// sequential consumers such as decompressors see the backend's bandwidth
+// rather than its latency:
+//
+//	backend := readahead.New(sftpBackend,
+//	    readahead.WithChunkSize(1<<20),
+//	    readahead.WithAhead(8),
+//	)
+//	r, _ := backend.NewReader(ctx, "dumps/db.tar.zst")
+//	zr, _ := zstd.NewReader(r)
+//
+// Readers are built on omnistorage.NewParallelReader. Objects no larger
+// than one chunk, and backends without range reads, are read with a
+// single stream as usual.
+package readahead
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/grokify/omnistorage"
+)
+
+const (
+	// DefaultChunkSize is the chunk size used unless WithChunkSize is
+	// given.
+	DefaultChunkSize = 1 << 20
+
+	// DefaultAhead is the number of chunks in flight unless WithAhead is
+	// given.
+	DefaultAhead = 8
+)
+
+// Option configures a readahead backend.
+type Option func(*Backend)
+
+// WithChunkSize sets the size of each ranged read. Default:
+// DefaultChunkSize.
+func WithChunkSize(n int64) Option {
+	return func(b *Backend) {
+		if n > 0 {
+			b.chunkSize = n
+		}
+	}
+}
+
+// WithAhead sets how many chunks each reader fetches at once, which is
+// also how many it holds in memory. Default: DefaultAhead.
+func WithAhead(n int) Option {
+	return func(b *Backend) {
+		if n > 0 {
+			b.ahead = n
+		}
+	}
+}
+
+// Backend wraps a backend with prefetching readers. Extended operations
+// return omnistorage.ErrNotSupported if the wrapped backend does not
+// implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend   omnistorage.Backend
+	chunkSize int64
+	ahead     int
+	closed    atomic.Bool
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend with prefetching readers.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{backend: backend, chunkSize: DefaultChunkSize, ahead: DefaultAhead}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// NewWriter creates a writer for the given path.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	return b.backend.NewWriter(ctx, path, opts...)
+}
+
+// NewReader creates a reader for path that prefetches chunks ahead of the
+// reader, if the wrapped backend supports range reads and more than one
+// chunk is to be read. It stats path first to learn its size. WithOffset
+// and WithLimit select the part read, as with the wrapped backend. A
+// chunk that fails fails the reader.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if b.closed.Load() {
+		return nil, omnistorage.ErrBackendClosed
+	}
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok || !ext.Features().RangeRead {
+		return b.backend.NewReader(ctx, path, opts...)
+	}
+	info, err := ext.Stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := omnistorage.ApplyReaderOptions(opts...)
+	start := min(max(cfg.Offset, 0), info.Size())
+	end := info.Size()
+	if cfg.Limit > 0 {
+		end = min(end, start+cfg.Limit)
+	}
+	if info.IsDir() || end-start <= b.chunkSize {
+		return b.backend.NewReader(ctx, path, opts...)
+	}
+	return omnistorage.NewParallelReader(ctx, b.backend, path, end, omnistorage.ParallelReadOptions{
+		ChunkSize:     b.chunkSize,
+		Concurrency:   b.ahead,
+		ReaderOptions: opts,
+		Offset:        start,
+	}), nil
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	return b.backend.Exists(ctx, path)
+}
+
+// Delete removes a path.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	return b.backend.Delete(ctx, path)
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	return b.backend.List(ctx, prefix)
+}
+
+// Close closes the wrapped backend.
+func (b *Backend) Close() error {
+	b.closed.Store(true)
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Copy(ctx, src, dst)
+}
+
+// Move moves an object.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Move(ctx, src, dst)
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
@@ -0,0 +1,72 @@
+package readahead
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), WithChunkSize(4), WithAhead(2))
+	})
+}
+
+// rangeCounter counts the reads of a memory backend.
+type rangeCounter struct {
+	*memory.Backend
+	reads atomic.Int32
+}
+
+func (b *rangeCounter) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	b.reads.Add(1)
+	return b.Backend.NewReader(ctx, p, opts...)
+}
+
+func TestReadahead(t *testing.T) {
+	ctx := context.Background()
+	inner := &rangeCounter{Backend: memory.New()}
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	w, _ := inner.NewWriter(ctx, "big.bin")
+	_, _ = w.Write(data)
+	_ = w.Close()
+
+	b := New(inner, WithChunkSize(100), WithAhead(3))
+
+	tests := []struct {
+		name  string
+		opts  []omnistorage.ReaderOption
+		want  []byte
+		reads int32
+	}{
+		{"whole", nil, data, 10},
+		{"offset", []omnistorage.ReaderOption{omnistorage.WithOffset(250)}, data[250:], 8},
+		{"range", []omnistorage.ReaderOption{omnistorage.WithOffset(250), omnistorage.WithLimit(300)}, data[250:550], 3},
+		{"one chunk", []omnistorage.ReaderOption{omnistorage.WithOffset(950)}, data[950:], 1},
+	}
+	for _, tc := range tests {
+		inner.reads.Store(0)
+		r, err := b.NewReader(ctx, "big.bin", tc.opts...)
+		if err != nil {
+			t.Fatalf("%s: NewReader error = %v", tc.name, err)
+		}
+		got, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil || !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: read %d bytes, %v, want %d", tc.name, len(got), err, len(tc.want))
+		}
+		if n := inner.reads.Load(); n != tc.reads {
+			t.Errorf("%s: %d reads, want %d", tc.name, n, tc.reads)
+		}
+	}
+
+	if _, err := b.NewReader(ctx, "missing.bin"); !omnistorage.IsNotFound(err) {
+		t.Errorf("NewReader(missing) error = %v, want ErrNotFound", err)
+	}
+}
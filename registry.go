@@ -8,15 +8,98 @@ import (
 
 var (
 	backendsMu sync.RWMutex
-	backends   = make(map[string]BackendFactory)
+	backends   = make(map[string]*registration)
 )
 
 // BackendFactory creates a Backend from configuration.
 // The config map contains backend-specific configuration keys.
 type BackendFactory func(config map[string]string) (Backend, error)
 
+// BackendInfo describes a registered backend.
+type BackendInfo struct {
+	// Name is the name the backend is registered under.
+	Name string
+
+	// Description is a short human-readable summary.
+	Description string
+
+	// ConfigKeys lists the keys accepted in the config map passed to Open.
+	ConfigKeys []ConfigKey
+
+	// Features are the backend's capabilities.
+	Features Features
+
+	// Lazy reports whether the factory is resolved on first Open.
+	Lazy bool
+}
+
+// ConfigKey describes a configuration key accepted by a backend.
+type ConfigKey struct {
+	// Name is the key in the config map.
+	Name string
+
+	// Description explains the key's meaning and format.
+	Description string
+
+	// Required reports whether Open fails without the key.
+	Required bool
+
+	// Default is the value used when the key is absent, if any.
+	Default string
+
+	// Secret marks credentials that should not be logged or displayed.
+	Secret bool
+}
+
+// RegisterOption configures a backend registration.
+type RegisterOption func(*BackendInfo)
+
+// WithDescription sets the backend's description.
+func WithDescription(description string) RegisterOption {
+	return func(info *BackendInfo) {
+		info.Description = description
+	}
+}
+
+// WithConfigKeys documents the configuration keys the backend accepts.
+func WithConfigKeys(keys ...ConfigKey) RegisterOption {
+	return func(info *BackendInfo) {
+		info.ConfigKeys = append(info.ConfigKeys, keys...)
+	}
+}
+
+// WithFeatures records the backend's capabilities.
+func WithFeatures(features Features) RegisterOption {
+	return func(info *BackendInfo) {
+		info.Features = features
+	}
+}
+
+// registration is a registered backend. Lazy registrations resolve their
+// factory once, on first use.
+type registration struct {
+	info    BackendInfo
+	factory BackendFactory
+	load    func() (BackendFactory, error)
+	once    sync.Once
+	err     error
+}
+
+func (r *registration) resolve() (BackendFactory, error) {
+	if r.load != nil {
+		r.once.Do(func() {
+			r.factory, r.err = r.load()
+			if r.err == nil && r.factory == nil {
+				r.err = fmt.Errorf("omnistorage: lazy backend %s returned nil factory", r.info.Name)
+			}
+		})
+	}
+	return r.factory, r.err
+}
+
 // Register registers a backend factory under the given name.
 // It is typically called from init() in backend packages.
+// Options describe the backend for Describe.
 //
 // Register panics if:
 //   - factory is nil
@@ -25,19 +108,44 @@ type BackendFactory func(config map[string]string) (Backend, error)
 // Example:
 //
 //	func init() {
-//	    omnistorage.Register("mybackend", New)
+//	    omnistorage.Register("mybackend", New,
+//	        omnistorage.WithDescription("My storage service"),
+//	        omnistorage.WithConfigKeys(omnistorage.ConfigKey{Name: "root", Required: true}))
 //	}
-func Register(name string, factory BackendFactory) {
-	backendsMu.Lock()
-	defer backendsMu.Unlock()
-
+func Register(name string, factory BackendFactory, opts ...RegisterOption) {
 	if factory == nil {
 		panic("omnistorage: Register factory is nil")
 	}
+	register(name, &registration{factory: factory}, opts)
+}
+
+// RegisterLazy registers a backend whose factory is resolved by load the
+// first time the backend is opened, rather than at registration. Use it
+// when building the factory is expensive, such as loading SDK
+// configuration or credentials, so programs that import the backend but
+// never open it do not pay that cost. The result of load, including an
+// error, is cached.
+//
+// RegisterLazy panics under the same conditions as Register.
+func RegisterLazy(name string, load func() (BackendFactory, error), opts ...RegisterOption) {
+	if load == nil {
+		panic("omnistorage: RegisterLazy load is nil")
+	}
+	register(name, &registration{load: load}, append(opts, func(info *BackendInfo) { info.Lazy = true }))
+}
+
+func register(name string, r *registration, opts []RegisterOption) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
 	if _, dup := backends[name]; dup {
 		panic("omnistorage: Register called twice for backend " + name)
 	}
-	backends[name] = factory
+	for _, opt := range opts {
+		opt(&r.info)
+	}
+	r.info.Name = name
+	backends[name] = r
 }
 
 // Open opens a backend by name with the given configuration.
@@ -53,15 +161,37 @@ func Register(name string, factory BackendFactory) {
 //	})
 func Open(name string, config map[string]string) (Backend, error) {
 	backendsMu.RLock()
-	factory, ok := backends[name]
+	r, ok := backends[name]
 	backendsMu.RUnlock()
 
 	if !ok {
 		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
 	}
+	factory, err := r.resolve()
+	if err != nil {
+		return nil, err
+	}
 	return factory(config)
 }
 
+// Describe returns the description of a registered backend.
+// It does not resolve lazy backends.
+//
+// Describe returns ErrUnknownBackend if no backend with the given name is registered.
+func Describe(name string) (BackendInfo, error) {
+	backendsMu.RLock()
+	r, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return BackendInfo{}, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
+	}
+	info := r.info
+	info.ConfigKeys = append([]ConfigKey(nil), r.info.ConfigKeys...)
+	info.Features.Hashes = append([]HashType(nil), r.info.Features.Hashes...)
+	return info, nil
+}
+
 // Backends returns a sorted list of registered backend names.
 func Backends() []string {
 	backendsMu.RLock()
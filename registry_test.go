@@ -0,0 +1,124 @@
+package omnistorage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	const name = "test-describe"
+	Register(name, func(map[string]string) (Backend, error) { return nil, nil },
+		WithDescription("Test backend"),
+		WithConfigKeys(
+			ConfigKey{Name: "root", Required: true},
+			ConfigKey{Name: "token", Secret: true},
+		),
+		WithFeatures(Features{Copy: true, Hashes: []HashType{HashMD5}}))
+	defer Unregister(name)
+
+	info, err := Describe(name)
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if info.Name != name || info.Description != "Test backend" || info.Lazy {
+		t.Errorf("Describe = %+v", info)
+	}
+	if len(info.ConfigKeys) != 2 || !info.ConfigKeys[0].Required || !info.ConfigKeys[1].Secret {
+		t.Errorf("ConfigKeys = %+v", info.ConfigKeys)
+	}
+	if !info.Features.Copy || !info.Features.SupportsHash(HashMD5) {
+		t.Errorf("Features = %+v", info.Features)
+	}
+
+	// The returned info is a copy.
+	info.ConfigKeys[0].Name = "changed"
+	info.Features.Hashes[0] = HashSHA1
+	again, _ := Describe(name)
+	if again.ConfigKeys[0].Name != "root" || again.Features.Hashes[0] != HashMD5 {
+		t.Error("Describe returned shared slices")
+	}
+
+	if _, err := Describe("test-missing"); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("Describe unknown: err = %v, want ErrUnknownBackend", err)
+	}
+}
+
+func TestRegisterLazy(t *testing.T) {
+	const name = "test-lazy"
+	loads := 0
+	opens := 0
+	RegisterLazy(name, func() (BackendFactory, error) {
+		loads++
+		return func(map[string]string) (Backend, error) {
+			opens++
+			return nil, nil
+		}, nil
+	}, WithDescription("Lazy backend"))
+	defer Unregister(name)
+
+	info, err := Describe(name)
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if !info.Lazy || info.Description != "Lazy backend" {
+		t.Errorf("Describe = %+v", info)
+	}
+	if loads != 0 {
+		t.Errorf("loads before Open = %d, want 0", loads)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := Open(name, nil); err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+	}
+	if loads != 1 || opens != 3 {
+		t.Errorf("loads = %d, opens = %d, want 1 and 3", loads, opens)
+	}
+}
+
+func TestRegisterLazyError(t *testing.T) {
+	const name = "test-lazy-error"
+	errLoad := errors.New("sdk unavailable")
+	RegisterLazy(name, func() (BackendFactory, error) { return nil, errLoad })
+	defer Unregister(name)
+
+	for i := 0; i < 2; i++ {
+		if _, err := Open(name, nil); !errors.Is(err, errLoad) {
+			t.Errorf("Open: err = %v, want %v", err, errLoad)
+		}
+	}
+
+	const nilName = "test-lazy-nil"
+	RegisterLazy(nilName, func() (BackendFactory, error) { return nil, nil })
+	defer Unregister(nilName)
+	if _, err := Open(nilName, nil); err == nil {
+		t.Error("Open with nil lazy factory: expected error")
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	const name = "test-dup"
+	factory := func(map[string]string) (Backend, error) { return nil, nil }
+	Register(name, factory)
+	defer Unregister(name)
+
+	for _, tt := range []struct {
+		name string
+		fn   func()
+	}{
+		{"duplicate", func() { Register(name, factory) }},
+		{"duplicate lazy", func() { RegisterLazy(name, func() (BackendFactory, error) { return factory, nil }) }},
+		{"nil factory", func() { Register("test-nil", nil) }},
+		{"nil load", func() { RegisterLazy("test-nil", nil) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", tt.name)
+				}
+			}()
+			tt.fn()
+		}()
+	}
+}
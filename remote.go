@@ -0,0 +1,106 @@
+package omnistorage
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+)
+
+var (
+	remotesMu sync.RWMutex
+	remotes   = make(map[string]Remote)
+)
+
+// Remote is a named backend configuration, such as "archive" for an S3
+// bucket with its region and credentials. Remotes are usually loaded from
+// a config file by the config package.
+type Remote struct {
+	// Name is the name the remote is opened by.
+	Name string
+
+	// Backend is the registered backend name, such as "s3".
+	Backend string
+
+	// Config is passed to the backend's factory. Values may hold
+	// unresolved secret references; see Resolve.
+	Config map[string]string
+
+	// Resolve, if set, is called by OpenRemote with a copy of Config and
+	// returns the configuration to open the backend with. It resolves
+	// secret references, such as environment variables, when the remote
+	// is opened rather than when it is defined.
+	Resolve func(config map[string]string) (map[string]string, error)
+}
+
+// AddRemote adds a remote, replacing any remote with the same name.
+func AddRemote(r Remote) {
+	remotesMu.Lock()
+	defer remotesMu.Unlock()
+	r.Config = maps.Clone(r.Config)
+	remotes[r.Name] = r
+}
+
+// LookupRemote returns the remote with the given name.
+func LookupRemote(name string) (Remote, bool) {
+	remotesMu.RLock()
+	defer remotesMu.RUnlock()
+	r, ok := remotes[name]
+	r.Config = maps.Clone(r.Config)
+	return r, ok
+}
+
+// Remotes returns a sorted list of remote names.
+func Remotes() []string {
+	remotesMu.RLock()
+	defer remotesMu.RUnlock()
+
+	names := make([]string, 0, len(remotes))
+	for name := range remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RemoveRemote removes a remote.
+// Returns true if the remote existed, false otherwise.
+func RemoveRemote(name string) bool {
+	remotesMu.Lock()
+	defer remotesMu.Unlock()
+
+	if _, ok := remotes[name]; ok {
+		delete(remotes, name)
+		return true
+	}
+	return false
+}
+
+// OpenRemote opens the backend of the named remote.
+//
+// OpenRemote returns ErrUnknownRemote if no remote with the given name has
+// been added, and ErrUnknownBackend if its backend is not registered.
+//
+// Example:
+//
+//	cfg, err := config.Load("omnistorage.toml")
+//	if err != nil {
+//	    return err
+//	}
+//	cfg.Register()
+//	backend, err := omnistorage.OpenRemote("archive")
+func OpenRemote(name string) (Backend, error) {
+	r, ok := LookupRemote(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRemote, name)
+	}
+
+	config := r.Config
+	if r.Resolve != nil {
+		var err error
+		if config, err = r.Resolve(config); err != nil {
+			return nil, fmt.Errorf("omnistorage: remote %s: %w", name, err)
+		}
+	}
+	return Open(r.Backend, config)
+}
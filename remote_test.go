@@ -0,0 +1,70 @@
+package omnistorage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestOpenRemote(t *testing.T) {
+	const backendName = "test-remote-backend"
+	var got map[string]string
+	Register(backendName, func(config map[string]string) (Backend, error) {
+		got = config
+		return nil, nil
+	})
+	defer Unregister(backendName)
+
+	AddRemote(Remote{
+		Name:    "test-archive",
+		Backend: backendName,
+		Config:  map[string]string{"bucket": "archive", "token": "${TOKEN}"},
+		Resolve: func(config map[string]string) (map[string]string, error) {
+			config["token"] = "resolved"
+			return config, nil
+		},
+	})
+	defer RemoveRemote("test-archive")
+
+	if !slices.Contains(Remotes(), "test-archive") {
+		t.Errorf("Remotes() = %v, want test-archive", Remotes())
+	}
+
+	if _, err := OpenRemote("test-archive"); err != nil {
+		t.Fatalf("OpenRemote: %v", err)
+	}
+	if got["bucket"] != "archive" || got["token"] != "resolved" {
+		t.Errorf("factory config = %v", got)
+	}
+
+	// Resolve works on a copy; the stored config keeps the reference.
+	r, _ := LookupRemote("test-archive")
+	if r.Config["token"] != "${TOKEN}" {
+		t.Errorf("stored token = %q, want unresolved reference", r.Config["token"])
+	}
+
+	if _, err := OpenRemote("test-missing"); !errors.Is(err, ErrUnknownRemote) {
+		t.Errorf("OpenRemote unknown: err = %v, want ErrUnknownRemote", err)
+	}
+
+	AddRemote(Remote{Name: "test-bad", Backend: "test-missing-backend"})
+	defer RemoveRemote("test-bad")
+	if _, err := OpenRemote("test-bad"); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("OpenRemote with unknown backend: err = %v, want ErrUnknownBackend", err)
+	}
+
+	resolveErr := errors.New("no secret")
+	AddRemote(Remote{
+		Name:    "test-secret",
+		Backend: backendName,
+		Resolve: func(map[string]string) (map[string]string, error) { return nil, resolveErr },
+	})
+	defer RemoveRemote("test-secret")
+	if _, err := OpenRemote("test-secret"); !errors.Is(err, resolveErr) {
+		t.Errorf("OpenRemote with failing Resolve: err = %v", err)
+	}
+
+	if !RemoveRemote("test-bad") || RemoveRemote("test-bad") {
+		t.Error("RemoveRemote did not report whether the remote existed")
+	}
+}
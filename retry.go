@@ -0,0 +1,353 @@
+package omnistorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+)
+
+// RetryConfig configures retry behavior for failed operations, for Retry,
+// WithRetry and sync.
+type RetryConfig struct {
+	// MaxRetries is the maximum number of retry attempts.
+	// 0 means no retries (fail on first error).
+	MaxRetries int
+
+	// InitialDelay is the delay before the first retry.
+	// Default is 1 second.
+	InitialDelay time.Duration
+
+	// MaxDelay is the maximum delay between retries.
+	// Default is 30 seconds.
+	MaxDelay time.Duration
+
+	// Multiplier is the factor by which delay increases after each retry.
+	// Default is 2.0 (exponential backoff).
+	Multiplier float64
+
+	// Jitter adds randomness to delays to prevent thundering herd.
+	// 0.1 means +/- 10% random variation. Default is 0.1.
+	Jitter float64
+
+	// RetryableErrors is a function that determines if an error should be retried.
+	// If nil, errors are classified by ClassifyError and only permanent
+	// errors, such as ErrNotFound or HTTP 403, fail without retrying.
+	RetryableErrors func(error) bool
+
+	// AttemptTimeout bounds each attempt. An attempt that times out is
+	// retried. 0 means attempts are bounded only by the context.
+	AttemptTimeout time.Duration
+
+	// ThrottleDelay is the minimum delay before retrying a throttled error,
+	// such as HTTP 429 or S3 SlowDown. 0 uses the normal backoff.
+	ThrottleDelay time.Duration
+
+	// OnRetry is called before each retry. It can be used for logging or
+	// metrics. It is not called when the final attempt fails.
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent describes a failed attempt that is about to be retried.
+type RetryEvent struct {
+	// Attempt is the number of the attempt that failed, starting at 1.
+	Attempt int
+
+	// Err is the error the attempt failed with.
+	Err error
+
+	// Class is the classification of Err.
+	Class ErrorClass
+
+	// Delay is the wait before the next attempt.
+	Delay time.Duration
+}
+
+// DefaultRetryConfig returns retry config with sensible defaults.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries:   3,
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2.0,
+		Jitter:       0.1,
+	}
+}
+
+// Retry runs op until it succeeds, retrying failed attempts with
+// exponential backoff as configured. Each attempt gets a context bounded
+// by config.AttemptTimeout. Permanent errors, context cancellation and the
+// error of the last attempt end the retries; the last is wrapped in a
+// RetryError.
+func Retry(ctx context.Context, config RetryConfig, op func(context.Context) error) error {
+	attempt := func() error {
+		if config.AttemptTimeout <= 0 {
+			return op(ctx)
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, config.AttemptTimeout)
+		defer cancel()
+		return op(attemptCtx)
+	}
+
+	if config.MaxRetries <= 0 {
+		return attempt()
+	}
+
+	// Apply defaults
+	if config.InitialDelay <= 0 {
+		config.InitialDelay = time.Second
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 30 * time.Second
+	}
+	if config.Multiplier <= 0 {
+		config.Multiplier = 2.0
+	}
+
+	var lastErr error
+	delay := config.InitialDelay
+
+	for n := 0; n <= config.MaxRetries; n++ {
+		// Try the operation
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+
+		// Check if context is cancelled
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Check if error is retryable
+		if !config.retryable(err) {
+			return err
+		}
+		class := ClassifyError(err)
+
+		// Don't delay after the last attempt
+		if n == config.MaxRetries {
+			break
+		}
+
+		// Apply jitter to delay.
+		// Using math/rand is intentional - crypto/rand is unnecessary for timing jitter.
+		// Jitter is used only to spread out retry timing to avoid thundering herd,
+		// not for any security purpose.
+		actualDelay := delay
+		if config.Jitter > 0 {
+			jitter := float64(delay) * config.Jitter
+			actualDelay = delay + time.Duration((rand.Float64()*2-1)*jitter) //nolint:gosec // G404: math/rand is appropriate for timing jitter
+			actualDelay = min(actualDelay, config.MaxDelay)
+		}
+		if class == ErrorThrottled && actualDelay < config.ThrottleDelay {
+			actualDelay = config.ThrottleDelay
+		}
+
+		if config.OnRetry != nil {
+			config.OnRetry(RetryEvent{Attempt: n + 1, Err: err, Class: class, Delay: actualDelay})
+		}
+
+		// Wait before retry
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(actualDelay):
+		}
+
+		// Increase delay for next iteration
+		delay = time.Duration(float64(delay) * config.Multiplier)
+		if delay > config.MaxDelay {
+			delay = config.MaxDelay
+		}
+	}
+
+	return &RetryError{
+		Attempts: config.MaxRetries + 1,
+		LastErr:  lastErr,
+	}
+}
+
+// retryable reports whether err should be retried.
+func (c RetryConfig) retryable(err error) bool {
+	if c.RetryableErrors != nil {
+		return c.RetryableErrors(err)
+	}
+	return ClassifyError(err) != ErrorPermanent
+}
+
+// RetryError indicates an operation failed after all retry attempts.
+type RetryError struct {
+	Attempts int
+	LastErr  error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("operation failed after %d attempts: %v", e.Attempts, e.LastErr)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.LastErr
+}
+
+// IsRetryError returns true if err is a RetryError.
+func IsRetryError(err error) bool {
+	var re *RetryError
+	return errors.As(err, &re)
+}
+
+// IsTemporaryError returns true if err is likely temporary and worth retrying.
+// This is a reasonable default for RetryConfig.RetryableErrors.
+func IsTemporaryError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Check for temporary interface (many network errors implement this)
+	var temp interface{ Temporary() bool }
+	if errors.As(err, &temp) {
+		return temp.Temporary()
+	}
+
+	// Check for timeout interface
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) {
+		return timeout.Timeout()
+	}
+
+	return false
+}
+
+// ErrorClass classifies an error for retrying.
+type ErrorClass int
+
+const (
+	// ErrorUnknown is an error that could not be classified. It is retried.
+	ErrorUnknown ErrorClass = iota
+
+	// ErrorTransient is a temporary failure, such as a network timeout, a
+	// reset connection, or an HTTP 5xx response. It is retried.
+	ErrorTransient
+
+	// ErrorThrottled is a rate-limit response, such as HTTP 429 or S3
+	// SlowDown. It is retried, after at least RetryConfig.ThrottleDelay.
+	ErrorThrottled
+
+	// ErrorPermanent is a failure that retrying cannot fix, such as
+	// ErrNotFound, ErrPermissionDenied, or an HTTP 4xx response. It is
+	// not retried.
+	ErrorPermanent
+)
+
+// String returns the class name.
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorTransient:
+		return "transient"
+	case ErrorThrottled:
+		return "throttled"
+	case ErrorPermanent:
+		return "permanent"
+	default:
+		return "unknown"
+	}
+}
+
+// throttleCodes are service error codes that mean the request was rate
+// limited.
+var throttleCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottled":                       true,
+	"RequestThrottledException":              true,
+	"TooManyRequestsException":               true,
+	"ProvisionedThroughputExceededException": true,
+	"TransactionInProgressException":         true,
+	"RequestLimitExceeded":                   true,
+	"BandwidthLimitExceeded":                 true,
+	"LimitExceededException":                 true,
+	"SlowDown":                               true,
+	"EC2ThrottledException":                  true,
+}
+
+// transientCodes are service error codes for temporary server failures.
+var transientCodes = map[string]bool{
+	"RequestTimeout":          true,
+	"RequestTimeoutException": true,
+	"InternalError":           true,
+	"InternalFailure":         true,
+	"ServiceUnavailable":      true,
+	"OperationAborted":        true,
+}
+
+// ClassifyError classifies err for retrying. It recognizes omnistorage
+// errors, context errors, HTTP status codes and service error codes
+// exposed by SDK errors (HTTPStatusCode() int and ErrorCode() string, as
+// implemented by the AWS SDK), and network errors. EAGAIN, which an SFTP
+// server short of resources returns, is throttling.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ErrorUnknown
+	case errors.Is(err, context.Canceled):
+		return ErrorPermanent
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrTimeout):
+		return ErrorTransient
+	case errors.Is(err, ErrThrottled), errors.Is(err, syscall.EAGAIN):
+		return ErrorThrottled
+	case errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrPermissionDenied),
+		errors.Is(err, ErrInvalidPath),
+		errors.Is(err, ErrNotSupported),
+		errors.Is(err, ErrQuotaExceeded),
+		errors.Is(err, ErrAlreadyExists),
+		errors.Is(err, ErrBackendClosed),
+		errors.Is(err, ErrWriterClosed),
+		errors.Is(err, ErrReaderClosed):
+		return ErrorPermanent
+	}
+
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		switch code := coded.ErrorCode(); {
+		case throttleCodes[code]:
+			return ErrorThrottled
+		case transientCodes[code]:
+			return ErrorTransient
+		}
+	}
+
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		switch code := status.HTTPStatusCode(); {
+		case code == 429:
+			return ErrorThrottled
+		case code == 408 || code >= 500:
+			return ErrorTransient
+		case code >= 400:
+			return ErrorPermanent
+		}
+	}
+
+	if IsTemporaryError(err) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return ErrorTransient
+	}
+
+	return ErrorUnknown
+}
+
+// IsRetryable returns true unless err is classified as ErrorPermanent.
+func IsRetryable(err error) bool {
+	return err != nil && ClassifyError(err) != ErrorPermanent
+}
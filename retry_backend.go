@@ -0,0 +1,248 @@
+package omnistorage
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// RetryBackend is a backend whose operations are retried on transient
+// errors. Create one with WithRetry.
+type RetryBackend struct {
+	backend Backend
+	config  RetryConfig
+}
+
+// Ensure RetryBackend implements ExtendedBackend.
+var _ ExtendedBackend = (*RetryBackend)(nil)
+
+// WithRetry wraps backend so that every operation is retried as configured
+// by config, the same retries sync applies to file transfers, for callers
+// that use the backend directly.
+//
+// Opening a reader or writer is retried. A reader also resumes after a
+// transient read error, by reopening at the offset it had reached, if the
+// backend reports Features().RangeRead. Data passed to a writer cannot be
+// replayed, so write and Close errors are returned as they are.
+// RetryConfig.AttemptTimeout bounds the other operations only, since
+// readers and writers use their context until they are closed.
+//
+// Extended operations return ErrNotSupported if backend does not implement
+// ExtendedBackend.
+func WithRetry(backend Backend, config RetryConfig) *RetryBackend {
+	return &RetryBackend{backend: backend, config: config}
+}
+
+// Unwrap returns the wrapped backend.
+func (b *RetryBackend) Unwrap() Backend {
+	return b.backend
+}
+
+// retry runs op with b's retry configuration.
+func (b *RetryBackend) retry(ctx context.Context, op func(context.Context) error) error {
+	return Retry(ctx, b.config, op)
+}
+
+// retryOpen runs op, which opens a reader or writer, with b's retry
+// configuration but without AttemptTimeout: the reader or writer keeps
+// using the context it was opened with after op returns, so it must not
+// be one canceled at the end of the attempt.
+func (b *RetryBackend) retryOpen(ctx context.Context, op func(context.Context) error) error {
+	config := b.config
+	config.AttemptTimeout = 0
+	return Retry(ctx, config, op)
+}
+
+// NewWriter creates a writer for the given path, retrying if it cannot be
+// opened.
+func (b *RetryBackend) NewWriter(ctx context.Context, path string, opts ...WriterOption) (io.WriteCloser, error) {
+	var w io.WriteCloser
+	err := b.retryOpen(ctx, func(ctx context.Context) error {
+		var err error
+		w, err = b.backend.NewWriter(ctx, path, opts...)
+		return err
+	})
+	return w, err
+}
+
+// NewReader creates a reader for the given path, retrying if it cannot be
+// opened. See WithRetry for how read errors are retried.
+func (b *RetryBackend) NewReader(ctx context.Context, path string, opts ...ReaderOption) (io.ReadCloser, error) {
+	open := func(opts ...ReaderOption) (io.ReadCloser, error) {
+		var r io.ReadCloser
+		err := b.retryOpen(ctx, func(ctx context.Context) error {
+			var err error
+			r, err = b.backend.NewReader(ctx, path, opts...)
+			return err
+		})
+		return r, err
+	}
+
+	r, err := open(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ext, ok := AsExtended(b.backend); !ok || !ext.Features().RangeRead || b.config.MaxRetries <= 0 {
+		return r, nil
+	}
+	return &resumingReader{r: r, open: open, opts: opts, config: ApplyReaderOptions(opts...), retry: b.config}, nil
+}
+
+// Exists checks if a path exists.
+func (b *RetryBackend) Exists(ctx context.Context, path string) (bool, error) {
+	var exists bool
+	err := b.retry(ctx, func(ctx context.Context) error {
+		var err error
+		exists, err = b.backend.Exists(ctx, path)
+		return err
+	})
+	return exists, err
+}
+
+// Delete removes a path.
+func (b *RetryBackend) Delete(ctx context.Context, path string) error {
+	return b.retry(ctx, func(ctx context.Context) error {
+		return b.backend.Delete(ctx, path)
+	})
+}
+
+// List lists paths with the given prefix.
+func (b *RetryBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	var paths []string
+	err := b.retry(ctx, func(ctx context.Context) error {
+		var err error
+		paths, err = b.backend.List(ctx, prefix)
+		return err
+	})
+	return paths, err
+}
+
+// Close closes the wrapped backend.
+func (b *RetryBackend) Close() error {
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *RetryBackend) Stat(ctx context.Context, path string) (ObjectInfo, error) {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	var info ObjectInfo
+	err := b.retry(ctx, func(ctx context.Context) error {
+		var err error
+		info, err = ext.Stat(ctx, path)
+		return err
+	})
+	return info, err
+}
+
+// Mkdir creates a directory.
+func (b *RetryBackend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return ErrNotSupported
+	}
+	return b.retry(ctx, func(ctx context.Context) error {
+		return ext.Mkdir(ctx, path)
+	})
+}
+
+// Rmdir removes an empty directory.
+func (b *RetryBackend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return ErrNotSupported
+	}
+	return b.retry(ctx, func(ctx context.Context) error {
+		return ext.Rmdir(ctx, path)
+	})
+}
+
+// Copy copies an object within the wrapped backend.
+func (b *RetryBackend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return ErrNotSupported
+	}
+	return b.retry(ctx, func(ctx context.Context) error {
+		return ext.Copy(ctx, src, dst)
+	})
+}
+
+// Move moves an object within the wrapped backend. If an attempt moved the
+// object but still failed, the retry fails with ErrNotFound.
+func (b *RetryBackend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return ErrNotSupported
+	}
+	return b.retry(ctx, func(ctx context.Context) error {
+		return ext.Move(ctx, src, dst)
+	})
+}
+
+// Features returns the features of the wrapped backend.
+func (b *RetryBackend) Features() Features {
+	if ext, ok := AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return Features{}
+}
+
+// resumingReader reopens its object at the current offset after a
+// retryable read error.
+type resumingReader struct {
+	r      io.ReadCloser
+	open   func(opts ...ReaderOption) (io.ReadCloser, error)
+	opts   []ReaderOption
+	config *ReaderConfig // as requested
+	retry  RetryConfig
+	read   int64 // bytes read so far
+	fails  int   // consecutive failed reads
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, ErrReaderClosed
+	}
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if err == nil || err == io.EOF {
+		r.fails = 0
+		return n, err
+	}
+	if !r.retry.retryable(err) || r.fails >= r.retry.MaxRetries {
+		return n, err
+	}
+	r.fails++
+
+	opts := append(r.opts[:len(r.opts):len(r.opts)], WithOffset(r.config.Offset+r.read))
+	if r.config.Limit > 0 {
+		opts = append(opts, WithLimit(r.config.Limit-r.read))
+	}
+	next, openErr := r.open(opts...)
+	if openErr != nil {
+		return n, err
+	}
+	_ = r.r.Close()
+	r.r = next
+	return n, nil
+}
+
+func (r *resumingReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.r.Close()
+}
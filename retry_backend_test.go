@@ -0,0 +1,223 @@
+package omnistorage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// flakyError is a transient error.
+type flakyError struct{}
+
+func (flakyError) Error() string { return "flaky" }
+func (flakyError) Timeout() bool { return true }
+
+// flakyBackend fails the first failures calls to List and Exists, and
+// makes readers fail once after failAfter bytes.
+type flakyBackend struct {
+	*memory.Backend
+	failures  int
+	failAfter int
+	calls     int
+}
+
+func (b *flakyBackend) fail() error {
+	b.calls++
+	if b.failures > 0 {
+		b.failures--
+		return flakyError{}
+	}
+	return nil
+}
+
+func (b *flakyBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := b.fail(); err != nil {
+		return nil, err
+	}
+	return b.Backend.List(ctx, prefix)
+}
+
+func (b *flakyBackend) Exists(ctx context.Context, p string) (bool, error) {
+	if err := b.fail(); err != nil {
+		return false, err
+	}
+	return b.Backend.Exists(ctx, p)
+}
+
+func (b *flakyBackend) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	r, err := b.Backend.NewReader(ctx, p, opts...)
+	if err != nil || b.failAfter <= 0 {
+		return r, err
+	}
+	n := b.failAfter
+	b.failAfter = 0
+	return &failingReader{r: r, left: n}, nil
+}
+
+// failingReader fails with a transient error after left bytes.
+type failingReader struct {
+	r    io.ReadCloser
+	left int
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.left == 0 {
+		return 0, flakyError{}
+	}
+	if len(p) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.r.Read(p)
+	r.left -= n
+	return n, err
+}
+
+func (r *failingReader) Close() error { return r.r.Close() }
+
+var fastRetry = omnistorage.RetryConfig{MaxRetries: 3, InitialDelay: time.Millisecond}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	inner := &flakyBackend{Backend: memory.New(), failures: 2}
+	b := omnistorage.WithRetry(inner, fastRetry)
+
+	if _, err := b.List(ctx, ""); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("List called %d times, want 3", inner.calls)
+	}
+
+	// Too many failures
+	inner.failures, inner.calls = 10, 0
+	_, err := b.Exists(ctx, "x")
+	if !omnistorage.IsRetryError(err) || !errors.Is(err, flakyError{}) {
+		t.Errorf("Exists error = %v, want RetryError wrapping flakyError", err)
+	}
+	if inner.calls != 4 {
+		t.Errorf("Exists called %d times, want 4", inner.calls)
+	}
+
+	// Permanent errors are not retried
+	if _, err := b.Stat(ctx, "missing"); !errors.Is(err, omnistorage.ErrNotFound) || omnistorage.IsRetryError(err) {
+		t.Errorf("Stat error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWithRetryResumesReads(t *testing.T) {
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	inner := &flakyBackend{Backend: memory.New()}
+	w, _ := inner.NewWriter(ctx, "data.bin")
+	_, _ = w.Write(data)
+	_ = w.Close()
+
+	b := omnistorage.WithRetry(inner, fastRetry)
+	for _, tt := range []struct {
+		name string
+		opts []omnistorage.ReaderOption
+		want []byte
+	}{
+		{"whole", nil, data},
+		{"range", []omnistorage.ReaderOption{omnistorage.WithOffset(100), omnistorage.WithLimit(500)}, data[100:600]},
+	} {
+		inner.failAfter = 123
+		r, err := b.NewReader(ctx, "data.bin", tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: NewReader failed: %v", tt.name, err)
+		}
+		got, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			t.Fatalf("%s: ReadAll failed: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: read %d bytes, want %d matching bytes", tt.name, len(got), len(tt.want))
+		}
+	}
+}
+
+// basicBackend hides the extended operations of a backend.
+type basicBackend struct {
+	omnistorage.Backend
+}
+
+func TestWithRetryNotExtended(t *testing.T) {
+	b := omnistorage.WithRetry(basicBackend{memory.New()}, fastRetry)
+	if _, err := b.Stat(context.Background(), "x"); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Stat error = %v, want ErrNotSupported", err)
+	}
+}
+
+// ctxBackend fails Close of its writers and readers if the context they
+// were opened with is done.
+type ctxBackend struct {
+	*memory.Backend
+}
+
+type ctxCloser struct {
+	ctx context.Context
+	io.Closer
+}
+
+func (c ctxCloser) Close() error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	return c.Closer.Close()
+}
+
+func (b ctxBackend) NewWriter(ctx context.Context, p string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	w, err := b.Backend.NewWriter(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Writer
+		io.Closer
+	}{w, ctxCloser{ctx, w}}, nil
+}
+
+func (b ctxBackend) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	r, err := b.Backend.NewReader(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, ctxCloser{ctx, r}}, nil
+}
+
+func TestWithRetryAttemptTimeoutStreams(t *testing.T) {
+	ctx := context.Background()
+	config := fastRetry
+	config.AttemptTimeout = time.Minute
+	b := omnistorage.WithRetry(ctxBackend{memory.New()}, config)
+
+	w, err := b.NewWriter(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.WriteString(w, "data")
+	if err := w.Close(); err != nil {
+		t.Fatalf("writer Close error = %v", err)
+	}
+
+	r, err := b.NewReader(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != "data" {
+		t.Errorf("read %q, want %q", data, "data")
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("reader Close error = %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+// Package spool provides a backend wrapper that spools writes locally and
+// uploads them on Close.
+//
+// Writers keep data in memory up to a limit, then spill it to a temporary
+// file, so producers write at local speed whatever the latency of the
+// backend, and memory stays bounded however large the object:
+//
+//	backend := spool.New(s3Backend,
+//	    spool.WithMemoryLimit(8<<20),
+//	    spool.WithTempDir("/var/tmp/uploads"),
+//	)
+//	w, _ := backend.NewWriter(ctx, "exports/big.csv")
+//	// ... write at any pace ...
+//	err := w.Close() // uploads, then removes the temporary file
+//
+// Close uploads with omnistorage.Uploader if the wrapped backend
+// implements it, as S3 does, so the upload reads the spooled data a part
+// at a time instead of buffering it again in the backend's writer.
+package spool
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/grokify/omnistorage"
+)
+
+// DefaultMemoryLimit is the memory limit used unless WithMemoryLimit is
+// given.
+const DefaultMemoryLimit = 8 << 20
+
+// Option configures a spool backend.
+type Option func(*Backend)
+
+// WithMemoryLimit sets how many bytes of an object a writer keeps in
+// memory before it spills to a temporary file. 0 spools every write to
+// disk. Default: DefaultMemoryLimit.
+func WithMemoryLimit(n int64) Option {
+	return func(b *Backend) {
+		b.memoryLimit = max(n, 0)
+	}
+}
+
+// WithTempDir sets the directory of the temporary files. Default: the
+// system temporary directory.
+func WithTempDir(dir string) Option {
+	return func(b *Backend) {
+		b.tempDir = dir
+	}
+}
+
+// Backend wraps a backend, spooling writes until they are closed.
+// Extended operations return omnistorage.ErrNotSupported if the wrapped
+// backend does not implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend     omnistorage.Backend
+	memoryLimit int64
+	tempDir     string
+	closed      atomic.Bool
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend, spooling its writes.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{backend: backend, memoryLimit: DefaultMemoryLimit}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// NewWriter returns a writer that spools the data for path and uploads it
+// on Close. Nothing is written to the backend if the writer fails before
+// then.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	if b.closed.Load() {
+		return nil, omnistorage.ErrBackendClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &writer{ctx: ctx, b: b, path: path, opts: opts}, nil
+}
+
+// NewReader creates a reader for the given path.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	return b.backend.NewReader(ctx, path, opts...)
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	return b.backend.Exists(ctx, path)
+}
+
+// Delete removes a path.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	return b.backend.Delete(ctx, path)
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	return b.backend.List(ctx, prefix)
+}
+
+// Close closes the wrapped backend.
+func (b *Backend) Close() error {
+	b.closed.Store(true)
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Copy(ctx, src, dst)
+}
+
+// Move moves an object.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Move(ctx, src, dst)
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// upload writes the content of r to path in the wrapped backend.
+func (b *Backend) upload(ctx context.Context, path string, r io.Reader, opts []omnistorage.WriterOption) error {
+	if u, ok := b.backend.(omnistorage.Uploader); ok {
+		return u.Upload(ctx, path, r, opts...)
+	}
+	w, err := b.backend.NewWriter(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// writer keeps data in memory, then in a temporary file once the memory
+// limit is reached, until Close uploads it.
+type writer struct {
+	ctx  context.Context
+	b    *Backend
+	path string
+	opts []omnistorage.WriterOption
+
+	buf    bytes.Buffer
+	file   *os.File // set once the memory limit is reached
+	err    error    // first write error
+	closed bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, omnistorage.ErrWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.file == nil && int64(w.buf.Len()+len(p)) <= w.b.memoryLimit {
+		return w.buf.Write(p)
+	}
+	if w.file == nil {
+		if w.err = w.spill(); w.err != nil {
+			return 0, w.err
+		}
+	}
+	n, err := w.file.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// spill moves the buffered data to a new temporary file.
+func (w *writer) spill() error {
+	f, err := os.CreateTemp(w.b.tempDir, "omnistorage-spool-*")
+	if err != nil {
+		return err
+	}
+	w.file = f
+	if _, err := f.Write(w.buf.Bytes()); err != nil {
+		return err
+	}
+	w.buf = bytes.Buffer{}
+	return nil
+}
+
+func (w *writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.cleanup()
+
+	if w.err != nil {
+		return w.err
+	}
+	var r io.Reader = &w.buf
+	if w.file != nil {
+		if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = w.file
+	}
+	return w.b.upload(w.ctx, w.path, r, w.opts)
+}
+
+// cleanup releases the buffer and removes the temporary file.
+func (w *writer) cleanup() {
+	w.buf = bytes.Buffer{}
+	if w.file != nil {
+		_ = w.file.Close()
+		_ = os.Remove(w.file.Name())
+		w.file = nil
+	}
+}
@@ -0,0 +1,120 @@
+package spool
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), WithMemoryLimit(4), WithTempDir(t.TempDir()))
+	})
+}
+
+// uploader is a memory backend that implements omnistorage.Uploader and
+// records the uploads.
+type uploader struct {
+	*memory.Backend
+	uploads []string
+}
+
+func (u *uploader) Upload(ctx context.Context, p string, r io.Reader, opts ...omnistorage.WriterOption) error {
+	u.uploads = append(u.uploads, p)
+	w, err := u.NewWriter(ctx, p, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func tempFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func read(t *testing.T, b omnistorage.Backend, p string) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewReader(%s) error = %v", p, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestSpool(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &uploader{Backend: memory.New()}
+	b := New(inner, WithMemoryLimit(10), WithTempDir(dir))
+
+	small, _ := b.NewWriter(ctx, "small.txt")
+	_, _ = io.WriteString(small, "hello")
+	big, _ := b.NewWriter(ctx, "big.txt")
+	for range 5 {
+		_, _ = io.WriteString(big, "0123456789")
+	}
+
+	if ok, _ := inner.Exists(ctx, "big.txt"); ok {
+		t.Error("big.txt was uploaded before Close")
+	}
+	if n := tempFiles(t, dir); n != 1 {
+		t.Errorf("%d temporary files while writing, want 1", n)
+	}
+
+	for _, w := range []io.WriteCloser{small, big} {
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close error = %v", err)
+		}
+	}
+	if n := tempFiles(t, dir); n != 0 {
+		t.Errorf("%d temporary files after Close, want 0", n)
+	}
+	if got := read(t, inner, "small.txt"); got != "hello" {
+		t.Errorf("small.txt = %q", got)
+	}
+	if got := read(t, inner, "big.txt"); got != strings.Repeat("0123456789", 5) {
+		t.Errorf("big.txt = %q", got)
+	}
+	if strings.Join(inner.uploads, ",") != "small.txt,big.txt" {
+		t.Errorf("uploads = %v, want both through Upload", inner.uploads)
+	}
+}
+
+func TestSpoolCanceled(t *testing.T) {
+	dir := t.TempDir()
+	inner := memory.New()
+	b := New(inner, WithMemoryLimit(0), WithTempDir(dir))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := b.NewWriter(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.WriteString(w, "data")
+	cancel()
+	if err := w.Close(); err == nil {
+		t.Error("Close with a canceled context succeeded")
+	}
+	if ok, _ := inner.Exists(context.Background(), "a.txt"); ok {
+		t.Error("a.txt was written with a canceled context")
+	}
+	if n := tempFiles(t, dir); n != 0 {
+		t.Errorf("%d temporary files after a failed Close, want 0", n)
+	}
+}
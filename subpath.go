@@ -0,0 +1,268 @@
+package omnistorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// SubpathBackend is a backend scoped to a prefix of another backend.
+// Create one with Subpath.
+type SubpathBackend struct {
+	backend Backend
+	root    string // cleaned prefix with a trailing slash, or ""
+}
+
+// Ensure SubpathBackend implements ExtendedBackend.
+var _ ExtendedBackend = (*SubpathBackend)(nil)
+
+// Subpath returns a backend whose paths are relative to prefix in backend,
+// like a chroot. Paths are joined to prefix on the way in and stripped of
+// it on the way out, so Stat returns paths relative to prefix.
+// Paths that would leave prefix, such as "../other", fail with
+// ErrInvalidPath, so code handed the subpath cannot reach the rest of
+// backend. So do object operations on the root of the subpath, such as
+// NewWriter(ctx, ""), which would name the object prefix itself.
+//
+// Closing the subpath closes backend. Extended operations return
+// ErrNotSupported if backend does not implement ExtendedBackend.
+func Subpath(backend Backend, prefix string) *SubpathBackend {
+	root := path.Clean("/" + prefix)
+	if root == "/" {
+		root = ""
+	} else {
+		root = strings.TrimPrefix(root, "/") + "/"
+	}
+	// Nested subpaths share one wrapper
+	if sub, ok := backend.(*SubpathBackend); ok {
+		return &SubpathBackend{backend: sub.backend, root: sub.root + root}
+	}
+	return &SubpathBackend{backend: backend, root: root}
+}
+
+// Prefix returns the prefix of the subpath in the wrapped backend, with a
+// trailing slash, or "" for the whole backend.
+func (b *SubpathBackend) Prefix() string {
+	return b.root
+}
+
+// Unwrap returns the wrapped backend.
+func (b *SubpathBackend) Unwrap() Backend {
+	return b.backend
+}
+
+// full returns the path in the wrapped backend for p.
+func (b *SubpathBackend) full(p string) (string, error) {
+	rel := path.Clean(strings.TrimPrefix(p, "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%w: %s is outside the subpath", ErrInvalidPath, p)
+	}
+	if rel == "." {
+		rel = ""
+	}
+	if rel == "" && b.root != "" {
+		return strings.TrimSuffix(b.root, "/"), nil
+	}
+	return b.root + rel, nil
+}
+
+// fullObject returns the path in the wrapped backend for p, which must
+// name an object: the root of the subpath is not one, and in the wrapped
+// backend would name the object outside it that shares its name.
+func (b *SubpathBackend) fullObject(p string) (string, error) {
+	full, err := b.full(p)
+	if err != nil {
+		return "", err
+	}
+	if full == strings.TrimSuffix(b.root, "/") {
+		return "", fmt.Errorf("%w: %q is the root of the subpath, not an object", ErrInvalidPath, p)
+	}
+	return full, nil
+}
+
+// fullPrefix returns the List prefix in the wrapped backend for prefix.
+// Prefixes name directories, so "a/b" and "a/b/" list the same one; the
+// wrapped backend cleans the prefix, and only ".." elements are checked
+// here.
+func (b *SubpathBackend) fullPrefix(prefix string) (string, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	for _, elem := range strings.Split(prefix, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("%w: %s is outside the subpath", ErrInvalidPath, prefix)
+		}
+	}
+	return b.root + prefix, nil
+}
+
+// rel returns p, a path in the wrapped backend, relative to the subpath.
+func (b *SubpathBackend) rel(p string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(p, "/"), b.root)
+}
+
+// NewWriter creates a writer for the given path.
+func (b *SubpathBackend) NewWriter(ctx context.Context, p string, opts ...WriterOption) (io.WriteCloser, error) {
+	full, err := b.fullObject(p)
+	if err != nil {
+		return nil, err
+	}
+	return b.backend.NewWriter(ctx, full, opts...)
+}
+
+// NewReader creates a reader for the given path.
+func (b *SubpathBackend) NewReader(ctx context.Context, p string, opts ...ReaderOption) (io.ReadCloser, error) {
+	full, err := b.fullObject(p)
+	if err != nil {
+		return nil, err
+	}
+	return b.backend.NewReader(ctx, full, opts...)
+}
+
+// Exists checks if a path exists.
+func (b *SubpathBackend) Exists(ctx context.Context, p string) (bool, error) {
+	full, err := b.fullObject(p)
+	if err != nil {
+		return false, err
+	}
+	return b.backend.Exists(ctx, full)
+}
+
+// Delete removes a path.
+func (b *SubpathBackend) Delete(ctx context.Context, p string) error {
+	full, err := b.fullObject(p)
+	if err != nil {
+		return err
+	}
+	return b.backend.Delete(ctx, full)
+}
+
+// List lists the paths under the directory prefix of the subpath,
+// relative to prefix.
+func (b *SubpathBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	full, err := b.fullPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return b.backend.List(ctx, full)
+}
+
+// Close closes the wrapped backend.
+func (b *SubpathBackend) Close() error {
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object, with its path relative to the
+// subpath.
+func (b *SubpathBackend) Stat(ctx context.Context, p string) (ObjectInfo, error) {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	full, err := b.fullObject(p)
+	if err != nil {
+		return nil, err
+	}
+	info, err := ext.Stat(ctx, full)
+	if err != nil {
+		return nil, err
+	}
+	return subpathInfo{ObjectInfo: info, path: b.rel(info.Path())}, nil
+}
+
+// Mkdir creates a directory.
+func (b *SubpathBackend) Mkdir(ctx context.Context, p string) error {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return ErrNotSupported
+	}
+	full, err := b.full(p)
+	if err != nil {
+		return err
+	}
+	return ext.Mkdir(ctx, full)
+}
+
+// Rmdir removes an empty directory.
+func (b *SubpathBackend) Rmdir(ctx context.Context, p string) error {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return ErrNotSupported
+	}
+	full, err := b.full(p)
+	if err != nil {
+		return err
+	}
+	return ext.Rmdir(ctx, full)
+}
+
+// Copy copies an object within the subpath.
+func (b *SubpathBackend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return ErrNotSupported
+	}
+	fullSrc, fullDst, err := b.fullPair(src, dst)
+	if err != nil {
+		return err
+	}
+	return ext.Copy(ctx, fullSrc, fullDst)
+}
+
+// Move moves an object within the subpath.
+func (b *SubpathBackend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := AsExtended(b.backend)
+	if !ok {
+		return ErrNotSupported
+	}
+	fullSrc, fullDst, err := b.fullPair(src, dst)
+	if err != nil {
+		return err
+	}
+	return ext.Move(ctx, fullSrc, fullDst)
+}
+
+func (b *SubpathBackend) fullPair(src, dst string) (string, string, error) {
+	fullSrc, err := b.fullObject(src)
+	if err != nil {
+		return "", "", err
+	}
+	fullDst, err := b.fullObject(dst)
+	if err != nil {
+		return "", "", err
+	}
+	return fullSrc, fullDst, nil
+}
+
+// Features returns the features of the wrapped backend.
+func (b *SubpathBackend) Features() Features {
+	if ext, ok := AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return Features{}
+}
+
+// subpathInfo is an ObjectInfo with a path relative to a subpath.
+type subpathInfo struct {
+	ObjectInfo
+	path string
+}
+
+func (i subpathInfo) Path() string {
+	return i.path
+}
+
+// extended returns the wrapped info's extended attributes, or none.
+func (i subpathInfo) extended() ExtendedObjectInfo {
+	if ext, ok := i.ObjectInfo.(ExtendedObjectInfo); ok {
+		return ext
+	}
+	return &BasicObjectInfo{}
+}
+
+func (i subpathInfo) ETag() string               { return i.extended().ETag() }
+func (i subpathInfo) StorageClass() string       { return i.extended().StorageClass() }
+func (i subpathInfo) VersionID() string          { return i.extended().VersionID() }
+func (i subpathInfo) CacheControl() string       { return i.extended().CacheControl() }
+func (i subpathInfo) ContentEncoding() string    { return i.extended().ContentEncoding() }
+func (i subpathInfo) ContentDisposition() string { return i.extended().ContentDisposition() }
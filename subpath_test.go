@@ -0,0 +1,137 @@
+package omnistorage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestSubpathConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return omnistorage.Subpath(memory.New(), "tenant/a")
+	})
+}
+
+func TestSubpath(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	for _, p := range []string{"data/x.txt", "data/sub/y.txt", "data2/z.txt", "other.txt"} {
+		w, _ := inner.NewWriter(ctx, p)
+		_, _ = w.Write([]byte(p))
+		_ = w.Close()
+	}
+	b := omnistorage.Subpath(inner, "/data/")
+	if b.Prefix() != "data/" {
+		t.Errorf("Prefix() = %q, want %q", b.Prefix(), "data/")
+	}
+
+	paths, err := b.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	slices.Sort(paths)
+	if want := []string{"sub/y.txt", "x.txt"}; !slices.Equal(paths, want) {
+		t.Errorf("List = %v, want %v", paths, want)
+	}
+
+	r, err := b.NewReader(ctx, "sub/../x.txt")
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(got) != "data/x.txt" {
+		t.Errorf("read %q, want %q", got, "data/x.txt")
+	}
+
+	info, err := b.Stat(ctx, "sub/y.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Path() != "sub/y.txt" {
+		t.Errorf("Stat path = %q, want %q", info.Path(), "sub/y.txt")
+	}
+
+	if err := b.Copy(ctx, "x.txt", "copy.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if ok, _ := inner.Exists(ctx, "data/copy.txt"); !ok {
+		t.Error("Copy did not write data/copy.txt")
+	}
+
+	// Nested subpaths compose
+	nested := omnistorage.Subpath(b, "sub")
+	if ok, _ := nested.Exists(ctx, "y.txt"); !ok {
+		t.Error("nested Exists(y.txt) = false, want true")
+	}
+	if nested.Prefix() != "data/sub/" {
+		t.Errorf("nested Prefix() = %q, want %q", nested.Prefix(), "data/sub/")
+	}
+}
+
+func TestSubpathEscape(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	w, _ := inner.NewWriter(ctx, "other.txt")
+	_ = w.Close()
+	b := omnistorage.Subpath(inner, "data")
+
+	for _, p := range []string{"../other.txt", "/../other.txt", "sub/../../other.txt", ".."} {
+		if _, err := b.NewReader(ctx, p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("NewReader(%q) error = %v, want ErrInvalidPath", p, err)
+		}
+		if err := b.Delete(ctx, p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("Delete(%q) error = %v, want ErrInvalidPath", p, err)
+		}
+	}
+	if err := b.Copy(ctx, "x", "../x"); !errors.Is(err, omnistorage.ErrInvalidPath) {
+		t.Errorf("Copy to ../x error = %v, want ErrInvalidPath", err)
+	}
+	if _, err := b.List(ctx, "../"); !errors.Is(err, omnistorage.ErrInvalidPath) {
+		t.Errorf("List(../) error = %v, want ErrInvalidPath", err)
+	}
+	if ok, _ := inner.Exists(ctx, "other.txt"); !ok {
+		t.Error("other.txt was deleted through the subpath")
+	}
+}
+
+func TestSubpathRoot(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	w, _ := inner.NewWriter(ctx, "data")
+	_ = w.Close()
+	b := omnistorage.Subpath(inner, "data")
+
+	for _, p := range []string{"", ".", "/", "sub/.."} {
+		if _, err := b.NewWriter(ctx, p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("NewWriter(%q) error = %v, want ErrInvalidPath", p, err)
+		}
+		if _, err := b.NewReader(ctx, p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("NewReader(%q) error = %v, want ErrInvalidPath", p, err)
+		}
+		if _, err := b.Exists(ctx, p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("Exists(%q) error = %v, want ErrInvalidPath", p, err)
+		}
+		if err := b.Delete(ctx, p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("Delete(%q) error = %v, want ErrInvalidPath", p, err)
+		}
+		if _, err := b.Stat(ctx, p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("Stat(%q) error = %v, want ErrInvalidPath", p, err)
+		}
+		if err := b.Copy(ctx, p, "x"); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("Copy(%q, x) error = %v, want ErrInvalidPath", p, err)
+		}
+		if err := b.Move(ctx, "x", p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("Move(x, %q) error = %v, want ErrInvalidPath", p, err)
+		}
+	}
+	if ok, _ := inner.Exists(ctx, "data"); !ok {
+		t.Error("data was deleted through the subpath")
+	}
+}
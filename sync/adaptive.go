@@ -0,0 +1,177 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	gosync "sync"
+	"time"
+)
+
+// AdaptiveConfig configures adaptive concurrency (see Options.Adaptive).
+// The zero value uses the defaults.
+type AdaptiveConfig struct {
+	// MinConcurrency is the fewest concurrent transfers. Default: 1.
+	MinConcurrency int
+
+	// Backoff is how long no transfers are started after a throttling
+	// error. It doubles while throttling goes on, up to MaxBackoff, and
+	// is reset once a transfer succeeds. Default: 1 second.
+	Backoff time.Duration
+
+	// MaxBackoff is the longest Backoff. Default: 30 seconds.
+	MaxBackoff time.Duration
+
+	// MaxThrottles is how many throttling errors in a row, with no
+	// transfer succeeding in between, make a throttled file fail rather
+	// than be tried again: the backend is not recovering. Default: 10.
+	MaxThrottles int
+}
+
+// adaptiveLimiter limits concurrent transfers, halving the limit and
+// pausing new transfers when one is throttled, and raising the limit by
+// one after as many successes in a row as the limit, up to max. Throttling
+// errors while paused come from transfers started before it, so they do
+// not lower the limit again.
+type adaptiveLimiter struct {
+	cfg    AdaptiveConfig
+	max    int
+	logger *slog.Logger
+
+	mu          gosync.Mutex
+	wake        chan struct{} // closed when a transfer ends
+	limit       int
+	active      int
+	successes   int
+	backoff     time.Duration
+	pausedUntil time.Time
+	throttled   int
+	streak      int // throttling errors since the last success
+}
+
+// newAdaptiveLimiter returns a limiter of up to n transfers for
+// opts.Adaptive, or nil if it is not set.
+func newAdaptiveLimiter(opts Options, n int) *adaptiveLimiter {
+	if opts.Adaptive == nil {
+		return nil
+	}
+	cfg := *opts.Adaptive
+	if cfg.MinConcurrency <= 0 {
+		cfg.MinConcurrency = 1
+	}
+	cfg.MinConcurrency = min(cfg.MinConcurrency, n)
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 30 * time.Second
+	}
+	cfg.MaxBackoff = max(cfg.MaxBackoff, cfg.Backoff)
+	if cfg.MaxThrottles <= 0 {
+		cfg.MaxThrottles = 10
+	}
+	return &adaptiveLimiter{
+		cfg:    cfg,
+		max:    n,
+		logger: opts.logger(),
+		wake:   make(chan struct{}),
+		limit:  n,
+	}
+}
+
+// do runs fn when the limit allows, and again while it is throttled,
+// until MaxThrottles throttling errors in a row. A nil limiter runs fn
+// once.
+func (l *adaptiveLimiter) do(ctx context.Context, fn func(context.Context) error) error {
+	if l == nil {
+		return fn(ctx)
+	}
+	for {
+		if err := l.acquire(ctx); err != nil {
+			return err
+		}
+		err := fn(ctx)
+		if !l.release(err) {
+			return err
+		}
+	}
+}
+
+// acquire waits until a transfer may start.
+func (l *adaptiveLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if wait := time.Until(l.pausedUntil); wait > 0 {
+			l.mu.Unlock()
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		wake := l.wake
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// release ends a transfer that returned err and adapts the limit. It
+// reports whether the transfer was throttled and should be tried again.
+func (l *adaptiveLimiter) release(err error) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	close(l.wake)
+	l.wake = make(chan struct{})
+
+	switch {
+	case err == nil:
+		l.backoff = 0
+		l.streak = 0
+		l.successes++
+		if l.successes >= l.limit && l.limit < l.max {
+			l.limit++
+			l.successes = 0
+			l.logger.Debug("raising concurrency", slog.Int("concurrency", l.limit))
+		}
+	case ClassifyError(err) == ErrorThrottled:
+		l.throttled++
+		l.streak++
+		l.successes = 0
+		now := time.Now()
+		if now.Before(l.pausedUntil) {
+			return l.streak < l.cfg.MaxThrottles
+		}
+		l.limit = max(l.limit/2, l.cfg.MinConcurrency)
+		l.backoff = min(max(l.backoff*2, l.cfg.Backoff), l.cfg.MaxBackoff)
+		l.pausedUntil = now.Add(l.backoff)
+		l.logger.Info("throttled, lowering concurrency",
+			slog.Int("concurrency", l.limit),
+			slog.Duration("backoff", l.backoff),
+			slog.Any("error", err))
+		return l.streak < l.cfg.MaxThrottles
+	}
+	return false
+}
+
+// throttledCount returns the number of transfers that were throttled.
+func (l *adaptiveLimiter) throttledCount() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.throttled
+}
@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// throttlingBackend is a memory backend that throttles writers beyond
+// its capacity, as S3 returns SlowDown.
+type throttlingBackend struct {
+	*memory.Backend
+	capacity int
+
+	mu        gosync.Mutex
+	active    int
+	peak      int
+	throttled int
+}
+
+func (b *throttlingBackend) NewWriter(ctx context.Context, p string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.active >= b.capacity {
+		b.throttled++
+		return nil, fmt.Errorf("put %s: %w", p, omnistorage.ErrThrottled)
+	}
+	w, err := b.Backend.NewWriter(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	b.active++
+	b.peak = max(b.peak, b.active)
+	return &slowWriter{WriteCloser: w, b: b}, nil
+}
+
+// slowWriter holds its slot in the backend for a while.
+type slowWriter struct {
+	io.WriteCloser
+	b *throttlingBackend
+}
+
+func (w *slowWriter) Close() error {
+	time.Sleep(2 * time.Millisecond)
+	w.b.mu.Lock()
+	w.b.active--
+	w.b.mu.Unlock()
+	return w.WriteCloser.Close()
+}
+
+func TestSyncAdaptive(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	for i := range 40 {
+		writeFile(t, ctx, src, fmt.Sprintf("f%02d.txt", i), "data")
+	}
+
+	// Without Adaptive, throttled files fail
+	dst := &throttlingBackend{Backend: memory.New(), capacity: 2}
+	result, err := Sync(ctx, src, dst, "", "", Options{Concurrency: 8, MaxErrors: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.throttled == 0 || len(result.Errors) != dst.throttled {
+		t.Fatalf("throttled = %d, errors = %d; want every throttled file to fail", dst.throttled, len(result.Errors))
+	}
+
+	dst = &throttlingBackend{Backend: memory.New(), capacity: 2}
+	result, err = Sync(ctx, src, dst, "", "", Options{
+		Concurrency: 8,
+		Adaptive:    &AdaptiveConfig{Backoff: time.Millisecond},
+	})
+	if err != nil || !result.Success() || result.Copied != 40 {
+		t.Fatalf("adaptive Sync = %+v, %v; want 40 copied", result, err)
+	}
+	if result.Throttled == 0 || result.Throttled != dst.throttled {
+		t.Errorf("Throttled = %d, backend throttled %d", result.Throttled, dst.throttled)
+	}
+	if dst.peak > dst.capacity {
+		t.Errorf("peak concurrency = %d, capacity %d", dst.peak, dst.capacity)
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := newAdaptiveLimiter(Options{Adaptive: &AdaptiveConfig{Backoff: time.Hour}}, 8)
+	ctx := context.Background()
+	throttled := fmt.Errorf("put: %w", omnistorage.ErrThrottled)
+
+	for range 3 {
+		if err := l.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !l.release(throttled) {
+		t.Error("throttled transfer is not tried again")
+	}
+	// Transfers started before the pause do not lower the limit again
+	l.release(throttled)
+	if l.limit != 4 || l.throttled != 2 {
+		t.Errorf("limit = %d, throttled = %d; want 4, 2", l.limit, l.throttled)
+	}
+
+	// No transfer starts during the backoff
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(cctx); err == nil {
+		t.Error("acquire succeeded during backoff")
+	}
+
+	// Successes raise the limit again once the backoff is over
+	l.pausedUntil = time.Time{}
+	l.release(nil)
+	for range 4 {
+		_ = l.acquire(ctx)
+		l.release(nil)
+	}
+	if l.limit != 5 || l.backoff != 0 {
+		t.Errorf("limit = %d, backoff = %v after successes; want 5, 0", l.limit, l.backoff)
+	}
+
+	// A backend that keeps throttling fails the file
+	l = newAdaptiveLimiter(Options{Adaptive: &AdaptiveConfig{Backoff: time.Microsecond, MaxThrottles: 3}}, 2)
+	calls := 0
+	err := l.do(ctx, func(context.Context) error {
+		calls++
+		return throttled
+	})
+	if err != throttled || calls != 3 {
+		t.Errorf("do = %v after %d calls, want throttled after 3", err, calls)
+	}
+}
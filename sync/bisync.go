@@ -2,12 +2,15 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"path"
 	"time"
 
 	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/metrics"
 	"github.com/grokify/omnistorage/sync/filter"
 )
 
@@ -47,12 +50,42 @@ type BisyncOptions struct {
 	// Default is ".conflict".
 	ConflictSuffix string
 
+	// Tiebreaker resolves a conflict that ConflictNewerWins or
+	// ConflictLargerWins cannot decide because both files have the same
+	// modification time or size. The default, ConflictNewerWins, keeps
+	// the path2 version.
+	Tiebreaker ConflictStrategy
+
+	// ConflictResolver, if set, is called for each conflict after the
+	// OnConflict hook and decides how it is resolved, overriding
+	// ConflictStrategy, for custom policies such as asking a user or
+	// merging text or JSON files. Returning an error records it for the
+	// file, as ConflictError does.
+	ConflictResolver func(ctx context.Context, c Conflict) (ConflictDecision, error)
+
 	// DryRun reports what would be done without making changes.
 	DryRun bool
 
 	// Checksum uses file checksums for comparison instead of size/time.
 	Checksum bool
 
+	// IgnoreExisting only copies files missing from one side. Files on
+	// both sides are never compared, so they are not conflicts.
+	IgnoreExisting bool
+
+	// IgnoreSize ignores size when comparing files, as in Options.
+	IgnoreSize bool
+
+	// IgnoreTime ignores modification time when comparing files, as in
+	// Options.
+	IgnoreTime bool
+
+	// SizeOnly compares files by size only, as in Options.
+	SizeOnly bool
+
+	// SkipEmptyFiles ignores zero-byte files on both sides, as in Options.
+	SkipEmptyFiles bool
+
 	// DeleteMissing deletes files that don't exist on the other side.
 	// Use with caution - this can result in data loss if a file was
 	// intentionally deleted on one side but still exists on the other.
@@ -71,17 +104,41 @@ type BisyncOptions struct {
 	// Filter specifies which files to include/exclude.
 	Filter *filter.Filter
 
+	// Path1Filter and Path2Filter further limit the files of one side,
+	// for example to keep files generated in path1 out of path2. A file
+	// excluded on one side is left alone: it is not copied to the other
+	// side, and the other side's version is not copied over it.
+	Path1Filter *filter.Filter
+	Path2Filter *filter.Filter
+
+	// MaxTransferBytes and MaxDuration cap a run as in Options. Once a cap
+	// is reached, no further files are copied, and the partial result is
+	// returned with a *LimitError.
+	MaxTransferBytes int64
+	MaxDuration      time.Duration
+
 	// BandwidthLimit is the maximum bytes per second for transfers.
 	BandwidthLimit int64
 
 	// Retry configures retry behavior for failed operations.
 	Retry *RetryConfig
 
+	// FileTimeout bounds the work on each file as in Options.
+	FileTimeout time.Duration
+
+	// Hooks are called around file operations and conflicts. If nil, no
+	// hooks are called.
+	Hooks *Hooks
+
 	// PreserveMetadata controls which metadata to preserve.
 	PreserveMetadata *MetadataOptions
 
 	// Logger for structured logging. If nil, no logging is performed.
 	Logger *slog.Logger
+
+	// Metrics receives an event for every file copied or updated.
+	// If nil, no metrics are recorded.
+	Metrics metrics.Collector
 }
 
 // DefaultBisyncOptions returns BisyncOptions with sensible defaults.
@@ -97,6 +154,46 @@ func DefaultBisyncOptions() BisyncOptions {
 	}
 }
 
+// syncOptions returns the Options used to list, compare and copy files.
+func (o BisyncOptions) syncOptions(logger *slog.Logger) Options {
+	return Options{
+		DryRun:           o.DryRun,
+		Checksum:         o.Checksum,
+		IgnoreExisting:   o.IgnoreExisting,
+		IgnoreSize:       o.IgnoreSize,
+		IgnoreTime:       o.IgnoreTime,
+		SizeOnly:         o.SizeOnly,
+		SkipEmptyFiles:   o.SkipEmptyFiles,
+		Filter:           o.Filter,
+		Concurrency:      o.Concurrency,
+		BandwidthLimit:   o.BandwidthLimit,
+		MaxTransferBytes: o.MaxTransferBytes,
+		MaxDuration:      o.MaxDuration,
+		Retry:            o.Retry,
+		FileTimeout:      o.FileTimeout,
+		PreserveMetadata: o.PreserveMetadata,
+		Logger:           logger,
+	}
+}
+
+// sideFiles indexes the files of one side by path. Files that Filter
+// includes but the side filter excludes are in excluded.
+func sideFiles(files []FileInfo, f *filter.Filter) (included map[string]FileInfo, excluded map[string]bool) {
+	included = make(map[string]FileInfo)
+	excluded = make(map[string]bool)
+	for _, fi := range files {
+		if fi.IsDir {
+			continue
+		}
+		if f != nil && !f.Match(filter.FileInfo{Path: fi.Path, Size: fi.Size, ModTime: fi.ModTime}) {
+			excluded[fi.Path] = true
+			continue
+		}
+		included[fi.Path] = fi
+	}
+	return included, excluded
+}
+
 // BisyncResult contains the results of a bidirectional sync.
 type BisyncResult struct {
 	// CopiedToPath2 is files copied from path1 to path2.
@@ -156,6 +253,18 @@ func (r *BisyncResult) TotalDeleted() int {
 	return r.DeletedFromPath1 + r.DeletedFromPath2
 }
 
+// ConflictDecision is a ConflictResolver's decision for a conflict.
+type ConflictDecision struct {
+	// Strategy resolves the conflict. Tiebreaker applies as usual.
+	Strategy ConflictStrategy
+
+	// Merge, if set, overrides Strategy: it is called with the contents
+	// of the path1 and path2 versions and returns the merged content,
+	// which replaces the file on both sides. No common ancestor is kept
+	// between runs, so merges are two-way.
+	Merge func(path1, path2 io.Reader) (io.Reader, error)
+}
+
 // Conflict represents a file that was changed on both sides.
 type Conflict struct {
 	// Path is the relative path of the conflicting file.
@@ -211,12 +320,8 @@ func Bisync(ctx context.Context, backend1, backend2 omnistorage.Backend, path1,
 	)
 
 	// Scan both sides
-	syncOpts := Options{
-		Checksum:    opts.Checksum,
-		Filter:      opts.Filter,
-		Concurrency: opts.Concurrency,
-		Logger:      logger,
-	}
+	syncOpts := opts.syncOptions(logger)
+	budget := newTransferBudget(syncOpts, startTime)
 
 	if opts.Progress != nil {
 		opts.Progress(Progress{Phase: PhaseScanning, CurrentFile: path1})
@@ -239,19 +344,8 @@ func Bisync(ctx context.Context, backend1, backend2 omnistorage.Backend, path1,
 	logger.Debug("path2 scan complete", slog.Int("files", len(files2)))
 
 	// Build maps for comparison
-	map1 := make(map[string]FileInfo)
-	for _, f := range files1 {
-		if !f.IsDir {
-			map1[f.Path] = f
-		}
-	}
-
-	map2 := make(map[string]FileInfo)
-	for _, f := range files2 {
-		if !f.IsDir {
-			map2[f.Path] = f
-		}
-	}
+	map1, excluded1 := sideFiles(files1, opts.Path1Filter)
+	map2, excluded2 := sideFiles(files2, opts.Path2Filter)
 
 	if opts.Progress != nil {
 		opts.Progress(Progress{Phase: PhaseComparing, TotalFiles: len(map1) + len(map2)})
@@ -271,11 +365,14 @@ func Bisync(ctx context.Context, backend1, backend2 omnistorage.Backend, path1,
 	for p, f1 := range map1 {
 		processed[p] = true
 
+		if excluded2[p] {
+			continue
+		}
 		f2, existsIn2 := map2[p]
 		if !existsIn2 {
 			// File only in path1 - copy to path2
 			actions = append(actions, action{file: f1, direction: "to2"})
-		} else {
+		} else if !opts.IgnoreExisting {
 			// File exists in both - check if changed
 			if NeedsUpdate(f1, f2, syncOpts) || NeedsUpdate(f2, f1, syncOpts) {
 				// Both sides have the file but they differ
@@ -284,12 +381,14 @@ func Bisync(ctx context.Context, backend1, backend2 omnistorage.Backend, path1,
 				// Files are in sync
 				result.Skipped++
 			}
+		} else {
+			result.Skipped++
 		}
 	}
 
 	// Process files only in path2
 	for p, f2 := range map2 {
-		if processed[p] {
+		if processed[p] || excluded1[p] {
 			continue
 		}
 
@@ -304,13 +403,7 @@ func Bisync(ctx context.Context, backend1, backend2 omnistorage.Backend, path1,
 
 	// Create sync context for file operations
 	sctx := &syncContext{
-		opts: Options{
-			DryRun:           opts.DryRun,
-			BandwidthLimit:   opts.BandwidthLimit,
-			Retry:            opts.Retry,
-			PreserveMetadata: opts.PreserveMetadata,
-			Logger:           logger,
-		},
+		opts:        syncOpts,
 		rateLimiter: newTokenBucket(opts.BandwidthLimit),
 		logger:      logger,
 	}
@@ -340,6 +433,14 @@ func Bisync(ctx context.Context, backend1, backend2 omnistorage.Backend, path1,
 			copiedCounter = &result.CopiedToPath1
 		}
 
+		switch opts.Hooks.beforeCopy(ctx, act.file) {
+		case Skip:
+			result.Skipped++
+			return nil
+		case Abort:
+			return abortError("copy-to-"+destName, act.file.Path)
+		}
+
 		srcPath := path.Join(srcBase, act.file.Path)
 		dstPath := path.Join(dstBase, act.file.Path)
 
@@ -349,11 +450,23 @@ func Bisync(ctx context.Context, backend1, backend2 omnistorage.Backend, path1,
 		)
 
 		if !opts.DryRun {
-			if err := copyFileWithContext(ctx, sctx, srcBackend, dstBackend, srcPath, dstPath); err != nil {
+			start := time.Now()
+			err := copyFileWithContext(ctx, sctx, srcBackend, dstBackend, srcPath, dstPath)
+			observe(opts.Metrics, metrics.TransferCopy, act.file.Path, act.file.Size, start, err)
+			if err != nil {
 				logger.Error("copy to "+destName+" failed", slog.String("file", act.file.Path), slog.Any("error", err))
-				result.Errors = append(result.Errors, FileError{Path: act.file.Path, Op: "copy-to-" + destName, Err: err})
+				fe := FileError{Path: act.file.Path, Op: "copy-to-" + destName, Err: err}
+				decision := opts.Hooks.onError(ctx, act.file, fe)
+				if decision == Skip {
+					return nil
+				}
+				result.Errors = append(result.Errors, fe)
+				if decision == Abort {
+					return abortError(fe.Op, fe.Path)
+				}
 				return err
 			}
+			opts.Hooks.afterCopy(ctx, act.file)
 		}
 		*copiedCounter++
 		result.BytesTransferred += act.file.Size
@@ -383,17 +496,21 @@ actionLoop:
 			})
 		}
 
+		size := act.file.Size
+		if act.otherFile != nil {
+			size = max(size, act.otherFile.Size)
+		}
+		if !budget.take(size) {
+			continue
+		}
+
 		switch act.direction {
-		case "to2":
-			if err := copyDirection(act, true); err != nil {
-				if maxErrorsReached() {
-					break actionLoop
+		case "to1", "to2":
+			if err := copyDirection(act, act.direction == "to2"); err != nil {
+				if errors.Is(err, ErrAborted) {
+					result.Duration = time.Since(startTime)
+					return result, err
 				}
-				continue
-			}
-
-		case "to1":
-			if err := copyDirection(act, false); err != nil {
 				if maxErrorsReached() {
 					break actionLoop
 				}
@@ -408,7 +525,18 @@ actionLoop:
 				Path2Info: *act.otherFile,
 			}
 
-			resolution, copyDir, err := resolveConflict(ctx, sctx, backend1, backend2, path1, path2, act.file, *act.otherFile, opts)
+			switch opts.Hooks.onConflict(ctx, conflict) {
+			case Skip:
+				conflict.Resolution = "skipped"
+				result.Conflicts = append(result.Conflicts, conflict)
+				continue
+			case Abort:
+				result.Duration = time.Since(startTime)
+				return result, abortError("conflict", act.file.Path)
+			}
+
+			start := time.Now()
+			resolution, copyDir, err := resolveConflictWith(ctx, sctx, backend1, backend2, path1, path2, conflict, opts)
 			conflict.Resolution = resolution
 			conflict.Error = err
 
@@ -418,7 +546,19 @@ actionLoop:
 					slog.String("resolution", resolution),
 					slog.Any("error", err),
 				)
-				result.Errors = append(result.Errors, FileError{Path: act.file.Path, Op: "conflict", Err: err})
+				if !opts.DryRun {
+					observe(opts.Metrics, metrics.TransferUpdate, act.file.Path, 0, start, err)
+				}
+				fe := FileError{Path: act.file.Path, Op: "conflict", Err: err}
+				decision := opts.Hooks.onError(ctx, act.file, fe)
+				if decision != Skip {
+					result.Errors = append(result.Errors, fe)
+				}
+				if decision == Abort {
+					result.Conflicts = append(result.Conflicts, conflict)
+					result.Duration = time.Since(startTime)
+					return result, abortError(fe.Op, fe.Path)
+				}
 			} else {
 				logger.Debug("conflict resolved",
 					slog.String("file", act.file.Path),
@@ -430,13 +570,20 @@ actionLoop:
 					case "to1":
 						result.UpdatedInPath1++
 						result.BytesTransferred += act.otherFile.Size
+						observe(opts.Metrics, metrics.TransferUpdate, act.file.Path, act.otherFile.Size, start, nil)
+						opts.Hooks.afterCopy(ctx, *act.otherFile)
 					case "to2":
 						result.UpdatedInPath2++
 						result.BytesTransferred += act.file.Size
+						observe(opts.Metrics, metrics.TransferUpdate, act.file.Path, act.file.Size, start, nil)
+						opts.Hooks.afterCopy(ctx, act.file)
 					case "both":
 						result.UpdatedInPath1++
 						result.UpdatedInPath2++
 						result.BytesTransferred += act.file.Size + act.otherFile.Size
+						observe(opts.Metrics, metrics.TransferUpdate, act.file.Path, act.file.Size+act.otherFile.Size, start, nil)
+						opts.Hooks.afterCopy(ctx, act.file)
+						opts.Hooks.afterCopy(ctx, *act.otherFile)
 					}
 				}
 			}
@@ -477,10 +624,91 @@ actionLoop:
 		slog.Duration("duration", result.Duration),
 	)
 
+	if err := budget.err(); err != nil {
+		logger.Warn("bisync stopped early", slog.Any("error", err))
+		return result, err
+	}
 	return result, nil
 }
 
-// resolveConflict resolves a conflict between two files based on the strategy.
+// resolveConflictWith resolves c with opts.ConflictResolver, if it is
+// set, and with opts.ConflictStrategy otherwise. It returns the same
+// values as resolveConflict.
+func resolveConflictWith(
+	ctx context.Context,
+	sctx *syncContext,
+	backend1, backend2 omnistorage.Backend,
+	path1, path2 string,
+	c Conflict,
+	opts BisyncOptions,
+) (string, string, error) {
+	strategy := opts.ConflictStrategy
+	if opts.ConflictResolver != nil {
+		decision, err := opts.ConflictResolver(ctx, c)
+		if err != nil {
+			return "resolver", "", err
+		}
+		if decision.Merge != nil {
+			if !opts.DryRun {
+				if err := mergeConflict(ctx, sctx, backend1, backend2, path.Join(path1, c.Path), path.Join(path2, c.Path), decision.Merge); err != nil {
+					return "merged", "", err
+				}
+			}
+			return "merged", "both", nil
+		}
+		strategy = decision.Strategy
+	}
+	return resolveConflict(ctx, sctx, backend1, backend2, path1, path2, c.Path1Info, c.Path2Info, strategy, opts)
+}
+
+// mergeConflict writes the merge of the files at p1 and p2 to p1, then
+// copies it to p2.
+func mergeConflict(
+	ctx context.Context,
+	sctx *syncContext,
+	backend1, backend2 omnistorage.Backend,
+	p1, p2 string,
+	merge func(path1, path2 io.Reader) (io.Reader, error),
+) error {
+	r1, err := backend1.NewReader(ctx, p1)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r1.Close() }()
+	r2, err := backend2.NewReader(ctx, p2)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r2.Close() }()
+
+	merged, err := merge(r1, r2)
+	if err != nil {
+		return fmt.Errorf("merging %s: %w", p1, err)
+	}
+	if c, ok := merged.(io.Closer); ok {
+		defer func() { _ = c.Close() }()
+	}
+
+	// Read the merge fully before p1 is replaced, since it may read r1
+	data, err := io.ReadAll(merged)
+	if err != nil {
+		return fmt.Errorf("merging %s: %w", p1, err)
+	}
+	w, err := backend1.NewWriter(ctx, p1)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return copyFileWithContext(ctx, sctx, backend1, backend2, p1, p2)
+}
+
+// resolveConflict resolves a conflict between two files based on strategy.
 // Returns the resolution description, the direction to copy ("to1", "to2", "both", or ""),
 // and any error.
 func resolveConflict(
@@ -489,12 +717,21 @@ func resolveConflict(
 	backend1, backend2 omnistorage.Backend,
 	path1, path2 string,
 	file1, file2 FileInfo,
+	strategy ConflictStrategy,
 	opts BisyncOptions,
 ) (string, string, error) {
 	srcPath1 := path.Join(path1, file1.Path)
 	srcPath2 := path.Join(path2, file2.Path)
 
-	switch opts.ConflictStrategy {
+	// Equal times or sizes cannot decide; the tiebreaker applies unless
+	// it is the same strategy, or the default, which keeps path2
+	tied := (strategy == ConflictNewerWins && file1.ModTime.Equal(file2.ModTime)) ||
+		(strategy == ConflictLargerWins && file1.Size == file2.Size)
+	if tied && opts.Tiebreaker != ConflictNewerWins && opts.Tiebreaker != strategy {
+		return resolveConflict(ctx, sctx, backend1, backend2, path1, path2, file1, file2, opts.Tiebreaker, opts)
+	}
+
+	switch strategy {
 	case ConflictNewerWins:
 		if file1.ModTime.After(file2.ModTime) {
 			// path1 is newer, copy to path2
@@ -595,6 +832,6 @@ func resolveConflict(
 			file1.Path, file1.ModTime, file1.Size, file2.ModTime, file2.Size)
 
 	default:
-		return "unknown-strategy", "", fmt.Errorf("unknown conflict strategy: %d", opts.ConflictStrategy)
+		return "unknown-strategy", "", fmt.Errorf("unknown conflict strategy: %d", strategy)
 	}
 }
@@ -3,12 +3,17 @@ package sync
 import (
 	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/grokify/omnistorage"
 	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/sync/filter"
 )
 
 func TestBisyncNewFiles(t *testing.T) {
@@ -294,6 +299,168 @@ func TestBisyncConflictError(t *testing.T) {
 	}
 }
 
+func readBackend(t *testing.T, b omnistorage.Backend, p string) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewReader(%s) failed: %v", p, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestBisyncConflictResolver(t *testing.T) {
+	ctx := context.Background()
+	backend1 := memory.New()
+	backend2 := memory.New()
+	writeFile(t, ctx, backend1, "p1/notes.txt", "line from path1\n")
+	writeFile(t, ctx, backend1, "p1/keep.txt", "path1 keep")
+	writeFile(t, ctx, backend2, "p2/notes.txt", "other line from path2\n")
+	writeFile(t, ctx, backend2, "p2/keep.txt", "path2 keeps")
+
+	// Merge text files, keep path1 otherwise
+	resolver := func(ctx context.Context, c Conflict) (ConflictDecision, error) {
+		if strings.HasSuffix(c.Path, "notes.txt") {
+			return ConflictDecision{Merge: func(r1, r2 io.Reader) (io.Reader, error) {
+				return io.MultiReader(r1, r2), nil
+			}}, nil
+		}
+		return ConflictDecision{Strategy: ConflictSourceWins}, nil
+	}
+	result, err := Bisync(ctx, backend1, backend2, "p1", "p2", BisyncOptions{ConflictResolver: resolver})
+	if err != nil {
+		t.Fatalf("Bisync failed: %v", err)
+	}
+	if len(result.Conflicts) != 2 || len(result.Errors) != 0 {
+		t.Fatalf("Conflicts = %+v, Errors = %v; want 2 conflicts, no errors", result.Conflicts, result.Errors)
+	}
+
+	merged := "line from path1\nother line from path2\n"
+	if got := readBackend(t, backend1, "p1/notes.txt"); got != merged {
+		t.Errorf("path1 notes.txt = %q, want %q", got, merged)
+	}
+	if got := readBackend(t, backend2, "p2/notes.txt"); got != merged {
+		t.Errorf("path2 notes.txt = %q, want %q", got, merged)
+	}
+	if got := readBackend(t, backend2, "p2/keep.txt"); got != "path1 keep" {
+		t.Errorf("path2 keep.txt = %q, want %q", got, "path1 keep")
+	}
+}
+
+func TestBisyncConflictResolverError(t *testing.T) {
+	ctx := context.Background()
+	backend1 := memory.New()
+	backend2 := memory.New()
+	writeFile(t, ctx, backend1, "p1/a.txt", "one")
+	writeFile(t, ctx, backend2, "p2/a.txt", "three")
+
+	errAsk := errors.New("user cancelled")
+	result, err := Bisync(ctx, backend1, backend2, "p1", "p2", BisyncOptions{
+		ConflictResolver: func(context.Context, Conflict) (ConflictDecision, error) {
+			return ConflictDecision{}, errAsk
+		},
+	})
+	if err != nil {
+		t.Fatalf("Bisync failed: %v", err)
+	}
+	if len(result.Errors) != 1 || !errors.Is(result.Errors[0].Err, errAsk) {
+		t.Errorf("Errors = %v, want the resolver error", result.Errors)
+	}
+	if got := readBackend(t, backend2, "p2/a.txt"); got != "three" {
+		t.Errorf("path2 a.txt = %q, want it untouched", got)
+	}
+}
+
+func TestResolveConflictTiebreaker(t *testing.T) {
+	ctx := context.Background()
+	backend1 := memory.New()
+	backend2 := memory.New()
+	writeFile(t, ctx, backend1, "p1/a.txt", "longer")
+	writeFile(t, ctx, backend2, "p2/a.txt", "short")
+
+	mod := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	file1 := FileInfo{Path: "a.txt", Size: 6, ModTime: mod}
+	file2 := FileInfo{Path: "a.txt", Size: 5, ModTime: mod}
+	sctx := &syncContext{logger: slog.New(slog.DiscardHandler)}
+
+	for _, tt := range []struct {
+		tiebreaker ConflictStrategy
+		resolution string
+		dir        string
+	}{
+		{ConflictNewerWins, "newer-wins:path2", "to1"},
+		{ConflictLargerWins, "larger-wins:path1", "to2"},
+		{ConflictSkip, "skipped", ""},
+	} {
+		opts := BisyncOptions{Tiebreaker: tt.tiebreaker, DryRun: true}
+		resolution, dir, err := resolveConflict(ctx, sctx, backend1, backend2, "p1", "p2", file1, file2, ConflictNewerWins, opts)
+		if err != nil || resolution != tt.resolution || dir != tt.dir {
+			t.Errorf("tiebreaker %d: resolveConflict = %q, %q, %v; want %q, %q", tt.tiebreaker, resolution, dir, err, tt.resolution, tt.dir)
+		}
+	}
+}
+
+func TestBisyncSideFilters(t *testing.T) {
+	ctx := context.Background()
+	backend1 := memory.New()
+	backend2 := memory.New()
+	writeFile(t, ctx, backend1, "p1/src/main.go", "package main")
+	writeFile(t, ctx, backend1, "p1/build/app", "binary")
+	writeFile(t, ctx, backend2, "p2/build/app", "old binary!")
+	writeFile(t, ctx, backend2, "p2/README", "readme")
+
+	result, err := Bisync(ctx, backend1, backend2, "p1", "p2", BisyncOptions{
+		Path1Filter: filter.New(filter.Exclude("build/**")),
+	})
+	if err != nil {
+		t.Fatalf("Bisync failed: %v", err)
+	}
+	if result.CopiedToPath2 != 1 || result.CopiedToPath1 != 1 || len(result.Conflicts) != 0 {
+		t.Errorf("CopiedToPath2 = %d, CopiedToPath1 = %d, Conflicts = %d; want 1, 1, 0",
+			result.CopiedToPath2, result.CopiedToPath1, len(result.Conflicts))
+	}
+	if got := readBackend(t, backend1, "p1/build/app"); got != "binary" {
+		t.Errorf("excluded path1 file = %q, want it untouched", got)
+	}
+	if got := readBackend(t, backend2, "p2/build/app"); got != "old binary!" {
+		t.Errorf("path2 file = %q, want it untouched", got)
+	}
+}
+
+func TestBisyncIgnoreExisting(t *testing.T) {
+	ctx := context.Background()
+	backend1 := memory.New()
+	backend2 := memory.New()
+	writeFile(t, ctx, backend1, "p1/a.txt", "one")
+	writeFile(t, ctx, backend2, "p2/a.txt", "three")
+
+	result, err := Bisync(ctx, backend1, backend2, "p1", "p2", BisyncOptions{IgnoreExisting: true})
+	if err != nil {
+		t.Fatalf("Bisync failed: %v", err)
+	}
+	if len(result.Conflicts) != 0 || result.Skipped != 1 {
+		t.Errorf("Conflicts = %d, Skipped = %d; want 0, 1", len(result.Conflicts), result.Skipped)
+	}
+}
+
+func TestBisyncMaxTransfer(t *testing.T) {
+	ctx := context.Background()
+	backend1 := memory.New()
+	backend2 := memory.New()
+	writeFile(t, ctx, backend1, "p1/a.txt", "12345")
+	writeFile(t, ctx, backend1, "p1/b.txt", "12345")
+
+	result, err := Bisync(ctx, backend1, backend2, "p1", "p2", BisyncOptions{MaxTransferBytes: 7})
+	var le *LimitError
+	if !errors.As(err, &le) || le.Remaining != 1 {
+		t.Fatalf("Bisync error = %v, want a *LimitError with 1 file remaining", err)
+	}
+	if result.CopiedToPath2 != 1 {
+		t.Errorf("CopiedToPath2 = %d, want 1", result.CopiedToPath2)
+	}
+}
+
 func TestConflictStrategyConstants(t *testing.T) {
 	// Verify conflict strategy constants are distinct
 	strategies := []ConflictStrategy{
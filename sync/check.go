@@ -3,9 +3,14 @@ package sync
 import (
 	"context"
 	"io"
+	"log/slog"
 	"path"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/trash"
 )
 
 // Check compares files between source and destination backends.
@@ -18,28 +23,102 @@ import (
 //
 // By default, files are compared by size and modification time.
 // Set opts.Checksum to true for content-based comparison (slower but more accurate).
+// Paths are mapped with opts.PathTransform and matched under
+// opts.CaseInsensitive and opts.Normalize, as in Sync, and files under
+// opts.TrashPrefix in the destination are ignored.
 func Check(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) (*CheckResult, error) {
+	scan, err := check(ctx, src, dst, srcPath, dstPath, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return scan.result, nil
+}
+
+// CheckAndRepair compares source and destination like Check, then repairs
+// the destination from the comparison, without scanning either side
+// again: files only in the source are copied, files that differ are
+// updated and, if opts.DeleteExtra is set, files only in the destination
+// are deleted, as Sync would with opts. Files that could not be compared
+// are left alone and reported in the CheckResult.
+//
+// It returns the CheckResult of the comparison, which describes the
+// destination before the repair, and the Result of the repair.
+func CheckAndRepair(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) (*CheckResult, *Result, error) {
+	startTime := time.Now()
+	scan, err := check(ctx, src, dst, srcPath, dstPath, opts, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	checked := scan.result
+	opts.logger().Info("repairing destination",
+		slog.String("src_path", srcPath),
+		slog.String("dst_path", dstPath),
+		slog.Int("differ", len(checked.Differ)),
+		slog.Int("src_only", len(checked.SrcOnly)),
+		slog.Int("dst_only", len(checked.DstOnly)),
+	)
+	result, err := apply(ctx, src, dst, scan.plan, opts, startTime)
+	return checked, result, err
+}
+
+// checkScan is the outcome of comparing source and destination.
+type checkScan struct {
+	result *CheckResult
+
+	// plan makes the destination match the source, if requested.
+	plan *ActionPlan
+
+	// diff lists the differences, new files first, then modified, then
+	// deleted.
+	diff DiffEntries
+}
+
+// check compares source and destination. If repair is set, it also
+// returns the plan that makes the destination match the source.
+func check(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options, repair bool) (*checkScan, error) {
+	if err := opts.checkNames(); err != nil {
+		return nil, err
+	}
+	var transferCompare func(a, b FileInfo) int
+	if repair {
+		var err error
+		if transferCompare, err = opts.transferCompare(); err != nil {
+			return nil, err
+		}
+	}
+
 	result := &CheckResult{}
+	plan := &ActionPlan{SrcPath: srcPath, DstPath: dstPath, CreatedAt: time.Now()}
+	var toCopy, toDelete []Action
+	var added, modified, deleted DiffEntries
 
 	// List source files
-	srcFiles, err := listFiles(ctx, src, srcPath, opts)
+	excluded := make(map[string]bool)
+	srcFiles, err := scanFiles(ctx, src, srcPath, opts, true, excluded)
 	if err != nil {
 		return nil, err
 	}
 
-	// List destination files
-	dstFiles, err := listFiles(ctx, dst, dstPath, opts)
+	// List destination files, without the trash
+	dstFiles, err := listDestFiles(ctx, dst, dstPath, opts, excluded)
 	if err != nil {
 		return nil, err
 	}
+	if opts.TrashPrefix != "" {
+		tb := trash.New(dst, trash.WithPrefix(opts.TrashPrefix))
+		dstFiles = slices.DeleteFunc(dstFiles, func(f FileInfo) bool {
+			return tb.InTrash(path.Join(dstPath, f.Path))
+		})
+	}
 
-	// Build destination file map
-	dstMap := make(map[string]FileInfo)
+	// Index destination files by path key
+	var dstRegular []FileInfo
 	for _, f := range dstFiles {
 		if !f.IsDir {
-			dstMap[f.Path] = f
+			dstRegular = append(dstRegular, f)
 		}
 	}
+	dstIndex := newPathIndex(dstRegular, opts)
 
 	// Compare files
 	for _, srcFile := range srcFiles {
@@ -47,14 +126,22 @@ func Check(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath s
 			continue
 		}
 
-		dstFile, exists := dstMap[srcFile.Path]
+		dstName, err := opts.destName(srcFile.Path)
+		if err != nil {
+			return nil, err
+		}
+		if dstName == "" {
+			continue
+		}
+
+		dstFile, exists := dstIndex.take(dstName)
 		if !exists {
 			result.SrcOnly = append(result.SrcOnly, srcFile.Path)
+			toCopy = append(toCopy, newAction(ActionCopy, srcFile, dstName))
+			added = append(added, newDiffEntry(DiffStatusNew, srcFile.Path, &srcFile, nil))
 			continue
 		}
 
-		delete(dstMap, srcFile.Path)
-
 		// Compare the files
 		same, err := filesMatch(ctx, src, dst, srcFile, dstFile, srcPath, dstPath, opts)
 		if err != nil {
@@ -68,17 +155,50 @@ func Check(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath s
 
 		if same {
 			result.Match = append(result.Match, srcFile.Path)
+			plan.Skipped++
 		} else {
 			result.Differ = append(result.Differ, srcFile.Path)
+			modified = append(modified, newDiffEntry(DiffStatusModified, srcFile.Path, &srcFile, &dstFile))
+			if opts.keepsExisting(srcFile, dstFile) {
+				plan.Skipped++
+			} else {
+				toCopy = append(toCopy, newAction(ActionUpdate, srcFile, dstFile.Path))
+			}
 		}
 	}
 
 	// Remaining files exist only in destination
-	for p := range dstMap {
-		result.DstOnly = append(result.DstOnly, p)
+	for _, f := range dstIndex.rest() {
+		result.DstOnly = append(result.DstOnly, f.Path)
+		deleted = append(deleted, newDiffEntry(DiffStatusDeleted, f.Path, nil, &f))
+		if opts.DeleteExtra {
+			toDelete = append(toDelete, Action{Type: ActionDelete, File: f})
+		}
+	}
+
+	scan := &checkScan{result: result, diff: slices.Concat(added, modified, deleted)}
+	if !repair {
+		return scan, nil
 	}
 
-	return result, nil
+	if transferCompare != nil {
+		slices.SortStableFunc(toCopy, func(a, b Action) int {
+			return transferCompare(a.File, b.File)
+		})
+	}
+	slices.SortFunc(toDelete, func(a, b Action) int {
+		return strings.Compare(a.File.Path, b.File.Path)
+	})
+	if opts.CreateEmptyDirs {
+		plan.Actions, err = planDirs(ctx, src, dst, srcPath, dstPath, srcFiles, dstFiles, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	plan.Actions = append(plan.Actions, toCopy...)
+	plan.Actions = append(plan.Actions, toDelete...)
+	scan.plan = plan
+	return scan, nil
 }
 
 // filesMatch determines if two files are the same.
@@ -173,55 +293,14 @@ func compareContent(ctx context.Context, src, dst omnistorage.Backend, srcRelPat
 	}
 }
 
-// Diff returns a human-readable summary of differences between backends.
-func Diff(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) ([]DiffEntry, error) {
-	checkResult, err := Check(ctx, src, dst, srcPath, dstPath, opts)
+// Diff returns the differences between source and destination, as Check
+// finds them: new files first, then modified, then deleted, with their
+// sizes and modification times. Render them with WriteText, WriteJSON or
+// WriteNDJSON.
+func Diff(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) (DiffEntries, error) {
+	scan, err := check(ctx, src, dst, srcPath, dstPath, opts, false)
 	if err != nil {
 		return nil, err
 	}
-
-	var entries []DiffEntry
-
-	for _, p := range checkResult.SrcOnly {
-		entries = append(entries, DiffEntry{
-			Path:   p,
-			Status: DiffStatusNew,
-		})
-	}
-
-	for _, p := range checkResult.Differ {
-		entries = append(entries, DiffEntry{
-			Path:   p,
-			Status: DiffStatusModified,
-		})
-	}
-
-	for _, p := range checkResult.DstOnly {
-		entries = append(entries, DiffEntry{
-			Path:   p,
-			Status: DiffStatusDeleted,
-		})
-	}
-
-	return entries, nil
+	return scan.diff, nil
 }
-
-// DiffEntry represents a single difference between backends.
-type DiffEntry struct {
-	Path   string
-	Status DiffStatus
-}
-
-// DiffStatus represents the type of difference.
-type DiffStatus string
-
-const (
-	// DiffStatusNew indicates a file exists only in source.
-	DiffStatusNew DiffStatus = "new"
-
-	// DiffStatusModified indicates a file differs between source and destination.
-	DiffStatusModified DiffStatus = "modified"
-
-	// DiffStatusDeleted indicates a file exists only in destination.
-	DiffStatusDeleted DiffStatus = "deleted"
-)
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/compress/gzip"
+)
+
+// compressedExts are the extensions of file types that are already
+// compressed and gain nothing from compression on the wire.
+var compressedExts = map[string]bool{
+	".7z": true, ".br": true, ".bz2": true, ".gz": true, ".lz4": true,
+	".rar": true, ".tgz": true, ".xz": true, ".zip": true, ".zst": true,
+	".avif": true, ".gif": true, ".heic": true, ".jpeg": true, ".jpg": true,
+	".png": true, ".webp": true,
+	".aac": true, ".flac": true, ".m4a": true, ".mp3": true, ".ogg": true, ".opus": true,
+	".avi": true, ".m4v": true, ".mkv": true, ".mov": true, ".mp4": true, ".webm": true,
+	".docx": true, ".jar": true, ".pptx": true, ".xlsx": true, ".parquet": true,
+}
+
+// IsCompressedFile reports whether the extension of p names a file type
+// that is already compressed, such as .gz, .jpg or .mp4. It is the default
+// Options.TransferCompressionSkip.
+func IsCompressedFile(p string) bool {
+	return compressedExts[strings.ToLower(path.Ext(p))]
+}
+
+// transferSource returns the backend to read the source file srcPath from:
+// src, or src read compressed if TransferCompression applies to the file.
+func (o Options) transferSource(src omnistorage.Backend, srcPath string) omnistorage.Backend {
+	if !o.TransferCompression || o.TransformReader != nil {
+		return src
+	}
+	cr, ok := src.(omnistorage.CompressedReader)
+	if !ok {
+		return src
+	}
+	skip := o.TransferCompressionSkip
+	if skip == nil {
+		skip = IsCompressedFile
+	}
+	if skip(srcPath) {
+		return src
+	}
+	return compressedSource{Backend: src, cr: cr}
+}
+
+// compressedSource reads from a backend through its CompressedReader and
+// decompresses on the client.
+type compressedSource struct {
+	omnistorage.Backend
+	cr omnistorage.CompressedReader
+}
+
+// NewReader reads path compressed, or uncompressed if the backend cannot
+// compress it or a byte range is requested.
+func (s compressedSource) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if len(opts) > 0 {
+		return s.Backend.NewReader(ctx, p, opts...)
+	}
+	r, err := s.cr.NewCompressedReader(ctx, p)
+	if errors.Is(err, omnistorage.ErrNotSupported) {
+		return s.Backend.NewReader(ctx, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	return zr, nil
+}
@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// gzipSource serves compressed reads of a memory backend and counts them.
+type gzipSource struct {
+	*memory.Backend
+	compressed int
+}
+
+func (s *gzipSource) NewCompressedReader(ctx context.Context, p string) (io.ReadCloser, error) {
+	r, err := s.Backend.NewReader(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, r); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	s.compressed++
+	return io.NopCloser(&buf), nil
+}
+
+var _ omnistorage.CompressedReader = (*gzipSource)(nil)
+
+func TestSyncTransferCompression(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	src := &gzipSource{Backend: mem}
+	dst := memory.New()
+
+	text := strings.Repeat("log line\n", 1000)
+	writeFile(t, ctx, mem, "app.log", text)
+	writeFile(t, ctx, mem, "photo.JPG", "jpeg data")
+
+	result, err := Sync(ctx, src, dst, "", "", Options{TransferCompression: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 2 {
+		t.Errorf("Copied = %d, want 2", result.Copied)
+	}
+	if src.compressed != 1 {
+		t.Errorf("compressed reads = %d, want 1 (photo.JPG skipped)", src.compressed)
+	}
+	if got := readBackend(t, dst, "app.log"); got != text {
+		t.Errorf("app.log has %d bytes, want %d", len(got), len(text))
+	}
+	if got := readBackend(t, dst, "photo.JPG"); got != "jpeg data" {
+		t.Errorf("photo.JPG = %q, want %q", got, "jpeg data")
+	}
+
+	// Without the option, reads are uncompressed
+	src.compressed = 0
+	writeFile(t, ctx, mem, "app.log", text+"more\n")
+	if _, err := Sync(ctx, src, dst, "", "", Options{}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if src.compressed != 0 {
+		t.Errorf("compressed reads = %d, want 0", src.compressed)
+	}
+}
+
+func TestIsCompressedFile(t *testing.T) {
+	for p, want := range map[string]bool{
+		"a/b.tar.gz": true,
+		"IMG.JPG":    true,
+		"movie.mp4":  true,
+		"app.log":    false,
+		"data.csv":   false,
+		"noext":      false,
+	} {
+		if got := IsCompressedFile(p); got != want {
+			t.Errorf("IsCompressedFile(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
@@ -2,26 +2,38 @@ package sync
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"path"
 	"time"
 
 	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/metrics"
+	"github.com/grokify/omnistorage/sync/filter"
 )
 
 // Copy copies files from source to destination.
 //
 // If srcPath is a file, it copies that single file.
 // If srcPath is a directory (or prefix), it copies all files recursively.
+// If srcPath is a glob pattern (see omnistorage.MatchGlob), such as
+// "logs/2024-*/**/*.json.gz", it copies the matching files, with their
+// paths relative to the pattern's base directory (omnistorage.GlobBase),
+// "logs" here. Escape metacharacters in a literal path with \.
 //
 // Copy never deletes files from the destination. Use Sync with DeleteExtra=true
 // for mirror behavior.
 //
 // Options that affect Copy:
 //   - DryRun: report what would be copied without copying
-//   - IgnoreExisting: skip files that already exist in destination
+//   - IgnoreExisting, NoOverwrite: skip files that already exist in destination
+//   - UpdateOnly: skip files that are not newer in the source
 //   - Progress: callback for progress updates
 func Copy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) (*Result, error) {
+	if omnistorage.HasGlob(srcPath) {
+		return copyGlob(ctx, src, dst, srcPath, dstPath, opts)
+	}
+
 	startTime := time.Now()
 	result := &Result{DryRun: opts.DryRun}
 
@@ -31,26 +43,25 @@ func Copy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 		return nil, err
 	}
 
-	// Try to determine if it's a single file by checking if List returns just this path
+	// It's a single file if it exists and List, of the directory it
+	// would be, returns nothing
 	srcPaths, err := src.List(ctx, srcPath)
 	if err != nil {
 		return nil, err
 	}
 
-	isSingleFile := srcExists && len(srcPaths) == 1 && srcPaths[0] == srcPath
+	isSingleFile := srcExists && len(srcPaths) == 0 && !isDir(ctx, src, srcPath)
 
 	if isSingleFile {
 		// Single file copy
-		if opts.IgnoreExisting {
-			dstExists, err := dst.Exists(ctx, dstPath)
-			if err != nil {
-				return nil, err
-			}
-			if dstExists {
-				result.Skipped = 1
-				result.Duration = time.Since(startTime)
-				return result, nil
-			}
+		keep, err := opts.keepsExistingPath(ctx, src, dst, srcPath, dstPath)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			result.Skipped = 1
+			result.Duration = time.Since(startTime)
+			return result, nil
 		}
 
 		if opts.Progress != nil {
@@ -61,8 +72,16 @@ func Copy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 			})
 		}
 
+		update := destExists(ctx, dst, dstPath)
 		if !opts.DryRun {
-			if err := copyFile(ctx, src, dst, srcPath, dstPath); err != nil {
+			op := metrics.TransferCopy
+			if update {
+				op = metrics.TransferUpdate
+			}
+			start := time.Now()
+			err := copyFile(ctx, src, dst, srcPath, dstPath)
+			observe(opts.Metrics, op, srcPath, fileSize(ctx, src, srcPath), start, err)
+			if err != nil {
 				result.Errors = append(result.Errors, FileError{
 					Path: srcPath,
 					Op:   "copy",
@@ -73,13 +92,16 @@ func Copy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 			}
 		}
 
-		result.Copied = 1
-
-		// Get file size for stats
-		if ext, ok := omnistorage.AsExtended(src); ok {
-			if info, err := ext.Stat(ctx, srcPath); err == nil {
-				result.BytesTransferred = info.Size()
-			}
+		action := Action{Type: ActionCopy, File: FileInfo{Path: srcPath, Size: fileSize(ctx, src, srcPath)}, DstPath: dstPath}
+		if update {
+			action.Type = ActionUpdate
+			result.Updated = 1
+		} else {
+			result.Copied = 1
+		}
+		result.BytesTransferred = action.File.Size
+		if opts.DryRun {
+			result.Actions = []Action{action}
 		}
 
 		if opts.Progress != nil {
@@ -99,6 +121,25 @@ func Copy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 	return Sync(ctx, src, dst, srcPath, dstPath, opts)
 }
 
+// copyGlob copies the files matching the glob pattern srcPath, as a
+// directory copy of the pattern's base directory filtered by the pattern.
+func copyGlob(ctx context.Context, src, dst omnistorage.Backend, pattern, dstPath string, opts Options) (*Result, error) {
+	if _, err := omnistorage.MatchGlob(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, pattern)
+	}
+	base := omnistorage.GlobBase(pattern)
+	rules := []filter.Option{filter.Where(filter.MatcherFunc(func(fi filter.FileInfo) bool {
+		ok, _ := omnistorage.MatchGlob(pattern, path.Join(base, fi.Path))
+		return ok
+	}))}
+	if opts.Filter != nil {
+		rules = append(rules, filter.Where(opts.Filter))
+	}
+	opts.Filter = filter.New(rules...)
+	opts.DeleteExtra = false
+	return Sync(ctx, src, dst, base, dstPath, opts)
+}
+
 // CopyFile copies a single file from source to destination backend.
 //
 // This is a convenience function for copying individual files across backends.
@@ -194,9 +235,10 @@ func CopyBetweenPaths(ctx context.Context, src, dst omnistorage.Backend, srcPath
 func TreeCopy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) (*Result, error) {
 	startTime := time.Now()
 	result := &Result{DryRun: opts.DryRun}
+	budget := newTransferBudget(opts, startTime)
 
-	// List all source files
-	srcPaths, err := src.List(ctx, srcPath)
+	// List all source files, relative to srcPath
+	relPaths, err := src.List(ctx, srcPath)
 	if err != nil {
 		return nil, err
 	}
@@ -204,11 +246,11 @@ func TreeCopy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPat
 	if opts.Progress != nil {
 		opts.Progress(Progress{
 			Phase:      PhaseTransferring,
-			TotalFiles: len(srcPaths),
+			TotalFiles: len(relPaths),
 		})
 	}
 
-	for i, p := range srcPaths {
+	for i, relPath := range relPaths {
 		select {
 		case <-ctx.Done():
 			result.Duration = time.Since(startTime)
@@ -217,13 +259,7 @@ func TreeCopy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPat
 		}
 
 		// Preserve relative path structure
-		relPath := p
-		if len(srcPath) > 0 && len(p) > len(srcPath) {
-			relPath = p[len(srcPath):]
-			if len(relPath) > 0 && relPath[0] == '/' {
-				relPath = relPath[1:]
-			}
-		}
+		p := omnistorage.ListedPath(srcPath, relPath)
 		fullDstPath := path.Join(dstPath, relPath)
 
 		if opts.Progress != nil {
@@ -231,20 +267,35 @@ func TreeCopy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPat
 				Phase:            PhaseTransferring,
 				CurrentFile:      p,
 				FilesTransferred: i,
-				TotalFiles:       len(srcPaths),
+				TotalFiles:       len(relPaths),
 			})
 		}
 
-		if opts.IgnoreExisting {
-			exists, _ := dst.Exists(ctx, fullDstPath)
-			if exists {
-				result.Skipped++
-				continue
-			}
+		keep, err := opts.keepsExistingPath(ctx, src, dst, p, fullDstPath)
+		if err != nil {
+			result.Errors = append(result.Errors, FileError{Path: p, Op: "copy", Err: err})
+			continue
+		}
+		if keep {
+			result.Skipped++
+			continue
+		}
+
+		size := fileSize(ctx, src, p)
+		if !budget.take(size) {
+			continue
 		}
 
+		update := destExists(ctx, dst, fullDstPath)
 		if !opts.DryRun {
-			if err := copyFile(ctx, src, dst, p, fullDstPath); err != nil {
+			op := metrics.TransferCopy
+			if update {
+				op = metrics.TransferUpdate
+			}
+			start := time.Now()
+			err := copyFile(ctx, src, dst, p, fullDstPath)
+			observe(opts.Metrics, op, relPath, size, start, err)
+			if err != nil {
 				result.Errors = append(result.Errors, FileError{
 					Path: p,
 					Op:   "copy",
@@ -258,16 +309,92 @@ func TreeCopy(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPat
 			}
 		}
 
-		result.Copied++
+		action := newAction(ActionCopy, FileInfo{Path: relPath, Size: size}, relPath)
+		if update {
+			action.Type = ActionUpdate
+			result.Updated++
+		} else {
+			result.Copied++
+		}
+		result.BytesTransferred += size
+		if opts.DryRun {
+			result.Actions = append(result.Actions, action)
+		}
 	}
 
 	if opts.Progress != nil {
 		opts.Progress(Progress{
 			Phase:            PhaseComplete,
-			FilesTransferred: result.Copied,
+			FilesTransferred: result.Copied + result.Updated,
+			BytesTransferred: result.BytesTransferred,
 		})
 	}
 
 	result.Duration = time.Since(startTime)
-	return result, nil
+	return result, budget.err()
+}
+
+// fileSize returns the size of p, or 0 if the backend cannot stat it.
+func fileSize(ctx context.Context, backend omnistorage.Backend, p string) int64 {
+	if ext, ok := omnistorage.AsExtended(backend); ok {
+		if info, err := ext.Stat(ctx, p); err == nil {
+			return info.Size()
+		}
+	}
+	return 0
+}
+
+// isDir reports whether p is a directory, such as an empty one, which
+// lists no files. A backend that cannot stat p has no directories.
+func isDir(ctx context.Context, backend omnistorage.Backend, p string) bool {
+	if ext, ok := omnistorage.AsExtended(backend); ok {
+		if info, err := ext.Stat(ctx, p); err == nil {
+			return info.IsDir()
+		}
+	}
+	return false
+}
+
+// destExists reports whether p exists in dst, so that copying to it is an
+// update. A failed check counts as a new copy.
+func destExists(ctx context.Context, dst omnistorage.Backend, p string) bool {
+	exists, err := dst.Exists(ctx, p)
+	return err == nil && exists
+}
+
+// keepsExistingPath reports whether the file at dstPath exists and is
+// kept rather than replaced by srcPath, under IgnoreExisting, NoOverwrite
+// or UpdateOnly.
+func (o Options) keepsExistingPath(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string) (bool, error) {
+	if !o.IgnoreExisting && !o.NoOverwrite && !o.UpdateOnly {
+		return false, nil
+	}
+	exists, err := dst.Exists(ctx, dstPath)
+	if err != nil || !exists {
+		return false, err
+	}
+	if o.IgnoreExisting || o.NoOverwrite {
+		return true, nil
+	}
+	return !isNewer(modTime(ctx, src, srcPath), modTime(ctx, dst, dstPath)), nil
+}
+
+// refusesOverwrite reports whether NoOverwrite forbids writing dstPath,
+// because a file exists there.
+func (o Options) refusesOverwrite(ctx context.Context, dst omnistorage.Backend, dstPath string) (bool, error) {
+	if !o.NoOverwrite {
+		return false, nil
+	}
+	return dst.Exists(ctx, dstPath)
+}
+
+// modTime returns the modification time of p, or the zero time if the
+// backend does not report one.
+func modTime(ctx context.Context, backend omnistorage.Backend, p string) time.Time {
+	if ext, ok := omnistorage.AsExtended(backend); ok {
+		if info, err := ext.Stat(ctx, p); err == nil {
+			return info.ModTime()
+		}
+	}
+	return time.Time{}
 }
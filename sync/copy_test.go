@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/sync/filter"
 )
 
 func TestCopySingleFile(t *testing.T) {
@@ -53,6 +54,33 @@ func TestCopyDirectory(t *testing.T) {
 	verifyFile(t, ctx, dst, "backup/sub/file3.txt", "content3")
 }
 
+func TestCopyGlob(t *testing.T) {
+	ctx := context.Background()
+
+	src := memory.New()
+	dst := memory.New()
+
+	writeFile(t, ctx, src, "logs/2024-01/a.json.gz", "a")
+	writeFile(t, ctx, src, "logs/2024-02/x/b.json.gz", "b")
+	writeFile(t, ctx, src, "logs/2024-02/c.txt", "c")
+	writeFile(t, ctx, src, "logs/2023-12/d.json.gz", "d")
+
+	f := filter.New(filter.Exclude("b.*"))
+	result, err := Copy(ctx, src, dst, "logs/2024-*/**/*.json.gz", "archive", Options{Filter: f})
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	if result.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", result.Copied)
+	}
+	verifyFile(t, ctx, dst, "archive/2024-01/a.json.gz", "a")
+
+	if _, err := Copy(ctx, src, dst, "logs/[2024", "archive", Options{}); err == nil {
+		t.Error("Copy with a bad pattern should fail")
+	}
+}
+
 func TestCopyIgnoreExisting(t *testing.T) {
 	ctx := context.Background()
 
@@ -188,6 +216,31 @@ func TestTreeCopy(t *testing.T) {
 	}
 }
 
+func TestTreeCopyDryRun(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "data/a.txt", "aa")
+	writeFile(t, ctx, src, "data/sub/b.txt", "bbb")
+	writeFile(t, ctx, dst, "backup/a.txt", "old")
+
+	result, err := TreeCopy(ctx, src, dst, "data", "backup", Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Copied != 1 || result.Updated != 1 || result.BytesTransferred != 5 {
+		t.Errorf("copied %d, updated %d, %d bytes; want 1, 1, 5", result.Copied, result.Updated, result.BytesTransferred)
+	}
+	if len(result.Actions) != 2 || result.Actions[0].Type != ActionUpdate || result.Actions[1].File.Path != "sub/b.txt" {
+		t.Errorf("Actions = %+v", result.Actions)
+	}
+
+	result, err = Copy(ctx, src, dst, "data/a.txt", "backup/a.txt", Options{DryRun: true})
+	if err != nil || result.Updated != 1 || result.Copied != 0 || len(result.Actions) != 1 {
+		t.Errorf("single file Copy = %+v, %v; want 1 update", result, err)
+	}
+}
+
 func TestCopyWithProgress(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,294 @@
+package sync
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/sync/filter"
+)
+
+// DedupeKeep selects which file of a duplicate group is kept.
+type DedupeKeep string
+
+const (
+	// KeepFirst keeps the file with the alphabetically first path.
+	KeepFirst DedupeKeep = "first"
+
+	// KeepNewest keeps the most recently modified file.
+	KeepNewest DedupeKeep = "newest"
+
+	// KeepOldest keeps the least recently modified file.
+	KeepOldest DedupeKeep = "oldest"
+)
+
+// DedupeAction is what Dedupe does with the duplicates it does not keep.
+type DedupeAction string
+
+const (
+	// DedupeReport only reports duplicates.
+	DedupeReport DedupeAction = ""
+
+	// DedupeDelete deletes duplicates.
+	DedupeDelete DedupeAction = "delete"
+
+	// DedupeLink replaces duplicates with links to the kept file. The
+	// backend must implement omnistorage.Linker.
+	DedupeLink DedupeAction = "link"
+
+	// DedupeRename renames duplicates by appending RenameSuffix, so they
+	// can be reviewed before they are deleted.
+	DedupeRename DedupeAction = "rename"
+)
+
+// DedupeOptions configures Dedupe.
+type DedupeOptions struct {
+	// Action is what to do with duplicates. Default: DedupeReport.
+	Action DedupeAction
+
+	// Keep selects the file kept in each group. Default: KeepFirst.
+	Keep DedupeKeep
+
+	// Select, if set, is called for each duplicate group and returns the
+	// path to keep, overriding Keep. Returning "" leaves the group alone;
+	// returning an error stops Dedupe. Use it for interactive review.
+	Select func(ctx context.Context, g DuplicateGroup) (string, error)
+
+	// Hash is the hash used to compare files of equal size.
+	// Default: omnistorage.HashMD5.
+	Hash omnistorage.HashType
+
+	// RenameSuffix is appended to duplicates by DedupeRename.
+	// Default: ".dup".
+	RenameSuffix string
+
+	// Filter limits the files considered. Empty files are all duplicates
+	// of each other; use filter.MinSize(1) to skip them.
+	Filter *filter.Filter
+
+	// DryRun reports what would be done without making changes.
+	DryRun bool
+}
+
+// DuplicateGroup is a set of files with the same size and hash.
+type DuplicateGroup struct {
+	// Size is the size of each file.
+	Size int64
+
+	// Hash is the content hash of each file.
+	Hash string
+
+	// Files lists the duplicates, sorted by path. Paths are relative to
+	// the Dedupe prefix.
+	Files []FileInfo
+
+	// Keep is the path kept, or "" if the group was left alone.
+	Keep string
+}
+
+// DedupeResult contains the results of Dedupe.
+type DedupeResult struct {
+	// Groups lists the duplicate groups found.
+	Groups []DuplicateGroup
+
+	// Removed is the number of duplicates deleted, linked or renamed.
+	Removed int
+
+	// BytesReclaimed is the total size of the removed duplicates.
+	BytesReclaimed int64
+
+	// Errors contains any errors that occurred.
+	Errors []FileError
+
+	// DryRun indicates if this was a dry run.
+	DryRun bool
+}
+
+// Duplicates returns the number of files that are duplicates of a file
+// kept, or that would be with the default Keep policy.
+func (r *DedupeResult) Duplicates() int {
+	n := 0
+	for _, g := range r.Groups {
+		n += len(g.Files) - 1
+	}
+	return n
+}
+
+// Dedupe finds files under prefix with identical content and, depending
+// on opts.Action, reports, deletes, links or renames all but one file of
+// each group.
+//
+// Files are grouped by size first, so only files of equal size are
+// hashed. The hash is taken from the backend's metadata when available
+// and computed by reading the file otherwise.
+func Dedupe(ctx context.Context, backend omnistorage.Backend, prefix string, opts DedupeOptions) (*DedupeResult, error) {
+	if opts.Hash == omnistorage.HashNone {
+		opts.Hash = omnistorage.HashMD5
+	}
+	if opts.Keep == "" {
+		opts.Keep = KeepFirst
+	}
+	if opts.RenameSuffix == "" {
+		opts.RenameSuffix = ".dup"
+	}
+	switch opts.Action {
+	case DedupeReport, DedupeDelete, DedupeRename:
+	case DedupeLink:
+		if _, ok := backend.(omnistorage.Linker); !ok {
+			return nil, fmt.Errorf("dedupe link: %w", omnistorage.ErrNotSupported)
+		}
+	default:
+		return nil, fmt.Errorf("unknown dedupe action: %q", opts.Action)
+	}
+	if omnistorage.NewHash(opts.Hash) == nil {
+		return nil, fmt.Errorf("dedupe hash %q: %w", opts.Hash, omnistorage.ErrNotSupported)
+	}
+
+	files, err := listFiles(ctx, backend, prefix, Options{Filter: opts.Filter})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DedupeResult{DryRun: opts.DryRun}
+
+	bySize := make(map[int64][]FileInfo)
+	for _, f := range files {
+		if !f.IsDir {
+			bySize[f.Size] = append(bySize[f.Size], f)
+		}
+	}
+
+	for _, same := range bySize {
+		if len(same) < 2 {
+			continue
+		}
+		byHash := make(map[string][]FileInfo)
+		for _, f := range same {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			h, err := contentHash(ctx, backend, path.Join(prefix, f.Path), opts.Hash)
+			if err != nil {
+				result.Errors = append(result.Errors, FileError{Path: f.Path, Op: "hash", Err: err})
+				continue
+			}
+			f.Hash = h
+			byHash[h] = append(byHash[h], f)
+		}
+		for h, dups := range byHash {
+			if len(dups) < 2 {
+				continue
+			}
+			slices.SortFunc(dups, func(a, b FileInfo) int { return strings.Compare(a.Path, b.Path) })
+			result.Groups = append(result.Groups, DuplicateGroup{Size: dups[0].Size, Hash: h, Files: dups})
+		}
+	}
+
+	// Largest groups by reclaimable bytes first, then by path.
+	slices.SortFunc(result.Groups, func(a, b DuplicateGroup) int {
+		if c := cmp.Compare(b.Size*int64(len(b.Files)-1), a.Size*int64(len(a.Files)-1)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Files[0].Path, b.Files[0].Path)
+	})
+
+	for i := range result.Groups {
+		g := &result.Groups[i]
+		keep, err := opts.keep(ctx, *g)
+		if err != nil {
+			return result, err
+		}
+		g.Keep = keep
+		if keep == "" || opts.Action == DedupeReport {
+			continue
+		}
+
+		for _, f := range g.Files {
+			if f.Path == keep {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			if !opts.DryRun {
+				if err := opts.remove(ctx, backend, prefix, keep, f.Path); err != nil {
+					result.Errors = append(result.Errors, FileError{Path: f.Path, Op: string(opts.Action), Err: err})
+					continue
+				}
+			}
+			result.Removed++
+			result.BytesReclaimed += f.Size
+		}
+	}
+
+	return result, nil
+}
+
+// keep returns the path to keep in g.
+func (o DedupeOptions) keep(ctx context.Context, g DuplicateGroup) (string, error) {
+	if o.Select != nil {
+		keep, err := o.Select(ctx, g)
+		if err != nil || keep == "" {
+			return "", err
+		}
+		if !slices.ContainsFunc(g.Files, func(f FileInfo) bool { return f.Path == keep }) {
+			return "", fmt.Errorf("dedupe: selected path %s is not in the group", keep)
+		}
+		return keep, nil
+	}
+
+	best := g.Files[0]
+	for _, f := range g.Files[1:] {
+		switch o.Keep {
+		case KeepNewest:
+			if f.ModTime.After(best.ModTime) {
+				best = f
+			}
+		case KeepOldest:
+			if f.ModTime.Before(best.ModTime) {
+				best = f
+			}
+		case KeepFirst:
+		default:
+			return "", fmt.Errorf("unknown dedupe keep policy: %q", o.Keep)
+		}
+	}
+	return best.Path, nil
+}
+
+// remove applies the dedupe action to the duplicate dup of keep.
+func (o DedupeOptions) remove(ctx context.Context, backend omnistorage.Backend, prefix, keep, dup string) error {
+	dupPath := path.Join(prefix, dup)
+	switch o.Action {
+	case DedupeDelete:
+		return deleteUnlocked(ctx, backend, backend, dupPath)
+	case DedupeLink:
+		return backend.(omnistorage.Linker).Link(ctx, path.Join(prefix, keep), dupPath)
+	case DedupeRename:
+		return MoveFile(ctx, backend, backend, dupPath, dupPath+o.RenameSuffix)
+	default:
+		return nil
+	}
+}
+
+// contentHash returns the hash of p, from the backend's metadata if it
+// has one and by reading the file otherwise.
+func contentHash(ctx context.Context, backend omnistorage.Backend, p string, t omnistorage.HashType) (string, error) {
+	if ext, ok := omnistorage.AsExtended(backend); ok {
+		if info, err := ext.Stat(ctx, p); err == nil {
+			if h := info.Hash(t); h != "" {
+				return h, nil
+			}
+		}
+	}
+	r, err := backend.NewReader(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = r.Close() }()
+	return omnistorage.HashReader(r, t)
+}
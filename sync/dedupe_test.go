@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/file"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/sync/filter"
+)
+
+// writeDuplicates writes two duplicate groups and one unique file of the
+// same size as the first group.
+func writeDuplicates(t *testing.T, ctx context.Context, backend *memory.Backend) {
+	t.Helper()
+	writeFile(t, ctx, backend, "up/a.jpg", "photo")
+	writeFile(t, ctx, backend, "up/b.jpg", "photo")
+	writeFile(t, ctx, backend, "up/c.jpg", "photo")
+	writeFile(t, ctx, backend, "up/other.jpg", "other")
+	writeFile(t, ctx, backend, "up/x.txt", "document body")
+	writeFile(t, ctx, backend, "up/y.txt", "document body")
+}
+
+func TestDedupeReport(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeDuplicates(t, ctx, backend)
+
+	result, err := Dedupe(ctx, backend, "up", DedupeOptions{})
+	if err != nil {
+		t.Fatalf("Dedupe error = %v", err)
+	}
+	if len(result.Groups) != 2 || result.Duplicates() != 3 || result.Removed != 0 {
+		t.Fatalf("Groups = %+v, Removed = %d; want 2 groups, 3 duplicates, nothing removed", result.Groups, result.Removed)
+	}
+
+	// The group with the most reclaimable bytes comes first.
+	g := result.Groups[0]
+	if g.Size != 13 || g.Keep != "x.txt" || len(g.Files) != 2 || g.Hash != omnistorage.HashBytes([]byte("document body"), omnistorage.HashMD5) {
+		t.Errorf("Groups[0] = %+v", g)
+	}
+	if paths, _ := backend.List(ctx, "up"); len(paths) != 6 {
+		t.Errorf("report mode changed files: %v", paths)
+	}
+}
+
+func TestDedupeDeleteKeepNewest(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeFile(t, ctx, backend, "a.bin", "same")
+	time.Sleep(10 * time.Millisecond)
+	writeFile(t, ctx, backend, "b.bin", "same")
+	writeFile(t, ctx, backend, "empty1", "")
+	writeFile(t, ctx, backend, "empty2", "")
+
+	result, err := Dedupe(ctx, backend, "", DedupeOptions{
+		Action: DedupeDelete,
+		Keep:   KeepNewest,
+		Filter: filter.New(filter.MinSize(1)),
+	})
+	if err != nil {
+		t.Fatalf("Dedupe error = %v", err)
+	}
+	if result.Removed != 1 || result.BytesReclaimed != 4 {
+		t.Errorf("Removed = %d, BytesReclaimed = %d; want 1, 4", result.Removed, result.BytesReclaimed)
+	}
+	if exists, _ := backend.Exists(ctx, "a.bin"); exists {
+		t.Error("older a.bin kept")
+	}
+	if exists, _ := backend.Exists(ctx, "b.bin"); !exists {
+		t.Error("newer b.bin deleted")
+	}
+	if exists, _ := backend.Exists(ctx, "empty2"); !exists {
+		t.Error("empty file deleted despite filter")
+	}
+}
+
+func TestDedupeSelectAndRename(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeDuplicates(t, ctx, backend)
+
+	var asked int
+	result, err := Dedupe(ctx, backend, "up", DedupeOptions{
+		Action: DedupeRename,
+		Select: func(_ context.Context, g DuplicateGroup) (string, error) {
+			asked++
+			if g.Size == 13 {
+				return "", nil // leave the documents alone
+			}
+			return "c.jpg", nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Dedupe error = %v", err)
+	}
+	if asked != 2 || result.Removed != 2 {
+		t.Errorf("asked = %d, Removed = %d; want 2, 2", asked, result.Removed)
+	}
+	paths, _ := backend.List(ctx, "up")
+	slices.Sort(paths)
+	want := []string{"a.jpg.dup", "b.jpg.dup", "c.jpg", "other.jpg", "x.txt", "y.txt"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+
+	stop := errors.New("stop")
+	_, err = Dedupe(ctx, backend, "up", DedupeOptions{
+		Select: func(context.Context, DuplicateGroup) (string, error) { return "", stop },
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Dedupe error = %v, want Select error", err)
+	}
+}
+
+func TestDedupeDryRun(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeDuplicates(t, ctx, backend)
+
+	result, err := Dedupe(ctx, backend, "up", DedupeOptions{Action: DedupeDelete, DryRun: true})
+	if err != nil {
+		t.Fatalf("Dedupe error = %v", err)
+	}
+	if result.Removed != 3 || !result.DryRun {
+		t.Errorf("Removed = %d, DryRun = %v; want 3, true", result.Removed, result.DryRun)
+	}
+	if paths, _ := backend.List(ctx, "up"); len(paths) != 6 {
+		t.Errorf("dry run changed files: %v", paths)
+	}
+}
+
+func TestDedupeLink(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	backend := file.New(file.Config{Root: dir, CreateDirs: true})
+	for _, p := range []string{"a.iso", "b.iso"} {
+		if err := os.WriteFile(filepath.Join(dir, p), []byte("image"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Dedupe(ctx, backend, "", DedupeOptions{Action: DedupeLink})
+	if err != nil {
+		t.Fatalf("Dedupe error = %v", err)
+	}
+	if result.Removed != 1 {
+		t.Errorf("Removed = %d, want 1", result.Removed)
+	}
+	a, _ := os.Stat(filepath.Join(dir, "a.iso"))
+	b, _ := os.Stat(filepath.Join(dir, "b.iso"))
+	if !os.SameFile(a, b) {
+		t.Error("b.iso is not linked to a.iso")
+	}
+
+	if _, err := Dedupe(ctx, memory.New(), "", DedupeOptions{Action: DedupeLink}); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Dedupe link on memory backend: err = %v, want ErrNotSupported", err)
+	}
+}
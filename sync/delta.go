@@ -0,0 +1,401 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// DefaultDeltaMinSize is the smallest destination file updated by a delta
+// transfer unless Options.DeltaMinSize is set.
+const DefaultDeltaMinSize = 16 << 20
+
+// deltaBlockSize is the size of the blocks compared by a delta transfer.
+const deltaBlockSize = 128 << 10
+
+// deltaSignatureSuffix is appended to a destination path to name its
+// signature in Options.DeltaSignatures.
+const deltaSignatureSuffix = ".delta.json"
+
+// blockSum is the checksum pair of one block: the rsync weak checksum,
+// which can be rolled along the source a byte at a time and rules out most
+// windows cheaply, and a SHA-256 that confirms a match.
+type blockSum struct {
+	Weak   uint32   `json:"w"`
+	Strong [32]byte `json:"s"`
+}
+
+// deltaSignature lists the block checksums of a destination file. Size
+// and ModTime identify the version of the file it was computed from.
+type deltaSignature struct {
+	Size      int64      `json:"size"`
+	ModTime   time.Time  `json:"mod_time"`
+	BlockSize int        `json:"block_size"`
+	Blocks    []blockSum `json:"blocks"`
+}
+
+// blockLen returns the length of block k, which is BlockSize except for a
+// shorter last block.
+func (s *deltaSignature) blockLen(k int) int {
+	return int(min(int64(s.BlockSize), s.Size-int64(k)*int64(s.BlockSize)))
+}
+
+// rollingSum is the rsync weak checksum of a window of bytes.
+type rollingSum struct {
+	a, b uint32
+	n    uint32 // window length
+}
+
+func newRollingSum(p []byte) rollingSum {
+	s := rollingSum{n: uint32(len(p))}
+	for i, c := range p {
+		s.a += uint32(c)
+		s.b += (s.n - uint32(i)) * uint32(c)
+	}
+	return s
+}
+
+// roll moves the window one byte forward, dropping out and adding in.
+func (s *rollingSum) roll(out, in byte) {
+	s.a += uint32(in) - uint32(out)
+	s.b += s.a - s.n*uint32(out)
+}
+
+// shrink drops the first byte of the window, at the end of the data.
+func (s *rollingSum) shrink(out byte) {
+	s.a -= uint32(out)
+	s.b -= s.n * uint32(out)
+	s.n--
+}
+
+func (s rollingSum) sum() uint32 {
+	return (s.a & 0xffff) | (s.b << 16)
+}
+
+func sumBlock(p []byte) blockSum {
+	return blockSum{Weak: newRollingSum(p).sum(), Strong: sha256.Sum256(p)}
+}
+
+// deltaMinSize returns the smallest file size for a delta transfer.
+func (o Options) deltaMinSize() int64 {
+	if o.DeltaMinSize > 0 {
+		return o.DeltaMinSize
+	}
+	return DefaultDeltaMinSize
+}
+
+// deltaTarget returns the destination as a Patcher and its current info if
+// the copy of a source file of srcSize bytes to dstPath should be a delta
+// transfer.
+func deltaTarget(ctx context.Context, sctx *syncContext, dst omnistorage.Backend, dstPath string, srcSize int64) (omnistorage.Patcher, omnistorage.ObjectInfo, bool) {
+	if !sctx.opts.DeltaTransfer || sctx.opts.TransformReader != nil || srcSize < 0 {
+		return nil, nil, false
+	}
+	patcher, ok := dst.(omnistorage.Patcher)
+	if !ok {
+		return nil, nil, false
+	}
+	ext, ok := omnistorage.AsExtended(dst)
+	if !ok {
+		return nil, nil, false
+	}
+	info, err := ext.Stat(ctx, dstPath)
+	if err != nil || info.IsDir() || info.Size() < sctx.opts.deltaMinSize() {
+		return nil, nil, false
+	}
+	return patcher, info, true
+}
+
+// deltaCopy updates dstPath in place to match srcPath, and returns the
+// number of bytes that did not have to be written.
+//
+// The source is searched as rsync does: the weak checksum of a block-sized
+// window is rolled along it a byte at a time and looked up among the
+// destination's blocks, and a hit is confirmed with SHA-256. A block
+// matched at its own offset in the destination is not written. Writes are
+// made in source order, all before the window, so the destination data
+// under it is still the old data. A block found at another offset, as
+// after data was inserted or removed before it, is written with the
+// unmatched data, since in place it must still be moved to its new
+// offset; the search jumps over it, but only to the next block boundary,
+// where blocks that kept their offset match again. So appends and changes
+// within a file cost the blocks they touch, while data inserted in the
+// middle rewrites everything after it up to a removal that restores the
+// offsets.
+func deltaCopy(ctx context.Context, sctx *syncContext, src, dst omnistorage.Backend, patcher omnistorage.Patcher, dstInfo omnistorage.ObjectInfo, srcPath, dstPath string) (int64, error) {
+	sig, err := dstSignature(ctx, sctx, dst, dstInfo, dstPath)
+	if err != nil {
+		return 0, err
+	}
+	index := make(map[uint32][]int, len(sig.Blocks))
+	for k, b := range sig.Blocks {
+		index[b.Weak] = append(index[b.Weak], k)
+	}
+	blockSize := sig.BlockSize
+
+	r, err := src.NewReader(ctx, srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = r.Close() }()
+	br := bufio.NewReaderSize(&limitedReader{ctx: ctx, r: r, bucket: sctx.rateLimiter}, blockSize)
+
+	// The window is buf[start:end], at offset off in the source; pending
+	// holds the bytes before it that are still to be written, from
+	// pendingOff.
+	buf := make([]byte, 2*blockSize)
+	var start, end int
+	var off, pendingOff, saved int64
+	pending := make([]byte, 0, 2*blockSize)
+	newSig := &signatureWriter{sig: deltaSignature{BlockSize: blockSize}}
+
+	// fill tops the window up to a block
+	fill := func() (bool, error) {
+		end = copy(buf, buf[start:end])
+		start = 0
+		n, err := io.ReadFull(br, buf[end:blockSize])
+		end += n
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return true, nil
+		}
+		return false, err
+	}
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if err := patcher.WriteAt(ctx, dstPath, pending, pendingOff); err != nil {
+			return err
+		}
+		pendingOff += int64(len(pending))
+		pending = pending[:0]
+		return nil
+	}
+	// match returns whether the window matches a destination block, and
+	// whether that block is at the window's offset.
+	match := func(w []byte, weak uint32) (matched, inPlace bool) {
+		ks := index[weak]
+		if len(ks) == 0 {
+			return false, false
+		}
+		strong := sha256.Sum256(w)
+		for _, k := range ks {
+			if sig.blockLen(k) != len(w) || sig.Blocks[k].Strong != strong {
+				continue
+			}
+			matched = true
+			if int64(k)*int64(blockSize) == off {
+				return true, true
+			}
+		}
+		return matched, false
+	}
+
+	eof, err := fill()
+	if err != nil {
+		return 0, err
+	}
+	sum := newRollingSum(buf[start:end])
+	for start < end {
+		w := buf[start:end]
+		if matched, inPlace := match(w, sum.sum()); matched {
+			if inPlace {
+				if err := flush(); err != nil {
+					return saved, err
+				}
+				saved += int64(len(w))
+				pendingOff = off + int64(len(w))
+			} else if rem := int(off % int64(blockSize)); rem != 0 {
+				// Jump only to the next block boundary, where the
+				// window may match in place again
+				w = w[:min(len(w), blockSize-rem)]
+				pending = append(pending, w...)
+			} else {
+				pending = append(pending, w...)
+			}
+			newSig.Write(w)
+			off += int64(len(w))
+			start += len(w)
+			if len(pending) >= blockSize {
+				if err := flush(); err != nil {
+					return saved, err
+				}
+			}
+			if !eof {
+				if eof, err = fill(); err != nil {
+					return saved, err
+				}
+			}
+			sum = newRollingSum(buf[start:end])
+			continue
+		}
+
+		// Slide the window one byte, leaving its first byte unmatched
+		out := buf[start]
+		newSig.Write(buf[start : start+1])
+		pending = append(pending, out)
+		off++
+		start++
+		if !eof {
+			c, err := br.ReadByte()
+			switch {
+			case err == nil:
+				if end == len(buf) {
+					end = copy(buf, buf[start:end])
+					start = 0
+				}
+				buf[end] = c
+				end++
+				sum.roll(out, c)
+			case errors.Is(err, io.EOF):
+				eof = true
+				sum.shrink(out)
+			default:
+				return saved, err
+			}
+		} else {
+			sum.shrink(out)
+		}
+		if len(pending) >= blockSize {
+			if err := flush(); err != nil {
+				return saved, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return saved, err
+	}
+
+	if off < sig.Size {
+		if err := patcher.Truncate(ctx, dstPath, off); err != nil {
+			return saved, err
+		}
+	}
+
+	if sctx.opts.DeltaSignatures != nil {
+		newSig.close()
+		if ext, ok := omnistorage.AsExtended(dst); ok {
+			if info, err := ext.Stat(ctx, dstPath); err == nil {
+				newSig.sig.ModTime = info.ModTime()
+			}
+		}
+		// A signature that cannot be saved is recomputed next time
+		if err := saveSignature(ctx, sctx.opts.DeltaSignatures, dstPath, &newSig.sig); err != nil {
+			sctx.logger.Warn("failed to save delta signature",
+				"path", dstPath,
+				"error", err)
+		}
+	}
+	return saved, nil
+}
+
+// signatureWriter computes the signature of the data written to it.
+type signatureWriter struct {
+	sig   deltaSignature
+	block []byte
+}
+
+func (w *signatureWriter) Write(p []byte) {
+	w.sig.Size += int64(len(p))
+	for len(p) > 0 {
+		n := min(len(p), w.sig.BlockSize-len(w.block))
+		w.block = append(w.block, p[:n]...)
+		p = p[n:]
+		if len(w.block) == w.sig.BlockSize {
+			w.sig.Blocks = append(w.sig.Blocks, sumBlock(w.block))
+			w.block = w.block[:0]
+		}
+	}
+}
+
+// close adds the last, short block.
+func (w *signatureWriter) close() {
+	if len(w.block) > 0 {
+		w.sig.Blocks = append(w.sig.Blocks, sumBlock(w.block))
+		w.block = w.block[:0]
+	}
+}
+
+// limitedReader waits on a bandwidth limit for the bytes it reads.
+type limitedReader struct {
+	ctx    context.Context
+	r      io.Reader
+	bucket *tokenBucket
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if werr := l.bucket.WaitN(l.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// dstSignature returns the signature of the destination file, from
+// Options.DeltaSignatures if it holds one for the file's current size and
+// modification time, or by reading the file.
+func dstSignature(ctx context.Context, sctx *syncContext, dst omnistorage.Backend, info omnistorage.ObjectInfo, dstPath string) (*deltaSignature, error) {
+	if store := sctx.opts.DeltaSignatures; store != nil {
+		if sig, err := loadSignature(ctx, store, dstPath); err == nil &&
+			sig.BlockSize == deltaBlockSize && sig.Size == info.Size() && sig.ModTime.Equal(info.ModTime()) {
+			return sig, nil
+		}
+	}
+
+	r, err := dst.NewReader(ctx, dstPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	sig := &deltaSignature{BlockSize: deltaBlockSize}
+	buf := make([]byte, deltaBlockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			sig.Blocks = append(sig.Blocks, sumBlock(buf[:n]))
+			sig.Size += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return sig, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read destination signature: %w", err)
+		}
+	}
+}
+
+func loadSignature(ctx context.Context, store omnistorage.Backend, dstPath string) (*deltaSignature, error) {
+	r, err := store.NewReader(ctx, dstPath+deltaSignatureSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	var sig deltaSignature
+	if err := json.NewDecoder(r).Decode(&sig); err != nil {
+		return nil, err
+	}
+	return &sig, nil
+}
+
+func saveSignature(ctx context.Context, store omnistorage.Backend, dstPath string, sig *deltaSignature) error {
+	w, err := store.NewWriter(ctx, dstPath+deltaSignatureSuffix,
+		omnistorage.WithContentType("application/json"))
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(sig); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestSyncDeltaTransfer(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	sigs := memory.New()
+	opts := Options{DeltaTransfer: true, DeltaMinSize: 1, DeltaSignatures: sigs}
+
+	base := strings.Repeat("0123456789abcdef", 4*deltaBlockSize/16) // 4 blocks
+	writeFile(t, ctx, src, "log.txt", base)
+	writeFile(t, ctx, src, "small.txt", "x")
+	if _, err := Sync(ctx, src, dst, "", "", opts); err != nil {
+		t.Fatalf("initial Sync failed: %v", err)
+	}
+
+	// Appending writes only the tail
+	appended := base + "tail"
+	writeFile(t, ctx, src, "log.txt", appended)
+	result, err := Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Updated != 1 {
+		t.Errorf("Updated = %d, want 1", result.Updated)
+	}
+	if result.DeltaSaved != int64(len(base)) {
+		t.Errorf("DeltaSaved = %d, want %d", result.DeltaSaved, len(base))
+	}
+	if got := readBackend(t, dst, "log.txt"); got != appended {
+		t.Errorf("dst log.txt has %d bytes, want %d", len(got), len(appended))
+	}
+	if ok, _ := sigs.Exists(ctx, "log.txt"+deltaSignatureSuffix); !ok {
+		t.Error("signature of log.txt was not saved")
+	}
+
+	// A changed block and a shorter file
+	changed := []byte(base[:3*deltaBlockSize])
+	changed[deltaBlockSize+1] = 'X'
+	writeFile(t, ctx, src, "log.txt", string(changed))
+	result, err = Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.DeltaSaved != 2*deltaBlockSize {
+		t.Errorf("DeltaSaved = %d, want %d", result.DeltaSaved, 2*deltaBlockSize)
+	}
+	if got := readBackend(t, dst, "log.txt"); got != string(changed) {
+		t.Errorf("dst log.txt has %d bytes, want %d matching the source", len(got), len(changed))
+	}
+
+	// A stale signature is ignored
+	writeFile(t, ctx, dst, "log.txt", "stale")
+	result, err = Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.DeltaSaved != 0 {
+		t.Errorf("DeltaSaved = %d, want 0", result.DeltaSaved)
+	}
+	if got := readBackend(t, dst, "log.txt"); got != string(changed) {
+		t.Errorf("dst log.txt has %d bytes, want %d matching the source", len(got), len(changed))
+	}
+}
+
+func TestRollingSum(t *testing.T) {
+	data := make([]byte, 300)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	const n = 64
+	sum := newRollingSum(data[:n])
+	for i := 1; i+n <= len(data); i++ {
+		sum.roll(data[i-1], data[i+n-1])
+		if got, want := sum.sum(), newRollingSum(data[i:i+n]).sum(); got != want {
+			t.Fatalf("rolled sum at %d = %08x, want %08x", i, got, want)
+		}
+	}
+	for i := len(data) - n + 1; i < len(data); i++ {
+		sum.shrink(data[i-1])
+		if got, want := sum.sum(), newRollingSum(data[i:]).sum(); got != want {
+			t.Fatalf("shrunk sum at %d = %08x, want %08x", i, got, want)
+		}
+	}
+}
+
+func TestSyncDeltaTransferMovedBlocks(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	opts := Options{DeltaTransfer: true, DeltaMinSize: 1, Checksum: true}
+
+	base := make([]byte, 5*deltaBlockSize+1000)
+	rand.New(rand.NewSource(1)).Read(base)
+	writeFile(t, ctx, dst, "data.bin", string(base))
+
+	// Inserting 10 bytes in block 0 and removing 10 in block 3 moves
+	// blocks 1 and 2, which are rewritten, and restores the offsets of
+	// block 4 and the tail, which are not
+	var edited bytes.Buffer
+	edited.Write(base[:100])
+	edited.WriteString("0123456789")
+	edited.Write(base[100 : 3*deltaBlockSize+5])
+	edited.Write(base[3*deltaBlockSize+15:])
+	writeFile(t, ctx, src, "data.bin", edited.String())
+
+	result, err := Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if want := int64(deltaBlockSize + 1000); result.DeltaSaved != want {
+		t.Errorf("DeltaSaved = %d, want %d", result.DeltaSaved, want)
+	}
+	if got := readBackend(t, dst, "data.bin"); got != edited.String() {
+		t.Error("dst data.bin does not match the source")
+	}
+
+	// Removing data shifts everything after it
+	shorter := append(bytes.Clone(base[:1000]), base[2000:]...)
+	writeFile(t, ctx, dst, "data.bin", string(base))
+	writeFile(t, ctx, src, "data.bin", string(shorter))
+	if _, err := Sync(ctx, src, dst, "", "", opts); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if got := readBackend(t, dst, "data.bin"); got != string(shorter) {
+		t.Error("dst data.bin does not match the source after a removal")
+	}
+}
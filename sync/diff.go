@@ -0,0 +1,174 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/grokify/oscompat/tsync"
+)
+
+// DiffStatus represents the type of difference.
+type DiffStatus string
+
+const (
+	// DiffStatusNew indicates a file exists only in source.
+	DiffStatusNew DiffStatus = "new"
+
+	// DiffStatusModified indicates a file differs between source and destination.
+	DiffStatusModified DiffStatus = "modified"
+
+	// DiffStatusDeleted indicates a file exists only in destination.
+	DiffStatusDeleted DiffStatus = "deleted"
+)
+
+// DiffEntry represents a single difference between backends.
+type DiffEntry struct {
+	// Path is the source path of new and modified files, and the
+	// destination path of deleted files, relative to the Diff paths.
+	Path   string     `json:"path"`
+	Status DiffStatus `json:"status"`
+
+	// SrcSize and SrcModTime describe the source file, and DstSize and
+	// DstModTime the destination file. They are zero for the side the
+	// file is missing from, and modification times are zero if the
+	// backend does not report them.
+	SrcSize    int64     `json:"src_size"`
+	DstSize    int64     `json:"dst_size"`
+	SrcModTime time.Time `json:"src_mod_time,omitzero"`
+	DstModTime time.Time `json:"dst_mod_time,omitzero"`
+
+	// SizeDelta is SrcSize minus DstSize: the bytes the destination
+	// grows by when synced.
+	SizeDelta int64 `json:"size_delta"`
+
+	// ModTimeDelta is how much newer the source file is than the
+	// destination file, negative if it is older. It is zero unless both
+	// modification times are known.
+	ModTimeDelta time.Duration `json:"mod_time_delta,omitempty"`
+}
+
+// newDiffEntry returns the entry for p, from the source and destination
+// files, either of which is nil if the file is missing from that side.
+func newDiffEntry(status DiffStatus, p string, src, dst *FileInfo) DiffEntry {
+	e := DiffEntry{Path: p, Status: status}
+	if src != nil {
+		e.SrcSize, e.SrcModTime = src.Size, src.ModTime
+	}
+	if dst != nil {
+		e.DstSize, e.DstModTime = dst.Size, dst.ModTime
+	}
+	e.SizeDelta = e.SrcSize - e.DstSize
+	if !e.SrcModTime.IsZero() && !e.DstModTime.IsZero() {
+		e.ModTimeDelta = e.SrcModTime.Sub(e.DstModTime)
+	}
+	return e
+}
+
+// Itemize returns the change as an rsync --itemize-changes code: ">f" and
+// "+++++++++" for a new file, ">f" and flags for a modified one, where "s"
+// means the size differs, "t" the modification time and "c" neither, so
+// the content does, and "*deleting" for a deleted one.
+func (e DiffEntry) Itemize() string {
+	switch e.Status {
+	case DiffStatusNew:
+		return ">f+++++++++"
+	case DiffStatusDeleted:
+		return "*deleting  "
+	}
+	flags := []byte(">f.........")
+	if e.SizeDelta != 0 {
+		flags[3] = 's'
+	}
+	if e.timeDiffers() {
+		flags[4] = 't'
+	}
+	if e.SizeDelta == 0 && !e.timeDiffers() {
+		flags[2] = 'c'
+	}
+	return string(flags)
+}
+
+// timeDiffers reports whether the modification times differ by more than
+// the filesystem timestamp precision tolerance.
+func (e DiffEntry) timeDiffers() bool {
+	return e.ModTimeDelta != 0 && !tsync.Equal(e.SrcModTime, e.DstModTime)
+}
+
+// DiffEntries is the list of differences Diff returns.
+type DiffEntries []DiffEntry
+
+// WriteText writes one line per entry, with its rsync itemize code, path
+// and the change in size and modification time, then a summary line:
+//
+//	>f+++++++++ reports/new.csv  1.2 KiB
+//	>f.st...... config.yaml  310 B -> 412 B (+102 B), mtime +2h0m0s
+//	*deleting   old.log  4.0 KiB
+//	3 differences: 1 new, 1 modified, 1 deleted
+func (d DiffEntries) WriteText(w io.Writer) error {
+	counts := map[DiffStatus]int{}
+	for _, e := range d {
+		counts[e.Status]++
+		var detail string
+		switch e.Status {
+		case DiffStatusNew:
+			detail = formatBytes(e.SrcSize)
+		case DiffStatusDeleted:
+			detail = formatBytes(e.DstSize)
+		default:
+			var parts []string
+			if e.SizeDelta != 0 {
+				parts = append(parts, fmt.Sprintf("%s -> %s (%s)", formatBytes(e.DstSize), formatBytes(e.SrcSize), formatDelta(e.SizeDelta)))
+			}
+			if e.timeDiffers() {
+				delta := e.ModTimeDelta.Round(time.Second).String()
+				if e.ModTimeDelta > 0 {
+					delta = "+" + delta
+				}
+				parts = append(parts, "mtime "+delta)
+			}
+			if len(parts) == 0 {
+				parts = append(parts, "content differs")
+			}
+			detail = strings.Join(parts, ", ")
+		}
+		if _, err := fmt.Fprintf(w, "%s %s  %s\n", e.Itemize(), e.Path, detail); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d differences: %d new, %d modified, %d deleted\n",
+		len(d), counts[DiffStatusNew], counts[DiffStatusModified], counts[DiffStatusDeleted])
+	return err
+}
+
+// WriteJSON writes the entries as an indented JSON array.
+func (d DiffEntries) WriteJSON(w io.Writer) error {
+	if d == nil {
+		d = DiffEntries{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteNDJSON writes the entries as newline-delimited JSON, one object
+// per line, for streaming into log pipelines.
+func (d DiffEntries) WriteNDJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, e := range d {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatDelta formats a size change with its sign, such as +1.5 KiB.
+func formatDelta(n int64) string {
+	if n < 0 {
+		return "-" + formatBytes(-n)
+	}
+	return "+" + formatBytes(n)
+}
@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestDiffSizes(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "new.txt", "new")
+	writeFile(t, ctx, src, "modified.txt", "short")
+	writeFile(t, ctx, dst, "modified.txt", "much longer old content")
+	writeFile(t, ctx, dst, "deleted.txt", "deleted")
+
+	entries, err := Diff(ctx, src, dst, "", "", Options{SizeOnly: true})
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("entries = %+v, want 3", entries)
+	}
+	mod := entries[1]
+	if mod.Status != DiffStatusModified || mod.SrcSize != 5 || mod.DstSize != 23 || mod.SizeDelta != -18 {
+		t.Errorf("modified entry = %+v", mod)
+	}
+	if mod.SrcModTime.IsZero() || mod.DstModTime.IsZero() {
+		t.Errorf("modified entry has no mod times: %+v", mod)
+	}
+	if del := entries[2]; del.Status != DiffStatusDeleted || del.DstSize != 7 || del.SizeDelta != -7 {
+		t.Errorf("deleted entry = %+v", del)
+	}
+}
+
+func TestDiffEntriesWrite(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := DiffEntries{
+		newDiffEntry(DiffStatusNew, "reports/new.csv", &FileInfo{Size: 1200, ModTime: now}, nil),
+		newDiffEntry(DiffStatusModified, "config.yaml",
+			&FileInfo{Size: 412, ModTime: now}, &FileInfo{Size: 310, ModTime: now.Add(-2 * time.Hour)}),
+		newDiffEntry(DiffStatusModified, "same-size.bin",
+			&FileInfo{Size: 10, ModTime: now}, &FileInfo{Size: 10, ModTime: now}),
+		newDiffEntry(DiffStatusDeleted, "old.log", nil, &FileInfo{Size: 4096}),
+	}
+
+	var text bytes.Buffer
+	if err := entries.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	want := `>f+++++++++ reports/new.csv  1.2 KiB
+>f.st...... config.yaml  310 B -> 412 B (+102 B), mtime +2h0m0s
+>fc........ same-size.bin  content differs
+*deleting   old.log  4.0 KiB
+4 differences: 1 new, 2 modified, 1 deleted
+`
+	if text.String() != want {
+		t.Errorf("WriteText =\n%s\nwant\n%s", text.String(), want)
+	}
+
+	var js bytes.Buffer
+	if err := entries.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []DiffEntry
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || len(decoded) != 4 {
+		t.Fatalf("WriteJSON = %s, %v", js.String(), err)
+	}
+	if decoded[1].SizeDelta != 102 || decoded[1].ModTimeDelta != 2*time.Hour {
+		t.Errorf("decoded modified entry = %+v", decoded[1])
+	}
+	if !strings.Contains(js.String(), `"size_delta": 102`) {
+		t.Errorf("WriteJSON missing size_delta:\n%s", js.String())
+	}
+
+	var nd bytes.Buffer
+	if err := entries.WriteNDJSON(&nd); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(&nd)
+	lines := 0
+	for scanner.Scan() {
+		var e DiffEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %d: %v", lines, err)
+		}
+		if e.Path != entries[lines].Path {
+			t.Errorf("line %d path = %q, want %q", lines, e.Path, entries[lines].Path)
+		}
+		lines++
+	}
+	if lines != 4 {
+		t.Errorf("WriteNDJSON lines = %d, want 4", lines)
+	}
+
+	// An empty diff is an empty array, not null
+	var empty bytes.Buffer
+	if err := DiffEntries(nil).WriteJSON(&empty); err != nil || strings.TrimSpace(empty.String()) != "[]" {
+		t.Errorf("empty WriteJSON = %q, %v", empty.String(), err)
+	}
+}
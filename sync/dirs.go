@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"path"
+
+	"github.com/grokify/omnistorage"
+)
+
+// planDirs returns an ActionMkdir for each directory that is empty in the
+// source and missing from the destination. It returns no actions if the
+// source cannot list directories or the destination cannot create them.
+func planDirs(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, srcFiles, dstFiles []FileInfo, opts Options) ([]Action, error) {
+	logger := opts.logger()
+	lister, ok := src.(omnistorage.DirLister)
+	if !ok {
+		logger.Debug("source cannot list directories, not creating empty directories")
+		return nil, nil
+	}
+	if ext, ok := omnistorage.AsExtended(dst); !ok || !ext.Features().Mkdir {
+		logger.Debug("destination does not support mkdir, not creating empty directories")
+		return nil, nil
+	}
+
+	srcDirs, err := lister.ListDirs(ctx, srcPath)
+	if err != nil {
+		logger.Error("failed to list source directories", slog.String("path", srcPath), slog.Any("error", err))
+		return nil, err
+	}
+
+	// A directory is not empty if anything is listed inside it
+	occupied := make(map[string]bool)
+	for _, f := range srcFiles {
+		markParents(occupied, f.Path)
+	}
+	for _, d := range srcDirs {
+		markParents(occupied, d)
+	}
+
+	// Directories holding destination files exist already
+	existing := make(map[string]bool)
+	for _, f := range dstFiles {
+		markParents(existing, opts.pathKey(f.Path))
+	}
+	if lister, ok := dst.(omnistorage.DirLister); ok {
+		dstDirs, err := lister.ListDirs(ctx, dstPath)
+		if err != nil {
+			logger.Error("failed to list destination directories", slog.String("path", dstPath), slog.Any("error", err))
+			return nil, err
+		}
+		for _, d := range dstDirs {
+			existing[opts.pathKey(d)] = true
+		}
+	}
+
+	var actions []Action
+	for _, d := range srcDirs {
+		if occupied[d] {
+			continue
+		}
+		name, err := opts.destName(d)
+		if err != nil {
+			return nil, err
+		}
+		if name != "" && !existing[opts.pathKey(name)] {
+			actions = append(actions, newAction(ActionMkdir, FileInfo{Path: d, IsDir: true}, name))
+		}
+	}
+	return actions, nil
+}
+
+// markParents marks every parent directory of p.
+func markParents(set map[string]bool, p string) {
+	for dir := path.Dir(p); dir != "." && dir != "/" && !set[dir]; dir = path.Dir(dir) {
+		set[dir] = true
+	}
+}
+
+// makeDirs creates the directories of ActionMkdir actions in dst. Errors
+// are added to result, up to Options.MaxErrors.
+func makeDirs(ctx context.Context, dst omnistorage.Backend, dstPath string, actions []Action, opts Options, result *Result) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	ext, hasExt := omnistorage.AsExtended(dst)
+
+	for _, a := range actions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !opts.DryRun {
+			var err error
+			if hasExt {
+				err = ext.Mkdir(ctx, path.Join(dstPath, a.destPath()))
+			} else {
+				err = omnistorage.ErrNotSupported
+			}
+			if err != nil {
+				fe := FileError{Path: a.File.Path, Op: "mkdir", Err: err}
+				decision := opts.Hooks.onError(ctx, a.File, fe)
+				if decision == Skip {
+					continue
+				}
+				result.Errors = append(result.Errors, fe)
+				if decision == Abort {
+					return abortError(fe.Op, fe.Path)
+				}
+				if opts.MaxErrors > 0 && len(result.Errors) >= opts.MaxErrors {
+					return nil
+				}
+				continue
+			}
+		}
+		result.DirsCreated++
+	}
+	return nil
+}
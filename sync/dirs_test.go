@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/file"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestSyncCreateEmptyDirs(t *testing.T) {
+	ctx := context.Background()
+	srcRoot, dstRoot := t.TempDir(), t.TempDir()
+	for _, d := range []string{"empty", "nested/a/b", "full"} {
+		if err := os.MkdirAll(filepath.Join(srcRoot, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(srcRoot, "full", "file.txt"), []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := file.DefaultConfig()
+	cfg.Root = srcRoot
+	src := file.New(cfg)
+	cfg.Root = dstRoot
+	dst := file.New(cfg)
+
+	// Without the option only files are copied
+	result, err := Sync(ctx, src, dst, "", "", Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.DirsCreated != 0 {
+		t.Errorf("DirsCreated = %d, want 0 without CreateEmptyDirs", result.DirsCreated)
+	}
+
+	plan, err := Plan(ctx, src, dst, "", "", Options{CreateEmptyDirs: true})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if got := plan.Count(ActionMkdir); got != 2 {
+		t.Errorf("Count(ActionMkdir) = %d, want 2", got)
+	}
+	if plan.Actions[0].Type != ActionMkdir {
+		t.Errorf("first action = %s, want directories first", plan.Actions[0].Type)
+	}
+
+	result, err = Sync(ctx, src, dst, "", "", Options{CreateEmptyDirs: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.DirsCreated != 2 || result.Copied != 1 {
+		t.Errorf("DirsCreated = %d, Copied = %d, want 2 and 1", result.DirsCreated, result.Copied)
+	}
+	for _, d := range []string{"empty", "nested/a/b", "full"} {
+		if fi, err := os.Stat(filepath.Join(dstRoot, d)); err != nil || !fi.IsDir() {
+			t.Errorf("directory %s missing from destination: %v", d, err)
+		}
+	}
+
+	// A second run has nothing to do
+	result, err = Sync(ctx, src, dst, "", "", Options{CreateEmptyDirs: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.DirsCreated != 0 || result.Copied != 0 {
+		t.Errorf("DirsCreated = %d, Copied = %d on second run, want 0", result.DirsCreated, result.Copied)
+	}
+}
+
+func TestSyncCreateEmptyDirsWithPrefix(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	if err := src.Mkdir(ctx, "data/empty"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ctx, src, "data/file.txt", "content")
+
+	result, err := Sync(ctx, src, dst, "data", "backup", Options{CreateEmptyDirs: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.DirsCreated != 1 {
+		t.Errorf("DirsCreated = %d, want 1", result.DirsCreated)
+	}
+	info, err := dst.Stat(ctx, "backup/empty")
+	if err != nil || !info.IsDir() {
+		t.Errorf("backup/empty not created: %v", err)
+	}
+}
+
+func TestSyncSkipEmptyFiles(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "empty.txt", "")
+	writeFile(t, ctx, src, "full.txt", "content")
+	writeFile(t, ctx, dst, "placeholder", "")
+
+	result, err := Sync(ctx, src, dst, "", "", Options{SkipEmptyFiles: true, DeleteExtra: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 1 || result.Deleted != 0 {
+		t.Errorf("Copied = %d, Deleted = %d, want 1 and 0", result.Copied, result.Deleted)
+	}
+	if exists, _ := dst.Exists(ctx, "empty.txt"); exists {
+		t.Error("empty.txt was copied")
+	}
+	if exists, _ := dst.Exists(ctx, "placeholder"); !exists {
+		t.Error("placeholder was deleted")
+	}
+}
@@ -0,0 +1,42 @@
+package sync
+
+import (
+	"context"
+	"io"
+
+	"github.com/grokify/omnistorage"
+)
+
+// downloadSource returns the backend to read the source file with info
+// from: src, or src read in parallel ranged chunks if
+// ParallelDownloadCutoff applies to the file.
+func (o Options) downloadSource(src omnistorage.Backend, info omnistorage.ObjectInfo) omnistorage.Backend {
+	if o.ParallelDownloadCutoff <= 0 || info == nil || info.Size() < o.ParallelDownloadCutoff {
+		return src
+	}
+	if _, ok := src.(compressedSource); ok {
+		return src
+	}
+	ext, ok := omnistorage.AsExtended(src)
+	if !ok || !ext.Features().RangeRead {
+		return src
+	}
+	return parallelSource{Backend: src, size: info.Size(), opts: o.ParallelDownload}
+}
+
+// parallelSource reads a file of known size from a backend in concurrent
+// ranged chunks.
+type parallelSource struct {
+	omnistorage.Backend
+	size int64
+	opts omnistorage.ParallelReadOptions
+}
+
+// NewReader reads path in parallel, or as usual if a byte range is
+// requested.
+func (s parallelSource) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if len(opts) > 0 {
+		return s.Backend.NewReader(ctx, p, opts...)
+	}
+	return omnistorage.NewParallelReader(ctx, s.Backend, p, s.size, s.opts), nil
+}
@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// rangeSource counts the ranged reads of a memory backend.
+type rangeSource struct {
+	*memory.Backend
+	ranged atomic.Int32
+}
+
+func (s *rangeSource) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if len(opts) > 0 {
+		s.ranged.Add(1)
+	}
+	return s.Backend.NewReader(ctx, p, opts...)
+}
+
+func TestSyncParallelDownload(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	src := &rangeSource{Backend: mem}
+	dst := memory.New()
+
+	big := strings.Repeat("0123456789", 1000)
+	writeFile(t, ctx, mem, "big.bin", big)
+	writeFile(t, ctx, mem, "small.txt", "hello")
+
+	result, err := Sync(ctx, src, dst, "", "", Options{
+		ParallelDownloadCutoff: 5000,
+		ParallelDownload:       omnistorage.ParallelReadOptions{ChunkSize: 2048, Concurrency: 2},
+	})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 2 {
+		t.Errorf("Copied = %d, want 2", result.Copied)
+	}
+	if n := src.ranged.Load(); n != 5 {
+		t.Errorf("ranged reads = %d, want 5 (big.bin only)", n)
+	}
+	if got := readBackend(t, dst, "big.bin"); got != big {
+		t.Errorf("big.bin has %d bytes, want %d", len(got), len(big))
+	}
+	if got := readBackend(t, dst, "small.txt"); got != "hello" {
+		t.Errorf("small.txt = %q, want %q", got, "hello")
+	}
+}
@@ -0,0 +1,212 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/sync/filter"
+)
+
+// ExpireReason is the rule of an ExpirePolicy that expired a file.
+type ExpireReason string
+
+const (
+	// ExpiredAge means the file was older than MaxAge.
+	ExpiredAge ExpireReason = "age"
+
+	// ExpiredVersions means newer versions of the file exceeded
+	// KeepVersions.
+	ExpiredVersions ExpireReason = "versions"
+
+	// ExpiredSize means the files under the prefix exceeded MaxBytes.
+	ExpiredSize ExpireReason = "size"
+)
+
+// ExpirePolicy configures Expire. Each rule that is set expires files on
+// its own; a file expired by one rule is not counted by the next. Rules
+// apply in the order MaxAge, KeepVersions, MaxBytes.
+type ExpirePolicy struct {
+	// MaxAge expires files last modified more than MaxAge ago.
+	// 0 disables the rule.
+	MaxAge time.Duration
+
+	// KeepVersions keeps the KeepVersions most recently modified files of
+	// each version series and expires the rest. 0 disables the rule.
+	KeepVersions int
+
+	// VersionKey maps a file path, relative to the Expire prefix, to its
+	// version series. Default: the directory of the path, so each
+	// directory is one series, as for dated backups such as
+	// db/2024-01-01.sql.
+	VersionKey func(path string) string
+
+	// MaxBytes expires the least recently modified files until the files
+	// left total at most MaxBytes. 0 disables the rule.
+	MaxBytes int64
+
+	// Archive, if set, receives expired files instead of them being
+	// deleted. It may be the backend being expired.
+	Archive omnistorage.Backend
+
+	// ArchivePrefix is prepended to the relative path of each archived
+	// file. With Archive set to the expired backend, it must lie outside
+	// the Expire prefix or archived files expire again on the next run.
+	ArchivePrefix string
+
+	// Filter limits the files considered.
+	Filter *filter.Filter
+
+	// Now is the time ages are measured from. Default: time.Now().
+	Now time.Time
+
+	// DryRun reports what would be expired without making changes.
+	DryRun bool
+}
+
+// ExpiredFile is a file expired by Expire.
+type ExpiredFile struct {
+	FileInfo
+
+	// Reason is the rule that expired the file.
+	Reason ExpireReason
+}
+
+// ExpireResult contains the results of Expire.
+type ExpireResult struct {
+	// Expired lists the files expired, or that would be in a dry run,
+	// oldest first. Paths are relative to the Expire prefix.
+	Expired []ExpiredFile
+
+	// Kept is the number of files left in place.
+	Kept int
+
+	// Removed is the number of files deleted or archived.
+	Removed int
+
+	// BytesReclaimed is the total size of the removed files.
+	BytesReclaimed int64
+
+	// Errors contains any errors that occurred.
+	Errors []FileError
+
+	// DryRun indicates if this was a dry run.
+	DryRun bool
+}
+
+// Expire deletes, or moves to policy.Archive, the files under prefix that
+// policy expires, as a client-side lifecycle rule for backends without
+// native ones, such as SFTP and the file backend.
+//
+// File ages and sizes come from Stat, so backend must implement
+// omnistorage.ExtendedBackend.
+func Expire(ctx context.Context, backend omnistorage.Backend, prefix string, policy ExpirePolicy) (*ExpireResult, error) {
+	if _, ok := omnistorage.AsExtended(backend); !ok {
+		return nil, fmt.Errorf("expire: %w", omnistorage.ErrNotSupported)
+	}
+	if policy.MaxAge < 0 || policy.KeepVersions < 0 || policy.MaxBytes < 0 {
+		return nil, fmt.Errorf("expire: negative policy limit")
+	}
+	if policy.VersionKey == nil {
+		policy.VersionKey = path.Dir
+	}
+	if policy.Now.IsZero() {
+		policy.Now = time.Now()
+	}
+
+	files, err := listFiles(ctx, backend, prefix, Options{Filter: policy.Filter})
+	if err != nil {
+		return nil, err
+	}
+	files = slices.DeleteFunc(files, func(f FileInfo) bool { return f.IsDir })
+
+	// Newest first, so each rule keeps a prefix of the files
+	slices.SortStableFunc(files, func(a, b FileInfo) int {
+		if c := b.ModTime.Compare(a.ModTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	result := &ExpireResult{DryRun: policy.DryRun}
+	var kept []FileInfo
+	expire := func(f FileInfo, reason ExpireReason) {
+		result.Expired = append(result.Expired, ExpiredFile{FileInfo: f, Reason: reason})
+	}
+
+	if policy.MaxAge > 0 {
+		cutoff := policy.Now.Add(-policy.MaxAge)
+		for _, f := range files {
+			if f.ModTime.Before(cutoff) {
+				expire(f, ExpiredAge)
+			} else {
+				kept = append(kept, f)
+			}
+		}
+		files, kept = kept, nil
+	}
+
+	if policy.KeepVersions > 0 {
+		versions := make(map[string]int)
+		for _, f := range files {
+			key := policy.VersionKey(f.Path)
+			versions[key]++
+			if versions[key] > policy.KeepVersions {
+				expire(f, ExpiredVersions)
+			} else {
+				kept = append(kept, f)
+			}
+		}
+		files, kept = kept, nil
+	}
+
+	if policy.MaxBytes > 0 {
+		var total int64
+		for _, f := range files {
+			if total+f.Size > policy.MaxBytes {
+				expire(f, ExpiredSize)
+				continue
+			}
+			total += f.Size
+			kept = append(kept, f)
+		}
+		files = kept
+	}
+	result.Kept = len(files)
+
+	slices.SortStableFunc(result.Expired, func(a, b ExpiredFile) int {
+		if c := a.ModTime.Compare(b.ModTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	for _, f := range result.Expired {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if !policy.DryRun {
+			if err := policy.remove(ctx, backend, prefix, f.Path); err != nil {
+				result.Errors = append(result.Errors, FileError{Path: f.Path, Op: "expire", Err: err})
+				continue
+			}
+		}
+		result.Removed++
+		result.BytesReclaimed += f.Size
+	}
+
+	return result, nil
+}
+
+// remove deletes or archives the expired file rel, relative to prefix.
+func (p ExpirePolicy) remove(ctx context.Context, backend omnistorage.Backend, prefix, rel string) error {
+	full := path.Join(prefix, rel)
+	if p.Archive == nil {
+		return deleteUnlocked(ctx, backend, backend, full)
+	}
+	return MoveFile(ctx, backend, p.Archive, full, path.Join(p.ArchivePrefix, rel))
+}
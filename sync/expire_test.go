@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// writeBackups writes three backups of db and one log, oldest first, and
+// returns the time after the first backup.
+func writeBackups(t *testing.T, ctx context.Context, backend *memory.Backend) time.Time {
+	t.Helper()
+	writeFile(t, ctx, backend, "bk/db/1.sql", "one")
+	time.Sleep(10 * time.Millisecond)
+	mark := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	writeFile(t, ctx, backend, "bk/db/2.sql", "two")
+	time.Sleep(10 * time.Millisecond)
+	writeFile(t, ctx, backend, "bk/db/3.sql", "three")
+	writeFile(t, ctx, backend, "bk/app.log", "log")
+	return mark
+}
+
+func expiredPaths(r *ExpireResult) []string {
+	var paths []string
+	for _, f := range r.Expired {
+		paths = append(paths, f.Path+":"+string(f.Reason))
+	}
+	return paths
+}
+
+func TestExpire(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	mark := writeBackups(t, ctx, backend)
+
+	result, err := Expire(ctx, backend, "bk", ExpirePolicy{
+		MaxAge:       time.Since(mark),
+		KeepVersions: 1,
+	})
+	if err != nil {
+		t.Fatalf("Expire error = %v", err)
+	}
+	got := expiredPaths(result)
+	want := []string{"db/1.sql:age", "db/2.sql:versions"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expired = %v, want %v", got, want)
+	}
+	if result.Removed != 2 || result.Kept != 2 || result.BytesReclaimed != 6 {
+		t.Errorf("Removed = %d, Kept = %d, BytesReclaimed = %d; want 2, 2, 6", result.Removed, result.Kept, result.BytesReclaimed)
+	}
+	for p, want := range map[string]bool{"bk/db/1.sql": false, "bk/db/2.sql": false, "bk/db/3.sql": true, "bk/app.log": true} {
+		if exists, _ := backend.Exists(ctx, p); exists != want {
+			t.Errorf("Exists(%s) = %v, want %v", p, exists, want)
+		}
+	}
+}
+
+func TestExpireSizeDryRun(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeBackups(t, ctx, backend)
+
+	// The newest files fit: app.log and 3.sql are 8 bytes
+	result, err := Expire(ctx, backend, "bk", ExpirePolicy{MaxBytes: 9, DryRun: true})
+	if err != nil {
+		t.Fatalf("Expire error = %v", err)
+	}
+	if got := expiredPaths(result); len(got) != 2 || got[0] != "db/1.sql:size" || got[1] != "db/2.sql:size" {
+		t.Errorf("Expired = %v, want db/1.sql and db/2.sql by size", got)
+	}
+	if !result.DryRun || result.Removed != 2 {
+		t.Errorf("DryRun = %v, Removed = %d; want true, 2", result.DryRun, result.Removed)
+	}
+	if exists, _ := backend.Exists(ctx, "bk/db/1.sql"); !exists {
+		t.Error("dry run deleted bk/db/1.sql")
+	}
+}
+
+func TestExpireArchive(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	archive := memory.New()
+	writeBackups(t, ctx, backend)
+
+	result, err := Expire(ctx, backend, "bk", ExpirePolicy{
+		KeepVersions:  2,
+		Archive:       archive,
+		ArchivePrefix: "cold",
+	})
+	if err != nil || result.Removed != 1 {
+		t.Fatalf("Expire = %+v, %v; want 1 removed", result, err)
+	}
+	if exists, _ := backend.Exists(ctx, "bk/db/1.sql"); exists {
+		t.Error("bk/db/1.sql was not moved")
+	}
+	if exists, _ := archive.Exists(ctx, "cold/db/1.sql"); !exists {
+		t.Error("cold/db/1.sql was not archived")
+	}
+}
+
+func TestExpireNotExtended(t *testing.T) {
+	_, err := Expire(context.Background(), basicBackend{memory.New()}, "", ExpirePolicy{MaxAge: time.Hour})
+	if !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Expire error = %v, want ErrNotSupported", err)
+	}
+}
+
+// basicBackend hides the extended operations of a backend.
+type basicBackend struct {
+	omnistorage.Backend
+}
@@ -0,0 +1,137 @@
+package filter
+
+import (
+	"slices"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Matcher decides whether a file passes. *Filter is a Matcher, so filters
+// built from rules or rule files compose with custom predicates through
+// And, Or and Not, and back into a Filter with Where:
+//
+//	images := filter.New(filter.IncludeContentType("image/*"))
+//	small := filter.New(filter.MaxSize(10 * filter.MB))
+//	f := filter.New(
+//	    filter.Exclude("*.tmp"),
+//	    filter.Where(filter.Or(filter.And(images, small), filter.Not(images))),
+//	)
+type Matcher interface {
+	// Match returns true if the file passes.
+	Match(fi FileInfo) bool
+}
+
+// MatcherFunc adapts a function to a Matcher. The function sees the
+// metadata, tags and hashes of a file only if other rules of the filter
+// need them; a predicate that needs them is written as a Filter instead.
+type MatcherFunc func(fi FileInfo) bool
+
+// Match calls fn(fi).
+func (fn MatcherFunc) Match(fi FileInfo) bool {
+	return fn(fi)
+}
+
+// And returns a Matcher passing files that pass all of ms. With no
+// matchers, it passes every file.
+func And(ms ...Matcher) Matcher {
+	return and(ms)
+}
+
+// Or returns a Matcher passing files that pass any of ms. With no
+// matchers, it passes no file.
+func Or(ms ...Matcher) Matcher {
+	return or(ms)
+}
+
+// Not returns a Matcher passing files that m does not.
+func Not(m Matcher) Matcher {
+	return not{m}
+}
+
+// Where adds a rule that files must pass m, such as a combination of
+// filters or a MatcherFunc. It is checked with the other constraints, after
+// include rules; the metadata, tags and hashes the filters in m need are
+// fetched as for the filter's own rules, and the rule is skipped until
+// they are set.
+func Where(m Matcher) Option {
+	return func(f *Filter) {
+		f.rules = append(f.rules, rule{
+			ruleType: ruleWhere,
+			matcher:  m,
+		})
+	}
+}
+
+type and []Matcher
+
+func (ms and) Match(fi FileInfo) bool {
+	for _, m := range ms {
+		if !m.Match(fi) {
+			return false
+		}
+	}
+	return true
+}
+
+type or []Matcher
+
+func (ms or) Match(fi FileInfo) bool {
+	for _, m := range ms {
+		if m.Match(fi) {
+			return true
+		}
+	}
+	return false
+}
+
+type not struct{ m Matcher }
+
+func (n not) Match(fi FileInfo) bool {
+	return !n.m.Match(fi)
+}
+
+// needs are the file attributes a Matcher needs beyond those that are
+// always set.
+type needs struct {
+	metadata bool
+	tags     bool
+	hashes   []omnistorage.HashType
+}
+
+// add adds the needs of o to n.
+func (n *needs) add(o needs) {
+	n.metadata = n.metadata || o.metadata
+	n.tags = n.tags || o.tags
+	for _, t := range o.hashes {
+		if !slices.Contains(n.hashes, t) {
+			n.hashes = append(n.hashes, t)
+		}
+	}
+}
+
+// missing reports whether fi lacks attributes of n.
+func (n needs) missing(fi FileInfo) bool {
+	return (n.metadata && fi.Metadata == nil) ||
+		(n.tags && fi.Tags == nil) ||
+		(len(n.hashes) > 0 && fi.Hashes == nil)
+}
+
+// needsOf returns the needs of m: those of the filters it is built from.
+func needsOf(m Matcher) needs {
+	var n needs
+	switch m := m.(type) {
+	case *Filter:
+		n = m.needs()
+	case and:
+		for _, c := range m {
+			n.add(needsOf(c))
+		}
+	case or:
+		for _, c := range m {
+			n.add(needsOf(c))
+		}
+	case not:
+		n = needsOf(m.m)
+	}
+	return n
+}
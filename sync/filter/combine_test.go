@@ -0,0 +1,85 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+)
+
+func TestCombinators(t *testing.T) {
+	json := New(Include("*.json"))
+	small := New(MaxSize(100))
+	underData := MatcherFunc(func(fi FileInfo) bool { return strings.HasPrefix(fi.Path, "data/") })
+
+	tests := []struct {
+		name string
+		m    Matcher
+		fi   FileInfo
+		want bool
+	}{
+		{"and both", And(json, small), FileInfo{Path: "a.json", Size: 10}, true},
+		{"and one", And(json, small), FileInfo{Path: "a.json", Size: 1000}, false},
+		{"and none", And(), FileInfo{Path: "a.txt"}, true},
+		{"or one", Or(json, small), FileInfo{Path: "a.txt", Size: 10}, true},
+		{"or neither", Or(json, small), FileInfo{Path: "a.txt", Size: 1000}, false},
+		{"or none", Or(), FileInfo{Path: "a.json"}, false},
+		{"not", Not(json), FileInfo{Path: "a.json"}, false},
+		{"func", Or(underData, Not(small)), FileInfo{Path: "data/a.txt", Size: 10}, true},
+		{"nested", And(Or(json, underData), Not(small)), FileInfo{Path: "data/a.txt", Size: 1000}, true},
+	}
+
+	for _, tc := range tests {
+		if got := tc.m.Match(tc.fi); got != tc.want {
+			t.Errorf("%s: Match(%+v) = %v, want %v", tc.name, tc.fi, got, tc.want)
+		}
+	}
+}
+
+func TestWhere(t *testing.T) {
+	// JSON files, except large ones unless tagged keep=true.
+	keep := New(IncludeTag("keep", "true"))
+	f := New(
+		Include("*.json"),
+		Where(Or(New(MaxSize(100)), keep)),
+	)
+
+	if !f.NeedsTags() || f.NeedsMetadata() {
+		t.Errorf("NeedsTags() = %v, NeedsMetadata() = %v, want true, false", f.NeedsTags(), f.NeedsMetadata())
+	}
+
+	tests := []struct {
+		fi   FileInfo
+		want bool
+	}{
+		{FileInfo{Path: "a.txt", Tags: map[string]string{}}, false},
+		{FileInfo{Path: "a.json", Size: 10, Tags: map[string]string{}}, true},
+		{FileInfo{Path: "a.json", Size: 1000, Tags: map[string]string{}}, false},
+		{FileInfo{Path: "a.json", Size: 1000, Tags: map[string]string{"keep": "true"}}, true},
+		// Tags not fetched yet: the Where rule is skipped.
+		{FileInfo{Path: "a.json", Size: 1000}, true},
+	}
+
+	for _, tc := range tests {
+		if got := f.Match(tc.fi); got != tc.want {
+			t.Errorf("Match(%+v) = %v, want %v", tc.fi, got, tc.want)
+		}
+	}
+}
+
+func TestWhereNeeds(t *testing.T) {
+	f := New(Where(Not(And(
+		New(ExcludeMetadata("owner", "test")),
+		New(ExcludeHashes(omnistorage.HashSHA256, "aaa"), ExcludeHashes(omnistorage.HashMD5, "bbb")),
+	))))
+
+	if !f.NeedsMetadata() || f.NeedsTags() {
+		t.Errorf("NeedsMetadata() = %v, NeedsTags() = %v, want true, false", f.NeedsMetadata(), f.NeedsTags())
+	}
+	if types := f.NeedsHashes(); len(types) != 2 {
+		t.Errorf("NeedsHashes() = %v, want sha256 and md5", types)
+	}
+	if New(Where(MatcherFunc(func(FileInfo) bool { return true }))).NeedsTags() {
+		t.Error("a MatcherFunc should need nothing")
+	}
+}
@@ -1,7 +1,7 @@
 // Package filter provides file filtering for sync operations.
 //
 // Filters are used to include or exclude files based on patterns,
-// size, and age. They are inspired by rclone's filtering system.
+// size, age, content type, metadata, object tags and content hashes. They are inspired by rclone's filtering system.
 //
 // Basic usage:
 //
@@ -14,14 +14,22 @@
 //	if f.Match(fileInfo) {
 //	    // File passes filter
 //	}
+//
+// Filters and custom predicates compose with And, Or and Not (see
+// Matcher).
 package filter
 
 import (
 	"bufio"
+	"mime"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/grokify/omnistorage"
 )
 
 // Filter determines whether files should be included in sync operations.
@@ -38,13 +46,26 @@ const (
 	ruleMaxSize
 	ruleMinAge
 	ruleMaxAge
+	ruleIncludeMetadata
+	ruleExcludeMetadata
+	ruleIncludeTag
+	ruleExcludeTag
+	ruleIncludeContentType
+	ruleExcludeContentType
+	ruleIncludeHashes
+	ruleExcludeHashes
+	ruleWhere
 )
 
 type rule struct {
 	ruleType ruleType
-	pattern  string        // for include/exclude
+	key      string        // for metadata/tag rules
+	pattern  string        // for include/exclude, content types, and metadata/tag values
 	size     int64         // for min/max size
 	duration time.Duration // for min/max age
+	hashType omnistorage.HashType
+	hashes   map[string]bool // lower-case hex, for hash rules
+	matcher  Matcher         // for where rules
 }
 
 // FileInfo contains the information needed for filtering.
@@ -53,6 +74,26 @@ type FileInfo struct {
 	Size    int64
 	ModTime time.Time
 	IsDir   bool
+
+	// ContentType is the file's MIME type as reported by the backend,
+	// needed by content type rules. If it is empty or
+	// application/octet-stream, the type is guessed from the extension.
+	ContentType string
+
+	// Metadata is the file's user metadata, needed by metadata rules.
+	// Metadata rules are skipped while Metadata is nil; use an empty map
+	// for a file without metadata.
+	Metadata map[string]string
+
+	// Tags are the file's object tags, needed by tag rules. Tag rules are
+	// skipped while Tags is nil, so callers can check the other rules
+	// before fetching tags; use an empty map for a file without tags.
+	Tags map[string]string
+
+	// Hashes are the file's content hashes, of the types NeedsHashes
+	// returns, needed by hash rules. Hash rules are skipped while Hashes
+	// is nil, as with Tags.
+	Hashes omnistorage.HashSet
 }
 
 // Option configures a Filter.
@@ -137,10 +178,146 @@ func MaxAge(d time.Duration) Option {
 	}
 }
 
+// IncludeMetadata adds a metadata include rule: files whose metadata
+// value for key matches pattern are included (unless excluded). Keys are
+// case-insensitive; the pattern uses filepath.Match syntax, so "*"
+// matches any value of a key that is set. When there are metadata or tag
+// include rules, files must match at least one of them, as well as any
+// include pattern.
+func IncludeMetadata(key, pattern string) Option {
+	return func(f *Filter) {
+		f.rules = append(f.rules, rule{
+			ruleType: ruleIncludeMetadata,
+			key:      key,
+			pattern:  pattern,
+		})
+	}
+}
+
+// ExcludeMetadata adds a metadata exclude rule: files whose metadata
+// value for key matches pattern are excluded.
+func ExcludeMetadata(key, pattern string) Option {
+	return func(f *Filter) {
+		f.rules = append(f.rules, rule{
+			ruleType: ruleExcludeMetadata,
+			key:      key,
+			pattern:  pattern,
+		})
+	}
+}
+
+// IncludeTag adds a tag include rule, such as IncludeTag("class", "gold"):
+// files whose object tag key has a value matching pattern are included
+// (unless excluded). Tag keys are case-sensitive.
+func IncludeTag(key, pattern string) Option {
+	return func(f *Filter) {
+		f.rules = append(f.rules, rule{
+			ruleType: ruleIncludeTag,
+			key:      key,
+			pattern:  pattern,
+		})
+	}
+}
+
+// ExcludeTag adds a tag exclude rule, such as ExcludeTag("temp", "true"):
+// files whose object tag key has a value matching pattern are excluded.
+func ExcludeTag(key, pattern string) Option {
+	return func(f *Filter) {
+		f.rules = append(f.rules, rule{
+			ruleType: ruleExcludeTag,
+			key:      key,
+			pattern:  pattern,
+		})
+	}
+}
+
+// IncludeContentType adds a content type include rule: files whose MIME
+// type matches pattern, such as "image/*" or "application/json", are
+// included (unless excluded). Types are compared without parameters and
+// case-insensitively. When there are content type include rules, files
+// must match at least one of them.
+func IncludeContentType(pattern string) Option {
+	return func(f *Filter) {
+		f.rules = append(f.rules, rule{
+			ruleType: ruleIncludeContentType,
+			pattern:  strings.ToLower(pattern),
+		})
+	}
+}
+
+// ExcludeContentType adds a content type exclude rule, such as
+// ExcludeContentType("video/*"): files whose MIME type matches pattern
+// are excluded.
+func ExcludeContentType(pattern string) Option {
+	return func(f *Filter) {
+		f.rules = append(f.rules, rule{
+			ruleType: ruleExcludeContentType,
+			pattern:  strings.ToLower(pattern),
+		})
+	}
+}
+
+// IncludeHashes adds a hash include rule: only files whose content hash
+// of type t is one of hashes, given in hex, are included (unless
+// excluded). Use it to process exactly a known set of artifacts. When
+// there are several hash include rules, files must match at least one.
+func IncludeHashes(t omnistorage.HashType, hashes ...string) Option {
+	return hashRule(ruleIncludeHashes, t, hashes)
+}
+
+// ExcludeHashes adds a hash exclude rule: files whose content hash of
+// type t is one of hashes, given in hex, are excluded, such as artifacts
+// an ingest pipeline has already processed, wherever they are.
+func ExcludeHashes(t omnistorage.HashType, hashes ...string) Option {
+	return hashRule(ruleExcludeHashes, t, hashes)
+}
+
+func hashRule(rt ruleType, t omnistorage.HashType, hashes []string) Option {
+	set := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		set[strings.ToLower(h)] = true
+	}
+	return func(f *Filter) {
+		f.rules = append(f.rules, rule{
+			ruleType: rt,
+			hashType: t,
+			hashes:   set,
+		})
+	}
+}
+
+// HashesFromFile reads a list of hashes for IncludeHashes or
+// ExcludeHashes from a file with one hash per line, optionally followed
+// by a file name as written by sha256sum and similar tools. Empty lines
+// and lines starting with # are ignored.
+func HashesFromFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var hashes []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hashes = append(hashes, strings.Fields(line)[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
 // FromFile loads filter rules from a file.
 // Each line is a pattern. Lines starting with + are includes,
-// lines starting with - are excludes. Empty lines and lines
-// starting with # are ignored.
+// lines starting with - are excludes. Patterns of the form
+// meta:key=value and tag:key=value are metadata and tag rules, and
+// type:pattern content type rules. Empty lines and lines starting with #
+// are ignored.
 //
 // Example file:
 //
@@ -149,6 +326,10 @@ func MaxAge(d time.Duration) Option {
 //	# Exclude temp files
 //	- *.tmp
 //	- *.bak
+//	# Exclude objects tagged temp=true
+//	- tag:temp=true
+//	# Exclude videos
+//	- type:video/*
 func FromFile(path string) (Option, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -166,13 +347,13 @@ func FromFile(path string) (Option, error) {
 
 		if strings.HasPrefix(line, "+ ") {
 			pattern := strings.TrimPrefix(line, "+ ")
-			opts = append(opts, Include(pattern))
+			opts = append(opts, parseRule(pattern, true))
 		} else if strings.HasPrefix(line, "- ") {
 			pattern := strings.TrimPrefix(line, "- ")
-			opts = append(opts, Exclude(pattern))
+			opts = append(opts, parseRule(pattern, false))
 		} else {
 			// Default to exclude
-			opts = append(opts, Exclude(line))
+			opts = append(opts, parseRule(line, false))
 		}
 	}
 
@@ -187,13 +368,55 @@ func FromFile(path string) (Option, error) {
 	}, nil
 }
 
+// parseRule returns the rule of a filter file pattern, include or
+// exclude.
+func parseRule(pattern string, include bool) Option {
+	if t, ok := strings.CutPrefix(pattern, "type:"); ok {
+		if include {
+			return IncludeContentType(t)
+		}
+		return ExcludeContentType(t)
+	}
+	for _, kind := range []string{"meta:", "tag:"} {
+		kv, ok := strings.CutPrefix(pattern, kind)
+		if !ok {
+			continue
+		}
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			break
+		}
+		switch {
+		case kind == "meta:" && include:
+			return IncludeMetadata(key, value)
+		case kind == "meta:":
+			return ExcludeMetadata(key, value)
+		case include:
+			return IncludeTag(key, value)
+		default:
+			return ExcludeTag(key, value)
+		}
+	}
+	if include {
+		return Include(pattern)
+	}
+	return Exclude(pattern)
+}
+
 // Match returns true if the file passes the filter.
 //
 // The filtering logic:
 //  1. If there are include patterns and the file doesn't match any, exclude it
-//  2. If the file matches any exclude pattern, exclude it
-//  3. If the file fails size or age constraints, exclude it
-//  4. Otherwise, include the file
+//  2. If there are metadata or tag include rules and the file doesn't
+//     match any, exclude it
+//  3. If there are content type or hash include rules and the file
+//     doesn't match any of each, exclude it
+//  4. If the file matches any exclude pattern or rule, exclude it
+//  5. If the file fails size or age constraints or a Where rule, exclude it
+//  6. Otherwise, include the file
+//
+// Metadata, tag and hash rules are skipped if fi.Metadata, fi.Tags and
+// fi.Hashes are nil.
 func (f *Filter) Match(fi FileInfo) bool {
 	if f == nil || len(f.rules) == 0 {
 		return true
@@ -202,12 +425,44 @@ func (f *Filter) Match(fi FileInfo) bool {
 	// Check if there are any include rules
 	hasIncludes := false
 	matchesInclude := false
+	hasAttrIncludes := false
+	matchesAttrInclude := false
+	hasTypeIncludes := false
+	matchesTypeInclude := false
+	hasHashIncludes := false
+	matchesHashInclude := false
+	var contentType string
+	if f.has(ruleIncludeContentType, ruleExcludeContentType) {
+		contentType = fi.mediaType()
+	}
 	for _, r := range f.rules {
-		if r.ruleType == ruleInclude {
+		switch r.ruleType {
+		case ruleInclude:
 			hasIncludes = true
 			if matchPattern(r.pattern, fi.Path) {
 				matchesInclude = true
 			}
+		case ruleIncludeMetadata, ruleIncludeTag:
+			if !r.hasAttr(fi) {
+				continue
+			}
+			hasAttrIncludes = true
+			if r.matchAttr(fi) {
+				matchesAttrInclude = true
+			}
+		case ruleIncludeContentType:
+			hasTypeIncludes = true
+			if matched, _ := filepath.Match(r.pattern, contentType); matched {
+				matchesTypeInclude = true
+			}
+		case ruleIncludeHashes:
+			if fi.Hashes == nil {
+				continue
+			}
+			hasHashIncludes = true
+			if r.matchHash(fi) {
+				matchesHashInclude = true
+			}
 		}
 	}
 
@@ -215,6 +470,15 @@ func (f *Filter) Match(fi FileInfo) bool {
 	if hasIncludes && !matchesInclude {
 		return false
 	}
+	if hasAttrIncludes && !matchesAttrInclude {
+		return false
+	}
+	if hasTypeIncludes && !matchesTypeInclude {
+		return false
+	}
+	if hasHashIncludes && !matchesHashInclude {
+		return false
+	}
 
 	// Check exclude patterns and constraints
 	for _, r := range f.rules {
@@ -241,17 +505,143 @@ func (f *Filter) Match(fi FileInfo) bool {
 			if age > r.duration {
 				return false
 			}
+		case ruleExcludeMetadata, ruleExcludeTag:
+			if r.hasAttr(fi) && r.matchAttr(fi) {
+				return false
+			}
+		case ruleExcludeContentType:
+			if matched, _ := filepath.Match(r.pattern, contentType); matched {
+				return false
+			}
+		case ruleExcludeHashes:
+			if fi.Hashes != nil && r.matchHash(fi) {
+				return false
+			}
+		case ruleWhere:
+			if !needsOf(r.matcher).missing(fi) && !r.matcher.Match(fi) {
+				return false
+			}
 		}
 	}
 
 	return true
 }
 
+// matchHash reports whether the file's hash of a hash rule's type is
+// in its set.
+func (r rule) matchHash(fi FileInfo) bool {
+	h := fi.Hashes.Get(r.hashType)
+	return h != "" && r.hashes[strings.ToLower(h)]
+}
+
+// mediaType returns the MIME type of fi, without parameters and in lower
+// case: fi.ContentType, or if it says nothing, the type of the path's
+// extension.
+func (fi FileInfo) mediaType() string {
+	t, _, _ := strings.Cut(fi.ContentType, ";")
+	t = strings.ToLower(strings.TrimSpace(t))
+	if t == "" || t == "application/octet-stream" {
+		if guess := mime.TypeByExtension(path.Ext(fi.Path)); guess != "" {
+			guess, _, _ = strings.Cut(guess, ";")
+			return strings.ToLower(guess)
+		}
+	}
+	return t
+}
+
+// hasAttr reports whether fi has the metadata or tags a metadata or tag
+// rule needs.
+func (r rule) hasAttr(fi FileInfo) bool {
+	switch r.ruleType {
+	case ruleIncludeMetadata, ruleExcludeMetadata:
+		return fi.Metadata != nil
+	default:
+		return fi.Tags != nil
+	}
+}
+
+// matchAttr reports whether the metadata or tag value of a metadata or tag
+// rule matches its pattern.
+func (r rule) matchAttr(fi FileInfo) bool {
+	var value string
+	var ok bool
+	switch r.ruleType {
+	case ruleIncludeMetadata, ruleExcludeMetadata:
+		for k, v := range fi.Metadata {
+			if strings.EqualFold(k, r.key) {
+				value, ok = v, true
+				break
+			}
+		}
+	default:
+		value, ok = fi.Tags[r.key]
+	}
+	if !ok {
+		return false
+	}
+	matched, _ := filepath.Match(r.pattern, value)
+	return matched
+}
+
 // MatchPath is a convenience method that matches by path only.
 func (f *Filter) MatchPath(path string) bool {
 	return f.Match(FileInfo{Path: path})
 }
 
+// NeedsMetadata returns true if the filter has metadata rules, so
+// FileInfo.Metadata must be set for them to apply.
+func (f *Filter) NeedsMetadata() bool {
+	return f.needs().metadata
+}
+
+// NeedsTags returns true if the filter has tag rules, so FileInfo.Tags
+// must be set for them to apply. Fetching tags usually takes a request
+// per file, so callers fetch them only for files that pass Match without
+// them.
+func (f *Filter) NeedsTags() bool {
+	return f.needs().tags
+}
+
+// NeedsHashes returns the hash types of the filter's hash rules, so
+// FileInfo.Hashes must have them for the rules to apply. Hashing usually
+// means reading the file, so callers compute hashes only for files that
+// pass Match without them.
+func (f *Filter) NeedsHashes() []omnistorage.HashType {
+	return f.needs().hashes
+}
+
+// needs returns the file attributes the filter's rules, including those
+// of filters in Where rules, need.
+func (f *Filter) needs() needs {
+	var n needs
+	if f == nil {
+		return n
+	}
+	for _, r := range f.rules {
+		switch r.ruleType {
+		case ruleIncludeMetadata, ruleExcludeMetadata:
+			n.metadata = true
+		case ruleIncludeTag, ruleExcludeTag:
+			n.tags = true
+		case ruleIncludeHashes, ruleExcludeHashes:
+			n.add(needs{hashes: []omnistorage.HashType{r.hashType}})
+		case ruleWhere:
+			n.add(needsOf(r.matcher))
+		}
+	}
+	return n
+}
+
+// has reports whether the filter has a rule of any of types.
+func (f *Filter) has(types ...ruleType) bool {
+	for _, r := range f.rules {
+		if slices.Contains(types, r.ruleType) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsEmpty returns true if the filter has no rules.
 func (f *Filter) IsEmpty() bool {
 	return f == nil || len(f.rules) == 0
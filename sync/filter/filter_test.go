@@ -3,8 +3,11 @@ package filter
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/grokify/omnistorage"
 )
 
 func TestFilterInclude(t *testing.T) {
@@ -254,6 +257,197 @@ func TestFilterFromFile(t *testing.T) {
 	}
 }
 
+func TestFilterMetadata(t *testing.T) {
+	f := New(
+		IncludeMetadata("class", "gold"),
+		ExcludeMetadata("temp", "true"),
+	)
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     bool
+	}{
+		{"gold", map[string]string{"class": "gold"}, true},
+		{"key case", map[string]string{"Class": "gold"}, true},
+		{"silver", map[string]string{"class": "silver"}, false},
+		{"gold temp", map[string]string{"class": "gold", "temp": "true"}, false},
+		{"none", map[string]string{}, false},
+		// Metadata not known: metadata rules are skipped.
+		{"unknown", nil, true},
+	}
+
+	for _, tc := range tests {
+		got := f.Match(FileInfo{Path: "file.txt", Metadata: tc.metadata})
+		if got != tc.want {
+			t.Errorf("Match(%s) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if !f.NeedsMetadata() || f.NeedsTags() {
+		t.Errorf("NeedsMetadata() = %v, NeedsTags() = %v, want true, false", f.NeedsMetadata(), f.NeedsTags())
+	}
+}
+
+func TestFilterTags(t *testing.T) {
+	f := New(
+		Include("*.json"),
+		IncludeTag("class", "gold*"),
+		ExcludeTag("temp", "true"),
+	)
+
+	tests := []struct {
+		path string
+		tags map[string]string
+		want bool
+	}{
+		{"a.json", map[string]string{"class": "gold"}, true},
+		{"a.json", map[string]string{"class": "golden"}, true},
+		{"a.json", map[string]string{"class": "silver"}, false},
+		{"a.json", map[string]string{"class": "gold", "temp": "true"}, false},
+		{"a.json", map[string]string{}, false},
+		{"a.txt", map[string]string{"class": "gold"}, false},
+		// Tags not fetched yet: tag rules are skipped.
+		{"a.json", nil, true},
+	}
+
+	for _, tc := range tests {
+		got := f.Match(FileInfo{Path: tc.path, Tags: tc.tags})
+		if got != tc.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tc.path, tc.tags, got, tc.want)
+		}
+	}
+	if !f.NeedsTags() || f.NeedsMetadata() {
+		t.Errorf("NeedsTags() = %v, NeedsMetadata() = %v, want true, false", f.NeedsTags(), f.NeedsMetadata())
+	}
+}
+
+func TestFilterFromFileAttributes(t *testing.T) {
+	filterPath := filepath.Join(t.TempDir(), "filters.txt")
+	content := `+ tag:class=gold
+- meta:owner=test
+- tag:temp=true
+`
+	if err := os.WriteFile(filterPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write filter file: %v", err)
+	}
+
+	opt, err := FromFile(filterPath)
+	if err != nil {
+		t.Fatalf("FromFile failed: %v", err)
+	}
+	f := New(opt)
+
+	if !f.Match(FileInfo{Path: "a", Tags: map[string]string{"class": "gold"}}) {
+		t.Error("gold file should match")
+	}
+	if f.Match(FileInfo{Path: "a", Tags: map[string]string{"class": "gold", "temp": "true"}}) {
+		t.Error("temp file should not match")
+	}
+	if f.Match(FileInfo{Path: "a", Tags: map[string]string{"class": "gold"}, Metadata: map[string]string{"owner": "test"}}) {
+		t.Error("file with owner=test metadata should not match")
+	}
+}
+
+func TestFilterContentType(t *testing.T) {
+	f := New(
+		IncludeContentType("image/*"),
+		IncludeContentType("application/json"),
+		ExcludeContentType("image/svg+xml"),
+	)
+
+	tests := []struct {
+		path        string
+		contentType string
+		want        bool
+	}{
+		{"photo", "image/png", true},
+		{"photo", "IMAGE/JPEG", true},
+		{"data", "application/json; charset=utf-8", true},
+		{"page", "text/html", false},
+		{"logo", "image/svg+xml", false},
+		// No content type from the backend: guessed from the extension.
+		{"photo.png", "", true},
+		{"photo.jpg", "application/octet-stream", true},
+		{"logo.svg", "", false},
+		{"doc.pdf", "", false},
+		{"unknown", "", false},
+	}
+
+	for _, tc := range tests {
+		got := f.Match(FileInfo{Path: tc.path, ContentType: tc.contentType})
+		if got != tc.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tc.path, tc.contentType, got, tc.want)
+		}
+	}
+
+	// Content type rules in a filter file.
+	filterPath := filepath.Join(t.TempDir(), "filters.txt")
+	if err := os.WriteFile(filterPath, []byte("- type:image/*\n"), 0600); err != nil {
+		t.Fatalf("Failed to write filter file: %v", err)
+	}
+	opt, err := FromFile(filterPath)
+	if err != nil {
+		t.Fatalf("FromFile failed: %v", err)
+	}
+	if f := New(opt); f.MatchPath("photo.png") || !f.MatchPath("data.json") {
+		t.Error("type:image/* should exclude photo.png only")
+	}
+}
+
+func TestFilterHashes(t *testing.T) {
+	f := New(
+		IncludeHashes(omnistorage.HashSHA256, "AAA", "bbb", "ccc"),
+		ExcludeHashes(omnistorage.HashSHA256, "ccc"),
+	)
+
+	tests := []struct {
+		hashes omnistorage.HashSet
+		want   bool
+	}{
+		{omnistorage.HashSet{omnistorage.HashSHA256: "aaa"}, true},
+		{omnistorage.HashSet{omnistorage.HashSHA256: "BBB"}, true},
+		{omnistorage.HashSet{omnistorage.HashSHA256: "ccc"}, false},
+		{omnistorage.HashSet{omnistorage.HashSHA256: "ddd"}, false},
+		{omnistorage.HashSet{omnistorage.HashMD5: "aaa"}, false},
+		// Hashes not computed yet: hash rules are skipped.
+		{nil, true},
+	}
+
+	for _, tc := range tests {
+		got := f.Match(FileInfo{Path: "file", Hashes: tc.hashes})
+		if got != tc.want {
+			t.Errorf("Match(%v) = %v, want %v", tc.hashes, got, tc.want)
+		}
+	}
+	if types := f.NeedsHashes(); len(types) != 1 || types[0] != omnistorage.HashSHA256 {
+		t.Errorf("NeedsHashes() = %v, want [sha256]", types)
+	}
+	if New(Include("*")).NeedsHashes() != nil {
+		t.Error("NeedsHashes() without hash rules should be nil")
+	}
+}
+
+func TestHashesFromFile(t *testing.T) {
+	hashPath := filepath.Join(t.TempDir(), "processed.sha256")
+	content := `# processed artifacts
+aaa  build/app.tar.gz
+bbb
+
+ccc *build/app.zip
+`
+	if err := os.WriteFile(hashPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write hash file: %v", err)
+	}
+
+	hashes, err := HashesFromFile(hashPath)
+	if err != nil {
+		t.Fatalf("HashesFromFile failed: %v", err)
+	}
+	if want := []string{"aaa", "bbb", "ccc"}; !slices.Equal(hashes, want) {
+		t.Errorf("HashesFromFile() = %v, want %v", hashes, want)
+	}
+}
+
 func TestFilterFromFileNotFound(t *testing.T) {
 	_, err := FromFile("/nonexistent/filter.txt")
 	if err == nil {
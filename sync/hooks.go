@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ErrAborted is returned when a hook aborts a sync.
+var ErrAborted = errors.New("sync aborted by hook")
+
+// Decision is a hook's verdict on a file operation.
+type Decision int
+
+const (
+	// Continue performs the operation as usual.
+	Continue Decision = iota
+
+	// Skip leaves the file untouched and moves on to the next one.
+	Skip
+
+	// Abort stops the sync. Transfers already in flight finish, and the
+	// partial result is returned with an error matching ErrAborted.
+	Abort
+)
+
+// String returns the decision name.
+func (d Decision) String() string {
+	switch d {
+	case Continue:
+		return "continue"
+	case Skip:
+		return "skip"
+	case Abort:
+		return "abort"
+	default:
+		return "unknown"
+	}
+}
+
+// Hooks are callbacks invoked around file operations, for example to
+// notify a queue of each landed object or to veto deleting paths under
+// legal hold. Any hook may be nil.
+//
+// Sync transfers files concurrently, so hooks may be called from several
+// goroutines at once.
+type Hooks struct {
+	// BeforeCopy is called before a file is copied or updated, including
+	// in dry-run mode. Skipped files are counted in Result.Skipped.
+	BeforeCopy func(ctx context.Context, f FileInfo) Decision
+
+	// AfterCopy is called after a file has been copied or updated. It is
+	// not called in dry-run mode.
+	AfterCopy func(ctx context.Context, f FileInfo)
+
+	// BeforeDelete is called before a file is deleted, including in
+	// dry-run mode. Skipped files are kept and counted in Result.Skipped.
+	BeforeDelete func(ctx context.Context, f FileInfo) Decision
+
+	// OnError is called when a file operation fails. Continue records the
+	// error in the result as usual, Skip ignores it, and Abort records it
+	// and stops the sync.
+	OnError func(ctx context.Context, f FileInfo, err FileError) Decision
+
+	// OnConflict is called by Bisync for a file changed on both sides,
+	// before the conflict is resolved. Continue resolves it with the
+	// ConflictStrategy, and Skip leaves both sides untouched.
+	OnConflict func(ctx context.Context, c Conflict) Decision
+}
+
+func (h *Hooks) beforeCopy(ctx context.Context, f FileInfo) Decision {
+	if h == nil || h.BeforeCopy == nil {
+		return Continue
+	}
+	return h.BeforeCopy(ctx, f)
+}
+
+func (h *Hooks) afterCopy(ctx context.Context, f FileInfo) {
+	if h != nil && h.AfterCopy != nil {
+		h.AfterCopy(ctx, f)
+	}
+}
+
+func (h *Hooks) beforeDelete(ctx context.Context, f FileInfo) Decision {
+	if h == nil || h.BeforeDelete == nil {
+		return Continue
+	}
+	return h.BeforeDelete(ctx, f)
+}
+
+func (h *Hooks) onError(ctx context.Context, f FileInfo, err FileError) Decision {
+	if h == nil || h.OnError == nil {
+		return Continue
+	}
+	return h.OnError(ctx, f, err)
+}
+
+func (h *Hooks) onConflict(ctx context.Context, c Conflict) Decision {
+	if h == nil || h.OnConflict == nil {
+		return Continue
+	}
+	return h.OnConflict(ctx, c)
+}
+
+// abortError returns the error for a sync aborted by a hook at op on p.
+func abortError(op, p string) error {
+	return fmt.Errorf("%w: %s %s", ErrAborted, op, p)
+}
+
+// confirmed returns the actions Confirm allows. New files are always
+// allowed; declined updates and deletes are counted as skipped in result.
+func (o Options) confirmed(actions []Action, result *Result) []Action {
+	out := actions[:0]
+	for _, a := range actions {
+		if (a.Type == ActionUpdate || a.Type == ActionDelete) && !o.Confirm(a) {
+			o.logger().Debug("action declined", slog.String("action", string(a.Type)), slog.String("path", a.File.Path))
+			result.Skipped++
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
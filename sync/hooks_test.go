@@ -0,0 +1,277 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	gosync "sync"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestSyncHooks(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "a.txt", "a")
+	writeFile(t, ctx, src, "b.txt", "b")
+	writeFile(t, ctx, src, "skip.txt", "skip")
+	writeFile(t, ctx, dst, "extra.txt", "extra")
+	writeFile(t, ctx, dst, "held/contract.pdf", "held")
+
+	var mu gosync.Mutex
+	var landed []string
+	result, err := Sync(ctx, src, dst, "", "", Options{
+		DeleteExtra: true,
+		Hooks: &Hooks{
+			BeforeCopy: func(_ context.Context, f FileInfo) Decision {
+				if f.Path == "skip.txt" {
+					return Skip
+				}
+				return Continue
+			},
+			AfterCopy: func(_ context.Context, f FileInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				landed = append(landed, f.Path)
+			},
+			BeforeDelete: func(_ context.Context, f FileInfo) Decision {
+				if strings.HasPrefix(f.Path, "held/") {
+					return Skip
+				}
+				return Continue
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sync error = %v", err)
+	}
+
+	if result.Copied != 2 || result.Deleted != 1 || result.Skipped != 2 {
+		t.Errorf("Copied = %d, Deleted = %d, Skipped = %d; want 2, 1, 2", result.Copied, result.Deleted, result.Skipped)
+	}
+	slices.Sort(landed)
+	if !slices.Equal(landed, []string{"a.txt", "b.txt"}) {
+		t.Errorf("AfterCopy called for %v, want a.txt and b.txt", landed)
+	}
+	if exists, _ := dst.Exists(ctx, "skip.txt"); exists {
+		t.Error("skip.txt copied despite BeforeCopy skip")
+	}
+	if exists, _ := dst.Exists(ctx, "held/contract.pdf"); !exists {
+		t.Error("held/contract.pdf deleted despite BeforeDelete skip")
+	}
+}
+
+func TestSyncHookAbort(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "a.txt", "a")
+	writeFile(t, ctx, src, "b.txt", "b")
+	writeFile(t, ctx, src, "c.txt", "c")
+	writeFile(t, ctx, dst, "extra.txt", "extra")
+
+	result, err := Sync(ctx, src, dst, "", "", Options{
+		DeleteExtra:   true,
+		Concurrency:   1,
+		TransferOrder: OrderAlphabetical,
+		Hooks: &Hooks{
+			BeforeCopy: func(_ context.Context, f FileInfo) Decision {
+				if f.Path == "b.txt" {
+					return Abort
+				}
+				return Continue
+			},
+		},
+	})
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("Sync error = %v, want ErrAborted", err)
+	}
+	if result == nil || result.Copied != 1 {
+		t.Fatalf("Result = %+v, want 1 file copied", result)
+	}
+	if exists, _ := dst.Exists(ctx, "extra.txt"); !exists {
+		t.Error("extra.txt deleted after abort")
+	}
+}
+
+// failingWriter fails every write to paths with the given prefix.
+type failingWriter struct {
+	*memory.Backend
+	prefix string
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (b failingWriter) NewWriter(ctx context.Context, p string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	if strings.HasPrefix(p, b.prefix) {
+		return nil, errWriteFailed
+	}
+	return b.Backend.NewWriter(ctx, p, opts...)
+}
+
+func TestSyncHookOnError(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	writeFile(t, ctx, src, "ok.txt", "ok")
+	writeFile(t, ctx, src, "tmp/scratch.txt", "scratch")
+	writeFile(t, ctx, src, "tmp/other.txt", "other")
+	dst := failingWriter{Backend: memory.New(), prefix: "tmp/"}
+
+	var calls []string
+	var mu gosync.Mutex
+	result, err := Sync(ctx, src, dst, "", "", Options{
+		MaxErrors: 10,
+		Hooks: &Hooks{
+			OnError: func(_ context.Context, f FileInfo, fe FileError) Decision {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, fe.Op+" "+f.Path)
+				if !errors.Is(fe.Err, errWriteFailed) {
+					t.Errorf("OnError err = %v, want errWriteFailed", fe.Err)
+				}
+				if f.Path == "tmp/scratch.txt" {
+					return Skip
+				}
+				return Continue
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sync error = %v", err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("OnError calls = %v, want 2", calls)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Path != "tmp/other.txt" {
+		t.Errorf("Errors = %v, want only tmp/other.txt", result.Errors)
+	}
+	if result.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", result.Copied)
+	}
+}
+
+func TestMoveBeforeDelete(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "a.txt", "a")
+	writeFile(t, ctx, src, "held.txt", "held")
+
+	_, err := Move(ctx, src, dst, "", "", Options{
+		Hooks: &Hooks{
+			BeforeDelete: func(_ context.Context, f FileInfo) Decision {
+				if f.Path == "held.txt" {
+					return Skip
+				}
+				return Continue
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Move error = %v", err)
+	}
+	if exists, _ := src.Exists(ctx, "held.txt"); !exists {
+		t.Error("held.txt deleted from source despite BeforeDelete skip")
+	}
+	if exists, _ := src.Exists(ctx, "a.txt"); exists {
+		t.Error("a.txt not deleted from source")
+	}
+}
+
+func TestBisyncOnConflict(t *testing.T) {
+	ctx := context.Background()
+	backend1 := memory.New()
+	backend2 := memory.New()
+	writeFile(t, ctx, backend1, "p1/doc.txt", "version one")
+	writeFile(t, ctx, backend2, "p2/doc.txt", "version two, longer")
+	writeFile(t, ctx, backend1, "p1/new.txt", "new")
+
+	var seen []Conflict
+	var landed []string
+	result, err := Bisync(ctx, backend1, backend2, "p1", "p2", BisyncOptions{
+		ConflictStrategy: ConflictLargerWins,
+		Hooks: &Hooks{
+			OnConflict: func(_ context.Context, c Conflict) Decision {
+				seen = append(seen, c)
+				return Skip
+			},
+			AfterCopy: func(_ context.Context, f FileInfo) {
+				landed = append(landed, f.Path)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Bisync error = %v", err)
+	}
+	if len(seen) != 1 || seen[0].Path != "doc.txt" || seen[0].Path2Info.Size != 19 {
+		t.Errorf("OnConflict calls = %+v, want doc.txt", seen)
+	}
+	if result.TotalUpdated() != 0 {
+		t.Errorf("TotalUpdated = %d, want 0 for a skipped conflict", result.TotalUpdated())
+	}
+	verifyFile(t, ctx, backend1, "p1/doc.txt", "version one")
+	if !slices.Equal(landed, []string{"new.txt"}) {
+		t.Errorf("AfterCopy called for %v, want new.txt", landed)
+	}
+
+	writeFile(t, ctx, backend1, "p1/other.txt", "other")
+	_, err = Bisync(ctx, backend1, backend2, "p1", "p2", BisyncOptions{
+		Hooks: &Hooks{
+			OnConflict: func(context.Context, Conflict) Decision { return Abort },
+		},
+	})
+	if !errors.Is(err, ErrAborted) {
+		t.Errorf("Bisync error = %v, want ErrAborted", err)
+	}
+}
+
+func TestSyncConfirm(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "new.txt", "new")
+	writeFile(t, ctx, src, "keep.txt", "source")
+	writeFile(t, ctx, dst, "keep.txt", "local edits")
+	writeFile(t, ctx, src, "replace.txt", "source")
+	writeFile(t, ctx, dst, "replace.txt", "old")
+	writeFile(t, ctx, dst, "extra.txt", "extra")
+	writeFile(t, ctx, dst, "scratch.tmp", "scratch")
+
+	var asked []string
+	opts := Options{
+		DeleteExtra: true,
+		SizeOnly:    true,
+		Concurrency: 4,
+		Confirm: func(a Action) bool {
+			asked = append(asked, string(a.Type)+" "+a.File.Path)
+			return a.File.Path == "replace.txt" || strings.HasSuffix(a.File.Path, ".tmp")
+		},
+	}
+	// Dry runs ask nothing
+	if _, err := Sync(ctx, src, dst, "", "", Options{DryRun: true, Confirm: opts.Confirm}); err != nil || len(asked) != 0 {
+		t.Fatalf("dry run asked %v, err %v", asked, err)
+	}
+
+	result, err := Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	slices.Sort(asked)
+	if want := []string{"delete extra.txt", "delete scratch.tmp", "update keep.txt", "update replace.txt"}; !slices.Equal(asked, want) {
+		t.Errorf("asked = %q, want %q", asked, want)
+	}
+	if result.Copied != 1 || result.Updated != 1 || result.Deleted != 1 || result.Skipped != 2 {
+		t.Errorf("result = %+v, want 1 copied, updated, deleted, 2 skipped", result)
+	}
+	verifyFile(t, ctx, dst, "keep.txt", "local edits")
+	verifyFile(t, ctx, dst, "replace.txt", "source")
+	verifyFile(t, ctx, dst, "extra.txt", "extra")
+	if ok, _ := dst.Exists(ctx, "scratch.tmp"); ok {
+		t.Error("scratch.tmp was not deleted")
+	}
+}
@@ -0,0 +1,195 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	gosync "sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// journalFlushInterval is how often a Journal is saved while files are
+// recorded. Files recorded since the last save are compared again after
+// a crash.
+const journalFlushInterval = 10 * time.Second
+
+// journalEntry is a source file recorded in a Journal.
+type journalEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Journal records the source files a sync has transferred, so that a run
+// that crashed or was cancelled can be re-run and skip them without
+// comparing them with the destination (see Options.Journal). It is saved
+// as newline-delimited JSON to a path in a backend, such as a local file
+// backend, outside the paths synced. Paths are relative to the source
+// path synced, so use one journal per sync.
+//
+// A Journal is safe for concurrent use.
+type Journal struct {
+	backend omnistorage.Backend
+	path    string
+
+	mu      gosync.Mutex
+	entries map[string]journalEntry
+	dirty   bool
+	saved   time.Time
+
+	saveMu gosync.Mutex // serializes saves
+}
+
+// OpenJournal loads the journal at path in backend, or returns an empty
+// journal if there is none.
+func OpenJournal(ctx context.Context, backend omnistorage.Backend, path string) (*Journal, error) {
+	j := &Journal{
+		backend: backend,
+		path:    path,
+		entries: make(map[string]journalEntry),
+		saved:   time.Now(),
+	}
+	r, err := backend.NewReader(ctx, path)
+	if omnistorage.IsNotFound(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	// A run killed while saving can leave a truncated last line, which is
+	// ignored: its file is compared again.
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("open journal: %w", err)
+		}
+		var e journalEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("open journal: %s: %w", path, err)
+		}
+		j.entries[e.Path] = e
+	}
+	return j, nil
+}
+
+// Done reports whether the source file f was transferred by a previous
+// run, with the same size and modification time it has now.
+func (j *Journal) Done(f FileInfo) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e, ok := j.entries[f.Path]
+	return ok && e.Size == f.Size && e.ModTime.Equal(f.ModTime)
+}
+
+// Files returns the number of files recorded.
+func (j *Journal) Files() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.entries)
+}
+
+// Bytes returns the total size of the files recorded.
+func (j *Journal) Bytes() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var n int64
+	for _, e := range j.entries {
+		n += e.Size
+	}
+	return n
+}
+
+// record adds the transferred source file f, and saves the journal if it
+// has not been saved for journalFlushInterval.
+func (j *Journal) record(ctx context.Context, f FileInfo) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	j.entries[f.Path] = journalEntry{Path: f.Path, Size: f.Size, ModTime: f.ModTime}
+	j.dirty = true
+	due := time.Since(j.saved) >= journalFlushInterval
+	j.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return j.Flush(ctx)
+}
+
+// Flush saves the journal if files were recorded since it was last saved.
+// Flush of a nil Journal does nothing.
+func (j *Journal) Flush(ctx context.Context) error {
+	if j == nil {
+		return nil
+	}
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+
+	j.mu.Lock()
+	if !j.dirty {
+		j.mu.Unlock()
+		return nil
+	}
+	entries := make([]journalEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		entries = append(entries, e)
+	}
+	j.dirty = false
+	j.saved = time.Now()
+	j.mu.Unlock()
+
+	if err := j.save(ctx, entries); err != nil {
+		j.mu.Lock()
+		j.dirty = true
+		j.mu.Unlock()
+		return fmt.Errorf("save journal: %w", err)
+	}
+	return nil
+}
+
+func (j *Journal) save(ctx context.Context, entries []journalEntry) error {
+	w, err := j.backend.NewWriter(ctx, j.path, omnistorage.WithContentType("application/x-ndjson"))
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			_ = w.Close()
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Remove deletes the saved journal and forgets the files recorded.
+func (j *Journal) Remove(ctx context.Context) error {
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+
+	j.mu.Lock()
+	j.entries = make(map[string]journalEntry)
+	j.dirty = false
+	j.mu.Unlock()
+
+	if err := j.backend.Delete(ctx, j.path); err != nil && !omnistorage.IsNotFound(err) {
+		return fmt.Errorf("remove journal: %w", err)
+	}
+	return nil
+}
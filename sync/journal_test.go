@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestSyncJournalResume(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	state := memory.New()
+	for _, p := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"} {
+		writeFile(t, ctx, src, p, "content of "+p)
+	}
+
+	journal, err := OpenJournal(ctx, state, "sync.journal")
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+
+	// Cancel the first run after two files
+	runCtx, cancel := context.WithCancel(ctx)
+	copies := 0
+	_, err = Sync(runCtx, src, dst, "", "", Options{
+		Concurrency: 1,
+		Journal:     journal,
+		Hooks: &Hooks{AfterCopy: func(context.Context, FileInfo) {
+			if copies++; copies == 2 {
+				cancel()
+			}
+		}},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sync error = %v, want context.Canceled", err)
+	}
+	if journal.Files() != 2 {
+		t.Errorf("Files() = %d, want 2", journal.Files())
+	}
+
+	// The resumed run skips the files in the saved journal, unless they
+	// changed since
+	journal, err = OpenJournal(ctx, state, "sync.journal")
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	if journal.Files() != 2 || journal.Bytes() != int64(2*len("content of a.txt")) {
+		t.Fatalf("reopened journal has %d files, %d bytes", journal.Files(), journal.Bytes())
+	}
+	writeFile(t, ctx, src, "b.txt", "changed")
+	result, err := Sync(ctx, src, dst, "", "", Options{Journal: journal})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Resumed != 1 || result.Copied != 3 || result.Updated != 1 {
+		t.Errorf("Resumed = %d, Copied = %d, Updated = %d, want 1, 3, 1", result.Resumed, result.Copied, result.Updated)
+	}
+	if got := readBackend(t, dst, "b.txt"); got != "changed" {
+		t.Errorf("b.txt = %q, want %q", got, "changed")
+	}
+
+	// A complete run removes the journal
+	if exists, _ := state.Exists(ctx, "sync.journal"); exists {
+		t.Error("journal not removed after a complete run")
+	}
+	if journal.Files() != 0 {
+		t.Errorf("Files() = %d after a complete run, want 0", journal.Files())
+	}
+}
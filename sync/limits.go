@@ -0,0 +1,123 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	gosync "sync"
+	"time"
+)
+
+// ErrLimitReached matches any *LimitError with errors.Is.
+var ErrLimitReached = errors.New("transfer limit reached")
+
+// Limit identifies the transfer cap that stopped a sync.
+type Limit string
+
+const (
+	// LimitMaxTransfer is Options.MaxTransferBytes.
+	LimitMaxTransfer Limit = "max transfer"
+
+	// LimitMaxDuration is Options.MaxDuration.
+	LimitMaxDuration Limit = "max duration"
+)
+
+// LimitError is returned, with the partial Result, when a sync stops
+// early because Options.MaxTransferBytes or Options.MaxDuration was
+// reached. Files started before the limit was reached were finished and
+// are counted in the Result.
+type LimitError struct {
+	// Limit is the cap that was reached.
+	Limit Limit
+
+	// Remaining is the number of files that were not copied.
+	Remaining int
+
+	// RemainingBytes is the size of the files that were not copied.
+	RemainingBytes int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s reached: %d files (%d bytes) not transferred", e.Limit, e.Remaining, e.RemainingBytes)
+}
+
+// Is reports whether target is ErrLimitReached.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitReached
+}
+
+// IsLimitError returns true if err is a LimitError.
+func IsLimitError(err error) bool {
+	var le *LimitError
+	return errors.As(err, &le)
+}
+
+// transferBudget enforces MaxTransferBytes and MaxDuration. It is shared
+// by the workers of one run; once exhausted it stays exhausted.
+type transferBudget struct {
+	maxBytes int64
+	deadline time.Time
+
+	mu             gosync.Mutex
+	started        int64
+	limit          Limit
+	remaining      int
+	remainingBytes int64
+}
+
+func newTransferBudget(opts Options, start time.Time) *transferBudget {
+	b := &transferBudget{maxBytes: opts.MaxTransferBytes}
+	if opts.MaxDuration > 0 {
+		b.deadline = start.Add(opts.MaxDuration)
+	}
+	return b
+}
+
+// take reports whether a file of the given size may be started. A file
+// that is refused is counted as remaining.
+func (b *transferBudget) take(size int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit == "" {
+		b.check(size)
+	}
+	if b.limit != "" {
+		b.remaining++
+		b.remainingBytes += size
+		return false
+	}
+	b.started += size
+	return true
+}
+
+// expired reports whether the budget is exhausted, checking the deadline.
+// It is used for deletes, which do not count against MaxTransferBytes.
+func (b *transferBudget) expired() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit == "" {
+		b.check(0)
+	}
+	return b.limit != ""
+}
+
+func (b *transferBudget) check(size int64) {
+	switch {
+	case !b.deadline.IsZero() && !time.Now().Before(b.deadline):
+		b.limit = LimitMaxDuration
+	case b.maxBytes > 0 && b.started+size > b.maxBytes:
+		b.limit = LimitMaxTransfer
+	}
+}
+
+// err returns a *LimitError if the budget is exhausted, or nil.
+func (b *transferBudget) err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit == "" {
+		return nil
+	}
+	return &LimitError{Limit: b.limit, Remaining: b.remaining, RemainingBytes: b.remainingBytes}
+}
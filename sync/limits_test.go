@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/throttle"
+)
+
+// writeTenByteFiles writes n files of 10 bytes each.
+func writeTenByteFiles(t *testing.T, ctx context.Context, backend *memory.Backend, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		writeFile(t, ctx, backend, fmt.Sprintf("file%d.txt", i), "0123456789")
+	}
+}
+
+func TestSyncMaxTransferBytes(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeTenByteFiles(t, ctx, src, 5)
+	writeFile(t, ctx, dst, "extra.txt", "extra")
+
+	result, err := Sync(ctx, src, dst, "", "", Options{
+		DeleteExtra:      true,
+		Concurrency:      1,
+		MaxTransferBytes: 25,
+	})
+
+	var le *LimitError
+	if !errors.As(err, &le) {
+		t.Fatalf("Sync error = %v, want *LimitError", err)
+	}
+	if !errors.Is(err, ErrLimitReached) || !IsLimitError(err) {
+		t.Error("LimitError does not match ErrLimitReached")
+	}
+	if le.Limit != LimitMaxTransfer || le.Remaining != 3 || le.RemainingBytes != 30 {
+		t.Errorf("LimitError = %+v, want max transfer with 3 files (30 bytes) remaining", le)
+	}
+	if result == nil || result.Copied != 2 || result.BytesTransferred != 20 {
+		t.Fatalf("Result = %+v, want 2 files (20 bytes) copied", result)
+	}
+	if result.Deleted != 0 {
+		t.Error("extra files were deleted after the limit was reached")
+	}
+	if exists, _ := dst.Exists(ctx, "extra.txt"); !exists {
+		t.Error("extra.txt deleted from a partially synced destination")
+	}
+}
+
+func TestSyncMaxTransferBytesNotReached(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeTenByteFiles(t, ctx, src, 3)
+
+	result, err := Sync(ctx, src, dst, "", "", Options{MaxTransferBytes: 30})
+	if err != nil {
+		t.Fatalf("Sync error = %v, want nil at exactly the limit", err)
+	}
+	if result.Copied != 3 {
+		t.Errorf("Copied = %d, want 3", result.Copied)
+	}
+}
+
+func TestSyncMaxTransferBytesDryRun(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeTenByteFiles(t, ctx, src, 4)
+
+	result, err := Sync(ctx, src, dst, "", "", Options{DryRun: true, MaxTransferBytes: 15})
+	if !IsLimitError(err) {
+		t.Fatalf("Sync error = %v, want *LimitError", err)
+	}
+	if result.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", result.Copied)
+	}
+}
+
+func TestSyncMaxDuration(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	writeTenByteFiles(t, ctx, src, 5)
+	dst := throttle.New(memory.New(), throttle.WithOpLatency(throttle.OpWrite, 60*time.Millisecond))
+
+	result, err := Sync(ctx, src, dst, "", "", Options{
+		Concurrency: 1,
+		MaxDuration: 100 * time.Millisecond,
+	})
+
+	var le *LimitError
+	if !errors.As(err, &le) {
+		t.Fatalf("Sync error = %v, want *LimitError", err)
+	}
+	if le.Limit != LimitMaxDuration {
+		t.Errorf("Limit = %q, want %q", le.Limit, LimitMaxDuration)
+	}
+	if result.Copied == 0 || result.Copied == 5 || result.Copied+le.Remaining != 5 {
+		t.Errorf("Copied = %d, Remaining = %d; want a partial sync", result.Copied, le.Remaining)
+	}
+	// In-flight files finish: every counted file is complete.
+	for i := 0; i < result.Copied; i++ {
+		if exists, _ := dst.Exists(ctx, fmt.Sprintf("file%d.txt", i)); !exists {
+			t.Errorf("file%d.txt missing", i)
+		}
+	}
+}
+
+func TestMoveStopsAtLimit(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeTenByteFiles(t, ctx, src, 3)
+
+	result, err := Move(ctx, src, dst, "", "", Options{Concurrency: 1, MaxTransferBytes: 10})
+	if !IsLimitError(err) {
+		t.Fatalf("Move error = %v, want *LimitError", err)
+	}
+	if result.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", result.Copied)
+	}
+	paths, _ := src.List(ctx, "")
+	if len(paths) != 3 {
+		t.Errorf("source has %d files, want 3 (none deleted)", len(paths))
+	}
+}
+
+func TestTreeCopyMaxTransferBytes(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeTenByteFiles(t, ctx, src, 4)
+
+	result, err := TreeCopy(ctx, src, dst, "", "backup", Options{MaxTransferBytes: 20})
+	var le *LimitError
+	if !errors.As(err, &le) {
+		t.Fatalf("TreeCopy error = %v, want *LimitError", err)
+	}
+	if result.Copied != 2 || le.Remaining != 2 {
+		t.Errorf("Copied = %d, Remaining = %d; want 2 and 2", result.Copied, le.Remaining)
+	}
+}
@@ -0,0 +1,34 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// deleteUnlocked deletes p with deleter, which is backend or a trash
+// wrapping it, unless p is locked in backend. A locked object fails with
+// an error matching omnistorage.ErrObjectLocked that names the lock:
+// deleting it would fail, or, in a versioned bucket, only hide it behind
+// a delete marker while the locked version stays. Only backends with
+// Features().ObjectLock are checked.
+func deleteUnlocked(ctx context.Context, backend, deleter omnistorage.Backend, p string) error {
+	if ext, ok := omnistorage.AsExtended(backend); ok && ext.Features().ObjectLock {
+		if locker, ok := backend.(omnistorage.ObjectLocker); ok {
+			lock, err := locker.ObjectLock(ctx, p)
+			if errors.Is(err, omnistorage.ErrNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if lock.LockedAt(time.Now()) {
+				return fmt.Errorf("%w: %s (%s)", omnistorage.ErrObjectLocked, p, lock)
+			}
+		}
+	}
+	return deleter.Delete(ctx, p)
+}
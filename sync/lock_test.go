@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// lockingBackend is a memory backend with Object Lock, where locks are
+// set directly in the locks map.
+type lockingBackend struct {
+	*memory.Backend
+	locks map[string]omnistorage.ObjectLock
+}
+
+func (b *lockingBackend) Features() omnistorage.Features {
+	f := b.Backend.Features()
+	f.ObjectLock = true
+	return f
+}
+
+func (b *lockingBackend) ObjectLock(ctx context.Context, p string) (omnistorage.ObjectLock, error) {
+	if _, err := b.Stat(ctx, p); err != nil {
+		return omnistorage.ObjectLock{}, err
+	}
+	return b.locks[p], nil
+}
+
+func (b *lockingBackend) SetRetention(ctx context.Context, p string, mode omnistorage.RetentionMode, until time.Time) error {
+	lock := b.locks[p]
+	lock.Mode, lock.RetainUntil = mode, until
+	b.locks[p] = lock
+	return nil
+}
+
+func (b *lockingBackend) SetLegalHold(ctx context.Context, p string, on bool) error {
+	lock := b.locks[p]
+	lock.LegalHold = on
+	b.locks[p] = lock
+	return nil
+}
+
+func TestSyncLockedDelete(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := &lockingBackend{Backend: memory.New(), locks: map[string]omnistorage.ObjectLock{}}
+	writeFile(t, ctx, dst.Backend, "held.txt", "held")
+	writeFile(t, ctx, dst.Backend, "retained.txt", "retained")
+	writeFile(t, ctx, dst.Backend, "expired.txt", "expired")
+	_ = dst.SetLegalHold(ctx, "held.txt", true)
+	_ = dst.SetRetention(ctx, "retained.txt", omnistorage.RetentionCompliance, time.Now().Add(time.Hour))
+	_ = dst.SetRetention(ctx, "expired.txt", omnistorage.RetentionGovernance, time.Now().Add(-time.Hour))
+
+	result, err := Sync(ctx, src, dst, "", "", Options{DeleteExtra: true})
+	if err != nil {
+		t.Fatalf("Sync error = %v", err)
+	}
+	if result.Deleted != 1 || len(result.Errors) != 2 {
+		t.Fatalf("Deleted = %d, Errors = %v; want 1 deleted, 2 errors", result.Deleted, result.Errors)
+	}
+	for _, fe := range result.Errors {
+		if !errors.Is(fe.Err, omnistorage.ErrObjectLocked) {
+			t.Errorf("error = %v, want ErrObjectLocked", fe)
+		}
+	}
+	verifyFile(t, ctx, dst.Backend, "held.txt", "held")
+	verifyFile(t, ctx, dst.Backend, "retained.txt", "retained")
+	if ok, _ := dst.Exists(ctx, "expired.txt"); ok {
+		t.Error("expired.txt was not deleted")
+	}
+
+	// Moves leave locked sources in place
+	writeFile(t, ctx, dst.Backend, "move.txt", "move")
+	_ = dst.SetLegalHold(ctx, "move.txt", true)
+	if err := MoveFile(ctx, dst, src, "move.txt", "move.txt"); !errors.Is(err, omnistorage.ErrObjectLocked) {
+		t.Errorf("MoveFile error = %v, want ErrObjectLocked", err)
+	}
+	verifyFile(t, ctx, dst.Backend, "move.txt", "move")
+}
@@ -0,0 +1,214 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// DefaultManifestPath is where Sync writes its manifest, relative to the
+// destination path, unless ManifestConfig.Path is set.
+const DefaultManifestPath = ".omnistorage-manifest.json"
+
+// ErrManifestSignature is returned by VerifyManifest when a manifest's
+// signature is not valid for the key.
+var ErrManifestSignature = errors.New("invalid manifest signature")
+
+// ManifestConfig configures the signed manifest Sync writes of the files
+// it transferred (see Options.Manifest).
+type ManifestConfig struct {
+	// Key signs the manifest. Required.
+	Key ed25519.PrivateKey
+
+	// Path is the manifest's path, relative to the destination path.
+	// Default: DefaultManifestPath. The file is ignored in the
+	// destination, so it is neither compared nor deleted by DeleteExtra.
+	Path string
+
+	// Source identifies the source in the manifest, such as
+	// "s3://builds/app/1.4.2" or a build ID. Default: the source path.
+	Source string
+}
+
+// path returns the manifest's path relative to the destination path.
+func (c *ManifestConfig) path() string {
+	if c.Path == "" {
+		return DefaultManifestPath
+	}
+	return c.Path
+}
+
+// Manifest records the files transferred by a sync run.
+type Manifest struct {
+	// Source identifies where the files came from (see
+	// ManifestConfig.Source).
+	Source string `json:"source"`
+
+	// Destination is the destination path of the run.
+	Destination string `json:"destination"`
+
+	// Created is when the run started.
+	Created time.Time `json:"created"`
+
+	// Files lists the files transferred, sorted by path, with paths
+	// relative to the destination path.
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a file in a Manifest.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Hashes returns the SHA-256 hashes of the manifest's files, such as for
+// a filter.ExcludeHashes rule that skips content already transferred.
+func (m *Manifest) Hashes() []string {
+	hashes := make([]string, len(m.Files))
+	for i, f := range m.Files {
+		hashes[i] = f.SHA256
+	}
+	return hashes
+}
+
+// signedManifest is the stored form of a manifest: its JSON encoding and
+// the ed25519 signature of exactly those bytes.
+type signedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature []byte          `json:"signature"`
+}
+
+// manifestFile returns the entry of the file at full, with the SHA-256
+// the backend reports, or computed by reading the file, so the manifest
+// describes what landed rather than what was sent.
+func manifestFile(ctx context.Context, backend omnistorage.Backend, full, relPath string) (ManifestFile, error) {
+	f := ManifestFile{Path: relPath}
+	info := statSource(ctx, backend, full)
+	if sum := sourceChecksums(info).Get(omnistorage.HashSHA256); sum != "" {
+		f.Size = info.Size()
+		f.SHA256 = strings.ToLower(sum)
+		return f, nil
+	}
+	r, err := backend.NewReader(ctx, full)
+	if err != nil {
+		return f, err
+	}
+	defer func() { _ = r.Close() }()
+	h := sha256.New()
+	if f.Size, err = io.Copy(h, r); err != nil {
+		return f, err
+	}
+	f.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return f, nil
+}
+
+// write signs m with the configured key and writes it to dst.
+func (c *ManifestConfig) write(ctx context.Context, dst omnistorage.Backend, dstPath string, m *Manifest) error {
+	if len(c.Key) != ed25519.PrivateKeySize {
+		return errors.New("manifest: invalid ed25519 private key")
+	}
+	slices.SortFunc(m.Files, func(a, b ManifestFile) int { return strings.Compare(a.Path, b.Path) })
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(signedManifest{Manifest: body, Signature: ed25519.Sign(c.Key, body)})
+	if err != nil {
+		return err
+	}
+	return copyFileWithReader(ctx, dst, bytes.NewReader(data), path.Join(dstPath, c.path()), "application/json")
+}
+
+// ReadManifest reads the signed manifest at p and checks its signature
+// with key. It returns ErrManifestSignature if the signature is not
+// valid, so the manifest cannot be trusted.
+func ReadManifest(ctx context.Context, backend omnistorage.Backend, p string, key ed25519.PublicKey) (*Manifest, error) {
+	r, err := backend.NewReader(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	var signed signedManifest
+	if err := json.NewDecoder(r).Decode(&signed); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", p, err)
+	}
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, signed.Manifest, signed.Signature) {
+		return nil, fmt.Errorf("%w: %s", ErrManifestSignature, p)
+	}
+	var m Manifest
+	if err := json.Unmarshal(signed.Manifest, &m); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", p, err)
+	}
+	return &m, nil
+}
+
+// ManifestVerification contains the results of VerifyManifest.
+type ManifestVerification struct {
+	// Manifest is the verified manifest.
+	Manifest *Manifest
+
+	// Verified is the number of files that match the manifest.
+	Verified int
+
+	// Missing lists files in the manifest that do not exist.
+	Missing []string
+
+	// Mismatched lists files whose size or SHA-256 differ from the
+	// manifest.
+	Mismatched []string
+
+	// Errors contains files that could not be checked.
+	Errors []FileError
+}
+
+// Success returns true if every file in the manifest matches it.
+func (v *ManifestVerification) Success() bool {
+	return len(v.Missing) == 0 && len(v.Mismatched) == 0 && len(v.Errors) == 0
+}
+
+// VerifyManifest checks the manifest Sync wrote under root, at p relative
+// to it ("" for DefaultManifestPath): that it was signed with the private
+// key of key, and that each file it lists is under root with the recorded
+// size and SHA-256. Files not in the manifest are not checked. A manifest
+// with an invalid signature returns ErrManifestSignature.
+func VerifyManifest(ctx context.Context, backend omnistorage.Backend, root, p string, key ed25519.PublicKey) (*ManifestVerification, error) {
+	if p == "" {
+		p = DefaultManifestPath
+	}
+	m, err := ReadManifest(ctx, backend, path.Join(root, p), key)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &ManifestVerification{Manifest: m}
+	for _, want := range m.Files {
+		if err := ctx.Err(); err != nil {
+			return v, err
+		}
+		got, err := manifestFile(ctx, backend, path.Join(root, want.Path), want.Path)
+		switch {
+		case errors.Is(err, omnistorage.ErrNotFound):
+			v.Missing = append(v.Missing, want.Path)
+		case err != nil:
+			v.Errors = append(v.Errors, FileError{Path: want.Path, Op: "verify", Err: err})
+		case got.Size != want.Size || got.SHA256 != want.SHA256:
+			v.Mismatched = append(v.Mismatched, want.Path)
+		default:
+			v.Verified++
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestSyncManifest(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, dst := memory.New(), memory.New()
+	writeFile(t, ctx, src, "build/app.tar", "app")
+	writeFile(t, ctx, src, "build/docs/index.html", "docs")
+
+	opts := Options{DeleteExtra: true, Manifest: &ManifestConfig{Key: priv, Source: "ci/build/42"}}
+	result, err := Sync(ctx, src, dst, "build", "release", opts)
+	if err != nil || !result.Success() {
+		t.Fatalf("Sync = %+v, %v", result, err)
+	}
+
+	v, err := VerifyManifest(ctx, dst, "release", "", pub)
+	if err != nil {
+		t.Fatalf("VerifyManifest error = %v", err)
+	}
+	if !v.Success() || v.Verified != 2 {
+		t.Errorf("VerifyManifest = %+v, want 2 verified", v)
+	}
+	m := v.Manifest
+	paths := []string{m.Files[0].Path, m.Files[1].Path}
+	if m.Source != "ci/build/42" || m.Destination != "release" || !slices.Equal(paths, []string{"app.tar", "docs/index.html"}) {
+		t.Errorf("manifest = %+v", m)
+	}
+	sum := sha256.Sum256([]byte("app"))
+	if m.Files[0].Size != 3 || m.Files[0].SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("app.tar = %+v", m.Files[0])
+	}
+	if hashes := m.Hashes(); len(hashes) != 2 || hashes[0] != m.Files[0].SHA256 {
+		t.Errorf("Hashes() = %v", hashes)
+	}
+
+	// A second run transfers nothing; the manifest is not deleted or synced
+	result, err = Sync(ctx, src, dst, "build", "release", opts)
+	if err != nil || result.Deleted != 0 || result.Copied != 0 {
+		t.Fatalf("second Sync = %+v, %v", result, err)
+	}
+	if v, _ := VerifyManifest(ctx, dst, "release", "", pub); v == nil || len(v.Manifest.Files) != 0 {
+		t.Errorf("second manifest = %+v", v)
+	}
+
+	// Tampering is detected
+	writeFile(t, ctx, src, "build/app.tar", "app v2")
+	if _, err := Sync(ctx, src, dst, "build", "release", opts); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, ctx, dst, "release/app.tar", "evil")
+	v, err = VerifyManifest(ctx, dst, "release", "", pub)
+	if err != nil || !slices.Equal(v.Mismatched, []string{"app.tar"}) {
+		t.Errorf("VerifyManifest after tampering = %+v, %v", v, err)
+	}
+	if err := dst.Delete(ctx, "release/app.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := VerifyManifest(ctx, dst, "release", "", pub); !slices.Equal(v.Missing, []string{"app.tar"}) {
+		t.Errorf("Missing = %v", v.Missing)
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, err := VerifyManifest(ctx, dst, "release", "", other); !errors.Is(err, ErrManifestSignature) {
+		t.Errorf("VerifyManifest with another key error = %v, want ErrManifestSignature", err)
+	}
+
+	data := readBackend(t, dst, "release/"+DefaultManifestPath)
+	writeFile(t, ctx, dst, "release/"+DefaultManifestPath, strings.Replace(data, "app.tar", "app.exe", 1))
+	if _, err := VerifyManifest(ctx, dst, "release", "", pub); !errors.Is(err, ErrManifestSignature) {
+		t.Errorf("VerifyManifest of an edited manifest error = %v, want ErrManifestSignature", err)
+	}
+}
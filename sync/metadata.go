@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"mime"
+	"path"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/sync/filter"
+)
+
+// MetadataUpdate is the new metadata of a file for UpdateMetadata.
+type MetadataUpdate struct {
+	// Metadata replaces the file's custom metadata. Nil or empty removes
+	// it.
+	Metadata map[string]string
+
+	// ContentType is the new content type. Empty keeps the current one.
+	ContentType string
+}
+
+// UpdateMetadataOptions configures UpdateMetadata.
+type UpdateMetadataOptions struct {
+	// Update returns the new metadata of the file at path, relative to
+	// the UpdateMetadata prefix, given its current info, and false if the
+	// file should be left alone. Required.
+	Update func(path string, info omnistorage.ObjectInfo) (MetadataUpdate, bool)
+
+	// Filter limits the files considered.
+	Filter *filter.Filter
+
+	// DryRun reports what would be updated without making changes.
+	DryRun bool
+}
+
+// UpdateMetadataResult contains the results of UpdateMetadata.
+type UpdateMetadataResult struct {
+	// Updated lists the paths updated, or that would be in a dry run,
+	// relative to the UpdateMetadata prefix.
+	Updated []string
+
+	// Unchanged is the number of files Update left alone.
+	Unchanged int
+
+	// Errors contains any errors that occurred.
+	Errors []FileError
+
+	// DryRun indicates if this was a dry run.
+	DryRun bool
+}
+
+// UpdateMetadata sets the metadata and content type of the files under
+// prefix in place, as opts.Update returns them, without rewriting their
+// content. Use it to fix the content types or tags of objects already
+// uploaded.
+//
+// The backend must implement omnistorage.ExtendedBackend and
+// omnistorage.MetadataSetter.
+func UpdateMetadata(ctx context.Context, backend omnistorage.Backend, prefix string, opts UpdateMetadataOptions) (*UpdateMetadataResult, error) {
+	if opts.Update == nil {
+		return nil, fmt.Errorf("update metadata: Update is required")
+	}
+	ext, ok := omnistorage.AsExtended(backend)
+	if !ok {
+		return nil, fmt.Errorf("update metadata: %w", omnistorage.ErrNotSupported)
+	}
+	setter, ok := backend.(omnistorage.MetadataSetter)
+	if !ok {
+		return nil, fmt.Errorf("update metadata: %w", omnistorage.ErrNotSupported)
+	}
+
+	files, err := listFiles(ctx, backend, prefix, Options{Filter: opts.Filter})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpdateMetadataResult{DryRun: opts.DryRun}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if f.IsDir {
+			continue
+		}
+		full := path.Join(prefix, f.Path)
+		info, err := ext.Stat(ctx, full)
+		if err != nil {
+			result.Errors = append(result.Errors, FileError{Path: f.Path, Op: "stat", Err: err})
+			continue
+		}
+		update, ok := opts.Update(f.Path, info)
+		if !ok {
+			result.Unchanged++
+			continue
+		}
+		if !opts.DryRun {
+			if err := setter.SetMetadata(ctx, full, update.Metadata, update.ContentType); err != nil {
+				result.Errors = append(result.Errors, FileError{Path: f.Path, Op: "set metadata", Err: err})
+				continue
+			}
+		}
+		result.Updated = append(result.Updated, f.Path)
+	}
+
+	return result, nil
+}
+
+// ContentTypeFromExtension is an UpdateMetadataOptions.Update that sets
+// the content type of each file to the one registered for its extension,
+// keeping its custom metadata. Files with an unknown extension or the
+// right content type are left alone.
+func ContentTypeFromExtension(p string, info omnistorage.ObjectInfo) (MetadataUpdate, bool) {
+	contentType := mime.TypeByExtension(path.Ext(p))
+	if contentType == "" || contentType == info.ContentType() {
+		return MetadataUpdate{}, false
+	}
+	return MetadataUpdate{Metadata: maps.Clone(info.Metadata()), ContentType: contentType}, true
+}
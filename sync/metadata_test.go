@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/file"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestUpdateMetadata(t *testing.T) {
+	ctx := context.Background()
+	cfg := file.DefaultConfig()
+	cfg.Root = t.TempDir()
+	cfg.Metadata = file.MetadataSidecar
+	backend := file.New(cfg)
+	defer func() { _ = backend.Close() }()
+
+	for _, p := range []string{"site/index.html", "site/app.js", "site/README"} {
+		w, err := backend.NewWriter(ctx, p,
+			omnistorage.WithContentType("application/octet-stream"),
+			omnistorage.WithMetadata(map[string]string{"owner": "web"}))
+		if err != nil {
+			t.Fatalf("NewWriter(%s) error = %v", p, err)
+		}
+		_, _ = w.Write([]byte("body"))
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close(%s) error = %v", p, err)
+		}
+	}
+
+	opts := UpdateMetadataOptions{Update: ContentTypeFromExtension, DryRun: true}
+	result, err := UpdateMetadata(ctx, backend, "site", opts)
+	if err != nil {
+		t.Fatalf("UpdateMetadata error = %v", err)
+	}
+	if len(result.Updated) != 2 || result.Unchanged != 1 {
+		t.Fatalf("dry run Updated = %v, Unchanged = %d; want 2 updated, 1 unchanged", result.Updated, result.Unchanged)
+	}
+	if info, _ := backend.Stat(ctx, "site/index.html"); info.ContentType() != "application/octet-stream" {
+		t.Errorf("dry run changed content type to %q", info.ContentType())
+	}
+
+	opts.DryRun = false
+	result, err = UpdateMetadata(ctx, backend, "site", opts)
+	if err != nil {
+		t.Fatalf("UpdateMetadata error = %v", err)
+	}
+	slices.Sort(result.Updated)
+	if !slices.Equal(result.Updated, []string{"app.js", "index.html"}) || len(result.Errors) != 0 {
+		t.Fatalf("Updated = %v, Errors = %v", result.Updated, result.Errors)
+	}
+	info, err := backend.Stat(ctx, "site/index.html")
+	if err != nil {
+		t.Fatalf("Stat error = %v", err)
+	}
+	if info.ContentType() != "text/html; charset=utf-8" || info.Metadata()["owner"] != "web" {
+		t.Errorf("ContentType = %q, Metadata = %v", info.ContentType(), info.Metadata())
+	}
+
+	// A second run finds nothing to fix
+	result, err = UpdateMetadata(ctx, backend, "site", opts)
+	if err != nil {
+		t.Fatalf("UpdateMetadata error = %v", err)
+	}
+	if len(result.Updated) != 0 || result.Unchanged != 3 {
+		t.Errorf("second run Updated = %v, Unchanged = %d", result.Updated, result.Unchanged)
+	}
+}
+
+func TestUpdateMetadataNotSupported(t *testing.T) {
+	ctx := context.Background()
+	opts := UpdateMetadataOptions{Update: ContentTypeFromExtension}
+	if _, err := UpdateMetadata(ctx, memory.New(), "", opts); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("UpdateMetadata error = %v, want ErrNotSupported", err)
+	}
+	if _, err := UpdateMetadata(ctx, memory.New(), "", UpdateMetadataOptions{}); err == nil {
+		t.Error("UpdateMetadata without Update succeeded")
+	}
+}
@@ -0,0 +1,218 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	gosync "sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// MultiOptions configures Multi.
+type MultiOptions struct {
+	// Options configures each sync between the hub and a spoke.
+	Options Options
+
+	// HubPath is the path synced in the hub.
+	HubPath string
+
+	// SpokePath is the path synced in each spoke.
+	SpokePath string
+
+	// Parallel is the number of spokes synced at once. Default is 4.
+	// Each sync also runs Options.Concurrency transfers.
+	Parallel int
+
+	// Collect copies files that are new or newer in a spoke to the hub
+	// before the hub is propagated, so changes made at any spoke reach
+	// every other spoke. A file changed in several spokes is taken from
+	// the one with the newest modification time. Files are never
+	// deleted from the hub. Collect cannot be combined with
+	// Options.PathTransform.
+	Collect bool
+}
+
+// SpokeResult is the outcome of Multi for one spoke.
+type SpokeResult struct {
+	// Collected is the result of copying files from the spoke to the
+	// hub, or nil if MultiOptions.Collect is false.
+	Collected *Result
+
+	// Result is the result of syncing the hub to the spoke, or nil if
+	// the sync failed before it started.
+	Result *Result
+
+	// Err is the error that stopped the collection or sync, if any.
+	Err error
+}
+
+// MultiResult contains the results of Multi.
+type MultiResult struct {
+	// Spokes holds the result for each spoke, in the order given.
+	Spokes []SpokeResult
+
+	// Duration is how long Multi took.
+	Duration time.Duration
+}
+
+// Success returns true if every spoke synced without errors.
+func (r *MultiResult) Success() bool {
+	for _, s := range r.Spokes {
+		if s.Err != nil || (s.Result != nil && !s.Result.Success()) || (s.Collected != nil && !s.Collected.Success()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Total returns the sum of the results of the syncs to the spokes.
+func (r *MultiResult) Total() Result {
+	var total Result
+	for _, s := range r.Spokes {
+		if s.Result == nil {
+			continue
+		}
+		total.Copied += s.Result.Copied
+		total.Updated += s.Result.Updated
+		total.Deleted += s.Result.Deleted
+		total.DirsCreated += s.Result.DirsCreated
+		total.Skipped += s.Result.Skipped
+		total.Resumed += s.Result.Resumed
+		total.Errors = append(total.Errors, s.Result.Errors...)
+		total.BytesTransferred += s.Result.BytesTransferred
+		total.DeltaSaved += s.Result.DeltaSaved
+		total.DryRun = s.Result.DryRun
+	}
+	total.Duration = r.Duration
+	return total
+}
+
+// Multi syncs hub to each of spokes, as Sync would, replicating a dataset
+// to several backends such as regional buckets in one call. With
+// opts.Collect, files changed at the spokes are first copied back to the
+// hub.
+//
+// A failing spoke does not stop the others, and a spoke that could not be
+// collected from is not synced, so its changes are not overwritten. Each
+// spoke's error is in its SpokeResult, and Multi returns the errors of all
+// spokes joined with errors.Join.
+func Multi(ctx context.Context, hub omnistorage.Backend, spokes []omnistorage.Backend, opts MultiOptions) (*MultiResult, error) {
+	startTime := time.Now()
+	if opts.Collect && opts.Options.PathTransform != nil {
+		return nil, fmt.Errorf("multi: Collect cannot be used with PathTransform")
+	}
+	if opts.Parallel <= 0 {
+		opts.Parallel = 4
+	}
+
+	result := &MultiResult{Spokes: make([]SpokeResult, len(spokes))}
+	if opts.Collect {
+		if err := collect(ctx, hub, spokes, opts, result); err != nil {
+			result.Duration = time.Since(startTime)
+			return result, err
+		}
+	}
+
+	sem := make(chan struct{}, opts.Parallel)
+	var wg gosync.WaitGroup
+	for i, spoke := range spokes {
+		if result.Spokes[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				result.Spokes[i].Err = ctx.Err()
+				return
+			}
+			r, err := Sync(ctx, hub, spoke, opts.HubPath, opts.SpokePath, opts.Options)
+			result.Spokes[i].Result = r
+			result.Spokes[i].Err = err
+		}()
+	}
+	wg.Wait()
+
+	result.Duration = time.Since(startTime)
+	var errs []error
+	for i, s := range result.Spokes {
+		if s.Err != nil {
+			errs = append(errs, fmt.Errorf("spoke %d: %w", i, s.Err))
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// collect copies files that are new or newer in the spokes to the hub.
+// Spoke errors are recorded in result; the error returned is ctx's.
+func collect(ctx context.Context, hub omnistorage.Backend, spokes []omnistorage.Backend, opts MultiOptions, result *MultiResult) error {
+	collectOpts := opts.Options
+	collectOpts.DeleteExtra = false
+	hubExt, hubHasExt := omnistorage.AsExtended(hub)
+
+	// The spoke with the newest version of each file wins
+	type candidate struct {
+		spoke   int
+		modTime time.Time
+	}
+	winners := make(map[string]candidate)
+	plans := make([]*ActionPlan, len(spokes))
+	for i, spoke := range spokes {
+		plan, err := Plan(ctx, spoke, hub, opts.SpokePath, opts.HubPath, collectOpts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.Spokes[i].Err = fmt.Errorf("collect: %w", err)
+			continue
+		}
+		plans[i] = plan
+		for _, a := range plan.Actions {
+			switch a.Type {
+			case ActionCopy:
+			case ActionUpdate:
+				// Only newer files replace the hub's
+				if !hubHasExt {
+					continue
+				}
+				info, err := hubExt.Stat(ctx, path.Join(opts.HubPath, a.destPath()))
+				if err != nil || !a.File.ModTime.After(info.ModTime()) {
+					continue
+				}
+			default:
+				continue
+			}
+			if w, ok := winners[a.destPath()]; !ok || a.File.ModTime.After(w.modTime) {
+				winners[a.destPath()] = candidate{spoke: i, modTime: a.File.ModTime}
+			}
+		}
+	}
+
+	for i, plan := range plans {
+		if plan == nil {
+			continue
+		}
+		var actions []Action
+		for _, a := range plan.Actions {
+			if w, ok := winners[a.destPath()]; ok && w.spoke == i && (a.Type == ActionCopy || a.Type == ActionUpdate) {
+				actions = append(actions, a)
+			}
+		}
+		plan.Actions = actions
+		r, err := Apply(ctx, spokes[i], hub, plan, collectOpts)
+		result.Spokes[i].Collected = r
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.Spokes[i].Err = fmt.Errorf("collect: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestMulti(t *testing.T) {
+	ctx := context.Background()
+	hub := memory.New()
+	writeFile(t, ctx, hub, "data/a.txt", "a")
+	writeFile(t, ctx, hub, "data/b.txt", "b")
+
+	spokes := []omnistorage.Backend{memory.New(), memory.New(), memory.New()}
+	writeFile(t, ctx, spokes[1].(*memory.Backend), "replica/extra.txt", "extra")
+
+	result, err := Multi(ctx, hub, spokes, MultiOptions{
+		Options:   Options{DeleteExtra: true},
+		HubPath:   "data",
+		SpokePath: "replica",
+		Parallel:  2,
+	})
+	if err != nil {
+		t.Fatalf("Multi failed: %v", err)
+	}
+	if !result.Success() {
+		t.Errorf("Success() = false")
+	}
+	if total := result.Total(); total.Copied != 6 || total.Deleted != 1 {
+		t.Errorf("Total Copied = %d, Deleted = %d; want 6, 1", total.Copied, total.Deleted)
+	}
+	for i, spoke := range spokes {
+		if ok, _ := spoke.Exists(ctx, "replica/b.txt"); !ok {
+			t.Errorf("spoke %d is missing b.txt", i)
+		}
+	}
+}
+
+func TestMultiCollect(t *testing.T) {
+	ctx := context.Background()
+	hub := memory.New()
+	s0, s1 := memory.New(), memory.New()
+	writeFile(t, ctx, hub, "shared.txt", "hub")
+	writeFile(t, ctx, s0, "shared.txt", "hub")
+	writeFile(t, ctx, s1, "shared.txt", "hub")
+
+	// Both spokes change shared.txt; s1's change is newer
+	time.Sleep(10 * time.Millisecond)
+	writeFile(t, ctx, s0, "shared.txt", "from s0")
+	time.Sleep(10 * time.Millisecond)
+	writeFile(t, ctx, s1, "shared.txt", "from s1!")
+	writeFile(t, ctx, s0, "new.txt", "new at s0")
+
+	result, err := Multi(ctx, hub, []omnistorage.Backend{s0, s1}, MultiOptions{Collect: true})
+	if err != nil {
+		t.Fatalf("Multi failed: %v", err)
+	}
+	if c := result.Spokes[0].Collected; c == nil || c.Copied != 1 || c.Updated != 0 {
+		t.Errorf("spoke 0 Collected = %+v, want new.txt only", c)
+	}
+	for name, b := range map[string]*memory.Backend{"hub": hub, "s0": s0, "s1": s1} {
+		if got := readBackend(t, b, "shared.txt"); got != "from s1!" {
+			t.Errorf("%s shared.txt = %q, want %q", name, got, "from s1!")
+		}
+		if got := readBackend(t, b, "new.txt"); got != "new at s0" {
+			t.Errorf("%s new.txt = %q, want %q", name, got, "new at s0")
+		}
+	}
+}
+
+func TestMultiSpokeError(t *testing.T) {
+	ctx := context.Background()
+	hub := memory.New()
+	writeFile(t, ctx, hub, "a.txt", "a")
+	closed := memory.New()
+	_ = closed.Close()
+
+	result, err := Multi(ctx, hub, []omnistorage.Backend{memory.New(), closed}, MultiOptions{})
+	if !errors.Is(err, omnistorage.ErrBackendClosed) {
+		t.Fatalf("Multi error = %v, want ErrBackendClosed", err)
+	}
+	if result.Spokes[0].Err != nil || result.Spokes[0].Result.Copied != 1 {
+		t.Errorf("spoke 0 = %+v, want a successful copy", result.Spokes[0])
+	}
+	if result.Spokes[1].Err == nil || result.Success() {
+		t.Errorf("spoke 1 Err = %v, Success = %v; want an error", result.Spokes[1].Err, result.Success())
+	}
+}
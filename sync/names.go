@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is the Unicode normalization form in which Sync compares
+// and writes paths.
+type Normalization string
+
+const (
+	// NormalizeNone compares paths byte for byte.
+	NormalizeNone Normalization = ""
+
+	// NormalizeNFC uses the composed form used by Linux, Windows and
+	// most object stores.
+	NormalizeNFC Normalization = "nfc"
+
+	// NormalizeNFD uses the decomposed form used by macOS filesystems.
+	NormalizeNFD Normalization = "nfd"
+)
+
+// Collision is a set of source files whose paths map to the same
+// destination path under Options.PathTransform, CaseInsensitive or
+// Normalize. Only the first, in listing order, is synced.
+type Collision struct {
+	// DstPath is the destination path, relative to the destination
+	// root.
+	DstPath string `json:"dst_path"`
+
+	// Paths are the colliding source paths, the synced one first.
+	Paths []string `json:"paths"`
+}
+
+// checkNames returns an error if Options.Normalize is not supported.
+func (o Options) checkNames() error {
+	switch o.Normalize {
+	case NormalizeNone, NormalizeNFC, NormalizeNFD:
+		return nil
+	default:
+		return fmt.Errorf("unknown normalization: %q", o.Normalize)
+	}
+}
+
+// normalize returns p in the form set by Options.Normalize.
+func (o Options) normalize(p string) string {
+	switch o.Normalize {
+	case NormalizeNFC:
+		return norm.NFC.String(p)
+	case NormalizeNFD:
+		return norm.NFD.String(p)
+	default:
+		return p
+	}
+}
+
+// pathKey returns the key under which source and destination paths are
+// matched: p normalized and, with CaseInsensitive, case-folded.
+func (o Options) pathKey(p string) string {
+	p = o.normalize(p)
+	if o.CaseInsensitive {
+		p = strings.ToLower(strings.ToUpper(p))
+	}
+	return p
+}
+
+// pathIndex looks up destination files by path key.
+type pathIndex struct {
+	opts  Options
+	files map[string][]FileInfo
+}
+
+func newPathIndex(files []FileInfo, opts Options) *pathIndex {
+	idx := &pathIndex{opts: opts, files: make(map[string][]FileInfo, len(files))}
+	for _, f := range files {
+		k := opts.pathKey(f.Path)
+		idx.files[k] = append(idx.files[k], f)
+	}
+	return idx
+}
+
+// take removes and returns the file matching p, preferring an exact
+// match over one that differs in case or normalization.
+func (idx *pathIndex) take(p string) (FileInfo, bool) {
+	k := idx.opts.pathKey(p)
+	files := idx.files[k]
+	if len(files) == 0 {
+		return FileInfo{}, false
+	}
+	i := 0
+	for j, f := range files {
+		if f.Path == p {
+			i = j
+			break
+		}
+	}
+	f := files[i]
+	if len(files) == 1 {
+		delete(idx.files, k)
+	} else {
+		idx.files[k] = append(files[:i:i], files[i+1:]...)
+	}
+	return f, true
+}
+
+// rest returns the files not taken.
+func (idx *pathIndex) rest() []FileInfo {
+	var files []FileInfo
+	for _, fs := range idx.files {
+		files = append(files, fs...)
+	}
+	return files
+}
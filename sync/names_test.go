@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+const (
+	nfcName = "caf\u00e9.txt"  // é as one code point
+	nfdName = "cafe\u0301.txt" // e followed by a combining acute accent
+)
+
+func TestSyncNormalize(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, nfdName, "content")
+	writeFile(t, ctx, src, "new-"+nfdName, "content")
+	writeFile(t, ctx, dst, nfcName, "old")
+
+	// Without normalization, the NFD name is a different file
+	plan, err := Plan(ctx, src, dst, "", "", Options{DeleteExtra: true, SizeOnly: true})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if plan.Count(ActionCopy) != 2 || plan.Count(ActionDelete) != 1 {
+		t.Errorf("unnormalized plan = %+v, want 2 copies and 1 delete", plan.Actions)
+	}
+
+	result, err := Sync(ctx, src, dst, "", "", Options{DeleteExtra: true, SizeOnly: true, Normalize: NormalizeNFC})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 1 || result.Updated != 1 || result.Deleted != 0 {
+		t.Errorf("Copied = %d, Updated = %d, Deleted = %d, want 1, 1, 0", result.Copied, result.Updated, result.Deleted)
+	}
+	verifyFile(t, ctx, dst, nfcName, "content")
+	verifyFile(t, ctx, dst, "new-"+nfcName, "content")
+	if exists, _ := dst.Exists(ctx, nfdName); exists {
+		t.Error("NFD name was written to the destination")
+	}
+
+	// A second run is a no-op rather than an endless re-copy
+	result, err = Sync(ctx, src, dst, "", "", Options{DeleteExtra: true, Normalize: NormalizeNFC})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied+result.Updated+result.Deleted != 0 {
+		t.Errorf("second run changed files: %+v", result)
+	}
+}
+
+func TestSyncCaseInsensitive(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "Docs/README.md", "new readme")
+	writeFile(t, ctx, src, "photo.JPG", "photo")
+	writeFile(t, ctx, src, "photo.jpg", "other photo")
+	writeFile(t, ctx, dst, "docs/readme.md", "old")
+
+	result, err := Sync(ctx, src, dst, "", "", Options{DeleteExtra: true, CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 1 || result.Updated != 1 || result.Deleted != 0 {
+		t.Errorf("Copied = %d, Updated = %d, Deleted = %d, want 1, 1, 0", result.Copied, result.Updated, result.Deleted)
+	}
+	verifyFile(t, ctx, dst, "docs/readme.md", "new readme")
+	verifyFile(t, ctx, dst, "photo.JPG", "photo")
+
+	if len(result.Collisions) != 1 {
+		t.Fatalf("Collisions = %+v, want 1", result.Collisions)
+	}
+	c := result.Collisions[0]
+	if c.DstPath != "photo.JPG" || len(c.Paths) != 2 || c.Paths[0] != "photo.JPG" || c.Paths[1] != "photo.jpg" {
+		t.Errorf("Collision = %+v, want photo.JPG and photo.jpg to photo.JPG", c)
+	}
+}
+
+func TestCheckCaseInsensitive(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "A.txt", "same")
+	writeFile(t, ctx, dst, "a.txt", "same")
+
+	result, err := Check(ctx, src, dst, "", "", Options{CaseInsensitive: true, IgnoreTime: true})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !result.InSync() {
+		t.Errorf("Check = %+v, want in sync", result)
+	}
+}
+
+func TestUnknownNormalization(t *testing.T) {
+	ctx := context.Background()
+	if _, err := Plan(ctx, memory.New(), memory.New(), "", "", Options{Normalize: "nfkc"}); err == nil {
+		t.Error("Plan with unknown normalization should fail")
+	}
+}
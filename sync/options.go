@@ -23,11 +23,15 @@
 package sync
 
 import (
+	"io"
 	"log/slog"
 	"time"
 
 	"github.com/grokify/mogo/log/slogutil"
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/metrics"
 	"github.com/grokify/omnistorage/sync/filter"
+	"github.com/grokify/omnistorage/trash"
 	"github.com/grokify/oscompat/tsync"
 )
 
@@ -49,6 +53,21 @@ type Options struct {
 	// Useful for resuming interrupted syncs.
 	IgnoreExisting bool
 
+	// NoOverwrite never replaces files that exist in the destination,
+	// like cp -n. Like IgnoreExisting, it skips existing files when
+	// planning; unlike it, Apply also checks each new file just before
+	// writing it, so a file created in the destination since it was
+	// listed, or a plan made without NoOverwrite, never clobbers one.
+	// Deletes are not affected.
+	NoOverwrite bool
+
+	// UpdateOnly replaces a destination file only if the source is
+	// strictly newer, like rsync -u, so changes made in the destination
+	// are kept. Files that differ but are not newer in the source are
+	// skipped, as are files whose modification times are unknown.
+	// Times are compared with the tolerance NeedsUpdate uses.
+	UpdateOnly bool
+
 	// IgnoreSize ignores size when comparing files.
 	// Only compares modification time (or checksum if Checksum is true).
 	IgnoreSize bool
@@ -64,6 +83,11 @@ type Options struct {
 	// Can be nil if progress updates aren't needed.
 	Progress func(Progress)
 
+	// Tracker, if set, keeps the live state of the run's transfers, per
+	// worker and in total, for Tracker.Stats: each worker's file, bytes,
+	// speed and ETA, the queue depth and the error counts.
+	Tracker *Tracker
+
 	// MaxErrors is the maximum number of errors before aborting.
 	// 0 means abort on first error.
 	MaxErrors int
@@ -72,6 +96,14 @@ type Options struct {
 	// Default is 4.
 	Concurrency int
 
+	// Adaptive, if set, adapts the number of concurrent transfers to
+	// what the backends sustain, up to Concurrency. When a transfer is
+	// throttled, such as by S3 SlowDown, HTTP 429 or SFTP EAGAIN, the
+	// limit is halved, no transfers start for a backoff period, and the
+	// file is tried again instead of failing; the limit grows back by one
+	// as transfers succeed. Set it to &AdaptiveConfig{} for the defaults.
+	Adaptive *AdaptiveConfig
+
 	// Filter specifies which files to include/exclude from sync.
 	// If nil, all files are included.
 	Filter *filter.Filter
@@ -80,15 +112,197 @@ type Options struct {
 	// Only applies when DeleteExtra is true.
 	DeleteExcluded bool
 
+	// TrashPrefix, if set, moves files deleted by DeleteExtra under this
+	// prefix of the destination backend instead of removing them, in a
+	// directory named after the start of the run, as trash.New with
+	// trash.WithPrefix does. Files under the prefix are ignored in the
+	// destination. Restore them with the trash package.
+	TrashPrefix string
+
+	// CreateEmptyDirs creates directories that are empty in the source in
+	// the destination. It requires a source that implements
+	// omnistorage.DirLister and a destination that supports Mkdir
+	// (Features().Mkdir); otherwise no directories are created. Filters
+	// apply to files only, so a directory whose files are all excluded is
+	// created empty.
+	CreateEmptyDirs bool
+
+	// CaseInsensitive matches source and destination paths regardless of
+	// case, for destinations such as macOS and Windows filesystems where
+	// "A.txt" and "a.txt" are the same file. Existing destination files
+	// keep their names; source files that differ only in case are
+	// reported in Result.Collisions and only the first is synced.
+	CaseInsensitive bool
+
+	// Normalize matches source and destination paths after Unicode
+	// normalization, so a name written in NFD on macOS and in NFC
+	// elsewhere is the same file, and names new destination files in that
+	// form. Existing destination files keep their names.
+	Normalize Normalization
+
+	// PathTransform, if set, maps each source path, relative to the
+	// source root, to its destination path, for example to flatten
+	// directories, add a date prefix or lowercase keys. Returning "" skips
+	// the file. Destination files are matched and extra files found by
+	// transformed path, and source files that map to the same path are
+	// reported in Result.Collisions. See AddPrefix, AddSuffix, Rewrite,
+	// Flatten and ChainTransforms.
+	PathTransform func(string) string
+
+	// TransformReader, if set, is called for every file copied through
+	// the client with its source path and content, and returns the
+	// content to write, for streaming transforms such as compression,
+	// redaction or re-encoding. If the returned reader implements
+	// io.Closer, it is closed after the copy. Server-side copies are not
+	// used while it is set. Transformed files usually differ in size from
+	// their source, so set IgnoreSize to avoid copying them again on every
+	// run.
+	TransformReader func(path string, r io.Reader) io.Reader
+
+	// DeltaTransfer updates destination files in place, writing only the
+	// blocks that differ from the source, when the destination implements
+	// omnistorage.Patcher and the file is at least DeltaMinSize, so
+	// appending to a large log rewrites only its tail. The source is
+	// searched for the destination's blocks with rsync's rolling weak
+	// checksum, confirmed by SHA-256. Blocks found at their own offset are
+	// not written; blocks that moved must still be written at their new
+	// offset, so data inserted mid-file rewrites the rest of it. The
+	// destination is read to compute its block checksums unless
+	// DeltaSignatures holds them. Updated files keep the destination's
+	// metadata, and the update is not atomic.
+	DeltaTransfer bool
+
+	// DeltaMinSize is the smallest destination file updated by a delta
+	// transfer. Default is DefaultDeltaMinSize (16 MiB).
+	DeltaMinSize int64
+
+	// DeltaSignatures, if set, stores the block checksums of each file
+	// updated by a delta transfer, at its destination path plus
+	// ".delta.json", so the next delta transfer of the file reads only
+	// the source. A signature is used only while the destination file's
+	// size and modification time are unchanged.
+	DeltaSignatures omnistorage.Backend
+
+	// TransferCompression reads files compressed from sources that
+	// implement omnistorage.CompressedReader, such as SFTP with
+	// RemoteGzip, and decompresses them before writing, for copies where
+	// the link between the source and the client is the bottleneck, like
+	// SFTP to S3 through a laptop. Sources that cannot compress are read
+	// as usual.
+	TransferCompression bool
+
+	// TransferCompressionSkip reports whether a source path should be read
+	// uncompressed with TransferCompression. Default: IsCompressedFile,
+	// which skips types that are already compressed, such as .gz and .jpg.
+	TransferCompressionSkip func(path string) bool
+
+	// ParallelDownloadCutoff reads source files of at least this many
+	// bytes in concurrent ranged chunks, as omnistorage.NewParallelReader
+	// does, when the source supports range reads. A single stream caps
+	// the throughput of one large file; with S3 at around 80 MB/s.
+	// 0 disables parallel downloads.
+	ParallelDownloadCutoff int64
+
+	// ParallelDownload configures the chunk size and concurrency of
+	// parallel downloads. Each file being copied reads up to
+	// ParallelDownload.Concurrency chunks at once.
+	ParallelDownload omnistorage.ParallelReadOptions
+
+	// StripeCutoff copies source files of at least this many bytes as
+	// stripes transferred concurrently, each read with a ranged read and
+	// written in place, when the source supports range reads and the
+	// destination implements omnistorage.Patcher. One huge file then
+	// keeps several transfers busy instead of one. Destinations that
+	// cannot write in place are served by ParallelDownloadCutoff, with
+	// S3 uploading the parts concurrently. 0 disables striping.
+	StripeCutoff int64
+
+	// StripeSize is the size of each stripe. Default: 64 MiB.
+	StripeSize int64
+
+	// Stripes is the number of stripes of a file transferred at once.
+	// Default: 4.
+	Stripes int
+
+	// SkipEmptyFiles ignores zero-byte files in source and destination, as
+	// if they were excluded by Filter. File sizes are only known for
+	// backends that implement ExtendedBackend.
+	SkipEmptyFiles bool
+
 	// BandwidthLimit is the maximum bytes per second for transfers.
 	// 0 means unlimited. The limit is shared across all concurrent transfers.
 	// Example: 1048576 for 1MB/s, or use filter.MB constant.
 	BandwidthLimit int64
 
+	// MaxTransferBytes caps the bytes copied in one run. 0 means unlimited.
+	// A file is not started if it would take the total over the cap; from
+	// then on no further files are started, files already in flight
+	// finish, extra files are not deleted, and the partial Result is
+	// returned with a *LimitError.
+	MaxTransferBytes int64
+
+	// MaxDuration caps how long a run keeps starting transfers. 0 means
+	// unlimited. Once it has elapsed, no further files are copied or
+	// deleted, files already in flight finish, and the partial Result is
+	// returned with a *LimitError. Use a context deadline to interrupt
+	// in-flight files as well.
+	MaxDuration time.Duration
+
+	// TransferOrder sets the order in which files are transferred. The
+	// default is listing order. With Concurrency above 1, it is the order
+	// in which transfers start.
+	TransferOrder TransferOrder
+
+	// Priority, if set, returns a priority for each file to transfer.
+	// Files with a higher priority are transferred first, so critical
+	// paths land before bulk data if the sync is interrupted; files with
+	// equal priority follow TransferOrder.
+	Priority func(FileInfo) int
+
 	// Retry configures retry behavior for failed file operations.
 	// If nil or MaxRetries is 0, operations are not retried.
 	Retry *RetryConfig
 
+	// FileTimeout bounds the work on each file: its Stat while listing,
+	// and its copy, including retries, or deletion. A file that exceeds
+	// it fails with an error matching omnistorage.ErrTimeout, and the
+	// sync goes on with the next file. Backends must observe the context
+	// for the bound to hold; set the backend's own operation timeout,
+	// such as sftp.Config.OpTimeout, for those that do not. 0 means no
+	// limit.
+	FileTimeout time.Duration
+
+	// Journal records each file copied, so that a run that crashed or
+	// was cancelled can be re-run with the same journal and skip the
+	// files already copied without comparing them with the destination,
+	// as long as their source size and modification time are unchanged.
+	// The journal is saved periodically and when the copies end. It is
+	// removed once a run completes without errors. Open it with
+	// OpenJournal. If nil, no journal is kept.
+	Journal *Journal
+
+	// Manifest, if set, writes a manifest of the files the run
+	// transferred, with their sizes and SHA-256 hashes as stored in the
+	// destination, the source's identity and the start time, signed with
+	// an ed25519 key, to the destination. Check it with VerifyManifest.
+	// The manifest replaces the previous run's, and is written even if
+	// some files failed, listing those that were transferred. It is not
+	// written in dry runs.
+	Manifest *ManifestConfig
+
+	// Hooks are called around file operations and can skip files or
+	// abort the sync. If nil, no hooks are called.
+	Hooks *Hooks
+
+	// Confirm, if set, is asked before each update, which overwrites a
+	// destination file, and each delete; the file is skipped unless it
+	// returns true. Use it to prompt like cp -i or to apply a policy,
+	// rather than allow all deletes with DeleteExtra. Confirm is called
+	// one action at a time, for all actions before any transfer starts,
+	// so prompts are never interleaved with transfers. It is not called
+	// in dry runs.
+	Confirm func(action Action) bool
+
 	// PreserveMetadata controls which metadata to preserve during sync.
 	// If nil, only content-type is preserved (default behavior).
 	PreserveMetadata *MetadataOptions
@@ -96,6 +310,10 @@ type Options struct {
 	// Logger is used for structured logging during sync operations.
 	// If nil, a null logger is used (no logging).
 	Logger *slog.Logger
+
+	// Metrics receives an event for every file copied, updated or deleted.
+	// Nothing is reported in dry-run mode. If nil, no metrics are recorded.
+	Metrics metrics.Collector
 }
 
 // logger returns the configured logger or a null logger if none is set.
@@ -106,6 +324,34 @@ func (o Options) logger() *slog.Logger {
 	return slogutil.Null()
 }
 
+// deleter returns the backend through which extra files are deleted from
+// dst: dst itself, or its trash if TrashPrefix is set. start names the
+// trash directory, so a run's deletes are kept together.
+func (o Options) deleter(dst omnistorage.Backend, start time.Time) omnistorage.Backend {
+	if o.TrashPrefix == "" {
+		return dst
+	}
+	return trash.New(dst, trash.WithPrefix(o.TrashPrefix), trash.WithClock(func() time.Time { return start }))
+}
+
+// observe reports a transfer to c, if it is set. Failed transfers are
+// reported with no bytes.
+func observe(c metrics.Collector, op, p string, size int64, start time.Time, err error) {
+	if c == nil {
+		return
+	}
+	if err != nil {
+		size = 0
+	}
+	c.ObserveTransfer(metrics.Transfer{
+		Op:       op,
+		Path:     p,
+		Bytes:    size,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
 // DefaultOptions returns Options with sensible defaults.
 func DefaultOptions() Options {
 	return Options{
@@ -175,20 +421,55 @@ type Result struct {
 	// Deleted is the number of files deleted from destination.
 	Deleted int
 
+	// DeletedBytes is the total size of the files deleted from the
+	// destination, if the destination reports sizes.
+	DeletedBytes int64
+
+	// DirsCreated is the number of empty directories created in the
+	// destination (see Options.CreateEmptyDirs).
+	DirsCreated int
+
 	// Skipped is the number of files skipped (already in sync).
 	Skipped int
 
+	// Resumed is the number of files skipped because Options.Journal
+	// recorded them as copied by an earlier run.
+	Resumed int
+
 	// Errors contains any errors that occurred.
 	Errors []FileError
 
 	// BytesTransferred is the total bytes transferred.
 	BytesTransferred int64
 
+	// Throttled is the number of transfer attempts that were throttled,
+	// with Options.Adaptive set.
+	Throttled int
+
+	// DeltaSaved is the number of bytes of files updated by delta
+	// transfers that matched the destination and were not written (see
+	// Options.DeltaTransfer).
+	DeltaSaved int64
+
 	// Duration is how long the sync took.
 	Duration time.Duration
 
 	// DryRun indicates if this was a dry run.
 	DryRun bool
+
+	// Actions lists, for dry runs of Sync, Copy, TreeCopy and Apply, what
+	// the run would have done, in order: the directories to create, the
+	// files to copy and update, with their source sizes, and the files to
+	// delete, with their destination sizes. The counts and byte totals of
+	// the Result match it. Paths are relative to the source and
+	// destination paths, except for a single file Copy, whose action has
+	// the full paths. It is nil for other runs.
+	Actions []Action
+
+	// Collisions lists source files that map to the same destination
+	// path under Options.PathTransform, CaseInsensitive or Normalize.
+	// Only the first of each was synced.
+	Collisions []Collision
 }
 
 // Success returns true if sync completed without errors.
@@ -232,11 +513,11 @@ func (r *CheckResult) InSync() bool {
 
 // FileInfo represents a file for sync comparison.
 type FileInfo struct {
-	Path    string
-	Size    int64
-	ModTime time.Time
-	Hash    string // MD5 or other hash if available
-	IsDir   bool
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash,omitempty"` // MD5 or other hash if available
+	IsDir   bool      `json:"is_dir,omitempty"`
 }
 
 // MetadataOptions configures which metadata to preserve during sync.
@@ -252,6 +533,16 @@ type MetadataOptions struct {
 	// CustomMetadata preserves backend-specific custom metadata.
 	// For S3, this includes user-defined metadata headers.
 	CustomMetadata bool
+
+	// HTTPHeaders preserves the Cache-Control, Content-Encoding and
+	// Content-Disposition headers of sources that report them.
+	// Default is true.
+	HTTPHeaders bool
+
+	// StorageClass preserves the storage class, for copies between
+	// buckets of one provider. Default is false, so destinations use
+	// their own default class.
+	StorageClass bool
 }
 
 // DefaultMetadataOptions returns metadata options with sensible defaults.
@@ -260,9 +551,24 @@ func DefaultMetadataOptions() *MetadataOptions {
 		ContentType:    true,
 		ModTime:        false, // Requires backend support, off by default
 		CustomMetadata: false, // Can be expensive, off by default
+		HTTPHeaders:    true,
+		StorageClass:   false, // Classes are provider-specific, off by default
 	}
 }
 
+// isNewer reports whether src was modified after dst, beyond the
+// tolerance for filesystem timestamp precision.
+func isNewer(src, dst time.Time) bool {
+	return !src.IsZero() && !dst.IsZero() && src.After(dst) && !tsync.Equal(src, dst)
+}
+
+// keepsExisting reports whether the existing destination file dst is kept
+// rather than replaced by src under IgnoreExisting, NoOverwrite or
+// UpdateOnly.
+func (o Options) keepsExisting(src, dst FileInfo) bool {
+	return o.IgnoreExisting || o.NoOverwrite || (o.UpdateOnly && !isNewer(src.ModTime, dst.ModTime))
+}
+
 // NeedsUpdate returns true if dst should be updated to match src.
 func NeedsUpdate(src, dst FileInfo, opts Options) bool {
 	// If size-only mode, just compare sizes
@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// TransferOrder is the order in which Sync starts file transfers.
+type TransferOrder string
+
+const (
+	// OrderDefault transfers files in listing order.
+	OrderDefault TransferOrder = ""
+
+	// OrderLargestFirst transfers the largest files first.
+	OrderLargestFirst TransferOrder = "largest-first"
+
+	// OrderSmallestFirst transfers the smallest files first, which
+	// completes the most files if the sync is interrupted.
+	OrderSmallestFirst TransferOrder = "smallest-first"
+
+	// OrderNewestFirst transfers the most recently modified files first.
+	OrderNewestFirst TransferOrder = "newest-first"
+
+	// OrderAlphabetical transfers files in path order.
+	OrderAlphabetical TransferOrder = "alphabetical"
+)
+
+// TransferOrders lists the supported transfer orders, excluding
+// OrderDefault.
+var TransferOrders = []TransferOrder{
+	OrderLargestFirst,
+	OrderSmallestFirst,
+	OrderNewestFirst,
+	OrderAlphabetical,
+}
+
+// transferCompare returns the comparison used to order transfers, or nil
+// to keep listing order. Files with a higher Priority come first; ties
+// are broken by TransferOrder.
+func (o Options) transferCompare() (func(a, b FileInfo) int, error) {
+	var byOrder func(a, b FileInfo) int
+	switch o.TransferOrder {
+	case OrderDefault:
+	case OrderLargestFirst:
+		byOrder = func(a, b FileInfo) int { return cmp.Compare(b.Size, a.Size) }
+	case OrderSmallestFirst:
+		byOrder = func(a, b FileInfo) int { return cmp.Compare(a.Size, b.Size) }
+	case OrderNewestFirst:
+		byOrder = func(a, b FileInfo) int { return b.ModTime.Compare(a.ModTime) }
+	case OrderAlphabetical:
+		byOrder = func(a, b FileInfo) int { return strings.Compare(a.Path, b.Path) }
+	default:
+		return nil, fmt.Errorf("unknown transfer order: %q", o.TransferOrder)
+	}
+
+	if o.Priority == nil {
+		return byOrder, nil
+	}
+	priority := o.Priority
+	return func(a, b FileInfo) int {
+		if c := cmp.Compare(priority(b), priority(a)); c != 0 {
+			return c
+		}
+		if byOrder != nil {
+			return byOrder(a, b)
+		}
+		return 0
+	}, nil
+}
@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// transferOrder runs a sequential sync and returns the files in the order
+// they were transferred.
+func transferOrder(t *testing.T, files map[string]string, opts Options) []string {
+	t.Helper()
+	ctx := context.Background()
+	src := memory.New()
+	for p, content := range files {
+		writeFile(t, ctx, src, p, content)
+	}
+
+	var order []string
+	opts.Concurrency = 1
+	opts.Progress = func(p Progress) {
+		if p.Phase == PhaseTransferring && p.CurrentFile != "" {
+			order = append(order, p.CurrentFile)
+		}
+	}
+	if _, err := Sync(ctx, src, memory.New(), "", "", opts); err != nil {
+		t.Fatalf("Sync error = %v", err)
+	}
+	return order
+}
+
+func TestSyncTransferOrder(t *testing.T) {
+	files := map[string]string{"a.txt": "22", "b.txt": "1", "c.txt": "333"}
+	tests := []struct {
+		order TransferOrder
+		want  []string
+	}{
+		{OrderLargestFirst, []string{"c.txt", "a.txt", "b.txt"}},
+		{OrderSmallestFirst, []string{"b.txt", "a.txt", "c.txt"}},
+		{OrderAlphabetical, []string{"a.txt", "b.txt", "c.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			got := transferOrder(t, files, Options{TransferOrder: tt.order})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncPriority(t *testing.T) {
+	files := map[string]string{
+		"bulk/big.bin":    "0123456789",
+		"bulk/small.bin":  "0",
+		"critical/db.sql": "01234",
+		"critical/a.cfg":  "01",
+	}
+	got := transferOrder(t, files, Options{
+		TransferOrder: OrderSmallestFirst,
+		Priority: func(f FileInfo) int {
+			if strings.HasPrefix(f.Path, "critical/") {
+				return 1
+			}
+			return 0
+		},
+	})
+	want := []string{"critical/a.cfg", "critical/db.sql", "bulk/small.bin", "bulk/big.bin"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestTransferCompareNewestFirst(t *testing.T) {
+	now := time.Now()
+	files := []FileInfo{
+		{Path: "old", ModTime: now.Add(-time.Hour)},
+		{Path: "new", ModTime: now},
+		{Path: "mid", ModTime: now.Add(-time.Minute)},
+	}
+	compare, err := Options{TransferOrder: OrderNewestFirst}.transferCompare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortStableFunc(files, compare)
+	if files[0].Path != "new" || files[1].Path != "mid" || files[2].Path != "old" {
+		t.Errorf("order = %v, want new, mid, old", files)
+	}
+}
+
+func TestSyncUnknownTransferOrder(t *testing.T) {
+	_, err := Sync(context.Background(), memory.New(), memory.New(), "", "", Options{TransferOrder: "random"})
+	if err == nil {
+		t.Error("Sync with unknown transfer order succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage/backend/file"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestSyncUpdateOnly(t *testing.T) {
+	ctx := context.Background()
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	now := time.Now()
+	put := func(dir, name, content string, mtime time.Time) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(srcDir, "newer.txt", "source", now)
+	put(dstDir, "newer.txt", "old", now.Add(-time.Hour))
+	put(srcDir, "older.txt", "source", now.Add(-time.Hour))
+	put(dstDir, "older.txt", "edited", now)
+	put(srcDir, "new.txt", "source", now)
+
+	src := file.New(file.Config{Root: srcDir})
+	dst := file.New(file.Config{Root: dstDir, CreateDirs: true})
+	result, err := Sync(ctx, src, dst, "", "", Options{UpdateOnly: true})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if result.Copied != 1 || result.Updated != 1 || result.Skipped != 1 {
+		t.Errorf("Copied = %d, Updated = %d, Skipped = %d, want 1, 1, 1", result.Copied, result.Updated, result.Skipped)
+	}
+	for name, want := range map[string]string{"newer.txt": "source", "older.txt": "edited", "new.txt": "source"} {
+		if got := readBackend(t, dst, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// A single file that is not newer is kept too
+	result, err = Copy(ctx, src, dst, "older.txt", "older.txt", Options{UpdateOnly: true})
+	if err != nil || result.Skipped != 1 {
+		t.Errorf("Copy = %+v, %v, want 1 skipped", result, err)
+	}
+}
+
+func TestSyncNoOverwrite(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "changed.txt", "source")
+	writeFile(t, ctx, dst, "changed.txt", "destination")
+	writeFile(t, ctx, src, "late.txt", "source")
+	writeFile(t, ctx, src, "new.txt", "source")
+
+	// Plan without NoOverwrite, then create a file in the destination
+	// before the plan is applied
+	plan, err := Plan(ctx, src, dst, "", "", Options{})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	writeFile(t, ctx, dst, "late.txt", "created meanwhile")
+
+	result, err := Apply(ctx, src, dst, plan, Options{NoOverwrite: true})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if result.Copied != 1 || result.Updated != 0 || result.Skipped != 2 {
+		t.Errorf("Copied = %d, Updated = %d, Skipped = %d, want 1, 0, 2", result.Copied, result.Updated, result.Skipped)
+	}
+	verifyFile(t, ctx, dst, "changed.txt", "destination")
+	verifyFile(t, ctx, dst, "late.txt", "created meanwhile")
+	verifyFile(t, ctx, dst, "new.txt", "source")
+
+	// Planning with NoOverwrite skips existing files up front
+	plan, err = Plan(ctx, src, dst, "", "", Options{NoOverwrite: true})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan.Actions) != 0 {
+		t.Errorf("plan actions = %+v, want none", plan.Actions)
+	}
+}
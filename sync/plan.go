@@ -0,0 +1,309 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/trash"
+)
+
+// ActionType is the kind of change an Action makes.
+type ActionType string
+
+const (
+	// ActionCopy copies a file that is missing from the destination.
+	ActionCopy ActionType = "copy"
+
+	// ActionUpdate overwrites a destination file that differs from the
+	// source.
+	ActionUpdate ActionType = "update"
+
+	// ActionDelete deletes a destination file that is not in the source.
+	ActionDelete ActionType = "delete"
+
+	// ActionMkdir creates a directory that is empty in the source (see
+	// Options.CreateEmptyDirs).
+	ActionMkdir ActionType = "mkdir"
+)
+
+// Action is a single change in an ActionPlan.
+type Action struct {
+	// Type is the kind of change.
+	Type ActionType `json:"type"`
+
+	// File is the source file for copies, updates and directories, or the
+	// destination file for deletes. Its path is relative to the plan's SrcPath and
+	// DstPath.
+	File FileInfo `json:"file"`
+
+	// DstPath is the destination path, relative to the plan's DstPath,
+	// if it differs from File.Path: the existing destination name of a
+	// file matched under Options.CaseInsensitive or Normalize, or the
+	// name of a new one after Options.PathTransform and Normalize.
+	DstPath string `json:"dst_path,omitempty"`
+}
+
+// newAction returns an action for the source file f, written to dstName.
+func newAction(t ActionType, f FileInfo, dstName string) Action {
+	a := Action{Type: t, File: f}
+	if dstName != f.Path {
+		a.DstPath = dstName
+	}
+	return a
+}
+
+// destPath returns the destination path of a, relative to the plan's
+// DstPath.
+func (a Action) destPath() string {
+	if a.DstPath != "" {
+		return a.DstPath
+	}
+	return a.File.Path
+}
+
+// ActionPlan is the list of changes a sync would make. It is returned by
+// Plan and executed by Apply. Callers can review, filter, reorder or
+// persist it (it marshals to JSON) before applying it, for example to
+// require approval of deletions.
+type ActionPlan struct {
+	// SrcPath is the source path the plan was made for.
+	SrcPath string `json:"src_path"`
+
+	// DstPath is the destination path the plan was made for.
+	DstPath string `json:"dst_path"`
+
+	// Actions lists the changes in the order they are applied: empty
+	// directories first, then copies and updates, in Options.Priority and
+	// TransferOrder order, then deletes.
+	Actions []Action `json:"actions"`
+
+	// Skipped is the number of files already in sync.
+	Skipped int `json:"skipped"`
+
+	// Resumed is the number of files Options.Journal recorded as copied
+	// by an earlier run, which were not compared.
+	Resumed int `json:"resumed,omitempty"`
+
+	// Collisions lists source files that map to the same destination
+	// path. Only the first of each is synced.
+	Collisions []Collision `json:"collisions,omitempty"`
+
+	// CreatedAt is when the plan was made.
+	CreatedAt time.Time `json:"created_at"`
+
+	// inSync lists the skipped and resumed source files with their
+	// destination paths, for Move to delete. Their Type is empty.
+	inSync []Action
+}
+
+// Count returns the number of actions of type t.
+func (p *ActionPlan) Count(t ActionType) int {
+	n := 0
+	for _, a := range p.Actions {
+		if a.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+// Bytes returns the number of bytes the plan's copies and updates
+// transfer.
+func (p *ActionPlan) Bytes() int64 {
+	var n int64
+	for _, a := range p.Actions {
+		if a.Type == ActionCopy || a.Type == ActionUpdate {
+			n += a.File.Size
+		}
+	}
+	return n
+}
+
+// Plan scans source and destination and returns the changes Sync would
+// make with opts, without making them. The comparison options (Checksum,
+// SizeOnly, IgnoreExisting, NoOverwrite, UpdateOnly, Filter, DeleteExtra,
+// DeleteExcluded and so on) and TransferOrder and Priority apply; the
+// transfer options are used by Apply.
+func Plan(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) (*ActionPlan, error) {
+	transferCompare, err := opts.transferCompare()
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.checkNames(); err != nil {
+		return nil, err
+	}
+
+	logger := opts.logger()
+	plan := &ActionPlan{SrcPath: srcPath, DstPath: dstPath, CreatedAt: time.Now()}
+
+	// Scan source files
+	if opts.Progress != nil {
+		opts.Progress(Progress{Phase: PhaseScanning, CurrentFile: srcPath})
+	}
+
+	logger.Debug("scanning source files", slog.String("path", srcPath))
+	excluded := make(map[string]bool)
+	srcFiles, err := scanFiles(ctx, src, srcPath, opts, true, excluded)
+	if err != nil {
+		logger.Error("failed to list source files", slog.String("path", srcPath), slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("source scan complete", slog.Int("files", len(srcFiles)))
+
+	// Scan destination files
+	logger.Debug("scanning destination files", slog.String("path", dstPath))
+	dstFiles, err := listDestFiles(ctx, dst, dstPath, opts, excluded)
+	if err != nil {
+		logger.Error("failed to list destination files", slog.String("path", dstPath), slog.Any("error", err))
+		return nil, err
+	}
+	if opts.TrashPrefix != "" {
+		tb := trash.New(dst, trash.WithPrefix(opts.TrashPrefix))
+		dstFiles = slices.DeleteFunc(dstFiles, func(f FileInfo) bool {
+			return tb.InTrash(path.Join(dstPath, f.Path))
+		})
+	}
+	if opts.Manifest != nil {
+		dstFiles = slices.DeleteFunc(dstFiles, func(f FileInfo) bool {
+			return f.Path == opts.Manifest.path()
+		})
+	}
+	logger.Debug("destination scan complete", slog.Int("files", len(dstFiles)))
+
+	// Index destination files for lookup by path key
+	dstIndex := newPathIndex(dstFiles, opts)
+
+	// Compare and determine actions
+	if opts.Progress != nil {
+		opts.Progress(Progress{Phase: PhaseComparing, TotalFiles: len(srcFiles)})
+	}
+
+	var toCopy, toDelete []Action
+
+	// Source paths by destination path key, to find collisions. Without
+	// name options, keys are source paths and there are none.
+	var seen map[string]*Collision
+	var keys []string
+	if opts.CaseInsensitive || opts.Normalize != NormalizeNone || opts.PathTransform != nil {
+		seen = make(map[string]*Collision)
+	}
+
+	for _, srcFile := range srcFiles {
+		if srcFile.IsDir {
+			continue // Skip directories, they're created as needed
+		}
+
+		dstName, err := opts.destName(srcFile.Path)
+		if err != nil {
+			return nil, err
+		}
+		if dstName == "" {
+			continue // Skipped by PathTransform
+		}
+
+		key := opts.pathKey(dstName)
+		if c, dup := seen[key]; dup {
+			c.Paths = append(c.Paths, srcFile.Path)
+			continue
+		}
+
+		// New files get transformed names; existing ones keep theirs
+		dstFile, exists := dstIndex.take(dstName)
+		if exists {
+			dstName = dstFile.Path
+		}
+		if seen != nil {
+			seen[key] = &Collision{DstPath: dstName, Paths: []string{srcFile.Path}}
+			keys = append(keys, key)
+		}
+
+		if opts.Journal != nil && opts.Journal.Done(srcFile) {
+			plan.Resumed++
+			plan.inSync = append(plan.inSync, newAction("", srcFile, dstName))
+		} else if !exists {
+			// New file
+			toCopy = append(toCopy, newAction(ActionCopy, srcFile, dstName))
+		} else if !dstFile.IsDir && NeedsUpdate(srcFile, dstFile, opts) {
+			// File needs update
+			if !opts.keepsExisting(srcFile, dstFile) {
+				toCopy = append(toCopy, newAction(ActionUpdate, srcFile, dstName))
+			} else {
+				plan.Skipped++
+				plan.inSync = append(plan.inSync, newAction("", srcFile, dstName))
+			}
+		} else {
+			plan.Skipped++
+			plan.inSync = append(plan.inSync, newAction("", srcFile, dstName))
+		}
+	}
+
+	for _, k := range keys {
+		if c := seen[k]; len(c.Paths) > 1 {
+			plan.Collisions = append(plan.Collisions, *c)
+		}
+	}
+	for _, c := range plan.Collisions {
+		logger.Warn("source paths collide in destination",
+			slog.String("dst_path", c.DstPath),
+			slog.Any("paths", c.Paths),
+		)
+	}
+
+	// Remaining destination files exist only in destination
+	if opts.DeleteExtra {
+		for _, f := range dstIndex.rest() {
+			if !f.IsDir {
+				toDelete = append(toDelete, Action{Type: ActionDelete, File: f})
+			}
+		}
+		slices.SortFunc(toDelete, func(a, b Action) int {
+			return strings.Compare(a.File.Path, b.File.Path)
+		})
+	}
+
+	if transferCompare != nil {
+		slices.SortStableFunc(toCopy, func(a, b Action) int {
+			return transferCompare(a.File, b.File)
+		})
+	}
+
+	if opts.CreateEmptyDirs {
+		plan.Actions, err = planDirs(ctx, src, dst, srcPath, dstPath, srcFiles, dstFiles, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	plan.Actions = append(plan.Actions, toCopy...)
+	plan.Actions = append(plan.Actions, toDelete...)
+	return plan, nil
+}
+
+// Apply executes plan from src to dst. Actions run in plan order, except
+// that directories are created first, copies and updates run
+// concurrently (up to Options.Concurrency) and deletes run after all
+// copies; if a transfer limit stops the copies,
+// no deletes are made. The comparison options in opts are ignored, except
+// NoOverwrite, which skips files that exist in dst when they are written.
+//
+// Apply does not re-check the files: a file changed since the plan was
+// made is copied or deleted as planned.
+func Apply(ctx context.Context, src, dst omnistorage.Backend, plan *ActionPlan, opts Options) (*Result, error) {
+	for _, a := range plan.Actions {
+		switch a.Type {
+		case ActionCopy, ActionUpdate, ActionDelete, ActionMkdir:
+		default:
+			return nil, fmt.Errorf("plan: unknown action %q for %s", a.Type, a.File.Path)
+		}
+		if a.File.Path == "" {
+			return nil, fmt.Errorf("plan: %s action with empty path", a.Type)
+		}
+	}
+	return apply(ctx, src, dst, plan, opts, time.Now())
+}
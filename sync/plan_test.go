@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "new.txt", "new")
+	writeFile(t, ctx, src, "same.txt", "same")
+	writeFile(t, ctx, src, "changed.txt", "changed content")
+	writeFile(t, ctx, dst, "same.txt", "same")
+	writeFile(t, ctx, dst, "changed.txt", "old")
+	writeFile(t, ctx, dst, "b-extra.txt", "extra")
+	writeFile(t, ctx, dst, "a-extra.txt", "extra")
+
+	// Plan makes no changes.
+	plan, err := Plan(ctx, src, dst, "", "", Options{DeleteExtra: true, SizeOnly: true, TransferOrder: OrderAlphabetical})
+	if err != nil {
+		t.Fatalf("Plan error = %v", err)
+	}
+	if exists, _ := dst.Exists(ctx, "new.txt"); exists {
+		t.Fatal("Plan copied a file")
+	}
+
+	var got []string
+	for _, a := range plan.Actions {
+		got = append(got, string(a.Type)+" "+a.File.Path)
+	}
+	want := []string{"update changed.txt", "copy new.txt", "delete a-extra.txt", "delete b-extra.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Actions = %v, want %v", got, want)
+	}
+	if plan.Skipped != 1 || plan.Count(ActionDelete) != 2 || plan.Bytes() != 18 {
+		t.Errorf("Skipped = %d, deletes = %d, bytes = %d; want 1, 2, 18", plan.Skipped, plan.Count(ActionDelete), plan.Bytes())
+	}
+
+	// Persist the plan, veto one deletion, and apply it.
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded ActionPlan
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Actions = slices.DeleteFunc(loaded.Actions, func(a Action) bool {
+		return a.File.Path == "b-extra.txt"
+	})
+
+	result, err := Apply(ctx, src, dst, &loaded, Options{})
+	if err != nil {
+		t.Fatalf("Apply error = %v", err)
+	}
+	if result.Copied != 1 || result.Updated != 1 || result.Deleted != 1 || result.Skipped != 1 {
+		t.Errorf("Result = %+v, want 1 copied, 1 updated, 1 deleted, 1 skipped", result)
+	}
+	verifyFile(t, ctx, dst, "changed.txt", "changed content")
+	verifyFile(t, ctx, dst, "new.txt", "new")
+	if exists, _ := dst.Exists(ctx, "a-extra.txt"); exists {
+		t.Error("a-extra.txt not deleted")
+	}
+	if exists, _ := dst.Exists(ctx, "b-extra.txt"); !exists {
+		t.Error("b-extra.txt deleted after being removed from the plan")
+	}
+}
+
+func TestApplyInvalidPlan(t *testing.T) {
+	ctx := context.Background()
+	for name, plan := range map[string]*ActionPlan{
+		"unknown type": {Actions: []Action{{Type: "rename", File: FileInfo{Path: "a"}}}},
+		"empty path":   {Actions: []Action{{Type: ActionDelete}}},
+	} {
+		if _, err := Apply(ctx, memory.New(), memory.New(), plan, Options{}); err == nil {
+			t.Errorf("%s: Apply succeeded, want error", name)
+		}
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "a.txt", "a")
+
+	plan := &ActionPlan{Actions: []Action{{Type: ActionCopy, File: FileInfo{Path: "a.txt", Size: 1, ModTime: time.Now()}}}}
+	result, err := Apply(ctx, src, dst, plan, Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("Apply error = %v", err)
+	}
+	if result.Copied != 1 || !result.DryRun {
+		t.Errorf("Result = %+v, want 1 copied in dry run", result)
+	}
+	if exists, _ := dst.Exists(ctx, "a.txt"); exists {
+		t.Error("dry-run Apply copied a file")
+	}
+}
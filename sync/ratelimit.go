@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"context"
 	"io"
 	"sync"
 	"time"
@@ -78,35 +79,53 @@ func newTokenBucket(bytesPerSecond int64) *tokenBucket {
 
 // wait blocks until n tokens are available and consumes them.
 func (tb *tokenBucket) wait(n int) {
+	_ = tb.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available and consumes them, or returns
+// ctx's error. It lets the bucket serve as an omnistorage.Limiter. Requests
+// larger than the burst size are served in burst-sized parts.
+func (tb *tokenBucket) WaitN(ctx context.Context, n int) error {
 	if tb == nil || tb.rate == 0 {
-		return
+		return nil
 	}
 
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
 
-	// Refill tokens based on elapsed time
-	tb.refill()
-
-	needed := int64(n)
+	for remaining := int64(n); remaining > 0; {
+		needed := min(remaining, tb.maxTokens)
 
-	// If we need more tokens than available, wait
-	for tb.tokens < needed {
-		// Calculate wait time
-		deficit := needed - tb.tokens
-		waitDuration := time.Duration(deficit) * time.Second / time.Duration(tb.rate)
-
-		// Release lock while waiting
-		tb.mu.Unlock()
-		time.Sleep(waitDuration)
-		tb.mu.Lock()
-
-		// Refill after waiting
+		// Refill tokens based on elapsed time
 		tb.refill()
-	}
 
-	// Consume tokens
-	tb.tokens -= needed
+		// If we need more tokens than available, wait
+		for tb.tokens < needed {
+			// Calculate wait time
+			deficit := needed - tb.tokens
+			waitDuration := time.Duration(deficit) * time.Second / time.Duration(tb.rate)
+
+			// Release lock while waiting
+			tb.mu.Unlock()
+			timer := time.NewTimer(waitDuration)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				tb.mu.Lock()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			tb.mu.Lock()
+
+			// Refill after waiting
+			tb.refill()
+		}
+
+		// Consume tokens
+		tb.tokens -= needed
+		remaining -= needed
+	}
+	return nil
 }
 
 // returnTokens returns unused tokens to the bucket.
@@ -2,6 +2,8 @@ package sync
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"testing"
 	"time"
@@ -137,3 +139,28 @@ func TestRateLimitedReaderNoLimit(t *testing.T) {
 		t.Error("Data mismatch")
 	}
 }
+
+func TestTokenBucketWaitN(t *testing.T) {
+	bucket := newTokenBucket(1000)
+	ctx := context.Background()
+
+	// More than the burst size is served in parts
+	start := time.Now()
+	if err := bucket.WaitN(ctx, 1500); err != nil {
+		t.Fatalf("WaitN failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("WaitN(1500) took %v, want about 500ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := bucket.WaitN(ctx, 1000); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitN error = %v, want context.Canceled", err)
+	}
+
+	var nilBucket *tokenBucket
+	if err := nilBucket.WaitN(ctx, 1000); err != nil {
+		t.Errorf("nil bucket WaitN error = %v, want nil", err)
+	}
+}
@@ -3,8 +3,14 @@ package sync
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/grokify/omnistorage"
 )
 
 func TestRetrySuccess(t *testing.T) {
@@ -241,3 +247,139 @@ func TestIsTemporaryError(t *testing.T) {
 		})
 	}
 }
+
+// apiError mimics an SDK error exposing a service error code and HTTP status.
+type apiError struct {
+	code   string
+	status int
+}
+
+func (e apiError) Error() string       { return fmt.Sprintf("api error %s (%d)", e.code, e.status) }
+func (e apiError) ErrorCode() string   { return e.code }
+func (e apiError) HTTPStatusCode() int { return e.status }
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"nil", nil, ErrorUnknown},
+		{"plain error", errors.New("error"), ErrorUnknown},
+		{"not found", fmt.Errorf("stat: %w", omnistorage.ErrNotFound), ErrorPermanent},
+		{"permission denied", omnistorage.ErrPermissionDenied, ErrorPermanent},
+		{"canceled", context.Canceled, ErrorPermanent},
+		{"deadline", context.DeadlineExceeded, ErrorTransient},
+		{"timeout", timeoutError{}, ErrorTransient},
+		{"connection reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, ErrorTransient},
+		{"unexpected EOF", io.ErrUnexpectedEOF, ErrorTransient},
+		{"503", fmt.Errorf("s3: %w", apiError{"ServiceUnavailable", 503}), ErrorTransient},
+		{"500 unknown code", apiError{"Whatever", 500}, ErrorTransient},
+		{"slow down", apiError{"SlowDown", 503}, ErrorThrottled},
+		{"429", apiError{"", 429}, ErrorThrottled},
+		{"EAGAIN", fmt.Errorf("sftp: error for %q: %w", "a", syscall.EAGAIN), ErrorThrottled},
+		{"403", apiError{"Forbidden", 403}, ErrorPermanent},
+		{"404", apiError{"", 404}, ErrorPermanent},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ClassifyError(tc.err); got != tc.want {
+				t.Errorf("ClassifyError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+			if want := tc.err != nil && tc.want != ErrorPermanent; IsRetryable(tc.err) != want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tc.err, !want, want)
+			}
+		})
+	}
+}
+
+func TestRetryPermanentErrorByDefault(t *testing.T) {
+	calls := 0
+	err := retryOperation(context.Background(), RetryConfig{
+		MaxRetries:   3,
+		InitialDelay: time.Millisecond,
+	}, func() error {
+		calls++
+		return apiError{"AccessDenied", 403}
+	})
+
+	if IsRetryError(err) {
+		t.Errorf("permanent error wrapped in RetryError: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call (no retry), got %d", calls)
+	}
+}
+
+func TestRetryOnRetry(t *testing.T) {
+	var events []RetryEvent
+	calls := 0
+	err := retryOperation(context.Background(), RetryConfig{
+		MaxRetries:    2,
+		InitialDelay:  time.Millisecond,
+		ThrottleDelay: 20 * time.Millisecond,
+		OnRetry:       func(e RetryEvent) { events = append(events, e) },
+	}, func() error {
+		calls++
+		if calls == 1 {
+			return apiError{"SlowDown", 503}
+		}
+		return apiError{"InternalError", 500}
+	})
+
+	if !IsRetryError(err) {
+		t.Fatalf("Expected RetryError, got %v", err)
+	}
+	// OnRetry is called before each retry, not after the final attempt.
+	if len(events) != 2 {
+		t.Fatalf("OnRetry called %d times, want 2", len(events))
+	}
+	if events[0].Attempt != 1 || events[0].Class != ErrorThrottled || events[0].Delay < 20*time.Millisecond {
+		t.Errorf("event 0 = %+v, want throttled attempt 1 with at least ThrottleDelay", events[0])
+	}
+	if events[1].Attempt != 2 || events[1].Class != ErrorTransient || events[1].Delay >= 20*time.Millisecond {
+		t.Errorf("event 1 = %+v, want transient attempt 2 with normal backoff", events[1])
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	calls := 0
+	err := retryWithContext(context.Background(), RetryConfig{
+		MaxRetries:     2,
+		InitialDelay:   time.Millisecond,
+		AttemptTimeout: 10 * time.Millisecond,
+	}, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			<-ctx.Done() // hang until the attempt times out
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Expected success on third attempt, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryJitterCappedAtMaxDelay(t *testing.T) {
+	var delays []time.Duration
+	_ = retryOperation(context.Background(), RetryConfig{
+		MaxRetries:   3,
+		InitialDelay: 4 * time.Millisecond,
+		MaxDelay:     4 * time.Millisecond,
+		Jitter:       0.5,
+		OnRetry:      func(e RetryEvent) { delays = append(delays, e.Delay) },
+	}, func() error {
+		return errors.New("error")
+	})
+
+	for i, d := range delays {
+		if d > 4*time.Millisecond {
+			t.Errorf("delay %d = %v, exceeds MaxDelay", i, d)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+// Package scheduler runs sync jobs on a schedule, for replication
+// services that would otherwise hand-roll timers:
+//
+//	nightly, _ := scheduler.ParseCron("30 2 * * *")
+//	runner := scheduler.New(scheduler.WithLogger(slog.Default()))
+//	_ = runner.Add(scheduler.Job{
+//	    Name:     "nightly-backup",
+//	    Src:      fileBackend,
+//	    Dst:      s3Backend,
+//	    DstPath:  "backup/",
+//	    Options:  sync.Options{DeleteExtra: true},
+//	    Schedule: nightly,
+//	})
+//	go runner.Run(ctx)
+//
+//	status, _ := runner.Status("nightly-backup")
+//	fmt.Println(status.LastEnd, status.LastErr)
+//
+// Each job runs sync.Sync from its source to its destination. A job that
+// is still running when it is due again is skipped, or queued, as its
+// OverlapPolicy says.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/grokify/mogo/log/slogutil"
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/sync"
+)
+
+// ErrRunning is returned by RunNow for a job that is already running and
+// does not allow overlapping runs.
+var ErrRunning = errors.New("scheduler: job is running")
+
+// ErrUnknownJob is returned for a job name that was not added.
+var ErrUnknownJob = errors.New("scheduler: unknown job")
+
+// OverlapPolicy is what happens when a job is due while it is still
+// running.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the run that is due. It is the default.
+	OverlapSkip OverlapPolicy = iota
+
+	// OverlapQueue runs the job again as soon as the current run ends.
+	// Several runs due meanwhile are queued as one.
+	OverlapQueue
+
+	// OverlapAllow starts the run alongside the current one. Use it
+	// only for jobs whose runs cannot interfere, as two syncs to the same
+	// destination can.
+	OverlapAllow
+)
+
+// Job is a sync run on a schedule.
+type Job struct {
+	// Name identifies the job in the runner and its logs. Required.
+	Name string
+
+	// Src and Dst are the backends synced from and to. Required.
+	Src, Dst omnistorage.Backend
+
+	// SrcPath and DstPath are the paths synced.
+	SrcPath, DstPath string
+
+	// Options configures each sync. If Options.Logger is nil, the
+	// runner's logger is used, with the job's name.
+	Options sync.Options
+
+	// Schedule determines when the job runs. Required.
+	Schedule Schedule
+
+	// Overlap is what happens when the job is due while it is still
+	// running. Default: OverlapSkip.
+	Overlap OverlapPolicy
+}
+
+// Status is the state of a job and the outcome of its last run.
+type Status struct {
+	// Name is the job's name.
+	Name string
+
+	// Running is the number of runs in progress.
+	Running int
+
+	// Next is when the job is next due, or zero if the runner is not
+	// running or the schedule has ended.
+	Next time.Time
+
+	// Runs is the number of runs completed.
+	Runs int
+
+	// Skipped is the number of runs skipped because the job was still
+	// running.
+	Skipped int
+
+	// LastStart and LastEnd are when the last completed run started and
+	// ended.
+	LastStart, LastEnd time.Time
+
+	// LastResult is the result of the last completed run, or nil.
+	LastResult *sync.Result
+
+	// LastErr is the error of the last completed run.
+	LastErr error
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithLogger sets the logger of the runner and of jobs without their own.
+// The default discards logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Runner) {
+		if logger != nil {
+			r.logger = logger
+		}
+	}
+}
+
+// WithLocation sets the time zone schedules are evaluated in. The default
+// is time.Local.
+func WithLocation(loc *time.Location) Option {
+	return func(r *Runner) {
+		if loc != nil {
+			r.loc = loc
+		}
+	}
+}
+
+// Runner runs jobs on their schedules. It is safe for concurrent use.
+type Runner struct {
+	logger *slog.Logger
+	loc    *time.Location
+
+	mu      gosync.Mutex
+	jobs    map[string]*jobState
+	ctx     context.Context // set while Run is running
+	wg      gosync.WaitGroup
+	running bool
+}
+
+// jobState is a job and its status, guarded by Runner.mu.
+type jobState struct {
+	job    Job
+	status Status
+	queued bool
+	stop   context.CancelFunc // stops the job's timer loop
+}
+
+// New returns a runner without jobs.
+func New(opts ...Option) *Runner {
+	r := &Runner{
+		logger: slogutil.Null(),
+		loc:    time.Local,
+		jobs:   make(map[string]*jobState),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Add adds job to the runner. If the runner is running, the job is
+// scheduled at once.
+func (r *Runner) Add(job Job) error {
+	switch {
+	case job.Name == "":
+		return errors.New("scheduler: job name is required")
+	case job.Src == nil || job.Dst == nil:
+		return fmt.Errorf("scheduler: job %s: Src and Dst are required", job.Name)
+	case job.Schedule == nil:
+		return fmt.Errorf("scheduler: job %s: Schedule is required", job.Name)
+	}
+	if job.Options.Logger == nil {
+		job.Options.Logger = r.logger.With(slog.String("job", job.Name))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.jobs[job.Name]; ok {
+		return fmt.Errorf("scheduler: job %s already added", job.Name)
+	}
+	js := &jobState{job: job, status: Status{Name: job.Name}}
+	r.jobs[job.Name] = js
+	if r.running {
+		r.start(js)
+	}
+	return nil
+}
+
+// Remove removes the job named name, and reports whether it was added.
+// A run in progress is not interrupted.
+func (r *Runner) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	js, ok := r.jobs[name]
+	if !ok {
+		return false
+	}
+	if js.stop != nil {
+		js.stop()
+	}
+	delete(r.jobs, name)
+	return true
+}
+
+// Run runs the jobs on their schedules until ctx is done, then waits for
+// the runs in progress, which are cancelled with ctx, and returns
+// ctx.Err().
+func (r *Runner) Run(ctx context.Context) error {
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return errors.New("scheduler: runner is already running")
+	}
+	r.running = true
+	r.ctx = ctx
+	for _, js := range r.jobs {
+		r.start(js)
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+
+	r.wg.Wait()
+	r.mu.Lock()
+	r.running = false
+	for _, js := range r.jobs {
+		js.stop = nil
+		js.status.Next = time.Time{}
+	}
+	r.mu.Unlock()
+	return ctx.Err()
+}
+
+// start starts the timer loop of js. r.mu must be held.
+func (r *Runner) start(js *jobState) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	js.stop = cancel
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.loop(ctx, js)
+	}()
+}
+
+// loop triggers js each time its schedule is due, until ctx is done.
+func (r *Runner) loop(ctx context.Context, js *jobState) {
+	for {
+		now := time.Now().In(r.loc)
+		next := js.job.Schedule.Next(now)
+		r.mu.Lock()
+		js.status.Next = next
+		r.mu.Unlock()
+		if next.IsZero() {
+			r.logger.Info("schedule ended", slog.String("job", js.job.Name))
+			return
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// The run gets the runner's context, so removing the job does not
+		// interrupt it
+		_, _ = r.trigger(r.ctx, js, false)
+	}
+}
+
+// trigger runs js in the background as its overlap policy allows, or
+// synchronously if wait is set, returning the run's result.
+func (r *Runner) trigger(ctx context.Context, js *jobState, wait bool) (*sync.Result, error) {
+	r.mu.Lock()
+	if js.status.Running > 0 && js.job.Overlap != OverlapAllow {
+		if js.job.Overlap == OverlapQueue && !wait {
+			js.queued = true
+			r.mu.Unlock()
+			return nil, nil
+		}
+		if !wait {
+			js.status.Skipped++
+			r.logger.Warn("skipping run, job still running", slog.String("job", js.job.Name))
+		}
+		r.mu.Unlock()
+		return nil, ErrRunning
+	}
+	js.status.Running++
+	r.mu.Unlock()
+
+	if wait {
+		return r.run(ctx, js)
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		_, _ = r.run(ctx, js)
+	}()
+	return nil, nil
+}
+
+// run runs js, then any run queued meanwhile. The caller has counted it
+// in status.Running.
+func (r *Runner) run(ctx context.Context, js *jobState) (*sync.Result, error) {
+	job := js.job
+	for {
+		start := time.Now()
+		r.logger.Info("job started", slog.String("job", job.Name))
+		result, err := sync.Sync(ctx, job.Src, job.Dst, job.SrcPath, job.DstPath, job.Options)
+		end := time.Now()
+		if err != nil {
+			r.logger.Error("job failed", slog.String("job", job.Name), slog.Any("error", err))
+		} else {
+			r.logger.Info("job complete", slog.String("job", job.Name), slog.Duration("duration", end.Sub(start)))
+		}
+
+		r.mu.Lock()
+		js.status.Runs++
+		js.status.LastStart, js.status.LastEnd = start, end
+		js.status.LastResult, js.status.LastErr = result, err
+		again := js.queued && ctx.Err() == nil
+		js.queued = false
+		if !again {
+			js.status.Running--
+		}
+		r.mu.Unlock()
+		if !again {
+			return result, err
+		}
+	}
+}
+
+// RunNow runs the job named name at once and waits for it, regardless of
+// its schedule, and returns its result. It returns ErrRunning if the job
+// is running and its policy is not OverlapAllow.
+func (r *Runner) RunNow(ctx context.Context, name string) (*sync.Result, error) {
+	r.mu.Lock()
+	js, ok := r.jobs[name]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	return r.trigger(ctx, js, true)
+}
+
+// Status returns the status of the job named name, and false if it was
+// not added.
+func (r *Runner) Status(name string) (Status, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	js, ok := r.jobs[name]
+	if !ok {
+		return Status{}, false
+	}
+	return js.status, true
+}
+
+// Statuses returns the status of every job, sorted by name.
+func (r *Runner) Statuses() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]Status, 0, len(r.jobs))
+	for _, js := range r.jobs {
+		statuses = append(statuses, js.status)
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return statuses
+}
@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/sync"
+)
+
+// tick runs every d, below the second that Every allows.
+type tick time.Duration
+
+func (d tick) Next(t time.Time) time.Time { return t.Add(time.Duration(d)) }
+
+func writeFile(t *testing.T, b *memory.Backend, p, content string) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(w, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	src, dst := memory.New(), memory.New()
+	writeFile(t, src, "a.txt", "hello")
+
+	r := New()
+	if err := r.Add(Job{Name: "copy", Src: src, Dst: dst, Schedule: tick(20 * time.Millisecond)}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := r.Add(Job{Name: "copy", Src: src, Dst: dst, Schedule: tick(time.Hour)}); err == nil {
+		t.Error("Add of a duplicate name succeeded")
+	}
+	if err := r.Add(Job{Name: "bad", Src: src, Dst: dst}); err == nil {
+		t.Error("Add without a schedule succeeded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	waitFor(t, func() bool {
+		s, _ := r.Status("copy")
+		return s.Runs >= 2
+	})
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run error = %v, want context.Canceled", err)
+	}
+
+	s, ok := r.Status("copy")
+	if !ok || s.LastErr != nil || s.LastResult == nil || s.LastEnd.Before(s.LastStart) || !s.Next.IsZero() {
+		t.Errorf("Status = %+v", s)
+	}
+	if exists, _ := dst.Exists(context.Background(), "a.txt"); !exists {
+		t.Error("a.txt not synced")
+	}
+	if _, err := r.RunNow(context.Background(), "missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("RunNow(missing) error = %v, want ErrUnknownJob", err)
+	}
+}
+
+// blockingJob returns a job whose runs wait for release after signalling
+// started.
+func blockingJob(t *testing.T, overlap OverlapPolicy) (Job, chan struct{}, chan struct{}) {
+	src := memory.New()
+	writeFile(t, src, "a.txt", "hello")
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	return Job{
+		Name:     "slow",
+		Src:      src,
+		Dst:      memory.New(),
+		Schedule: tick(time.Hour),
+		Overlap:  overlap,
+		Options: sync.Options{Hooks: &sync.Hooks{BeforeCopy: func(context.Context, sync.FileInfo) sync.Decision {
+			started <- struct{}{}
+			<-release
+			return sync.Skip
+		}}},
+	}, started, release
+}
+
+func TestRunnerOverlap(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("skip", func(t *testing.T) {
+		job, started, release := blockingJob(t, OverlapSkip)
+		r := New()
+		_ = r.Add(job)
+		go func() { _, _ = r.RunNow(ctx, "slow") }()
+		<-started
+		if _, err := r.RunNow(ctx, "slow"); !errors.Is(err, ErrRunning) {
+			t.Errorf("RunNow error = %v, want ErrRunning", err)
+		}
+		_, _ = r.trigger(ctx, r.jobs["slow"], false)
+		close(release)
+		waitFor(t, func() bool {
+			s, _ := r.Status("slow")
+			return s.Running == 0
+		})
+		if s, _ := r.Status("slow"); s.Runs != 1 || s.Skipped != 1 {
+			t.Errorf("Runs = %d, Skipped = %d, want 1, 1", s.Runs, s.Skipped)
+		}
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		job, started, release := blockingJob(t, OverlapQueue)
+		r := New()
+		_ = r.Add(job)
+		_, _ = r.trigger(ctx, r.jobs["slow"], false)
+		<-started
+		// Runs due while running are queued as one
+		_, _ = r.trigger(ctx, r.jobs["slow"], false)
+		_, _ = r.trigger(ctx, r.jobs["slow"], false)
+		close(release)
+		r.wg.Wait()
+		if s, _ := r.Status("slow"); s.Runs != 2 || s.Running != 0 {
+			t.Errorf("Runs = %d, Running = %d, want 2, 0", s.Runs, s.Running)
+		}
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		job, started, release := blockingJob(t, OverlapAllow)
+		r := New()
+		_ = r.Add(job)
+		_, _ = r.trigger(ctx, r.jobs["slow"], false)
+		_, _ = r.trigger(ctx, r.jobs["slow"], false)
+		<-started
+		<-started
+		if s, _ := r.Status("slow"); s.Running != 2 {
+			t.Errorf("Running = %d, want 2", s.Running)
+		}
+		close(release)
+		r.wg.Wait()
+	})
+}
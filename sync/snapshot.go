@@ -0,0 +1,468 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/oscompat/tsync"
+)
+
+// Layout of a snapshot store. Each snapshot is a manifest under
+// snapshotDir; file content is stored once, by SHA-256, under objectDir.
+const (
+	snapshotDir        = "snapshots/"
+	objectDir          = "objects/"
+	snapshotTimeFormat = "20060102T150405Z"
+)
+
+// errSnapshotReadOnly is returned by the write methods of a
+// SnapshotBackend.
+var errSnapshotReadOnly = fmt.Errorf("%w: snapshots are read-only", omnistorage.ErrPermissionDenied)
+
+// SnapshotManifest describes a snapshot made by Snapshot. It is stored as
+// JSON in the snapshot store, at snapshots/<name>.json.
+type SnapshotManifest struct {
+	// Name is the snapshot's name.
+	Name string `json:"name"`
+
+	// Created is when the snapshot was started.
+	Created time.Time `json:"created"`
+
+	// SrcPath is the source path the snapshot was made of.
+	SrcPath string `json:"src_path"`
+
+	// Files lists the files in the snapshot, sorted by path, with paths
+	// relative to SrcPath. Hash is the SHA-256 of the content, which is
+	// stored at objects/<first two hex digits>/<hash>.
+	Files []FileInfo `json:"files"`
+}
+
+// Size returns the total size of the files in the snapshot.
+func (m *SnapshotManifest) Size() int64 {
+	var n int64
+	for _, f := range m.Files {
+		n += f.Size
+	}
+	return n
+}
+
+// SnapshotResult contains the results of Snapshot.
+type SnapshotResult struct {
+	// Name is the snapshot's name.
+	Name string
+
+	// Files is the number of files in the snapshot.
+	Files int
+
+	// Bytes is the total size of the files in the snapshot.
+	Bytes int64
+
+	// Stored is the number of files whose content was not in the store
+	// yet and was uploaded. The others share content stored by an earlier
+	// snapshot or file.
+	Stored int
+
+	// StoredBytes is the size of the uploaded content.
+	StoredBytes int64
+
+	// Duration is how long the snapshot took.
+	Duration time.Duration
+}
+
+// Snapshot copies the files under srcPath in src into the snapshot store
+// dst as an immutable snapshot called name, or named after the current
+// time, like 20240102T150405Z, if name is empty. It fails with
+// omnistorage.ErrAlreadyExists if the snapshot exists.
+//
+// File content is stored once by its SHA-256, so a file that is unchanged
+// since an earlier snapshot, or that has the same content as another
+// file, takes no more space and is not uploaded again. A file with the
+// same size and modification time as in the latest snapshot of srcPath is
+// not even read. Wrap dst with omnistorage.Subpath to keep the store
+// under a prefix.
+//
+// The Filter and SkipEmptyFiles options select the files, and Logger
+// receives progress; other options are ignored. Snapshot stops at the
+// first error, before the manifest is written, so a failed snapshot does
+// not exist; content it stored is reused by the next attempt.
+func Snapshot(ctx context.Context, src, dst omnistorage.Backend, srcPath, name string, opts Options) (*SnapshotResult, error) {
+	start := time.Now()
+	if name == "" {
+		name = start.UTC().Format(snapshotTimeFormat)
+	}
+	if err := checkSnapshotName(name); err != nil {
+		return nil, err
+	}
+	exists, err := dst.Exists(ctx, manifestPath(name))
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("%w: snapshot %s", omnistorage.ErrAlreadyExists, name)
+	}
+
+	snapshots, err := Snapshots(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+	var previous map[string]FileInfo
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].SrcPath == srcPath {
+			previous = make(map[string]FileInfo, len(snapshots[i].Files))
+			for _, f := range snapshots[i].Files {
+				previous[f.Path] = f
+			}
+			break
+		}
+	}
+
+	logger := opts.logger()
+	files, err := listFiles(ctx, src, srcPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &SnapshotManifest{Name: name, Created: start, SrcPath: srcPath}
+	result := &SnapshotResult{Name: name}
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		full := f.Path
+		if f.Path != srcPath {
+			full = path.Join(srcPath, f.Path)
+		}
+		var stored bool
+		f, stored, err = storeContent(ctx, src, dst, full, f, previous)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", f.Path, err)
+		}
+		if stored {
+			result.Stored++
+			result.StoredBytes += f.Size
+		}
+		result.Files++
+		result.Bytes += f.Size
+		manifest.Files = append(manifest.Files, f)
+	}
+	slices.SortFunc(manifest.Files, func(a, b FileInfo) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := copyFileWithReader(ctx, dst, bytes.NewReader(data), manifestPath(name), "application/json"); err != nil {
+		return nil, err
+	}
+	result.Duration = time.Since(start)
+	logger.Info("snapshot complete",
+		slog.String("name", name),
+		slog.Int("files", result.Files),
+		slog.Int("stored", result.Stored),
+		slog.Int64("stored_bytes", result.StoredBytes),
+	)
+	return result, nil
+}
+
+// storeContent makes sure the content of the source file f, at full in
+// src, is in the store dst, and returns f with its SHA-256 and whether its
+// content was uploaded.
+func storeContent(ctx context.Context, src, dst omnistorage.Backend, full string, f FileInfo, previous map[string]FileInfo) (FileInfo, bool, error) {
+	if p, ok := previous[f.Path]; ok && p.Size == f.Size && !f.ModTime.IsZero() && tsync.Equal(p.ModTime, f.ModTime) {
+		f.Hash = p.Hash
+		return f, false, nil
+	}
+
+	var opts []omnistorage.WriterOption
+	info := statSource(ctx, src, full)
+	if info != nil && info.ContentType() != "" {
+		opts = append(opts, omnistorage.WithContentType(info.ContentType()))
+	}
+
+	// A source that knows the hash is copied only if the content is new
+	if sum := sourceChecksums(info)[omnistorage.HashSHA256]; sum != "" {
+		f.Hash = strings.ToLower(sum)
+		exists, err := dst.Exists(ctx, objectPath(f.Hash))
+		if err != nil || exists {
+			return f, false, err
+		}
+		return f, true, omnistorage.CopyPath(ctx, src, full, dst, objectPath(f.Hash), opts...)
+	}
+
+	// Otherwise upload while hashing, then keep the upload if it is new
+	tmp, err := tempObjectPath()
+	if err != nil {
+		return f, false, err
+	}
+	r, err := src.NewReader(ctx, full)
+	if err != nil {
+		return f, false, err
+	}
+	defer func() { _ = r.Close() }()
+	w, err := dst.NewWriter(ctx, tmp, opts...)
+	if err != nil {
+		return f, false, err
+	}
+	h := sha256.New()
+	if f.Size, err = io.Copy(w, io.TeeReader(r, h)); err != nil {
+		_ = w.Close()
+		_ = dst.Delete(ctx, tmp)
+		return f, false, err
+	}
+	if err := w.Close(); err != nil {
+		_ = dst.Delete(ctx, tmp)
+		return f, false, err
+	}
+	f.Hash = hex.EncodeToString(h.Sum(nil))
+	exists, err := dst.Exists(ctx, objectPath(f.Hash))
+	if err != nil || exists {
+		return f, false, errors.Join(err, dst.Delete(ctx, tmp))
+	}
+	return f, true, omnistorage.SmartMove(ctx, dst, tmp, dst, objectPath(f.Hash))
+}
+
+// Restore copies the files of the snapshot called name from the snapshot
+// store into dstPath in dst, as Sync would from a source holding the
+// snapshot's files, and with the same options. Set DeleteExtra to also
+// delete files that were not in the snapshot, rolling dstPath back to it
+// entirely, and DryRun to see what would change.
+func Restore(ctx context.Context, store, dst omnistorage.Backend, name, dstPath string, opts Options) (*Result, error) {
+	snapshot, err := OpenSnapshot(ctx, store, name)
+	if err != nil {
+		return nil, err
+	}
+	return Sync(ctx, snapshot, dst, "", dstPath, opts)
+}
+
+// Snapshots returns the manifests of the snapshots in the snapshot store,
+// oldest first.
+func Snapshots(ctx context.Context, store omnistorage.Backend) ([]*SnapshotManifest, error) {
+	paths, err := store.List(ctx, snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+	var manifests []*SnapshotManifest
+	for _, p := range paths {
+		name, ok := strings.CutSuffix(p, ".json")
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		m, err := readManifest(ctx, store, name)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	slices.SortStableFunc(manifests, func(a, b *SnapshotManifest) int {
+		return a.Created.Compare(b.Created)
+	})
+	return manifests, nil
+}
+
+// SnapshotBackend is a read-only view of a snapshot, returned by
+// OpenSnapshot. Its paths are those of the snapshot's files, and reads
+// return their content from the snapshot store. Writes, deletes and the
+// other changes fail with omnistorage.ErrPermissionDenied.
+type SnapshotBackend struct {
+	store    omnistorage.Backend
+	manifest *SnapshotManifest
+	files    map[string]FileInfo
+}
+
+// Ensure SnapshotBackend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*SnapshotBackend)(nil)
+
+// OpenSnapshot opens the snapshot called name in the snapshot store, to
+// browse or read its files. It fails with omnistorage.ErrNotFound if the
+// snapshot does not exist.
+func OpenSnapshot(ctx context.Context, store omnistorage.Backend, name string) (*SnapshotBackend, error) {
+	if err := checkSnapshotName(name); err != nil {
+		return nil, err
+	}
+	m, err := readManifest(ctx, store, name)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]FileInfo, len(m.Files))
+	for _, f := range m.Files {
+		files[f.Path] = f
+	}
+	return &SnapshotBackend{store: store, manifest: m, files: files}, nil
+}
+
+// Manifest returns the snapshot's manifest.
+func (b *SnapshotBackend) Manifest() *SnapshotManifest {
+	return b.manifest
+}
+
+// NewWriter fails: snapshots are read-only.
+func (b *SnapshotBackend) NewWriter(ctx context.Context, p string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	return nil, errSnapshotReadOnly
+}
+
+// NewReader reads a file of the snapshot.
+func (b *SnapshotBackend) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	f, err := b.file(p)
+	if err != nil {
+		return nil, err
+	}
+	return b.store.NewReader(ctx, objectPath(f.Hash), opts...)
+}
+
+// Exists reports whether the snapshot has the file p.
+func (b *SnapshotBackend) Exists(ctx context.Context, p string) (bool, error) {
+	_, err := b.file(p)
+	return err == nil, nil
+}
+
+// Delete fails: snapshots are read-only.
+func (b *SnapshotBackend) Delete(ctx context.Context, p string) error {
+	return errSnapshotReadOnly
+}
+
+// List lists the files of the snapshot under the directory prefix,
+// relative to it.
+func (b *SnapshotBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	prefix = omnistorage.CleanPrefix(prefix)
+	var paths []string
+	for _, f := range b.manifest.Files {
+		if rel, ok := strings.CutPrefix(f.Path, prefix); ok {
+			paths = append(paths, rel)
+		}
+	}
+	return paths, nil
+}
+
+// Close does nothing; the snapshot store stays open.
+func (b *SnapshotBackend) Close() error {
+	return nil
+}
+
+// Stat returns the size, modification time and SHA-256 the file p had
+// when the snapshot was made.
+func (b *SnapshotBackend) Stat(ctx context.Context, p string) (omnistorage.ObjectInfo, error) {
+	f, err := b.file(p)
+	if err != nil {
+		return nil, err
+	}
+	info := &omnistorage.BasicObjectInfo{
+		ObjectPath:    f.Path,
+		ObjectSize:    f.Size,
+		ObjectModTime: f.ModTime,
+		ObjectHashes:  map[omnistorage.HashType]string{omnistorage.HashSHA256: f.Hash},
+	}
+	if ext, ok := omnistorage.AsExtended(b.store); ok {
+		if obj, err := ext.Stat(ctx, objectPath(f.Hash)); err == nil {
+			info.ObjectContentType = obj.ContentType()
+		}
+	}
+	return info, nil
+}
+
+// Mkdir fails: snapshots are read-only.
+func (b *SnapshotBackend) Mkdir(ctx context.Context, p string) error {
+	return errSnapshotReadOnly
+}
+
+// Rmdir fails: snapshots are read-only.
+func (b *SnapshotBackend) Rmdir(ctx context.Context, p string) error {
+	return errSnapshotReadOnly
+}
+
+// Copy fails: snapshots are read-only.
+func (b *SnapshotBackend) Copy(ctx context.Context, src, dst string) error {
+	return errSnapshotReadOnly
+}
+
+// Move fails: snapshots are read-only.
+func (b *SnapshotBackend) Move(ctx context.Context, src, dst string) error {
+	return errSnapshotReadOnly
+}
+
+// Features reports Stat, prefix listing, SHA-256 hashes and, if the
+// snapshot store supports them, range reads.
+func (b *SnapshotBackend) Features() omnistorage.Features {
+	f := omnistorage.Features{
+		Stat:       true,
+		ListPrefix: true,
+		CanStream:  true,
+		Hashes:     []omnistorage.HashType{omnistorage.HashSHA256},
+	}
+	if ext, ok := omnistorage.AsExtended(b.store); ok {
+		f.RangeRead = ext.Features().RangeRead
+	}
+	return f
+}
+
+// file returns the snapshot's file p.
+func (b *SnapshotBackend) file(p string) (FileInfo, error) {
+	f, ok := b.files[strings.TrimPrefix(p, "/")]
+	if !ok {
+		return FileInfo{}, fmt.Errorf("%w: %s", omnistorage.ErrNotFound, p)
+	}
+	return f, nil
+}
+
+// checkSnapshotName rejects names that are not a single path element.
+func checkSnapshotName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%w: snapshot name %q", omnistorage.ErrInvalidPath, name)
+	}
+	return nil
+}
+
+func manifestPath(name string) string {
+	return snapshotDir + name + ".json"
+}
+
+func objectPath(hash string) string {
+	return objectDir + hash[:2] + "/" + hash
+}
+
+// tempObjectPath returns a unique path for content being uploaded.
+func tempObjectPath() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return objectDir + "tmp/" + hex.EncodeToString(b[:]), nil
+}
+
+// readManifest reads the manifest of the snapshot called name.
+func readManifest(ctx context.Context, store omnistorage.Backend, name string) (*SnapshotManifest, error) {
+	r, err := store.NewReader(ctx, manifestPath(name))
+	if errors.Is(err, omnistorage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: snapshot %s", omnistorage.ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	var m SnapshotManifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", name, err)
+	}
+	for _, f := range m.Files {
+		if len(f.Hash) != sha256.Size*2 || !isHex(f.Hash) {
+			return nil, fmt.Errorf("snapshot %s: %s has an invalid hash %q", name, f.Path, f.Hash)
+		}
+	}
+	return &m, nil
+}
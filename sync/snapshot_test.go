@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	for name, wrap := range map[string]func(*memory.Backend) omnistorage.Backend{
+		"extended": func(b *memory.Backend) omnistorage.Backend { return b },
+		"basic":    func(b *memory.Backend) omnistorage.Backend { return basicBackend{b} },
+	} {
+		src := memory.New()
+		store := memory.New()
+		writeFile(t, ctx, src, "data/a.txt", "alpha")
+		writeFile(t, ctx, src, "data/b.txt", "beta")
+		writeFile(t, ctx, src, "data/copy-of-a.txt", "alpha")
+
+		first, err := Snapshot(ctx, wrap(src), store, "data", "first", Options{})
+		if err != nil {
+			t.Fatalf("%s: Snapshot: %v", name, err)
+		}
+		if first.Files != 3 || first.Stored != 2 || first.StoredBytes != 9 {
+			t.Errorf("%s: first = %+v, want 3 files, 2 stored (9 bytes)", name, first)
+		}
+
+		// Change, add and delete files; only new content is stored
+		writeFile(t, ctx, src, "data/b.txt", "beta, edited")
+		writeFile(t, ctx, src, "data/c.txt", "alpha")
+		if err := src.Delete(ctx, "data/copy-of-a.txt"); err != nil {
+			t.Fatal(err)
+		}
+		second, err := Snapshot(ctx, wrap(src), store, "data", "second", Options{})
+		if err != nil {
+			t.Fatalf("%s: Snapshot: %v", name, err)
+		}
+		if second.Files != 3 || second.Stored != 1 {
+			t.Errorf("%s: second = %+v, want 3 files, 1 stored", name, second)
+		}
+		objects, _ := store.List(ctx, objectDir)
+		if len(objects) != 3 {
+			t.Errorf("%s: store objects = %v, want 3", name, objects)
+		}
+
+		if _, err := Snapshot(ctx, wrap(src), store, "data", "first", Options{}); !errors.Is(err, omnistorage.ErrAlreadyExists) {
+			t.Errorf("%s: repeated Snapshot error = %v, want ErrAlreadyExists", name, err)
+		}
+
+		snapshots, err := Snapshots(ctx, store)
+		if err != nil || len(snapshots) != 2 || snapshots[0].Name != "first" || snapshots[1].Name != "second" {
+			t.Fatalf("%s: Snapshots = %v, %v", name, snapshots, err)
+		}
+		if snapshots[0].Size() != 14 {
+			t.Errorf("%s: first size = %d, want 14", name, snapshots[0].Size())
+		}
+
+		// Browse the first snapshot
+		snap, err := OpenSnapshot(ctx, store, "first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths, _ := snap.List(ctx, "")
+		if !slices.Equal(paths, []string{"a.txt", "b.txt", "copy-of-a.txt"}) {
+			t.Errorf("%s: snapshot files = %v", name, paths)
+		}
+		if got := readBackend(t, snap, "b.txt"); got != "beta" {
+			t.Errorf("%s: b.txt in snapshot = %q", name, got)
+		}
+		if _, err := snap.NewWriter(ctx, "b.txt"); !errors.Is(err, omnistorage.ErrPermissionDenied) {
+			t.Errorf("%s: NewWriter error = %v, want ErrPermissionDenied", name, err)
+		}
+
+		// Roll back to the first snapshot
+		result, err := Restore(ctx, store, src, "first", "data", Options{DeleteExtra: true, SizeOnly: true})
+		if err != nil {
+			t.Fatalf("%s: Restore: %v", name, err)
+		}
+		if result.Copied != 1 || result.Updated != 1 || result.Deleted != 1 {
+			t.Errorf("%s: Restore = %+v, want 1 copied, updated and deleted", name, result)
+		}
+		verifyFile(t, ctx, src, "data/a.txt", "alpha")
+		verifyFile(t, ctx, src, "data/b.txt", "beta")
+		verifyFile(t, ctx, src, "data/copy-of-a.txt", "alpha")
+		if ok, _ := src.Exists(ctx, "data/c.txt"); ok {
+			t.Errorf("%s: data/c.txt was not deleted", name)
+		}
+
+		if _, err := Restore(ctx, store, src, "missing", "data", Options{}); !errors.Is(err, omnistorage.ErrNotFound) {
+			t.Errorf("%s: Restore(missing) error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestSnapshotName(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	store := memory.New()
+	writeFile(t, ctx, src, "a.txt", "alpha")
+
+	result, err := Snapshot(ctx, src, store, "", "", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Name) != len(snapshotTimeFormat) {
+		t.Errorf("default name = %q, want a timestamp", result.Name)
+	}
+	for _, name := range []string{"a/b", "..", `c:\x`} {
+		if _, err := Snapshot(ctx, src, store, "", name, Options{}); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("Snapshot(%q) error = %v, want ErrInvalidPath", name, err)
+		}
+	}
+}
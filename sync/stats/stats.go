@@ -0,0 +1,340 @@
+// Package stats accumulates the results of repeated sync runs into totals,
+// rates and a breakdown of errors by kind, and renders them as text or
+// JSON, such as for a nightly report:
+//
+//	acc := stats.New()
+//	for _, job := range jobs {
+//	    result, err := sync.Sync(ctx, job.Src, job.Dst, "", "", job.Options)
+//	    acc.Add(job.Name, result, err)
+//	}
+//	_ = acc.Summary().WriteText(os.Stdout)
+package stats
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	gosync "sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/sync"
+)
+
+// DefaultRecentErrors is the number of errors kept for Summary.RecentErrors
+// unless WithRecentErrors is given.
+const DefaultRecentErrors = 10
+
+// Option configures Stats.
+type Option func(*Stats)
+
+// WithRecentErrors sets the number of most recent errors kept, as
+// examples for the report. 0 keeps none.
+func WithRecentErrors(n int) Option {
+	return func(s *Stats) {
+		s.recentMax = max(n, 0)
+	}
+}
+
+// ErrorSample is one error of a run.
+type ErrorSample struct {
+	// Run is the name the run was added with.
+	Run string `json:"run,omitempty"`
+
+	// Path is the file the error occurred on, or "" for an error that
+	// stopped the run.
+	Path string `json:"path,omitempty"`
+
+	// Op is the operation that failed, such as "copy" or "delete".
+	Op string `json:"op,omitempty"`
+
+	// Kind is the omnistorage.ErrorKind of the error, such as "timeout".
+	Kind string `json:"kind"`
+
+	// Message is the error message.
+	Message string `json:"message"`
+
+	// Time is when the run was added.
+	Time time.Time `json:"time"`
+}
+
+// Summary is the accumulated outcome of the runs added to Stats.
+type Summary struct {
+	// Runs is the number of runs added.
+	Runs int `json:"runs"`
+
+	// Failed is the number of runs that returned an error or had file
+	// errors.
+	Failed int `json:"failed"`
+
+	// DryRuns is the number of runs that were dry runs. Their counts are
+	// included in the totals.
+	DryRuns int `json:"dry_runs,omitempty"`
+
+	// Copied, Updated and Deleted are the files copied, updated and
+	// deleted, in both directions for bisync runs.
+	Copied  int `json:"copied"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+
+	// Skipped is the files already in sync.
+	Skipped int `json:"skipped"`
+
+	// Conflicts is the files changed on both sides of bisync runs.
+	Conflicts int `json:"conflicts,omitempty"`
+
+	// BytesTransferred is the total bytes transferred.
+	BytesTransferred int64 `json:"bytes_transferred"`
+
+	// Duration is the total duration of the runs, and Longest the
+	// longest of them.
+	Duration time.Duration `json:"duration"`
+	Longest  time.Duration `json:"longest"`
+
+	// BytesPerSecond and FilesPerSecond are the transfer rates over
+	// Duration.
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	FilesPerSecond float64 `json:"files_per_second"`
+
+	// Errors is the number of file errors, plus the errors that stopped
+	// runs.
+	Errors int `json:"errors"`
+
+	// ErrorKinds counts the errors by omnistorage.ErrorKind, such as
+	// "not found" or "throttled".
+	ErrorKinds map[string]int `json:"error_kinds,omitempty"`
+
+	// ErrorOps counts the file errors by operation, such as "copy".
+	ErrorOps map[string]int `json:"error_ops,omitempty"`
+
+	// RecentErrors holds the most recent errors, oldest first.
+	RecentErrors []ErrorSample `json:"recent_errors,omitempty"`
+
+	// First and Last are when the first and last runs were added.
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+// Stats accumulates the results of sync runs. It is safe for concurrent
+// use.
+type Stats struct {
+	mu        gosync.Mutex
+	sum       Summary
+	recentMax int
+	now       func() time.Time
+}
+
+// New returns empty Stats.
+func New(opts ...Option) *Stats {
+	s := &Stats{recentMax: DefaultRecentErrors, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Reset()
+	return s
+}
+
+// Reset clears the accumulated results.
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sum = Summary{ErrorKinds: map[string]int{}, ErrorOps: map[string]int{}}
+}
+
+// Add adds the result and error of a Sync, Apply or CopyDir run, named
+// run in error samples. result may be nil if the run failed before it
+// started.
+func (s *Stats) Add(run string, result *sync.Result, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.begin()
+	if result != nil {
+		s.sum.Copied += result.Copied
+		s.sum.Updated += result.Updated
+		s.sum.Deleted += result.Deleted
+		s.sum.Skipped += result.Skipped
+		s.sum.BytesTransferred += result.BytesTransferred
+		s.addDuration(result.Duration)
+		if result.DryRun {
+			s.sum.DryRuns++
+		}
+		s.addFileErrors(run, result.Errors)
+	}
+	s.end(run, err, result != nil && !result.Success())
+}
+
+// AddBisync adds the result and error of a Bisync run, named run in error
+// samples. result may be nil if the run failed before it started.
+func (s *Stats) AddBisync(run string, result *sync.BisyncResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.begin()
+	if result != nil {
+		s.sum.Copied += result.TotalCopied()
+		s.sum.Updated += result.TotalUpdated()
+		s.sum.Deleted += result.TotalDeleted()
+		s.sum.Skipped += result.Skipped
+		s.sum.Conflicts += len(result.Conflicts)
+		s.sum.BytesTransferred += result.BytesTransferred
+		s.addDuration(result.Duration)
+		if result.DryRun {
+			s.sum.DryRuns++
+		}
+		s.addFileErrors(run, result.Errors)
+	}
+	s.end(run, err, result != nil && !result.Success())
+}
+
+// begin counts a run. s.mu must be held.
+func (s *Stats) begin() {
+	now := s.now()
+	if s.sum.Runs == 0 {
+		s.sum.First = now
+	}
+	s.sum.Runs++
+	s.sum.Last = now
+}
+
+// end records the error that stopped a run, and counts the run as failed
+// if it has one or hadFileErrors. s.mu must be held.
+func (s *Stats) end(run string, err error, hadFileErrors bool) {
+	if err != nil {
+		s.addError(ErrorSample{Run: run, Message: err.Error()}, err)
+	}
+	if err != nil || hadFileErrors {
+		s.sum.Failed++
+	}
+}
+
+func (s *Stats) addDuration(d time.Duration) {
+	s.sum.Duration += d
+	s.sum.Longest = max(s.sum.Longest, d)
+}
+
+func (s *Stats) addFileErrors(run string, errs []sync.FileError) {
+	for _, fe := range errs {
+		s.sum.ErrorOps[fe.Op]++
+		msg := ""
+		if fe.Err != nil {
+			msg = fe.Err.Error()
+		}
+		s.addError(ErrorSample{Run: run, Path: fe.Path, Op: fe.Op, Message: msg}, fe.Err)
+	}
+}
+
+func (s *Stats) addError(sample ErrorSample, err error) {
+	kind := omnistorage.KindOf(err).String()
+	if errors.Is(err, omnistorage.ErrChecksumMismatch) {
+		kind = "checksum mismatch"
+	}
+	s.sum.Errors++
+	s.sum.ErrorKinds[kind]++
+	if s.recentMax == 0 {
+		return
+	}
+	sample.Kind = kind
+	sample.Time = s.sum.Last
+	if len(s.sum.RecentErrors) == s.recentMax {
+		s.sum.RecentErrors = slices.Delete(s.sum.RecentErrors, 0, 1)
+	}
+	s.sum.RecentErrors = append(s.sum.RecentErrors, sample)
+}
+
+// Summary returns the accumulated results.
+func (s *Stats) Summary() Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := s.sum
+	sum.ErrorKinds = maps.Clone(s.sum.ErrorKinds)
+	sum.ErrorOps = maps.Clone(s.sum.ErrorOps)
+	sum.RecentErrors = slices.Clone(s.sum.RecentErrors)
+	if secs := sum.Duration.Seconds(); secs > 0 {
+		sum.BytesPerSecond = float64(sum.BytesTransferred) / secs
+		sum.FilesPerSecond = float64(sum.Copied+sum.Updated) / secs
+	}
+	return sum
+}
+
+// WriteText writes the summary as aligned text lines, with the errors by
+// kind and the recent errors.
+func (sum Summary) WriteText(w io.Writer) error {
+	p := &printer{w: w}
+	p.printf("Runs:        %d (%d failed)\n", sum.Runs, sum.Failed)
+	if sum.Runs > 0 {
+		p.printf("Period:      %s to %s\n", sum.First.Format(time.RFC3339), sum.Last.Format(time.RFC3339))
+	}
+	p.printf("Copied:      %d\n", sum.Copied)
+	p.printf("Updated:     %d\n", sum.Updated)
+	p.printf("Deleted:     %d\n", sum.Deleted)
+	p.printf("Skipped:     %d\n", sum.Skipped)
+	if sum.Conflicts > 0 {
+		p.printf("Conflicts:   %d\n", sum.Conflicts)
+	}
+	p.printf("Transferred: %s in %s (%s/s, longest run %s)\n",
+		formatBytes(sum.BytesTransferred), sum.Duration.Round(time.Second),
+		formatBytes(int64(sum.BytesPerSecond)), sum.Longest.Round(time.Second))
+	p.printf("Errors:      %d\n", sum.Errors)
+	for _, kind := range sortedByCount(sum.ErrorKinds) {
+		p.printf("  %-20s %d\n", kind+":", sum.ErrorKinds[kind])
+	}
+	if len(sum.RecentErrors) > 0 {
+		p.printf("Recent errors:\n")
+		for _, e := range sum.RecentErrors {
+			if e.Path != "" {
+				p.printf("  [%s] %s %s: %s\n", e.Run, e.Op, e.Path, e.Message)
+			} else {
+				p.printf("  [%s] %s\n", e.Run, e.Message)
+			}
+		}
+	}
+	return p.err
+}
+
+// WriteJSON writes the summary as indented JSON.
+func (sum Summary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sum)
+}
+
+// printer writes formatted lines, keeping the first error.
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, args ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+// sortedByCount returns the keys of counts, most frequent first.
+func sortedByCount(counts map[string]int) []string {
+	keys := slices.Collect(maps.Keys(counts))
+	slices.SortFunc(keys, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return keys
+}
+
+// formatBytes formats n with a binary unit, such as 1.5 GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
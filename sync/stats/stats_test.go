@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/sync"
+)
+
+func TestStats(t *testing.T) {
+	s := New(WithRecentErrors(2))
+	clock := time.Date(2024, 1, 10, 2, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+
+	s.Add("nightly", &sync.Result{
+		Copied: 3, Updated: 1, Skipped: 10,
+		BytesTransferred: 4 << 20,
+		Duration:         2 * time.Second,
+	}, nil)
+	clock = clock.Add(time.Hour)
+	s.Add("nightly", &sync.Result{
+		Copied: 1, Deleted: 2,
+		BytesTransferred: 2 << 20,
+		Duration:         4 * time.Second,
+		Errors: []sync.FileError{
+			{Path: "a.txt", Op: "copy", Err: fmt.Errorf("read: %w", omnistorage.ErrNotFound)},
+			{Path: "b.txt", Op: "copy", Err: &omnistorage.Error{Kind: omnistorage.KindTimeout, Err: errors.New("stalled")}},
+		},
+	}, nil)
+	s.AddBisync("laptop", &sync.BisyncResult{
+		CopiedToPath1: 1, CopiedToPath2: 1,
+		Conflicts: []sync.Conflict{{Path: "c.txt"}},
+	}, nil)
+	s.Add("archive", nil, context.DeadlineExceeded)
+
+	sum := s.Summary()
+	if sum.Runs != 4 || sum.Failed != 2 {
+		t.Errorf("Runs = %d, Failed = %d, want 4, 2", sum.Runs, sum.Failed)
+	}
+	if sum.Copied != 6 || sum.Updated != 1 || sum.Deleted != 2 || sum.Skipped != 10 || sum.Conflicts != 1 {
+		t.Errorf("counts = %+v", sum)
+	}
+	if sum.BytesTransferred != 6<<20 || sum.Duration != 6*time.Second || sum.Longest != 4*time.Second {
+		t.Errorf("bytes = %d, duration = %v, longest = %v", sum.BytesTransferred, sum.Duration, sum.Longest)
+	}
+	if sum.BytesPerSecond != float64(1<<20) {
+		t.Errorf("BytesPerSecond = %v, want %v", sum.BytesPerSecond, float64(1<<20))
+	}
+	if sum.Errors != 3 || sum.ErrorKinds["timeout"] != 2 || sum.ErrorKinds["not found"] != 1 || sum.ErrorOps["copy"] != 2 {
+		t.Errorf("Errors = %d, ErrorKinds = %v, ErrorOps = %v", sum.Errors, sum.ErrorKinds, sum.ErrorOps)
+	}
+	if len(sum.RecentErrors) != 2 || sum.RecentErrors[0].Path != "b.txt" || sum.RecentErrors[1].Run != "archive" {
+		t.Errorf("RecentErrors = %+v", sum.RecentErrors)
+	}
+	if !sum.First.Equal(time.Date(2024, 1, 10, 2, 0, 0, 0, time.UTC)) || !sum.Last.Equal(clock) {
+		t.Errorf("First = %v, Last = %v", sum.First, sum.Last)
+	}
+
+	var text bytes.Buffer
+	if err := sum.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Runs:        4 (2 failed)", "6.0 MiB in 6s (1.0 MiB/s", "timeout:", "[nightly] copy b.txt: stalled"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := sum.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Summary
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Errors != 3 || decoded.ErrorKinds["timeout"] != 2 {
+		t.Errorf("JSON round trip = %+v, %v", decoded, err)
+	}
+
+	s.Reset()
+	if sum := s.Summary(); sum.Runs != 0 || len(sum.ErrorKinds) != 0 {
+		t.Errorf("after Reset: %+v", sum)
+	}
+}
@@ -0,0 +1,128 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	gosync "sync"
+
+	"github.com/grokify/omnistorage"
+)
+
+// defaultStripeSize is the size of a stripe unless Options.StripeSize is
+// set.
+const defaultStripeSize = 64 << 20
+
+// stripeBlockSize is the size of the writes a stripe is copied in.
+const stripeBlockSize = 1 << 20
+
+// stripeTarget returns the destination as a Patcher if the copy of a
+// source file with info should be striped.
+func stripeTarget(sctx *syncContext, src, dst omnistorage.Backend, info omnistorage.ObjectInfo) (omnistorage.Patcher, bool) {
+	opts := sctx.opts
+	if opts.StripeCutoff <= 0 || info == nil || info.Size() < opts.StripeCutoff || opts.TransformReader != nil {
+		return nil, false
+	}
+	if _, ok := src.(compressedSource); ok {
+		return nil, false
+	}
+	if ps, ok := src.(parallelSource); ok {
+		src = ps.Backend
+	}
+	ext, ok := omnistorage.AsExtended(src)
+	if !ok || !ext.Features().RangeRead {
+		return nil, false
+	}
+	patcher, ok := dst.(omnistorage.Patcher)
+	return patcher, ok
+}
+
+// stripedCopy copies the size bytes of srcPath to dstPath as stripes of
+// StripeSize bytes, Stripes of them at a time, each read with a ranged
+// read and written in place. The destination file is created with
+// writerOpts and sized first. A failed copy removes it.
+func stripedCopy(ctx context.Context, sctx *syncContext, src, dst omnistorage.Backend, patcher omnistorage.Patcher, srcPath, dstPath string, size int64, writerOpts []omnistorage.WriterOption) error {
+	stripeSize := sctx.opts.StripeSize
+	if stripeSize <= 0 {
+		stripeSize = defaultStripeSize
+	}
+	stripes := sctx.opts.Stripes
+	if stripes <= 0 {
+		stripes = 4
+	}
+
+	w, err := dst.NewWriter(ctx, dstPath, writerOpts...)
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := patcher.Truncate(ctx, dstPath, size); err != nil {
+		_ = dst.Delete(context.WithoutCancel(ctx), dstPath)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sem := make(chan struct{}, stripes)
+	var wg gosync.WaitGroup
+	var once gosync.Once
+	var firstErr error
+	for off := int64(0); off < size; off += stripeSize {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := copyStripe(ctx, sctx, src, patcher, srcPath, dstPath, off, min(stripeSize, size-off)); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	err = firstErr
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = dst.Delete(context.WithoutCancel(ctx), dstPath)
+		return err
+	}
+	return nil
+}
+
+// copyStripe copies the length bytes of srcPath at off to dstPath.
+func copyStripe(ctx context.Context, sctx *syncContext, src omnistorage.Backend, patcher omnistorage.Patcher, srcPath, dstPath string, off, length int64) error {
+	r, err := src.NewReader(ctx, srcPath, omnistorage.WithOffset(off), omnistorage.WithLimit(length))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	buf := make([]byte, min(stripeBlockSize, length))
+	for end := off + length; off < end; {
+		n, err := io.ReadFull(r, buf[:min(int64(len(buf)), end-off)])
+		if err != nil {
+			return fmt.Errorf("read %s at %d: %w", srcPath, off, err)
+		}
+		if err := sctx.rateLimiter.WaitN(ctx, n); err != nil {
+			return err
+		}
+		if err := patcher.WriteAt(ctx, dstPath, buf[:n], off); err != nil {
+			return err
+		}
+		off += int64(n)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/file"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestSyncStripedCopy(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	src := &rangeSource{Backend: mem}
+	dst := file.New(file.Config{Root: t.TempDir(), CreateDirs: true})
+
+	big := strings.Repeat("0123456789", 10000)
+	writeFile(t, ctx, mem, "data/big.bin", big)
+	writeFile(t, ctx, mem, "data/small.txt", "hello")
+
+	opts := Options{StripeCutoff: 50000, StripeSize: 16384, Stripes: 3}
+	result, err := Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 2 || result.BytesTransferred != int64(len(big))+5 {
+		t.Errorf("Copied = %d, BytesTransferred = %d", result.Copied, result.BytesTransferred)
+	}
+	if n := src.ranged.Load(); n != 7 {
+		t.Errorf("ranged reads = %d, want 7 (big.bin only)", n)
+	}
+	if got := readBackend(t, dst, "data/big.bin"); got != big {
+		t.Errorf("big.bin has %d bytes, want %d", len(got), len(big))
+	}
+	if got := readBackend(t, dst, "data/small.txt"); got != "hello" {
+		t.Errorf("small.txt = %q, want %q", got, "hello")
+	}
+
+	// A failed stripe removes the partial file
+	failing := &failingRange{rangeSource: src, offset: 32768}
+	writeFile(t, ctx, mem, "data/big.bin", big+"more")
+	result, _ = Sync(ctx, failing, dst, "", "", opts)
+	if len(result.Errors) != 1 {
+		t.Fatalf("Errors = %v, want 1", result.Errors)
+	}
+	if exists, _ := dst.Exists(ctx, "data/big.bin"); exists {
+		t.Error("partial big.bin left behind")
+	}
+}
+
+// failingRange fails ranged reads at offset.
+type failingRange struct {
+	*rangeSource
+	offset int64
+}
+
+func (s *failingRange) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if omnistorage.ApplyReaderOptions(opts...).Offset == s.offset && len(opts) > 0 {
+		return nil, errors.New("connection reset")
+	}
+	return s.rangeSource.NewReader(ctx, p, opts...)
+}
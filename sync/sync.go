@@ -2,14 +2,19 @@ package sync
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"path"
+	"slices"
+	"strings"
 	gosync "sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/metrics"
 	"github.com/grokify/omnistorage/sync/filter"
 )
 
@@ -18,6 +23,8 @@ type syncContext struct {
 	opts        Options
 	rateLimiter *tokenBucket
 	logger      *slog.Logger
+	adaptive    *adaptiveLimiter
+	deltaSaved  atomic.Int64 // bytes not written thanks to delta transfers
 }
 
 // Sync synchronizes files from source to destination.
@@ -28,26 +35,23 @@ type syncContext struct {
 //
 // Both backends should support List operation. If the source backend implements
 // ExtendedBackend with Stat, it will be used for more accurate file comparison.
+//
+// Sync is equivalent to Plan followed by Apply.
 func Sync(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) (*Result, error) {
+	result, _, err := runSync(ctx, src, dst, srcPath, dstPath, opts)
+	return result, err
+}
+
+// runSync plans and applies a sync, and also returns the plan.
+func runSync(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath string, opts Options) (*Result, *ActionPlan, error) {
 	startTime := time.Now()
-	result := &Result{DryRun: opts.DryRun}
 
 	// Set default concurrency
 	if opts.Concurrency <= 0 {
 		opts.Concurrency = 4
 	}
 
-	// Get logger
-	logger := opts.logger()
-
-	// Create sync context with shared state
-	sctx := &syncContext{
-		opts:        opts,
-		rateLimiter: newTokenBucket(opts.BandwidthLimit),
-		logger:      logger,
-	}
-
-	logger.Info("starting sync",
+	opts.logger().Info("starting sync",
 		slog.String("src_path", srcPath),
 		slog.String("dst_path", dstPath),
 		slog.Bool("delete_extra", opts.DeleteExtra),
@@ -55,85 +59,76 @@ func Sync(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 		slog.Int("concurrency", opts.Concurrency),
 	)
 
-	// Scan source files
-	if opts.Progress != nil {
-		opts.Progress(Progress{Phase: PhaseScanning, CurrentFile: srcPath})
-	}
-
-	logger.Debug("scanning source files", slog.String("path", srcPath))
-	srcFiles, err := listFiles(ctx, src, srcPath, opts)
+	plan, err := Plan(ctx, src, dst, srcPath, dstPath, opts)
 	if err != nil {
-		logger.Error("failed to list source files", slog.String("path", srcPath), slog.Any("error", err))
-		return nil, err
+		return nil, nil, err
 	}
-	logger.Debug("source scan complete", slog.Int("files", len(srcFiles)))
+	result, err := apply(ctx, src, dst, plan, opts, startTime)
+	return result, plan, err
+}
 
-	// Scan destination files
-	logger.Debug("scanning destination files", slog.String("path", dstPath))
-	dstFiles, err := listFiles(ctx, dst, dstPath, opts)
-	if err != nil {
-		logger.Error("failed to list destination files", slog.String("path", dstPath), slog.Any("error", err))
-		return nil, err
-	}
-	logger.Debug("destination scan complete", slog.Int("files", len(dstFiles)))
+// apply executes plan. startTime is the start of the run, including
+// planning, for Result.Duration and Options.MaxDuration.
+func apply(ctx context.Context, src, dst omnistorage.Backend, plan *ActionPlan, opts Options, startTime time.Time) (*Result, error) {
+	result := &Result{DryRun: opts.DryRun, Skipped: plan.Skipped, Resumed: plan.Resumed, Collisions: plan.Collisions}
+	srcPath, dstPath := plan.SrcPath, plan.DstPath
 
-	// Build destination file map for quick lookup
-	dstMap := make(map[string]FileInfo)
-	for _, f := range dstFiles {
-		dstMap[f.Path] = f
+	// Set default concurrency
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
 	}
 
-	// Compare and determine actions
-	if opts.Progress != nil {
-		opts.Progress(Progress{Phase: PhaseComparing, TotalFiles: len(srcFiles)})
-	}
+	// Get logger
+	logger := opts.logger()
+	budget := newTransferBudget(opts, startTime)
 
-	type copyAction struct {
-		file     FileInfo
-		isUpdate bool // true if updating existing file, false if new
+	// Create sync context with shared state
+	sctx := &syncContext{
+		opts:        opts,
+		rateLimiter: newTokenBucket(opts.BandwidthLimit),
+		logger:      logger,
+		adaptive:    newAdaptiveLimiter(opts, opts.Concurrency),
 	}
 
-	var toCopy []copyAction
-	var toDelete []string
-
-	for _, srcFile := range srcFiles {
-		if srcFile.IsDir {
-			continue // Skip directories, they're created as needed
+	var toMkdir, toCopy, toDelete []Action
+	for _, a := range plan.Actions {
+		switch a.Type {
+		case ActionMkdir:
+			toMkdir = append(toMkdir, a)
+		case ActionDelete:
+			toDelete = append(toDelete, a)
+		default:
+			toCopy = append(toCopy, a)
 		}
+	}
 
-		dstFile, exists := dstMap[srcFile.Path]
-		if !exists {
-			// New file
-			toCopy = append(toCopy, copyAction{file: srcFile, isUpdate: false})
-		} else if !dstFile.IsDir && NeedsUpdate(srcFile, dstFile, opts) {
-			// File needs update
-			if !opts.IgnoreExisting {
-				toCopy = append(toCopy, copyAction{file: srcFile, isUpdate: true})
-			} else {
-				result.Skipped++
-			}
-		} else {
-			result.Skipped++
-		}
-		delete(dstMap, srcFile.Path)
+	if opts.Confirm != nil && !opts.DryRun {
+		toCopy = opts.confirmed(toCopy, result)
+		toDelete = opts.confirmed(toDelete, result)
 	}
 
-	// Remaining files in dstMap exist only in destination
-	if opts.DeleteExtra {
-		for p, f := range dstMap {
-			if !f.IsDir {
-				toDelete = append(toDelete, p)
-			}
-		}
+	// Create empty directories before the copies, which create the
+	// directories they need themselves
+	if err := makeDirs(ctx, dst, dstPath, toMkdir, opts, result); err != nil {
+		result.Duration = time.Since(startTime)
+		return result, err
+	}
+	if opts.DryRun {
+		result.Actions = append(result.Actions, toMkdir...)
+	}
+	if opts.MaxErrors > 0 && len(result.Errors) >= opts.MaxErrors {
+		result.Duration = time.Since(startTime)
+		return result, nil
 	}
 
 	// Calculate total bytes to transfer
 	var totalBytes int64
 	for _, action := range toCopy {
-		totalBytes += action.file.Size
+		totalBytes += action.File.Size
 	}
 
 	// Copy files using worker pool for parallel transfers
+	opts.Tracker.begin(opts.Concurrency, len(toCopy), totalBytes)
 	if opts.Progress != nil {
 		opts.Progress(Progress{
 			Phase:      PhaseTransferring,
@@ -146,16 +141,32 @@ func Sync(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 	var filesTransferred atomic.Int32
 	var copied atomic.Int32
 	var updated atomic.Int32
+	var skipped atomic.Int32
 	var errorsMu gosync.Mutex
+	var aborted error // set by the first hook to abort, guarded by errorsMu
 
 	// Use worker pool for parallel transfers
-	workCh := make(chan copyAction, len(toCopy))
+	workCh := make(chan Action, len(toCopy))
 	var wg gosync.WaitGroup
 
+	// Files for Options.Manifest, and in dry runs the copies that would
+	// have been made, guarded by errorsMu
+	manifest := []ManifestFile{}
+	var planned []Action
+
 	// Context for cancellation
 	copyCtx, cancelCopy := context.WithCancel(ctx)
 	defer cancelCopy()
 
+	abort := func(op, p string) {
+		errorsMu.Lock()
+		if aborted == nil {
+			aborted = abortError(op, p)
+		}
+		errorsMu.Unlock()
+		cancelCopy()
+	}
+
 	// Start workers
 	for i := 0; i < opts.Concurrency; i++ {
 		wg.Add(1)
@@ -167,14 +178,30 @@ func Sync(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 					return
 				default:
 				}
+				opts.Tracker.dequeue()
 
-				srcFullPath := path.Join(srcPath, action.file.Path)
-				dstFullPath := path.Join(dstPath, action.file.Path)
+				switch opts.Hooks.beforeCopy(copyCtx, action.File) {
+				case Skip:
+					skipped.Add(1)
+					opts.Tracker.skip(action.File)
+					continue
+				case Abort:
+					abort("copy", action.File.Path)
+					return
+				}
+
+				if !budget.take(action.File.Size) {
+					opts.Tracker.skip(action.File)
+					continue
+				}
+
+				srcFullPath := path.Join(srcPath, action.File.Path)
+				dstFullPath := path.Join(dstPath, action.destPath())
 
 				if opts.Progress != nil {
 					opts.Progress(Progress{
 						Phase:            PhaseTransferring,
-						CurrentFile:      action.file.Path,
+						CurrentFile:      action.File.Path,
 						FilesTransferred: int(filesTransferred.Load()),
 						TotalFiles:       len(toCopy),
 						BytesTransferred: bytesTransferred.Load(),
@@ -182,32 +209,78 @@ func Sync(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 					})
 				}
 
-				if !opts.DryRun {
-					err := copyFileWithContext(copyCtx, sctx, src, dst, srcFullPath, dstFullPath)
+				if opts.DryRun {
+					errorsMu.Lock()
+					planned = append(planned, action)
+					errorsMu.Unlock()
+				} else {
+					op := metrics.TransferCopy
+					if action.Type == ActionUpdate {
+						op = metrics.TransferUpdate
+					}
+					start := time.Now()
+					exists, err := opts.refusesOverwrite(copyCtx, dst, dstFullPath)
+					if exists {
+						logger.Debug("destination exists, not overwriting", slog.String("path", action.File.Path))
+						skipped.Add(1)
+						opts.Tracker.skip(action.File)
+						continue
+					}
+					if err == nil {
+						opts.Tracker.startFile(i, action.File, srcFullPath)
+						err = sctx.adaptive.do(copyCtx, func(ctx context.Context) error {
+							return copyFileWithContext(ctx, sctx, src, dst, srcFullPath, dstFullPath)
+						})
+					}
+					opts.Tracker.endFile(i, err)
+					observe(opts.Metrics, op, action.File.Path, action.File.Size, start, err)
 					if err != nil {
-						errorsMu.Lock()
-						result.Errors = append(result.Errors, FileError{
-							Path: action.file.Path,
+						fe := FileError{
+							Path: action.File.Path,
 							Op:   "copy",
 							Err:  err,
-						})
+						}
+						decision := opts.Hooks.onError(copyCtx, action.File, fe)
+						if decision == Skip {
+							continue
+						}
+						errorsMu.Lock()
+						result.Errors = append(result.Errors, fe)
 						shouldStop := opts.MaxErrors > 0 && len(result.Errors) >= opts.MaxErrors
 						errorsMu.Unlock()
+						if decision == Abort {
+							abort(fe.Op, fe.Path)
+							return
+						}
 						if shouldStop {
 							cancelCopy()
 							return
 						}
 						continue
 					}
+					opts.Hooks.afterCopy(copyCtx, action.File)
+					if err := opts.Journal.record(copyCtx, action.File); err != nil {
+						logger.Warn("failed to save journal", slog.Any("error", err))
+					}
+					if opts.Manifest != nil {
+						mf, err := manifestFile(copyCtx, dst, dstFullPath, action.destPath())
+						errorsMu.Lock()
+						if err != nil {
+							result.Errors = append(result.Errors, FileError{Path: action.File.Path, Op: "manifest", Err: err})
+						} else {
+							manifest = append(manifest, mf)
+						}
+						errorsMu.Unlock()
+					}
 				}
 
 				// Determine if this was a new file or update
-				if action.isUpdate {
+				if action.Type == ActionUpdate {
 					updated.Add(1)
 				} else {
 					copied.Add(1)
 				}
-				bytesTransferred.Add(action.file.Size)
+				bytesTransferred.Add(action.File.Size)
 				filesTransferred.Add(1)
 			}
 		}()
@@ -227,18 +300,42 @@ sendLoop:
 	// Wait for workers to finish
 	wg.Wait()
 
+	// Save the journal even if the run was cancelled, so it can resume
+	if err := opts.Journal.Flush(context.WithoutCancel(ctx)); err != nil {
+		logger.Warn("failed to save journal", slog.Any("error", err))
+	}
+
 	result.Copied = int(copied.Load())
 	result.Updated = int(updated.Load())
 	result.BytesTransferred = bytesTransferred.Load()
+	result.DeltaSaved = sctx.deltaSaved.Load()
+	result.Throttled = sctx.adaptive.throttledCount()
+	if opts.DryRun {
+		// Workers finish in any order; keep the order of the plan
+		order := make(map[string]int, len(toCopy))
+		for i, a := range toCopy {
+			order[a.File.Path] = i
+		}
+		slices.SortFunc(planned, func(a, b Action) int { return order[a.File.Path] - order[b.File.Path] })
+		result.Actions = append(result.Actions, planned...)
+	}
+	result.Skipped += int(skipped.Load())
 
 	// Check if context was cancelled
 	if ctx.Err() != nil {
 		result.Duration = time.Since(startTime)
 		return result, ctx.Err()
 	}
+	if aborted != nil {
+		logger.Warn("sync aborted", slog.Any("error", aborted))
+		result.Duration = time.Since(startTime)
+		return result, aborted
+	}
 
-	// Delete extra files
-	if opts.DeleteExtra && len(toDelete) > 0 {
+	// Delete extra files, unless a transfer limit stopped the copies: the
+	// destination is only partially synced.
+	if len(toDelete) > 0 && budget.err() == nil {
+		opts.Tracker.setPhase(PhaseDeleting)
 		if opts.Progress != nil {
 			opts.Progress(Progress{
 				Phase:      PhaseDeleting,
@@ -246,7 +343,9 @@ sendLoop:
 			})
 		}
 
-		for _, p := range toDelete {
+		deleter := opts.deleter(dst, startTime)
+		for _, a := range toDelete {
+			f := a.File
 			select {
 			case <-ctx.Done():
 				result.Duration = time.Since(startTime)
@@ -254,24 +353,52 @@ sendLoop:
 			default:
 			}
 
-			dstFullPath := path.Join(dstPath, p)
+			if budget.expired() {
+				break
+			}
+
+			switch opts.Hooks.beforeDelete(ctx, f) {
+			case Skip:
+				result.Skipped++
+				continue
+			case Abort:
+				result.Duration = time.Since(startTime)
+				return result, abortError("delete", f.Path)
+			}
+
+			dstFullPath := path.Join(dstPath, a.destPath())
 
 			if opts.Progress != nil {
 				opts.Progress(Progress{
 					Phase:        PhaseDeleting,
-					CurrentFile:  p,
+					CurrentFile:  f.Path,
 					FilesDeleted: result.Deleted,
 					TotalFiles:   len(toDelete),
 				})
 			}
 
 			if !opts.DryRun {
-				if err := dst.Delete(ctx, dstFullPath); err != nil {
-					result.Errors = append(result.Errors, FileError{
-						Path: p,
+				start := time.Now()
+				err := opts.withFileTimeout(ctx, "delete", dstFullPath, func(ctx context.Context) error {
+					return deleteUnlocked(ctx, dst, deleter, dstFullPath)
+				})
+				observe(opts.Metrics, metrics.TransferDelete, f.Path, 0, start, err)
+				opts.Tracker.deleteDone(err)
+				if err != nil {
+					fe := FileError{
+						Path: f.Path,
 						Op:   "delete",
 						Err:  err,
-					})
+					}
+					decision := opts.Hooks.onError(ctx, f, fe)
+					if decision == Skip {
+						continue
+					}
+					result.Errors = append(result.Errors, fe)
+					if decision == Abort {
+						result.Duration = time.Since(startTime)
+						return result, abortError(fe.Op, fe.Path)
+					}
 					if opts.MaxErrors > 0 && len(result.Errors) >= opts.MaxErrors {
 						result.Duration = time.Since(startTime)
 						return result, nil
@@ -280,9 +407,25 @@ sendLoop:
 				}
 			}
 			result.Deleted++
+			result.DeletedBytes += f.Size
+			if opts.DryRun {
+				result.Actions = append(result.Actions, a)
+			}
 		}
 	}
 
+	if opts.Manifest != nil && !opts.DryRun {
+		source := opts.Manifest.Source
+		if source == "" {
+			source = srcPath
+		}
+		m := &Manifest{Source: source, Destination: dstPath, Created: startTime.UTC(), Files: manifest}
+		if err := opts.Manifest.write(ctx, dst, dstPath, m); err != nil {
+			result.Errors = append(result.Errors, FileError{Path: opts.Manifest.path(), Op: "manifest", Err: err})
+		}
+	}
+
+	opts.Tracker.setPhase(PhaseComplete)
 	if opts.Progress != nil {
 		opts.Progress(Progress{
 			Phase:            PhaseComplete,
@@ -299,12 +442,22 @@ sendLoop:
 		slog.Int("copied", result.Copied),
 		slog.Int("updated", result.Updated),
 		slog.Int("deleted", result.Deleted),
+		slog.Int("dirs_created", result.DirsCreated),
 		slog.Int("skipped", result.Skipped),
 		slog.Int("errors", len(result.Errors)),
 		slog.Int64("bytes_transferred", result.BytesTransferred),
 		slog.Duration("duration", result.Duration),
 	)
 
+	if err := budget.err(); err != nil {
+		logger.Warn("sync stopped early", slog.Any("error", err))
+		return result, err
+	}
+	if opts.Journal != nil && !opts.DryRun && result.Success() {
+		if err := opts.Journal.Remove(ctx); err != nil {
+			logger.Warn("failed to remove journal", slog.Any("error", err))
+		}
+	}
 	return result, nil
 }
 
@@ -326,7 +479,7 @@ func Move(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 
 	// First, do a sync without deleting from destination
 	opts.DeleteExtra = false
-	result, err := Sync(ctx, src, dst, srcPath, dstPath, opts)
+	result, plan, err := runSync(ctx, src, dst, srcPath, dstPath, opts)
 	if err != nil {
 		return result, err
 	}
@@ -337,17 +490,25 @@ func Move(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 		return result, nil
 	}
 
-	// Delete successfully copied files from source
-	srcFiles, err := listFiles(ctx, src, srcPath, opts)
-	if err != nil {
-		return result, err
+	// Delete successfully copied files from source, found at their
+	// destination paths, which PathTransform and name matching may
+	// change. Files that failed are kept.
+	failed := make(map[string]bool)
+	for _, fe := range result.Errors {
+		failed[fe.Path] = true
 	}
-
-	for _, f := range srcFiles {
-		if f.IsDir {
-			continue
+	moved := slices.Clone(plan.inSync)
+	for _, a := range plan.Actions {
+		if (a.Type == ActionCopy || a.Type == ActionUpdate) && !failed[a.File.Path] {
+			moved = append(moved, a)
 		}
+	}
+	slices.SortFunc(moved, func(a, b Action) int {
+		return strings.Compare(a.File.Path, b.File.Path)
+	})
 
+	for _, a := range moved {
+		f := a.File
 		select {
 		case <-ctx.Done():
 			result.Duration = time.Since(startTime)
@@ -358,7 +519,7 @@ func Move(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 		srcFullPath := path.Join(srcPath, f.Path)
 
 		// Verify the file was copied successfully before deleting
-		dstFullPath := path.Join(dstPath, f.Path)
+		dstFullPath := path.Join(dstPath, a.destPath())
 		dstExists, err := dst.Exists(ctx, dstFullPath)
 		if err != nil {
 			result.Errors = append(result.Errors, FileError{
@@ -374,13 +535,30 @@ func Move(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPath st
 			continue
 		}
 
+		switch opts.Hooks.beforeDelete(ctx, f) {
+		case Skip:
+			continue
+		case Abort:
+			result.Duration = time.Since(startTime)
+			return result, abortError("delete-source", f.Path)
+		}
+
 		// Delete from source
-		if err := src.Delete(ctx, srcFullPath); err != nil {
-			result.Errors = append(result.Errors, FileError{
+		if err := deleteUnlocked(ctx, src, src, srcFullPath); err != nil {
+			fe := FileError{
 				Path: f.Path,
 				Op:   "delete-source",
 				Err:  err,
-			})
+			}
+			decision := opts.Hooks.onError(ctx, f, fe)
+			if decision == Skip {
+				continue
+			}
+			result.Errors = append(result.Errors, fe)
+			if decision == Abort {
+				result.Duration = time.Since(startTime)
+				return result, abortError(fe.Op, fe.Path)
+			}
 			if opts.MaxErrors > 0 && len(result.Errors) >= opts.MaxErrors {
 				break
 			}
@@ -406,11 +584,19 @@ func MoveFile(ctx context.Context, src, dst omnistorage.Backend, srcPath, dstPat
 		return err
 	}
 
-	return src.Delete(ctx, srcPath)
+	return deleteUnlocked(ctx, src, src, srcPath)
 }
 
 // listFiles lists all files under the given path and returns FileInfo for each.
 func listFiles(ctx context.Context, backend omnistorage.Backend, basePath string, opts Options) ([]FileInfo, error) {
+	return scanFiles(ctx, backend, basePath, opts, true, nil)
+}
+
+// scanFiles lists the files under basePath that pass opts.Filter. The
+// filter's metadata, tag and hash rules apply only with attrs, for a
+// source; excluded, if not nil, collects the files that pass the other
+// rules but not those.
+func scanFiles(ctx context.Context, backend omnistorage.Backend, basePath string, opts Options, attrs bool, excluded map[string]bool) ([]FileInfo, error) {
 	paths, err := backend.List(ctx, basePath)
 	if err != nil {
 		return nil, err
@@ -421,20 +607,23 @@ func listFiles(ctx context.Context, backend omnistorage.Backend, basePath string
 	// Try to get extended backend for better file info
 	extBackend, hasExt := omnistorage.AsExtended(backend)
 
-	for _, p := range paths {
-		// Make path relative to basePath
-		relPath := p
-		if basePath != "" && len(p) > len(basePath) {
-			relPath = p[len(basePath):]
-			if len(relPath) > 0 && relPath[0] == '/' {
-				relPath = relPath[1:]
-			}
-		}
+	attrs = attrs && (opts.Filter.NeedsMetadata() || opts.Filter.NeedsTags() || len(opts.Filter.NeedsHashes()) > 0)
+	if _, ok := backend.(omnistorage.Tagger); attrs && opts.Filter.NeedsTags() && !ok {
+		return nil, fmt.Errorf("filter has tag rules: %w", omnistorage.ErrNotSupported)
+	}
 
-		fi := FileInfo{Path: relPath}
+	for _, rel := range paths {
+		p := omnistorage.ListedPath(basePath, rel)
+		fi := FileInfo{Path: rel}
+		var info omnistorage.ObjectInfo
+		var contentType string
 
 		if hasExt {
-			info, err := extBackend.Stat(ctx, p)
+			err := opts.withFileTimeout(ctx, "stat", p, func(ctx context.Context) error {
+				var err error
+				info, err = extBackend.Stat(ctx, p)
+				return err
+			})
 			if err == nil {
 				fi.Size = info.Size()
 				fi.ModTime = info.ModTime()
@@ -442,20 +631,45 @@ func listFiles(ctx context.Context, backend omnistorage.Backend, basePath string
 				if opts.Checksum {
 					fi.Hash = info.Hash(omnistorage.HashMD5)
 				}
+				contentType = info.ContentType()
+			} else {
+				info = nil
 			}
 		}
 
+		if opts.SkipEmptyFiles && !fi.IsDir && fi.Size == 0 && hasExt {
+			continue
+		}
+
 		// Apply filter if present
 		if opts.Filter != nil && !fi.IsDir {
 			filterInfo := filter.FileInfo{
-				Path:    fi.Path,
-				Size:    fi.Size,
-				ModTime: fi.ModTime,
-				IsDir:   fi.IsDir,
+				Path:        fi.Path,
+				Size:        fi.Size,
+				ModTime:     fi.ModTime,
+				IsDir:       fi.IsDir,
+				ContentType: contentType,
 			}
 			if !opts.Filter.Match(filterInfo) {
 				continue
 			}
+			// Tags and hashes take a request or a read per file, so
+			// they are only fetched for files that pass the other rules.
+			if attrs {
+				err := fileAttributes(ctx, backend, p, info, opts, &filterInfo)
+				if errors.Is(err, omnistorage.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				if !opts.Filter.Match(filterInfo) {
+					if excluded != nil {
+						excluded[fi.Path] = true
+					}
+					continue
+				}
+			}
 		}
 
 		files = append(files, fi)
@@ -464,78 +678,222 @@ func listFiles(ctx context.Context, backend omnistorage.Backend, basePath string
 	return files, nil
 }
 
+// fileAttributes sets the metadata, tags and hashes opts.Filter needs in
+// fi, for the file at p, whose info is from Stat, or nil. Hashes the
+// backend does not report are computed by reading the file.
+func fileAttributes(ctx context.Context, backend omnistorage.Backend, p string, info omnistorage.ObjectInfo, opts Options, fi *filter.FileInfo) error {
+	if opts.Filter.NeedsMetadata() {
+		if info != nil {
+			fi.Metadata = info.Metadata()
+		}
+		if fi.Metadata == nil {
+			fi.Metadata = map[string]string{}
+		}
+	}
+	if opts.Filter.NeedsTags() {
+		err := opts.withFileTimeout(ctx, "tags", p, func(ctx context.Context) error {
+			var err error
+			fi.Tags, err = backend.(omnistorage.Tagger).Tags(ctx, p)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if fi.Tags == nil {
+			fi.Tags = map[string]string{}
+		}
+	}
+	if types := opts.Filter.NeedsHashes(); len(types) > 0 {
+		fi.Hashes = make(omnistorage.HashSet, len(types))
+		for _, t := range types {
+			var h string
+			if info != nil {
+				h = info.Hash(t)
+			}
+			if h == "" {
+				err := opts.withFileTimeout(ctx, "hash", p, func(ctx context.Context) error {
+					var err error
+					h, err = contentHash(ctx, backend, p, t)
+					return err
+				})
+				if err != nil {
+					return err
+				}
+			}
+			fi.Hashes.Set(t, h)
+		}
+	}
+	return nil
+}
+
+// listDestFiles lists the destination files under dstPath of a source
+// whose scan collected excluded. Metadata, tag and hash rules judge the
+// source, whose copies may not keep its tags, so a destination file whose
+// source they excluded is left out, as one excluded by any other rule is.
+func listDestFiles(ctx context.Context, dst omnistorage.Backend, dstPath string, opts Options, excluded map[string]bool) ([]FileInfo, error) {
+	files, err := scanFiles(ctx, dst, dstPath, opts, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(files, func(f FileInfo) bool { return excluded[f.Path] }), nil
+}
+
 // copyFileWithContext copies a single file with rate limiting, retry, and metadata support.
 func copyFileWithContext(ctx context.Context, sctx *syncContext, src, dst omnistorage.Backend, srcPath, dstPath string) error {
-	// Wrap with retry if configured
-	if sctx.opts.Retry != nil && sctx.opts.Retry.MaxRetries > 0 {
-		return retryOperation(ctx, *sctx.opts.Retry, func() error {
-			return copyFileSingle(ctx, sctx, src, dst, srcPath, dstPath)
-		})
+	return sctx.opts.withFileTimeout(ctx, "copy", srcPath, func(ctx context.Context) error {
+		// Wrap with retry if configured
+		if sctx.opts.Retry != nil && sctx.opts.Retry.MaxRetries > 0 {
+			return retryWithContext(ctx, *sctx.opts.Retry, func(ctx context.Context) error {
+				return copyFileSingle(ctx, sctx, src, dst, srcPath, dstPath)
+			})
+		}
+		return copyFileSingle(ctx, sctx, src, dst, srcPath, dstPath)
+	})
+}
+
+// withFileTimeout runs op on the file at path with ctx bounded by
+// FileTimeout. An error caused by the timeout is returned as an
+// *omnistorage.Error of kind KindTimeout.
+func (o Options) withFileTimeout(ctx context.Context, op, path string, fn func(context.Context) error) error {
+	if o.FileTimeout <= 0 {
+		return fn(ctx)
 	}
-	return copyFileSingle(ctx, sctx, src, dst, srcPath, dstPath)
+	fileCtx, cancel := context.WithTimeout(ctx, o.FileTimeout)
+	defer cancel()
+	err := fn(fileCtx)
+	if err != nil && ctx.Err() == nil && fileCtx.Err() != nil {
+		return &omnistorage.Error{Op: op, Path: path, Kind: omnistorage.KindTimeout, Err: err}
+	}
+	return err
 }
 
 // copyFileSingle performs a single copy attempt with rate limiting and metadata.
 func copyFileSingle(ctx context.Context, sctx *syncContext, src, dst omnistorage.Backend, srcPath, dstPath string) error {
 	// First try server-side copy if both backends are the same and support it
 	// Note: Server-side copy skips rate limiting as no data flows through client
-	if src == dst {
+	if src == dst && sctx.opts.TransformReader == nil {
 		if ext, ok := omnistorage.AsExtended(src); ok && ext.Features().Copy {
 			return ext.Copy(ctx, srcPath, dstPath)
 		}
 	}
 
 	// Fall back to read/write copy
-	reader, err := src.NewReader(ctx, srcPath)
-	if err != nil {
-		return err
+	info := statSource(ctx, src, srcPath)
+	src = sctx.opts.transferSource(src, srcPath)
+	src = sctx.opts.downloadSource(src, info)
+
+	if info != nil {
+		if patcher, dstInfo, ok := deltaTarget(ctx, sctx, dst, dstPath, info.Size()); ok {
+			saved, err := deltaCopy(ctx, sctx, src, dst, patcher, dstInfo, srcPath, dstPath)
+			sctx.deltaSaved.Add(saved)
+			return err
+		}
+	}
+	writerOpts := buildWriterOptions(info, sctx.opts.PreserveMetadata)
+	if patcher, ok := stripeTarget(sctx, src, dst, info); ok {
+		return stripedCopy(ctx, sctx, src, dst, patcher, srcPath, dstPath, info.Size(), writerOpts)
 	}
-	defer func() { _ = reader.Close() }()
 
-	// Apply rate limiting if configured
-	var finalReader io.Reader = reader
-	if sctx.rateLimiter != nil {
-		finalReader = newRateLimitedReader(reader, sctx.rateLimiter)
+	// Hashes the source reports are passed to the destination, which
+	// verifies the data it receives, and checked against the hashes of
+	// the data read. A transform changes the content, so they do not apply.
+	var sums omnistorage.HashSet
+	if sctx.opts.TransformReader == nil {
+		sums = sourceChecksums(info)
+	}
+	writerOpts = append(writerOpts,
+		omnistorage.WithContentMD5(sums.Get(omnistorage.HashMD5)),
+		omnistorage.WithContentSHA256(sums.Get(omnistorage.HashSHA256)))
+	hashTypes := make([]omnistorage.HashType, 0, len(sums))
+	for t := range sums {
+		hashTypes = append(hashTypes, t)
 	}
 
-	// Build writer options based on metadata settings
-	writerOpts := buildWriterOptions(ctx, src, srcPath, sctx.opts.PreserveMetadata)
+	pipeOpts := []omnistorage.PipeOption{
+		omnistorage.PipeWriterOptions(writerOpts...),
+		omnistorage.PipeHashes(hashTypes...),
+	}
+	if sctx.rateLimiter != nil {
+		pipeOpts = append(pipeOpts, omnistorage.PipeLimiter(sctx.rateLimiter))
+	}
+	if progress := sctx.opts.Tracker.progress(srcPath); progress != nil {
+		pipeOpts = append(pipeOpts, omnistorage.PipeProgress(progress))
+	}
+	if transform := sctx.opts.TransformReader; transform != nil {
+		pipeOpts = append(pipeOpts, omnistorage.PipeTransform(func(r io.Reader) io.Reader {
+			return transform(srcPath, r)
+		}))
+	}
 
-	writer, err := dst.NewWriter(ctx, dstPath, writerOpts...)
+	result, err := omnistorage.Pipe(ctx, src, srcPath, dst, dstPath, pipeOpts...)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(writer, finalReader)
+	// Destinations that cannot verify checksums have stored the data, so
+	// a mismatch removes it rather than leave a corrupt copy behind.
+	for _, t := range hashTypes {
+		if got, want := result.Hash(t), sums.Get(t); !strings.EqualFold(got, want) {
+			_ = dst.Delete(context.WithoutCancel(ctx), dstPath)
+			return fmt.Errorf("%w: %s of %s is %s, source reported %s",
+				omnistorage.ErrChecksumMismatch, t, srcPath, got, want)
+		}
+	}
+	return nil
+}
+
+// statSource returns the source file's info, or nil if the backend cannot
+// stat files or the stat fails.
+func statSource(ctx context.Context, src omnistorage.Backend, srcPath string) omnistorage.ObjectInfo {
+	ext, ok := omnistorage.AsExtended(src)
+	if !ok {
+		return nil
+	}
+	info, err := ext.Stat(ctx, srcPath)
 	if err != nil {
-		_ = writer.Close()
-		return err
+		return nil
 	}
+	return info
+}
 
-	return writer.Close()
+// sourceChecksums returns the MD5 and SHA-256 hashes info reports,
+// skipping values that are not hex hashes of the right length.
+func sourceChecksums(info omnistorage.ObjectInfo) omnistorage.HashSet {
+	if info == nil {
+		return nil
+	}
+	sums := omnistorage.HashSet{}
+	for t, n := range map[omnistorage.HashType]int{omnistorage.HashMD5: 32, omnistorage.HashSHA256: 64} {
+		if sum := info.Hash(t); len(sum) == n && isHex(sum) {
+			sums[t] = sum
+		}
+	}
+	return sums
+}
+
+func isHex(s string) bool {
+	return strings.Trim(s, "0123456789abcdefABCDEF") == ""
 }
 
 // buildWriterOptions builds WriterOptions based on source file metadata.
-func buildWriterOptions(ctx context.Context, src omnistorage.Backend, srcPath string, metaOpts *MetadataOptions) []omnistorage.WriterOption {
+func buildWriterOptions(info omnistorage.ObjectInfo, metaOpts *MetadataOptions) []omnistorage.WriterOption {
 	var opts []omnistorage.WriterOption
 
-	ext, hasExt := omnistorage.AsExtended(src)
-	if !hasExt {
-		return opts
-	}
-
-	info, err := ext.Stat(ctx, srcPath)
-	if err != nil {
+	if info == nil {
 		return opts
 	}
 
-	// Default: always preserve content-type
+	// Default: always preserve content-type and HTTP headers
 	preserveContentType := true
 	preserveCustomMetadata := false
+	preserveHTTPHeaders := true
+	preserveStorageClass := false
 
 	if metaOpts != nil {
 		preserveContentType = metaOpts.ContentType
 		preserveCustomMetadata = metaOpts.CustomMetadata
+		preserveHTTPHeaders = metaOpts.HTTPHeaders
+		preserveStorageClass = metaOpts.StorageClass
 	}
 
 	// Preserve content type
@@ -552,6 +910,20 @@ func buildWriterOptions(ctx context.Context, src omnistorage.Backend, srcPath st
 		}
 	}
 
+	if ext, ok := info.(omnistorage.ExtendedObjectInfo); ok {
+		if preserveHTTPHeaders {
+			opts = append(opts,
+				omnistorage.WithCacheControl(ext.CacheControl()),
+				omnistorage.WithContentEncoding(ext.ContentEncoding()),
+				omnistorage.WithContentDisposition(ext.ContentDisposition()))
+		}
+		if preserveStorageClass {
+			if class := ext.StorageClass(); class != "" {
+				opts = append(opts, omnistorage.WithStorageClass(class))
+			}
+		}
+	}
+
 	return opts
 }
 
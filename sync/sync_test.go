@@ -2,12 +2,17 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"io"
+	gosync "sync"
 	"testing"
 	"time"
 
+	"github.com/grokify/omnistorage"
 	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/metrics"
 	"github.com/grokify/omnistorage/sync/filter"
+	"github.com/grokify/omnistorage/trash"
 )
 
 func TestSyncBasic(t *testing.T) {
@@ -76,6 +81,43 @@ func TestSyncWithDelete(t *testing.T) {
 	}
 }
 
+func TestSyncWithTrash(t *testing.T) {
+	ctx := context.Background()
+
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "file1.txt", "content1")
+	writeFile(t, ctx, dst, "data/extra.txt", "extra content")
+
+	opts := Options{DeleteExtra: true, TrashPrefix: "data/.trash"}
+	result, err := Sync(ctx, src, dst, "", "data", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Deleted != 1 {
+		t.Errorf("Deleted = %d, want 1", result.Deleted)
+	}
+	if exists, _ := dst.Exists(ctx, "data/extra.txt"); exists {
+		t.Error("Extra file should have been moved to the trash")
+	}
+
+	// The trash is not synced or deleted on the next run
+	result, err = Sync(ctx, src, dst, "", "data", opts)
+	if err != nil {
+		t.Fatalf("second Sync failed: %v", err)
+	}
+	if result.Deleted != 0 {
+		t.Errorf("second Deleted = %d, want 0", result.Deleted)
+	}
+
+	if err := trash.New(dst, trash.WithPrefix("data/.trash")).Restore(ctx, "data/extra.txt"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if exists, _ := dst.Exists(ctx, "data/extra.txt"); !exists {
+		t.Error("Extra file was not restored")
+	}
+}
+
 func TestSyncWithoutDelete(t *testing.T) {
 	ctx := context.Background()
 
@@ -183,6 +225,51 @@ func TestSyncDryRun(t *testing.T) {
 	}
 }
 
+func TestSyncDryRunPreview(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "new.txt", "new")
+	writeFile(t, ctx, src, "changed.txt", "changed!")
+	writeFile(t, ctx, dst, "changed.txt", "old")
+	writeFile(t, ctx, dst, "extra.txt", "extra")
+
+	preview, err := Sync(ctx, src, dst, "", "", Options{DryRun: true, DeleteExtra: true, SizeOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Action{
+		{Type: ActionUpdate, File: FileInfo{Path: "changed.txt", Size: 8}},
+		{Type: ActionCopy, File: FileInfo{Path: "new.txt", Size: 3}},
+		{Type: ActionDelete, File: FileInfo{Path: "extra.txt", Size: 5}},
+	}
+	if len(preview.Actions) != len(want) {
+		t.Fatalf("Actions = %+v, want %d", preview.Actions, len(want))
+	}
+	for i, a := range preview.Actions {
+		if a.Type != want[i].Type || a.File.Path != want[i].File.Path || a.File.Size != want[i].File.Size {
+			t.Errorf("Actions[%d] = %s %s (%d bytes), want %s %s (%d bytes)",
+				i, a.Type, a.File.Path, a.File.Size, want[i].Type, want[i].File.Path, want[i].File.Size)
+		}
+	}
+	verifyFile(t, ctx, dst, "changed.txt", "old")
+
+	// The preview matches the real run
+	result, err := Sync(ctx, src, dst, "", "", Options{DeleteExtra: true, SizeOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Actions != nil {
+		t.Errorf("Actions = %v for a real run", result.Actions)
+	}
+	for _, r := range []*Result{preview, result} {
+		if r.Copied != 1 || r.Updated != 1 || r.Deleted != 1 || r.BytesTransferred != 11 || r.DeletedBytes != 5 {
+			t.Errorf("DryRun=%v: copied %d, updated %d, deleted %d, %d bytes, %d deleted bytes; want 1, 1, 1, 11, 5",
+				r.DryRun, r.Copied, r.Updated, r.Deleted, r.BytesTransferred, r.DeletedBytes)
+		}
+	}
+}
+
 func TestSyncProgress(t *testing.T) {
 	ctx := context.Background()
 
@@ -218,6 +305,7 @@ func TestSyncWithPrefix(t *testing.T) {
 	writeFile(t, ctx, src, "data/file1.txt", "content1")
 	writeFile(t, ctx, src, "data/file2.txt", "content2")
 	writeFile(t, ctx, src, "other/file3.txt", "content3")
+	writeFile(t, ctx, src, "database.txt", "content4")
 
 	// Sync only "data/" to "backup/"
 	result, err := Sync(ctx, src, dst, "data", "backup", DefaultOptions())
@@ -238,6 +326,11 @@ func TestSyncWithPrefix(t *testing.T) {
 	if exists {
 		t.Error("file3.txt should not have been copied")
 	}
+
+	// database.txt begins with "data" but is not inside it
+	if paths, _ := dst.List(ctx, ""); len(paths) != 2 {
+		t.Errorf("dst paths = %v, want only backup/file1.txt and backup/file2.txt", paths)
+	}
 }
 
 func TestCopyDir(t *testing.T) {
@@ -334,6 +427,55 @@ func TestCheckDifferences(t *testing.T) {
 	}
 }
 
+// listCounter counts List calls on a memory backend.
+type listCounter struct {
+	*memory.Backend
+	lists int
+}
+
+func (b *listCounter) List(ctx context.Context, prefix string) ([]string, error) {
+	b.lists++
+	return b.Backend.List(ctx, prefix)
+}
+
+func TestCheckAndRepair(t *testing.T) {
+	ctx := context.Background()
+	src := &listCounter{Backend: memory.New()}
+	dst := &listCounter{Backend: memory.New()}
+	writeFile(t, ctx, src.Backend, "same.txt", "same")
+	writeFile(t, ctx, dst.Backend, "same.txt", "same")
+	writeFile(t, ctx, src.Backend, "diff.txt", "short")
+	writeFile(t, ctx, dst.Backend, "diff.txt", "much longer content here")
+	writeFile(t, ctx, src.Backend, "src-only.txt", "src only")
+	writeFile(t, ctx, dst.Backend, "dst-only.txt", "dst only")
+
+	checked, result, err := CheckAndRepair(ctx, src, dst, "", "", Options{DeleteExtra: true})
+	if err != nil {
+		t.Fatalf("CheckAndRepair: %v", err)
+	}
+	if len(checked.Match) != 1 || len(checked.Differ) != 1 || len(checked.SrcOnly) != 1 || len(checked.DstOnly) != 1 {
+		t.Errorf("CheckResult = %+v", checked)
+	}
+	if result.Copied != 1 || result.Updated != 1 || result.Deleted != 1 || result.Skipped != 1 {
+		t.Errorf("Result = %+v, want 1 copied, updated, deleted and skipped", result)
+	}
+	if src.lists != 1 || dst.lists != 1 {
+		t.Errorf("List calls = %d, %d, want 1 each", src.lists, dst.lists)
+	}
+
+	after, err := Check(ctx, src, dst, "", "", Options{})
+	if err != nil || !after.InSync() {
+		t.Errorf("Check after repair = %+v, %v, want in sync", after, err)
+	}
+
+	// Without DeleteExtra, files only in the destination are kept
+	writeFile(t, ctx, dst.Backend, "extra.txt", "extra")
+	checked, result, err = CheckAndRepair(ctx, src, dst, "", "", Options{})
+	if err != nil || len(checked.DstOnly) != 1 || result.Deleted != 0 {
+		t.Errorf("CheckAndRepair = %+v, %+v, %v", checked, result, err)
+	}
+}
+
 func TestCheckWithChecksum(t *testing.T) {
 	ctx := context.Background()
 
@@ -714,6 +856,153 @@ func TestSyncWithFilterExclude(t *testing.T) {
 	}
 }
 
+func TestSyncWithContentTypeFilter(t *testing.T) {
+	ctx := context.Background()
+
+	src := memory.New()
+	dst := memory.New()
+
+	// photo has its type from the backend, logo.png from its extension.
+	w, err := src.NewWriter(ctx, "photo", omnistorage.WithContentType("image/jpeg"))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	_, _ = w.Write([]byte("jpeg"))
+	_ = w.Close()
+	writeFile(t, ctx, src, "logo.png", "png")
+	writeFile(t, ctx, src, "data.json", "json")
+
+	f := filter.New(filter.IncludeContentType("image/*"))
+	result, err := Sync(ctx, src, dst, "", "", Options{Filter: f})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.Copied != 2 {
+		t.Errorf("Copied = %d, want 2", result.Copied)
+	}
+	if ok, _ := dst.Exists(ctx, "data.json"); ok {
+		t.Error("data.json should not have been copied")
+	}
+}
+
+func TestSyncWithHashFilter(t *testing.T) {
+	ctx := context.Background()
+
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "a.bin", "processed")
+	writeFile(t, ctx, src, "b.bin", "new")
+	writeFile(t, ctx, dst, "a.bin", "output of a")
+
+	processed := omnistorage.HashBytes([]byte("processed"), omnistorage.HashSHA256)
+	f := filter.New(filter.ExcludeHashes(omnistorage.HashSHA256, processed))
+	result, err := Sync(ctx, src, dst, "", "", Options{Filter: f, DeleteExtra: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.Copied != 1 || result.Deleted != 0 {
+		t.Errorf("Copied, Deleted = %d, %d, want 1, 0", result.Copied, result.Deleted)
+	}
+	if got := readBackend(t, dst, "a.bin"); got != "output of a" {
+		t.Errorf("a.bin = %q, want it left alone", got)
+	}
+	if got := readBackend(t, dst, "b.bin"); got != "new" {
+		t.Errorf("b.bin = %q, want %q", got, "new")
+	}
+}
+
+// taggedBackend is a memory backend with object tags and metadata, set
+// directly in its maps. It counts the calls to Tags.
+type taggedBackend struct {
+	*memory.Backend
+	tags     map[string]map[string]string
+	metadata map[string]map[string]string
+	tagCalls int
+}
+
+func (b *taggedBackend) Stat(ctx context.Context, p string) (omnistorage.ObjectInfo, error) {
+	info, err := b.Backend.Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &omnistorage.BasicObjectInfo{
+		ObjectPath:     info.Path(),
+		ObjectSize:     info.Size(),
+		ObjectModTime:  info.ModTime(),
+		ObjectMetadata: b.metadata[p],
+	}, nil
+}
+
+func (b *taggedBackend) Tags(ctx context.Context, p string) (map[string]string, error) {
+	b.tagCalls++
+	return b.tags[p], nil
+}
+
+func (b *taggedBackend) SetTags(ctx context.Context, p string, tags map[string]string) error {
+	b.tags[p] = tags
+	return nil
+}
+
+func TestSyncWithAttributeFilter(t *testing.T) {
+	ctx := context.Background()
+
+	src := &taggedBackend{
+		Backend: memory.New(),
+		tags: map[string]map[string]string{
+			"gold.json": {"class": "gold"},
+			"temp.json": {"class": "gold", "temp": "true"},
+			"test.json": {"class": "gold"},
+			"gold.txt":  {"class": "gold"},
+		},
+		metadata: map[string]map[string]string{
+			"test.json": {"owner": "test"},
+		},
+	}
+	dst := memory.New()
+	for _, p := range []string{"gold.json", "temp.json", "test.json", "plain.json", "gold.txt"} {
+		writeFile(t, ctx, src.Backend, p, p)
+	}
+	writeFile(t, ctx, dst, "temp.json", "old copy")
+	writeFile(t, ctx, dst, "extra.json", "extra")
+
+	f := filter.New(
+		filter.Include("*.json"),
+		filter.IncludeTag("class", "gold"),
+		filter.ExcludeTag("temp", "true"),
+		filter.ExcludeMetadata("owner", "test"),
+	)
+	result, err := Sync(ctx, src, dst, "", "", Options{Filter: f, DeleteExtra: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if result.Copied != 1 || result.Deleted != 1 {
+		t.Errorf("Copied, Deleted = %d, %d, want 1, 1", result.Copied, result.Deleted)
+	}
+	if ok, _ := dst.Exists(ctx, "gold.json"); !ok {
+		t.Error("gold.json should have been copied")
+	}
+	// The copy of a file excluded by its tags is left alone.
+	if got := readBackend(t, dst, "temp.json"); got != "old copy" {
+		t.Errorf("temp.json = %q, want it left alone", got)
+	}
+	if ok, _ := dst.Exists(ctx, "extra.json"); ok {
+		t.Error("extra.json should have been deleted")
+	}
+	// Tags are fetched only for the .json files.
+	if src.tagCalls != 4 {
+		t.Errorf("Tags called %d times, want 4", src.tagCalls)
+	}
+
+	// Backends without tags cannot apply tag rules.
+	_, err = Sync(ctx, memory.New(), dst, "", "", Options{Filter: f})
+	if !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Sync from backend without tags error = %v, want ErrNotSupported", err)
+	}
+}
+
 func TestSyncParallel(t *testing.T) {
 	ctx := context.Background()
 
@@ -849,6 +1138,43 @@ func TestDefaultMetadataOptions(t *testing.T) {
 	if opts.CustomMetadata {
 		t.Error("CustomMetadata should be false by default")
 	}
+	if !opts.HTTPHeaders {
+		t.Error("HTTPHeaders should be true by default")
+	}
+	if opts.StorageClass {
+		t.Error("StorageClass should be false by default")
+	}
+}
+
+func TestSyncPreservesHTTPHeaders(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+
+	w, _ := src.NewWriter(ctx, "page.html.gz",
+		omnistorage.WithContentType("text/html"),
+		omnistorage.WithCacheControl("no-cache"),
+		omnistorage.WithContentEncoding("gzip"),
+		omnistorage.WithContentDisposition("inline"))
+	_, _ = w.Write([]byte("compressed"))
+	_ = w.Close()
+
+	if _, err := Sync(ctx, src, dst, "", "", Options{}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	info, err := dst.Stat(ctx, "page.html.gz")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	ext, ok := info.(omnistorage.ExtendedObjectInfo)
+	if !ok {
+		t.Fatalf("Stat returned %T, want ExtendedObjectInfo", info)
+	}
+	if ext.ContentType() != "text/html" || ext.CacheControl() != "no-cache" ||
+		ext.ContentEncoding() != "gzip" || ext.ContentDisposition() != "inline" {
+		t.Errorf("dst headers = %q, %q, %q, %q; want text/html, no-cache, gzip, inline",
+			ext.ContentType(), ext.CacheControl(), ext.ContentEncoding(), ext.ContentDisposition())
+	}
 }
 
 // Helper functions
@@ -884,3 +1210,159 @@ func verifyFile(t *testing.T, ctx context.Context, backend *memory.Backend, path
 		t.Errorf("Content of %s = %q, want %q", path, data, expectedContent)
 	}
 }
+
+// transferRecorder is a metrics.Collector that keeps sync transfers.
+type transferRecorder struct {
+	mu        gosync.Mutex
+	transfers map[string]metrics.Transfer
+}
+
+func (r *transferRecorder) ObserveOperation(metrics.Operation) {}
+
+func (r *transferRecorder) ObserveTransfer(t metrics.Transfer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.transfers == nil {
+		r.transfers = make(map[string]metrics.Transfer)
+	}
+	r.transfers[t.Path] = t
+}
+
+func TestSyncMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "new.txt", "new")
+	writeFile(t, ctx, src, "changed.txt", "changed content")
+	writeFile(t, ctx, dst, "changed.txt", "old")
+	writeFile(t, ctx, dst, "extra.txt", "extra")
+
+	rec := &transferRecorder{}
+	if _, err := Sync(ctx, src, dst, "", "", Options{DeleteExtra: true, Metrics: rec}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	want := map[string]metrics.Transfer{
+		"new.txt":     {Op: metrics.TransferCopy, Bytes: 3},
+		"changed.txt": {Op: metrics.TransferUpdate, Bytes: 15},
+		"extra.txt":   {Op: metrics.TransferDelete},
+	}
+	if len(rec.transfers) != len(want) {
+		t.Fatalf("recorded %d transfers, want %d: %+v", len(rec.transfers), len(want), rec.transfers)
+	}
+	for p, w := range want {
+		got := rec.transfers[p]
+		if got.Op != w.Op || got.Bytes != w.Bytes || got.Err != nil {
+			t.Errorf("transfer %s = %+v, want op %s with %d bytes", p, got, w.Op, w.Bytes)
+		}
+	}
+
+	// Dry runs report nothing.
+	rec = &transferRecorder{}
+	writeFile(t, ctx, src, "another.txt", "x")
+	if _, err := Sync(ctx, src, dst, "", "", Options{DryRun: true, Metrics: rec}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(rec.transfers) != 0 {
+		t.Errorf("dry run recorded %d transfers, want 0", len(rec.transfers))
+	}
+}
+
+// misreportingSource reports a wrong MD5 for every file, as a source whose
+// data was corrupted after its hash was recorded would.
+type misreportingSource struct {
+	*memory.Backend
+}
+
+func (b misreportingSource) Stat(ctx context.Context, p string) (omnistorage.ObjectInfo, error) {
+	info, err := b.Backend.Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &omnistorage.BasicObjectInfo{
+		ObjectPath:    info.Path(),
+		ObjectSize:    info.Size(),
+		ObjectModTime: info.ModTime(),
+		ObjectHashes:  map[omnistorage.HashType]string{omnistorage.HashMD5: omnistorage.HashBytes([]byte("other"), omnistorage.HashMD5)},
+	}, nil
+}
+
+// uncheckedWriter ignores writer options, as a backend without checksum
+// support does.
+type uncheckedWriter struct {
+	*memory.Backend
+}
+
+func (b uncheckedWriter) NewWriter(ctx context.Context, p string, _ ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	return b.Backend.NewWriter(ctx, p)
+}
+
+func TestCopyChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	writeFile(t, ctx, src, "file.txt", "content")
+
+	for name, dst := range map[string]omnistorage.Backend{
+		"verifying destination": memory.New(),
+		"unchecked destination": uncheckedWriter{memory.New()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := CopyFile(ctx, misreportingSource{src}, dst, "file.txt", "file.txt")
+			if !errors.Is(err, omnistorage.ErrChecksumMismatch) {
+				t.Fatalf("CopyFile error = %v, want ErrChecksumMismatch", err)
+			}
+			if exists, _ := dst.Exists(ctx, "file.txt"); exists {
+				t.Error("mismatched copy left at destination")
+			}
+		})
+	}
+
+	// Matching hashes copy normally
+	dst := memory.New()
+	if err := CopyFile(ctx, src, dst, "file.txt", "file.txt"); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	verifyFile(t, ctx, dst, "file.txt", "content")
+}
+
+// stallingSource blocks reads of stuck.bin until the context is done, as
+// a hung connection would.
+type stallingSource struct {
+	*memory.Backend
+}
+
+func (b stallingSource) NewReader(ctx context.Context, p string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if p == "stuck.bin" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return b.Backend.NewReader(ctx, p, opts...)
+}
+
+func TestSyncFileTimeout(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "stuck.bin", "never read")
+	writeFile(t, ctx, src, "ok.txt", "content")
+
+	result, err := Sync(ctx, stallingSource{src}, dst, "", "", Options{
+		FileTimeout: 50 * time.Millisecond,
+		MaxErrors:   10,
+		Retry:       &RetryConfig{MaxRetries: 5, InitialDelay: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Sync error = %v", err)
+	}
+	if result.Copied != 1 || len(result.Errors) != 1 {
+		t.Fatalf("Copied = %d, Errors = %v; want 1 copied, 1 error", result.Copied, result.Errors)
+	}
+	fe := result.Errors[0]
+	if fe.Path != "stuck.bin" || !errors.Is(fe.Err, omnistorage.ErrTimeout) {
+		t.Errorf("error = %+v, want a timeout for stuck.bin", fe)
+	}
+	if got := readBackend(t, dst, "ok.txt"); got != "content" {
+		t.Errorf("ok.txt = %q, want %q", got, "content")
+	}
+}
@@ -0,0 +1,281 @@
+package sync
+
+import (
+	gosync "sync"
+	"time"
+)
+
+// Tracker exposes the live state of a sync run, per worker and in total,
+// for displays richer than Options.Progress, such as a TUI that polls
+// Stats on each tick. Set it in Options.Tracker; it follows one run at a
+// time and is reset when a run starts transferring files. Its methods are
+// safe for concurrent use.
+type Tracker struct {
+	mu           gosync.Mutex
+	phase        Phase
+	start        time.Time
+	workers      []workerState
+	files        map[string]int // source paths being copied, by worker
+	total        int
+	totalBytes   int64
+	queued       int
+	done         int
+	doneBytes    int64
+	errors       int
+	deleted      int
+	deleteErrors int
+}
+
+// workerState is the state of one worker of a Tracker.
+type workerState struct {
+	WorkerStats
+	srcPath string
+}
+
+// NewTracker returns a Tracker for Options.Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// LiveStats is a snapshot of a sync run, returned by Tracker.Stats.
+type LiveStats struct {
+	// Phase is the phase of the run, or "" before it starts.
+	Phase Phase
+
+	// Elapsed is the time since the transfers started.
+	Elapsed time.Duration
+
+	// Workers has the state of each transfer worker; there are
+	// Options.Concurrency of them.
+	Workers []WorkerStats
+
+	// Queued is the number of files waiting for a worker.
+	Queued int
+
+	// Files is the number of files to transfer, and FilesDone those
+	// transferred or failed.
+	Files     int
+	FilesDone int
+
+	// Bytes is the total size of the files to transfer, and BytesDone
+	// the bytes transferred, including those of files in flight.
+	Bytes     int64
+	BytesDone int64
+
+	// Speed is the average transfer rate since the transfers started, in
+	// bytes per second.
+	Speed float64
+
+	// ETA is the estimated time until the remaining bytes are
+	// transferred at Speed, or 0 if unknown.
+	ETA time.Duration
+
+	// Errors is the number of files that failed to transfer.
+	Errors int
+
+	// Deleted and DeleteErrors are the number of files deleted and that
+	// failed to delete.
+	Deleted      int
+	DeleteErrors int
+}
+
+// WorkerStats is the state of one transfer worker.
+type WorkerStats struct {
+	// ID identifies the worker, from 0.
+	ID int
+
+	// File is the path of the file being transferred, relative to the
+	// source path, or "" if the worker is idle.
+	File string
+
+	// Size is the size of File.
+	Size int64
+
+	// Bytes is the number of bytes of File transferred so far. Files
+	// copied server-side or in stripes report their bytes when done.
+	Bytes int64
+
+	// Started is when the worker started on File.
+	Started time.Time
+
+	// Speed is the transfer rate of File, in bytes per second.
+	Speed float64
+
+	// ETA is the estimated time until File is transferred, or 0 if
+	// unknown.
+	ETA time.Duration
+
+	// Files is the number of files the worker has transferred, and
+	// Errors the number that failed.
+	Files  int
+	Errors int
+}
+
+// Stats returns a snapshot of the run.
+func (t *Tracker) Stats() LiveStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	s := LiveStats{
+		Phase:        t.phase,
+		Workers:      make([]WorkerStats, len(t.workers)),
+		Queued:       t.queued,
+		Files:        t.total,
+		FilesDone:    t.done,
+		Bytes:        t.totalBytes,
+		BytesDone:    t.doneBytes,
+		Errors:       t.errors,
+		Deleted:      t.deleted,
+		DeleteErrors: t.deleteErrors,
+	}
+	if !t.start.IsZero() {
+		s.Elapsed = now.Sub(t.start)
+	}
+	for i, w := range t.workers {
+		ws := w.WorkerStats
+		if ws.File != "" {
+			s.BytesDone += ws.Bytes
+			ws.Speed, ws.ETA = rate(ws.Bytes, ws.Size, now.Sub(ws.Started))
+		}
+		s.Workers[i] = ws
+	}
+	s.Speed, s.ETA = rate(s.BytesDone, s.Bytes, s.Elapsed)
+	return s
+}
+
+// rate returns the rate of n bytes in d and the time until total is
+// reached at that rate.
+func rate(n, total int64, d time.Duration) (float64, time.Duration) {
+	if n <= 0 || d <= 0 {
+		return 0, 0
+	}
+	speed := float64(n) / d.Seconds()
+	var eta time.Duration
+	if total > n {
+		eta = time.Duration(float64(total-n) / speed * float64(time.Second))
+	}
+	return speed, eta
+}
+
+// begin resets the tracker for the transfers of a run.
+func (t *Tracker) begin(workers, files int, bytes int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.phase, t.start = PhaseTransferring, time.Now()
+	t.workers = make([]workerState, workers)
+	t.files = make(map[string]int)
+	t.total, t.totalBytes, t.queued = files, bytes, files
+	t.done, t.doneBytes, t.errors = 0, 0, 0
+	t.deleted, t.deleteErrors = 0, 0
+	for i := range t.workers {
+		t.workers[i].ID = i
+	}
+}
+
+// setPhase records the phase of the run.
+func (t *Tracker) setPhase(p Phase) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phase = p
+}
+
+// dequeue records that a worker took a file off the queue.
+func (t *Tracker) dequeue() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queued--
+}
+
+// skip records that a file taken off the queue will not be transferred,
+// so it no longer counts toward the totals.
+func (t *Tracker) skip(f FileInfo) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total--
+	t.totalBytes -= f.Size
+}
+
+// startFile records that worker started transferring f from srcPath.
+func (t *Tracker) startFile(worker int, f FileInfo, srcPath string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := &t.workers[worker]
+	w.File, w.Size, w.Bytes, w.Started = f.Path, f.Size, 0, time.Now()
+	w.srcPath = srcPath
+	t.files[srcPath] = worker
+}
+
+// progress returns a function recording the bytes of srcPath copied so
+// far, or nil if srcPath is not being tracked.
+func (t *Tracker) progress(srcPath string) func(int64) {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	worker, ok := t.files[srcPath]
+	if !ok {
+		return nil
+	}
+	return func(n int64) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if w := &t.workers[worker]; w.srcPath == srcPath {
+			w.Bytes = n
+		}
+	}
+}
+
+// endFile records that worker finished its file with err.
+func (t *Tracker) endFile(worker int, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := &t.workers[worker]
+	t.done++
+	if err != nil {
+		t.errors++
+		w.Errors++
+	} else {
+		t.doneBytes += w.Size
+		w.Files++
+	}
+	delete(t.files, w.srcPath)
+	w.File, w.Size, w.Bytes, w.Started, w.srcPath = "", 0, 0, time.Time{}, ""
+}
+
+// deleteDone records a delete that returned err.
+func (t *Tracker) deleteDone(err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.deleteErrors++
+	} else {
+		t.deleted++
+	}
+}
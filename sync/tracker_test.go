@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// gatedBackend is a memory backend whose writers do not finish until
+// release is closed.
+type gatedBackend struct {
+	*memory.Backend
+	release chan struct{}
+}
+
+func (b *gatedBackend) NewWriter(ctx context.Context, p string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	w, err := b.Backend.NewWriter(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return gatedWriter{w, b.release}, nil
+}
+
+type gatedWriter struct {
+	io.WriteCloser
+	release chan struct{}
+}
+
+func (w gatedWriter) Close() error {
+	<-w.release
+	return w.WriteCloser.Close()
+}
+
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	writeFile(t, ctx, src, "data/a.txt", "alpha")
+	writeFile(t, ctx, src, "data/b.txt", "bravo")
+	writeFile(t, ctx, src, "data/c.txt", "charlie")
+	dst := &gatedBackend{Backend: memory.New(), release: make(chan struct{})}
+	writeFile(t, ctx, dst.Backend, "extra.txt", "extra")
+
+	tracker := NewTracker()
+	if s := tracker.Stats(); s.Phase != "" || len(s.Workers) != 0 {
+		t.Errorf("Stats before the run = %+v", s)
+	}
+
+	done := make(chan *Result)
+	go func() {
+		result, err := Sync(ctx, src, dst, "data", "", Options{Concurrency: 2, DeleteExtra: true, Tracker: tracker})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- result
+	}()
+
+	// Both workers are blocked closing their files, with all bytes sent
+	deadline := time.Now().Add(5 * time.Second)
+	var s LiveStats
+	for {
+		s = tracker.Stats()
+		if len(s.Workers) == 2 && s.Workers[0].File != "" && s.Workers[1].File != "" && s.BytesDone == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("workers did not start: %+v", s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if s.Phase != PhaseTransferring || s.Files != 3 || s.Bytes != 17 || s.Queued != 1 || s.FilesDone != 0 {
+		t.Errorf("Stats during the run = %+v", s)
+	}
+	for _, w := range s.Workers {
+		if w.Size != 5 || w.Bytes != 5 || w.Started.IsZero() {
+			t.Errorf("worker = %+v", w)
+		}
+	}
+	close(dst.release)
+	result := <-done
+
+	s = tracker.Stats()
+	if s.Phase != PhaseComplete || s.FilesDone != 3 || s.BytesDone != 17 || s.Queued != 0 || s.Deleted != 1 || s.Errors != 0 {
+		t.Errorf("Stats after the run = %+v", s)
+	}
+	if files := s.Workers[0].Files + s.Workers[1].Files; files != result.Copied {
+		t.Errorf("worker files = %d, copied %d", files, result.Copied)
+	}
+	if s.Workers[0].File != "" || s.Speed <= 0 || s.ETA != 0 {
+		t.Errorf("Stats after the run = %+v", s)
+	}
+}
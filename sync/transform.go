@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// AddPrefix returns a path transform that prepends prefix to every
+// destination path. A date-based prefix, for example:
+//
+//	PathTransform: sync.AddPrefix(time.Now().Format("2006/01/02/"))
+func AddPrefix(prefix string) func(string) string {
+	return func(p string) string {
+		return prefix + p
+	}
+}
+
+// AddSuffix returns a path transform that appends suffix to every
+// destination path.
+func AddSuffix(suffix string) func(string) string {
+	return func(p string) string {
+		return p + suffix
+	}
+}
+
+// Rewrite returns a path transform that replaces matches of re with repl,
+// as regexp.Regexp.ReplaceAllString does. repl can refer to submatches
+// with $1 or ${name}.
+func Rewrite(re *regexp.Regexp, repl string) func(string) string {
+	return func(p string) string {
+		return re.ReplaceAllString(p, repl)
+	}
+}
+
+// Flatten returns a path transform that drops directories, writing every
+// file to the top of the destination. Files with the same name in
+// different directories collide; see Result.Collisions.
+func Flatten() func(string) string {
+	return path.Base
+}
+
+// ChainTransforms returns a path transform that applies fns in order.
+func ChainTransforms(fns ...func(string) string) func(string) string {
+	return func(p string) string {
+		for _, fn := range fns {
+			p = fn(p)
+		}
+		return p
+	}
+}
+
+// destName returns the destination path of the source path p, relative
+// to the destination root: p after Options.PathTransform and
+// Options.Normalize. Transformed paths are cleaned, so they cannot leave
+// the destination root. It returns "" if the transform skips p.
+func (o Options) destName(p string) (string, error) {
+	if o.PathTransform == nil {
+		return o.normalize(p), nil
+	}
+	name := o.PathTransform(p)
+	if name == "" {
+		return "", nil
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "", fmt.Errorf("path transform: %s maps to the destination root", p)
+	}
+	return o.normalize(name), nil
+}
@@ -0,0 +1,218 @@
+package sync
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestPathTransformHelpers(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{"AddPrefix", AddPrefix("2026/01/02/"), "a/b.txt", "2026/01/02/a/b.txt"},
+		{"AddSuffix", AddSuffix(".bak"), "a/b.txt", "a/b.txt.bak"},
+		{"Rewrite", Rewrite(regexp.MustCompile(`^logs/(\d+)/`), "archive/$1-"), "logs/42/app.log", "archive/42-app.log"},
+		{"Flatten", Flatten(), "a/b/c.txt", "c.txt"},
+		{"ChainTransforms", ChainTransforms(Flatten(), strings.ToLower, AddPrefix("x/")), "A/B.TXT", "x/b.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.in); got != tt.want {
+				t.Errorf("transform(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncPathTransform(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "Photos/IMG_1.JPG", "new photo")
+	writeFile(t, ctx, src, "Photos/tmp/skip.txt", "skip")
+	writeFile(t, ctx, dst, "img_1.jpg", "old")
+	writeFile(t, ctx, dst, "stale.jpg", "stale")
+
+	opts := Options{
+		DeleteExtra: true,
+		SizeOnly:    true,
+		PathTransform: func(p string) string {
+			if strings.Contains(p, "/tmp/") {
+				return ""
+			}
+			return strings.ToLower(path.Base(p))
+		},
+	}
+	result, err := Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Updated != 1 || result.Copied != 0 || result.Deleted != 1 {
+		t.Errorf("Updated = %d, Copied = %d, Deleted = %d, want 1, 0, 1", result.Updated, result.Copied, result.Deleted)
+	}
+	verifyFile(t, ctx, dst, "img_1.jpg", "new photo")
+	if exists, _ := dst.Exists(ctx, "skip.txt"); exists {
+		t.Error("skip.txt was copied")
+	}
+
+	// A second run is a no-op
+	result, err = Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied+result.Updated+result.Deleted != 0 {
+		t.Errorf("second run changed files: %+v", result)
+	}
+
+	check, err := Check(ctx, src, dst, "", "", Options{PathTransform: opts.PathTransform, IgnoreTime: true})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !check.InSync() {
+		t.Errorf("Check = %+v, want in sync", check)
+	}
+}
+
+func TestSyncPathTransformCollisions(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "a/report.txt", "a")
+	writeFile(t, ctx, src, "b/report.txt", "b")
+
+	result, err := Sync(ctx, src, dst, "", "out", Options{PathTransform: Flatten()})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", result.Copied)
+	}
+	verifyFile(t, ctx, dst, "out/report.txt", "a")
+	if len(result.Collisions) != 1 || result.Collisions[0].DstPath != "report.txt" {
+		t.Errorf("Collisions = %+v, want report.txt", result.Collisions)
+	}
+}
+
+func TestMovePathTransform(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "a/report.txt", "a")
+	writeFile(t, ctx, src, "b/report.txt", "b")
+	writeFile(t, ctx, src, "c/data.txt", "c")
+
+	if _, err := Move(ctx, src, dst, "", "out", Options{PathTransform: Flatten()}); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	verifyFile(t, ctx, dst, "out/report.txt", "a")
+	verifyFile(t, ctx, dst, "out/data.txt", "c")
+
+	for p, want := range map[string]bool{"a/report.txt": false, "b/report.txt": true, "c/data.txt": false} {
+		if exists, _ := src.Exists(ctx, p); exists != want {
+			t.Errorf("source %s exists = %v, want %v", p, exists, want)
+		}
+	}
+}
+
+func TestDestNameStaysInRoot(t *testing.T) {
+	opts := Options{PathTransform: AddPrefix("../../")}
+	got, err := opts.destName("a.txt")
+	if err != nil || got != "a.txt" {
+		t.Errorf("destName = %q, %v, want a.txt", got, err)
+	}
+
+	opts.PathTransform = func(string) string { return ".." }
+	if _, err := opts.destName("a.txt"); err == nil {
+		t.Error("destName mapping to the root should fail")
+	}
+}
+
+// gzipReader compresses r as it is read.
+func gzipReader(_ string, r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, r)
+		if err == nil {
+			err = zw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+func TestSyncTransformReader(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	dst := memory.New()
+	writeFile(t, ctx, src, "logs/app.log", "line 1\nline 2\n")
+
+	var paths []string
+	opts := Options{
+		IgnoreSize:    true,
+		PathTransform: AddSuffix(".gz"),
+		TransformReader: func(p string, r io.Reader) io.Reader {
+			paths = append(paths, p)
+			return gzipReader(p, r)
+		},
+	}
+	result, err := Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", result.Copied)
+	}
+	if len(paths) != 1 || paths[0] != "logs/app.log" {
+		t.Errorf("TransformReader paths = %v, want [logs/app.log]", paths)
+	}
+
+	r, err := dst.NewReader(ctx, "logs/app.log.gz")
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("destination is not gzip: %v", err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != "line 1\nline 2\n" {
+		t.Errorf("decompressed = %q", data)
+	}
+
+	// With IgnoreSize, the compressed copy is up to date
+	result, err = Sync(ctx, src, dst, "", "", opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Copied+result.Updated != 0 {
+		t.Errorf("second run copied files: %+v", result)
+	}
+}
+
+func TestSyncTransformReaderSameBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeFile(t, ctx, backend, "src/a.txt", "abc")
+
+	_, err := Sync(ctx, backend, backend, "src", "dst", Options{
+		TransformReader: func(_ string, r io.Reader) io.Reader {
+			return io.MultiReader(r, strings.NewReader("!"))
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	verifyFile(t, ctx, backend, "dst/a.txt", "abc!")
+}
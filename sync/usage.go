@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/sync/filter"
+)
+
+// UsageOptions configures Usage.
+type UsageOptions struct {
+	// Depth is the number of directory levels reported below the prefix.
+	// Files deeper down are counted in their ancestor at the last level.
+	// 0 means unlimited.
+	Depth int
+
+	// Filter limits the files counted.
+	Filter *filter.Filter
+}
+
+// DirUsage is the space used by a directory and everything below it.
+type DirUsage struct {
+	// Path is the directory path relative to the Usage prefix; "" for the
+	// prefix itself.
+	Path string `json:"path"`
+
+	// Files is the number of files in the directory and below.
+	Files int `json:"files"`
+
+	// Bytes is the total size of those files.
+	Bytes int64 `json:"bytes"`
+
+	// Children are the subdirectories, largest first.
+	Children []*DirUsage `json:"children,omitempty"`
+}
+
+// Usage returns the number of files and bytes under prefix, broken down
+// by directory, like du or ncdu. On object stores, directories are the
+// "/"-separated path segments of object keys.
+func Usage(ctx context.Context, backend omnistorage.Backend, prefix string, opts UsageOptions) (*DirUsage, error) {
+	files, err := listFiles(ctx, backend, prefix, Options{Filter: opts.Filter})
+	if err != nil {
+		return nil, err
+	}
+
+	root := &DirUsage{}
+	dirs := map[string]*DirUsage{"": root}
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		root.Files++
+		root.Bytes += f.Size
+
+		dir := path.Dir(f.Path)
+		if dir == "." {
+			continue
+		}
+		node := root
+		for i, name := range strings.Split(dir, "/") {
+			if opts.Depth > 0 && i >= opts.Depth {
+				break
+			}
+			p := path.Join(node.Path, name)
+			child, ok := dirs[p]
+			if !ok {
+				child = &DirUsage{Path: p}
+				dirs[p] = child
+				node.Children = append(node.Children, child)
+			}
+			child.Files++
+			child.Bytes += f.Size
+			node = child
+		}
+	}
+
+	root.sort()
+	return root, nil
+}
+
+// sort orders children largest first, then by path.
+func (u *DirUsage) sort() {
+	slices.SortFunc(u.Children, func(a, b *DirUsage) int {
+		if c := cmp.Compare(b.Bytes, a.Bytes); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	for _, c := range u.Children {
+		c.sort()
+	}
+}
+
+// WriteText writes the usage as an indented tree, one directory per line
+// with its size, share of the total, and file count.
+func (u *DirUsage) WriteText(w io.Writer) error {
+	return u.writeText(w, u.Bytes, 0)
+}
+
+func (u *DirUsage) writeText(w io.Writer, total int64, level int) error {
+	name := "."
+	if u.Path != "" {
+		name = path.Base(u.Path) + "/"
+	}
+	share := 100.0
+	if total > 0 {
+		share = float64(u.Bytes) / float64(total) * 100
+	}
+	if _, err := fmt.Fprintf(w, "%10s %5.1f%% %9d  %s%s\n",
+		formatBytes(u.Bytes), share, u.Files, strings.Repeat("  ", level), name); err != nil {
+		return err
+	}
+	for _, c := range u.Children {
+		if err := c.writeText(w, total, level+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the usage tree as indented JSON.
+func (u *DirUsage) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(u)
+}
+
+// formatBytes formats n with a binary unit, such as 1.5 GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
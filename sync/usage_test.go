@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+func TestUsage(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeFile(t, ctx, backend, "bucket/readme.txt", "hi")
+	writeFile(t, ctx, backend, "bucket/logs/2024/01/app.log", strings.Repeat("x", 100))
+	writeFile(t, ctx, backend, "bucket/logs/2024/02/app.log", strings.Repeat("x", 200))
+	writeFile(t, ctx, backend, "bucket/media/cat.jpg", strings.Repeat("x", 50))
+
+	u, err := Usage(ctx, backend, "bucket", UsageOptions{Depth: 2})
+	if err != nil {
+		t.Fatalf("Usage error = %v", err)
+	}
+	if u.Files != 4 || u.Bytes != 352 {
+		t.Errorf("root = %d files, %d bytes; want 4, 352", u.Files, u.Bytes)
+	}
+	if len(u.Children) != 2 || u.Children[0].Path != "logs" || u.Children[1].Path != "media" {
+		t.Fatalf("children = %+v, want logs then media", u.Children)
+	}
+	logs := u.Children[0]
+	if logs.Files != 2 || logs.Bytes != 300 {
+		t.Errorf("logs = %d files, %d bytes; want 2, 300", logs.Files, logs.Bytes)
+	}
+	// Depth 2 stops at logs/2024; the months are counted there.
+	if len(logs.Children) != 1 || logs.Children[0].Path != "logs/2024" || len(logs.Children[0].Children) != 0 {
+		t.Errorf("logs children = %+v, want only logs/2024", logs.Children)
+	}
+
+	var text bytes.Buffer
+	if err := u.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[0], " .") || !strings.Contains(lines[1], "85.2%") || !strings.HasSuffix(lines[2], "    2024/") {
+		t.Errorf("WriteText =\n%s", text.String())
+	}
+
+	var out bytes.Buffer
+	if err := u.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded DirUsage
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Bytes != 352 || len(decoded.Children) != 2 {
+		t.Errorf("WriteJSON round trip = %+v, %v", decoded, err)
+	}
+}
+
+func TestUsageUnlimitedDepth(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	writeFile(t, ctx, backend, "a/b/c/d.txt", "d")
+
+	u, err := Usage(ctx, backend, "", UsageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth := 0
+	for n := u; len(n.Children) > 0; n = n.Children[0] {
+		depth++
+	}
+	if depth != 3 {
+		t.Errorf("depth = %d, want 3", depth)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"path"
 
 	"github.com/grokify/omnistorage"
 )
@@ -208,23 +207,15 @@ func VerifyIntegrity(ctx context.Context, backend omnistorage.Backend, filePath
 
 // VerifyAllIntegrity checks integrity of all files under a path.
 func VerifyAllIntegrity(ctx context.Context, backend omnistorage.Backend, basePath string) ([]string, error) {
-	paths, err := backend.List(ctx, basePath)
-	if err != nil {
-		return nil, err
-	}
-
 	var corrupted []string
-	for _, p := range paths {
-		fullPath := p
-		if basePath != "" && len(p) > len(basePath) {
-			// Path is already full
-		} else if basePath != "" {
-			fullPath = path.Join(basePath, p)
-		}
-
-		if err := VerifyIntegrity(ctx, backend, fullPath); err != nil {
+	err := omnistorage.Walk(ctx, backend, basePath, func(p string, info omnistorage.ObjectInfo) error {
+		if !info.IsDir() && VerifyIntegrity(ctx, backend, p) != nil {
 			corrupted = append(corrupted, p)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return corrupted, nil
@@ -0,0 +1,86 @@
+package tee
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Logged operation names.
+const (
+	OpWrite  = "write"
+	OpDelete = "delete"
+	OpCopy   = "copy"
+	OpMove   = "move"
+	OpMkdir  = "mkdir"
+	OpRmdir  = "rmdir"
+)
+
+// Record is a line of the audit log.
+type Record struct {
+	// Time is when the operation started.
+	Time time.Time `json:"time"`
+
+	// Actor is who performed the operation, from ContextWithActor. It is
+	// empty if the context has none.
+	Actor string `json:"actor,omitempty"`
+
+	// Op is the operation, one of the Op constants.
+	Op string `json:"op"`
+
+	// Path is the path written or removed, or the source of a copy or
+	// move.
+	Path string `json:"path"`
+
+	// Dest is the destination of a copy or move.
+	Dest string `json:"dest,omitempty"`
+
+	// Bytes is the number of bytes written, or copied to the audit
+	// backend by a copy or move.
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// Error is the error of a failed operation.
+	Error string `json:"error,omitempty"`
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a context whose operations are logged as
+// performed by actor, such as a user or service name.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by ContextWithActor, or "".
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// record logs an operation, if there is a log.
+func (b *Backend) record(ctx context.Context, start time.Time, op, path, dest string, bytes int64, opErr error) error {
+	if b.log == nil {
+		return nil
+	}
+	rec := Record{
+		Time:  start.UTC(),
+		Actor: ActorFromContext(ctx),
+		Op:    op,
+		Path:  strings.TrimPrefix(path, "/"),
+		Dest:  strings.TrimPrefix(dest, "/"),
+		Bytes: bytes,
+	}
+	if opErr != nil {
+		rec.Error = opErr.Error()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	b.logMu.Lock()
+	defer b.logMu.Unlock()
+	_, err = b.log.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,365 @@
+// Package tee provides a backend wrapper that duplicates every write to a
+// secondary audit backend and logs each change as NDJSON.
+//
+// Reads, listings and stats go to the primary backend alone, so the audit
+// copy costs writes only:
+//
+//	logFile, _ := os.OpenFile("audit.ndjson", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+//	backend := tee.New(s3Backend, archiveBackend,
+//	    tee.WithAuditTransform(crypt.EncryptTransform(kw)),
+//	    tee.WithLog(logFile),
+//	)
+//	ctx = tee.ContextWithActor(ctx, "alice@example.com")
+//	w, _ := backend.NewWriter(ctx, "reports/q1.pdf")
+//
+// Deletes are not duplicated: the audit backend keeps every version last
+// written, while the log records the delete. Objects created by Copy and
+// Move are read back from the primary backend and written to the audit
+// backend at their new paths.
+package tee
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Option configures a tee backend.
+type Option func(*Backend)
+
+// WithAuditTransform sets a transform applied to the data written to the
+// audit backend, such as crypt.EncryptTransform or compression. It has the
+// signature of sync.Options.TransformReader.
+func WithAuditTransform(fn func(path string, r io.Reader) io.Reader) Option {
+	return func(b *Backend) {
+		b.transform = fn
+	}
+}
+
+// WithAuditPath sets a function mapping a path to the path of its copy in
+// the audit backend, such as adding ".gz" for compressed copies. The
+// default keeps the path.
+func WithAuditPath(fn func(path string) string) Option {
+	return func(b *Backend) {
+		if fn != nil {
+			b.auditPath = fn
+		}
+	}
+}
+
+// WithLog sets the writer of the audit log, which receives one JSON Record
+// per line for every write, delete, copy, move, mkdir and rmdir. Writes to
+// it are serialized. Without a log, writes are still duplicated.
+func WithLog(w io.Writer) Option {
+	return func(b *Backend) {
+		b.log = w
+	}
+}
+
+// WithClock sets the function that returns the time of a logged
+// operation. The default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(b *Backend) {
+		if now != nil {
+			b.now = now
+		}
+	}
+}
+
+// Backend wraps a primary backend, duplicating its writes to an audit
+// backend. An operation fails if the primary or the audit backend fails it,
+// or if it cannot be logged; the primary backend may have completed it by
+// then. Extended operations return omnistorage.ErrNotSupported if the
+// primary backend does not implement omnistorage.ExtendedBackend.
+type Backend struct {
+	primary   omnistorage.Backend
+	audit     omnistorage.Backend
+	transform func(path string, r io.Reader) io.Reader
+	auditPath func(string) string
+	now       func() time.Time
+
+	logMu sync.Mutex
+	log   io.Writer
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps primary, duplicating its writes to audit.
+func New(primary, audit omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{
+		primary:   primary,
+		audit:     audit,
+		auditPath: func(p string) string { return p },
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the primary backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.primary
+}
+
+// Audit returns the audit backend.
+func (b *Backend) Audit() omnistorage.Backend {
+	return b.audit
+}
+
+// NewWriter creates a writer for path on both backends. The write is
+// logged when the writer is closed.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	start := b.now()
+	created := !existed(ctx, b.primary, path)
+	auditCreated := !existed(ctx, b.audit, b.auditPath(path))
+	w, err := b.primary.NewWriter(ctx, path, opts...)
+	if err != nil {
+		return nil, errors.Join(err, b.record(ctx, start, OpWrite, path, "", 0, err))
+	}
+	aw, err := b.newAuditWriter(ctx, path, opts)
+	if err != nil {
+		_ = w.Close()
+		return nil, errors.Join(err, b.record(ctx, start, OpWrite, path, "", 0, err))
+	}
+	return &writer{ctx: ctx, b: b, path: path, start: start, w: w, aw: aw,
+		created: created, auditCreated: auditCreated}, nil
+}
+
+// existed reports whether path exists on backend. An object whose
+// existence cannot be checked is assumed to exist, so that it is never
+// deleted after a failed write.
+func existed(ctx context.Context, backend omnistorage.Backend, path string) bool {
+	exists, err := backend.Exists(ctx, path)
+	return exists || err != nil
+}
+
+// NewReader creates a reader for path on the primary backend.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	return b.primary.NewReader(ctx, path, opts...)
+}
+
+// Exists checks if a path exists on the primary backend.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	return b.primary.Exists(ctx, path)
+}
+
+// Delete removes path from the primary backend. The audit copy is kept.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	start := b.now()
+	err := b.primary.Delete(ctx, path)
+	return errors.Join(err, b.record(ctx, start, OpDelete, path, "", 0, err))
+}
+
+// List lists paths on the primary backend.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	return b.primary.List(ctx, prefix)
+}
+
+// Close closes both backends.
+func (b *Backend) Close() error {
+	return errors.Join(b.primary.Close(), b.audit.Close())
+}
+
+// Stat returns metadata about an object on the primary backend.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.primary)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory on the primary backend.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.primary)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	start := b.now()
+	err := ext.Mkdir(ctx, path)
+	return errors.Join(err, b.record(ctx, start, OpMkdir, path, "", 0, err))
+}
+
+// Rmdir removes an empty directory from the primary backend.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.primary)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	start := b.now()
+	err := ext.Rmdir(ctx, path)
+	return errors.Join(err, b.record(ctx, start, OpRmdir, path, "", 0, err))
+}
+
+// Copy copies an object within the primary backend, then copies dst to the
+// audit backend.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.primary)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	start := b.now()
+	err := ext.Copy(ctx, src, dst)
+	var n int64
+	if err == nil {
+		n, err = b.duplicate(ctx, dst)
+	}
+	return errors.Join(err, b.record(ctx, start, OpCopy, src, dst, n, err))
+}
+
+// Move moves an object within the primary backend, then copies dst to the
+// audit backend. The audit copy of src is kept.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.primary)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	start := b.now()
+	err := ext.Move(ctx, src, dst)
+	var n int64
+	if err == nil {
+		n, err = b.duplicate(ctx, dst)
+	}
+	return errors.Join(err, b.record(ctx, start, OpMove, src, dst, n, err))
+}
+
+// Features returns the features of the primary backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.primary); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// duplicate copies path from the primary to the audit backend and returns
+// the number of bytes read.
+func (b *Backend) duplicate(ctx context.Context, path string) (int64, error) {
+	r, err := b.primary.NewReader(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = r.Close() }()
+
+	aw, err := b.newAuditWriter(ctx, path, nil)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(aw, r)
+	return n, errors.Join(err, aw.Close())
+}
+
+// newAuditWriter creates a writer of the audit copy of path, applying the
+// audit transform. With a transform, the options describing the bytes
+// written, their checksums and content encoding, are dropped, since they
+// no longer match.
+func (b *Backend) newAuditWriter(ctx context.Context, path string, opts []omnistorage.WriterOption) (io.WriteCloser, error) {
+	if b.transform != nil {
+		opts = append(slices.Clip(opts), untransformed)
+	}
+	aw, err := b.audit.NewWriter(ctx, b.auditPath(path), opts...)
+	if err != nil || b.transform == nil {
+		return aw, err
+	}
+
+	pr, pw := io.Pipe()
+	tw := &transformWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := io.Copy(aw, b.transform(path, pr))
+		_ = pr.CloseWithError(err)
+		tw.done <- errors.Join(err, aw.Close())
+	}()
+	return tw, nil
+}
+
+// untransformed clears the writer options that the audit transform
+// invalidates.
+func untransformed(c *omnistorage.WriterConfig) {
+	c.ContentMD5 = ""
+	c.ContentSHA256 = ""
+	c.ContentEncoding = ""
+}
+
+// writer writes to the primary and audit writers, and logs the write on
+// Close. After a write error, Close deletes the partial copies this writer
+// created; objects that existed before the write are never deleted, so an
+// overwrite does not erase the previous object or its audit copy.
+type writer struct {
+	ctx   context.Context
+	b     *Backend
+	path  string
+	start time.Time
+	w     io.WriteCloser
+	aw    io.WriteCloser
+
+	created      bool // the primary object did not exist before the write
+	auditCreated bool // the audit copy did not exist before the write
+
+	bytes int64
+	err   error // first write error
+	once  sync.Once
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	if err == nil {
+		_, err = w.aw.Write(p[:n])
+	}
+	w.bytes += int64(n)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *writer) Close() error {
+	var err error
+	w.once.Do(func() {
+		err = errors.Join(w.err, w.aw.Close(), w.w.Close())
+		if w.err != nil {
+			ctx := context.WithoutCancel(w.ctx)
+			if w.created {
+				err = errors.Join(err, ignoreNotFound(w.b.primary.Delete(ctx, w.path)))
+			}
+			if w.auditCreated {
+				err = errors.Join(err, ignoreNotFound(w.b.audit.Delete(ctx, w.b.auditPath(w.path))))
+			}
+		}
+		err = errors.Join(err, w.b.record(w.ctx, w.start, OpWrite, w.path, "", w.bytes, err))
+	})
+	return err
+}
+
+// ignoreNotFound returns err, or nil if it is omnistorage.ErrNotFound.
+func ignoreNotFound(err error) error {
+	if omnistorage.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// transformWriter feeds the audit transform through a pipe. Close waits
+// for the transformed data to be written.
+type transformWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *transformWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *transformWriter) Close() error {
+	_ = w.pw.Close()
+	return <-w.done
+}
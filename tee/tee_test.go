@@ -0,0 +1,260 @@
+package tee
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), memory.New(), WithLog(io.Discard))
+	})
+}
+
+func read(t *testing.T, b omnistorage.Backend, p string) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewReader(%s) error = %v", p, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+// records parses an audit log.
+func records(t *testing.T, log *bytes.Buffer) []Record {
+	t.Helper()
+	var recs []Record
+	sc := bufio.NewScanner(bytes.NewReader(log.Bytes()))
+	for sc.Scan() {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("log line %q: %v", sc.Text(), err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func upper(_ string, r io.Reader) io.Reader {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return iotest.ErrReader(err)
+	}
+	return bytes.NewReader(bytes.ToUpper(data))
+}
+
+func TestTee(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "alice")
+	primary, audit := memory.New(), memory.New()
+	var log bytes.Buffer
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	b := New(primary, audit,
+		WithAuditTransform(upper),
+		WithAuditPath(func(p string) string { return "archive/" + p }),
+		WithLog(&log),
+		WithClock(func() time.Time { return now }),
+	)
+
+	w, err := b.NewWriter(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("NewWriter error = %v", err)
+	}
+	_, _ = io.WriteString(w, "hello")
+	_, _ = io.WriteString(w, " world")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	if got := read(t, b, "a.txt"); got != "hello world" {
+		t.Errorf("read a.txt = %q", got)
+	}
+	if got := read(t, audit, "archive/a.txt"); got != "HELLO WORLD" {
+		t.Errorf("audit copy = %q", got)
+	}
+
+	if err := b.Copy(ctx, "a.txt", "b.txt"); err != nil {
+		t.Fatalf("Copy error = %v", err)
+	}
+	if err := b.Move(ctx, "b.txt", "c.txt"); err != nil {
+		t.Fatalf("Move error = %v", err)
+	}
+	if err := b.Delete(ctx, "a.txt"); err != nil {
+		t.Fatalf("Delete error = %v", err)
+	}
+
+	paths, _ := b.List(ctx, "")
+	if strings.Join(paths, ",") != "c.txt" {
+		t.Errorf("List = %v, want [c.txt]", paths)
+	}
+	paths, _ = audit.List(ctx, "")
+	if strings.Join(paths, ",") != "archive/a.txt,archive/b.txt,archive/c.txt" {
+		t.Errorf("audit List = %v", paths)
+	}
+
+	want := []Record{
+		{Time: now, Actor: "alice", Op: OpWrite, Path: "a.txt", Bytes: 11},
+		{Time: now, Actor: "alice", Op: OpCopy, Path: "a.txt", Dest: "b.txt", Bytes: 11},
+		{Time: now, Actor: "alice", Op: OpMove, Path: "b.txt", Dest: "c.txt", Bytes: 11},
+		{Time: now, Actor: "alice", Op: OpDelete, Path: "a.txt"},
+	}
+	got := records(t, &log)
+	if len(got) != len(want) {
+		t.Fatalf("log = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// failingWriter is a backend whose writers fail on Close.
+type failingWriter struct {
+	*memory.Backend
+}
+
+func (b failingWriter) NewWriter(ctx context.Context, p string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	w, err := b.Backend.NewWriter(ctx, p, opts...)
+	return failClose{w}, err
+}
+
+type failClose struct{ io.WriteCloser }
+
+func (failClose) Close() error { return errors.New("disk full") }
+
+func TestAuditFailure(t *testing.T) {
+	ctx := context.Background()
+	var log bytes.Buffer
+	b := New(memory.New(), failingWriter{memory.New()}, WithLog(&log))
+
+	w, err := b.NewWriter(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("NewWriter error = %v", err)
+	}
+	_, _ = io.WriteString(w, "data")
+	if err := w.Close(); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Close error = %v, want the audit error", err)
+	}
+
+	recs := records(t, &log)
+	if len(recs) != 1 || recs[0].Error != "disk full" || recs[0].Actor != "" {
+		t.Errorf("log = %+v, want a failed write", recs)
+	}
+}
+
+// failWrite is an audit backend whose writers fail after the first write.
+type failWrite struct{ *memory.Backend }
+
+func (b failWrite) NewWriter(ctx context.Context, p string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	w, err := b.Backend.NewWriter(ctx, p, opts...)
+	return &failSecondWrite{WriteCloser: w}, err
+}
+
+type failSecondWrite struct {
+	io.WriteCloser
+	n int
+}
+
+func (w *failSecondWrite) Write(p []byte) (int, error) {
+	if w.n++; w.n > 1 {
+		return 0, errors.New("connection reset")
+	}
+	return w.WriteCloser.Write(p)
+}
+
+func TestAuditWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	primary, audit := memory.New(), memory.New()
+	b := New(primary, failWrite{audit}, WithLog(io.Discard))
+
+	w, _ := b.NewWriter(ctx, "a.txt")
+	_, _ = io.WriteString(w, "part1")
+	if _, err := io.WriteString(w, "part2"); err == nil {
+		t.Fatal("second Write succeeded")
+	}
+	if err := w.Close(); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("Close error = %v, want the write error", err)
+	}
+	for name, backend := range map[string]omnistorage.Backend{"primary": primary, "audit": audit} {
+		if exists, _ := backend.Exists(ctx, "a.txt"); exists {
+			t.Errorf("%s kept a partial copy", name)
+		}
+	}
+}
+
+func TestAuditWriteFailureKeepsExisting(t *testing.T) {
+	ctx := context.Background()
+	primary, audit := memory.New(), memory.New()
+	for _, backend := range []omnistorage.Backend{primary, audit} {
+		w, _ := backend.NewWriter(ctx, "a.txt")
+		_, _ = io.WriteString(w, "v1")
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := New(primary, failWrite{audit}, WithLog(io.Discard))
+
+	w, _ := b.NewWriter(ctx, "a.txt")
+	_, _ = io.WriteString(w, "part1")
+	_, _ = io.WriteString(w, "part2")
+	if err := w.Close(); err == nil {
+		t.Fatal("Close succeeded after a write error")
+	}
+	for name, backend := range map[string]omnistorage.Backend{"primary": primary, "audit": audit} {
+		if exists, _ := backend.Exists(ctx, "a.txt"); !exists {
+			t.Errorf("%s deleted the object that existed before the write", name)
+		}
+	}
+}
+
+func TestTransformDropsChecksums(t *testing.T) {
+	ctx := context.Background()
+	primary, audit := memory.New(), memory.New()
+	b := New(primary, audit, WithAuditTransform(upper), WithLog(io.Discard))
+
+	sum := md5.Sum([]byte("data"))
+	w, _ := b.NewWriter(ctx, "a.txt",
+		omnistorage.WithContentMD5(hex.EncodeToString(sum[:])),
+		omnistorage.WithContentEncoding("identity"))
+	_, _ = io.WriteString(w, "data")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	if got := read(t, audit, "a.txt"); got != "DATA" {
+		t.Errorf("audit copy = %q, want %q", got, "DATA")
+	}
+	info, err := audit.Stat(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext, ok := info.(omnistorage.ExtendedObjectInfo); ok && ext.ContentEncoding() != "" {
+		t.Errorf("audit ContentEncoding = %q, want none", ext.ContentEncoding())
+	}
+}
+
+func TestCopyNotFound(t *testing.T) {
+	var log bytes.Buffer
+	b := New(memory.New(), memory.New(), WithLog(&log))
+	if err := b.Copy(context.Background(), "missing", "b.txt"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Copy error = %v, want ErrNotFound", err)
+	}
+	if recs := records(t, &log); len(recs) != 1 || recs[0].Op != OpCopy || recs[0].Error == "" {
+		t.Errorf("log = %+v, want a failed copy", recs)
+	}
+}
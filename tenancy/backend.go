@@ -0,0 +1,253 @@
+package tenancy
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Usage counts the operations of a tenant.
+type Usage struct {
+	// Reads is the number of readers opened.
+	Reads int64
+
+	// Writes is the number of writers closed, copies and moves.
+	Writes int64
+
+	// Deletes is the number of deletes.
+	Deletes int64
+
+	// BytesRead is the number of bytes read.
+	BytesRead int64
+
+	// BytesWritten is the number of bytes written through writers.
+	BytesWritten int64
+}
+
+type counters struct {
+	reads, writes, deletes  atomic.Int64
+	bytesRead, bytesWritten atomic.Int64
+}
+
+func (c *counters) usage() Usage {
+	return Usage{
+		Reads:        c.reads.Load(),
+		Writes:       c.writes.Load(),
+		Deletes:      c.deletes.Load(),
+		BytesRead:    c.bytesRead.Load(),
+		BytesWritten: c.bytesWritten.Load(),
+	}
+}
+
+// Backend is the backend of one tenant. Paths are relative to the tenant.
+// Failed operations are not counted. Extended operations return
+// omnistorage.ErrNotSupported if the tenant's backend does not implement
+// omnistorage.ExtendedBackend.
+type Backend struct {
+	backend  omnistorage.Backend
+	id       string
+	counters *counters
+	closed   atomic.Bool
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// ID returns the tenant ID.
+func (b *Backend) ID() string {
+	return b.id
+}
+
+// Unwrap returns the tenant's backend: a subpath of the shared backend, or
+// the backend given with WithBackend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// NewWriter creates a writer for the given path.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	inner, err := b.inner()
+	if err != nil {
+		return nil, err
+	}
+	w, err := inner.NewWriter(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &writer{w: w, counters: b.counters}, nil
+}
+
+// NewReader creates a reader for the given path.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	inner, err := b.inner()
+	if err != nil {
+		return nil, err
+	}
+	r, err := inner.NewReader(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	b.counters.reads.Add(1)
+	return &reader{r: r, counters: b.counters}, nil
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	inner, err := b.inner()
+	if err != nil {
+		return false, err
+	}
+	return inner.Exists(ctx, path)
+}
+
+// Delete removes a path.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	inner, err := b.inner()
+	if err != nil {
+		return err
+	}
+	if err := inner.Delete(ctx, path); err != nil {
+		return err
+	}
+	b.counters.deletes.Add(1)
+	return nil
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	inner, err := b.inner()
+	if err != nil {
+		return nil, err
+	}
+	return inner.List(ctx, prefix)
+}
+
+// Close makes later operations fail with omnistorage.ErrBackendClosed. It
+// does not close the tenant's backend, which Tenants.Close closes.
+func (b *Backend) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+// inner returns the tenant's backend, or an error if b is closed.
+func (b *Backend) inner() (omnistorage.Backend, error) {
+	if b.closed.Load() {
+		return nil, omnistorage.ErrBackendClosed
+	}
+	return b.backend, nil
+}
+
+// extended returns the tenant's backend as an ExtendedBackend, or an
+// error if b is closed or the backend is not extended.
+func (b *Backend) extended() (omnistorage.ExtendedBackend, error) {
+	inner, err := b.inner()
+	if err != nil {
+		return nil, err
+	}
+	ext, ok := omnistorage.AsExtended(inner)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext, nil
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, err := b.extended()
+	if err != nil {
+		return nil, err
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, err := b.extended()
+	if err != nil {
+		return err
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, err := b.extended()
+	if err != nil {
+		return err
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object within the tenant.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, err := b.extended()
+	if err != nil {
+		return err
+	}
+	if err := ext.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	b.counters.writes.Add(1)
+	return nil
+}
+
+// Move moves an object within the tenant.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, err := b.extended()
+	if err != nil {
+		return err
+	}
+	if err := ext.Move(ctx, src, dst); err != nil {
+		return err
+	}
+	b.counters.writes.Add(1)
+	return nil
+}
+
+// Features returns the features of the tenant's backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// reader counts the bytes read.
+type reader struct {
+	r        io.ReadCloser
+	counters *counters
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.counters.bytesRead.Add(int64(n))
+	return n, err
+}
+
+func (r *reader) Close() error {
+	return r.r.Close()
+}
+
+// writer counts the bytes written, and the write once it is closed.
+type writer struct {
+	w        io.WriteCloser
+	counters *counters
+	once     sync.Once
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.counters.bytesWritten.Add(int64(n))
+	return n, err
+}
+
+func (w *writer) Close() error {
+	err := w.w.Close()
+	if err == nil {
+		w.once.Do(func() { w.counters.writes.Add(1) })
+	}
+	return err
+}
@@ -0,0 +1,170 @@
+// Package tenancy partitions one backend between tenants, so a SaaS layer
+// can safely share a bucket.
+//
+// Each tenant gets a backend scoped to its own prefix, "alice/" for the
+// tenant "alice" by default, or a separate backend of its own:
+//
+//	tenants := tenancy.New(s3Backend,
+//	    tenancy.WithBackend("bigcorp", dedicatedBackend),
+//	)
+//	backend, err := tenants.Tenant("alice")
+//	w, _ := backend.NewWriter(ctx, "docs/a.txt") // alice/docs/a.txt in s3Backend
+//
+// Tenant IDs are validated, and paths that would leave the tenant's
+// prefix, such as "../bob/a.txt", fail with omnistorage.ErrInvalidPath.
+// Every tenant's reads, writes and deletes are counted; Usage returns the
+// counters. To limit what tenants store, wrap the shared backend with
+// quota.New and quota.WithTenants(quota.TopLevel, ...).
+package tenancy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/grokify/omnistorage"
+)
+
+// ErrInvalidTenant is returned for tenant IDs that are empty, too long, or
+// contain characters other than letters, digits, '.', '_' and '-'.
+var ErrInvalidTenant = errors.New("tenancy: invalid tenant ID")
+
+// MaxIDLength is the maximum length of a tenant ID.
+const MaxIDLength = 128
+
+// Option configures a Tenants.
+type Option func(*Tenants)
+
+// WithPrefix sets the function mapping a valid tenant ID to its prefix in
+// the shared backend. It must map different IDs to prefixes neither of
+// which contains the other, such as "tenants/" + id. The default is the
+// ID itself, so each tenant has a top-level directory.
+func WithPrefix(fn func(id string) string) Option {
+	return func(t *Tenants) {
+		if fn != nil {
+			t.prefix = fn
+		}
+	}
+}
+
+// WithBackend gives the tenant id a separate backend instead of a prefix
+// of the shared one.
+func WithBackend(id string, backend omnistorage.Backend) Option {
+	return func(t *Tenants) {
+		t.backends[id] = backend
+	}
+}
+
+// Tenants maps tenant IDs to isolated backends. It is safe for concurrent
+// use.
+type Tenants struct {
+	shared   omnistorage.Backend
+	prefix   func(string) string
+	backends map[string]omnistorage.Backend
+
+	mu       sync.Mutex
+	counters map[string]*counters
+}
+
+// New partitions shared between tenants.
+func New(shared omnistorage.Backend, opts ...Option) *Tenants {
+	t := &Tenants{
+		shared:   shared,
+		prefix:   func(id string) string { return id },
+		backends: make(map[string]omnistorage.Backend),
+		counters: make(map[string]*counters),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// ValidateID returns an error wrapping ErrInvalidTenant if id is not a
+// valid tenant ID.
+func ValidateID(id string) error {
+	if id == "" || len(id) > MaxIDLength || id == "." || id == ".." {
+		return fmt.Errorf("%w: %q", ErrInvalidTenant, id)
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '_', c == '-':
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidTenant, id)
+		}
+	}
+	return nil
+}
+
+// Tenant returns the backend of tenant id: the backend given with
+// WithBackend, or the tenant's prefix of the shared backend. Closing it
+// closes only the returned handle; Close closes the backends of all
+// tenants.
+func (t *Tenants) Tenant(id string) (*Backend, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	inner, ok := t.backends[id]
+	if !ok {
+		inner = omnistorage.Subpath(t.shared, t.prefix(id))
+	}
+	return &Backend{backend: inner, id: id, counters: t.countersOf(id)}, nil
+}
+
+// Prefix returns the prefix of tenant id in the shared backend, with a
+// trailing slash, or "" if the tenant has a separate backend.
+func (t *Tenants) Prefix(id string) (string, error) {
+	if err := ValidateID(id); err != nil {
+		return "", err
+	}
+	if _, ok := t.backends[id]; ok {
+		return "", nil
+	}
+	return omnistorage.Subpath(t.shared, t.prefix(id)).Prefix(), nil
+}
+
+// Usage returns the counters of tenant id, which are zero until it uses
+// its backend.
+func (t *Tenants) Usage(id string) Usage {
+	t.mu.Lock()
+	c := t.counters[id]
+	t.mu.Unlock()
+	if c == nil {
+		return Usage{}
+	}
+	return c.usage()
+}
+
+// IDs returns the IDs of the tenants that have a backend, sorted.
+func (t *Tenants) IDs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]string, 0, len(t.counters))
+	for id := range t.counters {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Close closes the shared backend and the separate backends of tenants.
+func (t *Tenants) Close() error {
+	errs := []error{t.shared.Close()}
+	for _, b := range t.backends {
+		errs = append(errs, b.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (t *Tenants) countersOf(id string) *counters {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.counters[id]
+	if c == nil {
+		c = &counters{}
+		t.counters[id] = c
+	}
+	return c
+}
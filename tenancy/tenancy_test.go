@@ -0,0 +1,119 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		b, err := New(memory.New()).Tenant("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	})
+}
+
+func write(t *testing.T, b omnistorage.Backend, p, data string) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewWriter(%s) error = %v", p, err)
+	}
+	_, _ = io.WriteString(w, data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%s) error = %v", p, err)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	ctx := context.Background()
+	shared, dedicated := memory.New(), memory.New()
+	tenants := New(shared,
+		WithPrefix(func(id string) string { return "tenants/" + id }),
+		WithBackend("bigcorp", dedicated),
+	)
+
+	alice, err := tenants.Tenant("alice")
+	if err != nil {
+		t.Fatalf("Tenant(alice) error = %v", err)
+	}
+	bob, _ := tenants.Tenant("bob")
+	bigcorp, _ := tenants.Tenant("bigcorp")
+
+	write(t, alice, "docs/a.txt", "hello")
+	write(t, bob, "b.txt", "hi")
+	write(t, bigcorp, "c.txt", "data")
+
+	got, _ := shared.List(ctx, "")
+	if want := []string{"tenants/alice/docs/a.txt", "tenants/bob/b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("shared List = %v, want %v", got, want)
+	}
+	if ok, _ := dedicated.Exists(ctx, "c.txt"); !ok {
+		t.Error("bigcorp's object is not in its own backend")
+	}
+	if got, _ := alice.List(ctx, ""); !slices.Equal(got, []string{"docs/a.txt"}) {
+		t.Errorf("alice List = %v", got)
+	}
+	if prefix, _ := tenants.Prefix("alice"); prefix != "tenants/alice/" {
+		t.Errorf("Prefix(alice) = %q", prefix)
+	}
+
+	for _, p := range []string{"../bob/b.txt", "docs/../../bob/b.txt"} {
+		if _, err := alice.NewReader(ctx, p); !errors.Is(err, omnistorage.ErrInvalidPath) {
+			t.Errorf("NewReader(%s) error = %v, want ErrInvalidPath", p, err)
+		}
+	}
+	if _, err := alice.List(ctx, "../"); !errors.Is(err, omnistorage.ErrInvalidPath) {
+		t.Errorf("List(../) error = %v, want ErrInvalidPath", err)
+	}
+	if _, err := alice.NewWriter(ctx, ""); !errors.Is(err, omnistorage.ErrInvalidPath) {
+		t.Errorf("NewWriter(\"\") error = %v, want ErrInvalidPath", err)
+	}
+
+	r, err := alice.NewReader(ctx, "docs/a.txt")
+	if err != nil {
+		t.Fatalf("NewReader error = %v", err)
+	}
+	_, _ = io.ReadAll(r)
+	_ = r.Close()
+	if err := alice.Copy(ctx, "docs/a.txt", "docs/b.txt"); err != nil {
+		t.Fatalf("Copy error = %v", err)
+	}
+	if err := alice.Delete(ctx, "docs/a.txt"); err != nil {
+		t.Fatalf("Delete error = %v", err)
+	}
+
+	want := Usage{Reads: 1, Writes: 2, Deletes: 1, BytesRead: 5, BytesWritten: 5}
+	if got := tenants.Usage("alice"); got != want {
+		t.Errorf("Usage(alice) = %+v, want %+v", got, want)
+	}
+	if got := tenants.Usage("bob"); got != (Usage{Writes: 1, BytesWritten: 2}) {
+		t.Errorf("Usage(bob) = %+v", got)
+	}
+	if got := tenants.IDs(); !slices.Equal(got, []string{"alice", "bigcorp", "bob"}) {
+		t.Errorf("IDs() = %v", got)
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"alice", "tenant-42", "acme.corp_eu"} {
+		if err := ValidateID(id); err != nil {
+			t.Errorf("ValidateID(%q) error = %v", id, err)
+		}
+	}
+	tenants := New(memory.New())
+	for _, id := range []string{"", ".", "..", "a/b", "../bob", "a b", string(make([]byte, MaxIDLength+1))} {
+		if _, err := tenants.Tenant(id); !errors.Is(err, ErrInvalidTenant) {
+			t.Errorf("Tenant(%q) error = %v, want ErrInvalidTenant", id, err)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+// Package throttle provides a backend wrapper that simulates network
+// latency and bandwidth limits.
+//
+// Wrapping a fast local backend (memory or file) in a throttled backend
+// gives reproducible, cloud-like performance characteristics, so sync
+// performance can be benchmarked in CI without real cloud endpoints:
+//
+//	slow := throttle.New(memory.New(),
+//	    throttle.WithLatency(20*time.Millisecond),
+//	    throttle.WithJitter(5*time.Millisecond, 1),
+//	    throttle.WithReadBandwidth(50<<20),  // 50 MB/s
+//	    throttle.WithWriteBandwidth(10<<20), // 10 MB/s
+//	)
+//	result, err := sync.Sync(ctx, slow, dst, "", "", sync.Options{})
+//
+// Latency is added once at the start of every operation, before it reaches
+// the wrapped backend, and is cut short if the context is canceled.
+// Bandwidth limits are shared by all readers or all writers of a throttled
+// backend, like a single network link.
+package throttle
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Op identifies a backend operation for per-operation latency.
+type Op int
+
+// Operations.
+const (
+	OpRead Op = iota
+	OpWrite
+	OpExists
+	OpDelete
+	OpList
+	OpStat
+	OpMkdir
+	OpRmdir
+	OpCopy
+	OpMove
+)
+
+// String returns the operation name.
+func (op Op) String() string {
+	switch op {
+	case OpRead:
+		return "read"
+	case OpWrite:
+		return "write"
+	case OpExists:
+		return "exists"
+	case OpDelete:
+		return "delete"
+	case OpList:
+		return "list"
+	case OpStat:
+		return "stat"
+	case OpMkdir:
+		return "mkdir"
+	case OpRmdir:
+		return "rmdir"
+	case OpCopy:
+		return "copy"
+	case OpMove:
+		return "move"
+	default:
+		return "unknown"
+	}
+}
+
+// Option configures a throttled backend.
+type Option func(*Backend)
+
+// WithLatency sets the latency added to every operation.
+func WithLatency(d time.Duration) Option {
+	return func(b *Backend) {
+		b.latency = d
+	}
+}
+
+// WithOpLatency sets the latency for one operation, overriding WithLatency.
+func WithOpLatency(op Op, d time.Duration) Option {
+	return func(b *Backend) {
+		b.opLatency[op] = d
+	}
+}
+
+// WithJitter adds a random extra latency of up to d to every operation.
+// The random sequence is determined by seed, so runs are reproducible.
+func WithJitter(d time.Duration, seed uint64) Option {
+	return func(b *Backend) {
+		b.jitter = d
+		b.rand = rand.New(rand.NewPCG(seed, seed)) //nolint:gosec // simulation, not security
+	}
+}
+
+// WithReadBandwidth caps the total read throughput in bytes per second.
+func WithReadBandwidth(bytesPerSecond int64) Option {
+	return func(b *Backend) {
+		b.readRate = newPacer(bytesPerSecond)
+	}
+}
+
+// WithWriteBandwidth caps the total write throughput in bytes per second.
+func WithWriteBandwidth(bytesPerSecond int64) Option {
+	return func(b *Backend) {
+		b.writeRate = newPacer(bytesPerSecond)
+	}
+}
+
+// Backend wraps a backend with simulated latency and bandwidth limits.
+// Extended operations return omnistorage.ErrNotSupported if the wrapped
+// backend does not implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend   omnistorage.Backend
+	latency   time.Duration
+	opLatency map[Op]time.Duration
+	jitter    time.Duration
+	readRate  *pacer
+	writeRate *pacer
+
+	mu   sync.Mutex // guards rand
+	rand *rand.Rand
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend with the given throttling options. With no options,
+// operations pass through unchanged.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{
+		backend:   backend,
+		opLatency: make(map[Op]time.Duration),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// delay returns the simulated latency for op.
+func (b *Backend) delay(op Op) time.Duration {
+	d, ok := b.opLatency[op]
+	if !ok {
+		d = b.latency
+	}
+	if b.jitter > 0 {
+		b.mu.Lock()
+		d += time.Duration(b.rand.Int64N(int64(b.jitter) + 1))
+		b.mu.Unlock()
+	}
+	return d
+}
+
+// wait sleeps for the latency of op, returning early if ctx is done.
+func (b *Backend) wait(ctx context.Context, op Op) error {
+	d := b.delay(op)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewWriter creates a writer whose throughput is capped by the write
+// bandwidth.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	if err := b.wait(ctx, OpWrite); err != nil {
+		return nil, err
+	}
+	w, err := b.backend.NewWriter(ctx, path, opts...)
+	if err != nil || b.writeRate == nil {
+		return w, err
+	}
+	return &writer{w: w, pacer: b.writeRate}, nil
+}
+
+// NewReader creates a reader whose throughput is capped by the read
+// bandwidth.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	if err := b.wait(ctx, OpRead); err != nil {
+		return nil, err
+	}
+	r, err := b.backend.NewReader(ctx, path, opts...)
+	if err != nil || b.readRate == nil {
+		return r, err
+	}
+	return &reader{r: r, pacer: b.readRate}, nil
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	if err := b.wait(ctx, OpExists); err != nil {
+		return false, err
+	}
+	return b.backend.Exists(ctx, path)
+}
+
+// Delete removes a path.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	if err := b.wait(ctx, OpDelete); err != nil {
+		return err
+	}
+	return b.backend.Delete(ctx, path)
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := b.wait(ctx, OpList); err != nil {
+		return nil, err
+	}
+	return b.backend.List(ctx, prefix)
+}
+
+// Close closes the wrapped backend.
+func (b *Backend) Close() error {
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, OpStat); err != nil {
+		return nil, err
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, OpMkdir); err != nil {
+		return err
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, OpRmdir); err != nil {
+		return err
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object within the wrapped backend. Server-side copies
+// are not subject to the bandwidth limits.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, OpCopy); err != nil {
+		return err
+	}
+	return ext.Copy(ctx, src, dst)
+}
+
+// Move moves an object within the wrapped backend.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.wait(ctx, OpMove); err != nil {
+		return err
+	}
+	return ext.Move(ctx, src, dst)
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
+
+// chunkSize bounds each paced read or write, so concurrent streams share
+// bandwidth smoothly.
+const chunkSize = 32 * 1024
+
+type reader struct {
+	r     io.ReadCloser
+	pacer *pacer
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > chunkSize {
+		p = p[:chunkSize]
+	}
+	n, err := r.r.Read(p)
+	r.pacer.wait(n)
+	return n, err
+}
+
+func (r *reader) Close() error {
+	return r.r.Close()
+}
+
+type writer struct {
+	w     io.WriteCloser
+	pacer *pacer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		w.pacer.wait(len(chunk))
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (w *writer) Close() error {
+	return w.w.Close()
+}
+
+// pacer spaces transfers so that their total rate does not exceed a fixed
+// number of bytes per second. Each transfer reserves the next free slot on
+// a shared timeline, so concurrent streams split the bandwidth between them.
+type pacer struct {
+	rate int64
+
+	mu   sync.Mutex
+	next time.Time // when the link is next free
+}
+
+// newPacer returns a pacer for bytesPerSecond, or nil if it is not positive.
+func newPacer(bytesPerSecond int64) *pacer {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &pacer{rate: bytesPerSecond}
+}
+
+// wait blocks until n bytes may be transferred.
+func (p *pacer) wait(n int) {
+	if n <= 0 {
+		return
+	}
+	cost := time.Duration(int64(n) * int64(time.Second) / p.rate)
+
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	p.next = p.next.Add(cost)
+	until := p.next
+	p.mu.Unlock()
+
+	time.Sleep(time.Until(until))
+}
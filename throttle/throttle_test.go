@@ -0,0 +1,160 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(),
+			WithLatency(time.Millisecond),
+			WithReadBandwidth(1<<30),
+			WithWriteBandwidth(1<<30),
+		)
+	})
+}
+
+func TestLatency(t *testing.T) {
+	b := New(memory.New(),
+		WithLatency(20*time.Millisecond),
+		WithOpLatency(OpExists, 0),
+	)
+	ctx := context.Background()
+
+	start := time.Now()
+	if _, err := b.List(ctx, ""); err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("List took %v, want at least 20ms", elapsed)
+	}
+
+	start = time.Now()
+	if _, err := b.Exists(ctx, "x"); err != nil {
+		t.Fatalf("Exists error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 20*time.Millisecond {
+		t.Errorf("Exists took %v, want no added latency", elapsed)
+	}
+}
+
+func TestLatencyCanceled(t *testing.T) {
+	b := New(memory.New(), WithLatency(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := b.NewReader(ctx, "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NewReader error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestJitterReproducible(t *testing.T) {
+	delays := func() []time.Duration {
+		b := New(memory.New(), WithLatency(time.Millisecond), WithJitter(time.Second, 42))
+		var out []time.Duration
+		for range 5 {
+			out = append(out, b.delay(OpRead))
+		}
+		return out
+	}
+
+	first, second := delays(), delays()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("delays differ with the same seed: %v vs %v", first, second)
+		}
+		if first[i] < time.Millisecond || first[i] > time.Millisecond+time.Second {
+			t.Errorf("delay %v outside [1ms, 1.001s]", first[i])
+		}
+	}
+}
+
+func TestWriteBandwidth(t *testing.T) {
+	b := New(memory.New(), WithWriteBandwidth(1<<20)) // 1 MiB/s
+	ctx := context.Background()
+
+	start := time.Now()
+	w, err := b.NewWriter(ctx, "file.bin")
+	if err != nil {
+		t.Fatalf("NewWriter error = %v", err)
+	}
+	if _, err := w.Write(make([]byte, 128<<10)); err != nil {
+		t.Fatalf("Write error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	// 128 KiB at 1 MiB/s takes 125ms.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("write took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestReadBandwidthShared(t *testing.T) {
+	inner := memory.New()
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("x"), 64<<10)
+	for _, p := range []string{"a", "b"} {
+		w, _ := inner.NewWriter(ctx, p)
+		_, _ = w.Write(data)
+		_ = w.Close()
+	}
+	b := New(inner, WithReadBandwidth(1<<20)) // 1 MiB/s
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, p := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := b.NewReader(ctx, p)
+			if err != nil {
+				t.Errorf("NewReader error = %v", err)
+				return
+			}
+			defer func() { _ = r.Close() }()
+			got, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("read %d bytes, %v; want %d bytes", len(got), err, len(data))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Two 64 KiB reads share 1 MiB/s, taking 125ms in total.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("reads took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestNotExtended(t *testing.T) {
+	b := New(basicBackend{memory.New()})
+	ctx := context.Background()
+
+	if _, err := b.Stat(ctx, "x"); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Stat error = %v, want ErrNotSupported", err)
+	}
+	if err := b.Copy(ctx, "x", "y"); !errors.Is(err, omnistorage.ErrNotSupported) {
+		t.Errorf("Copy error = %v, want ErrNotSupported", err)
+	}
+	if f := b.Features(); f.Copy || f.Stat {
+		t.Errorf("Features() = %+v, want none", f)
+	}
+}
+
+// basicBackend hides the extended methods of a backend.
+type basicBackend struct {
+	omnistorage.Backend
+}
@@ -0,0 +1,326 @@
+// Package trash provides a backend wrapper whose deletes can be undone.
+//
+// Delete moves an object under a trash prefix, in a directory named after
+// the time of the delete, to the second, instead of removing it. A path
+// deleted again within the same second goes into a numbered directory
+// beside it, such as 20240102T150405Z-1:
+//
+//	backend := trash.New(s3Backend)
+//	_ = backend.Delete(ctx, "reports/q1.pdf")
+//	// reports/q1.pdf is now at .trash/20240102T150405Z/reports/q1.pdf
+//
+//	_ = backend.Restore(ctx, "reports/q1.pdf")
+//	n, err := backend.Empty(ctx, 30*24*time.Hour)
+//
+// List hides the trash, so the wrapped backend looks as it would without
+// it. Deleting a path inside the trash removes it for good. Objects are
+// moved server-side if the wrapped backend supports Move, and copied then
+// deleted otherwise.
+//
+// Set sync.Options.TrashPrefix to route the deletes of a sync with
+// DeleteExtra through the trash.
+package trash
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// DefaultPrefix is the trash prefix used unless WithPrefix is given.
+const DefaultPrefix = ".trash/"
+
+// timeFormat names the trash directory of each delete. It sorts in time
+// order. Deletes of one path within the same second add a "-N" suffix.
+const timeFormat = "20060102T150405Z"
+
+// Option configures a trash backend.
+type Option func(*Backend)
+
+// WithPrefix sets the prefix, in the wrapped backend, under which deleted
+// objects are kept. A trailing slash is added if missing.
+func WithPrefix(prefix string) Option {
+	return func(b *Backend) {
+		prefix = strings.Trim(prefix, "/")
+		if prefix != "" {
+			b.prefix = prefix + "/"
+		}
+	}
+}
+
+// WithClock sets the function that returns the time of a delete, which
+// names its trash directory. The default is time.Now. A fixed time puts
+// every delete in one directory, so they can be restored together; a path
+// deleted again goes into a numbered directory beside it.
+func WithClock(now func() time.Time) Option {
+	return func(b *Backend) {
+		if now != nil {
+			b.now = now
+		}
+	}
+}
+
+// Item is an object in the trash.
+type Item struct {
+	// Path is the path the object was deleted from.
+	Path string
+
+	// Deleted is when the object was deleted, to the second.
+	Deleted time.Time
+
+	// TrashPath is the path of the object in the wrapped backend.
+	TrashPath string
+}
+
+// Backend wraps a backend so that deletes move objects into a trash.
+// Extended operations return omnistorage.ErrNotSupported if the wrapped
+// backend does not implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend omnistorage.Backend
+	prefix  string
+	now     func() time.Time
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend with a trash.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{backend: backend, prefix: DefaultPrefix, now: time.Now}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// Prefix returns the trash prefix, with a trailing slash.
+func (b *Backend) Prefix() string {
+	return b.prefix
+}
+
+// InTrash reports whether path is inside the trash.
+func (b *Backend) InTrash(path string) bool {
+	path = strings.TrimPrefix(path, "/")
+	return strings.HasPrefix(path, b.prefix) || path == strings.TrimSuffix(b.prefix, "/")
+}
+
+// NewWriter creates a writer for the given path.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	return b.backend.NewWriter(ctx, path, opts...)
+}
+
+// NewReader creates a reader for the given path.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	return b.backend.NewReader(ctx, path, opts...)
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	return b.backend.Exists(ctx, path)
+}
+
+// Delete moves path into the trash. Like the wrapped backend's Delete, it
+// succeeds if path does not exist. Paths inside the trash are deleted for
+// good.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	if b.InTrash(path) {
+		return b.backend.Delete(ctx, path)
+	}
+	exists, err := b.backend.Exists(ctx, path)
+	if err != nil || !exists {
+		return err
+	}
+	path = strings.TrimPrefix(path, "/")
+	stamp := b.now().UTC().Format(timeFormat)
+	dst := b.prefix + stamp + "/" + path
+	for n := 1; ; n++ {
+		exists, err := b.backend.Exists(ctx, dst)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			break
+		}
+		dst = fmt.Sprintf("%s%s-%d/%s", b.prefix, stamp, n, path)
+	}
+	return omnistorage.SmartMove(ctx, b.backend, path, b.backend, dst)
+}
+
+// List lists paths with the given prefix, except those in the trash.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	paths, err := b.backend.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := paths[:0]
+	for _, p := range paths {
+		if !b.InTrash(omnistorage.ListedPath(prefix, p)) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// Close closes the wrapped backend.
+func (b *Backend) Close() error {
+	return b.backend.Close()
+}
+
+// Trashed returns the objects in the trash, oldest first. Paths in the
+// trash that were not put there by Delete are ignored.
+func (b *Backend) Trashed(ctx context.Context) ([]Item, error) {
+	paths, err := b.backend.List(ctx, b.prefix)
+	if err != nil {
+		return nil, err
+	}
+	type trashed struct {
+		item Item
+		seq  int // of deletes within one second
+	}
+	var all []trashed
+	for _, p := range paths {
+		dir, rel, ok := strings.Cut(p, "/")
+		if !ok || rel == "" {
+			continue
+		}
+		stamp, suffix, numbered := strings.Cut(dir, "-")
+		t, err := time.Parse(timeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		var seq int
+		if numbered {
+			if seq, err = strconv.Atoi(suffix); err != nil || seq < 1 {
+				continue
+			}
+		}
+		all = append(all, trashed{Item{Path: rel, Deleted: t, TrashPath: b.prefix + p}, seq})
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if !all[i].item.Deleted.Equal(all[j].item.Deleted) {
+			return all[i].item.Deleted.Before(all[j].item.Deleted)
+		}
+		return all[i].seq < all[j].seq
+	})
+	items := make([]Item, len(all))
+	for i, t := range all {
+		items[i] = t.item
+	}
+	return items, nil
+}
+
+// Restore moves the most recently deleted copy of path back from the
+// trash. It fails with omnistorage.ErrNotFound if path is not in the
+// trash, and with omnistorage.ErrAlreadyExists if path exists.
+func (b *Backend) Restore(ctx context.Context, path string) error {
+	items, err := b.Trashed(ctx)
+	if err != nil {
+		return err
+	}
+	path = strings.TrimPrefix(path, "/")
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].Path == path {
+			return b.RestoreItem(ctx, items[i])
+		}
+	}
+	return fmt.Errorf("%w: %s is not in the trash", omnistorage.ErrNotFound, path)
+}
+
+// RestoreItem moves item back from the trash. It fails with
+// omnistorage.ErrAlreadyExists if item.Path exists.
+func (b *Backend) RestoreItem(ctx context.Context, item Item) error {
+	exists, err := b.backend.Exists(ctx, item.Path)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", omnistorage.ErrAlreadyExists, item.Path)
+	}
+	return omnistorage.SmartMove(ctx, b.backend, item.TrashPath, b.backend, item.Path)
+}
+
+// Empty removes objects deleted more than olderThan ago from the trash, or
+// every object if olderThan is 0, and returns the number removed.
+func (b *Backend) Empty(ctx context.Context, olderThan time.Duration) (int, error) {
+	items, err := b.Trashed(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := b.now().Add(-olderThan)
+	removed := 0
+	for _, item := range items {
+		if olderThan > 0 && !item.Deleted.Before(cutoff) {
+			break
+		}
+		if err := b.backend.Delete(ctx, item.TrashPath); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object within the wrapped backend.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Copy(ctx, src, dst)
+}
+
+// Move moves an object within the wrapped backend. An object it replaces
+// at dst is not kept in the trash.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Move(ctx, src, dst)
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
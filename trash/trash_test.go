@@ -0,0 +1,177 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New())
+	})
+}
+
+func write(t *testing.T, b omnistorage.Backend, p, data string) {
+	t.Helper()
+	w, err := b.NewWriter(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewWriter(%s) error = %v", p, err)
+	}
+	_, _ = io.WriteString(w, data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%s) error = %v", p, err)
+	}
+}
+
+func read(t *testing.T, b omnistorage.Backend, p string) string {
+	t.Helper()
+	r, err := b.NewReader(context.Background(), p)
+	if err != nil {
+		t.Fatalf("NewReader(%s) error = %v", p, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+// clock is a settable time for WithClock.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	c := &clock{t: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)}
+	b := New(inner, WithClock(c.now))
+
+	write(t, b, "a.txt", "v1")
+	if err := b.Delete(ctx, "a.txt"); err != nil {
+		t.Fatalf("Delete error = %v", err)
+	}
+	if ok, _ := b.Exists(ctx, "a.txt"); ok {
+		t.Error("a.txt exists after Delete")
+	}
+	if got := read(t, inner, ".trash/20240102T150405Z/a.txt"); got != "v1" {
+		t.Errorf("trashed content = %q, want %q", got, "v1")
+	}
+	if paths, _ := b.List(ctx, ""); len(paths) != 0 {
+		t.Errorf("List = %v, want the trash hidden", paths)
+	}
+
+	// A later delete of the same path is restored first
+	c.t = c.t.Add(time.Hour)
+	write(t, b, "a.txt", "v2")
+	_ = b.Delete(ctx, "a.txt")
+	items, err := b.Trashed(ctx)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("Trashed = %v, %v, want 2 items", items, err)
+	}
+	if items[0].Path != "a.txt" || !items[0].Deleted.Before(items[1].Deleted) {
+		t.Errorf("Trashed = %v, want a.txt oldest first", items)
+	}
+
+	if err := b.Restore(ctx, "a.txt"); err != nil {
+		t.Fatalf("Restore error = %v", err)
+	}
+	if got := read(t, b, "a.txt"); got != "v2" {
+		t.Errorf("restored content = %q, want %q", got, "v2")
+	}
+	if err := b.Restore(ctx, "a.txt"); !errors.Is(err, omnistorage.ErrAlreadyExists) {
+		t.Errorf("Restore over existing error = %v, want ErrAlreadyExists", err)
+	}
+	if err := b.Restore(ctx, "missing.txt"); !errors.Is(err, omnistorage.ErrNotFound) {
+		t.Errorf("Restore(missing.txt) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteSameSecond(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	c := &clock{t: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)}
+	b := New(inner, WithClock(c.now))
+
+	// Deletes of one path within a second do not overwrite each other
+	for _, v := range []string{"v1", "v2", "v3"} {
+		write(t, b, "a.txt", v)
+		if err := b.Delete(ctx, "a.txt"); err != nil {
+			t.Fatalf("Delete %s error = %v", v, err)
+		}
+		c.t = c.t.Add(100 * time.Millisecond)
+	}
+	items, err := b.Trashed(ctx)
+	if err != nil || len(items) != 3 {
+		t.Fatalf("Trashed = %v, %v, want 3 items", items, err)
+	}
+	for i, want := range []string{
+		".trash/20240102T150405Z/a.txt",
+		".trash/20240102T150405Z-1/a.txt",
+		".trash/20240102T150405Z-2/a.txt",
+	} {
+		if items[i].TrashPath != want || items[i].Path != "a.txt" {
+			t.Errorf("items[%d] = %+v, want TrashPath %s", i, items[i], want)
+		}
+		if got := read(t, inner, want); got != fmt.Sprintf("v%d", i+1) {
+			t.Errorf("%s = %q, want v%d", want, got, i+1)
+		}
+	}
+
+	if err := b.Restore(ctx, "a.txt"); err != nil {
+		t.Fatalf("Restore error = %v", err)
+	}
+	if got := read(t, b, "a.txt"); got != "v3" {
+		t.Errorf("restored content = %q, want the latest, v3", got)
+	}
+	if n, err := b.Empty(ctx, 0); err != nil || n != 2 {
+		t.Errorf("Empty(0) = %d, %v, want 2", n, err)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := New(inner, WithPrefix("/bin/"), WithClock(c.now))
+
+	for _, p := range []string{"old.txt", "new.txt"} {
+		write(t, b, p, p)
+		_ = b.Delete(ctx, p)
+		c.t = c.t.Add(48 * time.Hour)
+	}
+	n, err := b.Empty(ctx, 72*time.Hour)
+	if err != nil || n != 1 {
+		t.Fatalf("Empty = %d, %v, want 1", n, err)
+	}
+	items, _ := b.Trashed(ctx)
+	if len(items) != 1 || items[0].Path != "new.txt" || !b.InTrash(items[0].TrashPath) {
+		t.Errorf("Trashed after Empty = %v, want new.txt in bin/", items)
+	}
+
+	if n, _ := b.Empty(ctx, 0); n != 1 {
+		t.Errorf("Empty(0) removed %d, want 1", n)
+	}
+	if paths, _ := inner.List(ctx, ""); len(paths) != 0 {
+		t.Errorf("inner List = %v, want empty", paths)
+	}
+}
+
+func TestDeleteInTrash(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.New()
+	b := New(inner)
+	write(t, inner, ".trash/junk", "x")
+	if err := b.Delete(ctx, ".trash/junk"); err != nil {
+		t.Fatalf("Delete error = %v", err)
+	}
+	if paths, _ := inner.List(ctx, ""); len(paths) != 0 {
+		t.Errorf("inner List = %v, want the trashed path removed for good", paths)
+	}
+}
@@ -0,0 +1,216 @@
+package omnistorage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DirReader is implemented by backends that can list the immediate
+// children of a directory with their info, such as S3 with a delimiter.
+// Walk uses it to list only the directories it descends into, without a
+// Stat per file.
+type DirReader interface {
+	// ListDir returns the files and subdirectories directly in dir,
+	// sorted by path, with IsDir set for subdirectories. An empty dir
+	// lists the root.
+	ListDir(ctx context.Context, dir string) ([]ObjectInfo, error)
+}
+
+// WalkFunc is called by Walk for each directory and file, with its path
+// relative to the backend root. Returning fs.SkipDir from a directory
+// skips its contents, and from a file skips the rest of its directory.
+// Returning fs.SkipAll stops the walk without an error; any other error
+// stops the walk and is returned by Walk.
+type WalkFunc func(path string, info ObjectInfo) error
+
+// WalkOption configures Walk.
+type WalkOption func(*walkConfig)
+
+type walkConfig struct {
+	concurrency int
+}
+
+// WithWalkConcurrency sets how many files of a directory Walk stats at
+// once, for backends that are not DirReaders. fn is still called from a
+// single goroutine, in order. Default: 1.
+func WithWalkConcurrency(n int) WalkOption {
+	return func(c *walkConfig) {
+		c.concurrency = n
+	}
+}
+
+// Walk calls fn for each directory and file under the directory prefix,
+// depth first and in lexical order within a directory, with the file's
+// info. If prefix is a file, fn is called for it alone; "" walks the
+// whole backend.
+//
+// DirReader backends list each directory Walk descends into, a page at a
+// time on object stores, so fs.SkipDir prunes whole subtrees from the
+// listing. Other backends are listed once under prefix, their directories
+// being the prefixes the listed paths share, and the files of each
+// directory are stat'ed when Walk reaches it, if the backend is an
+// ExtendedBackend; otherwise info has only the path. Files removed since
+// the listing are skipped.
+func Walk(ctx context.Context, backend Backend, prefix string, fn WalkFunc, opts ...WalkOption) error {
+	cfg := walkConfig{concurrency: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.concurrency = max(cfg.concurrency, 1)
+
+	dir := strings.TrimSuffix(CleanPrefix(prefix), "/")
+	var list func(context.Context, string) ([]ObjectInfo, error)
+	if dr, ok := backend.(DirReader); ok {
+		list = dr.ListDir
+	} else {
+		t, err := newWalkTree(ctx, backend, dir, cfg)
+		if err != nil {
+			return err
+		}
+		list = t.list
+	}
+
+	entries, err := list(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 && dir != "" {
+		// prefix may be a file
+		info, err := walkStat(ctx, backend, dir)
+		if err != nil || info == nil {
+			return err
+		}
+		entries = []ObjectInfo{info}
+	}
+
+	err = walkEntries(ctx, entries, list, fn)
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkEntries calls fn for entries and descends into their directories.
+func walkEntries(ctx context.Context, entries []ObjectInfo, list func(context.Context, string) ([]ObjectInfo, error), fn WalkFunc) error {
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := fn(e.Path(), e)
+		if !e.IsDir() {
+			if errors.Is(err, fs.SkipDir) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if errors.Is(err, fs.SkipDir) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		children, err := list(ctx, e.Path())
+		if err != nil {
+			return err
+		}
+		if err := walkEntries(ctx, children, list, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkStat returns the info of the file at p, or nil if it does not
+// exist.
+func walkStat(ctx context.Context, backend Backend, p string) (ObjectInfo, error) {
+	if ext, ok := AsExtended(backend); ok {
+		info, err := ext.Stat(ctx, p)
+		if IsNotFound(err) || (err == nil && info.IsDir()) {
+			return nil, nil
+		}
+		return info, err
+	}
+	ok, err := backend.Exists(ctx, p)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &BasicObjectInfo{ObjectPath: p}, nil
+}
+
+// walkTree is the directory tree of a backend that is not a DirReader,
+// built from one listing.
+type walkTree struct {
+	backend  Backend
+	cfg      walkConfig
+	children map[string][]string // paths of the files and directories in each directory
+	dirs     map[string]bool
+}
+
+// newWalkTree lists the files under dir.
+func newWalkTree(ctx context.Context, backend Backend, dir string, cfg walkConfig) (*walkTree, error) {
+	paths, err := backend.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &walkTree{backend: backend, cfg: cfg, children: make(map[string][]string), dirs: make(map[string]bool)}
+	for _, p := range paths {
+		p = ListedPath(dir, p)
+		// Add p to its directory, and each new directory above it to
+		// its parent, up to dir
+		for child := p; ; {
+			parent := path.Dir(child)
+			if parent == "." {
+				parent = ""
+			}
+			t.children[parent] = append(t.children[parent], child)
+			if parent == dir || t.dirs[parent] {
+				break
+			}
+			t.dirs[parent] = true
+			child = parent
+		}
+	}
+	for d, c := range t.children {
+		slices.Sort(c)
+		t.children[d] = slices.Compact(c)
+	}
+	return t, nil
+}
+
+// list returns the entries of dir, with the files stat'ed
+// cfg.concurrency at a time.
+func (t *walkTree) list(ctx context.Context, dir string) ([]ObjectInfo, error) {
+	paths := t.children[dir]
+	entries := make([]ObjectInfo, len(paths))
+	errs := make([]error, len(paths))
+
+	sem := make(chan struct{}, t.cfg.concurrency)
+	var wg sync.WaitGroup
+	for i, p := range paths {
+		if t.dirs[p] {
+			entries[i] = &BasicObjectInfo{ObjectPath: p, ObjectIsDir: true}
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			entries[i], errs[i] = walkStat(ctx, t.backend, p)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(entries, func(e ObjectInfo) bool { return e == nil }), nil
+}
@@ -0,0 +1,171 @@
+package omnistorage_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+)
+
+// dirReader lists directories from a memory backend as a DirReader would,
+// recording the directories listed.
+type dirReader struct {
+	*memory.Backend
+	listed []string
+}
+
+func (b *dirReader) ListDir(ctx context.Context, dir string) ([]omnistorage.ObjectInfo, error) {
+	b.listed = append(b.listed, dir)
+	paths, err := b.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []omnistorage.ObjectInfo
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if name, _, isDir := strings.Cut(p, "/"); isDir {
+			if !seen[name] {
+				seen[name] = true
+				entries = append(entries, &omnistorage.BasicObjectInfo{ObjectPath: path.Join(dir, name), ObjectIsDir: true})
+			}
+			continue
+		}
+		info, err := b.Stat(ctx, path.Join(dir, p))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, info)
+	}
+	slices.SortFunc(entries, func(a, b omnistorage.ObjectInfo) int { return strings.Compare(a.Path(), b.Path()) })
+	return entries, nil
+}
+
+func newWalkBackend(t *testing.T) *memory.Backend {
+	t.Helper()
+	ctx := context.Background()
+	b := memory.New()
+	for _, p := range []string{"a.txt", "logs/x.log", "logs/2024/y.log", "logs/2024/z.log", "tmp/big.bin", "zz.txt"} {
+		w, err := b.NewWriter(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(p))
+		_ = w.Close()
+	}
+	return b
+}
+
+// walked returns the paths Walk visits, with a trailing slash for
+// directories, and checks file sizes.
+func walked(t *testing.T, backend omnistorage.Backend, prefix string, fn omnistorage.WalkFunc, opts ...omnistorage.WalkOption) []string {
+	t.Helper()
+	var got []string
+	err := omnistorage.Walk(context.Background(), backend, prefix, func(p string, info omnistorage.ObjectInfo) error {
+		if info.IsDir() {
+			got = append(got, p+"/")
+		} else {
+			got = append(got, p)
+			if _, basic := backend.(basicBackend); !basic && info.Size() != int64(len(p)) {
+				t.Errorf("%s: Size() = %d, want %d", p, info.Size(), len(p))
+			}
+		}
+		if fn != nil {
+			return fn(p, info)
+		}
+		return nil
+	}, opts...)
+	if err != nil {
+		t.Fatalf("Walk error = %v", err)
+	}
+	return got
+}
+
+func TestWalk(t *testing.T) {
+	mem := newWalkBackend(t)
+	all := []string{"a.txt", "logs/", "logs/2024/", "logs/2024/y.log", "logs/2024/z.log", "logs/x.log", "tmp/", "tmp/big.bin", "zz.txt"}
+
+	for name, backend := range map[string]omnistorage.Backend{
+		"list":       mem,
+		"basic":      basicBackend{mem},
+		"dir reader": &dirReader{Backend: mem},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := walked(t, backend, "", nil); !slices.Equal(got, all) {
+				t.Errorf("Walk = %v, want %v", got, all)
+			}
+			if got := walked(t, backend, "", nil, omnistorage.WithWalkConcurrency(4)); !slices.Equal(got, all) {
+				t.Errorf("Walk with concurrency = %v, want %v", got, all)
+			}
+			if got, want := walked(t, backend, "/logs/", nil), all[2:6]; !slices.Equal(got, want) {
+				t.Errorf("Walk(logs) = %v, want %v", got, want)
+			}
+			if got := walked(t, backend, "logs/x.log", nil); !slices.Equal(got, []string{"logs/x.log"}) {
+				t.Errorf("Walk of a file = %v", got)
+			}
+			if got := walked(t, backend, "missing", nil); len(got) != 0 {
+				t.Errorf("Walk of a missing path = %v", got)
+			}
+
+			skipDir := func(p string, info omnistorage.ObjectInfo) error {
+				if p == "logs/2024" || p == "tmp/big.bin" {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			want := []string{"a.txt", "logs/", "logs/2024/", "logs/x.log", "tmp/", "tmp/big.bin", "zz.txt"}
+			if got := walked(t, backend, "", skipDir); !slices.Equal(got, want) {
+				t.Errorf("Walk with SkipDir = %v, want %v", got, want)
+			}
+
+			skipAll := func(p string, info omnistorage.ObjectInfo) error {
+				if p == "logs/2024/y.log" {
+					return fs.SkipAll
+				}
+				return nil
+			}
+			if got := walked(t, backend, "", skipAll); !slices.Equal(got, all[:4]) {
+				t.Errorf("Walk with SkipAll = %v, want %v", got, all[:4])
+			}
+		})
+	}
+}
+
+func TestWalkPrunesDirReader(t *testing.T) {
+	b := &dirReader{Backend: newWalkBackend(t)}
+	walked(t, b, "", func(p string, info omnistorage.ObjectInfo) error {
+		if p == "logs" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if want := []string{"", "tmp"}; !slices.Equal(b.listed, want) {
+		t.Errorf("listed %v, want %v", b.listed, want)
+	}
+}
+
+func TestWalkError(t *testing.T) {
+	errStop := errors.New("stop")
+	var visited int
+	err := omnistorage.Walk(context.Background(), newWalkBackend(t), "", func(p string, info omnistorage.ObjectInfo) error {
+		visited++
+		if p == "logs/2024" {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) || visited != 3 {
+		t.Errorf("Walk error = %v after %d paths, want errStop after 3", err, visited)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := omnistorage.Walk(ctx, newWalkBackend(t), "", func(string, omnistorage.ObjectInfo) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("Walk with canceled context error = %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+// Package worm provides a backend wrapper enforcing write once, read many
+// semantics.
+//
+// Writes, copies and moves that would replace an existing object, and
+// deletes and moves that would remove one, fail with
+// omnistorage.ErrImmutable, even when the wrapped backend allows them:
+//
+//	backend := worm.New(s3Backend,
+//	    worm.WithPrefixes("archive/"),
+//	    worm.WithGracePeriod(time.Hour),
+//	)
+//	err := backend.Delete(ctx, "archive/2024/q1.tar")
+//	// errors.Is(err, omnistorage.ErrImmutable)
+//
+// New objects can always be written. Objects modified less than the grace
+// period ago can still be replaced or removed, so a job can fix what it
+// just wrote.
+//
+// The wrapper checks for an existing object before each change, so two
+// writers racing to create the same path may both succeed. Use it to stop
+// buggy jobs, alongside object locks for guarantees against deliberate
+// changes.
+package worm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/grokify/omnistorage"
+)
+
+// Option configures a WORM backend.
+type Option func(*Backend)
+
+// WithPrefixes limits protection to paths with one of the given prefixes,
+// matched as strings, so "archive" also protects "archive2.txt"; end a
+// prefix with a slash to protect only a directory. By default every path
+// is protected.
+func WithPrefixes(prefixes ...string) Option {
+	return func(b *Backend) {
+		for _, p := range prefixes {
+			b.prefixes = append(b.prefixes, strings.TrimPrefix(p, "/"))
+		}
+	}
+}
+
+// WithGracePeriod lets objects modified less than d ago be replaced or
+// removed. Objects whose modification time the wrapped backend does not
+// report are protected at once. Default: 0, no grace period.
+func WithGracePeriod(d time.Duration) Option {
+	return func(b *Backend) {
+		b.grace = d
+	}
+}
+
+// WithClock sets the function that returns the current time, for the
+// grace period. The default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(b *Backend) {
+		if now != nil {
+			b.now = now
+		}
+	}
+}
+
+// Backend wraps a backend, rejecting changes to existing objects.
+// Extended operations return omnistorage.ErrNotSupported if the wrapped
+// backend does not implement omnistorage.ExtendedBackend.
+type Backend struct {
+	backend  omnistorage.Backend
+	prefixes []string
+	grace    time.Duration
+	now      func() time.Time
+}
+
+// Ensure Backend implements ExtendedBackend.
+var _ omnistorage.ExtendedBackend = (*Backend)(nil)
+
+// New wraps backend with WORM protection.
+func New(backend omnistorage.Backend, opts ...Option) *Backend {
+	b := &Backend{backend: backend, now: time.Now}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() omnistorage.Backend {
+	return b.backend
+}
+
+// Protected reports whether path is under a protected prefix. The path is
+// cleaned first, as backends clean it, so "tmp/../archive/x" and
+// "//archive/x" are protected like "archive/x".
+func (b *Backend) Protected(p string) bool {
+	if len(b.prefixes) == 0 {
+		return true
+	}
+	p = clean(p)
+	for _, prefix := range b.prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// clean returns p cleaned and relative to the backend root.
+func clean(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// check returns an error wrapping omnistorage.ErrImmutable if path is a
+// protected object outside the grace period.
+func (b *Backend) check(ctx context.Context, path string) error {
+	if !b.Protected(path) {
+		return nil
+	}
+	path = clean(path)
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		info, err := ext.Stat(ctx, path)
+		if omnistorage.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if b.grace > 0 && !info.ModTime().IsZero() && b.now().Sub(info.ModTime()) < b.grace {
+			return nil
+		}
+	} else {
+		exists, err := b.backend.Exists(ctx, path)
+		if err != nil || !exists {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s", omnistorage.ErrImmutable, path)
+}
+
+// NewWriter creates a writer for path. It fails with
+// omnistorage.ErrImmutable if path is a protected object.
+func (b *Backend) NewWriter(ctx context.Context, path string, opts ...omnistorage.WriterOption) (io.WriteCloser, error) {
+	if err := b.check(ctx, path); err != nil {
+		return nil, err
+	}
+	return b.backend.NewWriter(ctx, path, opts...)
+}
+
+// NewReader creates a reader for the given path.
+func (b *Backend) NewReader(ctx context.Context, path string, opts ...omnistorage.ReaderOption) (io.ReadCloser, error) {
+	return b.backend.NewReader(ctx, path, opts...)
+}
+
+// Exists checks if a path exists.
+func (b *Backend) Exists(ctx context.Context, path string) (bool, error) {
+	return b.backend.Exists(ctx, path)
+}
+
+// Delete removes path. It fails with omnistorage.ErrImmutable if path is a
+// protected object.
+func (b *Backend) Delete(ctx context.Context, path string) error {
+	if err := b.check(ctx, path); err != nil {
+		return err
+	}
+	return b.backend.Delete(ctx, path)
+}
+
+// List lists paths with the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	return b.backend.List(ctx, prefix)
+}
+
+// Close closes the wrapped backend.
+func (b *Backend) Close() error {
+	return b.backend.Close()
+}
+
+// Stat returns metadata about an object.
+func (b *Backend) Stat(ctx context.Context, path string) (omnistorage.ObjectInfo, error) {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return nil, omnistorage.ErrNotSupported
+	}
+	return ext.Stat(ctx, path)
+}
+
+// Mkdir creates a directory.
+func (b *Backend) Mkdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Mkdir(ctx, path)
+}
+
+// Rmdir removes an empty directory.
+func (b *Backend) Rmdir(ctx context.Context, path string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	return ext.Rmdir(ctx, path)
+}
+
+// Copy copies an object. It fails with omnistorage.ErrImmutable if dst is
+// a protected object.
+func (b *Backend) Copy(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.check(ctx, dst); err != nil {
+		return err
+	}
+	return ext.Copy(ctx, src, dst)
+}
+
+// Move moves an object. It fails with omnistorage.ErrImmutable if src or
+// dst is a protected object.
+func (b *Backend) Move(ctx context.Context, src, dst string) error {
+	ext, ok := omnistorage.AsExtended(b.backend)
+	if !ok {
+		return omnistorage.ErrNotSupported
+	}
+	if err := b.check(ctx, src); err != nil {
+		return err
+	}
+	if err := b.check(ctx, dst); err != nil {
+		return err
+	}
+	return ext.Move(ctx, src, dst)
+}
+
+// Features returns the features of the wrapped backend.
+func (b *Backend) Features() omnistorage.Features {
+	if ext, ok := omnistorage.AsExtended(b.backend); ok {
+		return ext.Features()
+	}
+	return omnistorage.Features{}
+}
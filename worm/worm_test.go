@@ -0,0 +1,114 @@
+package worm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grokify/omnistorage"
+	"github.com/grokify/omnistorage/backend/memory"
+	"github.com/grokify/omnistorage/backendtest"
+)
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformanceTests(t, func(t *testing.T) omnistorage.Backend {
+		return New(memory.New(), WithPrefixes("archive/"))
+	})
+}
+
+func write(b omnistorage.Backend, p, data string) error {
+	w, err := b.NewWriter(context.Background(), p)
+	if err != nil {
+		return err
+	}
+	_, _ = io.WriteString(w, data)
+	return w.Close()
+}
+
+func TestImmutable(t *testing.T) {
+	ctx := context.Background()
+	b := New(memory.New(), WithPrefixes("/archive/"))
+
+	for _, p := range []string{"archive/a.txt", "archive/b.txt", "scratch/a.txt"} {
+		if err := write(b, p, "v1"); err != nil {
+			t.Fatalf("write(%s) error = %v", p, err)
+		}
+	}
+
+	immutable := map[string]error{
+		"overwrite": write(b, "archive/a.txt", "v2"),
+		"delete":    b.Delete(ctx, "/archive/a.txt"),
+		"copy over": b.Copy(ctx, "scratch/a.txt", "archive/a.txt"),
+		"move from": b.Move(ctx, "archive/a.txt", "scratch/b.txt"),
+		"move over": b.Move(ctx, "scratch/a.txt", "archive/b.txt"),
+	}
+	for name, err := range immutable {
+		if !errors.Is(err, omnistorage.ErrImmutable) {
+			t.Errorf("%s error = %v, want ErrImmutable", name, err)
+		}
+	}
+
+	allowed := map[string]error{
+		"new object":         write(b, "archive/c.txt", "v1"),
+		"copy to new":        b.Copy(ctx, "archive/a.txt", "archive/d.txt"),
+		"unprotected write":  write(b, "scratch/a.txt", "v2"),
+		"unprotected delete": b.Delete(ctx, "scratch/a.txt"),
+		"missing delete":     b.Delete(ctx, "archive/missing.txt"),
+	}
+	for name, err := range allowed {
+		if err != nil {
+			t.Errorf("%s error = %v", name, err)
+		}
+	}
+
+	paths, _ := b.List(ctx, "archive/")
+	if len(paths) != 4 {
+		t.Errorf("List(archive/) = %v, want 4 objects", paths)
+	}
+}
+
+func TestUncleanPaths(t *testing.T) {
+	ctx := context.Background()
+	b := New(memory.New(), WithPrefixes("archive/"))
+	if err := write(b, "archive/x", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"tmp/../archive/x", "./archive/x", "//archive/x"} {
+		if err := b.Delete(ctx, p); !errors.Is(err, omnistorage.ErrImmutable) {
+			t.Errorf("Delete(%q) error = %v, want ErrImmutable", p, err)
+		}
+		if err := write(b, p, "v2"); !errors.Is(err, omnistorage.ErrImmutable) {
+			t.Errorf("write(%q) error = %v, want ErrImmutable", p, err)
+		}
+		if !b.Protected(p) {
+			t.Errorf("Protected(%q) = false", p)
+		}
+	}
+	if exists, _ := b.Exists(ctx, "archive/x"); !exists {
+		t.Error("archive/x was removed")
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	b := New(memory.New(), WithGracePeriod(time.Hour), WithClock(func() time.Time { return now }))
+
+	if err := write(b, "a.txt", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := write(b, "a.txt", "v2"); err != nil {
+		t.Errorf("overwrite within the grace period error = %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := write(b, "a.txt", "v3"); !errors.Is(err, omnistorage.ErrImmutable) {
+		t.Errorf("overwrite after the grace period error = %v, want ErrImmutable", err)
+	}
+	if err := b.Delete(ctx, "a.txt"); !errors.Is(err, omnistorage.ErrImmutable) {
+		t.Errorf("delete after the grace period error = %v, want ErrImmutable", err)
+	}
+}